@@ -0,0 +1,88 @@
+// Package adminops holds the moderator actions behind the admin dashboard and the admin
+// JSON API. Handlers only parse the request and render the outcome, so a decision made from
+// a dashboard form and one made through /admin/api validate and audit identically.
+package adminops
+
+import (
+	"errors"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/observability"
+	"gorm.io/gorm"
+)
+
+// Ops runs admin operations against the database
+type Ops struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	metrics *observability.Registry
+}
+
+// New creates the admin operations for a database and config; publications are counted in
+// metrics
+func New(db *gorm.DB, cfg *config.Config, metrics *observability.Registry) *Ops {
+	return &Ops{db: db, cfg: cfg, metrics: metrics}
+}
+
+// Kind classifies why an operation failed, so each transport maps it to a status in one place
+type Kind int
+
+const (
+	KindInternal Kind = iota // unexpected failure; the cause is logged, not shown
+	KindInvalid              // the request itself is malformed or not allowed
+	KindNotFound             // the candidate, event, or venue does not exist
+	KindConflict             // the entity is not in a state the operation applies to
+)
+
+// Error is a failed operation with a message safe to show the moderator
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error // underlying cause, if any
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the kind of an operation error; errors not raised by adminops are internal
+func KindOf(err error) Kind {
+	var opErr *Error
+	if errors.As(err, &opErr) {
+		return opErr.Kind
+	}
+	return KindInternal
+}
+
+// MessageOf returns the moderator-facing message of an operation error, or fallback for
+// errors not raised by adminops
+func MessageOf(err error, fallback string) string {
+	var opErr *Error
+	if errors.As(err, &opErr) {
+		return opErr.Message
+	}
+	return fallback
+}
+
+func invalid(message string) error {
+	return &Error{Kind: KindInvalid, Message: message}
+}
+
+func notFound(message string) error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func conflict(message string) error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func internal(message string, err error) error {
+	return &Error{Kind: KindInternal, Message: message, Err: err}
+}
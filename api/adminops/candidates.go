@@ -0,0 +1,531 @@
+package adminops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Moderation actions on a candidate
+const (
+	ActionApprove = "approve"
+	ActionReject  = "reject"
+)
+
+// MaxBulkCandidates caps how many candidates one bulk decision may touch
+const MaxBulkCandidates = 100
+
+// ModerateRequest approves or rejects one candidate
+type ModerateRequest struct {
+	CandidateID uuid.UUID
+	Action      string // approve, reject
+	Reason      string
+	Quiet       bool // on approve, mark the event quiet; false leaves its current flag alone
+}
+
+// ModerateResult is a candidate's state after a decision
+type ModerateResult struct {
+	CandidateID      uuid.UUID  `json:"candidate_id"`
+	Status           string     `json:"status"`
+	PublishedEventID *uuid.UUID `json:"published_event_id,omitempty"`
+}
+
+// Moderate records an approve or reject decision on a candidate. Approving promotes it to a
+// public event, or links it to the existing event it corroborates.
+func (o *Ops) Moderate(req ModerateRequest, actor services.Actor) (*ModerateResult, error) {
+	if req.Action != ActionApprove && req.Action != ActionReject {
+		return nil, invalid("Invalid action")
+	}
+
+	var result *ModerateResult
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = o.moderate(tx, req, actor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if req.Action == ActionApprove {
+		o.metrics.EventPublished("admin")
+	}
+	return result, nil
+}
+
+func (o *Ops) moderate(tx *gorm.DB, req ModerateRequest, actor services.Actor) (*ModerateResult, error) {
+	var candidate models.EventCandidate
+	if err := tx.Preload("Flyer.Submission").Where("id = ?", req.CandidateID).First(&candidate).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, notFound("Event not found")
+		}
+		return nil, internal("Failed to load event", err)
+	}
+
+	publishResult := "blocked"
+	if req.Action == ActionApprove {
+		publishResult = "published"
+	}
+
+	updates := map[string]interface{}{
+		"publish_result": publishResult,
+	}
+	if req.Reason != "" {
+		updates["publication_reason"] = req.Reason
+	}
+	previous := candidate.PublishResult
+	// The preloaded flyer and submission are only read; saving them back would upsert both
+	if err := tx.Model(&candidate).Omit(clause.Associations).Updates(updates).Error; err != nil {
+		return nil, internal("Failed to update event", err)
+	}
+
+	if req.Action == ActionApprove {
+		if err := o.promoteToPublicEvent(tx, &candidate); err != nil {
+			return nil, internal("Failed to publish event: "+err.Error(), err)
+		}
+		if req.Quiet && candidate.PublishedEventID != nil {
+			if err := services.SetEventQuiet(tx, *candidate.PublishedEventID, true, actor); err != nil {
+				return nil, internal("Failed to mark event quiet", err)
+			}
+		}
+	}
+
+	latency := time.Since(candidate.CreatedAt)
+	if err := services.RecordAudit(tx, services.AuditEntry{
+		EntityType:      services.AuditEntityCandidate,
+		EntityID:        candidate.ID,
+		Action:          services.CandidateDecisionAction(publishResult),
+		Actor:           actor,
+		DecisionLatency: &latency,
+		Changes: map[string]interface{}{
+			"publish_result": map[string]interface{}{"from": previous, "to": publishResult},
+		},
+		Metadata: map[string]interface{}{
+			"reason": req.Reason,
+		},
+	}); err != nil {
+		return nil, internal("Failed to record decision", err)
+	}
+
+	return &ModerateResult{
+		CandidateID:      candidate.ID,
+		Status:           publishResult,
+		PublishedEventID: candidate.PublishedEventID,
+	}, nil
+}
+
+// BulkModerateRequest applies one decision to several candidates
+type BulkModerateRequest struct {
+	CandidateIDs []uuid.UUID
+	Action       string // approve, reject
+	Reason       string
+	Quiet        bool
+}
+
+// BulkItemResult is one candidate's outcome within a bulk decision
+type BulkItemResult struct {
+	CandidateID      uuid.UUID  `json:"candidate_id"`
+	Status           string     `json:"status,omitempty"`
+	PublishedEventID *uuid.UUID `json:"published_event_id,omitempty"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// BulkModerateResult reports every candidate in a bulk decision
+type BulkModerateResult struct {
+	Results   []BulkItemResult `json:"results"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+}
+
+// BulkModerate decides each candidate in its own transaction, exactly as Moderate would,
+// so one candidate that fails (deleted meanwhile, say) doesn't undo the others. Only an
+// invalid request fails as a whole; per-candidate failures are reported in the result.
+func (o *Ops) BulkModerate(req BulkModerateRequest, actor services.Actor) (*BulkModerateResult, error) {
+	if req.Action != ActionApprove && req.Action != ActionReject {
+		return nil, invalid("Invalid action")
+	}
+	if len(req.CandidateIDs) == 0 {
+		return nil, invalid("No candidates selected")
+	}
+	if len(req.CandidateIDs) > MaxBulkCandidates {
+		return nil, invalid(fmt.Sprintf("At most %d candidates can be moderated at once", MaxBulkCandidates))
+	}
+
+	result := &BulkModerateResult{Results: make([]BulkItemResult, 0, len(req.CandidateIDs))}
+	seen := make(map[uuid.UUID]bool, len(req.CandidateIDs))
+	for _, id := range req.CandidateIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		item := BulkItemResult{CandidateID: id}
+		decided, err := o.Moderate(ModerateRequest{
+			CandidateID: id,
+			Action:      req.Action,
+			Reason:      req.Reason,
+			Quiet:       req.Quiet,
+		}, actor)
+		if err != nil {
+			item.Error = MessageOf(err, "Failed to update event")
+			result.Failed++
+		} else {
+			item.Status = decided.Status
+			item.PublishedEventID = decided.PublishedEventID
+			result.Succeeded++
+		}
+		result.Results = append(result.Results, item)
+	}
+	return result, nil
+}
+
+// ModerateAction is one decision within ModerateActions
+type ModerateAction struct {
+	CandidateID uuid.UUID
+	Action      string // approve, reject
+	Reason      string
+}
+
+// ActionFailure is a decision ModerateActions could not apply
+type ActionFailure struct {
+	CandidateID uuid.UUID `json:"id"`
+	Error       string    `json:"error"`
+}
+
+// ModerateActionsResult summarizes ModerateActions; Decided lists the candidates that changed
+type ModerateActionsResult struct {
+	Succeeded int             `json:"succeeded"`
+	Failed    []ActionFailure `json:"failed"`
+	Decided   []uuid.UUID     `json:"-"`
+}
+
+// ModerateActions applies a separate decision to each of up to MaxBulkCandidates candidates
+// in one transaction, so the decisions that succeed are committed together. Each decision
+// runs in its own savepoint: one that fails (an unknown candidate, say) is rolled back and
+// reported without undoing the rest. A candidate listed twice fails the second time.
+func (o *Ops) ModerateActions(actions []ModerateAction, actor services.Actor) (*ModerateActionsResult, error) {
+	if len(actions) == 0 {
+		return nil, invalid("No actions given")
+	}
+	if len(actions) > MaxBulkCandidates {
+		return nil, invalid(fmt.Sprintf("At most %d actions can be applied at once", MaxBulkCandidates))
+	}
+	for _, action := range actions {
+		if action.Action != ActionApprove && action.Action != ActionReject {
+			return nil, invalid(fmt.Sprintf("Invalid action %q for %s", action.Action, action.CandidateID))
+		}
+	}
+
+	var result *ModerateActionsResult
+	approved := 0
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		result = &ModerateActionsResult{Failed: []ActionFailure{}}
+		approved = 0
+		seen := make(map[uuid.UUID]bool, len(actions))
+		for _, action := range actions {
+			if seen[action.CandidateID] {
+				result.Failed = append(result.Failed, ActionFailure{CandidateID: action.CandidateID, Error: "Candidate appears more than once"})
+				continue
+			}
+			seen[action.CandidateID] = true
+
+			err := tx.Transaction(func(savepoint *gorm.DB) error {
+				_, err := o.moderate(savepoint, ModerateRequest{
+					CandidateID: action.CandidateID,
+					Action:      action.Action,
+					Reason:      action.Reason,
+				}, actor)
+				return err
+			})
+			if err != nil {
+				if KindOf(err) == KindInternal {
+					log.Printf("Bulk moderation of candidate %s failed: %v", action.CandidateID, err)
+				}
+				result.Failed = append(result.Failed, ActionFailure{CandidateID: action.CandidateID, Error: MessageOf(err, "Failed to update event")})
+				continue
+			}
+			result.Succeeded++
+			result.Decided = append(result.Decided, action.CandidateID)
+			if action.Action == ActionApprove {
+				approved++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, internal("Failed to apply moderation actions", err)
+	}
+	for i := 0; i < approved; i++ {
+		o.metrics.EventPublished("admin")
+	}
+	return result, nil
+}
+
+// RestoreRequest takes a blocked candidate back out of blocked
+type RestoreRequest struct {
+	CandidateID uuid.UUID
+	Action      string // review (default), approve
+	Reason      string // why the block was wrong
+}
+
+// Restore moves a blocked candidate back to needs_review, or approves and publishes it
+// directly, recording the reason in the audit log
+func (o *Ops) Restore(req RestoreRequest, actor services.Actor) (*models.EventCandidate, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, invalid("A reason is required")
+	}
+	action := req.Action
+	if action == "" {
+		action = services.RestoreActionReview
+	}
+
+	var candidate *models.EventCandidate
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		candidate, err = services.RestoreBlockedCandidate(tx, req.CandidateID, action, reason, actor)
+		if err != nil || action != services.RestoreActionApprove {
+			return err
+		}
+		if err := o.promoteToPublicEvent(tx, candidate); err != nil {
+			return err
+		}
+		latency := time.Since(candidate.CreatedAt)
+		return services.RecordAudit(tx, services.AuditEntry{
+			EntityType:      services.AuditEntityCandidate,
+			EntityID:        candidate.ID,
+			Action:          services.AuditActionCandidatePublished,
+			Actor:           actor,
+			DecisionLatency: &latency,
+			Changes: map[string]interface{}{
+				"publish_result": map[string]interface{}{"from": "blocked", "to": "published"},
+			},
+			Metadata: map[string]interface{}{
+				"reason": reason,
+			},
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRestoreAction):
+			return nil, invalid("Invalid action")
+		case errors.Is(err, services.ErrRestoreReasonRequired):
+			return nil, invalid("A reason is required")
+		case errors.Is(err, services.ErrCandidateNotFound):
+			return nil, notFound("Event not found")
+		case errors.Is(err, services.ErrCandidateNotBlocked):
+			return nil, conflict("Candidate is not blocked")
+		default:
+			return nil, internal("Failed to restore candidate", err)
+		}
+	}
+	if action == services.RestoreActionApprove {
+		o.metrics.EventPublished("admin")
+	}
+	return candidate, nil
+}
+
+// errUnreadableEditedDate rejects a corrected date on a published candidate that can't be
+// turned into the event's start time
+var errUnreadableEditedDate = errors.New("edited date could not be parsed")
+
+// EditCandidate changes a candidate's extracted fields. Field names and values are
+// validated against the field schema; a nil value clears the field. A published
+// candidate's event follows the edit in the same transaction: its title, start time, and
+// venue are updated when those fields change. It returns the candidate's fields after the
+// edit.
+func (o *Ops) EditCandidate(candidateID uuid.UUID, update map[string]interface{}, actor services.Actor) (map[string]interface{}, error) {
+	if len(update) == 0 {
+		return nil, invalid("Invalid request format")
+	}
+	cleaned, err := services.ActiveFieldSchema().ValidateFieldUpdate(update)
+	if err != nil {
+		return nil, invalid(err.Error())
+	}
+
+	var fields map[string]interface{}
+	err = o.db.Transaction(func(tx *gorm.DB) error {
+		var candidate models.EventCandidate
+		if err := tx.First(&candidate, "id = ?", candidateID).Error; err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
+			return fmt.Errorf("failed to parse event fields: %v", err)
+		}
+
+		// Dashboard forms post every field they show; only real changes are applied
+		changes := make(map[string]interface{}, len(cleaned))
+		for name, value := range cleaned {
+			if reflect.DeepEqual(fields[name], value) {
+				continue
+			}
+			changes[name] = map[string]interface{}{"from": fields[name], "to": value}
+			if value == nil {
+				delete(fields, name)
+			} else {
+				fields[name] = value
+			}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		fieldsJSON, err := json.Marshal(fields)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event fields: %v", err)
+		}
+		if err := tx.Model(&candidate).Updates(map[string]interface{}{
+			"fields":      string(fieldsJSON),
+			"search_text": services.CandidateSearchText(string(fieldsJSON)),
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := services.RecordAudit(tx, services.AuditEntry{
+			EntityType: services.AuditEntityCandidate,
+			EntityID:   candidate.ID,
+			Action:     services.AuditActionCandidateEdited,
+			Actor:      actor,
+			Changes:    changes,
+		}); err != nil {
+			return err
+		}
+
+		if candidate.PublishedEventID == nil {
+			return nil
+		}
+		return o.syncEditedEvent(tx, &candidate, fields, changes, actor)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, notFound("Candidate not found")
+		case errors.Is(err, services.ErrEventNotFound):
+			return nil, notFound("Published event not found")
+		case errors.Is(err, services.ErrInvalidEventEdit):
+			return nil, invalid(err.Error())
+		case errors.Is(err, errUnreadableEditedDate):
+			return nil, invalid("Could not read the edited date")
+		default:
+			return nil, internal("Failed to update candidate fields", err)
+		}
+	}
+	return fields, nil
+}
+
+// syncEditedEvent carries a published candidate's edited title, date, and venue over to
+// its event. A cleared venue leaves the event where it is.
+func (o *Ops) syncEditedEvent(tx *gorm.DB, candidate *models.EventCandidate, fields, edited map[string]interface{}, actor services.Actor) error {
+	var edit services.EventEdit
+	if _, ok := edited["title"]; ok {
+		title, _ := fields["title"].(string)
+		edit.Title = &title
+	}
+
+	if _, ok := edited["date_time"]; ok {
+		parsed, _, ok := services.ParseCandidateStart(tx, candidate, fields, services.RegionLocation(o.cfg))
+		if !ok {
+			return errUnreadableEditedDate
+		}
+		edit.StartTs = &parsed.Time
+	}
+
+	_, venueEdited := edited["venue"]
+	_, addressEdited := edited["address"]
+	if venueEdited || addressEdited {
+		venue, isNew, err := services.CandidateVenue(tx, fields)
+		if err != nil {
+			return err
+		}
+		if isNew {
+			if err := tx.Create(venue).Error; err != nil {
+				return fmt.Errorf("failed to create venue: %v", err)
+			}
+		}
+		if venue != nil {
+			edit.VenueID = &venue.ID
+		}
+	}
+
+	if edit.Empty() {
+		return nil
+	}
+	_, err := services.EditEvent(tx, *candidate.PublishedEventID, edit, actor)
+	return err
+}
+
+// promoteToPublicEvent creates an Event record from an approved EventCandidate
+func (o *Ops) promoteToPublicEvent(tx *gorm.DB, candidate *models.EventCandidate) error {
+	draft, err := services.DraftEventFromCandidate(tx, candidate, services.RegionLocation(o.cfg))
+	if err != nil {
+		return err
+	}
+
+	if existingEvent := draft.Existing; existingEvent != nil {
+		// Event already exists; this candidate corroborates it
+		if err := linkCandidateToEvent(tx, candidate, existingEvent.ID); err != nil {
+			return err
+		}
+		if existingEvent.ModerationState != services.EventStateApproved {
+			if err := services.TransitionEventState(tx, existingEvent.ID, services.EventTransition{
+				To:          services.EventStateApproved,
+				Actor:       services.ActorAdmin,
+				Reason:      services.TransitionReasonRepublished,
+				CandidateID: &candidate.ID,
+			}); err != nil {
+				return err
+			}
+			if err := services.RecordEventHistoryByID(tx, existingEvent.ID); err != nil {
+				return err
+			}
+			return services.RecordEventChange(tx, existingEvent.ID, services.ChangeTypeUpdated, services.ChangeReasonRepublished)
+		}
+		return nil // Already published
+	}
+
+	event := draft.Event
+	if draft.NewVenue {
+		if err := tx.Create(draft.Venue).Error; err != nil {
+			return fmt.Errorf("failed to create venue: %v", err)
+		}
+		event.VenueID = &draft.Venue.ID
+	}
+
+	// Create the event
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to create event: %v", err)
+	}
+	if err := services.RecordEventCreated(tx, &event, services.EventTransition{
+		Actor:       services.ActorAdmin,
+		Reason:      services.TransitionReasonManualApproved,
+		CandidateID: &candidate.ID,
+	}); err != nil {
+		return err
+	}
+	if err := services.RecordEventChange(tx, event.ID, services.ChangeTypeCreated, ""); err != nil {
+		return err
+	}
+	if err := services.RecordEventHistory(tx, &event); err != nil {
+		return err
+	}
+
+	return linkCandidateToEvent(tx, candidate, event.ID)
+}
+
+// linkCandidateToEvent records which public event a candidate published or corroborated
+func linkCandidateToEvent(tx *gorm.DB, candidate *models.EventCandidate, eventID uuid.UUID) error {
+	candidate.PublishedEventID = &eventID
+	if err := tx.Model(candidate).Omit(clause.Associations).Update("published_event_id", eventID).Error; err != nil {
+		return fmt.Errorf("failed to link candidate to event: %v", err)
+	}
+	return nil
+}
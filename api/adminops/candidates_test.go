@@ -0,0 +1,170 @@
+package adminops
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// TestEditCandidateKeepsSearchTextInSync checks every edit that changes a candidate's fields
+// rewrites its search_text from the edited fields in the same update
+func TestEditCandidateKeepsSearchTextInSync(t *testing.T) {
+	stored := `{"title":"Open Mic","venue":"The Grange Hall","description":"Sign up at the door"}`
+
+	tests := []struct {
+		name       string
+		update     map[string]interface{}
+		wantWrite  bool
+		wantFields string
+		wantSearch string
+	}{
+		{
+			name:       "title change",
+			update:     map[string]interface{}{"title": "Open Mic Night"},
+			wantWrite:  true,
+			wantFields: `{"description":"Sign up at the door","title":"Open Mic Night","venue":"The Grange Hall"}`,
+			wantSearch: "open mic night the grange hall sign up at the door",
+		},
+		{
+			name:       "cleared description",
+			update:     map[string]interface{}{"description": nil},
+			wantWrite:  true,
+			wantFields: `{"title":"Open Mic","venue":"The Grange Hall"}`,
+			wantSearch: "open mic the grange hall",
+		},
+		{
+			name:       "added organizer",
+			update:     map[string]interface{}{"organizer": "Friends of the Library"},
+			wantWrite:  true,
+			wantFields: `{"description":"Sign up at the door","organizer":"Friends of the Library","title":"Open Mic","venue":"The Grange Hall"}`,
+			wantSearch: "open mic the grange hall friends of the library sign up at the door",
+		},
+		{
+			name:   "form posting unchanged values writes nothing",
+			update: map[string]interface{}{"title": "Open Mic", "venue": "The Grange Hall"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			candidateID := uuid.New()
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT * FROM "event_candidates" WHERE id = $1`).
+				WithArgs(candidateID, testdb.Any).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "fields"}).AddRow(candidateID.String(), stored))
+			if tt.wantWrite {
+				mock.ExpectExec(`UPDATE "event_candidates" SET "fields"=$1,"search_text"=$2 WHERE "id" = $3`).
+					WithArgs(tt.wantFields, tt.wantSearch, candidateID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(testdb.IDs(uuid.New()))
+			}
+			mock.ExpectCommit()
+
+			ops := New(db, &config.Config{}, nil)
+			if _, err := ops.EditCandidate(candidateID, tt.update, services.Actor{Type: services.ActorAdmin, AdminID: "ops"}); err != nil {
+				t.Fatalf("EditCandidate() error = %v", err)
+			}
+		})
+	}
+}
+
+// TestRestore takes blocked candidates back out of blocked: to review, or approved and
+// promoted to a public event in the same transaction
+func TestRestore(t *testing.T) {
+	tests := []struct {
+		name          string
+		action        string
+		reason        string
+		publishResult string // the stored candidate's; empty when it doesn't exist
+		wantResult    string
+		wantKind      Kind
+	}{
+		{name: "back to review by default", reason: "false positive", publishResult: "blocked", wantResult: "needs_review"},
+		{name: "approved and published", action: services.RestoreActionApprove, reason: "false positive", publishResult: "blocked", wantResult: "published"},
+		{name: "reason required", reason: "   ", publishResult: "blocked", wantKind: KindInvalid},
+		{name: "unknown action", action: "publish", reason: "false positive", publishResult: "blocked", wantKind: KindInvalid},
+		{name: "candidate not blocked", reason: "false positive", publishResult: "needs_review", wantKind: KindConflict},
+		{name: "candidate not found", reason: "false positive", wantKind: KindNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			candidateID, eventID := uuid.New(), uuid.New()
+			if strings.TrimSpace(tt.reason) != "" {
+				mock.ExpectBegin()
+			}
+			if tt.action != "publish" && strings.TrimSpace(tt.reason) != "" {
+				rows := sqlmock.NewRows([]string{"id", "fields", "publish_result", "publication_reason"})
+				if tt.publishResult != "" {
+					rows.AddRow(candidateID.String(), `{"title":"Open Mic"}`, tt.publishResult, "moderation: flagged")
+				}
+				mock.ExpectQuery(`SELECT * FROM "event_candidates" WHERE id = $1 ORDER BY "event_candidates"."id" LIMIT $2 FOR UPDATE`).
+					WithArgs(candidateID, 1).
+					WillReturnRows(rows)
+			}
+			if tt.wantResult == "" {
+				if strings.TrimSpace(tt.reason) != "" {
+					mock.ExpectRollback()
+				}
+			} else {
+				columns := `"publication_reason"=$1,"publish_result"=$2`
+				args := []driver.Value{"restored: false positive", tt.wantResult}
+				if tt.wantResult == "needs_review" {
+					columns += `,"review_reason"=$3`
+					args = append(args, services.ReviewReasonRestored)
+				}
+				mock.ExpectExec(`UPDATE "event_candidates" SET ` + columns).
+					WithArgs(append(args, candidateID)...).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+					WithArgs(append([]driver.Value{testdb.Any, testdb.Any, string(services.AuditActionCandidateRestored)}, testdb.AnyArgs(9)...)...).
+					WillReturnRows(testdb.IDs(uuid.New()))
+				if tt.wantResult == "published" {
+					mock.ExpectQuery(`SELECT * FROM "events" WHERE canonical_key = $1`).
+						WillReturnRows(sqlmock.NewRows([]string{"id"}))
+					mock.ExpectQuery(`INSERT INTO "events"`).
+						WillReturnRows(testdb.IDs(eventID))
+					mock.ExpectQuery(`INSERT INTO "event_state_transitions"`).
+						WithArgs(eventID, nil, services.EventStateApproved, services.ActorAdmin, services.TransitionReasonManualApproved, candidateID, nil, testdb.Any, testdb.Any).
+						WillReturnRows(testdb.IDs(uuid.New()))
+					mock.ExpectExec(`UPDATE "event_history" SET "valid_to"`).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectQuery(`INSERT INTO "event_history"`).WillReturnRows(testdb.IDs(uuid.New()))
+					mock.ExpectExec(`UPDATE "event_candidates" SET "published_event_id"=$1`).
+						WithArgs(eventID, candidateID).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+						WithArgs(append([]driver.Value{testdb.Any, testdb.Any, string(services.AuditActionCandidatePublished)}, testdb.AnyArgs(9)...)...).
+						WillReturnRows(testdb.IDs(uuid.New()))
+				}
+				mock.ExpectCommit()
+			}
+
+			ops := New(db, &config.Config{}, nil)
+			candidate, err := ops.Restore(RestoreRequest{CandidateID: candidateID, Action: tt.action, Reason: tt.reason},
+				services.Actor{Type: services.ActorAdmin, AdminID: "ops"})
+			if tt.wantResult == "" {
+				if err == nil || KindOf(err) != tt.wantKind {
+					t.Fatalf("Restore() error = %v, want kind %v", err, tt.wantKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			if *candidate.PublishResult != tt.wantResult || *candidate.PublicationReason != "restored: false positive" {
+				t.Errorf("candidate is %s (%s), want %s", *candidate.PublishResult, *candidate.PublicationReason, tt.wantResult)
+			}
+			if tt.wantResult == "published" && (candidate.PublishedEventID == nil || *candidate.PublishedEventID != eventID) {
+				t.Errorf("published candidate links to %v, want the new event %s", candidate.PublishedEventID, eventID)
+			}
+		})
+	}
+}
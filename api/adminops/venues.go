@@ -0,0 +1,40 @@
+package adminops
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// MergeVenue folds a venue into another, re-pointing all of its events
+func (o *Ops) MergeVenue(sourceID, targetID uuid.UUID, actor services.Actor) (*services.VenueChangeResult, error) {
+	result, err := services.MergeVenues(o.db, sourceID, targetID, actor)
+	if err != nil {
+		return nil, venueError(err)
+	}
+	return result, nil
+}
+
+// DeleteVenue removes a venue, re-pointing its events to reassignTo if any reference it
+func (o *Ops) DeleteVenue(venueID uuid.UUID, reassignTo *uuid.UUID, actor services.Actor) (*services.VenueChangeResult, error) {
+	result, err := services.DeleteVenue(o.db, venueID, reassignTo, actor)
+	if err != nil {
+		return nil, venueError(err)
+	}
+	return result, nil
+}
+
+// venueError classifies venue service errors
+func venueError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrVenueNotFound):
+		return notFound("Venue not found")
+	case errors.Is(err, services.ErrVenueInUse):
+		return conflict("Venue is referenced by events; supply reassign_to to move them")
+	case errors.Is(err, services.ErrVenueSelfReference):
+		return invalid("Venue cannot be merged into itself")
+	default:
+		return internal("Failed to update venue", err)
+	}
+}
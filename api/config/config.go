@@ -22,31 +22,216 @@ type Config struct {
 	OpenAIAPIKey      string
 	OpenAIModel       string
 	OpenAITimeoutMS   int
+	OpenAIMaxRetries  int // retries of 429, 5xx, and network failures
+	OpenAIRetryBaseMS int // backoff before the first retry, doubling after
 	StructuredOutput  bool
 	ImageMaxLongSide  int
 	ImageJPEGQuality  int
+	HEICConverter     string // heif-convert compatible command; empty refuses HEIC uploads
+	MaxUploadImages   int    // photos one upload may carry
+	VisionConcurrency int    // vision calls in flight per instance, each holding an encoded image; 0 = no limit
+	VisionSlotWaitSec int    // how long a call waits for a slot before its submission is requeued
 
-	// Storage
-	UploadDir string
+	// RejectPoorQualityImages stops a submission as rejected_quality, skipping moderation,
+	// when the vision model finds no flyers and rates every photo "poor"
+	RejectPoorQualityImages bool
+	// QRScanEnabled decodes QR codes in each photo and attaches their links to the flyers
+	// they were printed on
+	QRScanEnabled bool
+
+	// Vision provider: openai or anthropic. Its model is used outside experiments; experiment
+	// variants may name another provider as provider/model.
+	VisionProvider   string
+	AnthropicAPIKey  string
+	AnthropicModel   string
+	AnthropicBaseURL string
+
+	// Vision model A/B experiment (empty name routes everything to the VisionProvider's model)
+	VisionExperiment         string
+	VisionExperimentVariants []string // name=model:weight
+	VisionModelPrices        []string // model=prompt/completion USD per million tokens
+
+	// JSON field definitions for extraction; empty uses the built-in event fields
+	ExtractionFieldsFile string
+	// Photo analysis prompt, read from a file or given inline (the file wins); empty uses
+	// the built-in prompt
+	VisionPromptPath string
+	VisionPrompt     string
+
+	// Storage. UploadDir holds the files themselves with the local backend, and working
+	// copies of them with s3.
+	UploadDir         string
+	StorageBackend    string // local or s3
+	S3Bucket          string
+	S3Region          string
+	S3Endpoint        string // empty uses AWS; set for MinIO, R2 and other S3-compatible stores
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3ForcePathStyle  bool   // bucket in the path instead of the host name, as MinIO expects
+	S3PublicBaseURL   string // where stored files are fetched from, e.g. a CDN; empty uses the bucket URL
 
 	// Queue (in-memory for simplicity)
 	RegionTZ string
+	// Minutes an event without an end time is assumed to last, for date filters and calendars
+	DefaultEventDurationMin int
+	// Submissions processed at once per instance; later uploads wait in line (0 disables the limit)
+	ProcessingConcurrency int
+	// Background workers that run uploaded submissions through the pipeline
+	WorkerPoolSize int
+	// Submissions left "processing" this long are re-enqueued at startup
+	StaleProcessingTimeoutSec int
+	// On SIGTERM, how long in-flight requests and jobs get to finish before they are cancelled
+	ShutdownGraceSec int
+
+	// Upload URL requests whose client perceptual hash is within this many bits of an earlier
+	// upload's are answered as duplicates (negative disables perceptual matching)
+	DuplicatePHashMaxDistance int
+	// Uploads are only matched against earlier ones from this many hours back (0 means all);
+	// a stored photo that matches is linked to the earlier submission instead of analyzed
+	DuplicateWindowHours int
+
+	// An auto-published event starting within two days of an existing one whose normalized
+	// title differs by at most this fraction of its length (Levenshtein) is merged into it
+	// until an admin confirms (0 disables)
+	DedupeTitleMaxDistance float64
+	// Events at a venue located further than this many meters from the new event's geocoded
+	// location are never merged with it (0 compares titles alone)
+	DedupeVenueMaxMeters float64
 
 	// Geocoding
-	Geocoder      string
-	GeocoderAPIKey string
+	Geocoder           string
+	GeocoderAPIKey     string
+	GoogleMapsAPIKey   string // used by GEOCODER=googlemaps, falling back to GeocoderAPIKey
+	NominatimBaseURL   string // GEOCODER=nominatim server; the public OpenStreetMap one by default
+	NominatimUserAgent string // identifies this deployment to Nominatim, as its usage policy requires
 
 	// Auto-publish settings
 	AutoPublishEnabled           bool
 	AutoPublishThreshold         float64
 	GeoConfThreshold            float64
+	ImplausibleDistanceKm       float64
+	CaptureLocationFallback     bool // place flyers naming no venue at the capture location
 	AutoPublishMinStartOffsetMin int
 	AutoPublishMaxStartOffsetDays int
 	TrustAdjust                 float64
 
+	// Review
+	ReviewSLAHours int
+	// Dashboard stats give up after this long; candidates still render without them
+	AdminStatsTimeoutMS int
+
+	// Signed candidate preview links for organizers (empty key disables them)
+	PreviewSigningKey   string
+	// Signs /v1/events pagination cursors; must be shared by every instance
+	CursorSigningKey string
+	PreviewLinkTTLHours int
+
+	// Venue claim tokens for disputing events (empty key disables claims)
+	VenueClaimSigningKey    string
+	VenueClaimTTLDays       int
+	VenueDisputeLimitPerDay int
+
+	// Public event flags: per client IP per event per 24 hours, per client IP across all
+	// events per hour, and the distinct networks (IPv4 /24, IPv6 /48) that send an event back to pending (0 disables each)
+	FlagLimitPerDay      int
+	FlagRateLimitPerHour int
+	FlagReviewThreshold  int
+
+	// Submissions per client IP per hour (uploads and manual entries combined, 0 disables)
+	SubmissionRateLimitPerHour int
+
+	// Per-IP token buckets for routes without their own budget, per minute (0 disables)
+	RateLimitUploadRPM int
+	RateLimitReadRPM   int
+
+	// Where the client IP behind every per-IP limit comes from: proxies (IPs or CIDRs)
+	// whose X-Forwarded-For is believed, or a header set by the hosting platform's edge.
+	// With neither, the TCP peer is the client.
+	TrustedProxies  []string
+	TrustedPlatform string
+
+	// Public read protection; partner API keys bypass all limits
+	PublicRateLimitPerMin int
+	PartnerAPIKeys        []string
+	AnonymousMaxOffset    int
+	AnonymousMaxPastDays  int
+	FingerprintSalt       string
+
+	// Map vector tiles
+	TileRateLimitPerMin int
+	TileCacheSize       int
+
+	// Degraded reads while the database is unreachable (a cache size of 0 disables them)
+	DBHealthIntervalSec    int
+	DegradedCacheSize      int
+	DegradedCacheMaxAgeMin int
+
+	// Precomputed homepage map clusters
+	ClusterWindowDays         int
+	ClusterZoomLevels         []int
+	ClusterRefreshIntervalSec int
+
+	// URL enrichment for online-only flyers
+	URLEnrichmentEnabled   bool
+	URLEnrichmentTimeoutMS int
+
+	// Background reconciliation (0 disables)
+	ReconcileIntervalMin int
+
+	// Stage 3 retries for transient moderation/geocoding failures
+	RetrySweepIntervalSec   int
+	RetryBatchSize          int
+	RetryMaxAttempts        int
+	RetryBaseDelaySec       int
+	BreakerFailureThreshold int
+	BreakerCooldownSec      int
+	GeocodeHedgeDelayMS     int
+	GeocodeHedgeMaxPerMin   int
+
+	// Automatic retries of failed uploads: up to SubmissionMaxRetries, the first after
+	// SubmissionRetryBaseDelaySec and each later one five times longer (0 interval disables)
+	SubmissionRetryIntervalSec  int
+	SubmissionMaxRetries        int
+	SubmissionRetryBaseDelaySec int
+
+	// Transactional outbox for webhooks, notifications, and the change feed
+	OutboxPollIntervalMS int
+	OutboxBatchSize      int
+	OutboxMaxAttempts    int
+	OutboxBaseDelaySec   int
+	OutboxLeaseSec       int
+	// Outbox sinks (empty URLs disable them)
+	WebhookURL       string
+	WebhookSecret    string
+	WebhookTimeoutMS int
+	SlackWebhookURL  string
+
+	// Admin API
+	AdminToken     string
+	PurgeAfterDays int
+	// Days candidates blocked by moderation are kept before maintenance purge removes them
+	BlockedRetentionDays int
+	// bcrypt hash of an admin API key, accepted alongside generated keys
+	AdminAPIKeyHash string
+	// Secret that authorizes generating the first admin key while none is configured;
+	// without it only loopback callers can
+	AdminBootstrapToken string
+	// Signs dashboard session cookies; must be shared by every instance
+	AdminSessionSecret string
+	// Requests per client IP per minute turned away for a missing or wrong admin key,
+	// after which admin routes answer 429 until the window ends (0 disables)
+	AdminAuthFailuresPerMin int
+
+	// Days a deleted event's canonical key is kept from auto-publishing again
+	EventDeleteCooldownDays int
+
+	// How long a runtime setting value is cached before re-reading it
+	SettingsCacheTTLSec int
+
 	// ICS
 	ICSUIDDomain string
 	ICSProdID    string
+	ICSFeedDays  int
 
 	// Optional features
 	PGVectorEnabled bool
@@ -67,26 +252,156 @@ func Load() (*Config, error) {
 		OpenAIAPIKey:      getEnv("OPENAI_API_KEY", ""),
 		OpenAIModel:       getEnv("OPENAI_MODEL", "gpt-4o"),
 		OpenAITimeoutMS:   getEnvInt("OPENAI_TIMEOUT_MS", 15000),
+		OpenAIMaxRetries:  getEnvInt("OPENAI_MAX_RETRIES", 3),
+		OpenAIRetryBaseMS: getEnvInt("OPENAI_RETRY_BASE_MS", 500),
 		StructuredOutput:  getEnvBool("STRUCTURED_OUTPUT", true),
 		ImageMaxLongSide:  getEnvInt("IMAGE_MAX_LONG_SIDE", 2048),
 		ImageJPEGQuality:  getEnvInt("IMAGE_JPEG_QUALITY", 85),
+		HEICConverter:     getEnv("HEIC_CONVERTER", "heif-convert"),
+		MaxUploadImages:   getEnvInt("MAX_UPLOAD_IMAGES", 6),
+		VisionConcurrency: getEnvInt("VISION_CONCURRENCY", 2),
+		VisionSlotWaitSec: getEnvInt("VISION_SLOT_WAIT_SEC", 30),
+
+		RejectPoorQualityImages: getEnvBool("REJECT_POOR_QUALITY_IMAGES", true),
+		QRScanEnabled:           getEnvBool("QR_SCAN_ENABLED", true),
+
+		VisionProvider:   strings.ToLower(getEnv("VISION_PROVIDER", "openai")),
+		AnthropicAPIKey:  getEnv("ANTHROPIC_API_KEY", ""),
+		AnthropicModel:   getEnv("ANTHROPIC_MODEL", "claude-3-5-sonnet-20241022"),
+		AnthropicBaseURL: getEnv("ANTHROPIC_BASE_URL", "https://api.anthropic.com"),
+
+		VisionExperiment:         getEnv("VISION_EXPERIMENT", ""),
+		VisionExperimentVariants: getEnvList("VISION_EXPERIMENT_VARIANTS"),
+		VisionModelPrices:        getEnvList("VISION_MODEL_PRICES"),
 
-		UploadDir: getEnv("UPLOAD_DIR", "/data/uploads"),
+		ExtractionFieldsFile: getEnv("EXTRACTION_FIELDS_FILE", ""),
+		VisionPromptPath:     getEnv("VISION_PROMPT_PATH", ""),
+		VisionPrompt:         getEnv("VISION_PROMPT", ""),
 
-		RegionTZ: getEnv("REGION_TZ", "America/Los_Angeles"),
+		UploadDir:         getEnv("UPLOAD_DIR", "/data/uploads"),
+		StorageBackend:    getEnv("STORAGE_BACKEND", "local"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3ForcePathStyle:  getEnvBool("S3_FORCE_PATH_STYLE", false),
+		S3PublicBaseURL:   getEnv("S3_PUBLIC_BASE_URL", ""),
 
-		Geocoder:       getEnv("GEOCODER", "mapbox"),
-		GeocoderAPIKey: getEnv("GEOCODER_API_KEY", ""),
+		RegionTZ:              getEnv("REGION_TZ", "America/Los_Angeles"),
+		DefaultEventDurationMin: getEnvInt("DEFAULT_EVENT_DURATION_MIN", 120),
+		ProcessingConcurrency: getEnvInt("PROCESSING_CONCURRENCY", 4),
+
+		WorkerPoolSize:            getEnvInt("WORKER_POOL_SIZE", getEnvInt("WORKER_COUNT", 4)), // WORKER_COUNT is the older name
+		StaleProcessingTimeoutSec: getEnvInt("STALE_PROCESSING_TIMEOUT_SEC", 600),
+		ShutdownGraceSec:          getEnvInt("SHUTDOWN_GRACE_SEC", 25),
+
+		DuplicatePHashMaxDistance: getEnvInt("DUPLICATE_PHASH_MAX_DISTANCE", 4),
+		DuplicateWindowHours:      getEnvInt("DUPLICATE_WINDOW_HOURS", 168),
+
+		DedupeTitleMaxDistance: getEnvFloat("DEDUPE_TITLE_MAX_DISTANCE", 0.2),
+		DedupeVenueMaxMeters:   getEnvFloat("DEDUPE_VENUE_MAX_METERS", 500),
+
+		Geocoder:           getEnv("GEOCODER", "mapbox"),
+		GeocoderAPIKey:     getEnv("GEOCODER_API_KEY", ""),
+		GoogleMapsAPIKey:   getEnv("GOOGLE_MAPS_API_KEY", ""),
+		NominatimBaseURL:   getEnv("NOMINATIM_BASE_URL", "https://nominatim.openstreetmap.org"),
+		NominatimUserAgent: getEnv("NOMINATIM_USER_AGENT", "williamboard/1.0 (+https://github.com/lincolngreen/williamboard)"),
 
 		AutoPublishEnabled:            getEnvBool("AUTO_PUBLISH_ENABLED", true),
 		AutoPublishThreshold:          getEnvFloat("AUTO_PUBLISH_THRESHOLD", 0.80),
 		GeoConfThreshold:             getEnvFloat("GEO_CONF_THRESHOLD", 0.75),
+		ImplausibleDistanceKm:        getEnvFloat("IMPLAUSIBLE_DISTANCE_KM", 150),
+		CaptureLocationFallback:      getEnvBool("CAPTURE_LOCATION_FALLBACK", true),
 		AutoPublishMinStartOffsetMin: getEnvInt("AUTO_PUBLISH_MIN_START_OFFSET_MIN", 30),
 		AutoPublishMaxStartOffsetDays: getEnvInt("AUTO_PUBLISH_MAX_START_OFFSET_DAYS", 180),
 		TrustAdjust:                   getEnvFloat("TRUST_ADJUST", 0.05),
 
+		ReviewSLAHours:      getEnvInt("REVIEW_SLA_HOURS", 24),
+		AdminStatsTimeoutMS: getEnvInt("ADMIN_STATS_TIMEOUT_MS", 2000),
+
+		PreviewSigningKey:   getEnv("PREVIEW_SIGNING_KEY", ""),
+		PreviewLinkTTLHours: getEnvInt("PREVIEW_LINK_TTL_HOURS", 72),
+
+		CursorSigningKey: getEnv("CURSOR_SIGNING_KEY", ""),
+
+		VenueClaimSigningKey:    getEnv("VENUE_CLAIM_SIGNING_KEY", ""),
+		VenueClaimTTLDays:       getEnvInt("VENUE_CLAIM_TTL_DAYS", 365),
+		VenueDisputeLimitPerDay: getEnvInt("VENUE_DISPUTE_LIMIT_PER_DAY", 5),
+
+		FlagLimitPerDay:      getEnvInt("FLAG_LIMIT_PER_DAY", 3),
+		FlagRateLimitPerHour: getEnvInt("FLAG_RATE_LIMIT_PER_HOUR", 5),
+		FlagReviewThreshold:  getEnvInt("FLAG_REVIEW_THRESHOLD", 3),
+
+		SubmissionRateLimitPerHour: getEnvInt("SUBMISSION_RATE_LIMIT_PER_HOUR", 30),
+
+		RateLimitUploadRPM: getEnvInt("RATE_LIMIT_UPLOAD_RPM", 10),
+		RateLimitReadRPM:   getEnvInt("RATE_LIMIT_READ_RPM", 1200),
+
+		TrustedProxies:  getEnvList("TRUSTED_PROXIES"),
+		TrustedPlatform: getEnv("TRUSTED_PLATFORM_HEADER", ""),
+
+		PublicRateLimitPerMin: getEnvInt("PUBLIC_RATE_LIMIT_PER_MIN", 120),
+		PartnerAPIKeys:        getEnvList("PARTNER_API_KEYS"),
+		AnonymousMaxOffset:    getEnvInt("ANONYMOUS_MAX_OFFSET", 1000),
+		AnonymousMaxPastDays:  getEnvInt("ANONYMOUS_MAX_PAST_DAYS", 90),
+		FingerprintSalt:       getEnv("FINGERPRINT_SALT", ""),
+
+		TileRateLimitPerMin: getEnvInt("TILE_RATE_LIMIT_PER_MIN", 600),
+		TileCacheSize:       getEnvInt("TILE_CACHE_SIZE", 2000),
+
+		DBHealthIntervalSec:    getEnvInt("DB_HEALTH_INTERVAL_SEC", 5),
+		DegradedCacheSize:      getEnvInt("DEGRADED_CACHE_SIZE", 1000),
+		DegradedCacheMaxAgeMin: getEnvInt("DEGRADED_CACHE_MAX_AGE_MIN", 120),
+
+		ClusterWindowDays:         getEnvInt("CLUSTER_WINDOW_DAYS", 7),
+		ClusterZoomLevels:         getEnvIntList("CLUSTER_ZOOM_LEVELS", []int{8, 10, 12}),
+		ClusterRefreshIntervalSec: getEnvInt("CLUSTER_REFRESH_INTERVAL_SEC", 300),
+
+		URLEnrichmentEnabled:   getEnvBool("URL_ENRICHMENT_ENABLED", false),
+		URLEnrichmentTimeoutMS: getEnvInt("URL_ENRICHMENT_TIMEOUT_MS", 5000),
+
+		ReconcileIntervalMin: getEnvInt("RECONCILE_INTERVAL_MIN", 60),
+
+		RetrySweepIntervalSec:   getEnvInt("RETRY_SWEEP_INTERVAL_SEC", 30),
+		RetryBatchSize:          getEnvInt("RETRY_BATCH_SIZE", 20),
+		RetryMaxAttempts:        getEnvInt("RETRY_MAX_ATTEMPTS", 5),
+		RetryBaseDelaySec:       getEnvInt("RETRY_BASE_DELAY_SEC", 60),
+		BreakerFailureThreshold: getEnvInt("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerCooldownSec:      getEnvInt("BREAKER_COOLDOWN_SEC", 60),
+		GeocodeHedgeDelayMS:     getEnvInt("GEOCODE_HEDGE_DELAY_MS", 2000),
+		GeocodeHedgeMaxPerMin:   getEnvInt("GEOCODE_HEDGE_MAX_PER_MIN", 30),
+
+		SubmissionRetryIntervalSec:  getEnvInt("SUBMISSION_RETRY_INTERVAL_SEC", 60),
+		SubmissionMaxRetries:        getEnvInt("SUBMISSION_MAX_RETRIES", 3),
+		SubmissionRetryBaseDelaySec: getEnvInt("SUBMISSION_RETRY_BASE_DELAY_SEC", 60),
+
+		OutboxPollIntervalMS: getEnvInt("OUTBOX_POLL_INTERVAL_MS", 1000),
+		OutboxBatchSize:      getEnvInt("OUTBOX_BATCH_SIZE", 50),
+		OutboxMaxAttempts:    getEnvInt("OUTBOX_MAX_ATTEMPTS", 8),
+		OutboxBaseDelaySec:   getEnvInt("OUTBOX_BASE_DELAY_SEC", 10),
+		OutboxLeaseSec:       getEnvInt("OUTBOX_LEASE_SEC", 30),
+		WebhookURL:           getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:        getEnv("WEBHOOK_SECRET", ""),
+		WebhookTimeoutMS:     getEnvInt("WEBHOOK_TIMEOUT_MS", 5000),
+		SlackWebhookURL:      getEnv("SLACK_WEBHOOK_URL", ""),
+
+		AdminToken:           getEnv("ADMIN_TOKEN", ""),
+		PurgeAfterDays:       getEnvInt("PURGE_AFTER_DAYS", 30),
+		BlockedRetentionDays: getEnvInt("BLOCKED_RETENTION_DAYS", 90),
+		AdminAPIKeyHash:      getEnv("ADMIN_API_KEY_HASH", ""),
+		AdminBootstrapToken:  getEnv("ADMIN_BOOTSTRAP_TOKEN", ""),
+
+		AdminSessionSecret:      getEnv("ADMIN_SESSION_SECRET", ""),
+		AdminAuthFailuresPerMin: getEnvInt("ADMIN_AUTH_FAILURES_PER_MIN", 20),
+
+		EventDeleteCooldownDays: getEnvInt("EVENT_DELETE_COOLDOWN_DAYS", 30),
+
+		SettingsCacheTTLSec: getEnvInt("SETTINGS_CACHE_TTL_SEC", 30),
+
 		ICSUIDDomain: getEnv("ICS_UID_DOMAIN", "williamboard.app"),
 		ICSProdID:    getEnv("ICS_PRODID", "-//WilliamBoard//EN"),
+		ICSFeedDays:  getEnvInt("ICS_FEED_DAYS", 90),
 
 		PGVectorEnabled: getEnvBool("PGVECTOR_ENABLED", false),
 		OTELEndpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
@@ -101,8 +416,26 @@ func Load() (*Config, error) {
 
 func (c *Config) Validate() error {
 	required := map[string]string{
-		"DATABASE_URL":   c.DatabaseURL,
-		"OPENAI_API_KEY": c.OpenAIAPIKey,
+		"DATABASE_URL": c.DatabaseURL,
+	}
+	// Only the default vision provider's key is required; moderation skips OpenAI without one
+	switch c.VisionProvider {
+	case "openai":
+		required["OPENAI_API_KEY"] = c.OpenAIAPIKey
+	case "anthropic":
+		required["ANTHROPIC_API_KEY"] = c.AnthropicAPIKey
+	default:
+		return fmt.Errorf("VISION_PROVIDER must be openai or anthropic, not %q", c.VisionProvider)
+	}
+
+	switch c.StorageBackend {
+	case "local":
+	case "s3":
+		required["S3_BUCKET"] = c.S3Bucket
+		required["S3_ACCESS_KEY_ID"] = c.S3AccessKeyID
+		required["S3_SECRET_ACCESS_KEY"] = c.S3SecretAccessKey
+	default:
+		return fmt.Errorf("STORAGE_BACKEND must be local or s3, not %q", c.StorageBackend)
 	}
 
 	for name, value := range required {
@@ -111,6 +444,11 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Without a configured key /admin starts open; production must never do that
+	if c.Environment == "production" && strings.TrimSpace(c.AdminAPIKeyHash) == "" && strings.TrimSpace(c.AdminToken) == "" {
+		return fmt.Errorf("ADMIN_API_KEY_HASH or ADMIN_TOKEN must be set in production")
+	}
+
 	return nil
 }
 
@@ -121,6 +459,35 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList splits a comma-separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvIntList reads a comma-separated list of integers, falling back to defaultValue when
+// the variable is unset or any entry isn't a number
+func getEnvIntList(key string, defaultValue []int) []int {
+	entries := getEnvList(key)
+	if len(entries) == 0 {
+		return defaultValue
+	}
+	values := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		value, err := strconv.Atoi(entry)
+		if err != nil {
+			return defaultValue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -150,4 +517,18 @@ func getEnvBool(key string, defaultValue bool) bool {
 
 func (c *Config) GetLocation() (*time.Location, error) {
 	return time.LoadLocation(c.RegionTZ)
-}
\ No newline at end of file
+}
+// ReviewSLA returns the target time from extraction to a review decision
+func (c *Config) ReviewSLA() time.Duration {
+	return time.Duration(c.ReviewSLAHours) * time.Hour
+}
+
+// PreviewLinkTTL returns how long a signed candidate preview link stays valid
+func (c *Config) PreviewLinkTTL() time.Duration {
+	return time.Duration(c.PreviewLinkTTLHours) * time.Hour
+}
+
+// VenueClaimTTL returns how long a venue claim token stays valid
+func (c *Config) VenueClaimTTL() time.Duration {
+	return time.Duration(c.VenueClaimTTLDays) * 24 * time.Hour
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRequiresAdminKeyInProduction(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		keyHash     string
+		token       string
+		wantErr     bool
+	}{
+		{name: "production with a key hash", environment: "production", keyHash: "$2a$10$hash"},
+		{name: "production with the legacy token", environment: "production", token: "legacy-token"},
+		{name: "production without a key", environment: "production", wantErr: true},
+		{name: "production with a blank key", environment: "production", keyHash: "  ", wantErr: true},
+		{name: "development without a key", environment: "development"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Environment:     tt.environment,
+				DatabaseURL:     "postgres://localhost/williamboard",
+				VisionProvider:  "openai",
+				OpenAIAPIKey:    "sk-test",
+				StorageBackend:  "local",
+				AdminAPIKeyHash: tt.keyHash,
+				AdminToken:      tt.token,
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "ADMIN_API_KEY_HASH") {
+				t.Errorf("Validate() error = %v, want it to name ADMIN_API_KEY_HASH", err)
+			}
+		})
+	}
+}
@@ -1,29 +1,44 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/adminops"
 	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/dateparse"
+	"github.com/lincolngreen/williamboard/api/middleware"
 	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/observability"
+	"github.com/lincolngreen/williamboard/api/services"
 	"gorm.io/gorm"
 )
 
 type AdminHandler struct {
-	config *config.Config
-	db     *gorm.DB
+	config       *config.Config
+	db           *gorm.DB
+	storage      *services.StorageService
+	fingerprints *middleware.FingerprintTracker
+	keys         *services.AdminKeyring
+	ops          *adminops.Ops
 }
 
 type AdminEventCandidate struct {
 	ID               string                 `json:"id"`
 	SubmissionID     string                 `json:"submission_id"`
 	FlyerID          string                 `json:"flyer_id"`
-	EventID          string                 `json:"event_id"`
+	EventID          string                 `json:"event_id"`           // published event UUID, empty until published
+	ExtractedEventID string                 `json:"extracted_event_id"` // label the LLM gave the event within its flyer
 	Fields           map[string]interface{} `json:"fields"`
 	Confidences      map[string]interface{} `json:"confidences"`
 	SourceExcerpt    *string                `json:"source_excerpt"`
@@ -45,24 +60,65 @@ type AdminEventCandidate struct {
 	OriginalImageURL string     `json:"original_image_url"`
 	ThumbnailURL     string     `json:"thumbnail_url"`
 	PublishedEventStartTime *time.Time `json:"published_event_start_time"` // When the published event is scheduled
+	Source           string     `json:"source"` // upload, manual
+
+	// Linkage to the public event (published candidates only)
+	PublishedEventID string `json:"published_event_id,omitempty"`
+	LinkedCandidates int64  `json:"linked_candidates,omitempty"`
+	Corroborated     bool   `json:"corroborated,omitempty"` // more than one candidate maps to the event
+
+	// Review aging (needs_review only)
+	Age       string `json:"age,omitempty"`
+	SLAStatus string `json:"sla_status,omitempty"`
+
+	// SwapOOB renders the row as an htmx out-of-band swap (bulk moderation responses)
+	SwapOOB bool `json:"-"`
 }
 
-func NewAdminHandler(cfg *config.Config, db *gorm.DB) *AdminHandler {
+func NewAdminHandler(cfg *config.Config, db *gorm.DB, storage *services.StorageService, fingerprints *middleware.FingerprintTracker, keys *services.AdminKeyring, metrics *observability.Registry) *AdminHandler {
 	return &AdminHandler{
-		config: cfg,
-		db:     db,
+		config:       cfg,
+		db:           db,
+		storage:      storage,
+		fingerprints: fingerprints,
+		keys:         keys,
+		ops:          adminops.New(db, cfg, metrics),
 	}
 }
 
-// AdminDashboard shows all event candidates in a table
+// dashboardFilters are the ?status= values the dashboard accepts, keyed to their label.
+// "processing" covers candidates without a publish decision yet.
+var dashboardFilters = map[string]string{
+	"published":    "Published",
+	"needs_review": "Needs Review",
+	"blocked":      "Blocked",
+	"processing":   "Processing",
+}
+
+// AdminDashboard shows all event candidates in a table, optionally filtered by ?status=.
+// Besides the table it renders an onboarding state for a fresh database, a no-results state
+// for an empty filter, a degraded state when the stats queries fail or time out (candidates
+// still render), and an error page when the candidates themselves can't be loaded.
 // GET /admin
 func (h *AdminHandler) AdminDashboard(c *gin.Context) {
-	// Get all event candidates with related data including submission for images
+	filter := c.Query("status")
+	if _, ok := dashboardFilters[filter]; !ok {
+		filter = ""
+	}
+
+	// Get event candidates with related data including submission for images
+	query := h.db.Preload("Flyer.Submission").Preload("PublishedEvent").Order("created_at DESC")
+	switch filter {
+	case "":
+	case "processing":
+		query = query.Where("publish_result IS NULL OR publish_result NOT IN ?", []string{"published", "needs_review", "blocked"})
+	default:
+		query = query.Where("publish_result = ?", filter)
+	}
 	var candidates []models.EventCandidate
-	if err := h.db.Preload("Flyer.Submission").Order("created_at DESC").Find(&candidates).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "admin.html", gin.H{
-			"error": "Failed to load event candidates",
-		})
+	if err := query.Find(&candidates).Error; err != nil {
+		log.Printf("Admin dashboard: failed to load candidates: %v", err)
+		h.renderAdminError(c, http.StatusInternalServerError, "Failed to load event candidates")
 		return
 	}
 
@@ -73,13 +129,60 @@ func (h *AdminHandler) AdminDashboard(c *gin.Context) {
 		adminCandidates[i] = adminCandidate
 	}
 
-	// Get summary stats
-	stats := h.getAdminStats()
+	// A fresh install gets onboarding hints instead of an empty table
+	onboarding := false
+	if len(candidates) == 0 && filter == "" {
+		var submissions int64
+		if err := h.db.Model(&models.Submission{}).Count(&submissions).Error; err != nil {
+			log.Printf("Admin dashboard: failed to count submissions: %v", err)
+		} else {
+			onboarding = submissions == 0
+		}
+	}
+
+	// Stats are a nicety; when they fail the candidates still render
+	stats, err := h.getAdminStats(c.Request.Context())
+	if err != nil {
+		log.Printf("Admin dashboard: stats unavailable: %v", err)
+		stats = nil
+	}
+
+	// Open venue disputes are shown above the candidates; a failed load just hides them
+	disputes, err := services.ListDisputes(h.db, services.FlagStatusPending)
+	if err != nil {
+		fmt.Printf("Failed to load disputes: %v\n", err)
+	}
+	// Events with pending public flags get their own table; a failed load just hides it
+	flagged, err := services.ListPendingFlags(h.db)
+	if err != nil {
+		log.Printf("Admin dashboard: failed to load flags: %v", err)
+	}
+	// Recently blocked candidates can be restored from their own table; a failed load just hides it
+	blocked, err := services.ListRecentlyBlocked(h.db, services.RecentlyBlockedLimit)
+	if err != nil {
+		log.Printf("Admin dashboard: failed to load blocked candidates: %v", err)
+	}
 
 	c.HTML(http.StatusOK, "admin.html", gin.H{
-		"candidates": adminCandidates,
-		"stats":      stats,
-		"title":      "WilliamBoard Admin",
+		"candidates":  adminCandidates,
+		"disputes":    disputes,
+		"flagged":     flagged,
+		"blocked":     blocked,
+		"stats":       stats,
+		"degraded":    stats == nil,
+		"onboarding":  onboarding,
+		"filter":      filter,
+		"filterLabel": dashboardFilters[filter],
+		"title":       "WilliamBoard Admin",
+	})
+}
+
+// renderAdminError shows the styled admin error page with the request ID for correlation
+func (h *AdminHandler) renderAdminError(c *gin.Context, status int, message string) {
+	c.HTML(status, "admin.html", gin.H{
+		"error":     message,
+		"requestID": middleware.RequestIDFrom(c),
+		"title":     "WilliamBoard Admin",
 	})
 }
 
@@ -88,7 +191,7 @@ func (h *AdminHandler) transformEventCandidate(candidate *models.EventCandidate)
 	admin := AdminEventCandidate{
 		ID:                candidate.ID.String(),
 		FlyerID:           candidate.FlyerID.String(),
-		EventID:           candidate.EventID,
+		ExtractedEventID:  candidate.EventID,
 		SourceExcerpt:     candidate.SourceExcerpt,
 		CompositeScore:    candidate.CompositeScore,
 		PublishResult:     candidate.PublishResult,
@@ -103,17 +206,30 @@ func (h *AdminHandler) transformEventCandidate(candidate *models.EventCandidate)
 		admin.QualityScore = *candidate.CompositeScore
 	}
 	
+	admin.Source = candidate.Flyer.Submission.Source
+
 	// Set image URLs from the submission
 	if candidate.Flyer.Submission.OriginalImageURL != "" {
 		admin.OriginalImageURL = candidate.Flyer.Submission.OriginalImageURL
-		// Use the original image as thumbnail for now
+		// A flyer found in a later photo links to that photo rather than the first
+		if candidate.Flyer.ImageIndex > 1 {
+			admin.OriginalImageURL = h.storage.GetSubmissionImageURL(candidate.Flyer.SubmissionID, candidate.Flyer.ImageIndex)
+		}
+		// Submissions uploaded before thumbnails were generated fall back to the original
 		admin.ThumbnailURL = candidate.Flyer.Submission.OriginalImageURL
 		
-		// If there's a derivative image, use that as thumbnail instead
-		if candidate.Flyer.Submission.DerivativeImageURL != nil && *candidate.Flyer.Submission.DerivativeImageURL != "" {
-			admin.ThumbnailURL = *candidate.Flyer.Submission.DerivativeImageURL
+		// The 400px thumbnail made on upload loads much faster than the original
+		if candidate.Flyer.Submission.ThumbnailURL != nil && *candidate.Flyer.Submission.ThumbnailURL != "" {
+			admin.ThumbnailURL = *candidate.Flyer.Submission.ThumbnailURL
 		}
 	}
+	// The flyer's own crop beats any whole-board image. Flyers detected before crops were cut
+	// during processing still get one: the crop endpoint cuts it on first request.
+	if candidate.Flyer.CropImageURL != nil && *candidate.Flyer.CropImageURL != "" {
+		admin.ThumbnailURL = *candidate.Flyer.CropImageURL
+	} else if admin.ThumbnailURL != "" && candidate.Flyer.RegionID != services.ManualFlyerRegionID {
+		admin.ThumbnailURL = h.storage.GetAdminCropURL(candidate.Flyer.ID)
+	}
 
 	// Parse fields JSON
 	var fields map[string]interface{}
@@ -176,13 +292,23 @@ func (h *AdminHandler) transformEventCandidate(candidate *models.EventCandidate)
 	// Set status and color for display
 	admin.Status, admin.StatusColor = h.getStatusDisplay(candidate.PublishResult, candidate.CompositeScore)
 
-	// If this event is published, look up the published event timestamp
-	if candidate.PublishResult != nil && *candidate.PublishResult == "published" {
-		var publishedEvent models.Event
-		// Look for events with matching title (case-insensitive)
-		titlePattern := "%" + strings.ToLower(strings.TrimSpace(admin.Title)) + "%"
-		if err := h.db.Where("LOWER(title) LIKE ? AND moderation_state = ?", titlePattern, "approved").First(&publishedEvent).Error; err == nil {
-			admin.PublishedEventStartTime = &publishedEvent.StartTs
+	// Show how long a candidate has been waiting on a human
+	if candidate.PublishResult != nil && *candidate.PublishResult == "needs_review" {
+		age := time.Since(candidate.CreatedAt)
+		admin.Age = services.FormatAge(age)
+		admin.SLAStatus = services.ReviewSLAStatus(age, h.config.ReviewSLA())
+	}
+
+	// Published candidates carry their event; callers preload it
+	if candidate.PublishResult != nil && *candidate.PublishResult == "published" && candidate.PublishedEventID != nil {
+		admin.EventID = candidate.PublishedEventID.String()
+		admin.PublishedEventID = admin.EventID
+		if candidate.PublishedEvent != nil {
+			admin.PublishedEventStartTime = &candidate.PublishedEvent.StartTs
+		}
+		if linked, err := services.LinkedCandidateCount(h.db, *candidate.PublishedEventID); err == nil {
+			admin.LinkedCandidates = linked
+			admin.Corroborated = linked > 1
 		}
 	}
 
@@ -210,16 +336,31 @@ func (h *AdminHandler) getStatusDisplay(publishResult *string, score *float64) (
 	}
 }
 
-// getAdminStats returns summary statistics
-func (h *AdminHandler) getAdminStats() map[string]interface{} {
+// getAdminStats returns summary statistics, failing if any count errors or the queries
+// together take longer than ADMIN_STATS_TIMEOUT_MS
+func (h *AdminHandler) getAdminStats(ctx context.Context) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(h.config.AdminStatsTimeoutMS)*time.Millisecond)
+	defer cancel()
+	db := h.db.WithContext(ctx)
+
 	stats := make(map[string]interface{})
 
 	// Count by status
 	var published, blocked, needsReview, total int64
-	h.db.Model(&models.EventCandidate{}).Where("publish_result = ?", "published").Count(&published)
-	h.db.Model(&models.EventCandidate{}).Where("publish_result = ?", "blocked").Count(&blocked)
-	h.db.Model(&models.EventCandidate{}).Where("publish_result = ?", "needs_review").Count(&needsReview)
-	h.db.Model(&models.EventCandidate{}).Count(&total)
+	counts := []struct {
+		dest  *int64
+		query *gorm.DB
+	}{
+		{&published, db.Model(&models.EventCandidate{}).Where("publish_result = ?", "published")},
+		{&blocked, db.Model(&models.EventCandidate{}).Where("publish_result = ?", "blocked")},
+		{&needsReview, db.Model(&models.EventCandidate{}).Where("publish_result = ?", "needs_review")},
+		{&total, db.Model(&models.EventCandidate{})},
+	}
+	for _, count := range counts {
+		if err := count.query.Count(count.dest).Error; err != nil {
+			return nil, fmt.Errorf("failed to count candidates: %w", err)
+		}
+	}
 
 	stats["total"] = total
 	stats["published"] = published
@@ -229,230 +370,374 @@ func (h *AdminHandler) getAdminStats() map[string]interface{} {
 
 	// Recent activity (last 24h)
 	var recent int64
-	h.db.Model(&models.EventCandidate{}).Where("created_at > ?", time.Now().Add(-24*time.Hour)).Count(&recent)
+	if err := db.Model(&models.EventCandidate{}).Where("created_at > ?", time.Now().Add(-24*time.Hour)).Count(&recent).Error; err != nil {
+		return nil, fmt.Errorf("failed to count recent candidates: %w", err)
+	}
 	stats["recent_24h"] = recent
 
-	return stats
-}
+	// Needs-review candidates past the review SLA
+	var breaching int64
+	if err := db.Model(&models.EventCandidate{}).
+		Where("publish_result = ? AND created_at < ?", "needs_review", time.Now().Add(-h.config.ReviewSLA())).
+		Count(&breaching).Error; err != nil {
+		return nil, fmt.Errorf("failed to count SLA breaches: %w", err)
+	}
+	stats["sla_breaching"] = breaching
+	stats["sla_hours"] = h.config.ReviewSLAHours
 
-// ModerateEvent handles approval/rejection of events
-// POST /admin/moderate/:id
-func (h *AdminHandler) ModerateEvent(c *gin.Context) {
-	eventID := c.Param("id")
-	action := c.PostForm("action")
-	reason := c.PostForm("reason")
+	tokens, err := services.SumTokenUsage(db, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	stats["tokens_24h"] = tokens
 
-	if action != "approve" && action != "reject" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+	return stats, nil
+}
+
+// GetReviewLatency returns review latency percentiles and SLA breaches
+// GET /admin/api/stats/review-latency?window=24h|7d|30d
+func (h *AdminHandler) GetReviewLatency(c *gin.Context) {
+	window := c.DefaultQuery("window", "7d")
+	if _, ok := services.ReviewLatencyWindows[window]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window. Allowed: 24h, 7d, 30d"})
 		return
 	}
 
-	// Find the event candidate with related data
-	var candidate models.EventCandidate
-	if err := h.db.Preload("Flyer.Submission").Where("id = ?", eventID).First(&candidate).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	report, err := services.ComputeReviewLatency(h.db, window, h.config.ReviewSLA(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute review latency"})
 		return
 	}
 
-	// Update publish result
-	var publishResult string
-	if action == "approve" {
-		publishResult = "published"
-	} else {
-		publishResult = "blocked"
+	c.JSON(http.StatusOK, report)
+}
+
+// parseActivityQuery reads ?admin_id= and ?since= (a date in REGION_TZ or RFC3339).
+// Without since, the last services.DefaultActivityWindow is covered.
+func (h *AdminHandler) parseActivityQuery(c *gin.Context) (string, time.Time, error) {
+	adminID := strings.TrimSpace(c.Query("admin_id"))
+	raw := c.Query("since")
+	if raw == "" {
+		return adminID, time.Now().Add(-services.DefaultActivityWindow), nil
+	}
+	if since, err := time.Parse(time.RFC3339, raw); err == nil {
+		return adminID, since, nil
 	}
+	since, err := time.ParseInLocation("2006-01-02", raw, services.RegionLocation(h.config))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid since, expected YYYY-MM-DD or RFC3339")
+	}
+	return adminID, since, nil
+}
 
-	// Start a transaction to handle both candidate update and event creation
-	tx := h.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+// loadActivity builds the per-moderator summary and recent actions list
+func (h *AdminHandler) loadActivity(adminID string, since time.Time) (gin.H, error) {
+	admins, err := services.ListAdminActivity(h.db, adminID, since)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := services.RecentAdminActions(h.db, adminID, since, 100)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"since":    since,
+		"admin_id": adminID,
+		"admins":   admins,
+		"recent":   recent,
+	}, nil
+}
 
-	// Update the candidate record
-	updates := map[string]interface{}{
-		"publish_result": publishResult,
+// GetActivity summarizes what each moderator did since a point in time
+// GET /admin/api/activity?admin_id=alice&since=2025-06-01
+func (h *AdminHandler) GetActivity(c *gin.Context) {
+	adminID, since, err := h.parseActivityQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if reason != "" {
-		updates["publication_reason"] = reason
+
+	activity, err := h.loadActivity(adminID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load activity"})
+		return
 	}
 
-	if err := tx.Model(&candidate).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+	c.JSON(http.StatusOK, activity)
+}
+
+// ActivityPage renders the moderator activity view
+// GET /admin/activity?admin_id=alice&since=2025-06-01
+func (h *AdminHandler) ActivityPage(c *gin.Context) {
+	adminID, since, err := h.parseActivityQuery(c)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "activity.html", gin.H{"title": "Moderator Activity", "error": err.Error()})
 		return
 	}
 
-	// If approved, create/update the public Event record
-	if action == "approve" {
-		if err := h.promoteToPublicEvent(tx, &candidate); err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish event: " + err.Error()})
-			return
-		}
+	activity, err := h.loadActivity(adminID, since)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "activity.html", gin.H{"title": "Moderator Activity", "error": "Failed to load activity"})
+		return
 	}
 
-	tx.Commit()
+	activity["title"] = "Moderator Activity"
+	activity["current_admin"] = middleware.AdminID(c)
+	activity["admin_cookie"] = middleware.AdminIDCookie
+	c.HTML(http.StatusOK, "activity.html", activity)
+}
 
-	// Return success for HTMX/AJAX requests or redirect for form submissions
-	if c.GetHeader("HX-Request") == "true" || c.GetHeader("Accept") == "application/json" {
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"status": publishResult,
-		})
-	} else {
-		c.Redirect(http.StatusSeeOther, "/admin")
+// GetTopFingerprints returns the busiest hashed client fingerprints in the current hour
+// GET /admin/api/stats/fingerprints?limit=20
+func (h *AdminHandler) GetTopFingerprints(c *gin.Context) {
+	limit := 20
+	if parsed, err := strconv.Atoi(c.Query("limit")); err == nil && parsed > 0 && parsed <= 200 {
+		limit = parsed
 	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window_start": h.fingerprints.WindowStart(),
+		"clients":      h.fingerprints.Top(limit),
+	})
 }
 
-// promoteToPublicEvent creates an Event record from an approved EventCandidate
-func (h *AdminHandler) promoteToPublicEvent(tx *gorm.DB, candidate *models.EventCandidate) error {
-	// Parse the fields JSON to extract event data
-	var fields map[string]interface{}
-	if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
-		return fmt.Errorf("failed to parse event fields: %v", err)
+// ModerateRequest approves or rejects a candidate
+type ModerateRequest struct {
+	Action string `json:"action" form:"action"` // approve, reject
+	Reason string `json:"reason" form:"reason"`
+	Quiet  bool   `json:"quiet" form:"quiet"` // on approve, mark the event quiet
+}
+
+// ModerateEvent handles approval/rejection of events
+// POST /admin/moderate/:id, POST /admin/api/candidates/:id/moderate {"action": "approve|reject", "reason": "...", "quiet": false}
+func (h *AdminHandler) ModerateEvent(c *gin.Context) {
+	render := h.renderer(c)
+	candidateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid candidate ID"})
+		return
+	}
+	var req ModerateRequest
+	if err := c.ShouldBind(&req); err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid request format"})
+		return
 	}
 
-	// Extract required title field
-	title, ok := fields["title"].(string)
-	if !ok || title == "" {
-		return errors.New("event title is required")
+	result, err := h.ops.Moderate(adminops.ModerateRequest{
+		CandidateID: candidateID,
+		Action:      req.Action,
+		Reason:      req.Reason,
+		Quiet:       req.Quiet,
+	}, adminActor(c))
+	if err != nil {
+		render.Error(c, err)
+		return
 	}
 
-	// Parse start time - try different formats
-	startTs := time.Now().Add(24 * time.Hour) // fallback to tomorrow to ensure future events
-	
-	// Check both "date" and "date_time" fields for compatibility
-	var dateStr string
-	if date, ok := fields["date"].(string); ok && date != "" {
-		dateStr = date
-	} else if dateTime, ok := fields["date_time"].(string); ok && dateTime != "" {
-		dateStr = dateTime
+	render.Success(c, gin.H{
+		"success":            true,
+		"status":             result.Status,
+		"published_event_id": result.PublishedEventID,
+	}, &result.CandidateID)
+}
+
+// BulkModerateRequest applies one decision to several candidates. Dashboard forms send one
+// candidate_ids field per selected row.
+type BulkModerateRequest struct {
+	CandidateIDs []string `json:"candidate_ids" form:"candidate_ids"`
+	Action       string   `json:"action" form:"action"` // approve, reject
+	Reason       string   `json:"reason" form:"reason"`
+	Quiet        bool     `json:"quiet" form:"quiet"`
+}
+
+// BulkModerate approves or rejects up to adminops.MaxBulkCandidates candidates at once, each
+// exactly as ModerateEvent would; per-candidate failures are reported, not fatal
+// POST /admin/candidates/bulk, POST /admin/api/candidates/bulk {"candidate_ids": ["..."], "action": "approve|reject"}
+func (h *AdminHandler) BulkModerate(c *gin.Context) {
+	render := h.renderer(c)
+	var req BulkModerateRequest
+	if err := c.ShouldBind(&req); err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid request format"})
+		return
 	}
-	
-	if dateStr != "" {
-		fmt.Printf("Parsing date string: %s for event: %s\n", dateStr, title)
-		// Try parsing different date formats
-		formats := []string{
-			"2006-01-02T15:04:05",    // ISO format first (most common from LLM)
-			"2006-01-02 15:04:05",
-			"2006-01-02T15:04",
-			"2006-01-02 15:04",
-			"2006-01-02",
-			"January 2, 2006",
-			"Jan 2, 2006",
-		}
-		
-		parsed := false
-		for _, format := range formats {
-			if parsedTime, err := time.Parse(format, dateStr); err == nil {
-				fmt.Printf("Successfully parsed '%s' as '%s' using format '%s'\n", dateStr, parsedTime.String(), format)
-				// If the parsed date is in the past, assume it's for next year
-				if parsedTime.Before(time.Now()) {
-					parsedTime = parsedTime.AddDate(1, 0, 0)
-					fmt.Printf("Date was in past, moved to next year: %s\n", parsedTime.String())
-				}
-				startTs = parsedTime
-				parsed = true
-				break
-			}
-		}
-		
-		// If we couldn't parse the date, keep the fallback
-		if !parsed {
-			fmt.Printf("Failed to parse date '%s', using fallback\n", dateStr)
-			startTs = time.Now().Add(24 * time.Hour)
-		} else {
-			fmt.Printf("Final startTs for event '%s': %s\n", title, startTs.String())
+	ids := make([]uuid.UUID, 0, len(req.CandidateIDs))
+	for _, raw := range req.CandidateIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid candidate ID: " + raw})
+			return
 		}
+		ids = append(ids, id)
 	}
 
-	// Create canonical key for deduplication (title + date)
-	canonicalKey := strings.ToLower(strings.TrimSpace(title)) + "_" + startTs.Format("2006-01-02")
+	result, err := h.ops.BulkModerate(adminops.BulkModerateRequest{
+		CandidateIDs: ids,
+		Action:       req.Action,
+		Reason:       req.Reason,
+		Quiet:        req.Quiet,
+	}, adminActor(c))
+	if err != nil {
+		render.Error(c, err)
+		return
+	}
+
+	render.Success(c, result, nil)
+}
+
+// ModerateBulkAction is one decision within a ModerateBulkRequest
+type ModerateBulkAction struct {
+	ID     string `json:"id"`
+	Action string `json:"action"` // approve, reject
+	Reason string `json:"reason"`
+}
+
+// ModerateBulkRequest carries a separate decision per candidate. The dashboard's bulk form
+// sends the checked rows as ids plus one action and reason, applied to each of them.
+type ModerateBulkRequest struct {
+	Actions []ModerateBulkAction `json:"actions" form:"-"`
+	IDs     []string             `json:"-" form:"ids"`
+	Action  string               `json:"-" form:"action"`
+	Reason  string               `json:"-" form:"reason"`
+}
+
+// ModerateBulk applies up to adminops.MaxBulkCandidates approve/reject decisions in one
+// transaction; a decision that fails is reported without undoing the others
+// POST /admin/moderate/bulk, POST /admin/api/moderate/bulk {"actions": [{"id": "...", "action": "approve|reject", "reason": "..."}]}
+func (h *AdminHandler) ModerateBulk(c *gin.Context) {
+	render := h.renderer(c)
+	var req ModerateBulkRequest
+	if err := c.ShouldBind(&req); err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid request format"})
+		return
+	}
+	for _, id := range req.IDs {
+		req.Actions = append(req.Actions, ModerateBulkAction{ID: id, Action: req.Action, Reason: req.Reason})
+	}
 
-	// Check if this event already exists
-	var existingEvent models.Event
-	if err := tx.Where("canonical_key = ?", canonicalKey).First(&existingEvent).Error; err == nil {
-		// Event already exists, just update moderation state if needed
-		if existingEvent.ModerationState != "approved" {
-			return tx.Model(&existingEvent).Update("moderation_state", "approved").Error
+	actions := make([]adminops.ModerateAction, 0, len(req.Actions))
+	for _, action := range req.Actions {
+		id, err := uuid.Parse(action.ID)
+		if err != nil {
+			render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid candidate ID: " + action.ID})
+			return
 		}
-		return nil // Already published
+		actions = append(actions, adminops.ModerateAction{CandidateID: id, Action: action.Action, Reason: action.Reason})
 	}
 
-	// Create new Event record
-	event := models.Event{
-		CanonicalKey:    canonicalKey,
-		Title:           title,
-		StartTs:         startTs,
-		Source:          "flyer",
-		PublishedVia:    "manual",
-		QualityScore:    candidate.CompositeScore,
-		ModerationState: "approved",
+	result, err := h.ops.ModerateActions(actions, adminActor(c))
+	if err != nil {
+		render.Error(c, err)
+		return
 	}
 
-	// Extract optional fields
-	if desc, ok := fields["description"].(string); ok && desc != "" {
-		event.Description = &desc
+	if wantsAdminJSON(c) || c.GetHeader("HX-Request") != "true" {
+		render.Success(c, result, nil)
+		return
 	}
-	if url, ok := fields["url"].(string); ok && url != "" {
-		event.URL = &url
+
+	// htmx: a summary for the bulk form, plus each decided row swapped in place out of band
+	var candidates []models.EventCandidate
+	if err := h.db.Preload("Flyer.Submission").Preload("PublishedEvent").Where("id IN ?", result.Decided).Find(&candidates).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Failed to reload candidates")
+		return
 	}
-	if price, ok := fields["price"].(string); ok && price != "" {
-		event.Price = &price
+	rows := make([]AdminEventCandidate, 0, len(candidates))
+	for i := range candidates {
+		row := h.transformEventCandidate(&candidates[i])
+		row.SwapOOB = true
+		rows = append(rows, row)
 	}
-	if organizer, ok := fields["organizer"].(string); ok && organizer != "" {
-		event.Organizer = &organizer
+	c.HTML(http.StatusOK, "bulk_moderate_result", gin.H{
+		"result": result,
+		"rows":   rows,
+	})
+}
+
+// UnpublishCandidate takes down the public event a published candidate is linked to,
+// blocking every candidate that maps to it. Corroborated events require confirm=true.
+// POST /admin/candidates/:id/unpublish
+func (h *AdminHandler) UnpublishCandidate(c *gin.Context) {
+	candidateID := c.Param("id")
+	reason := c.PostForm("reason")
+
+	var candidate models.EventCandidate
+	if err := h.db.Where("id = ?", candidateID).First(&candidate).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
 	}
-	
-	// Handle end time if provided
-	if endStr, ok := fields["end_date"].(string); ok && endStr != "" {
-		// Try parsing end time
-		formats := []string{
-			"2006-01-02 15:04:05",
-			"2006-01-02T15:04:05",
-			"2006-01-02 15:04",
-			"2006-01-02T15:04",
-			"2006-01-02",
-		}
-		
-		for _, format := range formats {
-			if parsed, err := time.Parse(format, endStr); err == nil {
-				event.EndTs = &parsed
-				break
-			}
-		}
+	if candidate.PublishedEventID == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Candidate is not linked to a published event"})
+		return
 	}
 
-	// Handle venue
-	if venueName, ok := fields["venue"].(string); ok && venueName != "" {
-		// Check if venue already exists
-		var venue models.Venue
-		if err := tx.Where("name ILIKE ?", venueName).First(&venue).Error; err != nil {
-			// Create new venue
-			venue = models.Venue{
-				Name: venueName,
-			}
-			
-			// Add address if available
-			if addr, ok := fields["address"].(string); ok && addr != "" {
-				venue.AddressLine = &addr
-			}
-			
-			if err := tx.Create(&venue).Error; err != nil {
-				return fmt.Errorf("failed to create venue: %v", err)
-			}
+	linked, err := services.LinkedCandidateCount(h.db, *candidate.PublishedEventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check linked candidates"})
+		return
+	}
+	if linked > 1 && c.PostForm("confirm") != "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":             fmt.Sprintf("Event is corroborated by %d candidates; unpublishing blocks all of them. Resubmit with confirm=true.", linked),
+			"linked_candidates": linked,
+		})
+		return
+	}
+
+	result, err := services.UnpublishEvent(h.db, *candidate.PublishedEventID, reason, adminActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUnpublishReason):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unpublish reason"})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Published event not found"})
+		case errors.Is(err, services.ErrInvalidStateTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is already unpublished"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpublish event"})
 		}
-		event.VenueID = &venue.ID
+		return
+	}
+
+	h.renderer(c).Success(c, result, &candidate.ID)
+}
+
+// RestoreCandidateRequest takes a blocked candidate back out of blocked
+type RestoreCandidateRequest struct {
+	Action string `json:"action" form:"action"`                    // review (default), approve
+	Reason string `json:"reason" form:"reason" binding:"required"` // why the block was wrong
+}
+
+// RestoreCandidate moves a blocked candidate back to needs_review, or approves and publishes
+// it directly, recording the reason in the audit log
+// POST /admin/candidates/:id/restore, POST /admin/api/candidates/:id/restore {"action": "review|approve", "reason": "..."}
+func (h *AdminHandler) RestoreCandidate(c *gin.Context) {
+	render := h.renderer(c)
+	candidateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid candidate ID"})
+		return
+	}
+	var req RestoreCandidateRequest
+	if err := c.ShouldBind(&req); err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "A reason is required"})
+		return
 	}
 
-	// Create the event
-	if err := tx.Create(&event).Error; err != nil {
-		return fmt.Errorf("failed to create event: %v", err)
+	candidate, err := h.ops.Restore(adminops.RestoreRequest{
+		CandidateID: candidateID,
+		Action:      req.Action,
+		Reason:      req.Reason,
+	}, adminActor(c))
+	if err != nil {
+		render.Error(c, err)
+		return
 	}
 
-	return nil
+	render.Success(c, gin.H{
+		"success":            true,
+		"status":             *candidate.PublishResult,
+		"published_event_id": candidate.PublishedEventID,
+	}, &candidate.ID)
 }
 
 // GetRawEventCandidate returns raw LLM response for debugging
@@ -461,7 +746,7 @@ func (h *AdminHandler) GetRawEventCandidate(c *gin.Context) {
 	candidateID := c.Param("id")
 
 	var candidate models.EventCandidate
-	if err := h.db.Preload("Flyer.Submission").Where("id = ?", candidateID).First(&candidate).Error; err != nil {
+	if err := h.db.Preload("Flyer.Submission").Preload("PublishedEvent").Where("id = ?", candidateID).First(&candidate).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event candidate not found"})
 		return
 	}
@@ -481,6 +766,8 @@ func (h *AdminHandler) GetRawEventCandidate(c *gin.Context) {
 		"id":                candidate.ID.String(),
 		"flyer_id":          candidate.FlyerID.String(),
 		"event_id":          candidate.EventID,
+		"published_event_id": candidate.PublishedEventID,
+		"published_event":   candidate.PublishedEvent,
 		"fields":            fields,
 		"confidences":       confidences,
 		"geocode":          geocode,
@@ -490,14 +777,1554 @@ func (h *AdminHandler) GetRawEventCandidate(c *gin.Context) {
 		"source_excerpt":    candidate.SourceExcerpt,
 		"created_at":        candidate.CreatedAt,
 		"submission":        candidate.Flyer.Submission,
+		"token_usage":       services.SubmissionTokenUsage(&candidate.Flyer.Submission),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// RegisterAdminRoutes adds admin routes to the router
-func RegisterAdminRoutes(router *gin.RouterGroup, handler *AdminHandler) {
-	router.GET("", handler.AdminDashboard)
-	router.POST("/moderate/:id", handler.ModerateEvent)
-	router.GET("/raw/:id", handler.GetRawEventCandidate)
-}
\ No newline at end of file
+// PriceTiersRequest replaces an event's price tiers
+type PriceTiersRequest struct {
+	PriceTiers models.PriceTiers `json:"price_tiers"`
+}
+
+// UpdatePriceTiers replaces a published event's price tiers and recomputes its min/max
+// PUT /admin/api/events/:id/price-tiers {"price_tiers": [{"label": "advance", "amount_cents": 1500, "url": "..."}]}
+func (h *AdminHandler) UpdatePriceTiers(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req PriceTiersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	tiers, err := services.ValidatePriceTiers(req.PriceTiers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event models.Event
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&event, "id = ?", eventID).Error; err != nil {
+			return err
+		}
+		previous := event.PriceTiers
+
+		services.ApplyPriceTiers(&event, tiers)
+		if err := tx.Model(&event).Updates(map[string]interface{}{
+			"price_tiers":     event.PriceTiers,
+			"price_min_cents": event.PriceMinCents,
+			"price_max_cents": event.PriceMaxCents,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := services.RecordEventHistoryByID(tx, event.ID); err != nil {
+			return err
+		}
+
+		if err := services.RecordAudit(tx, services.AuditEntry{
+			EntityType: services.AuditEntityEvent,
+			EntityID:   event.ID,
+			Action:     services.AuditActionEventEdited,
+			Actor:      adminActor(c),
+			Changes: map[string]interface{}{
+				"price_tiers": gin.H{"from": previous, "to": event.PriceTiers},
+			},
+		}); err != nil {
+			return err
+		}
+		return services.RecordEventChange(tx, event.ID, services.ChangeTypeUpdated, "")
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price tiers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"price_tiers":     event.PriceTiers,
+		"price_min_cents": event.PriceMinCents,
+		"price_max_cents": event.PriceMaxCents,
+	})
+}
+
+// UpdateEventRequest edits a public event: the admin-only quiet flag and calendar corrections
+// (title, reschedule). Omitted fields are left unchanged.
+type UpdateEventRequest struct {
+	Quiet   *bool      `json:"quiet"`
+	Title   *string    `json:"title"`
+	StartTs *time.Time `json:"start_ts"`
+	EndTs   *time.Time `json:"end_ts"`
+}
+
+// UpdateEvent edits a published event. Title and time changes bump its ICS sequence so
+// calendar subscribers get the update instead of a duplicate.
+// PATCH /admin/api/events/:id {"quiet": true, "start_ts": "2025-07-04T20:00:00-07:00"}
+func (h *AdminHandler) UpdateEvent(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req UpdateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	edit := services.EventEdit{Title: req.Title, StartTs: req.StartTs, EndTs: req.EndTs}
+	if req.Quiet == nil && edit.Empty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	var event models.Event
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if req.Quiet != nil {
+			if err := services.SetEventQuiet(tx, eventID, *req.Quiet, adminActor(c)); err != nil {
+				return err
+			}
+		}
+		if !edit.Empty() {
+			if _, err := services.EditEvent(tx, eventID, edit, adminActor(c)); err != nil {
+				return err
+			}
+		}
+		return tx.First(&event, "id = ?", eventID).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		case errors.Is(err, services.ErrInvalidEventEdit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, exportedEvent{Event: event, Quiet: event.Quiet})
+}
+
+// GetFieldSchema returns the deployment's extraction fields so edit forms can render them
+// GET /admin/api/fields
+func (h *AdminHandler) GetFieldSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, services.ActiveFieldSchema())
+}
+
+// UpdateCandidateFields edits a candidate's extracted fields, before or after approval; a
+// published candidate's event picks up a changed title, date, or venue.
+// Field names and values are validated against the field schema; null clears a field.
+// Dashboard forms post the fields as form values, where an empty value clears the field.
+// PATCH|PUT /admin/api/candidates/:id/fields {"title": "Corrected title", "reward": "$50"}
+// POST|PUT /admin/candidates/:id/fields (form)
+func (h *AdminHandler) UpdateCandidateFields(c *gin.Context) {
+	render := h.renderer(c)
+	candidateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid candidate ID"})
+		return
+	}
+
+	var update map[string]interface{}
+	if c.ContentType() == "application/json" {
+		if err := c.ShouldBindJSON(&update); err != nil {
+			render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid request format"})
+			return
+		}
+	} else {
+		if err := c.Request.ParseForm(); err != nil {
+			render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid request format"})
+			return
+		}
+		update = make(map[string]interface{}, len(c.Request.PostForm))
+		for name := range c.Request.PostForm {
+			if value := strings.TrimSpace(c.Request.PostForm.Get(name)); value != "" {
+				update[name] = value
+			} else {
+				update[name] = nil
+			}
+		}
+	}
+
+	fields, err := h.ops.EditCandidate(candidateID, update, adminActor(c))
+	if err != nil {
+		render.Error(c, err)
+		return
+	}
+
+	render.Success(c, gin.H{"fields": fields}, &candidateID)
+}
+
+// AdminSubmission summarizes a submission for operator tooling
+type AdminSubmission struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Source     string    `json:"source"`
+	Flyers     int64     `json:"flyers"`
+	Candidates int64     `json:"candidates"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ListSubmissions returns recent submissions, optionally filtered by status
+// GET /admin/api/submissions?status=error&limit=50
+func (h *AdminHandler) ListSubmissions(c *gin.Context) {
+	limit := 50
+	if parsed, err := strconv.Atoi(c.Query("limit")); err == nil && parsed > 0 && parsed <= 500 {
+		limit = parsed
+	}
+
+	query := h.db.Model(&models.Submission{}).Order("created_at DESC").Limit(limit)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var submissions []models.Submission
+	if err := query.Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list submissions"})
+		return
+	}
+
+	results := make([]AdminSubmission, 0, len(submissions))
+	for _, submission := range submissions {
+		result := AdminSubmission{
+			ID:        submission.ID.String(),
+			Status:    submission.Status,
+			Source:    submission.Source,
+			CreatedAt: submission.CreatedAt,
+			UpdatedAt: submission.UpdatedAt,
+		}
+		h.db.Model(&models.Flyer{}).Where("submission_id = ?", submission.ID).Count(&result.Flyers)
+		h.db.Model(&models.EventCandidate{}).
+			Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+			Where("flyers.submission_id = ?", submission.ID).
+			Count(&result.Candidates)
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"submissions": results})
+}
+
+// UnpublishEvent takes down a public event by ID, blocking every linked candidate
+// POST /admin/api/events/:id/unpublish {"reason": "spam"}
+func (h *AdminHandler) UnpublishEvent(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req UnpublishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	result, err := services.UnpublishEvent(h.db, eventID, req.Reason, adminActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUnpublishReason):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unpublish reason"})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		case errors.Is(err, services.ErrInvalidStateTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is already unpublished"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpublish event"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteEvent removes a public event entirely, leaving a tombstone (see services.DeleteEvent).
+// For takedowns; unpublishing is enough for everything else.
+// DELETE /admin/events/:id {"reason": "legal_takedown", "note": "..."}
+func (h *AdminHandler) DeleteEvent(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req DeleteEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	result, err := services.DeleteEvent(h.db, h.config, eventID, req.Reason, req.Note, adminActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidDeleteReason):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delete reason"})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		default:
+			log.Printf("Failed to delete event %s: %v", eventID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// exportColumns is the CSV header for event exports
+var exportColumns = []string{"id", "title", "start_ts", "end_ts", "venue", "address", "price", "price_min_cents", "price_max_cents", "url", "organizer", "source", "published_via", "quiet"}
+
+// exportedEvent is an event as it appears in the JSON export, with admin-only fields
+type exportedEvent struct {
+	models.Event
+	Quiet bool `json:"quiet"`
+}
+
+// ExportEvents dumps approved events overlapping [start, end) as CSV or JSON
+// GET /admin/api/export/events?start=2025-01-01&end=2025-02-01&format=csv
+func (h *AdminHandler) ExportEvents(c *gin.Context) {
+	query := services.OrderEventsChronologically(h.db.Model(&models.Event{}).Preload("Venue").
+		Where("moderation_state = ?", "approved"))
+
+	var bounds [2]*time.Time
+	for i, param := range []string{"start", "end"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s date, expected YYYY-MM-DD", param)})
+			return
+		}
+		bounds[i] = &parsed
+	}
+	query = services.WhereEventOverlaps(query, bounds[0], bounds[1])
+
+	var events []models.Event
+	if err := query.Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export events"})
+		return
+	}
+
+	if c.DefaultQuery("format", "csv") == "json" {
+		// Quiet is hidden from public JSON, so the export adds it back explicitly
+		rows := make([]exportedEvent, len(events))
+		for i, event := range events {
+			rows[i] = exportedEvent{Event: event, Quiet: event.Quiet}
+		}
+		c.JSON(http.StatusOK, gin.H{"events": rows})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="events.csv"`)
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write(exportColumns)
+	for _, event := range events {
+		venue, address := "", ""
+		if event.Venue != nil {
+			venue = event.Venue.Name
+			address = stringValue(event.Venue.AddressLine)
+		}
+		end := ""
+		if event.EndTs != nil {
+			end = event.EndTs.UTC().Format(time.RFC3339)
+		}
+		_ = writer.Write([]string{
+			event.ID.String(),
+			event.Title,
+			event.StartTs.UTC().Format(time.RFC3339),
+			end,
+			venue,
+			address,
+			stringValue(event.Price),
+			intValue(event.PriceMinCents),
+			intValue(event.PriceMaxCents),
+			stringValue(event.URL),
+			stringValue(event.Organizer),
+			event.Source,
+			event.PublishedVia,
+			strconv.FormatBool(event.Quiet),
+		})
+	}
+	writer.Flush()
+}
+
+// PurgeFailedSubmissions removes errored submissions older than PURGE_AFTER_DAYS, and
+// candidates blocked by moderation more than BLOCKED_RETENTION_DAYS ago
+// POST /admin/api/maintenance/purge?dry_run=true
+func (h *AdminHandler) PurgeFailedSubmissions(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+	olderThan := time.Now().AddDate(0, 0, -h.config.PurgeAfterDays)
+
+	report, err := services.PurgeFailedSubmissions(h.db, h.storage, olderThan, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Purge failed"})
+		return
+	}
+
+	// Moderation blocks nobody restored within BLOCKED_RETENTION_DAYS go in the same run
+	if h.config.BlockedRetentionDays > 0 {
+		blockedBefore := time.Now().AddDate(0, 0, -h.config.BlockedRetentionDays)
+		report.Blocked, err = services.PurgeBlockedCandidates(h.db, h.storage, blockedBefore, dryRun)
+		if err != nil {
+			log.Printf("Purge of blocked candidates failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Purge of blocked candidates failed"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// BackfillSearchText recomputes search_text for candidates stored before it existed
+// POST /admin/api/maintenance/search-backfill
+func (h *AdminHandler) BackfillSearchText(c *gin.Context) {
+	report, err := services.BackfillCandidateSearchText(h.db)
+	if err != nil {
+		log.Printf("Search backfill failed after %d candidates: %v", report.Scanned, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search backfill failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// AdminCandidateSearchResult is one candidate matched by an admin search
+type AdminCandidateSearchResult struct {
+	ID               string     `json:"id"`
+	FlyerID          string     `json:"flyer_id"`
+	Title            string     `json:"title"`
+	Venue            string     `json:"venue"`
+	Organizer        string     `json:"organizer"`
+	PublishResult    *string    `json:"publish_result"`
+	PublishedEventID *uuid.UUID `json:"published_event_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// SearchCandidates finds candidates by title, venue, organizer, or description
+// GET /admin/api/candidates/search?q=jazz&limit=50
+func (h *AdminHandler) SearchCandidates(c *gin.Context) {
+	limit := 50
+	if parsed, err := strconv.Atoi(c.Query("limit")); err == nil && parsed > 0 {
+		limit = parsed
+	}
+
+	candidates, err := services.SearchCandidates(h.db, c.Query("q"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrSearchQueryTooShort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query must be at least 3 characters"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+
+	results := make([]AdminCandidateSearchResult, 0, len(candidates))
+	for _, candidate := range candidates {
+		var fields map[string]interface{}
+		json.Unmarshal([]byte(candidate.Fields), &fields)
+		title, _ := fields["title"].(string)
+		venue, _ := fields["venue"].(string)
+		organizer, _ := fields["organizer"].(string)
+		results = append(results, AdminCandidateSearchResult{
+			ID:               candidate.ID.String(),
+			FlyerID:          candidate.FlyerID.String(),
+			Title:            title,
+			Venue:            venue,
+			Organizer:        organizer,
+			PublishResult:    candidate.PublishResult,
+			PublishedEventID: candidate.PublishedEventID,
+			CreatedAt:        candidate.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"candidates": results})
+}
+
+// adminActor identifies the moderator and route behind an admin request for the audit log
+func adminActor(c *gin.Context) services.Actor {
+	return services.Actor{
+		Type:    services.ActorAdmin,
+		AdminID: middleware.AdminID(c),
+		Route:   c.FullPath(),
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func intValue(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+// MergeVenueRequest names the venue that absorbs the merged one
+type MergeVenueRequest struct {
+	Into string `json:"into" form:"into" binding:"required"`
+}
+
+// DeleteVenue removes a venue, re-pointing its events to ?reassign_to= if any reference it
+// DELETE /admin/api/venues/:id?reassign_to=<venue id>
+func (h *AdminHandler) DeleteVenue(c *gin.Context) {
+	venueID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return
+	}
+
+	var reassignTo *uuid.UUID
+	if raw := c.Query("reassign_to"); raw != "" {
+		target, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassign_to venue ID"})
+			return
+		}
+		reassignTo = &target
+	}
+
+	result, err := h.ops.DeleteVenue(venueID, reassignTo, adminActor(c))
+	if err != nil {
+		jsonAdminRenderer{}.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MergeVenue folds a venue into another, re-pointing all of its events
+// POST /admin/api/venues/:id/merge {"into": "<venue id>"}, POST /admin/venues/:id/merge (form)
+func (h *AdminHandler) MergeVenue(c *gin.Context) {
+	render := h.renderer(c)
+	sourceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid venue ID"})
+		return
+	}
+
+	var req MergeVenueRequest
+	if err := c.ShouldBind(&req); err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid request format"})
+		return
+	}
+	targetID, err := uuid.Parse(req.Into)
+	if err != nil {
+		render.Error(c, &adminops.Error{Kind: adminops.KindInvalid, Message: "Invalid target venue ID"})
+		return
+	}
+
+	result, err := h.ops.MergeVenue(sourceID, targetID, adminActor(c))
+	if err != nil {
+		render.Error(c, err)
+		return
+	}
+
+	render.Success(c, result, nil)
+}
+
+// CategorySettingRequest sets one category's auto-publish overrides; omitted or null
+// fields use the global AUTO_PUBLISH_* value
+type CategorySettingRequest struct {
+	AutoPublishThreshold *float64 `json:"auto_publish_threshold"`
+	MinStartOffsetMin    *int     `json:"min_start_offset_min"`
+	MaxStartOffsetDays   *int     `json:"max_start_offset_days"`
+}
+
+// ListCategorySettings returns the global auto-publish policy and every category override
+// GET /admin/api/settings/categories
+func (h *AdminHandler) ListCategorySettings(c *gin.Context) {
+	settings, err := services.ListCategorySettings(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load category settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"default":    services.DefaultPublishPolicy(h.db, h.config),
+		"categories": settings,
+	})
+}
+
+// UpdateCategorySetting creates or replaces a category's auto-publish overrides
+// PUT /admin/api/settings/categories/:category {"auto_publish_threshold": 0.9, "max_start_offset_days": 30}
+func (h *AdminHandler) UpdateCategorySetting(c *gin.Context) {
+	var req CategorySettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var setting *models.CategoryPublishSetting
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		setting, err = services.SaveCategorySetting(tx, models.CategoryPublishSetting{
+			Category:             c.Param("category"),
+			AutoPublishThreshold: req.AutoPublishThreshold,
+			MinStartOffsetMin:    req.MinStartOffsetMin,
+			MaxStartOffsetDays:   req.MaxStartOffsetDays,
+		}, adminActor(c))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCategorySetting) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save category setting"})
+		return
+	}
+
+	c.JSON(http.StatusOK, setting)
+}
+
+// DeleteCategorySetting returns a category to the global auto-publish policy
+// DELETE /admin/api/settings/categories/:category
+func (h *AdminHandler) DeleteCategorySetting(c *gin.Context) {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return services.DeleteCategorySetting(tx, c.Param("category"), adminActor(c))
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category has no override"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category setting"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetTokenUsage totals the vision and moderation tokens billed for recent submissions
+// GET /admin/api/stats/tokens?window=24h|7d|30d
+func (h *AdminHandler) GetTokenUsage(c *gin.Context) {
+	window := c.DefaultQuery("window", "7d")
+	duration, ok := services.ReviewLatencyWindows[window]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window. Allowed: 24h, 7d, 30d"})
+		return
+	}
+
+	usage, err := services.SumTokenUsage(h.db, time.Now().Add(-duration))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute token usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window": window,
+		"usage":  usage,
+	})
+}
+
+// GetCategoryPublishRates breaks down automated decisions by normalized category
+// GET /admin/api/stats/categories?window=24h|7d|30d
+func (h *AdminHandler) GetCategoryPublishRates(c *gin.Context) {
+	window := c.DefaultQuery("window", "7d")
+	if _, ok := services.ReviewLatencyWindows[window]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window. Allowed: 24h, 7d, 30d"})
+		return
+	}
+
+	rates, err := services.ComputeCategoryPublishRates(h.db, h.config, window, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute category publish rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window":     window,
+		"categories": rates,
+	})
+}
+
+// GetExperiment compares outcomes per variant for a vision model experiment
+// GET /admin/api/experiments/:name
+func (h *AdminHandler) GetExperiment(c *gin.Context) {
+	name := c.Param("name")
+	outcomes, err := services.ComputeExperimentOutcomes(h.db, name)
+	if err != nil {
+		if errors.Is(err, services.ErrExperimentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No submissions were assigned to this experiment"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute experiment outcomes"})
+		return
+	}
+
+	active := services.ActiveVisionExperiment()
+	c.JSON(http.StatusOK, gin.H{
+		"name":     name,
+		"running":  active != nil && active.Name == name,
+		"variants": outcomes,
+	})
+}
+
+// GetModelVersions reports the exact OpenAI models that served our calls, and weekly publish
+// and correction rates of the candidates each vision model version extracted
+// GET /admin/api/models?days=90
+func (h *AdminHandler) GetModelVersions(c *gin.Context) {
+	days := 90
+	if daysStr := c.Query("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed <= 0 || parsed > services.MaxModelReportDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", services.MaxModelReportDays)})
+			return
+		}
+		days = parsed
+	}
+
+	report, err := services.ComputeModelVersionReport(h.db, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute model version report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"days":     days,
+		"versions": report.Versions,
+		"weekly":   report.Weekly,
+	})
+}
+
+// ListDeadLetters returns outbox entries that ran out of delivery attempts, newest first
+// GET /admin/api/outbox/dead?sink=webhook&limit=100
+func (h *AdminHandler) ListDeadLetters(c *gin.Context) {
+	limit := services.MaxOutboxPage
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	entries, err := services.ListDeadLetters(h.db, c.Query("sink"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dead letters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// RedriveOutboxEntry queues a dead-lettered outbox entry for delivery again
+// POST /admin/api/outbox/:id/redrive
+func (h *AdminHandler) RedriveOutboxEntry(c *gin.Context) {
+	entryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outbox entry ID"})
+		return
+	}
+
+	entry, err := services.RedriveOutboxEntry(h.db, entryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOutboxEntryNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Outbox entry not found"})
+		case errors.Is(err, services.ErrOutboxEntryNotDead):
+			c.JSON(http.StatusConflict, gin.H{"error": "Outbox entry is not dead-lettered"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redrive outbox entry"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// PutSettingRequest sets a runtime setting; a null value unsets it so the env default applies
+type PutSettingRequest struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// ListSettings returns every registered runtime setting with its effective value
+// GET /admin/api/settings
+func (h *AdminHandler) ListSettings(c *gin.Context) {
+	settings, err := services.ListSettings(h.db, h.config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": settings,
+		"count":    len(settings),
+	})
+}
+
+// GetSetting returns one runtime setting's effective value and recent change history
+// GET /admin/api/settings/:key
+func (h *AdminHandler) GetSetting(c *gin.Context) {
+	setting, err := services.GetSetting(h.db, h.config, c.Param("key"))
+	if err != nil {
+		h.respondSettingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setting)
+}
+
+// PutSetting validates and stores a runtime setting value
+// PUT /admin/api/settings/:key {"value": 0.9}
+func (h *AdminHandler) PutSetting(c *gin.Context) {
+	var req PutSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	setting, err := services.PutSetting(h.db, h.config, c.Param("key"), req.Value, adminActor(c))
+	if err != nil {
+		h.respondSettingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setting)
+}
+
+// respondSettingError maps runtime setting errors to HTTP responses
+func (h *AdminHandler) respondSettingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownSetting):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown setting"})
+	case errors.Is(err, services.ErrInvalidSettingValue):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Setting update failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update setting"})
+	}
+}
+
+// CreateVenueClaimRequest names the venue manager a claim token is issued to
+type CreateVenueClaimRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// CreateVenueClaim associates an email with a venue and returns the management token, which
+// is shown only here and must be delivered to the venue manager out-of-band
+// POST /admin/api/venues/:id/claims {"email": "manager@example.com"}
+func (h *AdminHandler) CreateVenueClaim(c *gin.Context) {
+	venueID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return
+	}
+
+	var req CreateVenueClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	claim, err := services.CreateVenueClaim(h.db, h.config.VenueClaimSigningKey, venueID, req.Email, h.config.VenueClaimTTL(), adminActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVenueClaimsDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Venue claims are not configured"})
+		case errors.Is(err, services.ErrVenueNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create venue claim"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, claim)
+}
+
+// ListVenueClaims returns every claim issued for a venue, without tokens
+// GET /admin/api/venues/:id/claims
+func (h *AdminHandler) ListVenueClaims(c *gin.Context) {
+	venueID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return
+	}
+
+	claims, err := services.ListVenueClaims(h.db, venueID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load venue claims"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"claims": claims,
+		"count":  len(claims),
+	})
+}
+
+// RevokeVenueClaim stops a claim's token from working
+// DELETE /admin/api/venues/:id/claims/:claim_id
+func (h *AdminHandler) RevokeVenueClaim(c *gin.Context) {
+	venueID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return
+	}
+	claimID, err := uuid.Parse(c.Param("claim_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim ID"})
+		return
+	}
+
+	claim, err := services.RevokeVenueClaim(h.db, venueID, claimID, adminActor(c))
+	if err != nil {
+		if errors.Is(err, services.ErrVenueClaimNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Venue claim not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke venue claim"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+// ListDisputes returns venue disputes, oldest first; ?status= defaults to pending
+// GET /admin/api/disputes?status=pending|resolved|dismissed|all
+func (h *AdminHandler) ListDisputes(c *gin.Context) {
+	status := c.DefaultQuery("status", services.FlagStatusPending)
+	if status == "all" {
+		status = ""
+	}
+
+	disputes, err := services.ListDisputes(h.db, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load disputes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"disputes": disputes,
+		"count":    len(disputes),
+	})
+}
+
+// ResolveDispute accepts (unpublishes the event) or rejects (restores the event) a venue dispute
+// POST /admin/disputes/:id/:action, POST /admin/api/disputes/:id/:action (action: accept, reject)
+func (h *AdminHandler) ResolveDispute(c *gin.Context) {
+	flagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispute ID"})
+		return
+	}
+	action := c.Param("action")
+	if action != "accept" && action != "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+		return
+	}
+
+	flag, err := services.ResolveDispute(h.db, flagID, action == "accept", adminActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDisputeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		case errors.Is(err, services.ErrDisputeResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": "Dispute already resolved"})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		case errors.Is(err, services.ErrInvalidStateTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is no longer under review"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dispute"})
+		}
+		return
+	}
+
+	// Return JSON for the API and HTMX/AJAX requests or redirect for form submissions
+	if strings.HasPrefix(c.FullPath(), "/admin/api/") || c.GetHeader("HX-Request") == "true" || c.GetHeader("Accept") == "application/json" {
+		c.JSON(http.StatusOK, flag)
+	} else {
+		c.Redirect(http.StatusSeeOther, "/admin")
+	}
+}
+
+// ListDuplicates returns pending near-duplicate merges, grouped by the event they were
+// merged into
+// GET /admin/dupes, GET /admin/duplicates
+func (h *AdminHandler) ListDuplicates(c *gin.Context) {
+	groups, err := services.ListPendingDuplicates(h.db)
+	if err != nil {
+		log.Printf("Failed to list duplicate suggestions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list duplicates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// ResolveDuplicateRequest accepts or rejects a merge suggestion
+type ResolveDuplicateRequest struct {
+	Action string `json:"action" form:"action" binding:"required"` // accept, reject
+}
+
+// ResolveDuplicate confirms a merge (the duplicate stays hidden, its candidates move to the
+// primary, and its public URL redirects there) or undoes it (the duplicate is published on
+// its own)
+// POST /admin/dupes/:id/resolve {"action": "accept|reject"}, POST /admin/duplicates/:id/confirm|reject
+func (h *AdminHandler) ResolveDuplicate(c *gin.Context) {
+	linkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duplicate ID"})
+		return
+	}
+	action := c.Param("action")
+	if action == "" {
+		var req ResolveDuplicateRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+			return
+		}
+		action = req.Action
+	}
+	if action == "confirm" {
+		action = "accept"
+	}
+	if action != "accept" && action != "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+		return
+	}
+
+	link, err := services.ResolveDuplicate(h.db, linkID, action == "accept", adminActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDedupeLinkNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Duplicate not found"})
+		case errors.Is(err, services.ErrDedupeLinkResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": "Duplicate already resolved"})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		case errors.Is(err, services.ErrInvalidStateTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is no longer merged"})
+		default:
+			log.Printf("Failed to resolve duplicate %s: %v", linkID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve duplicate"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+// ListFlags returns pending public flags grouped by event, most-flagged events first
+// GET /admin/flags
+func (h *AdminHandler) ListFlags(c *gin.Context) {
+	flagged, err := services.ListPendingFlags(h.db)
+	if err != nil {
+		log.Printf("Failed to list flags: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list flags"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"events": flagged,
+		"count":  len(flagged),
+	})
+}
+
+// ResolveFlagRequest dismisses a public flag or upholds it by unpublishing the event
+type ResolveFlagRequest struct {
+	Action string `json:"action" form:"action" binding:"required"` // dismiss, unpublish (or remove_event)
+}
+
+// ResolveFlag dismisses a public flag, or resolves it by unpublishing the flagged event
+// POST /admin/flags/:id/resolve {"action": "dismiss|unpublish|remove_event"}
+func (h *AdminHandler) ResolveFlag(c *gin.Context) {
+	flagID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flag ID"})
+		return
+	}
+	var req ResolveFlagRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+		return
+	}
+
+	flag, err := services.ResolveFlag(h.db, flagID, req.Action, adminActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidFlagAction):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+		case errors.Is(err, services.ErrFlagNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Flag not found"})
+		case errors.Is(err, services.ErrFlagResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": "Flag already resolved"})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		case errors.Is(err, services.ErrInvalidStateTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is already unpublished"})
+		default:
+			log.Printf("Failed to resolve flag %s: %v", flagID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve flag"})
+		}
+		return
+	}
+
+	// Return JSON for API and HTMX/AJAX requests or redirect for dashboard form submissions
+	if c.GetHeader("HX-Request") == "true" || strings.Contains(c.GetHeader("Accept"), "application/json") || c.ContentType() == "application/json" {
+		c.JSON(http.StatusOK, flag)
+	} else {
+		c.Redirect(http.StatusSeeOther, "/admin")
+	}
+}
+
+// RegisterAdminRoutes adds admin routes to the router
+func RegisterAdminRoutes(router *gin.RouterGroup, handler *AdminHandler) {
+	router.GET("", handler.AdminDashboard)
+	router.POST("/moderate/bulk", handler.ModerateBulk)
+	router.POST("/moderate/:id", handler.ModerateEvent)
+	router.GET("/raw/:id", handler.GetRawEventCandidate)
+	router.POST("/candidates/:id/unpublish", handler.UnpublishCandidate)
+	router.POST("/candidates/:id/restore", handler.RestoreCandidate)
+	router.POST("/candidates/:id/fields", handler.UpdateCandidateFields)
+	router.PUT("/candidates/:id/fields", handler.UpdateCandidateFields)
+	router.POST("/candidates/bulk", handler.BulkModerate)
+	router.POST("/venues/:id/merge", handler.MergeVenue)
+	router.GET("/candidates/:id/preview", handler.CandidatePreview)
+	router.GET("/events/:id", handler.EventDetail)
+	router.DELETE("/events/:id", handler.DeleteEvent)
+	router.GET("/activity", handler.ActivityPage)
+	router.GET("/flyers/:id/crop", handler.GetFlyerCrop)
+	router.GET("/flyers/:id/redaction", handler.GetFlyerRedaction)
+	router.POST("/flyers/:id/redact", handler.RedactFlyer)
+	router.POST("/disputes/:id/:action", handler.ResolveDispute)
+	router.GET("/dupes", handler.ListDuplicates)
+	router.POST("/dupes/:id/resolve", handler.ResolveDuplicate)
+	router.GET("/duplicates", handler.ListDuplicates)
+	router.POST("/duplicates/:id/:action", handler.ResolveDuplicate)
+	router.GET("/flags", handler.ListFlags)
+	router.POST("/flags/:id/resolve", handler.ResolveFlag)
+
+	api := router.Group("/api")
+	{
+		api.GET("/submissions", handler.ListSubmissions)
+		api.POST("/events/:id/unpublish", handler.UnpublishEvent)
+		api.GET("/events/:id/transitions", handler.GetEventTransitions)
+		api.GET("/export/events", handler.ExportEvents)
+		api.POST("/maintenance/purge", handler.PurgeFailedSubmissions)
+		api.POST("/maintenance/search-backfill", handler.BackfillSearchText)
+		api.GET("/candidates/search", handler.SearchCandidates)
+		api.GET("/stats/review-latency", handler.GetReviewLatency)
+		api.GET("/stats/fingerprints", handler.GetTopFingerprints)
+		api.GET("/stats/categories", handler.GetCategoryPublishRates)
+		api.GET("/stats/tokens", handler.GetTokenUsage)
+		api.GET("/settings/categories", handler.ListCategorySettings)
+		api.PUT("/settings/categories/:category", handler.UpdateCategorySetting)
+		api.DELETE("/settings/categories/:category", handler.DeleteCategorySetting)
+		api.GET("/settings", handler.ListSettings)
+		api.GET("/settings/:key", handler.GetSetting)
+		api.PUT("/settings/:key", handler.PutSetting)
+		api.GET("/activity", handler.GetActivity)
+		api.GET("/dates/unparsed", handler.ListUnparsedDates)
+		api.PATCH("/events/:id", handler.UpdateEvent)
+		api.PUT("/events/:id/price-tiers", handler.UpdatePriceTiers)
+		api.GET("/fields", handler.GetFieldSchema)
+		api.PATCH("/candidates/:id/fields", handler.UpdateCandidateFields)
+		api.PUT("/candidates/:id/fields", handler.UpdateCandidateFields)
+		api.POST("/candidates/:id/moderate", handler.ModerateEvent)
+		api.POST("/candidates/:id/restore", handler.RestoreCandidate)
+		api.POST("/candidates/bulk", handler.BulkModerate)
+		api.POST("/moderate/bulk", handler.ModerateBulk)
+		api.DELETE("/venues/:id", handler.DeleteVenue)
+		api.POST("/venues/:id/merge", handler.MergeVenue)
+		api.GET("/experiments/:name", handler.GetExperiment)
+		api.GET("/models", handler.GetModelVersions)
+		api.GET("/outbox/dead", handler.ListDeadLetters)
+		api.POST("/outbox/:id/redrive", handler.RedriveOutboxEntry)
+		api.POST("/venues/:id/claims", handler.CreateVenueClaim)
+		api.GET("/venues/:id/claims", handler.ListVenueClaims)
+		api.DELETE("/venues/:id/claims/:claim_id", handler.RevokeVenueClaim)
+		api.GET("/disputes", handler.ListDisputes)
+		api.POST("/disputes/:id/:action", handler.ResolveDispute)
+	}
+}
+// RedactFlyerRequest lists boxes to black out, in the unredacted crop's pixel coordinates
+type RedactFlyerRequest struct {
+	Rects []services.RedactionRect `json:"rects" binding:"required"`
+}
+
+// RedactFlyer saves a redacted copy of a flyer crop as its public image
+// POST /admin/flyers/:id/redact {"rects": [{"x": 10, "y": 400, "width": 300, "height": 80}]}
+func (h *AdminHandler) RedactFlyer(c *gin.Context) {
+	flyerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flyer ID"})
+		return
+	}
+
+	var req RedactFlyerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	flyer, err := services.RedactFlyer(h.db, h.storage, flyerID, req.Rects, adminActor(c))
+	if err != nil {
+		h.respondFlyerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"flyer_id":         flyer.ID,
+		"crop_image_url":   flyer.CropImageURL,
+		"public_image_url": flyer.PublicImageURL,
+		"redactions":       req.Rects,
+		"redacted_at":      flyer.RedactedAt,
+	})
+}
+
+// GetFlyerRedaction returns a flyer's current redaction and any suggested regions
+// GET /admin/flyers/:id/redaction
+func (h *AdminHandler) GetFlyerRedaction(c *gin.Context) {
+	flyerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flyer ID"})
+		return
+	}
+
+	var flyer models.Flyer
+	if err := h.db.First(&flyer, "id = ?", flyerID).Error; err != nil {
+		h.respondFlyerError(c, services.ErrFlyerNotFound)
+		return
+	}
+
+	suggestion, err := services.SuggestRedactions(h.db, flyerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load candidates"})
+		return
+	}
+
+	var redactions []services.RedactionRect
+	if flyer.Redactions != nil {
+		json.Unmarshal([]byte(*flyer.Redactions), &redactions)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"flyer_id":         flyer.ID,
+		"crop_image_url":   h.storage.GetAdminCropURL(flyer.ID),
+		"public_image_url": flyer.PublicImageURL,
+		"redactions":       redactions,
+		"redacted_at":      flyer.RedactedAt,
+		"suggested":        suggestion,
+	})
+}
+
+// GetFlyerCrop serves the unredacted crop, which is never exposed under /files
+// GET /admin/flyers/:id/crop
+func (h *AdminHandler) GetFlyerCrop(c *gin.Context) {
+	flyerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flyer ID"})
+		return
+	}
+
+	var flyer models.Flyer
+	if err := h.db.First(&flyer, "id = ?", flyerID).Error; err != nil {
+		h.respondFlyerError(c, services.ErrFlyerNotFound)
+		return
+	}
+
+	path, err := services.FlyerCropPath(h.storage, &flyer)
+	if err != nil {
+		h.respondFlyerError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.File(path)
+}
+
+// respondFlyerError maps flyer redaction errors to HTTP responses
+func (h *AdminHandler) respondFlyerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrFlyerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flyer not found"})
+	case errors.Is(err, services.ErrInvalidRedaction):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFlyerCropUnavailable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Flyer has no image to crop"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redact flyer"})
+	}
+}
+
+// UnparsedDate is a distinct candidate date string the date parser could not read
+type UnparsedDate struct {
+	Raw        string    `json:"raw"`
+	Count      int64     `json:"count"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// ListUnparsedDates returns distinct extracted date strings that fail to parse, most
+// frequent first, for growing the date parser's corpus
+// GET /admin/api/dates/unparsed?limit=100
+func (h *AdminHandler) ListUnparsedDates(c *gin.Context) {
+	limit := 100
+	if parsed, err := strconv.Atoi(c.Query("limit")); err == nil && parsed > 0 && parsed <= 1000 {
+		limit = parsed
+	}
+
+	var rows []UnparsedDate
+	err := h.db.Model(&models.EventCandidate{}).
+		Select("COALESCE(NULLIF(fields->>'date', ''), fields->>'date_time') AS raw, COUNT(*) AS count, MAX(created_at) AS last_seen_at").
+		Where("COALESCE(NULLIF(fields->>'date', ''), fields->>'date_time') <> ''").
+		Group("raw").
+		Order("count DESC").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load candidate dates"})
+		return
+	}
+
+	loc := services.RegionLocation(h.config)
+	unparsed := make([]UnparsedDate, 0)
+	for _, row := range rows {
+		if _, err := dateparse.Parse(row.Raw, row.LastSeenAt, loc); err != nil {
+			unparsed = append(unparsed, row)
+			if len(unparsed) == limit {
+				break
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dates": unparsed,
+		"total": len(unparsed),
+	})
+}
+
+// EventProvenance is a candidate that published or corroborated an event
+type EventProvenance struct {
+	CandidateID   string    `json:"candidate_id"`
+	SubmissionID  string    `json:"submission_id"`
+	Source        string    `json:"source"`
+	PublishResult string    `json:"publish_result"`
+	Score         float64   `json:"score"`
+	ImageURL      string    `json:"image_url,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// loadEventProvenance returns the candidates linked to an event, oldest first
+func (h *AdminHandler) loadEventProvenance(eventID uuid.UUID) ([]EventProvenance, error) {
+	var candidates []models.EventCandidate
+	if err := h.db.Preload("Flyer.Submission").
+		Where("published_event_id = ?", eventID).
+		Order("created_at ASC").
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	provenance := make([]EventProvenance, 0, len(candidates))
+	for _, candidate := range candidates {
+		entry := EventProvenance{
+			CandidateID:  candidate.ID.String(),
+			SubmissionID: candidate.Flyer.SubmissionID.String(),
+			Source:       candidate.Flyer.Submission.Source,
+			CreatedAt:    candidate.CreatedAt,
+		}
+		if candidate.PublishResult != nil {
+			entry.PublishResult = *candidate.PublishResult
+		}
+		if candidate.CompositeScore != nil {
+			entry.Score = *candidate.CompositeScore
+		}
+		if candidate.Flyer.CropImageURL != nil {
+			entry.ImageURL = *candidate.Flyer.CropImageURL
+		} else if candidate.Flyer.ImageIndex > 1 {
+			entry.ImageURL = h.storage.GetSubmissionImageURL(candidate.Flyer.SubmissionID, candidate.Flyer.ImageIndex)
+		} else {
+			entry.ImageURL = candidate.Flyer.Submission.OriginalImageURL
+		}
+		provenance = append(provenance, entry)
+	}
+	return provenance, nil
+}
+
+// GetEventTransitions returns an event's moderation state history
+// GET /admin/api/events/:id/transitions
+func (h *AdminHandler) GetEventTransitions(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.Select("id", "moderation_state").First(&event, "id = ?", eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	transitions, err := services.ListEventTransitions(h.db, eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transitions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"event_id":         event.ID,
+		"moderation_state": event.ModerationState,
+		"transitions":      transitions,
+	})
+}
+
+// EventDetail renders an event with the candidates it came from and its state history
+// GET /admin/events/:id
+func (h *AdminHandler) EventDetail(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "event_detail.html", gin.H{"title": "Event", "error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.Preload("Venue").First(&event, "id = ?", eventID).Error; err != nil {
+		c.HTML(http.StatusNotFound, "event_detail.html", gin.H{"title": "Event", "error": "Event not found"})
+		return
+	}
+
+	provenance, err := h.loadEventProvenance(eventID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "event_detail.html", gin.H{"title": event.Title, "error": "Failed to load candidates"})
+		return
+	}
+
+	transitions, err := services.ListEventTransitions(h.db, eventID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "event_detail.html", gin.H{"title": event.Title, "error": "Failed to load state history"})
+		return
+	}
+
+	c.HTML(http.StatusOK, "event_detail.html", gin.H{
+		"title":       event.Title,
+		"event":       event,
+		"provenance":  provenance,
+		"transitions": transitions,
+	})
+}
+
+// CandidatePreview renders a candidate as its public event page would look, built from
+// its current fields by the same merge that publishing uses
+// GET /admin/candidates/:id/preview
+func (h *AdminHandler) CandidatePreview(c *gin.Context) {
+	candidateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.renderPreviewError(c, http.StatusBadRequest, "Invalid candidate ID")
+		return
+	}
+
+	var candidate models.EventCandidate
+	if err := h.db.Preload("Flyer").First(&candidate, "id = ?", candidateID).Error; err != nil {
+		h.renderPreviewError(c, http.StatusNotFound, "Candidate not found")
+		return
+	}
+	if candidate.PublishedEventID != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/events/"+candidate.PublishedEventID.String())
+		return
+	}
+
+	data := h.previewData(c, &candidate, true)
+	if h.config.PreviewSigningKey != "" {
+		expires := time.Now().Add(h.config.PreviewLinkTTL())
+		data["shareURL"] = services.CandidatePreviewURL(h.config.PublicBaseURL, h.config.PreviewSigningKey, candidate.ID, expires)
+		data["shareExpires"] = expires
+	}
+	h.renderPreview(c, data)
+}
+
+// SignedCandidatePreview renders the same preview for a signed link, without admin
+// controls. Only the redacted flyer image is shown. Links stop working once the
+// candidate is published or rejected.
+// GET /preview/candidates/:id?expires=...&sig=...
+func (h *AdminHandler) SignedCandidatePreview(c *gin.Context) {
+	candidateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.renderPreviewError(c, http.StatusNotFound, "Preview not found")
+		return
+	}
+	switch err := services.VerifyCandidatePreview(h.config.PreviewSigningKey, candidateID, c.Query("expires"), c.Query("sig"), time.Now()); {
+	case errors.Is(err, services.ErrPreviewLinkExpired):
+		h.renderPreviewError(c, http.StatusGone, "This preview link has expired")
+		return
+	case err != nil:
+		h.renderPreviewError(c, http.StatusNotFound, "Preview not found")
+		return
+	}
+
+	var candidate models.EventCandidate
+	if err := h.db.Preload("Flyer").First(&candidate, "id = ?", candidateID).Error; err != nil {
+		h.renderPreviewError(c, http.StatusNotFound, "Preview not found")
+		return
+	}
+	if candidate.PublishedEventID != nil || (candidate.PublishResult != nil && *candidate.PublishResult == "blocked") {
+		h.renderPreviewError(c, http.StatusGone, "This event has already been reviewed")
+		return
+	}
+
+	h.renderPreview(c, h.previewData(c, &candidate, false))
+}
+
+// previewData builds the template data for a candidate preview. Admins see the
+// unredacted crop when no redacted image exists yet.
+func (h *AdminHandler) previewData(c *gin.Context, candidate *models.EventCandidate, admin bool) gin.H {
+	loc := services.RegionLocation(h.config)
+	data := gin.H{
+		"title":     "Preview",
+		"admin":     admin,
+		"candidate": candidate,
+	}
+
+	draft, err := services.DraftEventFromCandidate(h.db, candidate, loc)
+	if err != nil {
+		data["draftError"] = err.Error()
+	} else {
+		data["title"] = "Preview: " + draft.Event.Title
+		data["event"] = draft.Event
+		data["venue"] = draft.Venue
+		data["newVenue"] = draft.NewVenue
+		data["existing"] = draft.Existing
+		data["start"] = draft.Event.StartTs.In(loc).Format("Monday, January 2, 2006 at 3:04 PM MST")
+		if draft.Event.EndTs != nil {
+			data["end"] = draft.Event.EndTs.In(loc).Format("Monday, January 2, 2006 at 3:04 PM MST")
+		}
+	}
+
+	switch {
+	case candidate.Flyer.PublicImageURL != nil:
+		data["imageURL"] = *candidate.Flyer.PublicImageURL
+	case admin && candidate.Flyer.CropImageURL != nil:
+		data["imageURL"] = "/admin/flyers/" + candidate.FlyerID.String() + "/crop"
+		data["imageUnredacted"] = true
+	}
+	return data
+}
+
+// renderPreview sends a preview page that search engines and caches must not keep
+func (h *AdminHandler) renderPreview(c *gin.Context, data gin.H) {
+	c.Header("X-Robots-Tag", "noindex, nofollow, noarchive")
+	c.Header("Cache-Control", "private, no-store")
+	c.HTML(http.StatusOK, "candidate_preview.html", data)
+}
+
+func (h *AdminHandler) renderPreviewError(c *gin.Context, status int, message string) {
+	c.Header("X-Robots-Tag", "noindex, nofollow, noarchive")
+	c.HTML(status, "candidate_preview.html", gin.H{"title": "Preview", "error": message})
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/middleware"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+func TestCandidateRowEscapesExtractedText(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../templates/*"))
+	h := &AdminHandler{config: &config.Config{ReviewSLAHours: 24}}
+
+	tests := []struct {
+		name  string
+		field string
+		value string
+		// raw must not appear in the rendered row
+		raw string
+	}{
+		{"script in title", "title", `<script>alert("title")</script>`, `<script>alert("title")</script>`},
+		{"attribute breakout in title", "title", `"><img src=x onerror=alert(1)>`, `<img src=x onerror=alert(1)>`},
+		{"script in venue", "venue", `<script>alert("venue")</script>`, `<script>alert("venue")</script>`},
+		{"markup in address", "address", `<iframe src="//evil.example"></iframe>`, `<iframe src="//evil.example">`},
+		{"markup in date", "date_time", `<b onmouseover=alert(1)>Friday</b>`, `<b onmouseover=alert(1)>`},
+		{"attribute breakout in price", "price", `'><svg onload=alert(1)>`, `<svg onload=alert(1)>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]string{"title": "Open Mic", tt.field: tt.value}
+			fieldsJSON, err := json.Marshal(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			needsReview := "needs_review"
+			candidate := &models.EventCandidate{
+				ID:            uuid.New(),
+				FlyerID:       uuid.New(),
+				Fields:        string(fieldsJSON),
+				Confidences:   `{}`,
+				PublishResult: &needsReview,
+				CreatedAt:     time.Now().Add(-time.Hour),
+			}
+
+			var out bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&out, "candidate_row", h.transformEventCandidate(candidate)); err != nil {
+				t.Fatalf("render candidate_row: %v", err)
+			}
+			if strings.Contains(out.String(), tt.raw) {
+				t.Errorf("rendered row contains unescaped %q", tt.raw)
+			}
+		})
+	}
+}
+
+func TestTransformManualCandidate(t *testing.T) {
+	h := &AdminHandler{config: &config.Config{ReviewSLAHours: 24}}
+	needsReview := "needs_review"
+	candidate := &models.EventCandidate{
+		ID:            uuid.New(),
+		Fields:        `{"title":"Bake Sale","date_time":"Sat 10am","venue":"Library"}`,
+		Confidences:   `{}`,
+		PublishResult: &needsReview,
+		CreatedAt:     time.Now(),
+		Flyer: models.Flyer{
+			RegionID:   services.ManualFlyerRegionID,
+			Submission: models.Submission{Source: services.SubmissionSourceManual},
+		},
+	}
+
+	row := h.transformEventCandidate(candidate)
+	if row.Source != services.SubmissionSourceManual {
+		t.Errorf("Source = %q, want %q", row.Source, services.SubmissionSourceManual)
+	}
+	if row.ThumbnailURL != "" || row.OriginalImageURL != "" {
+		t.Errorf("image URLs = %q/%q, want none for a typed-in event", row.ThumbnailURL, row.OriginalImageURL)
+	}
+	if row.Title != "Bake Sale" || row.Date != "Sat 10am" || row.Venue != "Library" {
+		t.Errorf("row = %q/%q/%q, want the typed-in fields", row.Title, row.Date, row.Venue)
+	}
+	if row.Status != "Needs Review" {
+		t.Errorf("Status = %q, want Needs Review", row.Status)
+	}
+}
+
+func TestSignedCandidatePreview(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../templates/*"))
+	cfg := &config.Config{PreviewSigningKey: "preview-key", PublicBaseURL: "https://williamboard.example"}
+	candidateID := uuid.New()
+	link := func(expires time.Time) string {
+		return strings.TrimPrefix(services.CandidatePreviewURL(cfg.PublicBaseURL, cfg.PreviewSigningKey, candidateID, expires), cfg.PublicBaseURL)
+	}
+	valid := link(time.Now().Add(time.Hour))
+	blocked := "blocked"
+
+	tests := []struct {
+		name      string
+		path      string
+		published bool
+		result    *string
+		wantCode  int
+		wantBody  string
+	}{
+		{"signed link shows the edited fields", valid, false, nil, http.StatusOK, "Open Mic (edited)"},
+		{"expired link", link(time.Now().Add(-time.Minute)), false, nil, http.StatusGone, "expired"},
+		{"tampered link", strings.Replace(valid, "sig=", "sig=0", 1), false, nil, http.StatusNotFound, "Preview not found"},
+		{"link for another candidate", strings.Replace(valid, candidateID.String(), uuid.New().String(), 1), false, nil, http.StatusNotFound, "Preview not found"},
+		{"already published", valid, true, nil, http.StatusGone, "already been reviewed"},
+		{"rejected", valid, false, &blocked, http.StatusGone, "already been reviewed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			// Only a link that verifies gets as far as loading the candidate
+			if tt.wantCode == http.StatusOK || tt.published || tt.result != nil {
+				flyerID := uuid.New()
+				rows := sqlmock.NewRows([]string{"id", "flyer_id", "fields", "published_event_id", "publish_result"}).
+					AddRow(candidateID.String(), flyerID.String(), `{"title":"Open Mic (edited)","date_time":"2026-06-05 19:30"}`, nil, tt.result)
+				if tt.published {
+					rows = sqlmock.NewRows([]string{"id", "flyer_id", "published_event_id"}).AddRow(candidateID.String(), flyerID.String(), uuid.New().String())
+				}
+				mock.ExpectQuery(`SELECT * FROM "event_candidates" WHERE id = $1`).WithArgs(candidateID, testdb.Any).WillReturnRows(rows)
+				mock.ExpectQuery(`SELECT * FROM "flyers" WHERE "flyers"."id" = $1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "public_image_url", "crop_image_url"}).
+						AddRow(flyerID.String(), "https://api.example.com/files/s/redacted_1.jpg", "https://api.example.com/private/crop.jpg"))
+			}
+			if tt.wantCode == http.StatusOK {
+				mock.ExpectQuery(`FROM "submissions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(`FROM "events" WHERE canonical_key = $1`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+
+			h := &AdminHandler{config: cfg, db: db}
+			router := gin.New()
+			router.SetHTMLTemplate(tmpl)
+			router.GET("/preview/candidates/:id", h.SignedCandidatePreview)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Fatalf("status = %d; want %d with %q in:\n%s", w.Code, tt.wantCode, tt.wantBody, w.Body.String())
+			}
+			if got := w.Header().Get("X-Robots-Tag"); !strings.Contains(got, "noindex") {
+				t.Errorf("X-Robots-Tag = %q, want noindex", got)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			body := w.Body.String()
+			if w.Header().Get("Cache-Control") != "private, no-store" {
+				t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+			}
+			if !strings.Contains(body, "redacted_1.jpg") || strings.Contains(body, "crop") {
+				t.Error("organizer preview should show the redacted image and never the crop")
+			}
+			if strings.Contains(body, "/admin/moderate/") {
+				t.Error("organizer preview offers admin moderation controls")
+			}
+		})
+	}
+}
+
+// expectDashboardStats expects getAdminStats' counts and token sum, the first failing when fail is set
+func expectDashboardStats(mock sqlmock.Sqlmock, fail bool) {
+	if fail {
+		mock.ExpectQuery(`SELECT count(*) FROM "event_candidates"`).WillReturnError(errors.New("canceling statement due to statement timeout"))
+		return
+	}
+	for i := 0; i < 6; i++ {
+		mock.ExpectQuery(`SELECT count(*) FROM "event_candidates"`).WillReturnRows(testdb.Count(0))
+	}
+	mock.ExpectQuery(`FROM "submissions" WHERE created_at > $1`).
+		WillReturnRows(sqlmock.NewRows([]string{"vision_input_tokens"}).AddRow(0))
+}
+
+func TestAdminDashboardStates(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../templates/*"))
+	candidateID, flyerID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name        string
+		query       string
+		candidate   string // title of the one candidate listed, "" for none
+		failLoad    bool   // the candidate query fails
+		submissions int64  // counted only when an unfiltered dashboard has no candidates
+		statsFail   bool
+		wantCode    int
+		want        []string
+		notWant     []string
+	}{
+		{
+			name:     "fresh database shows onboarding",
+			wantCode: http.StatusOK,
+			want:     []string{"No submissions yet", "/v1/uploads/signed-url"},
+			notWant:  []string{"Stats are unavailable", "Clear filters"},
+		},
+		{
+			name:        "submissions without candidates are not a fresh install",
+			submissions: 3,
+			wantCode:    http.StatusOK,
+			notWant:     []string{"No submissions yet"},
+		},
+		{
+			name:     "empty filter offers to clear it",
+			query:    "?status=blocked",
+			wantCode: http.StatusOK,
+			want:     []string{"No Blocked candidates", "Clear filters"},
+			notWant:  []string{"No submissions yet"},
+		},
+		{
+			name:      "stats timing out still lists candidates",
+			candidate: "Quilting Circle",
+			statsFail: true,
+			wantCode:  http.StatusOK,
+			want:      []string{"Stats are unavailable", "Quilting Circle"},
+			notWant:   []string{"Something went wrong", `class="stat-number"`},
+		},
+		{
+			name:     "candidates failing renders the error page",
+			failLoad: true,
+			wantCode: http.StatusInternalServerError,
+			want:     []string{"Something went wrong", "Failed to load event candidates", "req-dashboard-1"},
+			notWant:  []string{`class="stat-number"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			if tt.failLoad {
+				mock.ExpectQuery(`SELECT * FROM "event_candidates"`).WillReturnError(errors.New("connection refused"))
+			} else {
+				rows := sqlmock.NewRows([]string{"id", "flyer_id", "fields", "publish_result"})
+				if tt.candidate != "" {
+					rows.AddRow(candidateID.String(), flyerID.String(), `{"title":"`+tt.candidate+`"}`, "needs_review")
+				}
+				mock.ExpectQuery(`SELECT * FROM "event_candidates"`).WillReturnRows(rows)
+				if tt.candidate != "" {
+					mock.ExpectQuery(`SELECT * FROM "flyers"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(flyerID.String()))
+				} else if tt.query == "" {
+					mock.ExpectQuery(`SELECT count(*) FROM "submissions"`).WillReturnRows(testdb.Count(tt.submissions))
+				}
+				expectDashboardStats(mock, tt.statsFail)
+				mock.ExpectQuery(`FROM "flags" JOIN events ON events.id = flags.event_id JOIN venue_claims`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(`FROM "flags" JOIN events ON events.id = flags.event_id WHERE flags.status`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(`FROM "event_candidates" LEFT JOIN LATERAL`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+
+			h := &AdminHandler{config: &config.Config{AdminStatsTimeoutMS: 1000, ReviewSLAHours: 24}, db: db}
+			router := gin.New()
+			router.SetHTMLTemplate(tmpl)
+			router.Use(middleware.RequestID())
+			router.GET("/admin", h.AdminDashboard)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin"+tt.query, nil)
+			req.Header.Set("X-Request-ID", "req-dashboard-1")
+			router.ServeHTTP(w, req)
+
+			body := w.Body.String()
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d:\n%s", w.Code, tt.wantCode, body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("page is missing %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("page unexpectedly shows %q", notWant)
+				}
+			}
+		})
+	}
+}
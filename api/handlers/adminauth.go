@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/middleware"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// AdminLoginPage renders the dashboard sign-in form
+// GET /admin/login?next=/admin/activity
+func (h *AdminHandler) AdminLoginPage(c *gin.Context) {
+	c.HTML(http.StatusOK, middleware.AdminLoginTemplate, gin.H{
+		"title": "Sign in",
+		"next":  loginRedirect(c.Query("next")),
+	})
+}
+
+// AdminLogin checks an admin API key and opens a dashboard session for it, kept in an
+// HttpOnly cookie that names the key but doesn't hold it (services.AdminSessionTTL). The
+// optional admin_id is remembered as the moderator name (middleware.AdminIDCookie).
+// POST /admin/login (form: key, admin_id, next)
+func (h *AdminHandler) AdminLogin(c *gin.Context) {
+	key := strings.TrimSpace(c.PostForm("key"))
+	next := loginRedirect(c.PostForm("next"))
+	token, expiresAt, err := h.keys.StartSession(key)
+	if err != nil {
+		status, message := http.StatusUnauthorized, "That key isn't valid"
+		if errors.Is(err, services.ErrInvalidAdminKey) {
+			c.Header("WWW-Authenticate", `Bearer realm="`+middleware.AdminRealm+`"`)
+		} else {
+			log.Printf("Failed to start admin session: %v", err)
+			status, message = http.StatusInternalServerError, "Couldn't sign in; please try again"
+		}
+		c.HTML(status, middleware.AdminLoginTemplate, gin.H{
+			"title": "Sign in",
+			"next":  next,
+			"error": message,
+		})
+		return
+	}
+
+	// Lax rather than Strict so links into the dashboard (e.g. from Slack) arrive signed
+	// in; cross-site POSTs still go without the cookie
+	secure := secureRequest(c)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(middleware.AdminSessionCookie, token, int(time.Until(expiresAt).Seconds()), "/admin", "", secure, true)
+	if adminID := strings.TrimSpace(c.PostForm("admin_id")); adminID != "" {
+		c.SetCookie(middleware.AdminIDCookie, adminID, 365*24*60*60, "/admin", "", secure, false)
+	}
+	c.Redirect(http.StatusSeeOther, next)
+}
+
+// AdminLogout ends the dashboard session, so its cookie stops working even where it was
+// copied, and clears it. It is reachable without a valid key, so a revoked key can still be
+// signed out.
+// POST /admin/logout
+func (h *AdminHandler) AdminLogout(c *gin.Context) {
+	if token, err := c.Cookie(middleware.AdminSessionCookie); err == nil {
+		if err := h.keys.EndSession(token); err != nil {
+			log.Printf("Failed to end admin session: %v", err)
+		}
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(middleware.AdminSessionCookie, "", -1, "/admin", "", secureRequest(c), true)
+	c.Redirect(http.StatusSeeOther, "/admin/login")
+}
+
+// SetupAdminKey generates a new admin API key and revokes the previously generated ones.
+// The key is in this response only; the server keeps just its bcrypt hash. The first key
+// is authorized by middleware.AdminBootstrapAuth instead of an admin key.
+// POST /admin/setup-key
+func (h *AdminHandler) SetupAdminKey(c *gin.Context) {
+	key, record, err := h.keys.CreateAdminAPIKey(adminActor(c))
+	if err != nil {
+		log.Printf("Failed to create admin API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin API key"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         record.ID,
+		"key":        key,
+		"created_by": record.CreatedBy,
+		"created_at": record.CreatedAt,
+		"message":    "Store this key now; it won't be shown again. Earlier generated keys are revoked.",
+	})
+}
+
+// loginRedirect limits where sign-in returns to: a dashboard path on this host
+func loginRedirect(next string) string {
+	if !strings.HasPrefix(next, "/admin") || strings.HasPrefix(next, "//") || strings.Contains(next, "\\") {
+		return "/admin"
+	}
+	return next
+}
+
+// secureRequest reports whether the client reached us over HTTPS, directly or through a proxy
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
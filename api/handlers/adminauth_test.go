@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/middleware"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// TestAdminLoginSession checks signing in sets a session cookie that doesn't hold the key,
+// and that signing out revokes the session rather than only clearing the cookie
+func TestAdminLoginSession(t *testing.T) {
+	db, mock := testdb.New(t)
+	keys, err := services.NewAdminKeyring(db, &config.Config{AdminToken: "legacy-token", AdminSessionSecret: "session-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &AdminHandler{db: db, keys: keys}
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New(middleware.AdminLoginTemplate).Parse(`{{.error}}`)))
+	router.POST("/admin/login", h.AdminLogin)
+	router.POST("/admin/logout", h.AdminLogout)
+	router.GET("/admin/stats", middleware.AdminAuth(keys), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	login := func(key string) *httptest.ResponseRecorder {
+		form := url.Values{"key": {key}, "next": {"/admin/activity"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	withCookie := func(method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A wrong key is refused with no cookie, and the generated keys are checked first
+	mock.ExpectQuery(`FROM "admin_api_keys"`).WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash"}))
+	if w := login("wrong"); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("login with a wrong key = %d with cookies %v, want 401 and none", w.Code, w.Result().Cookies())
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "admin_sessions"`).WithArgs("admin_token", testdb.Any, nil, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New().String(), time.Now()))
+	mock.ExpectCommit()
+	w := login("legacy-token")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/activity" {
+		t.Fatalf("login = %d to %q, want 303 to /admin/activity", w.Code, w.Header().Get("Location"))
+	}
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == middleware.AdminSessionCookie {
+			session = cookie
+		}
+	}
+	if session == nil || !session.HttpOnly || strings.Contains(session.Value, "legacy-token") {
+		t.Fatalf("session cookie = %+v, want an HttpOnly cookie without the key", session)
+	}
+	if session.MaxAge <= 0 || session.MaxAge > int(services.AdminSessionTTL.Seconds()) {
+		t.Errorf("session cookie lasts %ds, want at most %s", session.MaxAge, services.AdminSessionTTL)
+	}
+	if w := withCookie(http.MethodGet, "/admin/stats", session); w.Code != http.StatusOK {
+		t.Fatalf("dashboard with the session cookie = %d, want 200", w.Code)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "admin_sessions" SET "revoked_at"=$1 WHERE id = $2 AND revoked_at IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	w = withCookie(http.MethodPost, "/admin/logout", session)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("logout = %d, want 303", w.Code)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != middleware.AdminSessionCookie || cookies[0].MaxAge >= 0 {
+		t.Errorf("logout cookies = %+v, want the session cookie cleared", cookies)
+	}
+
+	// A copy of the cookie kept past sign-out no longer works
+	mock.ExpectQuery(`FROM "admin_sessions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if w := withCookie(http.MethodGet, "/admin/stats", session); w.Code != http.StatusUnauthorized {
+		t.Errorf("dashboard with a signed-out cookie = %d, want 401", w.Code)
+	}
+}
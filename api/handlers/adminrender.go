@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/adminops"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// TemplateFuncs are the helpers the admin templates call, registered on the router's templates
+var TemplateFuncs = template.FuncMap{
+	"mul": func(a, b float64) float64 {
+		return a * b
+	},
+	"ge": func(a, b float64) bool {
+		return a >= b
+	},
+	"gt": func(a, b float64) bool {
+		return a > b
+	},
+	"printf": fmt.Sprintf,
+	"unpublishReasons": func() []string {
+		return services.ValidUnpublishReasons
+	},
+}
+
+// adminRenderer writes the outcome of an adminops operation in one transport. Every admin
+// action goes through one of the two below, so a dashboard form and an API call get the
+// same status codes and messages.
+type adminRenderer interface {
+	// Success writes the operation's result; candidateID, when set, is the candidate whose
+	// dashboard row changed
+	Success(c *gin.Context, payload interface{}, candidateID *uuid.UUID)
+	Error(c *gin.Context, err error)
+}
+
+// renderer picks the JSON renderer for API callers and the HTML one for the dashboard
+func (h *AdminHandler) renderer(c *gin.Context) adminRenderer {
+	if wantsAdminJSON(c) {
+		return jsonAdminRenderer{}
+	}
+	return htmlAdminRenderer{h: h}
+}
+
+// wantsAdminJSON reports whether a request came through /admin/api, sent a JSON body, or
+// asked for JSON, rather than coming from a dashboard form or htmx
+func wantsAdminJSON(c *gin.Context) bool {
+	return strings.HasPrefix(c.FullPath(), "/admin/api/") ||
+		strings.Contains(c.GetHeader("Accept"), "application/json") ||
+		c.ContentType() == "application/json"
+}
+
+// adminErrorStatus maps an operation error to its HTTP status, logging unexpected failures
+func adminErrorStatus(c *gin.Context, err error) int {
+	switch adminops.KindOf(err) {
+	case adminops.KindInvalid:
+		return http.StatusBadRequest
+	case adminops.KindNotFound:
+		return http.StatusNotFound
+	case adminops.KindConflict:
+		return http.StatusConflict
+	default:
+		log.Printf("Admin operation %s failed: %v", c.FullPath(), err)
+		return http.StatusInternalServerError
+	}
+}
+
+// jsonAdminRenderer answers the admin API and scripted callers
+type jsonAdminRenderer struct{}
+
+func (jsonAdminRenderer) Success(c *gin.Context, payload interface{}, candidateID *uuid.UUID) {
+	c.JSON(http.StatusOK, payload)
+}
+
+func (jsonAdminRenderer) Error(c *gin.Context, err error) {
+	status := adminErrorStatus(c, err)
+	c.JSON(status, gin.H{"error": adminops.MessageOf(err, "Admin operation failed")})
+}
+
+// htmlAdminRenderer answers the dashboard: htmx requests get the candidate's refreshed row
+// (or a page refresh when no single row changed), plain form posts go back to /admin
+type htmlAdminRenderer struct {
+	h *AdminHandler
+}
+
+func (r htmlAdminRenderer) Success(c *gin.Context, payload interface{}, candidateID *uuid.UUID) {
+	if c.GetHeader("HX-Request") != "true" {
+		c.Redirect(http.StatusSeeOther, "/admin")
+		return
+	}
+	if candidateID == nil {
+		c.Header("HX-Refresh", "true")
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	var candidate models.EventCandidate
+	if err := r.h.db.Preload("Flyer.Submission").Preload("PublishedEvent").Where("id = ?", *candidateID).First(&candidate).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Failed to reload candidate")
+		return
+	}
+	c.HTML(http.StatusOK, "candidate_row", r.h.transformEventCandidate(&candidate))
+}
+
+func (htmlAdminRenderer) Error(c *gin.Context, err error) {
+	status := adminErrorStatus(c, err)
+	c.String(status, adminops.MessageOf(err, "Admin operation failed"))
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// expectCandidateAudit expects one audit log entry for action
+func expectCandidateAudit(mock sqlmock.Sqlmock, action services.AuditAction) {
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(append([]driver.Value{services.AuditEntityCandidate, testdb.Any, string(action)}, testdb.AnyArgs(9)...)...).
+		WillReturnRows(testdb.IDs(uuid.New()))
+}
+
+// TestAdminOperationsMatchAcrossTransports runs each admin operation once as a dashboard form
+// post and once through /admin/api, expecting the same database writes from both and the
+// same status and message when the operation fails
+func TestAdminOperationsMatchAcrossTransports(t *testing.T) {
+	candidateID, flyerID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		formPath   string
+		apiPath    string
+		fields     map[string]string
+		expect     func(mock sqlmock.Sqlmock)
+		wantStatus int    // from the JSON API; forms that succeed redirect instead
+		wantResult string // the candidate's publish_result after a success
+		wantError  string
+	}{
+		{
+			name:     "reject",
+			formPath: "/admin/moderate/",
+			apiPath:  "/admin/api/candidates/%s/moderate",
+			fields:   map[string]string{"action": "reject", "reason": "duplicate flyer"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT * FROM "event_candidates" WHERE id = $1`).
+					WithArgs(candidateID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "flyer_id", "publish_result"}).
+						AddRow(candidateID.String(), flyerID.String(), "needs_review"))
+				mock.ExpectQuery(`SELECT * FROM "flyers" WHERE "flyers"."id" = $1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(flyerID.String()))
+				mock.ExpectExec(`UPDATE "event_candidates" SET "publication_reason"=$1,"publish_result"=$2 WHERE "id" = $3`).
+					WithArgs("duplicate flyer", "blocked", candidateID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectCandidateAudit(mock, services.AuditActionCandidateBlocked)
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusOK,
+			wantResult: "blocked",
+		},
+		{
+			name:     "restore for review",
+			formPath: "/admin/candidates/%s/restore",
+			apiPath:  "/admin/api/candidates/%s/restore",
+			fields:   map[string]string{"reason": "not spam"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT * FROM "event_candidates" WHERE id = $1 ORDER BY "event_candidates"."id" LIMIT $2 FOR UPDATE`).
+					WithArgs(candidateID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "fields", "publish_result"}).
+						AddRow(candidateID.String(), `{"title":"Open Mic"}`, "blocked"))
+				mock.ExpectExec(`UPDATE "event_candidates" SET "publication_reason"=$1,"publish_result"=$2,"review_reason"=$3 WHERE "id" = $4`).
+					WithArgs("restored: not spam", "needs_review", services.ReviewReasonRestored, candidateID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectCandidateAudit(mock, services.AuditActionCandidateRestored)
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusOK,
+			wantResult: "needs_review",
+		},
+		{
+			name:     "unknown action",
+			formPath: "/admin/moderate/",
+			apiPath:  "/admin/api/candidates/%s/moderate",
+			fields:   map[string]string{"action": "publish"},
+			expect:   func(sqlmock.Sqlmock) {},
+
+			wantStatus: http.StatusBadRequest,
+			wantError:  "Invalid action",
+		},
+		{
+			name:     "restore without a reason",
+			formPath: "/admin/candidates/%s/restore",
+			apiPath:  "/admin/api/candidates/%s/restore",
+			fields:   map[string]string{"action": "approve"},
+			expect:   func(sqlmock.Sqlmock) {},
+
+			wantStatus: http.StatusBadRequest,
+			wantError:  "A reason is required",
+		},
+		{
+			name:     "candidate gone",
+			formPath: "/admin/moderate/",
+			apiPath:  "/admin/api/candidates/%s/moderate",
+			fields:   map[string]string{"action": "approve"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT * FROM "event_candidates" WHERE id = $1`).
+					WithArgs(candidateID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusNotFound,
+			wantError:  "Event not found",
+		},
+		{
+			name:     "conflicting state",
+			formPath: "/admin/candidates/%s/restore",
+			apiPath:  "/admin/api/candidates/%s/restore",
+			fields:   map[string]string{"reason": "not spam"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT * FROM "event_candidates" WHERE id = $1`).
+					WithArgs(candidateID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "publish_result"}).AddRow(candidateID.String(), "published"))
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusConflict,
+			wantError:  "Candidate is not blocked",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, transport := range []string{"form", "api"} {
+			t.Run(tt.name+" via "+transport, func(t *testing.T) {
+				db, mock := testdb.New(t)
+				tt.expect(mock)
+				h := NewAdminHandler(&config.Config{}, db, nil, nil, nil, nil)
+				router := gin.New()
+				router.POST("/admin/moderate/:id", h.ModerateEvent)
+				router.POST("/admin/candidates/:id/restore", h.RestoreCandidate)
+				router.POST("/admin/api/candidates/:id/moderate", h.ModerateEvent)
+				router.POST("/admin/api/candidates/:id/restore", h.RestoreCandidate)
+
+				var req *http.Request
+				if transport == "form" {
+					path := strings.Replace(tt.formPath, "%s", candidateID.String(), 1)
+					if !strings.Contains(tt.formPath, "%s") {
+						path += candidateID.String()
+					}
+					form := url.Values{}
+					for key, value := range tt.fields {
+						form.Set(key, value)
+					}
+					req = httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+					req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				} else {
+					body, err := json.Marshal(tt.fields)
+					if err != nil {
+						t.Fatal(err)
+					}
+					req = httptest.NewRequest(http.MethodPost, strings.Replace(tt.apiPath, "%s", candidateID.String(), 1), strings.NewReader(string(body)))
+					req.Header.Set("Content-Type", "application/json")
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				switch {
+				case transport == "form" && tt.wantError == "":
+					if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin" {
+						t.Errorf("form post = %d to %q, want a redirect to /admin", w.Code, w.Header().Get("Location"))
+					}
+				case transport == "form":
+					if w.Code != tt.wantStatus || w.Body.String() != tt.wantError {
+						t.Errorf("form post = %d %q, want %d %q", w.Code, w.Body.String(), tt.wantStatus, tt.wantError)
+					}
+				default:
+					var body struct {
+						Status string `json:"status"`
+						Error  string `json:"error"`
+					}
+					if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+						t.Fatalf("API response is not JSON: %s", w.Body)
+					}
+					if w.Code != tt.wantStatus || body.Status != tt.wantResult || body.Error != tt.wantError {
+						t.Errorf("API call = %d %s, want %d with status %q and error %q", w.Code, w.Body, tt.wantStatus, tt.wantResult, tt.wantError)
+					}
+				}
+			})
+		}
+	}
+}
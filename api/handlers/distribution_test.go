@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// recordEventQueries collects the SQL of every query GORM runs against the events table
+func recordEventQueries(t *testing.T, db *gorm.DB) func() []string {
+	t.Helper()
+
+	var mu sync.Mutex
+	var statements []string
+	err := db.Callback().Query().After("gorm:query").Register("test:record_event_queries", func(tx *gorm.DB) {
+		if tx.Statement.Table != "events" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, tx.Statement.SQL.String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), statements...)
+	}
+}
+
+func TestQuietEventsStayOffDistributionSurfaces(t *testing.T) {
+	venueID := uuid.New()
+	noEvents := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"id"}) }
+
+	tests := []struct {
+		name      string
+		path      string
+		expect    func(mock sqlmock.Sqlmock)
+		wantQuiet bool // whether quiet events are filtered out
+	}{
+		{
+			name: "map and list",
+			path: "/v1/events",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "events"`).WillReturnRows(noEvents())
+			},
+			wantQuiet: false,
+		},
+		{
+			name: "bulk calendar feed",
+			path: "/v1/events/calendar.ics",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "events"`).WillReturnRows(noEvents())
+				mock.ExpectQuery(`FROM "event_tombstones"`).WillReturnRows(noEvents())
+			},
+			wantQuiet: true,
+		},
+		{
+			name: "filtered calendar export",
+			path: "/v1/events/calendar.ics?keyword=jazz",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "events"`).WillReturnRows(noEvents())
+			},
+			wantQuiet: true,
+		},
+		{
+			name: "venue calendar",
+			path: "/v1/venues/" + venueID.String() + "/calendar.ics",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "venues"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(venueID.String()))
+				mock.ExpectQuery(`FROM "events"`).WillReturnRows(noEvents())
+			},
+			wantQuiet: true,
+		},
+		{
+			name: "rss",
+			path: "/v1/events/feed.rss",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "events"`).WillReturnRows(noEvents())
+				mock.ExpectQuery(`FROM "event_changes"`).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+			},
+			wantQuiet: true,
+		},
+		{
+			name: "atom",
+			path: "/v1/events/feed.atom",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "events"`).WillReturnRows(noEvents())
+				mock.ExpectQuery(`FROM "event_changes"`).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+			},
+			wantQuiet: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			statements := recordEventQueries(t, db)
+			tt.expect(mock)
+
+			h := &EventHandler{config: &config.Config{AppName: "WilliamBoard", ICSFeedDays: 60}, db: db}
+			router := gin.New()
+			router.GET("/v1/events", h.List)
+			router.GET("/v1/events/calendar.ics", h.CalendarFeed)
+			router.GET("/v1/events/feed.rss", h.RSSFeed)
+			router.GET("/v1/events/feed.atom", h.AtomFeed)
+			router.GET("/v1/venues/:id/calendar.ics", h.VenueCalendar)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d: %s", tt.path, w.Code, w.Body.String())
+			}
+
+			queries := statements()
+			if len(queries) != 1 {
+				t.Fatalf("ran %d events queries, want 1: %q", len(queries), queries)
+			}
+			if got := strings.Contains(queries[0], "events.quiet = $"); got != tt.wantQuiet {
+				t.Errorf("quiet filter applied = %v, want %v in %s", got, tt.wantQuiet, queries[0])
+			}
+		})
+	}
+}
+
+// TestOnlyTheMapLeavesOutUnlocatedEvents checks that events whose venue has no location are
+// left out of the map listing unless include_unlocated asks for them, while the feeds and the
+// filtered calendar export, which place nothing on a map, carry them as the bulk calendar does
+func TestOnlyTheMapLeavesOutUnlocatedEvents(t *testing.T) {
+	const located = `events.venue_id IN (SELECT "id" FROM "venues" WHERE location IS NOT NULL)`
+
+	tests := []struct {
+		name        string
+		path        string
+		wantLocated bool // whether unlocated events are filtered out
+		wantTitle   bool // whether the unlocated event is in the body
+	}{
+		{name: "map listing", path: "/v1/events", wantLocated: true},
+		{name: "map listing with include_unlocated", path: "/v1/events?include_unlocated=true", wantTitle: true},
+		{name: "rss", path: "/v1/events/feed.rss", wantTitle: true},
+		{name: "filtered atom", path: "/v1/events/feed.atom?keyword=porch", wantTitle: true},
+		{name: "filtered calendar export", path: "/v1/events/calendar.ics?keyword=porch", wantTitle: true},
+		{name: "calendar export with past events", path: "/v1/events/calendar.ics?include_past=true", wantTitle: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			statements := recordEventQueries(t, db)
+			venueID := uuid.New()
+			rows := sqlmock.NewRows([]string{"id", "title", "start_ts", "venue_id", "moderation_state"})
+			if !tt.wantLocated {
+				rows.AddRow(uuid.New().String(), "Porch Concert", time.Now().Add(24*time.Hour), venueID.String(), "approved")
+			}
+			mock.ExpectQuery(`FROM "events"`).WillReturnRows(rows)
+			if !tt.wantLocated {
+				mock.ExpectQuery(`SELECT * FROM "venues" WHERE "venues"."id" = $1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location"}).AddRow(venueID.String(), "A Front Porch", nil))
+			}
+			if strings.Contains(tt.path, "/feed.") {
+				mock.ExpectQuery(`FROM "event_changes"`).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+			}
+
+			h := &EventHandler{config: &config.Config{AppName: "WilliamBoard"}, db: db}
+			router := gin.New()
+			router.GET("/v1/events", h.List)
+			router.GET("/v1/events/feed.rss", h.RSSFeed)
+			router.GET("/v1/events/feed.atom", h.AtomFeed)
+			router.GET("/v1/events/calendar.ics", h.CalendarFeed)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d: %s", tt.path, w.Code, w.Body.String())
+			}
+
+			queries := statements()
+			if len(queries) == 0 {
+				t.Fatal("ran no events query")
+			}
+			if got := strings.Contains(queries[0], located); got != tt.wantLocated {
+				t.Errorf("location filter applied = %v, want %v in %s", got, tt.wantLocated, queries[0])
+			}
+			if got := strings.Contains(w.Body.String(), "Porch Concert"); got != tt.wantTitle {
+				t.Errorf("unlocated event listed = %v, want %v: %s", got, tt.wantTitle, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestQuietFlagIsAdminOnly(t *testing.T) {
+	event := models.Event{ID: uuid.New(), Title: "Support group", StartTs: time.Date(2026, 6, 1, 18, 0, 0, 0, time.UTC), Quiet: true}
+
+	public, err := json.Marshal(newEventFeature(&event, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range [][]byte{public, raw} {
+		if strings.Contains(string(body), "quiet") {
+			t.Errorf("public JSON exposes the quiet flag: %s", body)
+		}
+	}
+
+	// The admin export adds it back
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`FROM "events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "start_ts", "quiet"}).
+			AddRow(event.ID.String(), event.Title, event.StartTs, true))
+	h := &AdminHandler{db: db}
+	router := gin.New()
+	router.GET("/admin/api/export/events", h.ExportEvents)
+
+	for _, format := range []string{"json", "csv"} {
+		if format == "csv" {
+			mock.ExpectQuery(`FROM "events"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "start_ts", "quiet"}).
+					AddRow(event.ID.String(), event.Title, event.StartTs, true))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/api/export/events?format="+format, nil))
+		body := w.Body.String()
+		want := `"quiet":true`
+		if format == "csv" {
+			want = ",true\n"
+		}
+		if w.Code != http.StatusOK || !strings.Contains(body, want) {
+			t.Errorf("%s export = %d %s, want the quiet flag (%q)", format, w.Code, body, want)
+		}
+	}
+}
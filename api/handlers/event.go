@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"html"
+	"log"
+	"math"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -10,24 +15,29 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/middleware"
 	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/services"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EventHandler struct {
-	config *config.Config
-	db     *gorm.DB
+	config  *config.Config
+	db      *gorm.DB
+	storage *services.StorageService
 }
 
 type EventGeoJSON struct {
-	Type     string                 `json:"type"`
-	Features []EventFeature         `json:"features"`
+	Type       string                 `json:"type"`
+	Features   []EventFeature         `json:"features"`
+	NextCursor string                 `json:"next_cursor,omitempty"` // pass as ?cursor= for the next page
 }
 
 type EventFeature struct {
 	Type       string                 `json:"type"`
 	ID         string                 `json:"id"`
-	Geometry   EventGeometry          `json:"geometry"`
+	Geometry   *EventGeometry         `json:"geometry"` // null for events without a geocoded venue
 	Properties EventProperties        `json:"properties"`
 }
 
@@ -44,317 +54,1526 @@ type EventProperties struct {
 	Address     *string    `json:"address,omitempty"`
 	URL         *string    `json:"url,omitempty"`
 	Price       *string    `json:"price,omitempty"`
+	PriceTiers  models.PriceTiers `json:"price_tiers,omitempty"`
+	PriceMin    *int       `json:"price_min_cents,omitempty"`
+	PriceMax    *int       `json:"price_max_cents,omitempty"`
 	Description *string    `json:"description,omitempty"`
 	Organizer   *string    `json:"organizer,omitempty"`
+	Attributes  models.Attributes `json:"attributes,omitempty"` // public extra fields, when the deployment defines any
+	ImageURL    *string    `json:"image_url,omitempty"` // stable /v1/events/{id}/image URL, when the event has a flyer image
+	ThumbnailURL *string   `json:"thumbnail_url,omitempty"`
 	Source      string     `json:"source"`
+	HappeningNow bool      `json:"happening_now"` // started and not yet ended (end_ts, or start_ts plus the default duration)
+	DistanceKm  *float64   `json:"distance_km,omitempty"` // from the searched point (GET /v1/events/near only)
+	Headline    *string    `json:"headline,omitempty"`    // HTML-escaped ts_headline excerpt with matches in <b>, when keyword or q is given
+
+	// start_local, end_local, tz, tz_offset
+	services.LocalTimes
 }
 
 type UnpublishRequest struct {
 	Reason string `json:"reason" binding:"required"` // spam, duplicate, bad_location
 }
 
-func NewEventHandler(cfg *config.Config, db *gorm.DB) *EventHandler {
+// DeleteEventRequest is the body of an admin event deletion
+type DeleteEventRequest struct {
+	Reason string `json:"reason" binding:"required"` // legal_takedown, privacy, copyright, other
+	Note   string `json:"note"`
+}
+
+// FlagRequest is a public report against a published event
+type FlagRequest struct {
+	FlagType string `json:"flag_type" binding:"required"` // spam, inappropriate, duplicate, wrong_location
+	Reason   string `json:"reason" binding:"max=1000"`
+}
+
+// DisputeRequest explains why a venue manager says an event doesn't belong to their venue
+type DisputeRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+func NewEventHandler(cfg *config.Config, db *gorm.DB, storage *services.StorageService) *EventHandler {
 	return &EventHandler{
-		config: cfg,
-		db:     db,
+		config:  cfg,
+		db:      db,
+		storage: storage,
 	}
 }
 
-// List returns events in GeoJSON format with optional filtering
-// GET /v1/events?bbox=w,s,e,n&start_date=2024-01-01&end_date=2024-12-31&keyword=music&include_past=true
-func (h *EventHandler) List(c *gin.Context) {
-	query := h.db.Model(&models.Event{}).
-		Preload("Venue").
-		Where("moderation_state = ?", "approved")
-
-	// By default, only show future events unless include_past=true
-	if c.Query("include_past") != "true" {
-		query = query.Where("start_ts > ?", time.Now())
+// resolveTimezone reads the optional ?tz= parameter, falling back to the given zone.
+// Writes a 400 response and returns false for an invalid zone.
+func (h *EventHandler) resolveTimezone(c *gin.Context, fallback *time.Location) (*time.Location, bool) {
+	loc, err := services.LoadTimezone(c.Query("tz"), fallback)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "invalid_timezone",
+				"message": "Invalid tz parameter",
+				"details": err.Error(),
+			},
+		})
+		return nil, false
 	}
+	return loc, true
+}
 
-	// Apply filters
-	if bbox := c.Query("bbox"); bbox != "" {
-		coords := strings.Split(bbox, ",")
-		if len(coords) == 4 {
-			// TODO: Add spatial filtering with PostGIS
-			// For now, skip bbox filtering
+// requireAPIKey rejects an anonymous request that exceeds what unauthenticated callers may query
+func (h *EventHandler) requireAPIKey(c *gin.Context, details string) {
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": gin.H{
+			"code":    "api_key_required",
+			"message": "API key required",
+			"details": details,
+		},
+	})
+}
+
+// eventWindow is the time range an event listing covers; nil bounds are open. An event is
+// in the window when its [start, end) overlaps it (see services.WhereEventOverlaps).
+type eventWindow struct {
+	after *time.Time // the default upcoming-only cutoff: events still running then
+	from  *time.Time // start_date, or the anonymous history limit
+	until *time.Time // exclusive: the day after end_date
+}
+
+// parseEventWindow resolves start_date, end_date and include_past relative to now.
+// Dates are read in loc and end_date is inclusive. An explicit start_date replaces the
+// upcoming-only default, so include_past only matters when no start_date is given.
+// Writes an error response and returns false on invalid input.
+func (h *EventHandler) parseEventWindow(c *gin.Context, loc *time.Location, now time.Time, limitHistory bool) (eventWindow, bool) {
+	var window eventWindow
+	for _, param := range []string{"start_date", "end_date"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.ParseInLocation("2006-01-02", raw, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "invalid_date",
+					"message": fmt.Sprintf("%s must be a date (2006-01-02)", param),
+				},
+			})
+			return window, false
 		}
+		if param == "start_date" {
+			window.from = &parsed
+		} else {
+			until := parsed.AddDate(0, 0, 1)
+			window.until = &until
+		}
+	}
+	if window.from != nil && window.until != nil && !window.from.Before(*window.until) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "invalid_date",
+				"message": "start_date must not be after end_date",
+			},
+		})
+		return window, false
 	}
 
-	if startDate := c.Query("start_date"); startDate != "" {
-		if start, err := time.Parse("2006-01-02", startDate); err == nil {
-			query = query.Where("start_ts >= ?", start)
-		}
+	if window.from == nil && c.Query("include_past") != "true" {
+		window.after = &now
 	}
 
-	if endDate := c.Query("end_date"); endDate != "" {
-		if end, err := time.Parse("2006-01-02", endDate); err == nil {
-			query = query.Where("start_ts <= ?", end)
+	if limitHistory && !middleware.IsPartner(c) && h.config.AnonymousMaxPastDays > 0 {
+		// Anonymous history is limited to a recent window; explicit deeper ranges need a key
+		earliest := now.AddDate(0, 0, -h.config.AnonymousMaxPastDays)
+		if window.from != nil && window.from.Before(earliest) {
+			h.requireAPIKey(c, fmt.Sprintf("start_date more than %d days back requires an API key", h.config.AnonymousMaxPastDays))
+			return window, false
+		}
+		if window.from == nil && window.after == nil {
+			window.from = &earliest
 		}
 	}
+	return window, true
+}
 
-	if keyword := c.Query("keyword"); keyword != "" {
-		searchTerm := "%" + keyword + "%"
-		query = query.Where("title ILIKE ? OR description ILIKE ?", searchTerm, searchTerm)
+// apply restricts an events query to the window
+func (w eventWindow) apply(query *gorm.DB) *gorm.DB {
+	if w.after != nil {
+		query = services.WhereEventOverlaps(query, w.after, nil)
 	}
+	return services.WhereEventOverlaps(query, w.from, w.until)
+}
 
-	// Pagination
-	limit := 100
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
-			limit = parsedLimit
-		}
+// contains reports whether an event overlaps the window
+func (w eventWindow) contains(start time.Time, end *time.Time) bool {
+	if w.after != nil && !services.EventOverlaps(start, end, w.after, nil) {
+		return false
 	}
+	return services.EventOverlaps(start, end, w.from, w.until)
+}
 
-	offset := 0
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+// boundingBox is a map viewport in WGS 84 degrees
+type boundingBox struct {
+	west, south, east, north float64
+}
+
+// parseBBox reads bbox=w,s,e,n, returning nil when it is absent. Writes a 400 and returns
+// false unless it has four numbers within longitude/latitude range, west <= east, and
+// south <= north. Boxes crossing the antimeridian aren't supported.
+func (h *EventHandler) parseBBox(c *gin.Context) (*boundingBox, bool) {
+	raw := c.Query("bbox")
+	if raw == "" {
+		return nil, true
 	}
 
-	var events []models.Event
-	if err := query.Limit(limit).Offset(offset).Order("start_ts ASC").Find(&events).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	invalid := func(message string) (*boundingBox, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"message": "Failed to fetch events",
+				"code":    "invalid_bbox",
+				"message": message,
 			},
 		})
-		return
+		return nil, false
 	}
 
-	// Convert to GeoJSON
-	geoJSON := EventGeoJSON{
-		Type:     "FeatureCollection",
-		Features: make([]EventFeature, 0, len(events)),
+	coords, ok := parseCoordinates(raw, 4)
+	if !ok {
+		return invalid("bbox must be four comma-separated numbers: west,south,east,north")
 	}
 
-	for _, event := range events {
-		feature := EventFeature{
-			Type: "Feature",
-			ID:   event.ID.String(),
-			Properties: EventProperties{
-				Title:       event.Title,
-				StartTs:     event.StartTs,
-				EndTs:       event.EndTs,
-				URL:         event.URL,
-				Price:       event.Price,
-				Description: event.Description,
-				Organizer:   event.Organizer,
-				Source:      event.Source,
-			},
-		}
-
-		if event.Venue != nil {
-			feature.Properties.VenueName = &event.Venue.Name
-			feature.Properties.Address = event.Venue.AddressLine
-
-			// TODO: Parse PostGIS location to get coordinates
-			// For now, use dummy coordinates
-			feature.Geometry = EventGeometry{
-				Type:        "Point",
-				Coordinates: []float64{-122.4194, 37.7749}, // SF default
-			}
-		}
-
-		geoJSON.Features = append(geoJSON.Features, feature)
+	box := &boundingBox{west: coords[0], south: coords[1], east: coords[2], north: coords[3]}
+	switch {
+	case box.west < -180 || box.east > 180 || box.south < -90 || box.north > 90:
+		return invalid("bbox longitudes must be within -180..180 and latitudes within -90..90")
+	case box.west > box.east:
+		return invalid("bbox west must not be greater than east")
+	case box.south > box.north:
+		return invalid("bbox south must not be greater than north")
 	}
+	return box, true
+}
 
-	c.JSON(http.StatusOK, geoJSON)
+// maxRadiusMeters bounds a radius search; wider areas should use bbox
+const maxRadiusMeters = 500000
+
+// searchCircle is a radius search around a WGS 84 point
+type searchCircle struct {
+	lat, lng, meters float64
 }
 
-// Get returns a single event by ID
-// GET /v1/events/{id}
-func (h *EventHandler) Get(c *gin.Context) {
-	eventID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
+// parseRadius reads radius=lat,lng,meters, returning nil when it is absent. Writes a 400
+// and returns false unless the centre is in range and 0 < meters <= maxRadiusMeters.
+func (h *EventHandler) parseRadius(c *gin.Context) (*searchCircle, bool) {
+	raw := c.Query("radius")
+	if raw == "" {
+		return nil, true
+	}
+
+	invalid := func(message string) (*searchCircle, bool) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"message": "Invalid event ID",
+				"code":    "invalid_radius",
+				"message": message,
 			},
 		})
-		return
+		return nil, false
 	}
 
-	var event models.Event
-	if err := h.db.Preload("Venue").First(&event, "id = ?", eventID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
+	values, ok := parseCoordinates(raw, 3)
+	if !ok {
+		return invalid("radius must be three comma-separated numbers: lat,lng,meters")
+	}
+	circle := &searchCircle{lat: values[0], lng: values[1], meters: values[2]}
+	switch {
+	case circle.lat < -90 || circle.lat > 90 || circle.lng < -180 || circle.lng > 180:
+		return invalid("radius latitude must be within -90..90 and longitude within -180..180")
+	case circle.meters <= 0 || circle.meters > maxRadiusMeters:
+		return invalid(fmt.Sprintf("radius meters must be greater than 0 and at most %d", maxRadiusMeters))
+	}
+	return circle, true
+}
+
+// parseCoordinates splits raw into exactly n comma-separated finite numbers
+func parseCoordinates(raw string, n int) ([]float64, bool) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != n {
+		return nil, false
+	}
+	values := make([]float64, n)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// eventPage is the requested page of a listing: a cursor, or an offset for older clients
+type eventPage struct {
+	limit  int
+	offset int
+	cursor *services.EventCursor
+}
+
+// parseEventPage reads limit, offset and cursor. Writes an error response and returns
+// false when both offset and cursor are given or the cursor is malformed.
+func (h *EventHandler) parseEventPage(c *gin.Context) (eventPage, bool) {
+	page := eventPage{limit: 100}
+	if parsedLimit, err := strconv.Atoi(c.Query("limit")); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
+		page.limit = parsedLimit
+	}
+	if parsedOffset, err := strconv.Atoi(c.Query("offset")); err == nil && parsedOffset >= 0 {
+		page.offset = parsedOffset
+	}
+	if c.Query("offset") != "" {
+		// Offsets skip or repeat events as the listing changes; they go away after this release
+		c.Header("Deprecation", "true")
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		if c.Query("offset") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"error": gin.H{
-					"message": "Event not found",
+					"code":    "invalid_cursor",
+					"message": "cursor and offset cannot be combined",
 				},
 			})
-			return
+			return page, false
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": "Database error",
-			},
-		})
-		return
+		cursor, err := services.DecodeEventCursor(token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "invalid_cursor",
+					"message": "Invalid cursor",
+				},
+			})
+			return page, false
+		}
+		page.cursor = &cursor
 	}
+	return page, true
+}
 
-	c.JSON(http.StatusOK, event)
+// nextCursor returns the cursor after a full page, or "" when the listing is exhausted
+func (p eventPage) nextCursor(events []models.Event) string {
+	if len(events) < p.limit || len(events) == 0 {
+		return ""
+	}
+	return services.EventCursorAfter(&events[len(events)-1]).Encode()
 }
 
-// GetICS returns an event in ICS calendar format
-// GET /v1/events/{id}/ics
-func (h *EventHandler) GetICS(c *gin.Context) {
-	eventID, err := uuid.Parse(c.Param("id"))
+// List returns events in GeoJSON format with optional filtering, in (start_ts, id) order, or
+// best full-text match first with sort=relevance
+// GET /v1/events?bbox=w,s,e,n&radius=lat,lng,meters&start_date=2024-01-01&end_date=2024-12-31&keyword=music&sort=relevance&include_past=true&include_unlocated=true&tz=America/New_York&as_of=2025-05-01&cursor=...
+func (h *EventHandler) List(c *gin.Context) {
+	// Events carry no zone of their own; they are local to the region
+	regionLoc, err := h.config.GetLocation()
 	if err != nil {
+		regionLoc = time.UTC
+	}
+	loc, ok := h.resolveTimezone(c, regionLoc)
+	if !ok {
+		return
+	}
+
+	if asOf := c.Query("as_of"); asOf != "" {
+		h.listAsOf(c, asOf, loc)
+		return
+	}
+
+	query, ok := h.filteredEvents(c, loc)
+	if !ok {
+		return
+	}
+	query = h.mappableEvents(c, query)
+	byRelevance, ok := h.parseEventSort(c)
+	if !ok {
+		return
+	}
+	page, ok := h.parseEventPage(c)
+	if !ok {
+		return
+	}
+	if h.config.AnonymousMaxOffset > 0 && page.offset > h.config.AnonymousMaxOffset && !middleware.IsPartner(c) {
+		h.requireAPIKey(c, fmt.Sprintf("offsets beyond %d require an API key", h.config.AnonymousMaxOffset))
+		return
+	}
+	if byRelevance && page.cursor != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"message": "Invalid event ID",
+				"code":    "invalid_cursor",
+				"message": "cursor paging is not supported with sort=relevance; use offset",
 			},
 		})
 		return
 	}
 
-	var event models.Event
-	if err := h.db.Preload("Venue").First(&event, "id = ?", eventID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": gin.H{
-					"message": "Event not found",
-				},
-			})
-			return
-		}
+	if page.cursor != nil {
+		query = page.cursor.Where(query)
+	} else {
+		query = query.Offset(page.offset)
+	}
+	if byRelevance {
+		// Order drops a bare gorm.Expr, so the ranking goes in as an ORDER BY expression
+		query = query.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "ts_rank(events.search_vector, plainto_tsquery('english', ?)) DESC, events.start_ts, events.id",
+			Vars:               []interface{}{eventSearchTerm(c)},
+			WithoutParentheses: true,
+		}})
+	} else {
+		query = services.OrderEventsChronologically(query)
+	}
+
+	var events []models.Event
+	if err := query.Limit(page.limit).Find(&events).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
-				"message": "Database error",
+				"message": "Failed to fetch events",
 			},
 		})
 		return
 	}
 
-	// Generate ICS content
-	ics := fmt.Sprintf(`BEGIN:VCALENDAR
-VERSION:2.0
-PRODID:%s
-METHOD:PUBLISH
-BEGIN:VEVENT
-UID:evt_%s@%s
-DTSTART:%s
-DTEND:%s
-SUMMARY:%s
-DESCRIPTION:%s
-LOCATION:%s
-URL:%s
-STATUS:CONFIRMED
-END:VEVENT
-END:VCALENDAR`,
-		h.config.ICSProdID,
-		event.ID.String(),
-		h.config.ICSUIDDomain,
-		event.StartTs.UTC().Format("20060102T150405Z"),
-		func() string {
-			if event.EndTs != nil {
-				return event.EndTs.UTC().Format("20060102T150405Z")
-			}
-			return event.StartTs.Add(2 * time.Hour).UTC().Format("20060102T150405Z")
-		}(),
-		strings.ReplaceAll(event.Title, ",", "\\,"),
-		func() string {
-			if event.Description != nil {
-				return strings.ReplaceAll(*event.Description, ",", "\\,")
-			}
-			return ""
-		}(),
-		func() string {
-			if event.Venue != nil {
-				location := event.Venue.Name
-				if event.Venue.AddressLine != nil {
-					location += ", " + *event.Venue.AddressLine
-				}
-				return strings.ReplaceAll(location, ",", "\\,")
-			}
-			return ""
-		}(),
-		func() string {
-			if event.URL != nil {
-				return *event.URL
-			}
-			return ""
-		}(),
-	)
+	// Convert to GeoJSON
+	geoJSON := EventGeoJSON{
+		Type:     "FeatureCollection",
+		Features: make([]EventFeature, 0, len(events)),
+	}
+	if !byRelevance {
+		geoJSON.NextCursor = page.nextCursor(events)
+	}
 
-	c.Header("Content-Type", "text/calendar; charset=utf-8")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"event_%s.ics\"", event.ID.String()))
-	c.String(http.StatusOK, ics)
+	for i := range events {
+		geoJSON.Features = append(geoJSON.Features, newEventFeature(&events[i], loc))
+	}
+	h.attachFlyerImages(geoJSON.Features)
+	h.attachHeadlines(geoJSON.Features, eventSearchTerm(c))
+
+	c.JSON(http.StatusOK, geoJSON)
 }
 
-// Unpublish removes an event from public listing
-// POST /v1/events/{id}/unpublish
-func (h *EventHandler) Unpublish(c *gin.Context) {
-	eventID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
+// Radius bounds for GET /v1/events/near
+const (
+	defaultNearRadiusKm = 10
+	maxNearRadiusKm     = 200
+)
+
+// nearPointDistance is the geodesic distance in meters from a venue's location to the
+// searched point; the cast matches idx_venues_location_geography
+const nearPointDistance = "ST_Distance(location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography)"
+
+// Near lists approved events within radius_km (default 10, at most 200) of lat,lng, nearest
+// first, with each feature's distance_km. The listing's date, keyword and timezone
+// parameters apply; paging is by limit and offset. Events whose venue has no location have
+// no distance, so they are always left out.
+// GET /v1/events/near?lat=..&lng=..&radius_km=..
+func (h *EventHandler) Near(c *gin.Context) {
+	invalid := func(message string) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"message": "Invalid event ID",
+				"code":    "invalid_location",
+				"message": message,
 			},
 		})
-		return
 	}
 
-	var req UnpublishRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "Invalid request format",
-				"details": err.Error(),
-			},
-		})
+	point, ok := parseCoordinates(c.Query("lat")+","+c.Query("lng"), 2)
+	if !ok {
+		invalid("lat and lng are required numbers")
 		return
 	}
-
-	// Validate reason
-	validReasons := []string{"spam", "duplicate", "bad_location", "inappropriate"}
-	isValidReason := false
-	for _, validReason := range validReasons {
-		if req.Reason == validReason {
-			isValidReason = true
-			break
+	lat, lng := point[0], point[1]
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		invalid("lat must be within -90..90 and lng within -180..180")
+		return
+	}
+	radiusKm := float64(defaultNearRadiusKm)
+	if raw := c.Query("radius_km"); raw != "" {
+		parsed, ok := parseCoordinates(raw, 1)
+		if !ok || parsed[0] <= 0 || parsed[0] > maxNearRadiusKm {
+			invalid(fmt.Sprintf("radius_km must be greater than 0 and at most %d", maxNearRadiusKm))
+			return
 		}
+		radiusKm = parsed[0]
 	}
 
-	if !isValidReason {
+	regionLoc, err := h.config.GetLocation()
+	if err != nil {
+		regionLoc = time.UTC
+	}
+	loc, ok := h.resolveTimezone(c, regionLoc)
+	if !ok {
+		return
+	}
+	query, ok := h.filteredEvents(c, loc)
+	if !ok {
+		return
+	}
+	page, ok := h.parseEventPage(c)
+	if !ok {
+		return
+	}
+	if page.cursor != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"message": "Invalid unpublish reason",
+				"code":    "invalid_cursor",
+				"message": "cursor paging is not supported here; use offset",
 			},
 		})
 		return
 	}
+	if h.config.AnonymousMaxOffset > 0 && page.offset > h.config.AnonymousMaxOffset && !middleware.IsPartner(c) {
+		h.requireAPIKey(c, fmt.Sprintf("offsets beyond %d require an API key", h.config.AnonymousMaxOffset))
+		return
+	}
 
-	// Update event moderation state
-	result := h.db.Model(&models.Event{}).
-		Where("id = ?", eventID).
-		Updates(map[string]interface{}{
-			"moderation_state": "blocked",
-			"updated_at":       time.Now(),
-		})
+	nearby := h.db.Model(&models.Venue{}).
+		Select("id").
+		Where("location IS NOT NULL AND ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)", lng, lat, radiusKm*1000)
+	query = query.Where("events.venue_id IN (?)", nearby).
+		// Order drops a bare gorm.Expr, so the distance goes in as an ORDER BY expression
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "(SELECT " + nearPointDistance + " FROM venues WHERE venues.id = events.venue_id), events.start_ts, events.id",
+			Vars:               []interface{}{lng, lat},
+			WithoutParentheses: true,
+		}})
 
-	if result.Error != nil {
+	var events []models.Event
+	if err := query.Offset(page.offset).Limit(page.limit).Find(&events).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
-				"message": "Failed to unpublish event",
+				"message": "Failed to fetch events",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
+	// Distances come from PostGIS too, so they agree with the ordering
+	venueIDs := make([]uuid.UUID, 0, len(events))
+	for _, event := range events {
+		if event.VenueID != nil {
+			venueIDs = append(venueIDs, *event.VenueID)
+		}
+	}
+	var distances []struct {
+		ID     uuid.UUID
+		Meters float64
+	}
+	if len(venueIDs) > 0 {
+		if err := h.db.Model(&models.Venue{}).
+			Select("id, "+nearPointDistance+" AS meters", lng, lat).
+			Where("id IN ?", venueIDs).
+			Scan(&distances).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Failed to fetch events",
+				},
+			})
+			return
+		}
+	}
+	distanceKm := make(map[uuid.UUID]float64, len(distances))
+	for _, d := range distances {
+		distanceKm[d.ID] = math.Round(d.Meters/10) / 100
+	}
+
+	geoJSON := EventGeoJSON{
+		Type:     "FeatureCollection",
+		Features: make([]EventFeature, 0, len(events)),
+	}
+	for i := range events {
+		feature := newEventFeature(&events[i], loc)
+		if distance, ok := distanceKm[*events[i].VenueID]; ok {
+			feature.Properties.DistanceKm = &distance
+		}
+		geoJSON.Features = append(geoJSON.Features, feature)
+	}
+	h.attachFlyerImages(geoJSON.Features)
+	h.attachHeadlines(geoJSON.Features, eventSearchTerm(c))
+
+	c.JSON(http.StatusOK, geoJSON)
+}
+
+// filteredEvents builds the approved-events query shared by the listing and the feeds from
+// start_date, end_date, include_past, bbox, radius and keyword (or q). Writes an error
+// response and returns false on invalid input.
+func (h *EventHandler) filteredEvents(c *gin.Context, loc *time.Location) (*gorm.DB, bool) {
+	window, ok := h.parseEventWindow(c, loc, time.Now(), true)
+	if !ok {
+		return nil, false
+	}
+
+	query := h.db.Model(&models.Event{}).
+		Preload("Venue").
+		Where("moderation_state = ?", "approved")
+	query = window.apply(query)
+
+	// Apply filters
+	bbox, ok := h.parseBBox(c)
+	if !ok {
+		return nil, false
+	}
+	if bbox != nil {
+		// Events without a geocoded venue can't be placed in the box, so they drop out
+		inBox := h.db.Model(&models.Venue{}).
+			Select("id").
+			Where("location IS NOT NULL AND ST_Within(location, ST_MakeEnvelope(?, ?, ?, ?, 4326))", bbox.west, bbox.south, bbox.east, bbox.north)
+		query = query.Where("events.venue_id IN (?)", inBox)
+	}
+	circle, ok := h.parseRadius(c)
+	if !ok {
+		return nil, false
+	}
+	if circle != nil {
+		// Geography distances are in meters; the cast matches idx_venues_location_geography
+		inCircle := h.db.Model(&models.Venue{}).
+			Select("id").
+			Where("location IS NOT NULL AND ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)", circle.lng, circle.lat, circle.meters)
+		query = query.Where("events.venue_id IN (?)", inCircle)
+	}
+	if term := eventSearchTerm(c); term != "" {
+		// Full-text match on title and description, served by idx_events_search_vector
+		query = query.Where("events.search_vector @@ plainto_tsquery('english', ?)", term)
+	}
+
+	return query, true
+}
+
+// mappableEvents leaves out events whose venue has no location, which the map listing can't
+// place, unless include_unlocated=true asks for them. Feeds and calendar exports carry no
+// map, so they keep every event.
+func (h *EventHandler) mappableEvents(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if c.Query("include_unlocated") == "true" {
+		return query
+	}
+	located := h.db.Model(&models.Venue{}).Select("id").Where("location IS NOT NULL")
+	return query.Where("events.venue_id IN (?)", located)
+}
+
+// eventSearchTerm is the listing's full-text query: keyword, or its alias q
+func eventSearchTerm(c *gin.Context) string {
+	if keyword := strings.TrimSpace(c.Query("keyword")); keyword != "" {
+		return keyword
+	}
+	return strings.TrimSpace(c.Query("q"))
+}
+
+// parseEventSort reads sort, reporting whether results are ranked by relevance. Absent or
+// "start" keeps chronological order. Writes a 400 and returns false for any other value, or
+// for relevance without a keyword to rank by.
+func (h *EventHandler) parseEventSort(c *gin.Context) (bool, bool) {
+	invalid := func(message string) (bool, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"message": "Event not found",
+				"code":    "invalid_sort",
+				"message": message,
 			},
 		})
+		return false, false
+	}
+
+	switch c.Query("sort") {
+	case "", "start":
+		return false, true
+	case "relevance":
+		if eventSearchTerm(c) == "" {
+			return invalid("sort=relevance requires keyword or q")
+		}
+		return true, true
+	default:
+		return invalid("sort must be start or relevance")
+	}
+}
+
+// Headline match markers: private-use characters ts_headline wraps matches in, swapped for
+// <b> only after the excerpt is HTML-escaped so event text can't inject markup
+const (
+	headlineStartSel = "\ue000"
+	headlineStopSel  = "\ue001"
+)
+
+// eventHeadlineOptions shape the ts_headline excerpt: a short excerpt around the matched words, which are wrapped in the markers above
+const eventHeadlineOptions = `MaxWords=35, MinWords=15, MaxFragments=2, StartSel="` + headlineStartSel + `", StopSel="` + headlineStopSel + `"`
+
+// renderHeadline HTML-escapes a ts_headline excerpt and turns its match markers into <b> tags
+func renderHeadline(raw string) string {
+	return headlineMarkup.Replace(html.EscapeString(raw))
+}
+
+var headlineMarkup = strings.NewReplacer(headlineStartSel, "<b>", headlineStopSel, "</b>")
+
+// attachHeadlines sets each feature's headline to an excerpt of its title and description
+// with the words matching term highlighted. Lookup failures leave headlines off rather
+// than failing the listing.
+func (h *EventHandler) attachHeadlines(features []EventFeature, term string) {
+	if term == "" || len(features) == 0 {
 		return
 	}
+	ids := make([]string, 0, len(features))
+	for _, feature := range features {
+		ids = append(ids, feature.ID)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Event unpublished successfully",
-		"reason":  req.Reason,
-	})
-}
\ No newline at end of file
+	var headlines []struct {
+		ID       string
+		Headline string
+	}
+	if err := h.db.Model(&models.Event{}).
+		Select("id, ts_headline('english', coalesce(title, '') || ' ' || coalesce(description, ''), plainto_tsquery('english', ?), ?) AS headline", term, eventHeadlineOptions).
+		Where("id IN ?", ids).
+		Scan(&headlines).Error; err != nil {
+		log.Printf("Failed to build search headlines: %v", err)
+		return
+	}
+
+	byID := make(map[string]string, len(headlines))
+	for _, row := range headlines {
+		byID[row.ID] = renderHeadline(row.Headline)
+	}
+	for i := range features {
+		if headline, ok := byID[features[i].ID]; ok {
+			features[i].Properties.Headline = &headline
+		}
+	}
+}
+
+// newEventFeature converts an event (with its venue preloaded) to a GeoJSON feature
+func newEventFeature(event *models.Event, loc *time.Location) EventFeature {
+	feature := EventFeature{
+		Type: "Feature",
+		ID:   event.ID.String(),
+		Properties: EventProperties{
+			Title:       event.Title,
+			StartTs:     event.StartTs,
+			EndTs:       event.EndTs,
+			URL:         event.URL,
+			Price:       event.Price,
+			PriceTiers:  event.PriceTiers,
+			PriceMin:    event.PriceMinCents,
+			PriceMax:    event.PriceMaxCents,
+			Description: event.Description,
+			Organizer:   event.Organizer,
+			Attributes:  event.Attributes,
+			Source:      event.Source,
+			LocalTimes:  services.FormatLocalTimes(event.StartTs, event.EndTs, loc),
+			HappeningNow: services.EventHappeningAt(event.StartTs, event.EndTs, time.Now()),
+		},
+	}
+
+	if event.Venue != nil {
+		feature.Properties.VenueName = &event.Venue.Name
+		feature.Properties.Address = event.Venue.AddressLine
+		feature.Geometry = venueGeometry(event.Venue)
+	}
+
+	return feature
+}
+
+// venueGeometry is a venue's location as a GeoJSON point, or nil when it has none
+func venueGeometry(venue *models.Venue) *EventGeometry {
+	if venue == nil || venue.Location == nil {
+		return nil
+	}
+	lng, lat, err := services.ParsePoint(*venue.Location)
+	if err != nil {
+		log.Printf("Venue %s has an unreadable location: %v", venue.ID, err)
+		return nil
+	}
+	return &EventGeometry{
+		Type:        "Point",
+		Coordinates: []float64{lng, lat},
+	}
+}
+
+// attachFlyerImages sets each feature's image_url and thumbnail_url to the stable image
+// endpoint when the event has a redacted flyer image. Lookup failures leave images off
+// rather than failing the listing.
+func (h *EventHandler) attachFlyerImages(features []EventFeature) {
+	ids := make([]uuid.UUID, 0, len(features))
+	for _, feature := range features {
+		if id, err := uuid.Parse(feature.ID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	withImages, err := services.EventsWithImages(h.db, ids)
+	if err != nil {
+		log.Printf("Failed to load flyer images: %v", err)
+		return
+	}
+
+	for i := range features {
+		if id, err := uuid.Parse(features[i].ID); err == nil && withImages[id] {
+			full := services.EventImageURL(h.config.PublicBaseURL, id, services.EventImageFull)
+			thumb := services.EventImageURL(h.config.PublicBaseURL, id, services.EventImageThumb)
+			features[i].Properties.ImageURL = &full
+			features[i].Properties.ThumbnailURL = &thumb
+		}
+	}
+}
+
+// listAsOf serves GET /v1/events?as_of= from event history. Restricted to partner keys
+// because reconstruction scans history rather than using the live indexes.
+func (h *EventHandler) listAsOf(c *gin.Context, rawAsOf string, loc *time.Location) {
+	if !middleware.IsPartner(c) {
+		h.requireAPIKey(c, "as_of queries require an API key")
+		return
+	}
+
+	asOf, err := time.Parse(time.RFC3339, rawAsOf)
+	if err != nil {
+		// A bare date means "as of the end of that day" in the region
+		day, dayErr := time.ParseInLocation("2006-01-02", rawAsOf, loc)
+		if dayErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "invalid_as_of",
+					"message": "as_of must be a date (2006-01-02) or RFC3339 timestamp",
+				},
+			})
+			return
+		}
+		asOf = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	events, err := services.EventsAsOf(h.db, asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to fetch events",
+			},
+		})
+		return
+	}
+
+	// Apply the same filters and order as the live listing, relative to as_of
+	window, ok := h.parseEventWindow(c, loc, asOf, false)
+	if !ok {
+		return
+	}
+	page, ok := h.parseEventPage(c)
+	if !ok {
+		return
+	}
+	keyword := strings.ToLower(eventSearchTerm(c))
+	includeUnlocated := c.Query("include_unlocated") == "true"
+
+	// Venues are not versioned; attach their current records
+	venueIDs := make([]uuid.UUID, 0, len(events))
+	for _, event := range events {
+		if event.VenueID != nil {
+			venueIDs = append(venueIDs, *event.VenueID)
+		}
+	}
+	venues := make(map[uuid.UUID]*models.Venue)
+	if len(venueIDs) > 0 {
+		var found []models.Venue
+		if err := h.db.Where("id IN ?", venueIDs).Find(&found).Error; err == nil {
+			for i := range found {
+				venues[found[i].ID] = &found[i]
+			}
+		}
+	}
+
+	filtered := events[:0]
+	for _, event := range events {
+		if event.VenueID != nil {
+			event.Venue = venues[*event.VenueID]
+		}
+		if !includeUnlocated && (event.Venue == nil || event.Venue.Location == nil) {
+			continue
+		}
+		if !window.contains(event.StartTs, event.EndTs) {
+			continue
+		}
+		if page.cursor != nil && !page.cursor.After(&event) {
+			continue
+		}
+		if keyword != "" {
+			description := ""
+			if event.Description != nil {
+				description = *event.Description
+			}
+			if !strings.Contains(strings.ToLower(event.Title), keyword) && !strings.Contains(strings.ToLower(description), keyword) {
+				continue
+			}
+		}
+		filtered = append(filtered, event)
+	}
+	services.SortEventsChronologically(filtered)
+
+	offset := page.offset
+	if offset > len(filtered) {
+		offset = len(filtered)
+	}
+	pageEvents := filtered[offset:]
+	if len(pageEvents) > page.limit {
+		pageEvents = pageEvents[:page.limit]
+	}
+
+	geoJSON := EventGeoJSON{
+		Type:       "FeatureCollection",
+		Features:   make([]EventFeature, 0, len(pageEvents)),
+		NextCursor: page.nextCursor(pageEvents),
+	}
+	for i := range pageEvents {
+		geoJSON.Features = append(geoJSON.Features, newEventFeature(&pageEvents[i], loc))
+	}
+	h.attachFlyerImages(geoJSON.Features)
+
+	c.JSON(http.StatusOK, geoJSON)
+}
+
+// ChangeFeedResponse is a page of the event change feed
+type ChangeFeedResponse struct {
+	Changes []models.EventChange `json:"changes"`
+	Cursor  int64                `json:"cursor"` // pass as ?since= to fetch the next page
+	HasMore bool                 `json:"has_more"`
+}
+
+// Changes returns event change feed entries after a cursor so mirrors can sync incrementally
+// GET /v1/events/changes?since=0&limit=500
+func (h *EventHandler) Changes(c *gin.Context) {
+	since := int64(0)
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		parsed, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message": "Invalid since cursor",
+				},
+			})
+			return
+		}
+		since = parsed
+	}
+
+	limit := services.MaxChangeFeedPage
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= services.MaxChangeFeedPage {
+			limit = parsedLimit
+		}
+	}
+
+	changes, err := services.ListEventChanges(h.db, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to fetch changes",
+			},
+		})
+		return
+	}
+
+	response := ChangeFeedResponse{
+		Changes: changes,
+		Cursor:  since,
+		HasMore: len(changes) == limit,
+	}
+	if len(changes) > 0 {
+		response.Cursor = changes[len(changes)-1].Seq
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Get returns a single event by ID
+// GET /v1/events/{id}
+func (h *EventHandler) Get(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid event ID",
+			},
+		})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.Preload("Venue").First(&event, "id = ?", eventID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// Deleted events answer 410 so clients holding the ID know to drop it
+			if deleted, err := services.IsEventDeleted(h.db, eventID); err == nil && deleted {
+				c.JSON(http.StatusGone, gin.H{
+					"error": gin.H{
+						"message": "Event has been deleted",
+					},
+				})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"message": "Event not found",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Database error",
+			},
+		})
+		return
+	}
+
+	// A confirmed duplicate sends clients holding its ID to the event it was merged into
+	if event.ModerationState == services.EventStateMerged {
+		primaryID, err := services.MergedInto(h.db, eventID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Database error",
+				},
+			})
+			return
+		}
+		if primaryID != nil {
+			c.Redirect(http.StatusMovedPermanently, "/v1/events/"+primaryID.String())
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// eventImageMaxAge is how long clients and CDNs may cache an event image. Re-redacting a
+// flyer changes the image behind the same URL, so this is kept to a day.
+const eventImageMaxAge = 24 * time.Hour
+
+// GetImage serves an event's redacted flyer image at a URL that doesn't depend on storage layout
+// GET /v1/events/{id}/image?size=thumb|full
+func (h *EventHandler) GetImage(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid event ID",
+			},
+		})
+		return
+	}
+	size := c.DefaultQuery("size", services.EventImageFull)
+	if !services.IsValidEventImageSize(size) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "invalid_size",
+				"message": "size must be thumb or full",
+			},
+		})
+		return
+	}
+
+	path, err := services.EventImageFile(h.db, h.storage, eventID, size)
+	if err != nil {
+		if errors.Is(err, services.ErrEventImageUnavailable) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "image_not_found",
+					"message": "Event has no image",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to resolve image for event %s: %v", eventID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to load image",
+			},
+		})
+		return
+	}
+
+	// Redacted files get a fresh name per redaction, so the name identifies the content
+	etag := `"` + filepath.Base(path) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(eventImageMaxAge.Seconds())))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.File(path)
+}
+
+// GetICS returns an event in ICS calendar format. Times are in REGION_TZ unless ?tz= is given.
+// GET /v1/events/{id}/ics
+func (h *EventHandler) GetICS(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid event ID",
+			},
+		})
+		return
+	}
+
+	loc, ok := h.resolveTimezone(c, services.RegionLocation(h.config))
+	if !ok {
+		return
+	}
+
+	var event models.Event
+	if err := h.db.Preload("Venue").First(&event, "id = ?", eventID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"message": "Event not found",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Database error",
+			},
+		})
+		return
+	}
+
+	// Unpublished events render as cancellations under the same UID
+	ics := services.ICSCalendar{
+		ProdID:    h.config.ICSProdID,
+		UIDDomain: h.config.ICSUIDDomain,
+		Location:  loc,
+	}.Render([]models.Event{event})
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"event_%s.ics\"", event.ID.String()))
+	c.String(http.StatusOK, ics)
+}
+
+// eventFilterParams are the listing filters that filteredEvents reads
+var eventFilterParams = []string{"start_date", "end_date", "include_past", "bbox", "radius", "keyword", "q"}
+
+// hasEventFilters reports whether the request sets any listing filter
+func hasEventFilters(c *gin.Context) bool {
+	for _, param := range eventFilterParams {
+		if c.Query(param) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CalendarFeed is a subscribable ICS feed of upcoming distributable events. Events taken down
+// since they were published stay in the feed as cancellations until they fall out of the
+// window, so subscribed calendars remove them. Given any of the listing's filters, it instead
+// exports the events the listing would return, without cancellations. Either way events
+// whose venue has no location are included, as a calendar needs none. Times are in REGION_TZ
+// unless ?tz= is given.
+// GET /v1/events/calendar.ics?keyword=&start_date=&bbox=
+func (h *EventHandler) CalendarFeed(c *gin.Context) {
+	loc, ok := h.resolveTimezone(c, services.RegionLocation(h.config))
+	if !ok {
+		return
+	}
+
+	var events []models.Event
+	if hasEventFilters(c) {
+		query, ok := h.filteredEvents(c, loc)
+		if !ok {
+			return
+		}
+		if err := services.OrderEventsChronologically(services.DistributableEvents(query)).Limit(services.MaxICSFeedEvents).Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Database error",
+				},
+			})
+			return
+		}
+	} else {
+		now := time.Now()
+		var err error
+		events, err = services.ICSFeedEvents(h.db, now.Add(-24*time.Hour), now.AddDate(0, 0, h.config.ICSFeedDays))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Database error",
+				},
+			})
+			return
+		}
+	}
+
+	h.serveCalendar(c, events, loc)
+}
+
+// VenueCalendar is the calendar feed for a single venue's events, with the same window and
+// cancellations as the bulk feed. Times are in REGION_TZ unless ?tz= is given.
+// GET /v1/venues/{id}/calendar.ics
+func (h *EventHandler) VenueCalendar(c *gin.Context) {
+	venueID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid venue ID",
+			},
+		})
+		return
+	}
+	loc, ok := h.resolveTimezone(c, services.RegionLocation(h.config))
+	if !ok {
+		return
+	}
+
+	var venue models.Venue
+	if err := h.db.First(&venue, "id = ?", venueID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"message": "Venue not found",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Database error",
+			},
+		})
+		return
+	}
+
+	now := time.Now()
+	events, err := services.ICSVenueFeedEvents(h.db, venue.ID, now.Add(-24*time.Hour), now.AddDate(0, 0, h.config.ICSFeedDays))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Database error",
+			},
+		})
+		return
+	}
+
+	h.serveCalendar(c, events, loc)
+}
+
+// serveCalendar renders events as one VCALENDAR
+func (h *EventHandler) serveCalendar(c *gin.Context, events []models.Event, loc *time.Location) {
+	ics := services.ICSCalendar{
+		ProdID:    h.config.ICSProdID,
+		UIDDomain: h.config.ICSUIDDomain,
+		Location:  loc,
+	}.Render(events)
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.String(http.StatusOK, ics)
+}
+
+// RSSFeed is an RSS 2.0 feed of the events the listing would return, filtered the same way
+// GET /v1/events/feed.rss?keyword=&start_date=&bbox=
+func (h *EventHandler) RSSFeed(c *gin.Context) {
+	h.serveEventFeed(c, "application/rss+xml; charset=utf-8", services.EventFeed.RenderRSS)
+}
+
+// AtomFeed is an Atom 1.0 feed of the events the listing would return, filtered the same way
+// GET /v1/events/feed.atom?keyword=&start_date=&bbox=
+func (h *EventHandler) AtomFeed(c *gin.Context) {
+	h.serveEventFeed(c, "application/atom+xml; charset=utf-8", services.EventFeed.RenderAtom)
+}
+
+// serveEventFeed renders up to MaxEventFeedItems listed, non-quiet events in start order, with
+// Last-Modified set and a 304 for clients that already have the current feed
+func (h *EventHandler) serveEventFeed(c *gin.Context, contentType string, render func(services.EventFeed, []models.Event) ([]byte, error)) {
+	loc, ok := h.resolveTimezone(c, services.RegionLocation(h.config))
+	if !ok {
+		return
+	}
+	query, ok := h.filteredEvents(c, loc)
+	if !ok {
+		return
+	}
+
+	var events []models.Event
+	if err := services.OrderEventsChronologically(services.DistributableEvents(query)).Limit(services.MaxEventFeedItems).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to fetch events",
+			},
+		})
+		return
+	}
+	lastModified, err := services.EventFeedLastModified(h.db, events)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to fetch events",
+			},
+		})
+		return
+	}
+
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+		if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !lastModified.After(since) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	body, err := render(services.EventFeed{
+		Title:    h.config.AppName + " events",
+		Author:   h.config.AppName,
+		BaseURL:  h.config.PublicBaseURL,
+		SelfURL:  h.config.PublicBaseURL + c.Request.URL.RequestURI(),
+		Location: loc,
+		Updated:  lastModified,
+	}, events)
+	if err != nil {
+		log.Printf("Failed to render event feed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to render feed",
+			},
+		})
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// Unpublish removes an event from public listing
+// POST /v1/events/{id}/unpublish
+func (h *EventHandler) Unpublish(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid event ID",
+			},
+		})
+		return
+	}
+
+	var req UnpublishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid request format",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	result, err := services.UnpublishEvent(h.db, eventID, req.Reason, services.Actor{Type: services.ActorAPI, Route: c.FullPath()})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUnpublishReason):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message": "Invalid unpublish reason",
+				},
+			})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"message": "Event not found",
+				},
+			})
+		case errors.Is(err, services.ErrInvalidStateTransition):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": gin.H{
+					"code":    "already_unpublished",
+					"message": "Event is already unpublished",
+				},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Failed to unpublish event",
+				},
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Event unpublished successfully",
+		"reason":     req.Reason,
+		"candidates": result.CandidateIDs,
+	})
+}
+
+// Flag lets anyone report a published event. Flags from enough distinct IPs pull the event
+// back into review.
+// POST /v1/events/{id}/flag
+func (h *EventHandler) Flag(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid event ID",
+			},
+		})
+		return
+	}
+
+	var req FlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid request format",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	actor := services.Actor{Type: services.ActorAPI, Route: c.FullPath()}
+	result, err := services.FlagEvent(h.db, eventID, req.FlagType, req.Reason, c.ClientIP(),
+		h.config.FlagLimitPerDay, h.config.FlagReviewThreshold, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidFlagType):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "invalid_flag_type",
+					"message": "flag_type must be one of: " + strings.Join(services.ValidFlagTypes, ", "),
+				},
+			})
+		case errors.Is(err, services.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"message": "Event not found",
+				},
+			})
+		case errors.Is(err, services.ErrFlagLimitExceeded):
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": gin.H{
+					"code":    "flag_limit_exceeded",
+					"message": "Too many flags for this event today",
+				},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Failed to flag event",
+				},
+			})
+		}
+		return
+	}
+
+	if result.PulledForReview {
+		log.Printf("Event %s pulled for review after %d+ flags from distinct IPs", eventID, h.config.FlagReviewThreshold)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Thanks, the event has been flagged for review",
+		"flag_id":  result.Flag.ID,
+		"event_id": eventID,
+	})
+}
+
+// Dispute lets a venue manager holding a claim token pull an event attributed to their
+// venue back into review. The event is hidden until an admin accepts or rejects the dispute.
+// POST /v1/venues/{id}/events/{event_id}/dispute
+func (h *EventHandler) Dispute(c *gin.Context) {
+	venueID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid venue ID",
+			},
+		})
+		return
+	}
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid event ID",
+			},
+		})
+		return
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	claim, err := services.AuthenticateVenueClaim(h.db, h.config.VenueClaimSigningKey, venueID, token, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVenueClaimExpired):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"code":    "claim_expired",
+					"message": "Venue claim token has expired",
+				},
+			})
+		case errors.Is(err, services.ErrVenueClaimRevoked), errors.Is(err, services.ErrInvalidVenueClaim):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"code":    "invalid_claim",
+					"message": "Invalid venue claim token",
+				},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Failed to verify venue claim",
+				},
+			})
+		}
+		return
+	}
+
+	var req DisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid request format",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "A dispute reason is required",
+			},
+		})
+		return
+	}
+
+	actor := services.Actor{Type: services.ActorAPI, Route: c.FullPath()}
+	flag, err := services.DisputeEvent(h.db, claim, eventID, reason, c.ClientIP(), h.config.VenueDisputeLimitPerDay, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDisputeLimitExceeded):
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": gin.H{
+					"code":    "dispute_limit_exceeded",
+					"message": "Too many disputes for this venue today",
+				},
+			})
+		case errors.Is(err, services.ErrEventNotFound), errors.Is(err, services.ErrEventNotAtVenue):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"message": "Event not found at this venue",
+				},
+			})
+		case errors.Is(err, services.ErrInvalidStateTransition):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": gin.H{
+					"code":    "not_published",
+					"message": "Event is not currently published",
+				},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Failed to dispute event",
+				},
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Event disputed and pulled for review",
+		"dispute_id": flag.ID,
+		"event_id":   eventID,
+	})
+}
@@ -0,0 +1,899 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/middleware"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/services"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestParseEventWindowAnonymousHistory(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	earliest := now.AddDate(0, 0, -90)
+	h := &EventHandler{config: &config.Config{AnonymousMaxPastDays: 90}}
+
+	tests := []struct {
+		name     string
+		query    string
+		apiKey   string
+		wantOK   bool
+		wantCode int
+		wantFrom *time.Time
+	}{
+		{"map default shows upcoming events", "", "", true, http.StatusOK, nil},
+		{"include_past is clamped to the recent window", "include_past=true", "", true, http.StatusOK, &earliest},
+		{"recent start_date", "start_date=2026-05-01", "", true, http.StatusOK, timePtr(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC))},
+		{"deep start_date needs a key", "start_date=2025-01-01", "", false, http.StatusUnauthorized, nil},
+		{"partner may go deep", "start_date=2025-01-01", "partner-key", true, http.StatusOK, timePtr(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))},
+		{"partner history is unclamped", "include_past=true", "partner-key", true, http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var window eventWindow
+			var ok bool
+			router := gin.New()
+			router.Use(middleware.PartnerKeys([]string{"partner-key"}))
+			router.GET("/v1/events", func(c *gin.Context) {
+				window, ok = h.parseEventWindow(c, time.UTC, now, true)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/events?"+tt.query, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(middleware.APIKeyHeader, tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if ok != tt.wantOK || w.Code != tt.wantCode {
+				t.Fatalf("ok = %v, status = %d; want %v, %d", ok, w.Code, tt.wantOK, tt.wantCode)
+			}
+			if !ok {
+				return
+			}
+			if (window.from == nil) != (tt.wantFrom == nil) || (window.from != nil && !window.from.Equal(*tt.wantFrom)) {
+				t.Errorf("from = %v, want %v", window.from, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestParseEventWindowIncludePast(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	june10 := time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC)
+	june21 := time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC)
+	h := &EventHandler{config: &config.Config{}}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantAfter *time.Time
+		wantFrom  *time.Time
+		wantUntil *time.Time
+	}{
+		{"upcoming by default", "", &now, nil, nil},
+		{"include_past drops the upcoming filter", "include_past=true", nil, nil, nil},
+		{"start_date replaces the upcoming filter", "start_date=2026-06-10", nil, &june10, nil},
+		{"include_past is moot with a start_date", "start_date=2026-06-10&include_past=true", nil, &june10, nil},
+		{"end_date alone stays upcoming", "end_date=2026-06-20", &now, nil, &june21},
+		{"end_date with include_past", "end_date=2026-06-20&include_past=true", nil, nil, &june21},
+	}
+
+	sameTime := func(got, want *time.Time) bool {
+		return (got == nil) == (want == nil) && (got == nil || got.Equal(*want))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/events?"+tt.query, nil)
+
+			window, ok := h.parseEventWindow(c, time.UTC, now, true)
+			if !ok {
+				t.Fatal("parseEventWindow() rejected the request")
+			}
+			if !sameTime(window.after, tt.wantAfter) || !sameTime(window.from, tt.wantFrom) || !sameTime(window.until, tt.wantUntil) {
+				t.Errorf("window = after %v, from %v, until %v; want %v, %v, %v",
+					window.after, window.from, window.until, tt.wantAfter, tt.wantFrom, tt.wantUntil)
+			}
+		})
+	}
+}
+
+// TestParseEventWindowLocalDays checks start_date and end_date cover whole local days in the
+// listing's zone, including the short and long days at DST changes, and that events running
+// past midnight are listed on each day they cover
+func TestParseEventWindowLocalDays(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, chicago)
+	}
+	now := at(1, 1, 12, 0)
+	h := &EventHandler{config: &config.Config{}}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantLen time.Duration
+		start   time.Time
+		end     *time.Time
+		want    bool
+	}{
+		{"late show on the day it ends", "start_date=2026-06-13&end_date=2026-06-13", 24 * time.Hour, at(6, 12, 22, 0), timePtr(at(6, 13, 1, 0)), true},
+		{"festival on a middle day", "start_date=2026-07-11&end_date=2026-07-11", 24 * time.Hour, at(7, 10, 12, 0), timePtr(at(7, 12, 22, 0)), true},
+		{"festival in a week that starts after it", "start_date=2026-07-12&end_date=2026-07-18", 7 * 24 * time.Hour, at(7, 10, 12, 0), timePtr(at(7, 12, 22, 0)), true},
+		{"festival not in the week after", "start_date=2026-07-13&end_date=2026-07-19", 7 * 24 * time.Hour, at(7, 10, 12, 0), timePtr(at(7, 12, 22, 0)), false},
+		{"spring forward day is 23 hours", "start_date=2026-03-08&end_date=2026-03-08", 23 * time.Hour, at(3, 8, 23, 30), nil, true},
+		{"spring forward day ends at local midnight", "start_date=2026-03-08&end_date=2026-03-08", 23 * time.Hour, at(3, 9, 0, 0), nil, false},
+		{"fall back day is 25 hours", "start_date=2026-11-01&end_date=2026-11-01", 25 * time.Hour, at(11, 1, 23, 30), nil, true},
+		{"fall back day keeps the night before's late show", "start_date=2026-11-01&end_date=2026-11-01", 25 * time.Hour, at(10, 31, 23, 0), timePtr(at(11, 1, 1, 30)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/events?"+tt.query, nil)
+
+			window, ok := h.parseEventWindow(c, chicago, now, false)
+			if !ok {
+				t.Fatal("parseEventWindow() rejected the request")
+			}
+			if got := window.until.Sub(*window.from); got != tt.wantLen {
+				t.Errorf("window is %v long, want %v", got, tt.wantLen)
+			}
+			if got := window.contains(tt.start, tt.end); got != tt.want {
+				t.Errorf("contains(%v to %v) = %v, want %v", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetImage(t *testing.T) {
+	uploadDir := t.TempDir()
+	storage := services.NewStorageService(&config.Config{UploadDir: uploadDir})
+	submissionID := uuid.New()
+	if err := os.MkdirAll(filepath.Join(uploadDir, submissionID.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(storage.GetFilePath(submissionID, "redacted_1.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		publicFile  string // the image flyer found, "" for none; skipped when the request is rejected first
+		ifNoneMatch string
+		wantCode    int
+		wantErr     string
+	}{
+		{"full image", "", "redacted_1.jpg", "", http.StatusOK, ""},
+		{"revalidated", "?size=full", "redacted_1.jpg", `"redacted_1.jpg"`, http.StatusNotModified, ""},
+		{"re-redacted since", "?size=full", "redacted_1.jpg", `"redacted_0.jpg"`, http.StatusOK, ""},
+		{"no image", "", "", "", http.StatusNotFound, "image_not_found"},
+		{"purged original", "", "redacted_gone.jpg", "", http.StatusNotFound, "image_not_found"},
+		{"unknown size", "?size=huge", "-", "", http.StatusBadRequest, "invalid_size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+			if tt.publicFile != "-" {
+				rows := sqlmock.NewRows([]string{"id", "submission_id", "public_image_url"})
+				if tt.publicFile != "" {
+					rows.AddRow(uuid.New().String(), submissionID.String(), "https://api.example.com/files/"+submissionID.String()+"/"+tt.publicFile)
+				}
+				mock.ExpectQuery(`SELECT flyers.* FROM "event_candidates"`).WithArgs(services.EventStateApproved, eventID, testdb.Any).WillReturnRows(rows)
+			}
+
+			h := &EventHandler{config: &config.Config{}, db: db, storage: storage}
+			router := gin.New()
+			router.GET("/v1/events/:id/image", h.GetImage)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/events/"+eventID.String()+"/image"+tt.query, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantErr) {
+				t.Fatalf("status = %d, body = %s; want %d with %q", w.Code, w.Body.String(), tt.wantCode, tt.wantErr)
+			}
+			if w.Code == http.StatusOK {
+				if w.Body.String() != "jpeg" || w.Header().Get("ETag") != `"redacted_1.jpg"` ||
+					!strings.HasPrefix(w.Header().Get("Cache-Control"), "public, max-age=") {
+					t.Errorf("served %q with ETag %q, Cache-Control %q", w.Body.String(), w.Header().Get("ETag"), w.Header().Get("Cache-Control"))
+				}
+			}
+		})
+	}
+}
+
+func TestListAsOfRequiresPartnerKey(t *testing.T) {
+	h := &EventHandler{config: &config.Config{}}
+
+	tests := []struct {
+		name     string
+		asOf     string
+		apiKey   string
+		wantCode int
+		wantErr  string
+	}{
+		{"anonymous", "2026-05-01", "", http.StatusUnauthorized, "api_key_required"},
+		{"wrong key", "2026-05-01", "guess", http.StatusUnauthorized, "invalid_api_key"},
+		{"partner with a malformed as_of", "last tuesday", "partner-key", http.StatusBadRequest, "invalid_as_of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(middleware.PartnerKeys([]string{"partner-key"}))
+			router.GET("/v1/events", func(c *gin.Context) {
+				h.listAsOf(c, c.Query("as_of"), time.UTC)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/events?as_of="+url.QueryEscape(tt.asOf), nil)
+			if tt.apiKey != "" {
+				req.Header.Set(middleware.APIKeyHeader, tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantErr) {
+				t.Errorf("status = %d, body = %s; want %d with %q", w.Code, w.Body.String(), tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEventFeatureSerializationGolden(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2030, 7, 15, 2, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	location := "POINT(-122.4194 37.7749)"
+
+	tests := []struct {
+		name   string
+		golden string
+		event  models.Event
+	}{
+		{
+			name:   "default schema",
+			golden: "event_feature.golden",
+			event: models.Event{
+				ID:          uuid.MustParse("6f1c2d3e-4b5a-4c6d-8e7f-9a0b1c2d3e4f"),
+				Title:       "Summer Music Festival",
+				StartTs:     start,
+				EndTs:       &end,
+				URL:         strPtr("https://example.com"),
+				Price:       strPtr("$20 adv / $25 door"),
+				Description: strPtr("Live music and food trucks"),
+				Organizer:   strPtr("Music Society"),
+				Source:      "flyer",
+				Venue: &models.Venue{
+					Name:        "Central Park",
+					AddressLine: strPtr("123 Main St"),
+					Location:    &location,
+				},
+			},
+		},
+		{
+			name:   "extra public fields",
+			golden: "event_feature_attributes.golden",
+			event: models.Event{
+				ID:         uuid.MustParse("0a1b2c3d-4e5f-4a6b-8c7d-8e9f0a1b2c3d"),
+				Title:      "Black umbrella",
+				StartTs:    start,
+				Attributes: models.Attributes{"reward": 20.0, "item_description": "Folding, wooden handle"},
+				Source:     "flyer",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(newEventFeature(&tt.event, la), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("feature differs from %s; run with -update if the change is intended\ngot:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestParseBBox(t *testing.T) {
+	tests := []struct {
+		name    string
+		bbox    string
+		want    *boundingBox
+		wantErr bool
+	}{
+		{"absent", "", nil, false},
+		{"viewport", "-89.7,39.7,-89.6,39.8", &boundingBox{west: -89.7, south: 39.7, east: -89.6, north: 39.8}, false},
+		{"spaces around numbers", " -89.7, 39.7 ,-89.6 ,39.8 ", &boundingBox{west: -89.7, south: 39.7, east: -89.6, north: 39.8}, false},
+		{"a single point", "10,20,10,20", &boundingBox{west: 10, south: 20, east: 10, north: 20}, false},
+		{"whole world", "-180,-90,180,90", &boundingBox{west: -180, south: -90, east: 180, north: 90}, false},
+		{"three numbers", "-89.7,39.7,-89.6", nil, true},
+		{"five numbers", "-89.7,39.7,-89.6,39.8,1", nil, true},
+		{"non-numeric", "west,39.7,-89.6,39.8", nil, true},
+		{"empty part", "-89.7,,-89.6,39.8", nil, true},
+		{"NaN", "NaN,39.7,-89.6,39.8", nil, true},
+		{"west greater than east", "-89.6,39.7,-89.7,39.8", nil, true},
+		{"south greater than north", "-89.7,39.8,-89.6,39.7", nil, true},
+		{"longitude out of range", "-181,39.7,-89.6,39.8", nil, true},
+		{"latitude out of range", "-89.7,39.7,-89.6,91", nil, true},
+	}
+
+	h := &EventHandler{config: &config.Config{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/events?bbox="+url.QueryEscape(tt.bbox), nil)
+
+			got, ok := h.parseBBox(c)
+			if tt.wantErr {
+				if ok || w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_bbox") {
+					t.Errorf("parseBBox() = %v, %v with status %d; want a 400 invalid_bbox", got, ok, w.Code)
+				}
+				return
+			}
+			if !ok || (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("parseBBox() = %+v, %v; want %+v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+// TestListFiltersByBBox checks a bbox restricts the listing to events at venues the
+// database places inside the envelope, leaving unlocated venues out even when
+// include_unlocated asks for them, and that a malformed bbox is refused before any query
+func TestListFiltersByBBox(t *testing.T) {
+	inBox := `events.venue_id IN (SELECT "id" FROM "venues" WHERE location IS NOT NULL AND ST_Within(location, ST_MakeEnvelope($5, $6, $7, $8, 4326)))`
+	order := ` ORDER BY events.start_ts ASC,events.id ASC LIMIT $9`
+
+	tests := []struct {
+		name      string
+		query     string
+		wantSQL   string // empty when no query should run
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:     "bbox",
+			query:    "bbox=-89.7,39.7,-89.6,39.8",
+			wantSQL:  inBox + ` AND events.venue_id IN (SELECT "id" FROM "venues" WHERE location IS NOT NULL)` + order,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "bbox with include_unlocated",
+			query:    "bbox=-89.7,39.7,-89.6,39.8&include_unlocated=true",
+			wantSQL:  inBox + order,
+			wantCode: http.StatusOK,
+		},
+		{name: "too few numbers", query: "bbox=-89.7,39.7,-89.6", wantCode: http.StatusBadRequest, wantError: "invalid_bbox"},
+		{name: "west past east", query: "bbox=-89.6,39.7,-89.7,39.8", wantCode: http.StatusBadRequest, wantError: "invalid_bbox"},
+		{name: "latitude out of range", query: "bbox=-89.7,39.7,-89.6,91&include_unlocated=true", wantCode: http.StatusBadRequest, wantError: "invalid_bbox"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			inside := uuid.New()
+			venueID := uuid.New()
+			if tt.wantSQL != "" {
+				mock.ExpectQuery(tt.wantSQL).
+					WithArgs(append([]driver.Value{services.EventStateApproved}, append(testdb.AnyArgs(3), -89.7, 39.7, -89.6, 39.8, testdb.Any)...)...).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "title", "start_ts", "venue_id", "moderation_state"}).
+						AddRow(inside.String(), "Seed Swap", time.Now().Add(24*time.Hour), venueID.String(), services.EventStateApproved))
+				mock.ExpectQuery(`SELECT * FROM "venues" WHERE "venues"."id" = $1`).
+					WithArgs(venueID).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location"}).AddRow(venueID.String(), "Main Branch Library", "POINT(-89.65 39.78)"))
+				mock.ExpectQuery(`FROM "event_candidates"`).WillReturnRows(sqlmock.NewRows([]string{"published_event_id"}))
+			}
+
+			h := &EventHandler{config: &config.Config{}, db: db}
+			router := gin.New()
+			router.GET("/v1/events", h.List)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events?"+tt.query, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, body = %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+			if tt.wantError != "" {
+				if !strings.Contains(w.Body.String(), tt.wantError) {
+					t.Errorf("body = %s, want %q", w.Body.String(), tt.wantError)
+				}
+				return
+			}
+			var got EventGeoJSON
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Features) != 1 || got.Features[0].ID != inside.String() {
+				t.Errorf("features = %+v, want only the event inside the box", got.Features)
+			}
+		})
+	}
+}
+
+func TestVenueGeometry(t *testing.T) {
+	tests := []struct {
+		name  string
+		venue *models.Venue
+		want  []float64 // nil for a null geometry
+	}{
+		{"no venue", nil, nil},
+		{"venue never geocoded", &models.Venue{Name: "Somewhere"}, nil},
+		{"WKT as createOrUpdateVenue writes it", &models.Venue{Location: strPtr("SRID=4326;POINT(-89.65 39.78)")}, []float64{-89.65, 39.78}},
+		{"hex EWKB as the geometry column returns it", &models.Venue{Location: strPtr("0101000020E610000050FC1873D79A5EC0D0D556EC2FE34240")}, []float64{-122.4194, 37.7749}},
+		{"unreadable location", &models.Venue{Location: strPtr("POINT(nowhere)")}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := venueGeometry(tt.venue)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("venueGeometry() = %+v, want null", got)
+				}
+				return
+			}
+			if got == nil || got.Type != "Point" || len(got.Coordinates) != 2 || got.Coordinates[0] != tt.want[0] || got.Coordinates[1] != tt.want[1] {
+				t.Errorf("venueGeometry() = %+v, want a point at %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestListReturnsVenueCoordinates checks each listed feature carries its own venue's
+// coordinates rather than the San Francisco placeholder every feature once had, and that events without a geocoded venue come back with a null geometry
+// rather than a placeholder point or not at all
+func TestListReturnsVenueCoordinates(t *testing.T) {
+	db, mock := testdb.New(t)
+	library, grange, unlocated := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name    string
+		eventID uuid.UUID
+		venueID *uuid.UUID
+		want    string // the feature's geometry as JSON
+	}{
+		{"WKT location", uuid.New(), &library, `{"type":"Point","coordinates":[-89.65,39.78]}`},
+		{"hex EWKB location", uuid.New(), &grange, `{"type":"Point","coordinates":[-89.62,39.71]}`},
+		{"venue never geocoded", uuid.New(), &unlocated, `null`},
+		{"no venue", uuid.New(), nil, `null`},
+	}
+
+	events := sqlmock.NewRows([]string{"id", "title", "start_ts", "venue_id", "moderation_state"})
+	for i, tt := range tests {
+		var venueID interface{}
+		if tt.venueID != nil {
+			venueID = tt.venueID.String()
+		}
+		events.AddRow(tt.eventID.String(), tt.name, time.Now().Add(time.Duration(i+1)*time.Hour), venueID, services.EventStateApproved)
+	}
+	mock.ExpectQuery(`FROM "events" WHERE moderation_state = $1`).WillReturnRows(events)
+	mock.ExpectQuery(`SELECT * FROM "venues" WHERE "venues"."id" IN`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location"}).
+			AddRow(library.String(), "Main Branch Library", "SRID=4326;POINT(-89.65 39.78)").
+			AddRow(grange.String(), "The Grange Hall", "0101000020E610000048E17A14AE6756C07B14AE47E1DA4340").
+			AddRow(unlocated.String(), "Somewhere", nil))
+	mock.ExpectQuery(`FROM "event_candidates"`).WillReturnRows(sqlmock.NewRows([]string{"published_event_id"}))
+
+	h := &EventHandler{config: &config.Config{}, db: db}
+	router := gin.New()
+	router.GET("/v1/events", h.List)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events?include_unlocated=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var got struct {
+		Features []struct {
+			ID       string          `json:"id"`
+			Geometry json.RawMessage `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Features) != len(tests) {
+		t.Fatalf("listed %d features, want %d: %s", len(got.Features), len(tests), w.Body.String())
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feature := got.Features[i]
+			if feature.ID != tt.eventID.String() || string(feature.Geometry) != tt.want {
+				t.Errorf("feature %s geometry = %s, want %s for %s", feature.ID, feature.Geometry, tt.want, tt.eventID)
+			}
+		})
+	}
+}
+
+// TestNear checks the near search validates its point and radius before querying, and
+// lists events nearest first within the radius with each one's distance in kilometers
+func TestNear(t *testing.T) {
+	cursor := services.EventCursor{StartTs: time.Now().Add(time.Hour), ID: uuid.New()}.Encode()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantMeters float64 // the radius searched; 0 when no query should run
+		wantCode   int
+		wantError  string
+	}{
+		{name: "default radius", query: "lat=39.78&lng=-89.65", wantMeters: 10000, wantCode: http.StatusOK},
+		{name: "given radius", query: "lat=39.78&lng=-89.65&radius_km=25.5", wantMeters: 25500, wantCode: http.StatusOK},
+		{name: "largest radius", query: "lat=39.78&lng=-89.65&radius_km=200", wantMeters: 200000, wantCode: http.StatusOK},
+		{name: "no lng", query: "lat=39.78", wantCode: http.StatusBadRequest, wantError: "invalid_location"},
+		{name: "non-numeric lat", query: "lat=north&lng=-89.65", wantCode: http.StatusBadRequest, wantError: "invalid_location"},
+		{name: "latitude out of range", query: "lat=91&lng=-89.65", wantCode: http.StatusBadRequest, wantError: "invalid_location"},
+		{name: "longitude out of range", query: "lat=39.78&lng=-181", wantCode: http.StatusBadRequest, wantError: "invalid_location"},
+		{name: "zero radius", query: "lat=39.78&lng=-89.65&radius_km=0", wantCode: http.StatusBadRequest, wantError: "invalid_location"},
+		{name: "radius past the cap", query: "lat=39.78&lng=-89.65&radius_km=201", wantCode: http.StatusBadRequest, wantError: "invalid_location"},
+		{name: "cursor paging", query: "lat=39.78&lng=-89.65&cursor=" + url.QueryEscape(cursor), wantCode: http.StatusBadRequest, wantError: "invalid_cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			nearest, farther := uuid.New(), uuid.New()
+			library, grange := uuid.New(), uuid.New()
+			if tt.wantMeters != 0 {
+				mock.ExpectQuery(`AND events.venue_id IN (SELECT "id" FROM "venues" WHERE location IS NOT NULL AND ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography, $7)) ` +
+					`ORDER BY (SELECT ST_Distance(location::geography, ST_SetSRID(ST_MakePoint($8, $9), 4326)::geography) FROM venues WHERE venues.id = events.venue_id), events.start_ts, events.id LIMIT $10`).
+					WithArgs(append([]driver.Value{services.EventStateApproved}, append(testdb.AnyArgs(3), -89.65, 39.78, tt.wantMeters, -89.65, 39.78, testdb.Any)...)...).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "title", "start_ts", "venue_id", "moderation_state"}).
+						AddRow(nearest.String(), "Seed Swap", time.Now().Add(48*time.Hour), library.String(), services.EventStateApproved).
+						AddRow(farther.String(), "Contra Dance", time.Now().Add(24*time.Hour), grange.String(), services.EventStateApproved))
+				mock.ExpectQuery(`SELECT * FROM "venues" WHERE "venues"."id" IN`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location"}).
+						AddRow(library.String(), "Main Branch Library", "SRID=4326;POINT(-89.65 39.78)").
+						AddRow(grange.String(), "The Grange Hall", "SRID=4326;POINT(-89.62 39.71)"))
+				mock.ExpectQuery(`AS meters FROM "venues" WHERE id IN ($3,$4)`).
+					WithArgs(-89.65, 39.78, library, grange).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "meters"}).
+						AddRow(grange.String(), 8234.6).
+						AddRow(library.String(), 0.0))
+				mock.ExpectQuery(`FROM "event_candidates"`).WillReturnRows(sqlmock.NewRows([]string{"published_event_id"}))
+			}
+
+			h := &EventHandler{config: &config.Config{}, db: db}
+			router := gin.New()
+			router.GET("/v1/events/near", h.Near)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events/near?"+tt.query, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, body = %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+			if tt.wantError != "" {
+				if !strings.Contains(w.Body.String(), tt.wantError) {
+					t.Errorf("body = %s, want %q", w.Body.String(), tt.wantError)
+				}
+				return
+			}
+			var got EventGeoJSON
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Features) != 2 || got.Features[0].ID != nearest.String() || got.Features[1].ID != farther.String() {
+				t.Fatalf("features = %+v, want the library's event then the grange's, in the database's order", got.Features)
+			}
+			for i, want := range []float64{0, 8.23} {
+				if distance := got.Features[i].Properties.DistanceKm; distance == nil || *distance != want {
+					t.Errorf("feature %d distance_km = %v, want %v", i, distance, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderHeadline(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"plain excerpt", "Open mic night", "Open mic night"},
+		{"matches highlighted", "Open " + headlineStartSel + "jazz" + headlineStopSel + " night with " + headlineStartSel + "jazz" + headlineStopSel + " trio", "Open <b>jazz</b> night with <b>jazz</b> trio"},
+		{"markup in the event text is escaped", `<script>alert(1)</script> & "` + headlineStartSel + "jazz" + headlineStopSel + `"`, "&lt;script&gt;alert(1)&lt;/script&gt; &amp; &#34;<b>jazz</b>&#34;"},
+		{"literal tags aren't mistaken for matches", "<b>jazz</b>", "&lt;b&gt;jazz&lt;/b&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderHeadline(tt.raw); got != tt.want {
+				t.Errorf("renderHeadline(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestListFullTextSearch checks keyword and its alias q filter on the search vector, that
+// sort=relevance ranks by ts_rank, and that matching features carry an escaped headline
+func TestListFullTextSearch(t *testing.T) {
+	cursor := services.EventCursor{StartTs: time.Now().Add(time.Hour), ID: uuid.New()}.Encode()
+	match := `AND events.search_vector @@ plainto_tsquery('english', $5) AND events.venue_id IN (SELECT "id" FROM "venues" WHERE location IS NOT NULL) `
+
+	tests := []struct {
+		name      string
+		query     string
+		wantSQL   string // empty when no query should run
+		wantArgs  []driver.Value
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:     "keyword",
+			query:    "keyword=jazz",
+			wantSQL:  match + `ORDER BY events.start_ts ASC,events.id ASC LIMIT $6`,
+			wantArgs: []driver.Value{"jazz", testdb.Any},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "q alias",
+			query:    "q=jazz",
+			wantSQL:  match + `ORDER BY events.start_ts ASC,events.id ASC LIMIT $6`,
+			wantArgs: []driver.Value{"jazz", testdb.Any},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "relevance",
+			query:    "q=jazz&sort=relevance",
+			wantSQL:  match + `ORDER BY ts_rank(events.search_vector, plainto_tsquery('english', $6)) DESC, events.start_ts, events.id LIMIT $7`,
+			wantArgs: []driver.Value{"jazz", "jazz", testdb.Any},
+			wantCode: http.StatusOK,
+		},
+		{name: "relevance without a keyword", query: "sort=relevance", wantCode: http.StatusBadRequest, wantError: "invalid_sort"},
+		{name: "unknown sort", query: "keyword=jazz&sort=popular", wantCode: http.StatusBadRequest, wantError: "invalid_sort"},
+		{name: "relevance with a cursor", query: "keyword=jazz&sort=relevance&cursor=" + url.QueryEscape(cursor), wantCode: http.StatusBadRequest, wantError: "invalid_cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+			if tt.wantSQL != "" {
+				mock.ExpectQuery(tt.wantSQL).
+					WithArgs(append(append([]driver.Value{services.EventStateApproved}, testdb.AnyArgs(3)...), tt.wantArgs...)...).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "title", "start_ts", "moderation_state"}).
+						AddRow(eventID.String(), "Jazz <Night>", time.Now().Add(24*time.Hour), services.EventStateApproved))
+				mock.ExpectQuery(`FROM "event_candidates"`).WillReturnRows(sqlmock.NewRows([]string{"published_event_id"}))
+				mock.ExpectQuery(`AS headline FROM "events" WHERE id IN ($3)`).
+					WithArgs("jazz", eventHeadlineOptions, eventID.String()).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "headline"}).AddRow(eventID.String(), headlineStartSel+"Jazz"+headlineStopSel+" <Night>"))
+			}
+
+			h := &EventHandler{config: &config.Config{}, db: db}
+			router := gin.New()
+			router.GET("/v1/events", h.List)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events?"+tt.query, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, body = %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+			if tt.wantError != "" {
+				if !strings.Contains(w.Body.String(), tt.wantError) {
+					t.Errorf("body = %s, want %q", w.Body.String(), tt.wantError)
+				}
+				return
+			}
+			var got EventGeoJSON
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Features) != 1 {
+				t.Fatalf("features = %+v, want the one match", got.Features)
+			}
+			if headline := got.Features[0].Properties.Headline; headline == nil || *headline != "<b>Jazz</b> &lt;Night&gt;" {
+				t.Errorf("headline = %v, want the escaped excerpt with the match in bold", headline)
+			}
+		})
+	}
+}
+
+// TestGetICSEscapesAndFolds checks the single-event export served over HTTP is valid RFC
+// 5545 text: TEXT values escaped, long lines folded at 75 octets without splitting a
+// character, and CRLF line endings throughout
+func TestGetICSEscapesAndFolds(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		description string
+		want        []string // unfolded content lines
+	}{
+		{
+			name:  "plain title",
+			title: "Seed Swap",
+			want:  []string{"SUMMARY:Seed Swap"},
+		},
+		{
+			name:        "special characters",
+			title:       `Soup, Salad; Bread \ Butter`,
+			description: "Line one\nLine two\r\nLine three",
+			want:        []string{`SUMMARY:Soup\, Salad\; Bread \\ Butter`, `DESCRIPTION:Line one\nLine two\nLine three`},
+		},
+		{
+			name:        "long lines",
+			title:       "Spring Barn Dance " + strings.Repeat("and potluck, ", 8),
+			description: strings.Repeat("Bring a chair 🪑 and a friend 🎻; ", 6),
+			want: []string{
+				"SUMMARY:Spring Barn Dance " + strings.Repeat(`and potluck\, `, 8),
+				"DESCRIPTION:" + strings.Repeat(`Bring a chair 🪑 and a friend 🎻\; `, 6),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID, venueID := uuid.New(), uuid.New()
+			var description interface{}
+			if tt.description != "" {
+				description = tt.description
+			}
+			mock.ExpectQuery(`SELECT * FROM "events" WHERE id = $1`).
+				WithArgs(eventID, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "start_ts", "venue_id", "moderation_state"}).
+					AddRow(eventID.String(), tt.title, description, time.Date(2026, 6, 13, 19, 0, 0, 0, time.UTC), venueID.String(), services.EventStateApproved))
+			mock.ExpectQuery(`SELECT * FROM "venues" WHERE "venues"."id" = $1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "address_line"}).AddRow(venueID.String(), "The Grange Hall", "12 Main St, Springfield"))
+
+			h := &EventHandler{config: &config.Config{RegionTZ: "America/Chicago", ICSProdID: "-//WilliamBoard//EN", ICSUIDDomain: "williamboard.test"}, db: db}
+			router := gin.New()
+			router.GET("/v1/events/:id/ics", h.GetICS)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events/"+eventID.String()+"/ics", nil))
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/calendar; charset=utf-8" {
+				t.Fatalf("status = %d, Content-Type = %q, body = %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+			}
+			ics := w.Body.String()
+			if !strings.HasSuffix(ics, "\r\n") || strings.Count(ics, "\n") != strings.Count(ics, "\r\n") || strings.Count(ics, "\r") != strings.Count(ics, "\r\n") {
+				t.Fatalf("ICS has a line ending other than CRLF: %q", ics)
+			}
+			for i, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+				if len(line) > 75 || !utf8.ValidString(line) {
+					t.Errorf("line %d is %d octets or splits a character: %q", i+1, len(line), line)
+				}
+			}
+
+			unfolded := strings.Split(strings.ReplaceAll(ics, "\r\n ", ""), "\r\n")
+			for _, want := range append(tt.want, `LOCATION:The Grange Hall\, 12 Main St\, Springfield`, "DTSTART;TZID=America/Chicago:20260613T140000") {
+				if !slices.Contains(unfolded, want) {
+					t.Errorf("ICS is missing the line %q:\n%s", want, ics)
+				}
+			}
+		})
+	}
+}
+
+// postgisTx opens the database named by WB_TEST_DATABASE_URL, skipping the test when it is
+// unset, and returns a transaction rolled back when the test ends. Each of tables is shadowed
+// by an empty temporary copy, with its indexes, so the test sees only the rows it writes.
+// The database must be migrated by the API (PostGIS, pg_trgm and the schema).
+func postgisTx(t *testing.T, tables ...string) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("WB_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("WB_TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	for _, table := range tables {
+		if err := tx.Exec(fmt.Sprintf(`CREATE TEMP TABLE %[1]s (LIKE public.%[1]s INCLUDING ALL) ON COMMIT DROP`, table)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tx
+}
+
+// TestListFiltersByBBoxPostGIS lists events at venues inside and outside a box against a
+// real PostGIS database
+func TestListFiltersByBBoxPostGIS(t *testing.T) {
+	tx := postgisTx(t, "venues", "events")
+	start := time.Now().Add(48 * time.Hour).UTC()
+
+	place := func(name string, location *string) uuid.UUID {
+		venue := models.Venue{Name: name, Location: location}
+		if err := tx.Create(&venue).Error; err != nil {
+			t.Fatal(err)
+		}
+		event := models.Event{CanonicalKey: name, Title: "At " + name, StartTs: start, VenueID: &venue.ID, ModerationState: services.EventStateApproved, Source: "flyer"}
+		if err := tx.Create(&event).Error; err != nil {
+			t.Fatal(err)
+		}
+		return event.ID
+	}
+	library := place("Main Branch Library", strPtr("SRID=4326;POINT(-89.65 39.78)"))
+	grange := place("The Grange Hall", strPtr("SRID=4326;POINT(-89.62 39.71)"))
+	place("Riverside Park", strPtr("SRID=4326;POINT(-88.24 40.11)")) // Champaign, outside the box
+	place("Somewhere", nil)                                          // never geocoded
+
+	for _, query := range []string{"bbox=-89.7,39.7,-89.6,39.8", "bbox=-89.7,39.7,-89.6,39.8&include_unlocated=true"} {
+		h := &EventHandler{config: &config.Config{}, db: tx}
+		router := gin.New()
+		router.GET("/v1/events", h.List)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", query, w.Code, w.Body.String())
+		}
+
+		var got EventGeoJSON
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		ids := map[string]bool{}
+		for _, feature := range got.Features {
+			ids[feature.ID] = true
+		}
+		if len(ids) != 2 || !ids[library.String()] || !ids[grange.String()] {
+			t.Errorf("%s: listed %v, want only the library and the grange", query, ids)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
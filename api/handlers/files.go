@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// ServePublicFiles serves stored uploads under /files/*filepath. Files in a submission's
+// private directory (such as unredacted flyer crops) are reported as missing.
+func ServePublicFiles(storage *services.StorageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path, ok := storage.PublicFilePath(c.Param("filepath"))
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		c.File(path)
+	}
+}
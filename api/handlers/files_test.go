@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+func TestServePublicFilesKeepsPrivateFilesPrivate(t *testing.T) {
+	uploadDir := t.TempDir()
+	storage := services.NewStorageService(&config.Config{UploadDir: uploadDir})
+	for _, name := range []string{
+		"sub-1/redacted_flyer.jpg",
+		"sub-1/private/crop_flyer.jpg",
+		".env",
+	} {
+		path := filepath.Join(uploadDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := gin.New()
+	router.GET("/files/*filepath", ServePublicFiles(storage))
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"redacted crop", "/files/sub-1/redacted_flyer.jpg", http.StatusOK},
+		{"unredacted crop", "/files/sub-1/private/crop_flyer.jpg", http.StatusNotFound},
+		{"private directory listing", "/files/sub-1/private/", http.StatusNotFound},
+		{"encoded private segment", "/files/sub-1/%70rivate/crop_flyer.jpg", http.StatusNotFound},
+		{"dot-dot into private", "/files/sub-1/x/../private/crop_flyer.jpg", http.StatusNotFound},
+		{"dotfile", "/files/.env", http.StatusNotFound},
+		{"submission directory", "/files/sub-1", http.StatusNotFound},
+		{"missing file", "/files/sub-1/nope.jpg", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -2,32 +2,51 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/lincolngreen/williamboard/api/config"
 	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/services"
 	"gorm.io/gorm"
 )
 
+// Long-poll limits for GET /v1/submissions/:id/status?wait=
+const (
+	maxStatusWait      = 60 * time.Second
+	statusPollInterval = 2 * time.Second
+)
+
 type SubmissionHandler struct {
 	config *config.Config
 	db     *gorm.DB
+	broker *services.StatusBroker
+	queue  *services.ProcessingQueue
 }
 
 type SubmissionStatus struct {
-	Status     string                    `json:"status"`
-	Step       string                    `json:"step,omitempty"`
-	Flyers     []FlyerStatusResult       `json:"flyers,omitempty"`
-	Candidates []CandidateStatusResult   `json:"candidates,omitempty"`
-	Error      *string                   `json:"error,omitempty"`
+	Status          string                  `json:"status"`
+	Source          string                  `json:"source"`
+	Step            string                  `json:"step,omitempty"`
+	ImageCount      int                     `json:"imageCount"`      // photos uploaded
+	ImagesProcessed int                     `json:"imagesProcessed"` // photos the vision model has read
+	Flyers          []FlyerStatusResult     `json:"flyers,omitempty"`
+	Candidates      []CandidateStatusResult `json:"candidates,omitempty"`
+	Error           *string                 `json:"error,omitempty"`
+	DuplicateOf     *string                 `json:"duplicateOf,omitempty"` // the earlier upload whose results these are
+
+	// Set while the submission is still being processed
+	*services.Backpressure
 }
 
 type FlyerStatusResult struct {
-	FlyerID              string  `json:"flyerId"`
-	RegionID             string  `json:"regionId"`
-	ImageURL             string  `json:"imageUrl"`
-	DetectionConfidence  float64 `json:"detectionConfidence"`
+	FlyerID             string  `json:"flyerId"`
+	RegionID            string  `json:"regionId"`
+	ImageIndex          int     `json:"imageIndex"` // the photo it was found in, from 1
+	ImageURL            string  `json:"imageUrl"`
+	DetectionConfidence float64 `json:"detectionConfidence"`
 }
 
 type CandidateStatusResult struct {
@@ -38,15 +57,18 @@ type CandidateStatusResult struct {
 	Reason      *string `json:"reason,omitempty"`
 }
 
-func NewSubmissionHandler(cfg *config.Config, db *gorm.DB) *SubmissionHandler {
+func NewSubmissionHandler(cfg *config.Config, db *gorm.DB, broker *services.StatusBroker, queue *services.ProcessingQueue) *SubmissionHandler {
 	return &SubmissionHandler{
 		config: cfg,
 		db:     db,
+		broker: broker,
+		queue:  queue,
 	}
 }
 
 
-// GetStatus returns the current processing status of a submission
+// GetStatus returns the current processing status of a submission.
+// With ?wait=30s the request is held until the status changes or the wait elapses.
 // GET /v1/submissions/{id}/status
 func (h *SubmissionHandler) GetStatus(c *gin.Context) {
 	submissionID, err := uuid.Parse(c.Param("id"))
@@ -59,7 +81,114 @@ func (h *SubmissionHandler) GetStatus(c *gin.Context) {
 		return
 	}
 
-	// Find the submission with related data
+	wait, err := parseStatusWait(c.Query("wait"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid wait duration",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	// A deduplicated upload reports, and waits on, the earlier submission it was linked to
+	var duplicateOf *string
+	var link models.Submission
+	if err := h.db.Select("duplicate_of_id").First(&link, "id = ?", submissionID).Error; err == nil && link.DuplicateOfID != nil {
+		submissionID = *link.DuplicateOfID
+		original := submissionID.String()
+		duplicateOf = &original
+	}
+
+	// Subscribe before reading so a change between the read and the wait isn't missed
+	var updates <-chan string
+	if wait > 0 {
+		var cancel func()
+		updates, cancel = h.broker.Subscribe(submissionID)
+		defer cancel()
+	}
+
+	submission, ok := h.loadSubmission(c, submissionID)
+	if !ok {
+		return
+	}
+
+	if wait > 0 && !services.IsTerminalSubmissionStatus(submission.Status) {
+		if h.waitForStatusChange(c, submissionID, submission.Status, updates, wait) {
+			if submission, ok = h.loadSubmission(c, submissionID); !ok {
+				return
+			}
+		}
+	}
+
+	status := buildSubmissionStatus(submission)
+	status.DuplicateOf = duplicateOf
+	if !services.IsTerminalSubmissionStatus(submission.Status) {
+		backpressure := h.queue.Estimate(submissionID)
+		status.Backpressure = &backpressure
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// parseStatusWait accepts Go durations ("30s") or bare seconds ("30"), capped at maxStatusWait
+func parseStatusWait(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(raw)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			return 0, err
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxStatusWait {
+		wait = maxStatusWait
+	}
+	return wait, nil
+}
+
+// waitForStatusChange blocks until the submission leaves lastStatus, the wait elapses,
+// or the client disconnects. Returns true when the status should be re-read.
+func (h *SubmissionHandler) waitForStatusChange(c *gin.Context, submissionID uuid.UUID, lastStatus string, updates <-chan string, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	// Periodic DB check covers status changes made outside this process
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-timer.C:
+			return false
+		case status := <-updates:
+			if status != lastStatus {
+				return true
+			}
+		case <-ticker.C:
+			var current models.Submission
+			if err := h.db.Select("status").First(&current, "id = ?", submissionID).Error; err != nil {
+				return true
+			}
+			if current.Status != lastStatus {
+				return true
+			}
+		}
+	}
+}
+
+// loadSubmission fetches a submission with flyers and candidates, writing an error response on failure
+func (h *SubmissionHandler) loadSubmission(c *gin.Context, submissionID uuid.UUID) (*models.Submission, bool) {
 	var submission models.Submission
 	if err := h.db.Preload("Flyers.EventCandidates").First(&submission, "id = ?", submissionID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -68,18 +197,25 @@ func (h *SubmissionHandler) GetStatus(c *gin.Context) {
 					"message": "Submission not found",
 				},
 			})
-			return
+			return nil, false
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
 				"message": "Database error",
 			},
 		})
-		return
+		return nil, false
 	}
+	return &submission, true
+}
 
+// buildSubmissionStatus converts a submission with its flyers and candidates into the status payload
+func buildSubmissionStatus(submission *models.Submission) SubmissionStatus {
 	status := SubmissionStatus{
-		Status: submission.Status,
+		Status:          submission.Status,
+		Source:          submission.Source,
+		ImageCount:      submission.ImageCount,
+		ImagesProcessed: submission.ImagesProcessed,
 	}
 
 	// Determine processing step
@@ -100,6 +236,14 @@ func (h *SubmissionHandler) GetStatus(c *gin.Context) {
 		status.Step = "error"
 		errorMsg := "Processing failed"
 		status.Error = &errorMsg
+	case services.SubmissionStatusFailed:
+		status.Step = services.SubmissionStatusFailed
+		errorMsg := "Processing failed"
+		status.Error = &errorMsg
+	case services.SubmissionStatusRejectedQuality:
+		status.Step = services.SubmissionStatusRejectedQuality
+		errorMsg := services.RejectedQualityMessage
+		status.Error = &errorMsg
 	}
 
 	// Add flyer results if available
@@ -107,11 +251,15 @@ func (h *SubmissionHandler) GetStatus(c *gin.Context) {
 		flyerResult := FlyerStatusResult{
 			FlyerID:             flyer.ID.String(),
 			RegionID:            flyer.RegionID,
+			ImageIndex:          flyer.ImageIndex,
 			DetectionConfidence: flyer.DetectionConfidence,
 		}
 		
-		if flyer.CropImageURL != nil {
-			flyerResult.ImageURL = *flyer.CropImageURL
+		// The redacted crop once there is one; until then the crop of the submitter's own photo
+		if flyer.PublicImageURL != nil {
+			flyerResult.ImageURL = *flyer.PublicImageURL
+		} else if flyer.RegionCropURL != nil {
+			flyerResult.ImageURL = *flyer.RegionCropURL
 		}
 		
 		status.Flyers = append(status.Flyers, flyerResult)
@@ -138,5 +286,5 @@ func (h *SubmissionHandler) GetStatus(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, status)
+	return status
 }
\ No newline at end of file
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+func TestParseStatusWait(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"30s", 30 * time.Second, false},
+		{"1500ms", 1500 * time.Millisecond, false},
+		{"20", 20 * time.Second, false},
+		{"-5s", 0, false},
+		{"5m", maxStatusWait, false},
+		{"600", maxStatusWait, false},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseStatusWait(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStatusWait(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseStatusWait(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForStatusChange(t *testing.T) {
+	// Every case finishes well inside statusPollInterval, so the database is never polled
+	tests := []struct {
+		name string
+		// advance simulates the pipeline (or client) acting while the request waits
+		advance func(broker *services.StatusBroker, id uuid.UUID, disconnect context.CancelFunc)
+		want    bool
+	}{
+		{
+			name: "pipeline advances during the wait",
+			advance: func(broker *services.StatusBroker, id uuid.UUID, _ context.CancelFunc) {
+				broker.Publish(id, "processing")
+				broker.Publish(id, "done")
+			},
+			want: true,
+		},
+		{
+			name: "republished status keeps waiting until the wait elapses",
+			advance: func(broker *services.StatusBroker, id uuid.UUID, _ context.CancelFunc) {
+				broker.Publish(id, "processing")
+			},
+			want: false,
+		},
+		{
+			name: "client disconnects",
+			advance: func(_ *services.StatusBroker, _ uuid.UUID, disconnect context.CancelFunc) {
+				disconnect()
+			},
+			want: false,
+		},
+		{
+			name:    "nothing happens",
+			advance: func(*services.StatusBroker, uuid.UUID, context.CancelFunc) {},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := services.NewStatusBroker()
+			h := &SubmissionHandler{broker: broker}
+			id := uuid.New()
+
+			ctx, disconnect := context.WithCancel(context.Background())
+			defer disconnect()
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/v1/submissions/"+id.String()+"/status?wait=1", nil).WithContext(ctx)
+
+			updates, cancel := broker.Subscribe(id)
+			defer cancel()
+
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				tt.advance(broker, id, disconnect)
+			}()
+
+			if got := h.waitForStatusChange(c, id, "processing", updates, 300*time.Millisecond); got != tt.want {
+				t.Errorf("waitForStatusChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForStatusChangeDoesNotLeakOnDisconnect(t *testing.T) {
+	broker := services.NewStatusBroker()
+	h := &SubmissionHandler{broker: broker}
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		id := uuid.New()
+		ctx, disconnect := context.WithCancel(context.Background())
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		updates, cancel := broker.Subscribe(id)
+
+		done := make(chan struct{})
+		go func() {
+			h.waitForStatusChange(c, id, "processing", updates, maxStatusWait)
+			close(done)
+		}()
+		disconnect()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("wait did not return after the client disconnected")
+		}
+		cancel()
+	}
+
+	// Allow finished goroutines to be reaped before counting
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines grew from %d to %d", before, after)
+	}
+}
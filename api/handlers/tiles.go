@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/services"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTileZoom matches the deepest zoom web map clients request
+	maxTileZoom = 22
+	// tileLayerName is the layer clients style against
+	tileLayerName   = "events"
+	mvtContentType  = "application/vnd.mapbox-vector-tile"
+	tileCacheMaxAge = 60 // seconds; the server-side cache handles invalidation
+)
+
+type TileHandler struct {
+	config *config.Config
+	db     *gorm.DB
+	cache  *services.TileCache
+}
+
+func NewTileHandler(cfg *config.Config, db *gorm.DB) *TileHandler {
+	return &TileHandler{
+		config: cfg,
+		db:     db,
+		cache:  services.NewTileCache(cfg.TileCacheSize),
+	}
+}
+
+// eventTileSQL builds one MVT layer of approved events at geocoded venues. Each feature
+// carries id, title, category, and start_ts for styling and popups. Events that overlap
+// the date range are included, so a festival that began yesterday still shows.
+var eventTileSQL = `
+WITH bounds AS (
+	SELECT ST_TileEnvelope(@z, @x, @y) AS geom
+),
+features AS (
+	SELECT
+		ST_AsMVTGeom(ST_Transform(v.location, 3857), bounds.geom) AS geom,
+		e.id::text AS id,
+		e.title AS title,
+		c.category AS category,
+		to_char(e.start_ts AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS start_ts
+	FROM events e
+	JOIN venues v ON v.id = e.venue_id
+	JOIN bounds ON ST_Intersects(ST_Transform(v.location, 3857), bounds.geom)
+	LEFT JOIN LATERAL (
+		SELECT NULLIF(ec.fields->>'category', '') AS category
+		FROM event_candidates ec
+		WHERE ec.published_event_id = e.id
+		ORDER BY ec.created_at ASC
+		LIMIT 1
+	) c ON true
+	WHERE e.moderation_state = 'approved'
+		AND v.location IS NOT NULL
+		AND ` + services.EventOverlapSQL("e") + `
+		AND (@category = '' OR LOWER(c.category) = @category)
+)
+SELECT COALESCE(ST_AsMVT(features.*, '` + tileLayerName + `', 4096, 'geom'), ''::bytea) FROM features`
+
+// EventTile serves a Mapbox Vector Tile of events
+// GET /v1/tiles/events/:z/:x/:y.mvt?start_date=2025-06-01&end_date=2025-07-01&category=music
+func (h *TileHandler) EventTile(c *gin.Context) {
+	z, x, y, ok := parseTileCoords(c.Param("z"), c.Param("x"), c.Param("y"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "invalid_tile",
+				"message": fmt.Sprintf("tile must be /z/x/y.mvt with 0 <= z <= %d and x, y within the zoom level", maxTileZoom),
+			},
+		})
+		return
+	}
+
+	loc := services.RegionLocation(h.config)
+	start := time.Now()
+	var end *time.Time
+	if raw := c.Query("start_date"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, loc)
+		if err != nil {
+			h.invalidDate(c, "start_date")
+			return
+		}
+		start = parsed
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, loc)
+		if err != nil {
+			h.invalidDate(c, "end_date")
+			return
+		}
+		// end_date is inclusive, matching the events list
+		parsed = parsed.AddDate(0, 0, 1)
+		end = &parsed
+	}
+	if c.Query("start_date") == "" {
+		// Upcoming events only; truncate so the cache key is stable for a minute
+		start = start.Truncate(time.Minute)
+	}
+	category := strings.ToLower(strings.TrimSpace(c.Query("category")))
+
+	version, err := services.EventDataVersion(h.db)
+	if err != nil {
+		h.tileError(c)
+		return
+	}
+
+	endKey := int64(0)
+	if end != nil {
+		endKey = end.Unix()
+	}
+	key := fmt.Sprintf("%d/%d/%d|%d|%d|%s", z, x, y, start.Unix(), endKey, category)
+	tile, cached := h.cache.Get(key, version)
+	if !cached {
+		var raw []byte
+		err := h.db.Raw(eventTileSQL, map[string]interface{}{
+			"z":                z,
+			"x":                x,
+			"y":                y,
+			"start":            start,
+			"end":              end,
+			"category":         category,
+			"default_duration": services.DefaultEventDurationSeconds(),
+		}).Row().Scan(&raw)
+		if err != nil {
+			h.tileError(c)
+			return
+		}
+
+		tile, err = gzipBytes(raw)
+		if err != nil {
+			h.tileError(c)
+			return
+		}
+		h.cache.Put(key, version, tile)
+	}
+
+	etag := fmt.Sprintf(`"%d-%s"`, version, strings.ReplaceAll(key, "|", "-"))
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", tileCacheMaxAge))
+	c.Header("Vary", "Accept-Encoding")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		plain, err := gunzipBytes(tile)
+		if err != nil {
+			h.tileError(c)
+			return
+		}
+		c.Data(http.StatusOK, mvtContentType, plain)
+		return
+	}
+
+	c.Header("Content-Encoding", "gzip")
+	c.Data(http.StatusOK, mvtContentType, tile)
+}
+
+// EventClusters serves the precomputed homepage map clusters for a zoom level
+// GET /v1/events/clusters?days=7&zoom=10
+func (h *TileHandler) EventClusters(c *gin.Context) {
+	days := h.config.ClusterWindowDays
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed != h.config.ClusterWindowDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "invalid_days",
+					"message": fmt.Sprintf("days must be %d", h.config.ClusterWindowDays),
+				},
+			})
+			return
+		}
+	}
+
+	zoom, err := strconv.Atoi(c.Query("zoom"))
+	supported := false
+	for _, level := range h.config.ClusterZoomLevels {
+		supported = supported || (err == nil && level == zoom)
+	}
+	if !supported {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "invalid_zoom",
+				"message": fmt.Sprintf("zoom must be one of %v", h.config.ClusterZoomLevels),
+			},
+		})
+		return
+	}
+
+	set, err := services.GetEventClusterSet(h.db, days, zoom)
+	if err != nil {
+		if errors.Is(err, services.ErrClustersNotReady) {
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": gin.H{
+					"code":    "clusters_not_ready",
+					"message": "Clusters are still being computed",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to load clusters",
+			},
+		})
+		return
+	}
+
+	// Staleness: how old the set is, and whether events changed after it was built
+	cache := gin.H{
+		"computed_at":  set.ComputedAt,
+		"age_seconds":  int(time.Since(set.ComputedAt).Seconds()),
+		"compute_ms":   set.ComputeMS,
+		"data_version": set.DataVersion,
+	}
+	if latest, err := services.EventDataVersion(h.db); err == nil {
+		cache["latest_version"] = latest
+		cache["stale"] = latest > set.DataVersion
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", tileCacheMaxAge))
+	c.JSON(http.StatusOK, gin.H{
+		"days":         set.Days,
+		"zoom":         set.Zoom,
+		"window_start": set.WindowStart,
+		"window_end":   set.WindowEnd,
+		"event_count":  set.EventCount,
+		"clusters":     json.RawMessage(set.Clusters),
+		"cache":        cache,
+	})
+}
+
+// parseTileCoords validates z/x/y; y must carry the .mvt extension
+func parseTileCoords(rawZ, rawX, rawY string) (z, x, y int, ok bool) {
+	if !strings.HasSuffix(rawY, ".mvt") {
+		return 0, 0, 0, false
+	}
+	z, errZ := strconv.Atoi(rawZ)
+	x, errX := strconv.Atoi(rawX)
+	y, errY := strconv.Atoi(strings.TrimSuffix(rawY, ".mvt"))
+	if errZ != nil || errX != nil || errY != nil || z < 0 || z > maxTileZoom {
+		return 0, 0, 0, false
+	}
+	size := 1 << uint(z)
+	if x < 0 || x >= size || y < 0 || y >= size {
+		return 0, 0, 0, false
+	}
+	return z, x, y, true
+}
+
+func (h *TileHandler) invalidDate(c *gin.Context, param string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": gin.H{
+			"code":    "invalid_date",
+			"message": param + " must be a date (2006-01-02)",
+		},
+	})
+}
+
+func (h *TileHandler) tileError(c *gin.Context) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": gin.H{
+			"message": "Failed to build tile",
+		},
+	})
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBytes(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
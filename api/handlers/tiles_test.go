@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+func TestParseTileCoords(t *testing.T) {
+	tests := []struct {
+		z, x, y string
+		wantOK  bool
+	}{
+		{"0", "0", "0.mvt", true},
+		{"3", "7", "7.mvt", true},
+		{"22", "4194303", "0.mvt", true},
+		{"3", "8", "0.mvt", false},
+		{"3", "0", "-1.mvt", false},
+		{"23", "0", "0.mvt", false},
+		{"-1", "0", "0.mvt", false},
+		{"3", "1", "1", false},
+		{"3", "1", "1.pbf", false},
+		{"z", "1", "1.mvt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.z+"/"+tt.x+"/"+tt.y, func(t *testing.T) {
+			if _, _, _, ok := parseTileCoords(tt.z, tt.x, tt.y); ok != tt.wantOK {
+				t.Errorf("parseTileCoords() ok = %v, want %v", ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEventTileCachesUntilEventsChange(t *testing.T) {
+	db, mock := testdb.New(t)
+	h := &TileHandler{config: &config.Config{}, db: db, cache: services.NewTileCache(10)}
+	router := gin.New()
+	router.GET("/v1/tiles/events/:z/:x/:y", h.EventTile)
+
+	// What ST_AsMVT returned for the tile: one "events" layer
+	tile := []byte{0x1a, 0x0a, 0x0a, 0x06, 'e', 'v', 'e', 'n', 't', 's', 0x78, 0x02}
+	expectVersion := func(version int64) {
+		mock.ExpectQuery(`FROM event_changes`).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(version))
+	}
+	expectBuild := func() {
+		mock.ExpectQuery(`ST_AsMVT`).WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(tile))
+	}
+
+	steps := []struct {
+		name         string
+		version      int64
+		rebuild      bool
+		gzip         bool
+		ifNoneMatch  bool
+		wantCode     int
+		wantEncoding string
+	}{
+		{"first request builds the tile", 5, true, true, false, http.StatusOK, "gzip"},
+		{"repeat request is cached", 5, false, true, false, http.StatusOK, "gzip"},
+		{"client without gzip", 5, false, false, false, http.StatusOK, ""},
+		{"revalidation", 5, false, true, true, http.StatusNotModified, ""},
+		{"publish invalidates", 6, true, true, false, http.StatusOK, "gzip"},
+	}
+
+	var etag string
+	for _, step := range steps {
+		expectVersion(step.version)
+		if step.rebuild {
+			expectBuild()
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/tiles/events/3/1/2.mvt?start_date=2026-06-01&category=Music", nil)
+		if step.gzip {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		if step.ifNoneMatch {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != step.wantCode || w.Header().Get("Content-Encoding") != step.wantEncoding {
+			t.Fatalf("%s: status %d, encoding %q; want %d, %q", step.name, w.Code, w.Header().Get("Content-Encoding"), step.wantCode, step.wantEncoding)
+		}
+		if step.wantCode != http.StatusOK {
+			continue
+		}
+		etag = w.Header().Get("ETag")
+
+		body := w.Body.Bytes()
+		if step.gzip {
+			var err error
+			if body, err = gunzipBytes(body); err != nil {
+				t.Fatalf("%s: response is not gzip: %v", step.name, err)
+			}
+		}
+		if !bytes.Equal(body, tile) {
+			t.Errorf("%s: tile = %x, want %x", step.name, body, tile)
+		}
+		if got := w.Header().Get("Content-Type"); got != mvtContentType {
+			t.Errorf("%s: Content-Type = %q", step.name, got)
+		}
+	}
+}
+
+func TestEventTileRejectsBadRequests(t *testing.T) {
+	db, _ := testdb.New(t)
+	h := &TileHandler{config: &config.Config{}, db: db, cache: services.NewTileCache(10)}
+	router := gin.New()
+	router.GET("/v1/tiles/events/:z/:x/:y", h.EventTile)
+
+	for _, path := range []string{
+		"/v1/tiles/events/3/9/0.mvt",
+		"/v1/tiles/events/3/1/1.png",
+		"/v1/tiles/events/3/1/1.mvt?start_date=June",
+		"/v1/tiles/events/3/1/1.mvt?end_date=2026-13-01",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, w.Code)
+		}
+	}
+}
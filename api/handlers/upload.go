@@ -2,17 +2,25 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
-	"strings"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/lincolngreen/williamboard/api/config"
 	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/observability"
 	"github.com/lincolngreen/williamboard/api/services"
 	"gorm.io/gorm"
 )
@@ -22,28 +30,102 @@ type UploadHandler struct {
 	db         *gorm.DB
 	storage    *services.StorageService
 	vision     *services.VisionService
+	crops      *services.CropService
 	moderation *services.ModerationService
 	geocoding  *services.GeocodingService
+	enrichment *services.EnrichmentService
+	broker     *services.StatusBroker
+	queue      *services.ProcessingQueue
+	workers    *services.SubmissionWorkers
+	metrics    *observability.Registry
+
+	// Breakers pause Stage 3 calls to a failing dependency; the retry sweeper honours them too
+	moderationBreaker *services.CircuitBreaker
+	geocodeBreaker    *services.CircuitBreaker
 }
 
 type SignedURLRequest struct {
 	ContentType  string     `json:"contentType" binding:"required"`
 	SubmissionID *uuid.UUID `json:"submissionId"`
+
+	// Optional capture metadata from the app; preferred over the image's EXIF
+	CapturedAt        *time.Time `json:"capturedAt"`
+	Latitude          *float64   `json:"latitude"`
+	Longitude         *float64   `json:"longitude"`
+	DeviceOrientation *string    `json:"deviceOrientation"`
+	ExifOptIn         bool       `json:"exifOptIn"` // allow reading GPS position from the image's EXIF
+
+	// Optional hashes of the photo, so a re-upload can be caught before the file is sent
+	ImageSHA256 *string `json:"imageSha256"` // hex SHA-256 of the file bytes
+	ImagePHash  *string `json:"imagePhash"`  // 64-bit dHash as 16 hex digits
+}
+
+// DuplicateUploadResponse replaces the upload URL when the photo was already uploaded
+type DuplicateUploadResponse struct {
+	Duplicate bool `json:"duplicate"`
+	*services.DuplicateSubmission
+}
+
+// DeduplicatedUploadResponse answers a stored upload that matched an earlier one; its status
+// reports that submission's flyers and candidates
+type DeduplicatedUploadResponse struct {
+	Message              string `json:"message"`
+	SubmissionID         string `json:"submissionId"`
+	Status               string `json:"status"`
+	Deduplicated         bool   `json:"deduplicated"`
+	OriginalSubmissionID string `json:"originalSubmissionId"`
+	MatchedBy            string `json:"matchedBy"` // sha256 or phash
+}
+
+// SignedURLResponse is the upload target plus what to expect once the file is sent
+type SignedURLResponse struct {
+	*services.UploadURLResult
+	services.Backpressure
 }
 
-func NewUploadHandler(cfg *config.Config, db *gorm.DB, storage *services.StorageService) *UploadHandler {
-	vision := services.NewVisionService(cfg)
+// UploadAcceptedResponse confirms a stored upload was queued; results come from the status endpoint
+type UploadAcceptedResponse struct {
+	Message      string `json:"message"`
+	SubmissionID string `json:"submissionId"`
+	Status       string `json:"status"`
+	services.Backpressure
+}
+
+func NewUploadHandler(cfg *config.Config, db *gorm.DB, storage *services.StorageService, broker *services.StatusBroker, queue *services.ProcessingQueue, metrics *observability.Registry) *UploadHandler {
+	vision := services.NewVisionService(cfg, storage)
 	moderation := services.NewModerationService(cfg)
 	geocoding := services.NewGeocodingService(cfg)
+	enrichment := services.NewEnrichmentService(cfg)
+	breakerCooldown := time.Duration(cfg.BreakerCooldownSec) * time.Second
 	
-	return &UploadHandler{
+	h := &UploadHandler{
 		config:     cfg,
 		db:         db,
 		storage:    storage,
 		vision:     vision,
+		crops:      services.NewCropService(db, storage),
 		moderation: moderation,
 		geocoding:  geocoding,
+		enrichment: enrichment,
+		broker:     broker,
+		queue:      queue,
+		metrics:    metrics,
+
+		moderationBreaker: services.NewCircuitBreaker("moderation", cfg.BreakerFailureThreshold, breakerCooldown),
+		geocodeBreaker:    services.NewCircuitBreaker("geocoding", cfg.BreakerFailureThreshold, breakerCooldown),
 	}
+	h.workers = services.NewSubmissionWorkers(db, cfg, broker, queue, h.processUploadSync)
+	return h
+}
+
+// Workers returns the background pool that processes uploads; main starts it
+func (h *UploadHandler) Workers() *services.SubmissionWorkers {
+	return h.workers
+}
+
+// Breakers returns the Stage 3 dependency breakers, for the retry sweeper
+func (h *UploadHandler) Breakers() []*services.CircuitBreaker {
+	return []*services.CircuitBreaker{h.moderationBreaker, h.geocodeBreaker}
 }
 
 // GetSignedURL generates an upload URL for direct file upload
@@ -61,7 +143,7 @@ func (h *UploadHandler) GetSignedURL(c *gin.Context) {
 	}
 
 	// Validate content type
-	allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/webp"}
+	allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/webp", "image/heic", "image/heif"}
 	isValidType := false
 	for _, allowedType := range allowedTypes {
 		if req.ContentType == allowedType {
@@ -73,12 +155,52 @@ func (h *UploadHandler) GetSignedURL(c *gin.Context) {
 	if !isValidType {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"message": "Invalid content type. Allowed: jpeg, jpg, png, webp",
+				"message": "Invalid content type. Allowed: jpeg, jpg, png, webp, heic, heif",
+			},
+		})
+		return
+	}
+
+	capture := services.CaptureMetadata{
+		CapturedAt:        req.CapturedAt,
+		Latitude:          req.Latitude,
+		Longitude:         req.Longitude,
+		DeviceOrientation: req.DeviceOrientation,
+	}
+	if err := services.ValidateCaptureMetadata(capture, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
 			},
 		})
 		return
 	}
 
+	hashes, err := services.ParseImageHashes(req.ImageSHA256, req.ImagePHash)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+	if !hashes.Empty() {
+		duplicate, err := services.FindDuplicateSubmission(h.db, hashes, services.NewDuplicateSearch(h.config))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Failed to check for duplicate uploads",
+				},
+			})
+			return
+		}
+		if duplicate != nil {
+			c.JSON(http.StatusOK, DuplicateUploadResponse{Duplicate: true, DuplicateSubmission: duplicate})
+			return
+		}
+	}
+
 	// Generate submission ID if not provided
 	submissionID := uuid.New()
 	if req.SubmissionID != nil {
@@ -90,7 +212,11 @@ func (h *UploadHandler) GetSignedURL(c *gin.Context) {
 		ID:               submissionID,
 		OriginalImageURL: h.storage.GetOriginalImageURL(submissionID),
 		Status:           "uploaded",
+		Source:           services.SubmissionSourceUpload,
+		ExifOptIn:        req.ExifOptIn,
+		ClaimedSHA256:    hashes.SHA256,
 	}
+	services.ApplyCaptureMetadata(&submission, capture)
 
 	if err := h.db.Create(&submission).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -101,38 +227,790 @@ func (h *UploadHandler) GetSignedURL(c *gin.Context) {
 		return
 	}
 
-	// Generate upload URL
-	result := h.storage.GenerateUploadURL(submissionID)
-	c.JSON(http.StatusOK, result)
-}
+	// Generate upload URL
+	uploadURL, err := h.storage.GenerateUploadURL(submissionID)
+	if err != nil {
+		log.Printf("Failed to generate upload URL for submission %s: %v", submissionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to generate upload URL",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, SignedURLResponse{
+		UploadURLResult: uploadURL,
+		Backpressure:    h.queue.Estimate(submissionID),
+	})
+}
+
+// acceptsUpload reports whether a submission in this status may receive its photo, writing
+// a 409 when it may not. A fresh "uploaded" submission does, and so does one rejected for
+// image quality, whose retake re-runs it. Any other submission has started processing:
+// uploading again would overwrite its photo and run it twice next to its own flyers and
+// events. Failed runs are retried from the stored photo (automatically, then by an admin),
+// and finished ones are re-run through the admin reprocess endpoint.
+func acceptsUpload(c *gin.Context, status string) bool {
+	switch status {
+	case "uploaded", services.SubmissionStatusRejectedQuality:
+		return true
+	case "processing":
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"message": "Submission is already processing",
+			},
+		})
+	case "error", services.SubmissionStatusFailed:
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"message": "Submission processing failed",
+				"details": "it is retried from the photo already uploaded, automatically or by an admin; start a new submission to send a different photo",
+			},
+		})
+	default:
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"message": "Submission has already been processed",
+				"details": fmt.Sprintf("status is %q; start a new submission, or ask an admin to reprocess this one", status),
+			},
+		})
+	}
+	return false
+}
+
+// UploadFile handles direct file upload
+// PUT /v1/uploads/{id}
+func (h *UploadHandler) UploadFile(c *gin.Context) {
+	submissionIDStr := c.Param("id")
+	submissionID, err := uuid.Parse(submissionIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid submission ID",
+			},
+		})
+		return
+	}
+
+	// Check if submission exists
+	var submission models.Submission
+	if err := h.db.First(&submission, "id = ?", submissionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "Submission not found",
+			},
+		})
+		return
+	}
+
+	if !acceptsUpload(c, submission.Status) {
+		return
+	}
+
+	// Get uploaded files; "file" repeats for several overlapping photos of the same wall
+	var headers []*multipart.FileHeader
+	form, err := c.MultipartForm()
+	if err == nil {
+		headers = form.File["file"]
+		if len(headers) == 0 {
+			err = http.ErrMissingFile
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "No file uploaded",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+	if len(headers) > h.config.MaxUploadImages {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Too many files. Maximum is %d per upload", h.config.MaxUploadImages),
+			},
+		})
+		return
+	}
+
+	// Validate file size (12MB max per photo)
+	for _, header := range headers {
+		if header.Size > services.MaxUploadBytes {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message": "File too large. Maximum size is 12MB",
+				},
+			})
+			return
+		}
+	}
+
+	// Save each photo, hashing the first as it arrives; only the first is matched for duplicates
+	hasher := sha256.New()
+	for i, header := range headers {
+		var sum hash.Hash
+		if i == 0 {
+			sum = hasher
+		}
+		if !h.storeUploadedImage(c, submissionID, i+1, header, sum) {
+			return
+		}
+	}
+
+	h.finishUpload(c, submissionID, len(headers), submission.ImageCount, hex.EncodeToString(hasher.Sum(nil)))
+}
+
+// CompleteUpload starts processing a photo the client PUT straight to the storage bucket
+// with the URL from GetSignedURL
+// POST /v1/uploads/{id}/complete
+func (h *UploadHandler) CompleteUpload(c *gin.Context) {
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid submission ID",
+			},
+		})
+		return
+	}
+
+	var submission models.Submission
+	if err := h.db.First(&submission, "id = ?", submissionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "Submission not found",
+			},
+		})
+		return
+	}
+	if !acceptsUpload(c, submission.Status) {
+		return
+	}
+
+	// A working copy left by an earlier attempt may be stale; read what the client sent
+	imagePath := h.storage.GetFilePath(submissionID, services.SubmissionOriginalFilename)
+	os.Remove(imagePath)
+	imagePath, err = h.storage.FetchUpload(submissionID, services.SubmissionOriginalFilename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message": "No file uploaded",
+				},
+			})
+			return
+		}
+		if errors.Is(err, services.ErrFileTooLarge) {
+			h.storage.DeleteSubmissionFiles(submissionID)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message": "File too large. Maximum size is 12MB",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch direct upload for submission %s: %v", submissionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to read uploaded file",
+			},
+		})
+		return
+	}
+
+	// The upload policy holds the bucket to 12MB and the download above stops past it; the
+	// local backend's file is checked here. What the client sent is hashed before any
+	// conversion, as UploadFile does.
+	file, err := os.Open(imagePath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to read uploaded file",
+			},
+		})
+		return
+	}
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to read uploaded file",
+			},
+		})
+		return
+	}
+	if size > services.MaxUploadBytes {
+		h.storage.DeleteSubmissionFiles(submissionID)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "File too large. Maximum size is 12MB",
+			},
+		})
+		return
+	}
+
+	if err := services.TranscodeHEICOriginal(c.Request.Context(), h.config, imagePath); err != nil {
+		log.Printf("Failed to convert HEIC upload for submission %s: %v", submissionID, err)
+		h.storage.DeleteSubmissionFiles(submissionID)
+		if errors.Is(err, services.ErrHEICUnsupported) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": gin.H{
+					"code":    "heic_unsupported",
+					"message": "HEIC images are not supported here; please upload a JPEG or PNG",
+					"details": "this server has no HEIC converter installed",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"message": "Could not read HEIC image",
+			},
+		})
+		return
+	}
+
+	// A direct upload is always a single photo
+	h.finishUpload(c, submissionID, 1, submission.ImageCount, hex.EncodeToString(hasher.Sum(nil)))
+}
+
+// finishUpload records a submission's freshly stored photos, derives the images made from the
+// first, and queues it for processing. previousCount is the number of photos an earlier upload
+// stored; sha256Hex is the first photo's hash as received.
+func (h *UploadHandler) finishUpload(c *gin.Context, submissionID uuid.UUID, imageCount, previousCount int, sha256Hex string) {
+	// A re-upload with fewer photos leaves none of the earlier extras behind
+	for index := imageCount + 1; index <= previousCount; index++ {
+		os.Remove(h.storage.GetFilePath(submissionID, services.SubmissionImageFilename(index)))
+	}
+	if err := h.db.Model(&models.Submission{}).Where("id = ?", submissionID).
+		Update("image_count", imageCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to save file",
+			},
+		})
+		return
+	}
+
+	// Later photos are only turned upright; capture metadata, thumbnail, derivative, and
+	// hashes all come from the first
+	for index := 2; index <= imageCount; index++ {
+		if _, _, err := services.NormalizeOrientation(h.storage.GetFilePath(submissionID, services.SubmissionImageFilename(index))); err != nil {
+			log.Printf("Failed to normalize orientation of image %d for submission %s: %v", index, submissionID, err)
+		}
+	}
+
+	imagePath := h.storage.GetFilePath(submissionID, services.SubmissionOriginalFilename)
+
+	// Capture time (and GPS, when opted in) from EXIF; client metadata still takes precedence
+	if err := services.RecordExifCapture(h.db, submissionID, imagePath, services.RegionLocation(h.config)); err != nil {
+		log.Printf("Failed to read EXIF for submission %s: %v", submissionID, err)
+	}
+
+	// Rotate the original upright so polygons, crops, and overlays share the frame it is displayed in
+	if err := services.NormalizeSubmissionImage(h.db, submissionID, imagePath); err != nil {
+		log.Printf("Failed to normalize orientation for submission %s: %v", submissionID, err)
+	}
+
+	// Without the submitter's opt-in, no stored photo keeps its EXIF (GPS included)
+	photoPaths := make([]string, 0, imageCount)
+	for index := 1; index <= imageCount; index++ {
+		photoPaths = append(photoPaths, h.storage.GetFilePath(submissionID, services.SubmissionImageFilename(index)))
+	}
+	if err := services.StripExifUnlessOptedIn(h.db, submissionID, photoPaths...); err != nil {
+		log.Printf("Failed to strip EXIF for submission %s: %v", submissionID, err)
+	}
+
+	// Store the converted and rotated photos in place of what arrived
+	for index := 1; index <= imageCount; index++ {
+		if err := h.storage.Persist(submissionID, services.SubmissionImageFilename(index)); err != nil {
+			log.Printf("Failed to store image %d for submission %s: %v", index, submissionID, err)
+		}
+	}
+
+	// A small copy for the admin dashboard, which would otherwise load every full original
+	if err := services.RecordSubmissionThumbnail(h.db, h.storage, submissionID, imagePath); err != nil {
+		log.Printf("Failed to generate thumbnail for submission %s: %v", submissionID, err)
+	}
+
+	// The bounded JPEG the vision model reads, rather than the full original
+	if err := services.RecordSubmissionDerivative(h.db, h.storage, h.config, submissionID, imagePath); err != nil {
+		log.Printf("Failed to generate derivative for submission %s: %v", submissionID, err)
+	}
+
+	// Re-verify the client's hash; only server-computed hashes are used to spot duplicates
+	if err := services.RecordImageHashes(h.db, submissionID, sha256Hex, imagePath); err != nil {
+		log.Printf("Failed to record image hashes for submission %s: %v", submissionID, err)
+	}
+
+	// The same photo analyzed recently is not paid for twice: link to its flyers and stop
+	duplicate, err := services.DeduplicateSubmission(h.db, submissionID, services.NewDuplicateSearch(h.config))
+	if err != nil {
+		log.Printf("Failed to check submission %s for duplicates: %v", submissionID, err)
+	}
+	if duplicate != nil {
+		if err := h.updateSubmissionStatus(submissionID, "done"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message": "Failed to update submission status",
+				},
+			})
+			return
+		}
+		log.Printf("Submission %s duplicates %s (%s); skipping analysis", submissionID, duplicate.SubmissionID, duplicate.MatchedBy)
+		c.JSON(http.StatusOK, DeduplicatedUploadResponse{
+			Message:              "Image matches an earlier upload",
+			SubmissionID:         submissionID.String(),
+			Status:               "done",
+			Deduplicated:         true,
+			OriginalSubmissionID: duplicate.SubmissionID.String(),
+			MatchedBy:            duplicate.MatchedBy,
+		})
+		return
+	}
+
+	// Hand off to a background worker; clients poll the status endpoint for results
+	if err := h.updateSubmissionStatus(submissionID, "processing"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to queue image for processing",
+			},
+		})
+		return
+	}
+	h.workers.Enqueue(submissionID)
+
+	c.JSON(http.StatusAccepted, UploadAcceptedResponse{
+		Message:      "Image queued for processing",
+		SubmissionID: submissionID.String(),
+		Status:       "processing",
+		Backpressure: h.queue.Estimate(submissionID),
+	})
+}
+
+// CreateManualSubmission accepts a typed-in event and runs it through Stage 3, skipping vision
+// POST /v1/submissions/manual
+func (h *UploadHandler) CreateManualSubmission(c *gin.Context) {
+	var req services.ManualEventFields
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Invalid request format",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	submission, err := services.CreateManualSubmission(h.db, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": "Failed to create manual submission",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.processStage3(ctx, submission.ID); err != nil {
+		if statusErr := h.updateSubmissionStatus(submission.ID, "error"); statusErr != nil {
+			log.Printf("Failed to mark manual submission %s as error: %v", submission.ID, statusErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to process submission",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	if err := h.updateSubmissionStatus(submission.ID, "done"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to update submission status",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Event submitted for review",
+		"submissionId": submission.ID.String(),
+		"status":       "done",
+		"source":       services.SubmissionSourceManual,
+	})
+}
+
+// RetrySubmission re-runs processing for a failed submission, discarding its earlier results.
+// Uploads go back to the workers and get a 202; manual entries are re-scored inline.
+// POST /admin/submissions/:id/retry, POST /admin/api/submissions/:id/retry
+func (h *UploadHandler) RetrySubmission(c *gin.Context) {
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+
+	var submission models.Submission
+	if err := h.db.First(&submission, "id = ?", submissionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+	if submission.Status == "processing" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Submission is already processing"})
+		return
+	}
+
+	if submission.Source != services.SubmissionSourceManual {
+		// Clear earlier results so vision output isn't duplicated
+		if err := services.ClearSubmissionResults(h.db, submissionID); err != nil {
+			if errors.Is(err, services.ErrSubmissionHasPublished) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Submission already has published events; unpublish them first"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear previous results"})
+			return
+		}
+
+		// Marked "processing" before it is enqueued, as Enqueue requires; a retry that
+		// raced this one to it gets the 409
+		result := h.db.Model(&models.Submission{}).
+			Where("id = ? AND status <> ?", submissionID, "processing").
+			Updates(map[string]interface{}{
+				"status":     "processing",
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue submission for processing"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Submission is already processing"})
+			return
+		}
+		h.workers.Enqueue(submissionID)
+		c.JSON(http.StatusAccepted, gin.H{
+			"submissionId": submissionID.String(),
+			"status":       "processing",
+		})
+		return
+	}
+
+	// Manual entries have no image; re-run Stage 3 on the typed-in candidate
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.processStage3(ctx, submissionID); err != nil {
+		_ = h.updateSubmissionStatus(submissionID, "error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Retry failed: " + err.Error()})
+		return
+	}
+	if err := h.updateSubmissionStatus(submissionID, "done"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update submission status"})
+		return
+	}
+
+	if err := h.db.First(&submission, "id = ?", submissionID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload submission"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"submissionId": submission.ID.String(),
+		"status":       submission.Status,
+	})
+}
+
+// ReprocessSubmission runs a submission through the pipeline again, with an audit log entry.
+// By default its flyers and candidates are deleted and the stored image goes back to the
+// workers; ?stage=stage3 keeps them and re-runs only moderation and geocoding, without
+// another vision call. The submission is claimed before either runs, so a second call while
+// one is in flight gets a 409. Responds with the submission's status payload.
+// POST /admin/submissions/:id/reprocess, POST /admin/api/submissions/:id/reprocess
+func (h *UploadHandler) ReprocessSubmission(c *gin.Context) {
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+	stage := c.DefaultQuery("stage", services.ReprocessStageAll)
+	if stage != services.ReprocessStageAll && stage != services.ReprocessStageStage3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stage must be all or stage3"})
+		return
+	}
+
+	var submission models.Submission
+	if err := h.db.Select("id", "source").First(&submission, "id = ?", submissionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+	if submission.Source == services.SubmissionSourceManual && stage == services.ReprocessStageAll {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Manual submissions have no image; reprocess with stage=stage3"})
+		return
+	}
+
+	if err := services.ClaimSubmissionForReprocess(h.db, submissionID, stage, adminActor(c)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrSubmissionProcessing):
+			c.JSON(http.StatusConflict, gin.H{"error": "Submission is already processing"})
+		case errors.Is(err, services.ErrSubmissionHasPublished):
+			c.JSON(http.StatusConflict, gin.H{"error": "Submission already has published events; unpublish them first"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset submission"})
+		}
+		return
+	}
+
+	if stage == services.ReprocessStageStage3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		if err := h.processStage3(ctx, submissionID); err != nil {
+			// Reported through the status payload rather than failing the request
+			if statusErr := h.updateSubmissionStatus(submissionID, "error"); statusErr != nil {
+				log.Printf("Failed to mark submission %s errored: %v", submissionID, statusErr)
+			}
+			services.RecordSubmissionError(h.db, submissionID, err)
+		} else if err := h.updateSubmissionStatus(submissionID, "done"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update submission status"})
+			return
+		}
+	} else {
+		// Already marked "processing" by the claim, as Enqueue requires
+		h.workers.Enqueue(submissionID)
+	}
+
+	if err := h.db.Preload("Flyers.EventCandidates").First(&submission, "id = ?", submissionID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload submission"})
+		return
+	}
+	status := buildSubmissionStatus(&submission)
+	if !services.IsTerminalSubmissionStatus(submission.Status) {
+		backpressure := h.queue.Estimate(submissionID)
+		status.Backpressure = &backpressure
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// errNotAwaitingRetry is returned for candidates outside the Stage 3 retry queue
+var errNotAwaitingRetry = errors.New("candidate is not awaiting a Stage 3 retry")
+
+// RetryCandidate re-runs Stage 3 for a candidate held back by a transient failure.
+// Used by the retry sweeper and the admin force-retry endpoint.
+func (h *UploadHandler) RetryCandidate(ctx context.Context, candidateID uuid.UUID) error {
+	var candidate models.EventCandidate
+	if err := h.db.First(&candidate, "id = ?", candidateID).Error; err != nil {
+		return err
+	}
+	if !services.IsAwaitingRetry(&candidate) {
+		return errNotAwaitingRetry
+	}
+
+	// Typed-in events are never auto-published
+	var submission models.Submission
+	if err := h.db.Select("submissions.source").
+		Joins("JOIN flyers ON flyers.submission_id = submissions.id").
+		Where("flyers.id = ?", candidate.FlyerID).
+		First(&submission).Error; err != nil {
+		return fmt.Errorf("failed to fetch submission: %w", err)
+	}
+
+	log.Printf("Retrying Stage 3 for candidate %s (attempt %d, %s)", candidate.ID, candidate.RetryAttempts+1, *candidate.ReviewReason)
+	return h.processEventCandidate(ctx, &candidate, submission.Source != services.SubmissionSourceManual)
+}
+
+// GetGeocodingStats reports how often slow geocoding requests were hedged since startup
+// GET /admin/api/stats/geocoding
+func (h *UploadHandler) GetGeocodingStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"hedge_delay_ms":    h.config.GeocodeHedgeDelayMS,
+		"hedge_max_per_min": h.config.GeocodeHedgeMaxPerMin,
+		"hedging":           h.geocoding.HedgeStats(),
+	})
+}
+
+// ListRetries returns candidates waiting for a Stage 3 retry and the breaker states
+// GET /admin/api/retries
+func (h *UploadHandler) ListRetries(c *gin.Context) {
+	candidates, err := services.ListPendingRetries(h.db, 200)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list retries"})
+		return
+	}
+
+	retries := make([]gin.H, 0, len(candidates))
+	for _, candidate := range candidates {
+		retries = append(retries, gin.H{
+			"candidate_id":   candidate.ID,
+			"review_reason":  candidate.ReviewReason,
+			"retry_attempts": candidate.RetryAttempts,
+			"retry_after":    candidate.RetryAfter,
+			"last_error":     candidate.LastRetryError,
+			"created_at":     candidate.CreatedAt,
+		})
+	}
+
+	breakers := make([]gin.H, 0, 2)
+	for _, breaker := range h.Breakers() {
+		breakers = append(breakers, gin.H{"name": breaker.Name(), "open": !breaker.Allow()})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"retries":      retries,
+		"count":        len(retries),
+		"max_attempts": h.config.RetryMaxAttempts,
+		"breakers":     breakers,
+	})
+}
+
+// ForceRetryCandidate re-runs Stage 3 now, ignoring the candidate's backoff. Candidates
+// whose retries ran out can be forced too.
+// POST /admin/api/retries/:id
+func (h *UploadHandler) ForceRetryCandidate(c *gin.Context) {
+	candidateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid candidate ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if err := h.RetryCandidate(ctx, candidateID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Candidate not found"})
+		case errors.Is(err, errNotAwaitingRetry):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Retry failed: " + err.Error()})
+		}
+		return
+	}
+
+	var candidate models.EventCandidate
+	if err := h.db.First(&candidate, "id = ?", candidateID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload candidate"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"candidate_id":       candidate.ID,
+		"publish_result":     candidate.PublishResult,
+		"publication_reason": candidate.PublicationReason,
+		"review_reason":      candidate.ReviewReason,
+		"retry_attempts":     candidate.RetryAttempts,
+		"retry_after":        candidate.RetryAfter,
+		"published_event_id": candidate.PublishedEventID,
+	})
+}
+
+// ReextractFlyer re-reads one flyer from its crop and re-runs Stage 3 for its new candidates only.
+// The rest of the submission, including its status, is unchanged.
+// POST /admin/flyers/:id/reextract
+func (h *UploadHandler) ReextractFlyer(c *gin.Context) {
+	flyerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flyer ID"})
+		return
+	}
+
+	var flyer models.Flyer
+	if err := h.db.Preload("Submission").First(&flyer, "id = ?", flyerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flyer not found"})
+		return
+	}
+
+	// Fail fast before paying for a vision call; ReplaceFlyerCandidates re-checks in its transaction
+	var published int64
+	if err := h.db.Model(&models.EventCandidate{}).
+		Where("flyer_id = ? AND published_event_id IS NOT NULL", flyerID).
+		Count(&published).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if published > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Flyer already has published events; unpublish them first"})
+		return
+	}
+
+	cropPath, err := services.FlyerCropPath(h.storage, &flyer)
+	if err != nil {
+		if errors.Is(err, services.ErrFlyerCropUnavailable) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Flyer has no image to re-extract"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to crop flyer"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
 
-// UploadFile handles direct file upload
-// PUT /v1/uploads/{id}
-func (h *UploadHandler) UploadFile(c *gin.Context) {
-	submissionIDStr := c.Param("id")
-	submissionID, err := uuid.Parse(submissionIDStr)
+	// Re-extraction stays on the model the submission's experiment variant uses
+	model, err := services.ResolveVisionModel(h.db, h.config, flyer.SubmissionID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "Invalid submission ID",
-			},
-		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve vision model"})
+		return
+	}
+	result, usage, err := h.vision.ExtractFlyerEvents(ctx, cropPath, model)
+	if usageErr := services.RecordVisionUsage(h.db, flyer.SubmissionID, usage); usageErr != nil {
+		log.Printf("Failed to record vision usage for submission %s: %v", flyer.SubmissionID, usageErr)
+	}
+	if errors.Is(err, services.ErrVisionBusy) {
+		c.Header("Retry-After", strconv.Itoa(h.config.VisionSlotWaitSec))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Vision is at capacity; try again shortly"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Vision analysis failed: " + err.Error()})
 		return
 	}
 
-	// Check if submission exists
-	var submission models.Submission
-	if err := h.db.First(&submission, "id = ?", submissionID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"message": "Submission not found",
-			},
-		})
+	candidates, err := services.ReplaceFlyerCandidates(h.db, flyerID, result, adminActor(c))
+	if err != nil {
+		if errors.Is(err, services.ErrFlyerHasPublishedCandidates) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Flyer already has published events; unpublish them first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save candidates"})
 		return
 	}
+	if err := services.RecordFlyerVisionModel(h.db, flyerID, usage); err != nil {
+		log.Printf("Failed to record vision model for flyer %s: %v", flyerID, err)
+	}
+
+	allowAutoPublish := flyer.Submission.Source != services.SubmissionSourceManual
+	results := make([]CandidateStatusResult, 0, len(candidates))
+	for i := range candidates {
+		if err := h.processEventCandidate(ctx, &candidates[i], allowAutoPublish); err != nil {
+			log.Printf("Failed to process event candidate %s: %v", candidates[i].ID, err)
+		}
+
+		candidateResult := CandidateStatusResult{CandidateID: candidates[i].ID.String()}
+		if candidates[i].PublishResult != nil {
+			candidateResult.Decision = *candidates[i].PublishResult
+		}
+		if candidates[i].CompositeScore != nil {
+			candidateResult.Score = *candidates[i].CompositeScore
+		}
+		results = append(results, candidateResult)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"flyerId":    flyer.ID.String(),
+		"candidates": results,
+	})
+}
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+// storeUploadedImage saves one uploaded photo as the submission's image index and converts a
+// HEIC upload to JPEG, so every stored photo is a real JPEG. When sum is set the bytes are
+// hashed as they arrive. On failure it writes the error response and returns false.
+func (h *UploadHandler) storeUploadedImage(c *gin.Context, submissionID uuid.UUID, index int, header *multipart.FileHeader, sum hash.Hash) bool {
+	file, err := header.Open()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
@@ -140,95 +1018,208 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 				"details": err.Error(),
 			},
 		})
-		return
+		return false
 	}
 	defer file.Close()
 
-	// Validate file size (12MB max)
-	if header.Size > 12*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "File too large. Maximum size is 12MB",
-			},
-		})
-		return
+	var data io.Reader = file
+	if sum != nil {
+		data = io.TeeReader(file, sum)
 	}
-
-	// Save file
-	if err := h.storage.SaveFile(submissionID, "original.jpg", file); err != nil {
+	filename := services.SubmissionImageFilename(index)
+	if err := h.storage.SaveFile(submissionID, filename, data); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
 				"message": "Failed to save file",
 			},
 		})
-		return
+		return false
 	}
 
-	// Process immediately (synchronous)
-	if err := h.processUploadSync(submissionID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// iPhones upload HEIC; convert it once here so the stored original is a real JPEG
+	imagePath := h.storage.GetFilePath(submissionID, filename)
+	if err := services.TranscodeHEICOriginal(c.Request.Context(), h.config, imagePath); err != nil {
+		log.Printf("Failed to convert HEIC upload %d for submission %s: %v", index, submissionID, err)
+		os.Remove(imagePath)
+		if errors.Is(err, services.ErrHEICUnsupported) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": gin.H{
+					"code":    "heic_unsupported",
+					"message": "HEIC images are not supported here; please upload a JPEG or PNG",
+					"details": "this server has no HEIC converter installed",
+				},
+			})
+			return false
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"error": gin.H{
-				"message": "Failed to process image",
-				"details": err.Error(),
+				"message": "Could not read HEIC image",
 			},
 		})
-		return
+		return false
 	}
+	return true
+}
 
-	// Get results after processing
-	if err := h.db.Preload("Flyers.EventCandidates").First(&submission, "id = ?", submissionID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": "Failed to retrieve results",
-			},
-		})
-		return
-	}
+// processUploadSync runs a stored upload through GPT-4o Vision and Stage 3. Background
+// workers call it for uploads, cancelling parent on shutdown; admin retries call it inline.
+// The run is traced, with a child span per vision call, moderation, and geocode.
+func (h *UploadHandler) processUploadSync(parent context.Context, submissionID uuid.UUID) (err error) {
+	parent, span := observability.Start(parent, "upload.process", observability.String("submission.id", submissionID.String()))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 
-	// Count found events
-	eventCount := 0
-	for _, flyer := range submission.Flyers {
-		eventCount += len(flyer.EventCandidates)
-	}
+	// Wait for a processing slot
+	release := h.queue.Acquire(submissionID)
+	defer release()
+	started := time.Now()
+	defer func() { h.metrics.ObserveStage("total", time.Since(started)) }()
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":       "Image processed successfully",
-		"submissionId":  submissionID.String(),
-		"status":        submission.Status,
-		"eventsFound":   eventCount,
-		"flyersFound":   len(submission.Flyers),
-	})
-}
+	// The worker pool may have shut down while this waited
+	if err := parent.Err(); err != nil {
+		return err
+	}
 
-// processUploadSync processes the upload synchronously with GPT-4o Vision
-func (h *UploadHandler) processUploadSync(submissionID uuid.UUID) error {
 	// Update status to processing
 	if err := h.updateSubmissionStatus(submissionID, "processing"); err != nil {
 		return err
 	}
 
-	// Get the image file path
-	imagePath := h.storage.GetFilePath(submissionID, "original.jpg")
+	// Get the first photo's path; later photos were made upright on upload, and are fetched
+	// from remote storage as they are read
+	imagePath, err := h.storage.Fetch(submissionID, services.SubmissionOriginalFilename)
+	if err != nil {
+		if statusErr := h.updateSubmissionStatus(submissionID, "error"); statusErr != nil {
+			return fmt.Errorf("failed to fetch original: %w, status update failed: %v", err, statusErr)
+		}
+		return fmt.Errorf("failed to fetch original: %w", err)
+	}
+
+	// Originals stored before HEIC was converted on arrival are converted now
+	if err := services.TranscodeHEICOriginal(parent, h.config, imagePath); err != nil {
+		log.Printf("Failed to convert HEIC original for submission %s: %v", submissionID, err)
+	}
+
+	// Originals stored before uploads were rotated on arrival may still carry an EXIF
+	// orientation; rotate them now so polygons match the frame the model sees
+	if err := services.NormalizeSubmissionImage(h.db, submissionID, imagePath); err != nil {
+		log.Printf("Failed to normalize orientation for submission %s: %v", submissionID, err)
+	}
+	if err := services.StripExifUnlessOptedIn(h.db, submissionID, imagePath); err != nil {
+		log.Printf("Failed to strip EXIF for submission %s: %v", submissionID, err)
+	}
+	if err := h.storage.Persist(submissionID, services.SubmissionOriginalFilename); err != nil {
+		log.Printf("Failed to store converted original for submission %s: %v", submissionID, err)
+	}
+
+	// Likewise for thumbnails and derivatives; regenerating keeps them in step with a rotated original
+	if err := services.RecordSubmissionThumbnail(h.db, h.storage, submissionID, imagePath); err != nil {
+		log.Printf("Failed to generate thumbnail for submission %s: %v", submissionID, err)
+	}
+	if err := services.RecordSubmissionDerivative(h.db, h.storage, h.config, submissionID, imagePath); err != nil {
+		log.Printf("Failed to generate derivative for submission %s: %v", submissionID, err)
+	}
 	
 	// Process with GPT-4o Vision directly
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(parent, 90*time.Second)
 	defer cancel()
 	
-	result, err := h.vision.AnalyzeImage(ctx, submissionID, imagePath)
+	// The experiment variant, if any, is sticky so retries use the same model
+	model, err := services.ResolveVisionModel(h.db, h.config, submissionID)
+	span.SetAttributes(observability.String("llm.model", model))
 	if err != nil {
-		// Update status to error
 		if statusErr := h.updateSubmissionStatus(submissionID, "error"); statusErr != nil {
-			return fmt.Errorf("vision analysis failed: %w, status update failed: %v", err, statusErr)
+			return fmt.Errorf("failed to resolve vision model: %w, status update failed: %v", err, statusErr)
 		}
-		return fmt.Errorf("vision analysis failed: %w", err)
+		return fmt.Errorf("failed to resolve vision model: %w", err)
 	}
 
-	// Save vision results to database
-	if err := h.vision.SaveResults(h.db, submissionID, result); err != nil {
-		if statusErr := h.updateSubmissionStatus(submissionID, "error"); statusErr != nil {
-			return fmt.Errorf("failed to save results: %w, status update failed: %v", err, statusErr)
+	var submission models.Submission
+	if err := h.db.Select("id", "image_count").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return fmt.Errorf("failed to load submission: %w", err)
+	}
+	if err := h.setImagesProcessed(submissionID, 0); err != nil {
+		return err
+	}
+
+	// Each photo is read on its own; flyers record which one their polygon is in
+	flyersDetected, allPoor := 0, true
+	for index := 1; index <= max(submission.ImageCount, 1); index++ {
+		visionCtx, visionSpan := observability.Start(ctx, "upload.vision",
+			observability.String("submission.id", submissionID.String()),
+			observability.Int("image.index", index),
+			observability.String("llm.model", model))
+		visionStarted := time.Now()
+		result, usage, err := h.vision.AnalyzeImage(visionCtx, submissionID, index, model)
+		h.metrics.ObserveStage("vision", time.Since(visionStarted))
+		if provider, name := services.SplitVisionModel(usage.Model, h.config.VisionProvider); provider == services.VisionProviderOpenAI {
+			h.metrics.OpenAITokens(name, usage.PromptTokens, usage.CompletionTokens)
+		}
+		visionSpan.RecordError(err)
+		if result != nil {
+			visionSpan.SetAttributes(observability.Int("flyer.count", len(result.FlyersDetected)))
+		}
+		visionSpan.End()
+		if usageErr := services.RecordVisionUsage(h.db, submissionID, usage); usageErr != nil {
+			log.Printf("Failed to record vision usage for submission %s: %v", submissionID, usageErr)
+		}
+		if errors.Is(err, services.ErrVisionBusy) {
+			// Still "processing"; the caller queues it again instead of failing it
+			return fmt.Errorf("vision analysis of image %d deferred: %w", index, err)
+		}
+		if err != nil {
+			// Update status to error
+			if statusErr := h.updateSubmissionStatus(submissionID, "error"); statusErr != nil {
+				return fmt.Errorf("vision analysis of image %d failed: %w, status update failed: %v", index, err, statusErr)
+			}
+			return fmt.Errorf("vision analysis of image %d failed: %w", index, err)
+		}
+
+		flyersDetected += len(result.FlyersDetected)
+		if result.ImageQuality != services.ImageQualityPoor {
+			allPoor = false
+		}
+
+		// Save vision results to database
+		if err := h.vision.SaveResults(h.db, submissionID, index, result, usage); err != nil {
+			if statusErr := h.updateSubmissionStatus(submissionID, "error"); statusErr != nil {
+				return fmt.Errorf("failed to save results: %w, status update failed: %v", err, statusErr)
+			}
+			return fmt.Errorf("failed to save results: %w", err)
+		}
+
+		// Links printed only as QR codes are decoded from the photo rather than trusted to the model
+		if h.config.QRScanEnabled && len(result.FlyersDetected) > 0 {
+			if updated, err := services.AttachQRCodeLinks(h.db, h.storage, submissionID, index); err != nil {
+				log.Printf("Failed to scan QR codes in image %d of submission %s: %v", index, submissionID, err)
+			} else if updated > 0 {
+				log.Printf("Attached QR code links to %d candidates from image %d of submission %s", updated, index, submissionID)
+			}
+		}
+
+		if err := h.setImagesProcessed(submissionID, index); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to save results: %w", err)
+	}
+
+	// Nothing readable in a poor photo; ask for a retake rather than moderating an empty result
+	if h.config.RejectPoorQualityImages && allPoor && flyersDetected == 0 {
+		log.Printf("Submission %s rejected: no flyers found in poor-quality image", submissionID)
+		return h.updateSubmissionStatus(submissionID, services.SubmissionStatusRejectedQuality)
+	}
+
+	// Overlapping photos show the same flyers; keep each event once, from the earliest photo
+	if removed, err := services.DedupeSubmissionCandidates(h.db, submissionID, services.RegionLocation(h.config)); err != nil {
+		log.Printf("Failed to dedupe candidates across images for submission %s: %v", submissionID, err)
+	} else if removed > 0 {
+		log.Printf("Removed %d candidates repeated across images of submission %s", removed, submissionID)
+	}
+
+	// Crop each flyer now so review and re-extraction don't wait on it
+	if err := h.crops.CropSubmission(ctx, submissionID); err != nil {
+		log.Printf("Failed to crop flyers for submission %s: %v", submissionID, err)
 	}
 
 	// Update status to parsed (Stage 2 complete)
@@ -264,11 +1255,19 @@ func (h *UploadHandler) processStage3(ctx context.Context, submissionID uuid.UUI
 		return fmt.Errorf("failed to fetch event candidates: %w", err)
 	}
 
+	// Typed-in events are never auto-published
+	var submission models.Submission
+	if err := h.db.Select("source").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return fmt.Errorf("failed to fetch submission: %w", err)
+	}
+	allowAutoPublish := submission.Source != services.SubmissionSourceManual
+
 	log.Printf("Processing Stage 3 for %d event candidates", len(eventCandidates))
+	observability.SpanFromContext(ctx).SetAttributes(observability.Int("candidate.count", len(eventCandidates)))
 
 	// Process each event candidate
 	for _, candidate := range eventCandidates {
-		if err := h.processEventCandidate(ctx, &candidate); err != nil {
+		if err := h.processEventCandidate(ctx, &candidate, allowAutoPublish); err != nil {
 			log.Printf("Failed to process event candidate %s: %v", candidate.ID, err)
 			// Continue processing other candidates even if one fails
 			continue
@@ -278,25 +1277,170 @@ func (h *UploadHandler) processStage3(ctx context.Context, submissionID uuid.UUI
 	return nil
 }
 
-// processEventCandidate processes a single event candidate through moderation and geocoding
-func (h *UploadHandler) processEventCandidate(ctx context.Context, candidate *models.EventCandidate) error {
+// processEventCandidate processes a single event candidate through moderation and geocoding.
+// When allowAutoPublish is false, candidates that would have auto-published go to review instead.
+func (h *UploadHandler) processEventCandidate(ctx context.Context, candidate *models.EventCandidate, allowAutoPublish bool) error {
+	// Retries re-run this for candidates already in review; they only notify once
+	wasInReview := candidate.PublishResult != nil && *candidate.PublishResult == "needs_review"
+
 	// Parse event fields from JSON
 	var eventData map[string]interface{}
 	if err := json.Unmarshal([]byte(candidate.Fields), &eventData); err != nil {
 		return fmt.Errorf("failed to parse event fields: %w", err)
 	}
 
+	// *** ENRICHMENT ***
+	// Flyers that only point at a link or QR code can be filled in from the page's metadata
+	needsEnrichment := services.NeedsEnrichment(eventData)
+	if needsEnrichment && h.enrichment.Enabled() {
+		enrichmentURL := services.EnrichmentURL(eventData)
+		log.Printf("Enriching event candidate %s from %s", candidate.ID, enrichmentURL)
+		enrichmentResult, err := h.enrichment.EnrichFromURL(ctx, enrichmentURL)
+		if err != nil {
+			log.Printf("Enrichment failed for %s: %v", candidate.ID, err)
+		} else if merged := services.MergeEnrichment(eventData, enrichmentResult); len(merged) > 0 {
+			fieldsJSON, err := json.Marshal(eventData)
+			if err != nil {
+				return fmt.Errorf("failed to marshal enriched fields: %w", err)
+			}
+			candidate.Fields = string(fieldsJSON)
+			candidate.SearchText = services.CandidateSearchText(candidate.Fields)
+			needsEnrichment = services.NeedsEnrichment(eventData)
+			log.Printf("Enriched candidate %s with fields %v", candidate.ID, merged)
+		}
+	}
+
 	// *** MODERATION ***
+	// Transient failures (timeouts, rate limits, open breakers) are retried by the sweeper
+	var retryReason string
+	var retryCause error
 	log.Printf("Moderating event candidate %s", candidate.ID)
-	moderationResult, err := h.moderation.ModerateEventCandidate(ctx, eventData)
+	var moderationResult *services.ModerationResult
+	err := h.moderationBreaker.Call(func() error {
+		moderationCtx, span := observability.Start(ctx, "upload.moderation", observability.String("candidate.id", candidate.ID.String()))
+		defer span.End()
+		started := time.Now()
+		var err error
+		moderationResult, err = h.moderation.ModerateEventCandidate(moderationCtx, eventData)
+		h.metrics.ObserveStage("moderation", time.Since(started))
+		span.RecordError(err)
+		if moderationResult != nil {
+			span.SetAttributes(observability.String("llm.model", moderationResult.Model))
+			h.metrics.OpenAITokens(moderationResult.Model, moderationResult.PromptTokens, moderationResult.CompletionTokens)
+		}
+		return err
+	})
 	if err != nil {
 		log.Printf("Moderation failed for %s: %v", candidate.ID, err)
+		if services.IsRetryableError(err) {
+			retryReason = services.ReviewReasonModerationUnavailable
+			retryCause = err
+		}
 		// Use default values if moderation fails
 		moderationResult = &services.ModerationResult{
 			QualityScore:  0.5,
 			IsAppropriate: true,
 		}
 	}
+	if err := services.RecordServedModel(h.db, services.ModelPurposeModeration, moderationResult.Model, moderationResult.ServedModel, moderationResult.SystemFingerprint); err != nil {
+		log.Printf("Failed to record moderation model for %s: %v", candidate.ID, err)
+	}
+	if err := services.RecordModerationUsage(h.db, candidate.FlyerID, moderationResult); err != nil {
+		log.Printf("Failed to record moderation usage for %s: %v", candidate.ID, err)
+	}
+
+	// *** GEOCODING ***
+	// Looked up before the publish decision so a transient failure can hold the candidate back.
+	// Results are biased toward, and checked against, where the photo was taken.
+	capture, err := services.CandidateCapture(h.db, candidate)
+	if err != nil {
+		log.Printf("Failed to load capture metadata for %s: %v", candidate.ID, err)
+	}
+	venueAddress := extractVenueAddress(eventData)
+	var geocodeResult *services.GeocodeResult
+	implausibleDistance := false
+	if venueAddress != "" {
+		log.Printf("Geocoding venue address for %s: %s", candidate.ID, venueAddress)
+		err := h.geocodeBreaker.Call(func() error {
+			geocodeCtx, span := observability.Start(ctx, "upload.geocoding", observability.String("candidate.id", candidate.ID.String()))
+			defer span.End()
+			started := time.Now()
+			var err error
+			geocodeResult, err = h.geocoding.GeocodeAddress(geocodeCtx, venueAddress, capture.Location)
+			h.metrics.ObserveStage("geocoding", time.Since(started))
+			span.RecordError(err)
+			return err
+		})
+		if err != nil {
+			log.Printf("Geocoding failed for %s: %v", candidate.ID, err)
+			geocodeResult = nil
+			if retryReason == "" && services.IsRetryableError(err) {
+				retryReason = services.ReviewReasonGeocodeFailed
+				retryCause = err
+			}
+		} else {
+			h.metrics.GeocodeConfidence(geocodeResult.Confidence)
+
+			// Store geocoding result
+			geocodeJSON, _ := json.Marshal(geocodeResult)
+			geocodeStr := string(geocodeJSON)
+			candidate.Geocode = &geocodeStr
+
+			if capture.Location != nil && h.config.ImplausibleDistanceKm > 0 {
+				distance := services.DistanceKm(*capture.Location, geocodeResult.Latitude, geocodeResult.Longitude)
+				if distance > h.config.ImplausibleDistanceKm {
+					log.Printf("Geocode for %s is %.0fkm from the %s capture location", candidate.ID, distance, capture.LocationSource)
+					implausibleDistance = true
+				}
+			}
+		}
+	} else if capture.Location != nil && h.config.CaptureLocationFallback {
+		// No venue at all: place the event where the photo was taken, at a confidence too low
+		// to create a venue from
+		err := h.geocodeBreaker.Call(func() error {
+			geocodeCtx, span := observability.Start(ctx, "upload.geocoding",
+				observability.String("candidate.id", candidate.ID.String()),
+				observability.String("geocode.kind", "capture_fallback"))
+			defer span.End()
+			started := time.Now()
+			var err error
+			geocodeResult, err = h.geocoding.CaptureFallbackGeocode(geocodeCtx, *capture.Location)
+			h.metrics.ObserveStage("geocoding", time.Since(started))
+			span.RecordError(err)
+			return err
+		})
+		if err != nil {
+			log.Printf("Reverse geocoding the capture location failed for %s: %v", candidate.ID, err)
+			geocodeResult = nil
+		} else {
+			log.Printf("No venue address for %s; placed %s", candidate.ID, geocodeResult.FormattedAddress)
+			geocodeJSON, _ := json.Marshal(geocodeResult)
+			geocodeStr := string(geocodeJSON)
+			candidate.Geocode = &geocodeStr
+		}
+	}
+
+	// Per-category overrides of the threshold and start window apply to the normalized category
+	category := services.CandidateCategory(eventData)
+	policy, err := services.ResolvePublishPolicy(h.db, h.config, category)
+	if err != nil {
+		log.Printf("Failed to resolve publish policy for %s, using defaults: %v", candidate.ID, err)
+		policy = services.DefaultPublishPolicy(h.db, h.config)
+		policy.Category = category
+	}
+	start, _, startParsed := services.ParseCandidateStart(h.db, candidate, eventData, services.RegionLocation(h.config))
+	outsideWindow := startParsed && !policy.InWindow(start.Time, time.Now())
+
+	// A deleted event's canonical key stays out of auto-publishing for the cooldown, so a
+	// takedown isn't undone by the next photo of the same flyer
+	recentlyDeleted := false
+	if title, _ := eventData["title"].(string); startParsed && title != "" {
+		tombstone, err := services.RecentlyDeletedEvent(h.db, h.config, services.EventCanonicalKey(title, start.Time))
+		if err != nil {
+			log.Printf("Failed to check deleted events for %s, holding for review: %v", candidate.ID, err)
+		}
+		recentlyDeleted = err != nil || tombstone != nil
+	}
 
 	// Store composite score and publish decision
 	candidate.CompositeScore = &moderationResult.QualityScore
@@ -305,51 +1449,129 @@ func (h *UploadHandler) processEventCandidate(ctx context.Context, candidate *mo
 		blocked := "blocked"
 		candidate.PublishResult = &blocked
 		candidate.PublicationReason = moderationResult.ModerationReason
-	} else if moderationResult.QualityScore >= h.config.AutoPublishThreshold {
+	} else if retryReason != "" {
+		needsReview := "needs_review"
+		candidate.PublishResult = &needsReview
+		candidate.ReviewReason = &retryReason
+		maxAttempts := h.config.RetryMaxAttempts
+		baseDelay := time.Duration(h.config.RetryBaseDelaySec) * time.Second
+		var reason string
+		if services.ScheduleRetry(candidate, retryCause, maxAttempts, baseDelay, time.Now()) {
+			reason = fmt.Sprintf("retry %d/%d scheduled (%s)", candidate.RetryAttempts, maxAttempts, retryReason)
+		} else {
+			reason = fmt.Sprintf("requires manual review (%s after %d attempts)", retryReason, candidate.RetryAttempts)
+		}
+		candidate.PublicationReason = &reason
+	} else if implausibleDistance {
+		needsReview := "needs_review"
+		candidate.PublishResult = &needsReview
+		reason := "requires manual review (venue is far from where the photo was taken)"
+		candidate.PublicationReason = &reason
+		reviewReason := services.ReviewReasonImplausibleDistance
+		candidate.ReviewReason = &reviewReason
+	} else if needsEnrichment {
+		needsReview := "needs_review"
+		candidate.PublishResult = &needsReview
+		reason := "requires enrichment (details only available online)"
+		candidate.PublicationReason = &reason
+		reviewReason := services.ReviewReasonNeedsEnrichment
+		candidate.ReviewReason = &reviewReason
+	} else if !allowAutoPublish {
+		needsReview := "needs_review"
+		candidate.PublishResult = &needsReview
+		reason := "requires manual review (manual submission)"
+		candidate.PublicationReason = &reason
+		reviewReason := services.ReviewReasonManualSubmission
+		candidate.ReviewReason = &reviewReason
+	} else if moderationResult.QualityScore >= policy.Threshold && outsideWindow {
+		needsReview := "needs_review"
+		candidate.PublishResult = &needsReview
+		reason := "requires manual review (start date outside the auto-publish window)"
+		candidate.PublicationReason = &reason
+		reviewReason := services.ReviewReasonOutsidePublishWindow
+		candidate.ReviewReason = &reviewReason
+	} else if moderationResult.QualityScore >= policy.Threshold && recentlyDeleted {
+		needsReview := "needs_review"
+		candidate.PublishResult = &needsReview
+		reason := "requires manual review (matches a recently deleted event)"
+		candidate.PublicationReason = &reason
+		reviewReason := services.ReviewReasonRecentlyDeleted
+		candidate.ReviewReason = &reviewReason
+	} else if moderationResult.QualityScore >= policy.Threshold {
 		published := "published"
 		candidate.PublishResult = &published
 		reason := "auto-published (high quality score)"
 		candidate.PublicationReason = &reason
 		
-		// Auto-promote to public event
-		if err := h.promoteToPublicEvent(h.db, candidate); err != nil {
+		// Auto-promote to public event (event, change feed, and history commit together)
+		if err := h.db.Transaction(func(tx *gorm.DB) error {
+			return h.promoteToPublicEvent(ctx, tx, candidate)
+		}); err != nil {
 			log.Printf("Failed to promote auto-published candidate %s to public event: %v", candidate.ID, err)
+			candidate.PublishedEventID = nil // the event was rolled back
 			// Don't fail the entire process, just log the error
+		} else {
+			h.metrics.EventPublished("auto")
 		}
 	} else {
 		needsReview := "needs_review"
 		candidate.PublishResult = &needsReview
 		reason := "requires manual review (low quality score)"
 		candidate.PublicationReason = &reason
+		reviewReason := services.ReviewReasonLowScore
+		candidate.ReviewReason = &reviewReason
 	}
 
-	// *** GEOCODING ***
-	venueAddress := extractVenueAddress(eventData)
-	if venueAddress != "" {
-		log.Printf("Geocoding venue address for %s: %s", candidate.ID, venueAddress)
-		geocodeResult, err := h.geocoding.GeocodeAddress(ctx, venueAddress)
-		if err != nil {
-			log.Printf("Geocoding failed for %s: %v", candidate.ID, err)
-		} else {
-			// Store geocoding result
-			geocodeJSON, _ := json.Marshal(geocodeResult)
-			geocodeStr := string(geocodeJSON)
-			candidate.Geocode = &geocodeStr
-			
-			// Create or update venue record if high confidence
-			if geocodeResult.Confidence >= h.config.GeoConfThreshold {
-				if err := h.createOrUpdateVenue(eventData, geocodeResult); err != nil {
-					log.Printf("Failed to create/update venue for %s: %v", candidate.ID, err)
-				}
-			}
+	if retryReason == "" {
+		services.ClearRetry(candidate)
+	}
+
+	// Create or update venue record if high confidence
+	if geocodeResult != nil && !geocodeResult.Approximate && !implausibleDistance && geocodeResult.Confidence >= services.SettingGeoConfThreshold.Get(h.db, h.config) {
+		if err := h.createOrUpdateVenue(eventData, geocodeResult); err != nil {
+			log.Printf("Failed to create/update venue for %s: %v", candidate.ID, err)
 		}
 	}
 
-	// Save updated candidate
-	if err := h.db.Save(candidate).Error; err != nil {
+	// Save updated candidate; one entering review queues its notification in the same transaction
+	enteredReview := *candidate.PublishResult == "needs_review" && !wasInReview
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(candidate).Error; err != nil {
+			return err
+		}
+		if !enteredReview {
+			return nil
+		}
+		title, _ := eventData["title"].(string)
+		return services.EnqueueOutbox(tx, services.OutboxTopicCandidateNeedsReview, candidate.ID, services.CandidateReviewPayload{
+			CandidateID:  candidate.ID,
+			Title:        title,
+			ReviewReason: stringValue(candidate.ReviewReason),
+			Reason:       stringValue(candidate.PublicationReason),
+		})
+	}); err != nil {
 		return fmt.Errorf("failed to save moderated candidate: %w", err)
 	}
 
+	// Record the automated decision so review latency can be measured
+	latency := time.Since(candidate.CreatedAt)
+	if err := services.RecordAudit(h.db, services.AuditEntry{
+		EntityType:      services.AuditEntityCandidate,
+		EntityID:        candidate.ID,
+		Action:          services.CandidateDecisionAction(*candidate.PublishResult),
+		Actor:           services.SystemActor(services.ActorAuto),
+		DecisionLatency: &latency,
+		Metadata: map[string]interface{}{
+			"score":                  candidate.CompositeScore,
+			"reason":                 candidate.PublicationReason,
+			"category":               policy.Category,
+			"auto_publish_threshold": policy.Threshold,
+			"threshold_source":       policy.Source,
+		},
+	}); err != nil {
+		log.Printf("Failed to audit decision for candidate %s: %v", candidate.ID, err)
+	}
+
 	log.Printf("Completed Stage 3 for candidate %s: score=%.2f, decision=%s", 
 		candidate.ID, *candidate.CompositeScore, *candidate.PublishResult)
 
@@ -436,122 +1658,138 @@ func (h *UploadHandler) createOrUpdateVenue(eventData map[string]interface{}, ge
 	return nil
 }
 
-// updateSubmissionStatus updates the submission status in the database
-func (h *UploadHandler) updateSubmissionStatus(submissionID uuid.UUID, status string) error {
-	return h.db.Model(&models.Submission{}).
-		Where("id = ?", submissionID).
-		Updates(map[string]interface{}{
-			"status":     status,
-			"updated_at": time.Now(),
-		}).Error
+// setImagesProcessed records how many of a submission's photos the vision model has read
+func (h *UploadHandler) setImagesProcessed(submissionID uuid.UUID, count int) error {
+	if err := h.db.Model(&models.Submission{}).Where("id = ?", submissionID).
+		Update("images_processed", count).Error; err != nil {
+		return fmt.Errorf("failed to record images processed: %w", err)
+	}
+	return nil
 }
 
-// promoteToPublicEvent creates an Event record from an approved EventCandidate
-func (h *UploadHandler) promoteToPublicEvent(db *gorm.DB, candidate *models.EventCandidate) error {
-	// Parse the fields JSON to extract event data
-	var fields map[string]interface{}
-	if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
-		return fmt.Errorf("failed to parse event fields: %v", err)
+// updateSubmissionStatus updates the submission status in the database and notifies waiting clients.
+// A finished submission's outbox entry commits with the status change.
+func (h *UploadHandler) updateSubmissionStatus(submissionID uuid.UUID, status string) error {
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Submission{}).
+			Where("id = ?", submissionID).
+			Updates(map[string]interface{}{
+				"status":     status,
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		if status != "done" {
+			return nil
+		}
+		return services.EnqueueOutbox(tx, services.OutboxTopicSubmissionDone, submissionID, services.SubmissionDonePayload{
+			SubmissionID: submissionID,
+			Status:       status,
+		})
+	}); err != nil {
+		return err
 	}
 
-	// Extract required title field
-	title, ok := fields["title"].(string)
-	if !ok || title == "" {
-		return fmt.Errorf("event title is required")
-	}
+	h.metrics.SubmissionStatus(status)
+	h.broker.Publish(submissionID, status)
+	return nil
+}
 
-	// Parse start time - try different formats
-	startTs := time.Now().Add(24 * time.Hour) // fallback to tomorrow to ensure future events
-	
-	// Check both "date" and "date_time" fields for compatibility
-	var dateStr string
-	if date, ok := fields["date"].(string); ok && date != "" {
-		dateStr = date
-	} else if dateTime, ok := fields["date_time"].(string); ok && dateTime != "" {
-		dateStr = dateTime
+// promoteToPublicEvent creates an Event record from an approved EventCandidate
+func (h *UploadHandler) promoteToPublicEvent(ctx context.Context, db *gorm.DB, candidate *models.EventCandidate) error {
+	draft, err := services.DraftEventFromCandidate(db, candidate, services.RegionLocation(h.config))
+	if err != nil {
+		return err
 	}
-	
-	if dateStr != "" {
-		log.Printf("Parsing date string: %s for event: %s", dateStr, title)
-		// Try parsing different date formats
-		formats := []string{
-			"2006-01-02T15:04:05",    // ISO format first (most common from LLM)
-			"2006-01-02 15:04:05",
-			"2006-01-02T15:04",
-			"2006-01-02 15:04",
-			"2006-01-02",
-			"January 2, 2006",
-			"Jan 2, 2006",
-		}
-		
-		parsed := false
-		for _, format := range formats {
-			if parsedTime, err := time.Parse(format, dateStr); err == nil {
-				log.Printf("Successfully parsed '%s' as '%s' using format '%s'", dateStr, parsedTime.String(), format)
-				// If the parsed date is in the past, assume it's for next year
-				if parsedTime.Before(time.Now()) {
-					parsedTime = parsedTime.AddDate(1, 0, 0)
-					log.Printf("Date was in past, moved to next year: %s", parsedTime.String())
-				}
-				startTs = parsedTime
-				parsed = true
-				break
+
+	if existingEvent := draft.Existing; existingEvent != nil {
+		// Event already exists; this candidate corroborates it (link is saved with the candidate)
+		candidate.PublishedEventID = &existingEvent.ID
+		if existingEvent.ModerationState != services.EventStateApproved {
+			if err := services.TransitionEventState(db, existingEvent.ID, services.EventTransition{
+				To:          services.EventStateApproved,
+				Actor:       services.ActorAuto,
+				Reason:      services.TransitionReasonRepublished,
+				CandidateID: &candidate.ID,
+			}); err != nil {
+				return err
 			}
+			if err := services.RecordEventHistoryByID(db, existingEvent.ID); err != nil {
+				return err
+			}
+			return services.RecordEventChange(db, existingEvent.ID, services.ChangeTypeUpdated, services.ChangeReasonRepublished)
 		}
-		
-		// If we couldn't parse the date, keep the fallback
-		if !parsed {
-			log.Printf("Failed to parse date '%s', using fallback", dateStr)
-			startTs = time.Now().Add(24 * time.Hour)
-		} else {
-			log.Printf("Final startTs for event '%s': %s", title, startTs.String())
-		}
+		log.Printf("Event already exists and is approved: %s", existingEvent.Title)
+		return nil // Already published
 	}
 
-	// Create canonical key for deduplication (title + date)
-	canonicalKey := strings.ToLower(strings.TrimSpace(title)) + "_" + startTs.Format("2006-01-02")
-
-	// Check if this event already exists
-	var existingEvent models.Event
-	if err := db.Where("canonical_key = ?", canonicalKey).First(&existingEvent).Error; err == nil {
-		// Event already exists, just update moderation state if needed
-		if existingEvent.ModerationState != "approved" {
-			return db.Model(&existingEvent).Update("moderation_state", "approved").Error
+	event := draft.Event
+	event.PublishedVia = "auto"
+	// Geocoding has usually saved the venue already; one it couldn't place is created bare
+	if draft.NewVenue {
+		if err := db.Create(draft.Venue).Error; err != nil {
+			return fmt.Errorf("failed to create venue: %v", err)
 		}
-		log.Printf("Event already exists and is approved: %s", title)
-		return nil // Already published
+		event.VenueID = &draft.Venue.ID
 	}
 
-	// Create new Event record
-	event := models.Event{
-		CanonicalKey:    canonicalKey,
-		Title:           title,
-		StartTs:         startTs,
-		Source:          "flyer",
-		PublishedVia:    "auto",
-		QualityScore:    candidate.CompositeScore,
-		ModerationState: "approved",
+	// The same show under a slightly different title is held back as a duplicate of the live
+	// event until an admin confirms or undoes the merge
+	duplicates, err := services.NewDedupeService(db, h.config).FindDuplicates(ctx, &event, services.CandidateLocation(candidate))
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return h.createMergedEvent(db, candidate, &event, &duplicates[0])
 	}
 
-	// Extract optional fields
-	if desc, ok := fields["description"].(string); ok && desc != "" {
-		event.Description = &desc
+	// Save the event
+	if err := db.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to create event: %v", err)
 	}
-	if url, ok := fields["url"].(string); ok && url != "" {
-		event.URL = &url
+	if err := services.RecordEventCreated(db, &event, services.EventTransition{
+		Actor:       services.ActorAuto,
+		Reason:      services.TransitionReasonAutoPublished,
+		CandidateID: &candidate.ID,
+	}); err != nil {
+		return err
 	}
-	if price, ok := fields["price"].(string); ok && price != "" {
-		event.Price = &price
+	if err := services.RecordEventChange(db, event.ID, services.ChangeTypeCreated, ""); err != nil {
+		return err
 	}
-	if organizer, ok := fields["organizer"].(string); ok && organizer != "" {
-		event.Organizer = &organizer
+	if err := services.RecordEventHistory(db, &event); err != nil {
+		return err
 	}
+	candidate.PublishedEventID = &event.ID
 
-	// Save the event
-	if err := db.Create(&event).Error; err != nil {
+	log.Printf("Successfully created public event '%s' (ID: %s) from auto-published candidate", event.Title, event.ID)
+	return nil
+}
+
+// createMergedEvent saves an auto-published event as a merged duplicate of primary: out of
+// the listings and off the change feed, with a pending merge suggestion for the admins
+func (h *UploadHandler) createMergedEvent(db *gorm.DB, candidate *models.EventCandidate, event *models.Event, primary *models.Event) error {
+	event.ModerationState = services.EventStateMerged
+	if err := db.Create(event).Error; err != nil {
 		return fmt.Errorf("failed to create event: %v", err)
 	}
+	if err := services.RecordEventCreated(db, event, services.EventTransition{
+		Actor:       services.ActorAuto,
+		Reason:      services.TransitionReasonMergedDuplicate,
+		CandidateID: &candidate.ID,
+	}); err != nil {
+		return err
+	}
+	if err := services.RecordEventHistory(db, event); err != nil {
+		return err
+	}
+	if _, err := services.MergeEvents(db, primary.ID, event.ID, services.TitleSimilarity(event.Title, primary.Title), services.DedupeReasonTitleSimilarity); err != nil {
+		return err
+	}
+	candidate.PublishedEventID = &event.ID
 
-	log.Printf("Successfully created public event '%s' (ID: %s) from auto-published candidate", title, event.ID)
+	reason := fmt.Sprintf("auto-published as a likely duplicate of event %s (pending admin review)", primary.ID)
+	candidate.PublicationReason = &reason
+	log.Printf("Event '%s' (ID: %s) looks like a duplicate of '%s' (ID: %s); merged pending review", event.Title, event.ID, primary.Title, primary.ID)
 	return nil
-}
\ No newline at end of file
+}
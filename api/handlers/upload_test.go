@@ -0,0 +1,572 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+func TestAcceptsUpload(t *testing.T) {
+	tests := []struct {
+		status     string
+		wantAccept bool
+		wantCode   int
+	}{
+		{"uploaded", true, http.StatusOK},
+		{services.SubmissionStatusRejectedQuality, true, http.StatusOK},
+		{"processing", false, http.StatusConflict},
+		{"parsed", false, http.StatusConflict},
+		{"done", false, http.StatusConflict},
+		{"error", false, http.StatusConflict},
+		{services.SubmissionStatusFailed, false, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			if got := acceptsUpload(c, tt.status); got != tt.wantAccept {
+				t.Fatalf("acceptsUpload(%q) = %v, want %v", tt.status, got, tt.wantAccept)
+			}
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if !tt.wantAccept && w.Body.Len() == 0 {
+				t.Error("rejected upload wrote no error body")
+			}
+		})
+	}
+}
+
+// TestUploadFileAgain checks a retake uploaded to a submission rejected for image quality
+// replaces its photo and runs it again, while one that started processing refuses the upload
+func TestUploadFileAgain(t *testing.T) {
+	tests := []struct {
+		status      string
+		wantCode    int
+		wantMessage string // of the 409
+	}{
+		{status: services.SubmissionStatusRejectedQuality, wantCode: http.StatusAccepted},
+		{status: "processing", wantCode: http.StatusConflict, wantMessage: "Submission is already processing"},
+		{status: "done", wantCode: http.StatusConflict, wantMessage: "Submission has already been processed"},
+		{status: "error", wantCode: http.StatusConflict, wantMessage: "Submission processing failed"},
+	}
+
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			submissionID := uuid.New()
+			mock.ExpectQuery(`SELECT * FROM "submissions" WHERE id = $1`).
+				WithArgs(submissionID, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "status", "image_count"}).AddRow(submissionID.String(), tt.status, 1))
+			if tt.wantCode == http.StatusAccepted {
+				for _, columns := range []string{`"image_count"=$1`, `"image_height"=$1`, `"thumbnail_url"=$1`, `"derivative_image_url"=$1`} {
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "submissions" SET ` + columns).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "submissions" SET "status"=$1,"updated_at"=$2 WHERE id = $3`).
+					WithArgs("processing", testdb.Any, submissionID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			cfg := &config.Config{UploadDir: t.TempDir(), MaxUploadImages: 6, WorkerPoolSize: 1}
+			storage := services.NewStorageService(cfg)
+			// The earlier, rejected photo is still stored
+			if err := storage.SaveFile(submissionID, services.SubmissionOriginalFilename, strings.NewReader("blurry")); err != nil {
+				t.Fatal(err)
+			}
+			processed := make(chan uuid.UUID, 1)
+			broker, queue := services.NewStatusBroker(), services.NewProcessingQueue(1)
+			workers := services.NewSubmissionWorkers(db, cfg, broker, queue,
+				func(_ context.Context, id uuid.UUID) error { processed <- id; return nil })
+			h := &UploadHandler{config: cfg, db: db, storage: storage, workers: workers, broker: broker, queue: queue}
+			router := gin.New()
+			router.PUT("/v1/uploads/:id", h.UploadFile)
+
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			part, err := form.CreateFormFile("file", "retake.jpg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write(photo.Bytes())
+			form.Close()
+			req := httptest.NewRequest(http.MethodPut, "/v1/uploads/"+submissionID.String(), &body)
+			req.Header.Set("Content-Type", form.FormDataContentType())
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.wantCode)
+			}
+			stored, err := os.ReadFile(storage.GetFilePath(submissionID, services.SubmissionOriginalFilename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantCode != http.StatusAccepted {
+				if !strings.Contains(w.Body.String(), tt.wantMessage) || string(stored) != "blurry" {
+					t.Errorf("refused upload = %s with the stored photo changed: %v; want %q", w.Body, string(stored) != "blurry", tt.wantMessage)
+				}
+				return
+			}
+			if string(stored) == "blurry" {
+				t.Error("the retake did not replace the rejected photo")
+			}
+
+			mock.ExpectQuery(`SELECT "id" FROM "submissions" WHERE status = $1`).WillReturnRows(testdb.IDs())
+			workers.Start()
+			defer workers.Close(time.Second)
+			select {
+			case id := <-processed:
+				if id != submissionID {
+					t.Errorf("workers processed %s, want the retaken submission", id)
+				}
+			case <-time.After(time.Second):
+				t.Error("retaken submission never reached the workers")
+			}
+		})
+	}
+}
+
+// TestCompleteUploadRefusesMissingOrOversizedObject checks a direct upload to S3 is refused
+// when the client never sent the object, or sent one larger than the upload policy allows,
+// in which case the object is deleted from the bucket
+func TestCompleteUploadRefusesMissingOrOversizedObject(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int // of the object in the bucket; -1 for none
+		wantMessage string
+	}{
+		{"missing", -1, "No file uploaded"},
+		{"too large", services.MaxUploadBytes + 1, "File too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submissionID := uuid.New()
+			key := "/board/" + submissionID.String() + "/" + services.SubmissionOriginalFilename
+			var mu sync.Mutex
+			objects := map[string]int{}
+			if tt.size >= 0 {
+				objects[key] = tt.size
+			}
+			// A path-style bucket holding at most the one original
+			bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+					w.Write([]byte("<ListBucketResult>"))
+					for name := range objects {
+						fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", strings.TrimPrefix(name, "/board/"))
+					}
+					w.Write([]byte("</ListBucketResult>"))
+				case r.Method == http.MethodGet:
+					size, ok := objects[r.URL.Path]
+					if !ok {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					w.Write(make([]byte, size))
+				case r.Method == http.MethodDelete:
+					delete(objects, r.URL.Path)
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					t.Errorf("unexpected %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			}))
+			defer bucket.Close()
+
+			db, mock := testdb.New(t)
+			mock.ExpectQuery(`SELECT * FROM "submissions" WHERE id = $1`).
+				WithArgs(submissionID, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(submissionID.String(), "uploaded"))
+
+			cfg := &config.Config{UploadDir: t.TempDir(), StorageBackend: services.StorageBackendS3, S3Bucket: "board",
+				S3Region: "us-east-1", S3Endpoint: bucket.URL, S3ForcePathStyle: true}
+			storage := services.NewStorageService(cfg)
+			h := &UploadHandler{config: cfg, db: db, storage: storage}
+			router := gin.New()
+			router.POST("/v1/uploads/:id/complete", h.CompleteUpload)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/uploads/"+submissionID.String()+"/complete", nil))
+
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("CompleteUpload() = %d %s, want 400 %q", w.Code, w.Body, tt.wantMessage)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(objects) != 0 {
+				t.Errorf("bucket still holds %v", objects)
+			}
+			if _, err := os.Stat(storage.GetFilePath(submissionID, services.SubmissionOriginalFilename)); !os.IsNotExist(err) {
+				t.Error("a refused upload left a working copy")
+			}
+		})
+	}
+}
+
+func TestGetSignedURLDuplicateCheck(t *testing.T) {
+	cfg := &config.Config{DuplicatePHashMaxDistance: 4}
+	storage := services.NewStorageService(&config.Config{UploadDir: t.TempDir()})
+	sha := strings.Repeat("ab", 32)
+	prior := uuid.New()
+
+	tests := []struct {
+		name          string
+		body          string
+		lookup        bool // the hash is checked against earlier uploads
+		matches       bool
+		wantCode      int
+		wantDuplicate bool
+	}{
+		{
+			name:     "no hashes skips the check",
+			body:     `{"contentType": "image/jpeg"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:          "honest duplicate gets the earlier results instead of a URL",
+			body:          `{"contentType": "image/jpeg", "imageSha256": "` + sha + `"}`,
+			lookup:        true,
+			matches:       true,
+			wantCode:      http.StatusOK,
+			wantDuplicate: true,
+		},
+		{
+			name:     "unmatched hash gets a URL",
+			body:     `{"contentType": "image/jpeg", "imageSha256": "` + sha + `"}`,
+			lookup:   true,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "malformed hash",
+			body:     `{"contentType": "image/jpeg", "imagePhash": "not-hex"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			if tt.lookup {
+				rows := sqlmock.NewRows([]string{"id", "status"})
+				if tt.matches {
+					rows.AddRow(prior.String(), "done")
+				}
+				mock.ExpectQuery(`FROM "submissions" WHERE`).
+					WithArgs(append(testdb.AnyArgs(4), sha, 1)...).
+					WillReturnRows(rows)
+			}
+			if tt.matches {
+				mock.ExpectQuery(`SELECT count(*) FROM "flyers"`).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT count(*) FROM "event_candidates"`).WillReturnRows(testdb.Count(2))
+			} else if tt.wantCode == http.StatusOK {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "submissions"`).WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+				mock.ExpectCommit()
+			}
+
+			h := &UploadHandler{config: cfg, db: db, storage: storage, queue: services.NewProcessingQueue(1)}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/signed-url", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			h.GetSignedURL(c)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var body struct {
+				Duplicate    bool      `json:"duplicate"`
+				SubmissionID uuid.UUID `json:"submissionId"`
+				URL          string    `json:"url"`
+				FlyersFound  int       `json:"flyersFound"`
+				EventsFound  int       `json:"eventsFound"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Duplicate != tt.wantDuplicate || (body.URL == "") != tt.wantDuplicate {
+				t.Errorf("response = %s, want duplicate: %v", w.Body, tt.wantDuplicate)
+			}
+			if tt.wantDuplicate && (body.SubmissionID != prior || body.FlyersFound != 1 || body.EventsFound != 2) {
+				t.Errorf("duplicate summary = %+v, want submission %s with 1 flyer and 2 events", body, prior)
+			}
+		})
+	}
+}
+
+// deletedBefore matches a cutoff time within a minute of now minus the given days
+type deletedBefore int
+
+func (days deletedBefore) Match(v driver.Value) bool {
+	since, ok := v.(time.Time)
+	want := time.Now().AddDate(0, 0, -int(days))
+	return ok && since.Sub(want).Abs() < time.Minute
+}
+
+// TestResubmittedFlyerDuringDeleteCooldown re-extracts a flyer whose event was deleted: within
+// EVENT_DELETE_COOLDOWN_DAYS its candidate is held for review, afterwards it auto-publishes
+func TestResubmittedFlyerDuringDeleteCooldown(t *testing.T) {
+	const cooldownDays = 30
+	start := time.Now().UTC().AddDate(0, 0, 10).Truncate(24 * time.Hour).Add(19 * time.Hour)
+	// The same flyer read again, with the title in another case
+	deletedKey := services.CanonicalKeyHash(services.EventCanonicalKey("Open Mic Night", start))
+	fields := `{"title":"OPEN MIC NIGHT ","date":"` + start.Format("2006-01-02 15:04") + `"}`
+
+	tests := []struct {
+		name           string
+		cooldownDays   int
+		deletedDaysAgo int // when the event was deleted, or 0 for never
+		wantResult     string
+		wantReview     string
+	}{
+		{name: "never deleted", cooldownDays: cooldownDays, wantResult: "published"},
+		{name: "resubmitted the day after the takedown", cooldownDays: cooldownDays, deletedDaysAgo: 1, wantResult: "needs_review", wantReview: services.ReviewReasonRecentlyDeleted},
+		{name: "resubmitted on the cooldown's last day", cooldownDays: cooldownDays, deletedDaysAgo: cooldownDays - 1, wantResult: "needs_review", wantReview: services.ReviewReasonRecentlyDeleted},
+		{name: "resubmitted after the cooldown", cooldownDays: cooldownDays, deletedDaysAgo: cooldownDays + 1, wantResult: "published"},
+		{name: "cooldown disabled", deletedDaysAgo: 1, wantResult: "published"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Only the tombstone lookup is answered; the other reads fail and fall back to
+			// defaults, and the publish and save that follow the decision fail harmlessly
+			db, mock := testdb.New(t)
+			if tt.cooldownDays > 0 {
+				rows := sqlmock.NewRows([]string{"event_id", "canonical_key_hash", "deleted_at"})
+				// The database keeps tombstones newer than the cutoff
+				if tt.deletedDaysAgo > 0 && tt.deletedDaysAgo < tt.cooldownDays {
+					rows.AddRow(uuid.New().String(), deletedKey, time.Now().AddDate(0, 0, -tt.deletedDaysAgo))
+				}
+				mock.ExpectQuery(`SELECT * FROM "event_tombstones" WHERE canonical_key_hash = $1 AND deleted_at > $2 ORDER BY deleted_at DESC LIMIT $3`).
+					WithArgs(deletedKey, deletedBefore(tt.cooldownDays), 1).
+					WillReturnRows(rows)
+			}
+
+			cfg := &config.Config{AutoPublishThreshold: 0.8, EventDeleteCooldownDays: tt.cooldownDays}
+			h := &UploadHandler{
+				config:            cfg,
+				db:                db,
+				moderation:        services.NewModerationService(cfg),
+				enrichment:        services.NewEnrichmentService(cfg),
+				moderationBreaker: services.NewCircuitBreaker("moderation", 5, time.Minute),
+				geocodeBreaker:    services.NewCircuitBreaker("geocoding", 5, time.Minute),
+			}
+			candidate := models.EventCandidate{ID: uuid.New(), FlyerID: uuid.New(), Fields: fields, CreatedAt: time.Now()}
+			h.processEventCandidate(context.Background(), &candidate, true)
+
+			if candidate.PublishResult == nil || *candidate.PublishResult != tt.wantResult {
+				t.Fatalf("publish result = %v (%v), want %s", candidate.PublishResult, candidate.PublicationReason, tt.wantResult)
+			}
+			if review := candidate.ReviewReason; (review == nil) != (tt.wantReview == "") || (review != nil && *review != tt.wantReview) {
+				t.Errorf("review reason = %v, want %q", review, tt.wantReview)
+			}
+		})
+	}
+}
+
+// TestRetrySubmission checks an admin can send any failed upload back to the workers,
+// however many automatic retries it already used
+func TestRetrySubmission(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string // the stored submission's; empty when it doesn't exist
+		retryCount   int
+		published    int64 // events it already published
+		wantCode     int
+		wantEnqueued bool
+	}{
+		{name: "failed after every automatic retry", status: services.SubmissionStatusFailed, retryCount: 3, wantCode: http.StatusAccepted, wantEnqueued: true},
+		{name: "errored before any retry", status: "error", wantCode: http.StatusAccepted, wantEnqueued: true},
+		{name: "already processing", status: "processing", wantCode: http.StatusConflict},
+		{name: "already published events", status: services.SubmissionStatusFailed, published: 1, wantCode: http.StatusConflict},
+		{name: "unknown submission", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			submissionID := uuid.New()
+			rows := sqlmock.NewRows([]string{"id", "status", "source", "retry_count"})
+			if tt.status != "" {
+				rows.AddRow(submissionID.String(), tt.status, services.SubmissionSourceUpload, tt.retryCount)
+			}
+			mock.ExpectQuery(`SELECT * FROM "submissions" WHERE id = $1`).
+				WithArgs(submissionID, 1).
+				WillReturnRows(rows)
+			if tt.status != "" && tt.status != "processing" {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "event_candidates" JOIN flyers`).
+					WithArgs(submissionID).
+					WillReturnRows(testdb.Count(tt.published))
+				if tt.published > 0 {
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectExec(`DELETE FROM "event_candidates"`).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec(`DELETE FROM "flyers"`).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec(`UPDATE "submissions" SET "duplicate_of_id"=$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "submissions" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status <> $4`).
+						WithArgs("processing", testdb.Any, submissionID, "processing").
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+			}
+
+			processed := make(chan uuid.UUID, 1)
+			cfg := &config.Config{WorkerPoolSize: 1}
+			workers := services.NewSubmissionWorkers(db, cfg, services.NewStatusBroker(), services.NewProcessingQueue(1),
+				func(_ context.Context, id uuid.UUID) error { processed <- id; return nil })
+			h := &UploadHandler{config: cfg, db: db, workers: workers}
+			router := gin.New()
+			router.POST("/admin/submissions/:id/retry", h.RetrySubmission)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/submissions/"+submissionID.String()+"/retry", nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.wantCode)
+			}
+
+			// Workers start only now, so every job they see came from the retry
+			mock.ExpectQuery(`SELECT "id" FROM "submissions" WHERE status = $1`).WillReturnRows(testdb.IDs())
+			workers.Start()
+			defer workers.Close(time.Second)
+			select {
+			case id := <-processed:
+				if !tt.wantEnqueued || id != submissionID {
+					t.Errorf("workers processed %s, want enqueued: %v", id, tt.wantEnqueued)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantEnqueued {
+					t.Error("retried submission never reached the workers")
+				}
+			}
+		})
+	}
+}
+
+// TestReprocessSubmissionClaimsFirst checks a reprocess moves the submission to "processing"
+// before any work, and that a call losing that claim to another gets a 409 without running
+func TestReprocessSubmissionClaimsFirst(t *testing.T) {
+	tests := []struct {
+		name         string
+		stage        string
+		status       string // as the claim reads it
+		claimed      bool   // whether the conditional UPDATE still finds that status
+		wantCode     int
+		wantEnqueued bool
+	}{
+		{name: "full reprocess", stage: services.ReprocessStageAll, status: "error", claimed: true, wantCode: http.StatusOK, wantEnqueued: true},
+		{name: "claimed by a concurrent reprocess", stage: services.ReprocessStageStage3, status: "done", wantCode: http.StatusConflict},
+		{name: "still processing", stage: services.ReprocessStageStage3, status: "processing", wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			submissionID := uuid.New()
+			mock.ExpectQuery(`SELECT "id","source" FROM "submissions" WHERE id = $1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "source"}).AddRow(submissionID.String(), services.SubmissionSourceUpload))
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT "id","status" FROM "submissions" WHERE id = $1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(submissionID.String(), tt.status))
+			if tt.status != "processing" {
+				affected := int64(0)
+				if tt.claimed {
+					affected = 1
+				}
+				mock.ExpectExec(`UPDATE "submissions" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`).
+					WithArgs("processing", testdb.Any, submissionID, tt.status).
+					WillReturnResult(sqlmock.NewResult(0, affected))
+			}
+			if tt.claimed {
+				// Earlier results are cleared only once the submission is claimed
+				mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(`SELECT count(*) FROM "event_candidates" JOIN flyers`).WillReturnRows(testdb.Count(0))
+				mock.ExpectExec(`DELETE FROM "event_candidates"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`DELETE FROM "flyers"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`UPDATE "submissions" SET "duplicate_of_id"=$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(testdb.IDs(uuid.New()))
+				mock.ExpectCommit()
+				mock.ExpectQuery(`SELECT * FROM "submissions" WHERE id = $1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(submissionID.String(), "processing"))
+				mock.ExpectQuery(`SELECT * FROM "flyers" WHERE "flyers"."submission_id" = $1`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			} else {
+				mock.ExpectRollback()
+			}
+
+			processed := make(chan uuid.UUID, 1)
+			cfg := &config.Config{WorkerPoolSize: 1}
+			queue := services.NewProcessingQueue(1)
+			workers := services.NewSubmissionWorkers(db, cfg, services.NewStatusBroker(), queue,
+				func(_ context.Context, id uuid.UUID) error { processed <- id; return nil })
+			h := &UploadHandler{config: cfg, db: db, queue: queue, workers: workers}
+			router := gin.New()
+			router.POST("/admin/submissions/:id/reprocess", h.ReprocessSubmission)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/submissions/"+submissionID.String()+"/reprocess?stage="+tt.stage, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.wantCode)
+			}
+
+			// Workers start only now, so every job they see came from the reprocess
+			mock.ExpectQuery(`SELECT "id" FROM "submissions" WHERE status = $1`).WillReturnRows(testdb.IDs())
+			workers.Start()
+			defer workers.Close(time.Second)
+			select {
+			case id := <-processed:
+				if !tt.wantEnqueued || id != submissionID {
+					t.Errorf("workers processed %s, want enqueued: %v", id, tt.wantEnqueued)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantEnqueued {
+					t.Error("reprocessed submission never reached the workers")
+				}
+			}
+		})
+	}
+}
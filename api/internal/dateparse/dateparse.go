@@ -0,0 +1,377 @@
+// Package dateparse turns the free-form date strings extracted from flyers
+// ("Sat June 7th, 8PM", "6/7 @ 8pm", "this Friday") into times in a region's timezone.
+package dateparse
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Granularity says how much of a parsed time came from the input
+type Granularity string
+
+const (
+	// GranularityDate means only a day was given; Time is midnight in the region
+	GranularityDate Granularity = "date"
+	// GranularityDateTime means both a day and a time of day were given
+	GranularityDateTime Granularity = "datetime"
+)
+
+// yearRolloverGrace is how far before the reference a date may fall before it is assumed
+// to mean next year. Flyers stay up after their event, so recent past dates are kept.
+const yearRolloverGrace = 60 * 24 * time.Hour
+
+// ErrUnparsed is returned when no date can be recognized in the input
+var ErrUnparsed = errors.New("unrecognized date")
+
+// Result is a parsed flyer date
+type Result struct {
+	Time        time.Time   `json:"time"`
+	Granularity Granularity `json:"granularity"`
+	Confidence  float64     `json:"confidence"` // 0.0-1.0
+	Relative    bool        `json:"relative"`   // resolved from "tonight", "this Friday", etc.
+}
+
+// isoLayouts are tried first since extraction is asked for ISO dates
+var isoLayouts = []struct {
+	layout      string
+	granularity Granularity
+}{
+	{"2006-01-02T15:04:05", GranularityDateTime},
+	{"2006-01-02 15:04:05", GranularityDateTime},
+	{"2006-01-02T15:04", GranularityDateTime},
+	{"2006-01-02 15:04", GranularityDateTime},
+	{"2006-01-02", GranularityDate},
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "weds": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+const (
+	monthPattern   = `(jan|january|feb|february|mar|march|apr|april|may|jun|june|jul|july|aug|august|sep|sept|september|oct|october|nov|november|dec|december)\.?`
+	weekdayPattern = `(sun|sunday|mon|monday|tue|tues|tuesday|wed|weds|wednesday|thu|thur|thurs|thursday|fri|friday|sat|saturday)\.?`
+)
+
+var (
+	ordinalSuffix = regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)\b`)
+	noise         = regexp.MustCompile(`[,@]|\bat\b|\bfrom\b|\bdoors?\b|\bstarts?\b|\bshow\b`)
+	spaces        = regexp.MustCompile(`\s+`)
+
+	// "8-11pm", "7:30 - 10 pm", "10pm to 2am"
+	timeRange = regexp.MustCompile(`\b(\d{1,2})(?::(\d{2}))?\s*(am|pm)?\s*(?:-|–|to)\s*(\d{1,2})(?::(\d{2}))?\s*(am|pm)\b`)
+	// "8pm", "8:30 pm"
+	clockTime = regexp.MustCompile(`\b(\d{1,2})(?::(\d{2}))?\s*(am|pm)\b`)
+	// "20:00"
+	clock24   = regexp.MustCompile(`\b(\d{1,2}):(\d{2})\b`)
+	namedTime = regexp.MustCompile(`\b(noon|midnight)\b`)
+
+	isoDate    = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	monthDay   = regexp.MustCompile(`\b` + monthPattern + `\s+(\d{1,2})(?:\s+(\d{4}))?\b`)
+	dayMonth   = regexp.MustCompile(`\b(\d{1,2})\s+` + monthPattern + `(?:\s+(\d{4}))?\b`)
+	numericDay = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})(?:/(\d{4}|\d{2}))?\b`)
+
+	relativeDay     = regexp.MustCompile(`\b(today|tonight|tomorrow|this weekend)\b`)
+	relativeWeekday = regexp.MustCompile(`\b(?:(this|next|coming)\s+)?` + weekdayPattern + `\b`)
+)
+
+// Parse reads a flyer date relative to now (normally when the photo was taken) and
+// returns it in loc. Strings with an explicit zone keep it.
+func Parse(input string, now time.Time, loc *time.Location) (Result, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return Result{}, ErrUnparsed
+	}
+
+	if result, ok := parseISO(trimmed, now, loc); ok {
+		return result, nil
+	}
+
+	text := normalize(trimmed)
+	hour, minute, hasTime, text := extractTime(text)
+
+	day, confidence, relative, ok := extractDate(text, now, loc)
+	if !ok {
+		return Result{}, ErrUnparsed
+	}
+
+	result := Result{
+		Time:        day,
+		Granularity: GranularityDate,
+		Confidence:  confidence,
+		Relative:    relative,
+	}
+	if hasTime {
+		result.Time = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+		result.Granularity = GranularityDateTime
+	}
+	return result, nil
+}
+
+// parseISO handles machine-formatted dates, including RFC3339 with an offset
+func parseISO(input string, now time.Time, loc *time.Location) (Result, bool) {
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		return Result{Time: t, Granularity: GranularityDateTime, Confidence: 1.0}, true
+	}
+
+	for _, candidate := range isoLayouts {
+		t, err := time.ParseInLocation(candidate.layout, input, loc)
+		if err != nil {
+			continue
+		}
+		result := Result{Time: t, Granularity: candidate.granularity, Confidence: 0.95}
+		// Extraction sometimes fills in a year the flyer never printed
+		if rolled := rollForward(t, now); !rolled.Equal(t) {
+			result.Time = rolled
+			result.Confidence = 0.6
+		}
+		return result, true
+	}
+	return Result{}, false
+}
+
+func normalize(input string) string {
+	text := strings.ToLower(input)
+	text = strings.NewReplacer("p.m.", "pm", "a.m.", "am", "p.m", "pm", "a.m", "am").Replace(text)
+	text = ordinalSuffix.ReplaceAllString(text, "$1")
+	text = noise.ReplaceAllString(text, " ")
+	return strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+}
+
+// extractTime finds the start time of day and returns the text with it removed
+func extractTime(text string) (hour, minute int, ok bool, rest string) {
+	if m := timeRange.FindStringSubmatchIndex(text); m != nil {
+		startHour, _ := strconv.Atoi(text[m[2]:m[3]])
+		startMinute := submatchInt(text, m, 2)
+		endHour, _ := strconv.Atoi(text[m[8]:m[9]])
+		endMeridiem := text[m[12]:m[13]]
+
+		meridiem := endMeridiem
+		if m[6] >= 0 {
+			meridiem = text[m[6]:m[7]]
+		} else if endMeridiem == "am" && startHour > endHour && startHour != 12 {
+			// "10-2am" starts in the evening
+			meridiem = "pm"
+		}
+		if h, ok := to24Hour(startHour, meridiem); ok && startMinute < 60 {
+			return h, startMinute, true, cut(text, m[0], m[1])
+		}
+	}
+
+	if m := clockTime.FindStringSubmatchIndex(text); m != nil {
+		h, _ := strconv.Atoi(text[m[2]:m[3]])
+		min := submatchInt(text, m, 2)
+		if h, ok := to24Hour(h, text[m[6]:m[7]]); ok && min < 60 {
+			return h, min, true, cut(text, m[0], m[1])
+		}
+	}
+
+	if m := clock24.FindStringSubmatchIndex(text); m != nil {
+		h, _ := strconv.Atoi(text[m[2]:m[3]])
+		min, _ := strconv.Atoi(text[m[4]:m[5]])
+		if h < 24 && min < 60 {
+			return h, min, true, cut(text, m[0], m[1])
+		}
+	}
+
+	if m := namedTime.FindStringSubmatchIndex(text); m != nil {
+		h := 12
+		if text[m[2]:m[3]] == "midnight" {
+			h = 0
+		}
+		return h, 0, true, cut(text, m[0], m[1])
+	}
+
+	return 0, 0, false, text
+}
+
+// extractDate finds the day, preferring explicit dates over relative expressions
+func extractDate(text string, now time.Time, loc *time.Location) (day time.Time, confidence float64, relative bool, ok bool) {
+	if m := isoDate.FindStringSubmatch(text); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		d, _ := strconv.Atoi(m[3])
+		if day, ok := validDate(year, time.Month(month), d, loc); ok {
+			return day, 0.9 - weekdayPenalty(text, day), false, true
+		}
+	}
+
+	if m := monthDay.FindStringSubmatch(text); m != nil {
+		if day, conf, ok := explicitDate(months[m[1]], m[2], m[3], now, loc); ok {
+			return day, conf - weekdayPenalty(text, day), false, true
+		}
+	}
+
+	if m := dayMonth.FindStringSubmatch(text); m != nil {
+		if day, conf, ok := explicitDate(months[m[2]], m[1], m[3], now, loc); ok {
+			return day, conf - weekdayPenalty(text, day), false, true
+		}
+	}
+
+	if m := numericDay.FindStringSubmatch(text); m != nil {
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		// US month/day unless that reading is impossible
+		month, d, conf := first, second, 0.8
+		if first > 12 && second <= 12 {
+			month, d, conf = second, first, 0.6
+		}
+		if m[3] == "" {
+			conf -= 0.05
+		}
+		if day, _, ok := explicitDate(time.Month(month), strconv.Itoa(d), expandYear(m[3]), now, loc); ok {
+			return day, conf - weekdayPenalty(text, day), false, true
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	if m := relativeDay.FindStringSubmatch(text); m != nil {
+		switch m[1] {
+		case "today", "tonight":
+			return today, 0.8, true, true
+		case "tomorrow":
+			return today.AddDate(0, 0, 1), 0.8, true, true
+		case "this weekend":
+			return nextWeekday(today, time.Saturday), 0.6, true, true
+		}
+	}
+
+	if m := relativeWeekday.FindStringSubmatch(text); m != nil {
+		day := nextWeekday(today, weekdays[m[2]])
+		switch m[1] {
+		case "next":
+			// "next Friday" usually skips the coming one, but not always
+			return day.AddDate(0, 0, 7), 0.55, true, true
+		case "this", "coming":
+			return day, 0.75, true, true
+		default:
+			return day, 0.7, true, true
+		}
+	}
+
+	return time.Time{}, 0, false, false
+}
+
+// explicitDate builds a date from a month, day, and optional year, inferring a missing
+// year from now. Confidence is lower when the year was inferred.
+func explicitDate(month time.Month, dayText, yearText string, now time.Time, loc *time.Location) (time.Time, float64, bool) {
+	d, err := strconv.Atoi(dayText)
+	if err != nil || month == 0 {
+		return time.Time{}, 0, false
+	}
+
+	if yearText != "" {
+		year, _ := strconv.Atoi(yearText)
+		day, ok := validDate(year, month, d, loc)
+		return day, 0.9, ok
+	}
+
+	day, ok := validDate(now.Year(), month, d, loc)
+	if !ok {
+		// Feb 29 outside a leap year may still be valid next year
+		day, ok = validDate(now.Year()+1, month, d, loc)
+		return day, 0.85, ok
+	}
+	return rollForward(day, now), 0.85, true
+}
+
+// rollForward moves a date that falls well before now into the following year
+func rollForward(t, now time.Time) time.Time {
+	for t.Before(now.Add(-yearRolloverGrace)) {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t
+}
+
+// validDate rejects dates that time.Date would silently normalize, like June 31
+func validDate(year int, month time.Month, day int, loc *time.Location) (time.Time, bool) {
+	if month < time.January || month > time.December || day < 1 {
+		return time.Time{}, false
+	}
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	return t, t.Month() == month && t.Day() == day
+}
+
+// weekdayPenalty lowers confidence when the text names a weekday that disagrees with the date
+func weekdayPenalty(text string, day time.Time) float64 {
+	if m := relativeWeekday.FindStringSubmatch(text); m != nil && m[1] == "" {
+		if weekdays[m[2]] != day.Weekday() {
+			return 0.3
+		}
+	}
+	return 0
+}
+
+// nextWeekday returns the first date on or after from that falls on weekday
+func nextWeekday(from time.Time, weekday time.Weekday) time.Time {
+	offset := (int(weekday) - int(from.Weekday()) + 7) % 7
+	return from.AddDate(0, 0, offset)
+}
+
+func to24Hour(hour int, meridiem string) (int, bool) {
+	if hour < 1 || hour > 12 {
+		return 0, false
+	}
+	switch meridiem {
+	case "am":
+		if hour == 12 {
+			return 0, true
+		}
+		return hour, true
+	case "pm":
+		if hour == 12 {
+			return 12, true
+		}
+		return hour + 12, true
+	}
+	return 0, false
+}
+
+func expandYear(year string) string {
+	if len(year) == 2 {
+		return "20" + year
+	}
+	return year
+}
+
+// submatchInt reads an optional numeric group, defaulting to 0
+func submatchInt(text string, m []int, group int) int {
+	if m[group*2] < 0 {
+		return 0
+	}
+	value, _ := strconv.Atoi(text[m[group*2]:m[group*2+1]])
+	return value
+}
+
+func cut(text string, start, end int) string {
+	return strings.TrimSpace(text[:start] + " " + text[end:])
+}
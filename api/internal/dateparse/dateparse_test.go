@@ -0,0 +1,104 @@
+package dateparse
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCorpus(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Wednesday afternoon, when the flyer was photographed
+	now := time.Date(2026, 5, 20, 15, 0, 0, 0, loc)
+
+	tests := []struct {
+		input          string
+		want           string // local time as "2006-01-02 15:04"
+		granularity    Granularity
+		relative       bool
+		wantConfidence float64
+	}{
+		// Formats extraction is asked for
+		{"2026-07-04", "2026-07-04 00:00", GranularityDate, false, 0.95},
+		{"2026-07-04T19:30:00", "2026-07-04 19:30", GranularityDateTime, false, 0.95},
+		{"2026-07-04 19:30", "2026-07-04 19:30", GranularityDateTime, false, 0.95},
+		{"2026-07-04T19:30:00-04:00", "2026-07-04 16:30", GranularityDateTime, false, 1.0},
+		{"2025-05-10", "2026-05-10 00:00", GranularityDate, false, 0.6},
+
+		// As printed on flyers
+		{"Sat June 6th, 8PM", "2026-06-06 20:00", GranularityDateTime, false, 0.85},
+		{"Sat June 7th, 8PM", "2026-06-07 20:00", GranularityDateTime, false, 0.55},
+		{"6/7 @ 8pm", "2026-06-07 20:00", GranularityDateTime, false, 0.75},
+		{"6/7/26", "2026-06-07 00:00", GranularityDate, false, 0.8},
+		{"25/12", "2026-12-25 00:00", GranularityDate, false, 0.55},
+		{"15 June 2026 7:30pm", "2026-06-15 19:30", GranularityDateTime, false, 0.9},
+		{"Doors 7:30 p.m. June 12", "2026-06-12 19:30", GranularityDateTime, false, 0.85},
+		{"June 12 20:00", "2026-06-12 20:00", GranularityDateTime, false, 0.85},
+		{"8-11pm Sat May 23", "2026-05-23 20:00", GranularityDateTime, false, 0.85},
+		{"10-2am Friday May 22", "2026-05-22 22:00", GranularityDateTime, false, 0.85},
+		{"midnight Sat May 23", "2026-05-23 00:00", GranularityDateTime, false, 0.85},
+		{"May 1", "2026-05-01 00:00", GranularityDate, false, 0.85},
+		{"Jan 15", "2027-01-15 00:00", GranularityDate, false, 0.85},
+
+		// Relative to when the photo was taken
+		{"tonight 9pm", "2026-05-20 21:00", GranularityDateTime, true, 0.8},
+		{"tomorrow at noon", "2026-05-21 12:00", GranularityDateTime, true, 0.8},
+		{"this weekend", "2026-05-23 00:00", GranularityDate, true, 0.6},
+		{"this Friday", "2026-05-22 00:00", GranularityDate, true, 0.75},
+		{"Friday 7pm", "2026-05-22 19:00", GranularityDateTime, true, 0.7},
+		{"next Friday", "2026-05-29 00:00", GranularityDate, true, 0.55},
+		{"Wednesday", "2026-05-20 00:00", GranularityDate, true, 0.7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input, now, loc)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			if local := got.Time.In(loc).Format("2006-01-02 15:04"); local != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, local, tt.want)
+			}
+			if got.Granularity != tt.granularity || got.Relative != tt.relative {
+				t.Errorf("Parse(%q) granularity, relative = %s, %v; want %s, %v",
+					tt.input, got.Granularity, got.Relative, tt.granularity, tt.relative)
+			}
+			if math.Abs(got.Confidence-tt.wantConfidence) > 1e-9 {
+				t.Errorf("Parse(%q) confidence = %.2f, want %.2f", tt.input, got.Confidence, tt.wantConfidence)
+			}
+		})
+	}
+}
+
+func TestParseUnrecognized(t *testing.T) {
+	now := time.Date(2026, 5, 20, 15, 0, 0, 0, time.UTC)
+	for _, input := range []string{
+		"",
+		"   ",
+		"TBA",
+		"8pm",
+		"6/31",
+		"Feb 29",
+		"Call for details",
+	} {
+		if got, err := Parse(input, now, time.UTC); !errors.Is(err, ErrUnparsed) {
+			t.Errorf("Parse(%q) = %+v, %v; want ErrUnparsed", input, got, err)
+		}
+	}
+}
+
+func TestParseDefaultsToUTC(t *testing.T) {
+	now := time.Date(2026, 5, 20, 15, 0, 0, 0, time.UTC)
+	got, err := Parse("June 12 8pm", now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 6, 12, 20, 0, 0, 0, time.UTC); !got.Time.Equal(want) {
+		t.Errorf("Parse() = %s, want %s", got.Time, want)
+	}
+}
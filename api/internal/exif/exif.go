@@ -0,0 +1,306 @@
+// Package exif reads the few EXIF tags the pipeline uses (capture time, GPS position, and
+// orientation) from JPEG uploads, without decoding the image.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxHeaderBytes bounds how much of the file is scanned; APP1 sits near the start
+const maxHeaderBytes = 256 * 1024
+
+// dateTimeLayout is EXIF's local, zone-less timestamp format
+const dateTimeLayout = "2006:01:02 15:04:05"
+
+// ErrNoExif is returned when the image carries no readable EXIF block
+var ErrNoExif = errors.New("no exif data")
+
+// TIFF tags read by this package
+const (
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// TIFF field types
+const (
+	typeASCII    = 2
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+)
+
+// Data holds the tags found in an image; fields are nil when absent
+type Data struct {
+	// DateTimeOriginal is the camera's local clock reading, with no zone
+	DateTimeOriginal *string
+	Latitude         *float64
+	Longitude        *float64
+	// Orientation is the TIFF orientation (1-8) the pixels must be transformed by to
+	// display upright, or 0 when absent
+	Orientation int
+}
+
+// CapturedAt interprets DateTimeOriginal in loc, the camera's assumed zone
+func (d *Data) CapturedAt(loc *time.Location) (time.Time, bool) {
+	if d.DateTimeOriginal == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, *d.DateTimeOriginal, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Read extracts EXIF data, GPS position included, from the start of a JPEG stream
+func Read(r io.Reader) (*Data, error) {
+	return read(r, true)
+}
+
+// ReadWithoutLocation is Read without looking at the GPS tags, for images whose uploader
+// hasn't allowed their position to be read; Latitude and Longitude are always nil
+func ReadWithoutLocation(r io.Reader) (*Data, error) {
+	return read(r, false)
+}
+
+func read(r io.Reader, withLocation bool) (*Data, error) {
+	header, err := io.ReadAll(io.LimitReader(r, maxHeaderBytes))
+	if err != nil {
+		return nil, err
+	}
+	tiff, err := findTIFF(header)
+	if err != nil {
+		return nil, err
+	}
+	return parseTIFF(tiff, withLocation)
+}
+
+// Strip removes the Exif and XMP APP1 segments from a JPEG, which is where cameras put GPS
+// position, capture time, and device details, leaving the image data untouched. It reports
+// whether anything was removed; data that isn't a well-formed JPEG is returned as is.
+func Strip(data []byte) ([]byte, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data, false
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	removed := false
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return data, false
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image: copy the rest
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return data, false
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && (bytes.HasPrefix(segment, []byte("Exif\x00\x00")) || bytes.HasPrefix(segment, []byte("http://ns.adobe.com/xap/1.0/\x00"))) {
+			removed = true
+		} else {
+			out = append(out, data[pos:pos+2+length]...)
+		}
+		pos += 2 + length
+	}
+	if !removed {
+		return data, false
+	}
+	return append(out, data[pos:]...), true
+}
+
+// findTIFF walks JPEG segments to the Exif APP1 payload
+func findTIFF(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrNoExif
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, ErrNoExif
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return nil, ErrNoExif
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, ErrNoExif
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+		pos += 2 + length
+	}
+	return nil, ErrNoExif
+}
+
+// reader resolves IFD entries within a TIFF block
+type reader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type entry struct {
+	typ    uint16
+	count  uint32
+	offset []byte // the 4-byte value/offset field
+}
+
+func parseTIFF(tiff []byte, withLocation bool) (*Data, error) {
+	if len(tiff) < 8 {
+		return nil, ErrNoExif
+	}
+	r := &reader{data: tiff}
+	switch string(tiff[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, ErrNoExif
+	}
+
+	ifd0 := r.ifd(r.order.Uint32(tiff[4:8]))
+	if ifd0 == nil {
+		return nil, ErrNoExif
+	}
+
+	data := &Data{}
+	if e, ok := ifd0[tagOrientation]; ok {
+		data.Orientation = int(r.short(e))
+	}
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if exifIFD := r.ifd(r.long(e)); exifIFD != nil {
+			if e, ok := exifIFD[tagDateTimeOriginal]; ok {
+				data.DateTimeOriginal = r.ascii(e)
+			}
+		}
+	}
+	if data.DateTimeOriginal == nil {
+		if e, ok := ifd0[tagDateTime]; ok {
+			data.DateTimeOriginal = r.ascii(e)
+		}
+	}
+
+	if e, ok := ifd0[tagGPSIFD]; ok && withLocation {
+		if gps := r.ifd(r.long(e)); gps != nil {
+			data.Latitude = r.coordinate(gps, tagGPSLatitude, tagGPSLatitudeRef, "S")
+			data.Longitude = r.coordinate(gps, tagGPSLongitude, tagGPSLongitudeRef, "W")
+		}
+	}
+	return data, nil
+}
+
+// ifd reads the entries of the IFD at offset, or nil if it is out of bounds
+func (r *reader) ifd(offset uint32) map[uint16]entry {
+	start := int(offset)
+	if start < 0 || start+2 > len(r.data) {
+		return nil
+	}
+	count := int(r.order.Uint16(r.data[start : start+2]))
+	if start+2+count*12 > len(r.data) {
+		return nil
+	}
+
+	entries := make(map[uint16]entry, count)
+	for i := 0; i < count; i++ {
+		raw := r.data[start+2+i*12 : start+14+i*12]
+		entries[r.order.Uint16(raw[0:2])] = entry{
+			typ:    r.order.Uint16(raw[2:4]),
+			count:  r.order.Uint32(raw[4:8]),
+			offset: raw[8:12],
+		}
+	}
+	return entries
+}
+
+// value returns the bytes of an entry's value, inline or at its offset
+func (r *reader) value(e entry, size int) []byte {
+	total := size * int(e.count)
+	if total <= 4 {
+		return e.offset[:total]
+	}
+	start := int(r.order.Uint32(e.offset))
+	if start < 0 || start+total > len(r.data) {
+		return nil
+	}
+	return r.data[start : start+total]
+}
+
+func (r *reader) long(e entry) uint32 {
+	if e.typ != typeLong {
+		return 0
+	}
+	return r.order.Uint32(e.offset)
+}
+
+func (r *reader) short(e entry) uint16 {
+	if e.typ != typeShort {
+		return 0
+	}
+	return r.order.Uint16(e.offset[:2])
+}
+
+func (r *reader) ascii(e entry) *string {
+	if e.typ != typeASCII {
+		return nil
+	}
+	raw := r.value(e, 1)
+	if raw == nil {
+		return nil
+	}
+	text := strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+	if text == "" {
+		return nil
+	}
+	return &text
+}
+
+// coordinate converts a degrees/minutes/seconds GPS tag to signed decimal degrees
+func (r *reader) coordinate(gps map[uint16]entry, valueTag, refTag uint16, negativeRef string) *float64 {
+	e, ok := gps[valueTag]
+	if !ok || e.typ != typeRational || e.count != 3 {
+		return nil
+	}
+	raw := r.value(e, 8)
+	if raw == nil {
+		return nil
+	}
+
+	var parts [3]float64
+	for i := range parts {
+		num := r.order.Uint32(raw[i*8 : i*8+4])
+		den := r.order.Uint32(raw[i*8+4 : i*8+8])
+		if den == 0 {
+			return nil
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	degrees := parts[0] + parts[1]/60 + parts[2]/3600
+
+	if ref, ok := gps[refTag]; ok {
+		if value := r.ascii(ref); value != nil && strings.EqualFold(*value, negativeRef) {
+			degrees = -degrees
+		}
+	}
+	return &degrees
+}
@@ -0,0 +1,142 @@
+// Package testdb gives unit tests a GORM handle on the Postgres dialect whose queries are
+// answered by sqlmock, so code that talks to the database runs without one.
+package testdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// containsSQL matches when the executed statement contains the expected fragment, both
+// with runs of whitespace collapsed, so expectations name tables and clauses rather than
+// repeating GORM's exact SQL
+var containsSQL = sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+	want := whitespace.ReplaceAllString(strings.TrimSpace(expected), " ")
+	got := whitespace.ReplaceAllString(strings.TrimSpace(actual), " ")
+	if !strings.Contains(got, want) {
+		return fmt.Errorf("statement %q does not contain %q", got, want)
+	}
+	return nil
+})
+
+// New opens a mocked database. Expectations are matched in order, and the test fails if
+// any of them is left unmet when it finishes.
+func New(t testing.TB) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(containsSQL))
+	if err != nil {
+		t.Fatalf("open sqlmock: %v", err)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open gorm on sqlmock: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet database expectations: %v", err)
+		}
+		sqlDB.Close()
+	})
+	return db, mock
+}
+
+// Count returns the single-row result of a COUNT query
+func Count(n int64) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"count"}).AddRow(n)
+}
+
+// IDs returns one "id" row per value, as a Pluck("id") reads them
+func IDs(ids ...uuid.UUID) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id"})
+	for _, id := range ids {
+		rows.AddRow(id.String())
+	}
+	return rows
+}
+
+// Any matches every value; use it for arguments a test doesn't pin down
+var Any = sqlmock.AnyArg()
+
+// containing matches a string or []byte argument that includes each fragment
+type containing []string
+
+func (c containing) Match(v driver.Value) bool {
+	var s string
+	switch value := v.(type) {
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	default:
+		return false
+	}
+	for _, fragment := range c {
+		if !strings.Contains(s, fragment) {
+			return false
+		}
+	}
+	return true
+}
+
+// Containing matches a text or JSON argument that includes every fragment
+func Containing(fragments ...string) sqlmock.Argument {
+	return containing(fragments)
+}
+
+// AnyArgs returns n arguments that match anything, to pad out an INSERT's column list
+func AnyArgs(n int) []driver.Value {
+	args := make([]driver.Value, n)
+	for i := range args {
+		args[i] = Any
+	}
+	return args
+}
+
+// Creates collects what GORM inserts, by table, so tests can assert on the records written
+// instead of on positional SQL arguments
+type Creates struct {
+	mu     sync.Mutex
+	tables map[string][]interface{}
+}
+
+// RecordCreates starts collecting every successful Create on db
+func RecordCreates(t testing.TB, db *gorm.DB) *Creates {
+	t.Helper()
+
+	creates := &Creates{tables: make(map[string][]interface{})}
+	err := db.Callback().Create().After("gorm:create").Register("testdb:record_creates", func(tx *gorm.DB) {
+		if tx.Error != nil {
+			return
+		}
+		creates.mu.Lock()
+		defer creates.mu.Unlock()
+		creates.tables[tx.Statement.Table] = append(creates.tables[tx.Statement.Table], tx.Statement.Dest)
+	})
+	if err != nil {
+		t.Fatalf("register create recorder: %v", err)
+	}
+	return creates
+}
+
+// Table returns the values created in table, oldest first
+func (c *Creates) Table(name string) []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]interface{}(nil), c.tables[name]...)
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -12,6 +17,7 @@ import (
 	"github.com/lincolngreen/williamboard/api/handlers"
 	"github.com/lincolngreen/williamboard/api/middleware"
 	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/observability"
 	"github.com/lincolngreen/williamboard/api/services"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -29,6 +35,25 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if err := services.LoadFieldSchema(cfg); err != nil {
+		log.Fatalf("Failed to load extraction fields: %v", err)
+	}
+	promptVersion, err := services.LoadVisionPrompt(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load vision prompt: %v", err)
+	}
+	log.Printf("Vision prompt version %s", promptVersion)
+	if err := services.LoadVisionExperiment(cfg); err != nil {
+		log.Fatalf("Failed to load vision experiment: %v", err)
+	}
+	services.ConfigureEventDuration(cfg)
+	services.ConfigureCursorSigning(cfg)
+
+	// Start tracing before anything that makes spans is created
+	tracing, err := observability.Init(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start tracing: %v", err)
+	}
 
 	// Connect to database
 	db, err := connectDB(cfg)
@@ -42,19 +67,72 @@ func main() {
 	}
 
 	// Initialize services
+	metrics := observability.NewRegistry()
 	storageService := services.NewStorageService(cfg)
+	statusBroker := services.NewStatusBroker()
+	processingQueue := services.NewProcessingQueue(cfg.ProcessingConcurrency)
+	fingerprints := middleware.NewFingerprintTracker(cfg.FingerprintSalt, time.Hour)
+	dbHealth := services.NewDBHealth(db, time.Duration(cfg.DBHealthIntervalSec)*time.Second)
+	dbHealth.Start()
+	degraded := middleware.NewDegradedMode(dbHealth, cfg.DegradedCacheSize, time.Duration(cfg.DegradedCacheMaxAgeMin)*time.Minute)
+	services.NewReconciler(db, time.Duration(cfg.ReconcileIntervalMin)*time.Minute).Start()
+	clusterRefresher := services.NewClusterRefresher(db, cfg)
+	registerOutboxSinks(cfg, db, clusterRefresher)
+	services.NewOutboxDispatcher(db, cfg).Start()
+	clusterRefresher.Start()
 	
 	// Initialize handlers
-	uploadHandler := handlers.NewUploadHandler(cfg, db, storageService)
-	submissionHandler := handlers.NewSubmissionHandler(cfg, db)
-	eventHandler := handlers.NewEventHandler(cfg, db)
-	adminHandler := handlers.NewAdminHandler(cfg, db)
+	uploadHandler := handlers.NewUploadHandler(cfg, db, storageService, statusBroker, processingQueue, metrics)
+	services.NewRetrySweeper(db, cfg, uploadHandler.RetryCandidate, uploadHandler.Breakers()...).Start()
+	processingQueue.WatchBreakers(uploadHandler.Breakers()...)
+	uploadHandler.Workers().Start()
+	services.NewRetryService(db, cfg, uploadHandler.Workers(), metrics).Start()
+	submissionHandler := handlers.NewSubmissionHandler(cfg, db, statusBroker, processingQueue)
+	eventHandler := handlers.NewEventHandler(cfg, db, storageService)
+	tileHandler := handlers.NewTileHandler(cfg, db)
+	adminKeys, err := services.NewAdminKeyring(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to load admin credentials: %v", err)
+	}
+	if !adminKeys.Configured() {
+		log.Println("No admin API key is configured; /admin is closed until one is generated with POST /admin/setup-key (ADMIN_BOOTSTRAP_TOKEN, or from localhost)")
+	}
+	if !services.HEICSupported(cfg) {
+		log.Printf("HEIC converter %q not found; HEIC uploads will be refused with 415 (the Dockerfile installs heif-convert)", cfg.HEICConverter)
+	}
+	adminHandler := handlers.NewAdminHandler(cfg, db, storageService, fingerprints, adminKeys, metrics)
 
 	// Setup router
-	router := setupRouter(cfg, uploadHandler, submissionHandler, eventHandler, adminHandler, storageService)
+	rateLimitDone := make(chan struct{})
+	router := setupRouter(cfg, uploadHandler, submissionHandler, eventHandler, tileHandler, adminHandler, adminKeys, storageService, fingerprints, degraded, metrics, rateLimitDone)
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		log.Printf("Starting %s API server on port %s", cfg.AppName, cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
 
-	log.Printf("Starting %s API server on port %s", cfg.AppName, cfg.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.Port, router))
+	// On SIGTERM, stop taking uploads, then let workers finish (or hand back) their jobs
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	<-stop.Done()
+
+	grace := time.Duration(cfg.ShutdownGraceSec) * time.Second
+	log.Printf("Shutting down (grace %s)", grace)
+	shutdownDeadline := time.Now().Add(grace)
+	shutdownCtx, shutdownCancel := context.WithDeadline(context.Background(), shutdownDeadline)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+	close(rateLimitDone)
+	uploadHandler.Workers().Close(time.Until(shutdownDeadline))
+	if err := tracing.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Trace export shutdown: %v", err)
+	}
+	log.Println("Shutdown complete")
 }
 
 func connectDB(cfg *config.Config) (*gorm.DB, error) {
@@ -71,6 +149,9 @@ func connectDB(cfg *config.Config) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := observability.InstrumentGORM(db); err != nil {
+		return nil, fmt.Errorf("failed to instrument database: %w", err)
+	}
 
 	// Configure connection pool
 	sqlDB, err := db.DB()
@@ -93,8 +174,12 @@ func migrateDB(db *gorm.DB) error {
 		return fmt.Errorf("failed to create postgis extension: %w", err)
 	}
 	
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "pg_trgm"`).Error; err != nil {
+		return fmt.Errorf("failed to create pg_trgm extension: %w", err)
+	}
+	
 	// Now run AutoMigrate
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&models.Submission{},
 		&models.Flyer{},
 		&models.Venue{},
@@ -103,7 +188,55 @@ func migrateDB(db *gorm.DB) error {
 		&models.DedupeLink{},
 		&models.AuditLog{},
 		&models.Flag{},
-	)
+		&models.EventChange{},
+		&models.EventHistory{},
+		&models.EventStateTransition{},
+		&models.CategoryPublishSetting{},
+		&models.OutboxEntry{},
+		&models.VenueClaim{},
+		&models.Setting{},
+		&models.SettingChange{},
+		&models.EventClusterSet{},
+		&models.ModelVersion{},
+		&models.AdminAPIKey{},
+		&models.AdminSession{},
+		&models.EventTombstone{},
+	); err != nil {
+		return err
+	}
+
+	// AutoMigrate can't declare operator classes or expression indexes, so those are created here
+	for _, index := range autoMigrateIndexes {
+		if err := db.Exec(index).Error; err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+	return nil
+}
+
+// autoMigrateIndexes are the indexes from migrations/ that AutoMigrate can't declare, and
+// the generated column one of them covers (kept out of the model so AutoMigrate leaves it be)
+var autoMigrateIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_event_candidates_search_text ON event_candidates USING GIN (search_text gin_trgm_ops)`,
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(title, '') || ' ' || coalesce(description, ''))) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_events_search_vector ON events USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_venues_location ON venues USING GIST (location)`,
+	`CREATE INDEX IF NOT EXISTS idx_venues_location_geography ON venues USING GIST ((location::geography))`,
+}
+
+// registerOutboxSinks wires up the change feed, the map cluster cache, and whichever
+// optional sinks are configured
+func registerOutboxSinks(cfg *config.Config, db *gorm.DB, clusters *services.ClusterRefresher) {
+	timeout := time.Duration(cfg.WebhookTimeoutMS) * time.Millisecond
+
+	services.RegisterOutboxSink(services.NewChangeFeedSink(db))
+	services.RegisterOutboxSink(services.NewClusterCacheSink(clusters))
+	if cfg.WebhookURL != "" {
+		services.RegisterOutboxSink(services.NewWebhookSink(cfg.WebhookURL, cfg.WebhookSecret, timeout))
+	}
+	if cfg.SlackWebhookURL != "" {
+		services.RegisterOutboxSink(services.NewSlackNotifier(cfg.SlackWebhookURL, cfg.PublicBaseURL, timeout))
+	}
 }
 
 func setupRouter(
@@ -111,8 +244,14 @@ func setupRouter(
 	uploadHandler *handlers.UploadHandler,
 	submissionHandler *handlers.SubmissionHandler,
 	eventHandler *handlers.EventHandler,
+	tileHandler *handlers.TileHandler,
 	adminHandler *handlers.AdminHandler,
+	adminKeys *services.AdminKeyring,
 	storageService *services.StorageService,
+	fingerprints *middleware.FingerprintTracker,
+	degraded *middleware.DegradedMode,
+	metrics *observability.Registry,
+	rateLimitDone <-chan struct{},
 ) *gin.Engine {
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -120,25 +259,39 @@ func setupRouter(
 
 	router := gin.Default()
 
+	// Per-IP limits and flags key on ClientIP, so only believe forwarding headers set by
+	// infrastructure we run behind; by default the TCP peer is the client
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.TrustedPlatform = cfg.TrustedPlatform
+
 	// Create template with custom functions
-	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
-		"mul": func(a, b float64) float64 {
-			return a * b
-		},
-		"ge": func(a, b float64) bool {
-			return a >= b
-		},
-		"gt": func(a, b float64) bool {
-			return a > b
-		},
-		"printf": fmt.Sprintf,
-	}).ParseGlob("api/templates/*"))
+	tmpl := template.Must(template.New("").Funcs(handlers.TemplateFuncs).ParseGlob("api/templates/*"))
 	router.SetHTMLTemplate(tmpl)
 
 	// Middleware
+	router.Use(middleware.RequestID())
 	router.Use(middleware.CORS())
+	// Per-IP token buckets in front of every route: image uploads and completions draw from
+	// the upload budget, other reads (files, admin pages, unknown paths) from the read budget.
+	// Routes listed in Exempt have their own fixed-window budget below, so each is counted
+	// once; health, readiness and metrics are never limited.
+	router.Use(middleware.RateLimit(middleware.RateLimitConfig{
+		UploadRPM:   cfg.RateLimitUploadRPM,
+		ReadRPM:     cfg.RateLimitReadRPM,
+		PartnerKeys: cfg.PartnerAPIKeys,
+		Exempt: []string{
+			"/health", "/ready", "/metrics",
+			"/v1/uploads/signed-url", "/v1/submissions", "/v1/events", "/v1/tiles", "/v1/venues",
+			"/preview", "/admin/login", "/admin/setup-key",
+		},
+		Done: rateLimitDone,
+	}))
 	router.Use(middleware.Logger())
 	router.Use(middleware.ErrorHandler())
+	router.Use(middleware.PartnerKeys(cfg.PartnerAPIKeys))
+	router.Use(fingerprints.Track())
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -148,39 +301,115 @@ func setupRouter(
 		})
 	})
 
-	// Static file serving
-	router.Static("/files", storageService.GetUploadDir())
+	// Readiness: 200 while the database is up, or down with cached reads to serve
+	router.GET("/ready", func(c *gin.Context) {
+		healthy, degradedReads := degraded.ReadsAvailable()
+		switch {
+		case healthy:
+			c.JSON(http.StatusOK, gin.H{"status": "ready"})
+		case degradedReads:
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "database": "unavailable", "reads": "cached"})
+		default:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "database": "unavailable"})
+		}
+	})
+
+	// Every admin key check, including sign-in, counts its failures per IP against one
+	// budget; a client past it gets 429 on all of them until the minute is out
+	adminFailures := middleware.NewRateLimiter(cfg.AdminAuthFailuresPerMin, time.Minute)
+
+	// Prometheus scrape target, behind the admin key (sent as a bearer token)
+	router.GET("/metrics", adminFailures.LimitFailures(), middleware.AdminAuth(adminKeys), gin.WrapH(metrics))
+
+	// Static file serving (private directories, e.g. unredacted crops, are never exposed);
+	// with remote storage, files are fetched from the bucket's URLs instead
+	if storageService.ServesLocalFiles() {
+		router.GET("/files/*filepath", handlers.ServePublicFiles(storageService))
+		router.HEAD("/files/*filepath", handlers.ServePublicFiles(storageService))
+	}
 
 	// API routes
 	v1 := router.Group("/v1")
 	{
+		// Uploads and manual entries share one per-IP budget
+		submissionLimiter := middleware.NewRateLimiter(cfg.SubmissionRateLimitPerHour, time.Hour)
+		// Public reads get a higher, separate budget
+		publicLimiter := middleware.NewRateLimiter(cfg.PublicRateLimitPerMin, time.Minute)
+		// Map views fetch many tiles per pan, so tiles get their own budget
+		tileLimiter := middleware.NewRateLimiter(cfg.TileRateLimitPerMin, time.Minute)
+		// Flags across all events, on top of the per-event daily limit
+		flagLimiter := middleware.NewRateLimiter(cfg.FlagRateLimitPerHour, time.Hour)
+
 		// Upload endpoints
-		uploads := v1.Group("/uploads")
+		uploads := v1.Group("/uploads", degraded.Require())
 		{
-			uploads.POST("/signed-url", uploadHandler.GetSignedURL)
+			uploads.POST("/signed-url", submissionLimiter.Limit(), uploadHandler.GetSignedURL)
 			uploads.PUT("/:id", uploadHandler.UploadFile)
+			uploads.POST("/:id/complete", uploadHandler.CompleteUpload)
 		}
 
 		// Submission endpoints (for checking results after upload)
-		submissions := v1.Group("/submissions")
+		submissions := v1.Group("/submissions", degraded.Require())
 		{
-			submissions.GET("/:id/status", submissionHandler.GetStatus)
+			submissions.POST("/manual", submissionLimiter.Limit(), uploadHandler.CreateManualSubmission)
+			submissions.GET("/:id/status", publicLimiter.Limit(), submissionHandler.GetStatus)
 		}
 
-		// Event endpoints
-		events := v1.Group("/events")
+		// Event endpoints; reads are served from cache while the database is down
+		events := v1.Group("/events", degraded.Reads())
 		{
-			events.GET("", eventHandler.List)
-			events.GET("/:id", eventHandler.Get)
-			events.GET("/:id/ics", eventHandler.GetICS)
-			events.POST("/:id/unpublish", eventHandler.Unpublish)
+			publicRead := publicLimiter.Limit()
+			events.GET("", publicRead, eventHandler.List)
+			events.GET("/changes", publicRead, eventHandler.Changes)
+			events.GET("/near", publicRead, eventHandler.Near)
+			events.GET("/calendar.ics", publicRead, eventHandler.CalendarFeed)
+			events.GET("/feed.rss", publicRead, eventHandler.RSSFeed)
+			events.GET("/feed.atom", publicRead, eventHandler.AtomFeed)
+			events.GET("/clusters", publicRead, tileHandler.EventClusters)
+			events.GET("/:id", publicRead, eventHandler.Get)
+			events.GET("/:id/ics", publicRead, eventHandler.GetICS)
+			events.GET("/:id/image", publicRead, eventHandler.GetImage)
+			events.POST("/:id/unpublish", adminFailures.LimitFailures(), middleware.AdminAuth(adminKeys), eventHandler.Unpublish)
+			events.POST("/:id/flag", flagLimiter.Limit(), eventHandler.Flag)
 		}
+
+		// Map vector tiles
+		v1.GET("/tiles/events/:z/:x/:y", tileLimiter.Limit(), degraded.Reads(), tileHandler.EventTile)
+
+		// A single venue's calendar feed
+		v1.GET("/venues/:id/calendar.ics", publicLimiter.Limit(), degraded.Reads(), eventHandler.VenueCalendar)
+
+		// Venue managers dispute events attributed to their venue with a claim token
+		v1.POST("/venues/:id/events/:event_id/dispute", publicLimiter.Limit(), degraded.Require(), eventHandler.Dispute)
 	}
 
-	// Admin routes
-	admin := router.Group("/admin")
+	// Signed candidate previews for organizers; the signature stands in for admin auth
+	previewLimiter := middleware.NewRateLimiter(cfg.PublicRateLimitPerMin, time.Minute)
+	router.GET("/preview/candidates/:id", previewLimiter.Limit(), degraded.Require(), adminHandler.SignedCandidatePreview)
+
+	// Dashboard sign-in sits outside the admin group, with sign-in attempts rate limited
+	// per IP like other public endpoints
+	loginLimiter := middleware.NewRateLimiter(cfg.PublicRateLimitPerMin, time.Minute)
+	router.GET("/admin/login", adminHandler.AdminLoginPage)
+	router.POST("/admin/login", loginLimiter.Limit(), adminFailures.LimitFailures(), degraded.Require(), adminHandler.AdminLogin)
+	router.POST("/admin/logout", adminHandler.AdminLogout)
+
+	// The first admin key needs ADMIN_BOOTSTRAP_TOKEN or a loopback caller; later ones an admin key
+	router.POST("/admin/setup-key", loginLimiter.Limit(), adminFailures.LimitFailures(), degraded.Require(), middleware.AdminBootstrapAuth(adminKeys, cfg.AdminBootstrapToken), adminHandler.SetupAdminKey)
+
+	// Admin routes, all behind an admin API key; with none configured they are closed.
+	// Admin pages and writes return 503 while the database is down.
+	admin := router.Group("/admin", adminFailures.LimitFailures(), degraded.Require(), middleware.AdminAuth(adminKeys))
 	{
 		handlers.RegisterAdminRoutes(admin, adminHandler)
+		admin.POST("/submissions/:id/retry", uploadHandler.RetrySubmission)
+		admin.POST("/api/submissions/:id/retry", uploadHandler.RetrySubmission)
+		admin.POST("/submissions/:id/reprocess", uploadHandler.ReprocessSubmission)
+		admin.POST("/api/submissions/:id/reprocess", uploadHandler.ReprocessSubmission)
+		admin.GET("/api/retries", uploadHandler.ListRetries)
+		admin.GET("/api/stats/geocoding", uploadHandler.GetGeocodingStats)
+		admin.POST("/api/retries/:id", uploadHandler.ForceRetryCandidate)
+		admin.POST("/flyers/:id/reextract", uploadHandler.ReextractFlyer)
 	}
 
 	return router
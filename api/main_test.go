@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/handlers"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/middleware"
+	"github.com/lincolngreen/williamboard/api/observability"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// testRouter builds the server's router on a database that answers nothing. Templates are
+// loaded relative to the repository root, as the server runs.
+func testRouter(t *testing.T, cfg *config.Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	db, _ := testdb.New(t)
+	cfg.UploadDir = t.TempDir()
+	metrics := observability.NewRegistry()
+	storage := services.NewStorageService(cfg)
+	broker := services.NewStatusBroker()
+	queue := services.NewProcessingQueue(1)
+	fingerprints := middleware.NewFingerprintTracker("salt", time.Hour)
+	degraded := middleware.NewDegradedMode(services.NewDBHealth(db, 0), 10, time.Minute)
+	adminKeys, err := services.NewAdminKeyring(db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+
+	return setupRouter(cfg,
+		handlers.NewUploadHandler(cfg, db, storage, broker, queue, metrics),
+		handlers.NewSubmissionHandler(cfg, db, broker, queue),
+		handlers.NewEventHandler(cfg, db, storage),
+		handlers.NewTileHandler(cfg, db),
+		handlers.NewAdminHandler(cfg, db, storage, fingerprints, adminKeys, metrics),
+		adminKeys, storage, fingerprints, degraded, metrics, done)
+}
+
+// TestRouteRateLimits checks each route draws on exactly one per-IP budget: its own where
+// it has one, the token buckets otherwise (unknown paths included), and none at all for
+// health checks. The buckets
+// allow one request a minute, so a route also counted against them would be limited early.
+func TestRouteRateLimits(t *testing.T) {
+	type call struct {
+		method, path, body string
+		times              int
+	}
+	tests := []struct {
+		name        string
+		calls       []call
+		wantLimited int
+	}{
+		{
+			name:  "health and readiness checks are never limited",
+			calls: []call{{method: http.MethodGet, path: "/health", times: 50}, {method: http.MethodGet, path: "/ready", times: 50}},
+		},
+		{
+			name:        "public reads spend only the public budget",
+			calls:       []call{{method: http.MethodGet, path: "/v1/events/not-an-id", times: 5}},
+			wantLimited: 2,
+		},
+		{
+			name:        "flags spend only the flag budget",
+			calls:       []call{{method: http.MethodPost, path: "/v1/events/not-an-id/flag", body: `{}`, times: 4}},
+			wantLimited: 2,
+		},
+		{
+			name: "flagging leaves the read budget alone",
+			calls: []call{
+				{method: http.MethodPost, path: "/v1/events/not-an-id/flag", body: `{}`, times: 2},
+				{method: http.MethodGet, path: "/v1/events/not-an-id", times: 3},
+			},
+		},
+		{
+			name:        "upload completion uses the token buckets",
+			calls:       []call{{method: http.MethodPost, path: "/v1/uploads/not-an-id/complete", times: 3}},
+			wantLimited: 2,
+		},
+		{
+			name:        "tiles spend only the tile budget",
+			calls:       []call{{method: http.MethodGet, path: "/v1/tiles/events/1/0/0", times: 5}},
+			wantLimited: 1,
+		},
+		{
+			name:        "dashboard sign-in spends only the sign-in budget",
+			calls:       []call{{method: http.MethodPost, path: "/admin/login", body: `{}`, times: 5}},
+			wantLimited: 2,
+		},
+		{
+			name:        "admin pages use the token buckets",
+			calls:       []call{{method: http.MethodGet, path: "/admin/api/stats/tokens", times: 3}},
+			wantLimited: 2,
+		},
+		{
+			name:        "unknown paths use the token buckets",
+			calls:       []call{{method: http.MethodGet, path: "/wp-login.php", times: 3}},
+			wantLimited: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := testRouter(t, &config.Config{
+				RateLimitReadRPM:      1,
+				RateLimitUploadRPM:    1,
+				PublicRateLimitPerMin: 3,
+				FlagRateLimitPerHour:  2,
+				TileRateLimitPerMin:   4,
+				FlagLimitPerDay:       5,
+			})
+
+			limited := 0
+			for _, call := range tt.calls {
+				for i := 0; i < call.times; i++ {
+					req := httptest.NewRequest(call.method, call.path, strings.NewReader(call.body))
+					req.Header.Set("Content-Type", "application/json")
+					req.RemoteAddr = "198.51.100.4:443"
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, req)
+					if w.Code == http.StatusTooManyRequests {
+						limited++
+					}
+				}
+			}
+			if limited != tt.wantLimited {
+				t.Errorf("%d requests limited, want %d", limited, tt.wantLimited)
+			}
+		})
+	}
+}
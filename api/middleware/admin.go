@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminIDHeader names the moderator acting on an admin request; the dashboard sends the
+// same value in the AdminIDCookie cookie. There are no per-moderator credentials yet, so
+// the name is self-declared by whoever holds an admin key.
+const (
+	AdminIDHeader = "X-Admin-Id"
+	AdminIDCookie = "wb_admin_id"
+)
+
+// DefaultAdminID is recorded when an admin request does not name a moderator
+const DefaultAdminID = "admin"
+
+// maxAdminIDLength matches audit_logs.admin_id
+const maxAdminIDLength = 100
+
+// AdminID returns the moderator named by the request, or DefaultAdminID
+func AdminID(c *gin.Context) string {
+	id := strings.TrimSpace(c.GetHeader(AdminIDHeader))
+	if id == "" {
+		if cookie, err := c.Cookie(AdminIDCookie); err == nil {
+			id = strings.TrimSpace(cookie)
+		}
+	}
+	if id == "" {
+		return DefaultAdminID
+	}
+	if len(id) > maxAdminIDLength {
+		id = id[:maxAdminIDLength]
+	}
+	return id
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// partnerContextKey marks requests authenticated with a partner API key
+const partnerContextKey = "partner"
+
+// APIKeyHeader carries partner API keys; the api_key query parameter is also accepted
+const APIKeyHeader = "X-API-Key"
+
+// PartnerKeys identifies requests carrying a configured partner API key. Requests without
+// a key continue anonymously; an unrecognized key is rejected so typos aren't silently throttled.
+func PartnerKeys(keys []string) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		presented := presentedPartnerKey(c)
+		if presented == "" {
+			c.Next()
+			return
+		}
+
+		if isPartnerKey(keys, presented) {
+			c.Set(partnerContextKey, true)
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "invalid_api_key",
+				"message": "Invalid API key",
+			},
+		})
+	})
+}
+
+// IsPartner reports whether the request was authenticated with a partner API key
+func IsPartner(c *gin.Context) bool {
+	return c.GetBool(partnerContextKey)
+}
+
+// presentedPartnerKey returns the partner API key a request carries, or ""
+func presentedPartnerKey(c *gin.Context) string {
+	if presented := c.GetHeader(APIKeyHeader); presented != "" {
+		return presented
+	}
+	return c.Query("api_key")
+}
+
+// isPartnerKey reports whether presented is one of the configured partner keys
+func isPartnerKey(keys []string, presented string) bool {
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
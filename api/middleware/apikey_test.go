@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPartnerKeys(t *testing.T) {
+	router := gin.New()
+	router.Use(PartnerKeys([]string{"partner-one", "partner-two"}))
+	router.GET("/v1/events", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"partner": IsPartner(c)})
+	})
+
+	tests := []struct {
+		name        string
+		header      string
+		query       string
+		wantCode    int
+		wantPartner string
+	}{
+		{"anonymous", "", "", http.StatusOK, `{"partner":false}`},
+		{"key header", "partner-two", "", http.StatusOK, `{"partner":true}`},
+		{"key query parameter", "", "?api_key=partner-one", http.StatusOK, `{"partner":true}`},
+		{"unknown key", "partner-three", "", http.StatusUnauthorized, ""},
+		{"key prefix", "partner", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/events"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set(APIKeyHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantPartner != "" && w.Body.String() != tt.wantPartner {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantPartner)
+			}
+		})
+	}
+}
+
+func TestPublicReadLimit(t *testing.T) {
+	// The defaults: 120 public reads per minute per IP
+	const perMinute = 120
+
+	tests := []struct {
+		name string
+		// requests made back to back from one IP
+		requests int
+		apiKey   string
+		want429  int
+	}{
+		// Opening the map and panning around: a list, a few near searches and event pages
+		{"map browsing", 40, "", 0},
+		{"scraper walking offsets", 200, "", 80},
+		{"partner backfill", 200, "partner-key", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(perMinute, time.Minute)
+			router := gin.New()
+			router.Use(PartnerKeys([]string{"partner-key"}))
+			router.GET("/v1/events", limiter.Limit(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			limited := 0
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/v1/events?offset=20", nil)
+				req.RemoteAddr = "198.51.100.4:443"
+				if tt.apiKey != "" {
+					req.Header.Set(APIKeyHeader, tt.apiKey)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code == http.StatusTooManyRequests {
+					limited++
+				}
+			}
+			if limited != tt.want429 {
+				t.Errorf("%d of %d requests limited, want %d", limited, tt.requests, tt.want429)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyHeader carries an admin API key; "Authorization: Bearer <key>" is also accepted
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminSessionCookie holds the dashboard's signed session token, set by signing in at
+// /admin/login. It names the admin key the session was opened with, never the key itself.
+const AdminSessionCookie = "wb_admin_session"
+
+// AdminRealm is announced in WWW-Authenticate on rejected admin requests
+const AdminRealm = "williamboard-admin"
+
+// AdminLoginTemplate is rendered for dashboard pages opened without a valid key
+const AdminLoginTemplate = "admin_login.html"
+
+// AdminKeyVerifier checks admin API keys and dashboard sessions
+type AdminKeyVerifier interface {
+	// Configured reports whether any admin credential exists
+	Configured() bool
+	// Verify reports whether key is a valid admin credential
+	Verify(key string) bool
+	// VerifySession reports whether token is a live dashboard session
+	VerifySession(token string) bool
+}
+
+// AdminAuth requires a valid admin API key on every route it wraps, taken from
+// "Authorization: Bearer <key>" or the X-Admin-Key header, or else a live dashboard
+// session cookie. With no credential configured every request is refused until a first
+// key is made through AdminBootstrapAuth. Rejected requests get a 401 with
+// WWW-Authenticate; a browser opening a dashboard page gets the sign-in form instead of JSON.
+func AdminAuth(keys AdminKeyVerifier) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if adminAuthorized(c, keys) {
+			c.Next()
+			return
+		}
+		rejectAdmin(c)
+	})
+}
+
+// adminAuthorized reports whether the request carries a valid admin key or, without one,
+// a live dashboard session
+func adminAuthorized(c *gin.Context, keys AdminKeyVerifier) bool {
+	if key := PresentedAdminKey(c); key != "" {
+		return keys.Verify(key)
+	}
+	token, err := c.Cookie(AdminSessionCookie)
+	return err == nil && keys.VerifySession(token)
+}
+
+// AdminBootstrapAuth guards generating admin keys. Once any credential exists it is
+// AdminAuth. Before that, the first key can only be generated by a caller presenting
+// bootstrapToken (ADMIN_BOOTSTRAP_TOKEN) as its key, or, with no bootstrap token set, by
+// one connecting from the loopback interface. The peer address is used rather than the
+// client IP, which proxies can supply.
+func AdminBootstrapAuth(keys AdminKeyVerifier, bootstrapToken string) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if keys.Configured() {
+			if adminAuthorized(c, keys) {
+				c.Next()
+				return
+			}
+			rejectAdmin(c)
+			return
+		}
+
+		presented := PresentedAdminKey(c)
+
+		if bootstrapToken != "" {
+			if presented != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(bootstrapToken)) == 1 {
+				c.Next()
+				return
+			}
+		} else if isLoopbackPeer(c.Request) {
+			c.Next()
+			return
+		}
+		c.Header("WWW-Authenticate", `Bearer realm="`+AdminRealm+`"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "No admin key is configured; present ADMIN_BOOTSTRAP_TOKEN, or call from localhost when it is unset",
+		})
+	})
+}
+
+// isLoopbackPeer reports whether the request's TCP peer is on this machine
+func isLoopbackPeer(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// rejectAdmin answers a request without a valid admin key
+func rejectAdmin(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer realm="`+AdminRealm+`"`)
+	if c.Request.Method == http.MethodGet && c.GetHeader("HX-Request") == "" &&
+		strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.HTML(http.StatusUnauthorized, AdminLoginTemplate, gin.H{
+			"title": "Sign in",
+			"next":  c.Request.URL.RequestURI(),
+		})
+		c.Abort()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Admin API key required"})
+}
+
+// PresentedAdminKey returns the admin API key a request's headers carry, or ""
+func PresentedAdminKey(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return strings.TrimSpace(c.GetHeader(AdminKeyHeader))
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/services"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testKeyring returns a keyring holding envKey as ADMIN_API_KEY_HASH (if set) and the
+// generated keys, read once from the database, and the mock behind it
+func testKeyring(t *testing.T, envKey string, generated ...string) (*services.AdminKeyring, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testdb.New(t)
+	rows := sqlmock.NewRows([]string{"id", "key_hash"})
+	for _, key := range generated {
+		hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.AddRow(uuid.New().String(), string(hash))
+	}
+	mock.ExpectQuery(`SELECT "id","key_hash" FROM "admin_api_keys" WHERE revoked_at IS NULL ORDER BY created_at DESC`).
+		WillReturnRows(rows)
+
+	cfg := &config.Config{AdminSessionSecret: "session-secret"}
+	if envKey != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(envKey), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.AdminAPIKeyHash = string(hash)
+	}
+	keyring, err := services.NewAdminKeyring(db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring, mock
+}
+
+// adminTestRouter serves /admin/stats behind AdminAuth and /admin/setup-key behind
+// AdminBootstrapAuth, with a stand-in sign-in page
+func adminTestRouter(keys AdminKeyVerifier, bootstrapToken string) *gin.Engine {
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New(AdminLoginTemplate).Parse(`sign in, then {{.next}}`)))
+	router.POST("/admin/setup-key", AdminBootstrapAuth(keys, bootstrapToken), func(c *gin.Context) { c.Status(http.StatusCreated) })
+	admin := router.Group("/admin", AdminAuth(keys))
+	admin.GET("/stats", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	return router
+}
+
+func TestAdminAuth(t *testing.T) {
+	keyring, mock := testKeyring(t, "env-key", "wbk_generated")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "admin_sessions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New().String(), time.Now()))
+	mock.ExpectCommit()
+	session, _, err := keyring.StartSession("env-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := adminTestRouter(keyring, "")
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		cookie     string // the session cookie
+		cookieName string // defaults to AdminSessionCookie
+		html       bool
+		wantCode   int
+		wantBody   string
+	}{
+		{name: "bearer token", header: "Authorization", value: "Bearer env-key", wantCode: http.StatusOK},
+		{name: "lower-case bearer scheme", header: "Authorization", value: "bearer wbk_generated", wantCode: http.StatusOK},
+		{name: "key header", header: AdminKeyHeader, value: "wbk_generated", wantCode: http.StatusOK},
+		{name: "dashboard session", cookie: session, wantCode: http.StatusOK},
+		{name: "key in the session cookie", cookie: "env-key", wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "key in the old key cookie", cookie: "env-key", cookieName: "wb_admin_key", wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "wrong key beside a session", header: AdminKeyHeader, value: "wbk_guess", cookie: session, wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "no key", wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "wrong bearer token", header: "Authorization", value: "Bearer wbk_guess", wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "wrong key header", header: AdminKeyHeader, value: "env-key-2", wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "key prefix", header: AdminKeyHeader, value: "wbk_", wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "basic scheme", header: "Authorization", value: "Basic env-key", wantCode: http.StatusUnauthorized, wantBody: "Admin API key required"},
+		{name: "browser gets the sign-in page", html: true, wantCode: http.StatusUnauthorized, wantBody: "sign in, then /admin/stats?days=7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/stats?days=7", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			if tt.cookie != "" {
+				name := tt.cookieName
+				if name == "" {
+					name = AdminSessionCookie
+				}
+				req.AddCookie(&http.Cookie{Name: name, Value: tt.cookie})
+			}
+			if tt.html {
+				req.Header.Set("Accept", "text/html,application/xhtml+xml")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Fatalf("status = %d, body %s; want %d with %q", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+			wantChallenge := ""
+			if tt.wantCode == http.StatusUnauthorized {
+				wantChallenge = `Bearer realm="williamboard-admin"`
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != wantChallenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, wantChallenge)
+			}
+		})
+	}
+}
+
+func TestAdminBootstrapAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured bool // a generated key already exists
+		token      string
+		remoteAddr string
+		key        string
+		wantCode   int
+	}{
+		{name: "first key from localhost", remoteAddr: "127.0.0.1:50412", wantCode: http.StatusCreated},
+		{name: "first key from IPv6 localhost", remoteAddr: "[::1]:50412", wantCode: http.StatusCreated},
+		{name: "first key from elsewhere", remoteAddr: "203.0.113.9:50412", wantCode: http.StatusUnauthorized},
+		{name: "bootstrap token from elsewhere", token: "boot-secret", remoteAddr: "203.0.113.9:50412", key: "boot-secret", wantCode: http.StatusCreated},
+		{name: "wrong bootstrap token", token: "boot-secret", remoteAddr: "203.0.113.9:50412", key: "boot-guess", wantCode: http.StatusUnauthorized},
+		{name: "bootstrap token set, localhost needs it", token: "boot-secret", remoteAddr: "127.0.0.1:50412", wantCode: http.StatusUnauthorized},
+		{name: "rotation with the current key", configured: true, remoteAddr: "203.0.113.9:50412", key: "wbk_current", wantCode: http.StatusCreated},
+		{name: "rotation without a key, even from localhost", configured: true, remoteAddr: "127.0.0.1:50412", wantCode: http.StatusUnauthorized},
+		{name: "bootstrap token is no key once one exists", configured: true, token: "boot-secret", remoteAddr: "203.0.113.9:50412", key: "boot-secret", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keyring *services.AdminKeyring
+			if tt.configured {
+				keyring, _ = testKeyring(t, "", "wbk_current")
+			} else {
+				keyring, _ = testKeyring(t, "")
+			}
+			router := adminTestRouter(keyring, tt.token)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/setup-key", nil)
+			req.RemoteAddr = tt.remoteAddr
+			// A proxy's client IP headers never count as localhost
+			req.Header.Set("X-Forwarded-For", "127.0.0.1")
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, body %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+		})
+	}
+}
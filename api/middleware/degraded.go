@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// DegradedHeader marks a response served from the read cache while the database is down
+const DegradedHeader = "X-WilliamBoard-Degraded"
+
+// maxCachedResponseBytes keeps large bodies (full-size images, big feeds) out of the cache
+const maxCachedResponseBytes = 1 << 20
+
+// cachedHeaders are the response headers replayed with a cached body
+var cachedHeaders = []string{"Content-Type", "Content-Disposition", "ETag", "Last-Modified"}
+
+// DegradedMode keeps public reads working while the database is briefly unreachable.
+// Successful GET responses on read routes are kept in memory; when the health watcher
+// reports the database down, read routes replay them and everything else returns 503.
+type DegradedMode struct {
+	health *services.DBHealth
+	cache  *responseCache
+}
+
+// NewDegradedMode caches up to size read responses and serves them for at most maxAge
+// while the database is down. A size <= 0 disables the cache, so reads 503 like writes.
+func NewDegradedMode(health *services.DBHealth, size int, maxAge time.Duration) *DegradedMode {
+	return &DegradedMode{
+		health: health,
+		cache: &responseCache{
+			maxEntries: size,
+			maxAge:     maxAge,
+			entries:    make(map[string]cachedResponse),
+		},
+	}
+}
+
+// ReadsAvailable reports whether the database is down, and if so whether any cached reads
+// are fresh enough to serve
+func (d *DegradedMode) ReadsAvailable() (healthy bool, degradedReads bool) {
+	healthy, _ = d.health.Healthy()
+	if healthy {
+		return true, false
+	}
+	return false, d.cache.freshEntries(time.Now()) > 0
+}
+
+// Reads serves a route group's GET requests from the cache while the database is down and
+// refuses its writes. While the database is up, successful GET responses are cached.
+func (d *DegradedMode) Reads() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			d.require(c)
+			return
+		}
+
+		// Partners see more than anonymous callers, so they get their own entries
+		key := c.Request.URL.RequestURI()
+		if IsPartner(c) {
+			key = "partner:" + key
+		}
+		if healthy, _ := d.health.Healthy(); !healthy {
+			if response, ok := d.cache.get(key, time.Now()); ok {
+				for name, value := range response.header {
+					c.Header(name, value)
+				}
+				c.Header(DegradedHeader, "true")
+				c.Header("Cache-Control", "no-cache")
+				c.Header("Age", strconv.Itoa(int(time.Since(response.storedAt).Seconds())))
+				c.Data(response.status, response.header["Content-Type"], response.body)
+				c.Abort()
+				return
+			}
+			abortUnavailable(c)
+			return
+		}
+
+		if d.cache.maxEntries <= 0 {
+			c.Next()
+			return
+		}
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			// The database may have gone away since the last ping; don't wait for the next one
+			go d.health.Check(context.Background())
+			return
+		}
+		if c.Writer.Status() != http.StatusOK || recorder.overflow {
+			return
+		}
+		header := make(map[string]string)
+		for _, name := range cachedHeaders {
+			if value := c.Writer.Header().Get(name); value != "" {
+				header[name] = value
+			}
+		}
+		d.cache.put(key, cachedResponse{
+			status:   http.StatusOK,
+			header:   header,
+			body:     recorder.body.Bytes(),
+			storedAt: time.Now(),
+		})
+	})
+}
+
+// Require returns 503 while the database is down, for routes with nothing to serve from cache
+func (d *DegradedMode) Require() gin.HandlerFunc {
+	return gin.HandlerFunc(d.require)
+}
+
+func (d *DegradedMode) require(c *gin.Context) {
+	if healthy, _ := d.health.Healthy(); !healthy {
+		abortUnavailable(c)
+		return
+	}
+	c.Next()
+}
+
+func abortUnavailable(c *gin.Context) {
+	c.Header("Retry-After", "30")
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+		"error": gin.H{
+			"code":    "database_unavailable",
+			"message": "The database is temporarily unavailable. Please try again shortly.",
+		},
+	})
+}
+
+// responseRecorder copies what a handler writes, giving up past maxCachedResponseBytes
+type responseRecorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.record(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.record([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *responseRecorder) record(data []byte) {
+	if r.overflow {
+		return
+	}
+	if r.body.Len()+len(data) > maxCachedResponseBytes {
+		r.overflow = true
+		r.body = bytes.Buffer{}
+		return
+	}
+	r.body.Write(data)
+}
+
+type cachedResponse struct {
+	status   int
+	header   map[string]string
+	body     []byte
+	storedAt time.Time
+}
+
+// responseCache holds the latest successful response per request URI
+type responseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxAge     time.Duration
+	entries    map[string]cachedResponse
+}
+
+// get returns a response stored within maxAge
+func (r *responseCache) get(key string, now time.Time) (cachedResponse, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	response, ok := r.entries[key]
+	if !ok || now.Sub(response.storedAt) > r.maxAge {
+		return cachedResponse{}, false
+	}
+	return response, true
+}
+
+// put stores a response, evicting expired entries and then the oldest when full
+func (r *responseCache) put(key string, response cachedResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[key]; !ok && len(r.entries) >= r.maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range r.entries {
+			if response.storedAt.Sub(entry.storedAt) > r.maxAge {
+				delete(r.entries, k)
+				continue
+			}
+			if oldestKey == "" || entry.storedAt.Before(oldest) {
+				oldestKey, oldest = k, entry.storedAt
+			}
+		}
+		if len(r.entries) >= r.maxEntries {
+			delete(r.entries, oldestKey)
+		}
+	}
+	r.entries[key] = response
+}
+
+// freshEntries counts the responses that could still be served
+func (r *responseCache) freshEntries(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fresh := 0
+	for _, entry := range r.entries {
+		if now.Sub(entry.storedAt) <= r.maxAge {
+			fresh++
+		}
+	}
+	return fresh
+}
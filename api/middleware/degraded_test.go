@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/services"
+)
+
+// TestDegradedReadsSurviveDatabaseOutage serves a read while the database is up, takes the
+// database away, and checks the read keeps working from the cache while everything that
+// needs the database gets a 503
+func TestDegradedReadsSurviveDatabaseOutage(t *testing.T) {
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`SELECT title FROM events`).
+		WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Open Mic").AddRow("Seed Swap"))
+
+	health := services.NewDBHealth(db, 0)
+	degraded := NewDegradedMode(health, 10, time.Hour)
+	router := gin.New()
+	events := router.Group("/v1/events", degraded.Reads())
+	events.GET("", func(c *gin.Context) {
+		var titles []string
+		if err := db.Raw(`SELECT title FROM events`).Scan(&titles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"events": titles})
+	})
+	events.POST("/:id/flag", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	router.GET("/admin/stats", degraded.Require(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	live := serve(http.MethodGet, "/v1/events?days=7")
+	if live.Code != http.StatusOK || live.Header().Get(DegradedHeader) != "" {
+		t.Fatalf("live read = %d (degraded %q), want a fresh 200", live.Code, live.Header().Get(DegradedHeader))
+	}
+	if healthy, degradedReads := degraded.ReadsAvailable(); !healthy || degradedReads {
+		t.Errorf("ReadsAvailable() = %v, %v with the database up; want true, false", healthy, degradedReads)
+	}
+
+	// The database goes away and the next health check notices
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	if health.Check(context.Background()) {
+		t.Fatal("health check passed with the database closed")
+	}
+	if healthy, degradedReads := degraded.ReadsAvailable(); healthy || !degradedReads {
+		t.Errorf("ReadsAvailable() = %v, %v during the outage; want false, true", healthy, degradedReads)
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		wantCode     int
+		wantDegraded bool
+	}{
+		{"cached read", http.MethodGet, "/v1/events?days=7", http.StatusOK, true},
+		{"read never cached", http.MethodGet, "/v1/events?days=30", http.StatusServiceUnavailable, false},
+		{"write on a read route", http.MethodPost, "/v1/events/1/flag", http.StatusServiceUnavailable, false},
+		{"admin route", http.MethodGet, "/admin/stats", http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.method, tt.path)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if got := w.Header().Get(DegradedHeader) == "true"; got != tt.wantDegraded {
+				t.Errorf("%s = %q, want degraded %v", DegradedHeader, w.Header().Get(DegradedHeader), tt.wantDegraded)
+			}
+			if tt.wantDegraded {
+				if w.Body.String() != live.Body.String() || w.Header().Get("Content-Type") != live.Header().Get("Content-Type") {
+					t.Errorf("cached read = %s (%s), want the live %s", w.Body, w.Header().Get("Content-Type"), live.Body)
+				}
+				if w.Header().Get("Age") == "" {
+					t.Error("cached read has no Age")
+				}
+				return
+			}
+			if !strings.Contains(w.Body.String(), "database_unavailable") || w.Header().Get("Retry-After") == "" {
+				t.Errorf("503 = %s (Retry-After %q), want database_unavailable with Retry-After", w.Body, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	now := time.Date(2026, 6, 12, 12, 0, 0, 0, time.UTC)
+	stored := func(age time.Duration) cachedResponse {
+		return cachedResponse{status: http.StatusOK, body: []byte("{}"), storedAt: now.Add(-age)}
+	}
+
+	tests := []struct {
+		name      string
+		existing  map[string]time.Duration // key -> age of entries already cached
+		put       string
+		wantKeys  []string
+		wantGone  []string
+		wantFresh int
+	}{
+		{
+			name:      "room to spare",
+			existing:  map[string]time.Duration{"/v1/events": time.Minute},
+			put:       "/v1/events/1",
+			wantKeys:  []string{"/v1/events", "/v1/events/1"},
+			wantFresh: 2,
+		},
+		{
+			name:      "full cache drops the oldest",
+			existing:  map[string]time.Duration{"/v1/events": time.Minute, "/v1/events/1": 5 * time.Minute, "/v1/events/2": 2 * time.Minute},
+			put:       "/v1/events/3",
+			wantKeys:  []string{"/v1/events", "/v1/events/2", "/v1/events/3"},
+			wantGone:  []string{"/v1/events/1"},
+			wantFresh: 3,
+		},
+		{
+			name:      "full cache drops expired entries first",
+			existing:  map[string]time.Duration{"/v1/events": 2 * time.Hour, "/v1/events/1": 3 * time.Hour, "/v1/events/2": time.Minute},
+			put:       "/v1/events/3",
+			wantKeys:  []string{"/v1/events/2", "/v1/events/3"},
+			wantGone:  []string{"/v1/events", "/v1/events/1"},
+			wantFresh: 2,
+		},
+		{
+			name:      "replacing an entry evicts nothing",
+			existing:  map[string]time.Duration{"/v1/events": time.Minute, "/v1/events/1": 5 * time.Minute, "/v1/events/2": 2 * time.Minute},
+			put:       "/v1/events/1",
+			wantKeys:  []string{"/v1/events", "/v1/events/1", "/v1/events/2"},
+			wantFresh: 3,
+		},
+		{
+			name:      "expired entries are not served",
+			existing:  map[string]time.Duration{"/v1/events": 90 * time.Minute},
+			put:       "/v1/events/1",
+			wantKeys:  []string{"/v1/events/1"},
+			wantGone:  []string{"/v1/events"},
+			wantFresh: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &responseCache{maxEntries: 3, maxAge: time.Hour, entries: make(map[string]cachedResponse)}
+			for key, age := range tt.existing {
+				cache.entries[key] = stored(age)
+			}
+			cache.put(tt.put, stored(0))
+
+			for _, key := range tt.wantKeys {
+				if _, ok := cache.get(key, now); !ok {
+					t.Errorf("%s not served from the cache", key)
+				}
+			}
+			for _, key := range tt.wantGone {
+				if _, ok := cache.get(key, now); ok {
+					t.Errorf("%s still served from the cache", key)
+				}
+			}
+			if got := cache.freshEntries(now); got != tt.wantFresh {
+				t.Errorf("freshEntries() = %d, want %d", got, tt.wantFresh)
+			}
+		})
+	}
+}
+
+func TestDegradedReadsCacheOnlyWhatCanBeReplayed(t *testing.T) {
+	db, _ := testdb.New(t)
+	degraded := NewDegradedMode(services.NewDBHealth(db, 0), 10, time.Hour)
+	router := gin.New()
+	router.Use(PartnerKeys([]string{"partner-key"}))
+	events := router.Group("/v1/events", degraded.Reads())
+	events.GET("/missing", func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"}) })
+	events.GET("/huge", func(c *gin.Context) { c.Data(http.StatusOK, "text/calendar", make([]byte, maxCachedResponseBytes+1)) })
+	events.GET("", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"partner": IsPartner(c)}) })
+
+	for _, path := range []string{"/v1/events/missing", "/v1/events/huge", "/v1/events"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	partner := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	partner.Header.Set(APIKeyHeader, "partner-key")
+	router.ServeHTTP(httptest.NewRecorder(), partner)
+
+	tests := []struct {
+		key        string
+		wantCached bool
+		wantBody   string
+	}{
+		{"/v1/events/missing", false, ""},
+		{"/v1/events/huge", false, ""},
+		{"/v1/events", true, `{"partner":false}`},
+		{"partner:/v1/events", true, `{"partner":true}`},
+	}
+	for _, tt := range tests {
+		response, ok := degraded.cache.get(tt.key, time.Now())
+		if ok != tt.wantCached || (ok && string(response.body) != tt.wantBody) {
+			t.Errorf("cache[%s] = %q, %v; want %q, %v", tt.key, response.body, ok, tt.wantBody, tt.wantCached)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fingerprintContextKey holds the hashed fingerprint for logging
+const fingerprintContextKey = "fingerprint"
+
+// maxTrackedFingerprints bounds memory; the tracker resets its window when exceeded
+const maxTrackedFingerprints = 10000
+
+// FingerprintStats aggregates requests from one hashed client fingerprint
+type FingerprintStats struct {
+	Fingerprint string    `json:"fingerprint"`
+	Requests    int       `json:"requests"`
+	Throttled   int       `json:"throttled"`
+	Partner     bool      `json:"partner"`
+	TopPath     string    `json:"top_path"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+
+	paths map[string]int
+}
+
+// FingerprintTracker counts requests per hashed (IP, User-Agent, Accept-Language) in a
+// rolling window. Raw client details are never stored.
+type FingerprintTracker struct {
+	mu          sync.Mutex
+	salt        []byte
+	window      time.Duration
+	windowStart time.Time
+	stats       map[string]*FingerprintStats
+}
+
+// NewFingerprintTracker hashes with salt; an empty salt gets a random per-process value
+func NewFingerprintTracker(salt string, window time.Duration) *FingerprintTracker {
+	saltBytes := []byte(salt)
+	if len(saltBytes) == 0 {
+		saltBytes = make([]byte, 16)
+		_, _ = rand.Read(saltBytes)
+	}
+	return &FingerprintTracker{
+		salt:        saltBytes,
+		window:      window,
+		windowStart: time.Now(),
+		stats:       make(map[string]*FingerprintStats),
+	}
+}
+
+func (t *FingerprintTracker) fingerprint(c *gin.Context) string {
+	h := sha256.New()
+	h.Write(t.salt)
+	h.Write([]byte(c.ClientIP()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Request.UserAgent()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.GetHeader("Accept-Language")))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Track records each request's fingerprint after it completes
+func (t *FingerprintTracker) Track() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		fp := t.fingerprint(c)
+		c.Set(fingerprintContextKey, fp)
+
+		c.Next()
+
+		t.record(fp, c.FullPath(), c.Writer.Status() == http.StatusTooManyRequests, IsPartner(c), time.Now())
+	})
+}
+
+func (t *FingerprintTracker) record(fp, path string, throttled, partner bool, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.windowStart) >= t.window || len(t.stats) >= maxTrackedFingerprints {
+		t.stats = make(map[string]*FingerprintStats)
+		t.windowStart = now
+	}
+
+	s, ok := t.stats[fp]
+	if !ok {
+		s = &FingerprintStats{Fingerprint: fp, FirstSeen: now, paths: make(map[string]int)}
+		t.stats[fp] = s
+	}
+	s.Requests++
+	if throttled {
+		s.Throttled++
+	}
+	s.Partner = s.Partner || partner
+	s.LastSeen = now
+	s.paths[path]++
+	if s.paths[path] > s.paths[s.TopPath] {
+		s.TopPath = path
+	}
+}
+
+// Top returns the n busiest fingerprints in the current window
+func (t *FingerprintTracker) Top(n int) []FingerprintStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	top := make([]FingerprintStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		top = append(top, *s)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].Requests > top[j].Requests
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// WindowStart returns when the current counting window began
+func (t *FingerprintTracker) WindowStart() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.windowStart
+}
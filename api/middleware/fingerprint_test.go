@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFingerprintTracker(t *testing.T) {
+	tracker := NewFingerprintTracker("salt", time.Hour)
+	limiter := NewRateLimiter(3, time.Minute)
+	router := gin.New()
+	router.Use(tracker.Track())
+	router.GET("/v1/events", limiter.Limit(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/v1/events/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	clients := []struct {
+		ip, userAgent string
+		paths         []string
+	}{
+		{"198.51.100.1", "scraper/1.0", []string{"/v1/events", "/v1/events", "/v1/events", "/v1/events", "/v1/events"}},
+		{"198.51.100.2", "Mozilla/5.0", []string{"/v1/events", "/v1/events/abc"}},
+	}
+	for _, client := range clients {
+		for _, path := range client.paths {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.RemoteAddr = client.ip + ":1234"
+			req.Header.Set("User-Agent", client.userAgent)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}
+
+	top := tracker.Top(10)
+	if len(top) != 2 {
+		t.Fatalf("tracked %d fingerprints, want 2", len(top))
+	}
+
+	tests := []struct {
+		name          string
+		stats         FingerprintStats
+		wantRequests  int
+		wantThrottled int
+		wantTopPath   string
+	}{
+		{"busiest first", top[0], 5, 2, "/v1/events"},
+		{"browser", top[1], 2, 0, "/v1/events"},
+	}
+	for _, tt := range tests {
+		if tt.stats.Requests != tt.wantRequests || tt.stats.Throttled != tt.wantThrottled || tt.stats.TopPath != tt.wantTopPath {
+			t.Errorf("%s: requests/throttled/top = %d/%d/%s, want %d/%d/%s", tt.name,
+				tt.stats.Requests, tt.stats.Throttled, tt.stats.TopPath, tt.wantRequests, tt.wantThrottled, tt.wantTopPath)
+		}
+		if len(tt.stats.Fingerprint) != 16 || strings.Contains(tt.stats.Fingerprint, "198.51.100") {
+			t.Errorf("%s: fingerprint %q is not a short hash", tt.name, tt.stats.Fingerprint)
+		}
+	}
+}
+
+func TestFingerprintSaltChangesHash(t *testing.T) {
+	fingerprint := func(salt string) string {
+		tracker := NewFingerprintTracker(salt, time.Hour)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.RemoteAddr = "198.51.100.1:1234"
+		return tracker.fingerprint(c)
+	}
+
+	if fingerprint("a") == fingerprint("b") {
+		t.Error("different salts produced the same fingerprint")
+	}
+	if fingerprint("a") != fingerprint("a") {
+		t.Error("the same salt produced different fingerprints")
+	}
+}
@@ -7,8 +7,15 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// requestIDContextKey holds the request's correlation ID in the gin context
+const requestIDContextKey = "request_id"
+
+// maxRequestIDLength bounds IDs accepted from callers so they can't flood the logs
+const maxRequestIDLength = 64
+
 // CORS middleware for handling cross-origin requests
 func CORS() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
@@ -21,7 +28,7 @@ func CORS() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -32,11 +39,35 @@ func CORS() gin.HandlerFunc {
 	})
 }
 
+// RequestID tags each request with a correlation ID, reusing the caller's X-Request-ID when
+// it sends a reasonable one, and echoes it in the response. Error pages show it so a report
+// can be matched to the log line.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDContextKey, id)
+		c.Writer.Header().Set("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// RequestIDFrom returns the request's correlation ID, or "" outside RequestID
+func RequestIDFrom(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
 // Logger middleware for request logging
 func Logger() gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
+		fingerprint, _ := param.Keys[fingerprintContextKey].(string)
+		requestID, _ := param.Keys[requestIDContextKey].(string)
+		return fmt.Sprintf("%s %s %s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
 			param.ClientIP,
+			fingerprint,
+			requestID,
 			param.TimeStamp.Format(time.RFC1123),
 			param.Method,
 			param.Path,
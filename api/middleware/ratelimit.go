@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter counts requests per client IP in fixed windows
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter allows limit requests per client IP per window. A limit <= 0 disables limiting.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+	}
+}
+
+// allow records a request for key and reports whether it fits in the current window,
+// plus how long until the window resets
+func (r *RateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.clients[key]
+	if !ok || now.Sub(w.start) >= r.window {
+		// Opportunistically drop expired windows so the map doesn't grow unbounded
+		if len(r.clients) > 10000 {
+			for k, cw := range r.clients {
+				if now.Sub(cw.start) >= r.window {
+					delete(r.clients, k)
+				}
+			}
+		}
+		w = &rateWindow{start: now}
+		r.clients[key] = w
+	}
+
+	if w.count >= r.limit {
+		return false, w.start.Add(r.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// Limit returns middleware enforcing the limiter. Routes sharing a limiter share a budget.
+// Partner API key holders are never limited.
+func (r *RateLimiter) Limit() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if r.limit <= 0 || IsPartner(c) {
+			c.Next()
+			return
+		}
+
+		allowed, retryAfter := r.allow(c.ClientIP(), time.Now())
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": gin.H{
+					"code":    "rate_limited",
+					"message": "Too many requests. Please try again later.",
+				},
+			})
+			return
+		}
+
+		c.Next()
+	})
+}
+
+// LimitFailures returns middleware that counts only the requests the routes behind it turn
+// away with 401, such as a wrong admin key. Once a client IP has had limit of them in a
+// window, its requests get 429 before reaching the check, whatever they carry, until the
+// window ends. Partner API keys don't exempt a client.
+func (r *RateLimiter) LimitFailures() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if r.limit <= 0 {
+			c.Next()
+			return
+		}
+
+		if exhausted, retryAfter := r.exhausted(c.ClientIP(), time.Now()); exhausted {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": gin.H{
+					"code":    "rate_limited",
+					"message": "Too many failed attempts. Please try again later.",
+				},
+			})
+			return
+		}
+
+		c.Next()
+		if c.Writer.Status() == http.StatusUnauthorized {
+			r.allow(c.ClientIP(), time.Now())
+		}
+	})
+}
+
+// exhausted reports whether key has used up the current window without recording a
+// request, plus how long until the window resets
+func (r *RateLimiter) exhausted(key string, now time.Time) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.clients[key]
+	if !ok || now.Sub(w.start) >= r.window || w.count < r.limit {
+		return false, 0
+	}
+	return true, w.start.Add(r.window).Sub(now)
+}
+
+// RateLimitConfig sets the per-IP token buckets of RateLimit, in requests per minute. A
+// bucket holds a minute's worth of tokens, so a client may burst up to its whole budget;
+// a budget <= 0 disables that class.
+type RateLimitConfig struct {
+	UploadRPM   int             // writes under /v1/uploads, each of which may cost vision calls
+	ReadRPM     int             // GET and HEAD requests
+	PartnerKeys []string        // holders of a partner API key are never limited
+	Exempt      []string        // route prefixes that have a budget of their own, or none
+	IdleTTL     time.Duration   // buckets unused this long are dropped
+	Done        <-chan struct{} // closing it stops the cleanup goroutine
+}
+
+// defaultBucketTTL applies when RateLimitConfig.IdleTTL is unset
+const defaultBucketTTL = 10 * time.Minute
+
+type tokenBucket struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64 // unix nanoseconds of the latest request
+}
+
+// RateLimit returns middleware giving each client IP a token bucket per request class. It is
+// registered in front of every route; routes whose pattern starts with one of cfg.Exempt are
+// passed through, so a route with its own Limit budget is counted once. Requests that match
+// no route are limited by method like any other. Limited requests carry
+// X-RateLimit-Limit, X-RateLimit-Remaining, and X-RateLimit-Reset (Unix seconds when the
+// bucket is full again); rejected ones get 429 with Retry-After. Idle buckets are dropped
+// in the background until cfg.Done is closed.
+func RateLimit(cfg RateLimitConfig) gin.HandlerFunc {
+	ttl := cfg.IdleTTL
+	if ttl <= 0 {
+		ttl = defaultBucketTTL
+	}
+	var buckets sync.Map // class and client IP -> *tokenBucket
+	go func() {
+		ticker := time.NewTicker(ttl)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cfg.Done:
+				return
+			case now := <-ticker.C:
+				buckets.Range(func(key, value interface{}) bool {
+					if now.Sub(time.Unix(0, value.(*tokenBucket).lastSeen.Load())) >= ttl {
+						buckets.Delete(key)
+					}
+					return true
+				})
+			}
+		}
+	}()
+
+	return gin.HandlerFunc(func(c *gin.Context) {
+		class, rpm := rateLimitClass(cfg, c.Request)
+		if rpm <= 0 || rateLimitExempt(cfg.Exempt, c.FullPath()) || isPartnerKey(cfg.PartnerKeys, presentedPartnerKey(c)) {
+			c.Next()
+			return
+		}
+
+		key := class + "|" + c.ClientIP()
+		value, ok := buckets.Load(key)
+		if !ok {
+			value, _ = buckets.LoadOrStore(key, &tokenBucket{limiter: rate.NewLimiter(rate.Limit(float64(rpm)/60), rpm)})
+		}
+		bucket := value.(*tokenBucket)
+		now := time.Now()
+		bucket.lastSeen.Store(now.UnixNano())
+
+		reservation := bucket.limiter.ReserveN(now, 1)
+		delay := reservation.DelayFrom(now)
+		if delay > 0 {
+			reservation.CancelAt(now)
+		}
+		tokens := math.Max(bucket.limiter.TokensAt(now), 0)
+		refill := time.Duration((float64(rpm) - tokens) / (float64(rpm) / 60) * float64(time.Second))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rpm))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(int(tokens)))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(now.Add(refill).Unix(), 10))
+
+		if delay > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": gin.H{
+					"code":    "rate_limited",
+					"message": "Too many requests. Please try again later.",
+				},
+			})
+			return
+		}
+		c.Next()
+	})
+}
+
+// rateLimitClass names the bucket a request draws from and its budget per minute
+func rateLimitClass(cfg RateLimitConfig, req *http.Request) (string, int) {
+	switch {
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		return "read", cfg.ReadRPM
+	case strings.HasPrefix(req.URL.Path, "/v1/uploads"):
+		return "upload", cfg.UploadRPM
+	default:
+		return "", 0
+	}
+}
+
+// rateLimitExempt reports whether the route pattern route is left out of the token buckets
+func rateLimitExempt(exempt []string, route string) bool {
+	if route == "" {
+		return false
+	}
+	for _, prefix := range exempt {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		at            time.Duration
+		key           string
+		wantAllowed   bool
+		wantRetryUpTo time.Duration
+	}{
+		{"first request", 0, "1.1.1.1", true, 0},
+		{"second request", time.Second, "1.1.1.1", true, 0},
+		{"over the limit", 2 * time.Second, "1.1.1.1", false, 58 * time.Second},
+		{"another client has its own window", 3 * time.Second, "2.2.2.2", true, 0},
+		{"still over the limit", 30 * time.Second, "1.1.1.1", false, 30 * time.Second},
+		{"next window", time.Minute, "1.1.1.1", true, 0},
+	}
+
+	limiter := NewRateLimiter(2, time.Minute)
+	for _, tt := range tests {
+		allowed, retryAfter := limiter.allow(tt.key, start.Add(tt.at))
+		if allowed != tt.wantAllowed || retryAfter != tt.wantRetryUpTo {
+			t.Errorf("%s: allow() = %v, %v; want %v, %v", tt.name, allowed, retryAfter, tt.wantAllowed, tt.wantRetryUpTo)
+		}
+	}
+}
+
+func TestRateLimiterSharesBudgetAcrossRoutes(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute)
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.POST("/v1/uploads/signed-url", limiter.Limit(), ok)
+	router.POST("/v1/submissions/manual", limiter.Limit(), ok)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/v1/uploads/signed-url", http.StatusOK},
+		{"/v1/submissions/manual", http.StatusOK},
+		{"/v1/submissions/manual", http.StatusTooManyRequests},
+		{"/v1/uploads/signed-url", http.StatusTooManyRequests},
+	}
+
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("request %d to %s = %d, want %d", i+1, tt.path, w.Code, tt.want)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %d: 429 without Retry-After", i+1)
+		}
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := NewRateLimiter(0, time.Minute)
+	router := gin.New()
+	router.GET("/", limiter.Limit(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200 with limiting disabled", i+1, w.Code)
+		}
+	}
+}
+
+// TestLimitFailuresCountsOnlyRejections checks a client is cut off after its budget of 401s,
+// however many requests succeed, and only until the window ends
+func TestLimitFailuresCountsOnlyRejections(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute)
+	router := gin.New()
+	router.GET("/admin/stats", limiter.LimitFailures(), func(c *gin.Context) {
+		if c.GetHeader(AdminKeyHeader) != "right" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		key        string
+		remoteAddr string
+		want       int
+	}{
+		{"right", "203.0.113.7:5000", http.StatusOK},
+		{"wrong", "203.0.113.7:5000", http.StatusUnauthorized},
+		{"right", "203.0.113.7:5000", http.StatusOK},
+		{"right", "203.0.113.7:5000", http.StatusOK},
+		{"wrong", "203.0.113.7:5000", http.StatusUnauthorized},
+		{"wrong", "203.0.113.7:5000", http.StatusTooManyRequests},
+		{"right", "203.0.113.7:5000", http.StatusTooManyRequests},
+		{"right", "198.51.100.4:5000", http.StatusOK},
+	}
+
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+		req.Header.Set(AdminKeyHeader, tt.key)
+		req.RemoteAddr = tt.remoteAddr
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("request %d with the %s key from %s = %d, want %d", i+1, tt.key, tt.remoteAddr, w.Code, tt.want)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %d: 429 without Retry-After", i+1)
+		}
+	}
+
+	// A new window forgives the earlier failures
+	limiter.mu.Lock()
+	limiter.clients["203.0.113.7"].start = time.Now().Add(-time.Minute)
+	limiter.mu.Unlock()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.Header.Set(AdminKeyHeader, "right")
+	req.RemoteAddr = "203.0.113.7:5000"
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("request in the next window = %d, want 200", w.Code)
+	}
+}
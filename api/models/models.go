@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,15 +12,41 @@ import (
 
 // Submission represents an uploaded bulletin board image
 type Submission struct {
-	ID                 uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	UserID             *uuid.UUID `json:"user_id" gorm:"type:uuid"`
-	OriginalImageURL   string     `json:"original_image_url" gorm:"size:500;not null"`
-	DerivativeImageURL *string    `json:"derivative_image_url" gorm:"size:500"`
-	CapturedAt         *time.Time `json:"captured_at"`
-	ExifOptIn          bool       `json:"exif_opt_in" gorm:"default:false"`
-	Status             string     `json:"status" gorm:"size:50;not null;default:'uploaded'"` // uploaded, processing, parsed, error, done
-	CreatedAt          time.Time  `json:"created_at" gorm:"not null;default:now()"`
-	UpdatedAt          time.Time  `json:"updated_at" gorm:"not null;default:now()"`
+	ID                     uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID                 *uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	OriginalImageURL       string     `json:"original_image_url" gorm:"size:500;not null"`
+	DerivativeImageURL     *string    `json:"derivative_image_url" gorm:"size:500"` // resized copy the vision model reads
+	ThumbnailURL           *string    `json:"thumbnail_url" gorm:"size:500"`        // 400px-wide copy for the admin dashboard
+	CapturedAt             *time.Time `json:"captured_at"`                          // client-reported capture time
+	ExifOptIn              bool       `json:"exif_opt_in" gorm:"default:false"`
+	CaptureLatitude        *float64   `json:"capture_latitude"` // client-reported, with the user's permission
+	CaptureLongitude       *float64   `json:"capture_longitude"`
+	DeviceOrientation      *string    `json:"device_orientation" gorm:"size:30"`
+	ExifCapturedAt         *time.Time `json:"exif_captured_at"` // read from the uploaded image
+	ExifLatitude           *float64   `json:"exif_latitude"`    // only stored with ExifOptIn
+	ExifLongitude          *float64   `json:"exif_longitude"`
+	ImageWidth             *int       `json:"image_width"` // stored original, after EXIF orientation is applied
+	ImageHeight            *int       `json:"image_height"`
+	ImageCount             int        `json:"image_count" gorm:"not null;default:1"`                 // photos uploaded, see services.SubmissionImageFilename
+	ImagesProcessed        int        `json:"images_processed" gorm:"not null;default:0"`            // photos read by the vision model so far
+	ImageSHA256            *string    `json:"image_sha256" gorm:"column:image_sha256;size:64;index"` // of the bytes as uploaded, computed by the server
+	ImagePHash             *int64     `json:"image_phash" gorm:"column:image_phash"`                 // 64-bit dHash of the upright image
+	ClaimedSHA256          *string    `json:"claimed_sha256" gorm:"column:claimed_sha256;size:64"`   // reported by the client before upload
+	DuplicateOfID          *uuid.UUID `json:"duplicate_of_id" gorm:"type:uuid;index"`                // earlier upload of the same photo whose flyers it shares
+	ExperimentName         *string    `json:"experiment_name" gorm:"size:100;index"`                 // vision experiment and variant, sticky across retries
+	ExperimentVariant      *string    `json:"experiment_variant" gorm:"size:100"`
+	VisionModel            *string    `json:"vision_model" gorm:"size:100"`        // model of the latest vision call
+	VisionServedModel      *string    `json:"vision_served_model" gorm:"size:100"` // model that served it, as reported by the response
+	VisionInputTokens      *int       `json:"vision_input_tokens"`                 // summed over every vision call
+	VisionOutputTokens     *int       `json:"vision_output_tokens"`
+	ModerationInputTokens  *int       `json:"moderation_input_tokens"` // summed over every candidate's moderation calls
+	ModerationOutputTokens *int       `json:"moderation_output_tokens"`
+	Status                 string     `json:"status" gorm:"size:50;not null;default:'uploaded'"` // uploaded, processing, parsed, error, failed, done
+	RetryCount             int        `json:"retry_count" gorm:"not null;default:0"`                // automatic retries after an error, see services.RetryService
+	LastError              *string    `json:"last_error" gorm:"type:text"`                          // why processing last failed
+	Source                 string     `json:"source" gorm:"size:50;not null;default:'upload'"`   // upload, manual
+	CreatedAt              time.Time  `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt              time.Time  `json:"updated_at" gorm:"not null;default:now()"`
 
 	// Relations
 	Flyers []Flyer `json:"flyers,omitempty"`
@@ -31,8 +60,19 @@ type Flyer struct {
 	Polygon              string    `json:"polygon" gorm:"type:jsonb;not null"` // JSON array of {x, y} points
 	RotationDeg          *float64  `json:"rotation_deg"`
 	DetectionConfidence  float64   `json:"detection_confidence" gorm:"not null"`
-	CropImageURL         *string   `json:"crop_image_url" gorm:"size:500"`
+	CropImageURL         *string   `json:"crop_image_url" gorm:"size:500"`   // unredacted crop, admin-only
+	RegionCropURL        *string   `json:"region_crop_url" gorm:"size:500"`  // the same crop as crop_<region_id>.jpg beside the submitter's photo, for their status page
+	PublicImageURL       *string   `json:"public_image_url" gorm:"size:500"` // redacted crop, the only variant shown with events
+	Redactions           *string   `json:"redactions" gorm:"type:jsonb"`     // rectangles applied to PublicImageURL
+	RedactedAt           *time.Time `json:"redacted_at"`
+	ImageIndex           int       `json:"image_index" gorm:"not null;default:1"` // which of the submission's photos the polygon is in, from 1
+	ImageWidth           *int      `json:"image_width"`                            // that photo's upright size, the polygon's coordinate space
+	ImageHeight          *int      `json:"image_height"`
 	Notes                *string   `json:"notes"`
+	VisionModel          *string   `json:"vision_model" gorm:"size:100"`                // model requested for the extraction behind its candidates
+	VisionServedModel    *string   `json:"vision_served_model" gorm:"size:100;index"`   // model the response said served it
+	VisionFingerprint    *string   `json:"vision_fingerprint" gorm:"size:100"`          // system_fingerprint, when reported
+	PromptVersion        *string   `json:"prompt_version" gorm:"size:12;index"`         // services.PromptVersion of the prompt behind its candidates
 	CreatedAt            time.Time `json:"created_at" gorm:"not null;default:now()"`
 
 	// Relations
@@ -70,10 +110,17 @@ type EventCandidate struct {
 	CompositeScore     *float64   `json:"composite_score"`
 	PublishResult      *string    `json:"publish_result" gorm:"size:50"` // published, blocked, needs_review
 	PublicationReason  *string    `json:"publication_reason"`
+	ReviewReason       *string    `json:"review_reason" gorm:"size:50"` // low_score, needs_enrichment, moderation_unavailable, geocode_failed
+	RetryAttempts      int        `json:"retry_attempts" gorm:"not null;default:0"` // Stage 3 attempts that failed transiently
+	RetryAfter         *time.Time `json:"retry_after" gorm:"index"` // next Stage 3 retry; nil when none is scheduled
+	LastRetryError     *string    `json:"last_retry_error"`
+	PublishedEventID   *uuid.UUID `json:"published_event_id" gorm:"type:uuid;index"` // event this candidate published or corroborated
+	SearchText         string     `json:"-" gorm:"type:text;not null;default:''"` // lowercased searchable fields; see services.CandidateSearchText
 	CreatedAt          time.Time  `json:"created_at" gorm:"not null;default:now()"`
 
 	// Relations
-	Flyer Flyer `json:"flyer,omitempty"`
+	Flyer          Flyer  `json:"flyer,omitempty"`
+	PublishedEvent *Event `json:"published_event,omitempty" gorm:"foreignKey:PublishedEventID"`
 }
 
 // Event represents a published event
@@ -86,12 +133,18 @@ type Event struct {
 	VenueID         *uuid.UUID `json:"venue_id" gorm:"type:uuid"`
 	URL             *string    `json:"url" gorm:"size:500"`
 	Price           *string    `json:"price" gorm:"size:100"`
+	PriceTiers      PriceTiers `json:"price_tiers" gorm:"type:jsonb"`
+	PriceMinCents   *int       `json:"price_min_cents"` // summary of PriceTiers, kept in sync by services.ApplyPriceTiers
+	PriceMaxCents   *int       `json:"price_max_cents"`
+	Attributes      Attributes `json:"attributes,omitempty" gorm:"type:jsonb"` // public extra fields from the deployment's field schema
 	Description     *string    `json:"description"`
 	Organizer       *string    `json:"organizer" gorm:"size:200"`
 	Source          string     `json:"source" gorm:"size:50;not null;default:'flyer'"`
 	PublishedVia    string     `json:"published_via" gorm:"size:50;not null;default:'auto'"` // auto, manual
 	QualityScore    *float64   `json:"quality_score"`
 	ModerationState string     `json:"moderation_state" gorm:"size:50;not null;default:'pending'"` // pending, approved, blocked
+	Quiet           bool       `json:"-" gorm:"not null;default:false"` // admin-only: kept out of feeds, digests, sitemaps and featured slots
+	ICSSequence     int        `json:"-" gorm:"column:ics_sequence;not null;default:0"` // iCalendar SEQUENCE, bumped on every edit and state change
 	CreatedAt       time.Time  `json:"created_at" gorm:"not null;default:now()"`
 	UpdatedAt       time.Time  `json:"updated_at" gorm:"not null;default:now()"`
 
@@ -101,12 +154,15 @@ type Event struct {
 
 // DedupeLink represents merged duplicate events
 type DedupeLink struct {
-	ID                 uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	PrimaryEventID     uuid.UUID `json:"primary_event_id" gorm:"type:uuid;not null"`
-	DuplicateEventID   uuid.UUID `json:"duplicate_event_id" gorm:"type:uuid;not null"`
-	SimilarityScore    float64   `json:"similarity_score" gorm:"not null"`
-	MergeReason        string    `json:"merge_reason" gorm:"size:100;not null"`
-	CreatedAt          time.Time `json:"created_at" gorm:"not null;default:now()"`
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	PrimaryEventID   uuid.UUID  `json:"primary_event_id" gorm:"type:uuid;not null"`
+	DuplicateEventID uuid.UUID  `json:"duplicate_event_id" gorm:"type:uuid;not null;index"`
+	SimilarityScore  float64    `json:"similarity_score" gorm:"not null"`
+	MergeReason      string     `json:"merge_reason" gorm:"size:100;not null"`
+	Status           string     `json:"status" gorm:"size:20;not null;default:'pending';index:idx_dedupe_links_status"` // pending, accepted, rejected
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	ResolvedBy       *string    `json:"resolved_by" gorm:"size:100"` // admin who accepted or rejected the merge
+	CreatedAt        time.Time  `json:"created_at" gorm:"not null;default:now();index:idx_dedupe_links_status"`
 
 	// Relations
 	PrimaryEvent   Event `json:"primary_event,omitempty"`
@@ -119,6 +175,10 @@ type AuditLog struct {
 	EntityType string    `json:"entity_type" gorm:"size:50;not null"`
 	EntityID   uuid.UUID `json:"entity_id" gorm:"type:uuid;not null"`
 	Action     string    `json:"action" gorm:"size:100;not null"`
+	ActorType  string    `json:"actor_type" gorm:"size:20;not null;default:'system'"` // auto, admin, api, system
+	AdminID    *string   `json:"admin_id" gorm:"size:100"`                         // acting moderator, for admin actions
+	Route      *string   `json:"route" gorm:"size:200"`                            // Gin route the action came through
+	LatencyMs  *int64    `json:"latency_ms"`                                       // extraction-to-decision time, for candidate decisions
 	UserID     *uuid.UUID `json:"user_id" gorm:"type:uuid"`
 	Changes    *string   `json:"changes" gorm:"type:jsonb"`
 	Metadata   *string   `json:"metadata" gorm:"type:jsonb"`
@@ -127,18 +187,182 @@ type AuditLog struct {
 
 // Flag represents user-reported issues
 type Flag struct {
-	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	EventID    uuid.UUID `json:"event_id" gorm:"type:uuid;not null"`
-	FlagType   string    `json:"flag_type" gorm:"size:50;not null"` // spam, inappropriate, duplicate, wrong_location
-	Reason     *string   `json:"reason"`
-	ReporterIP *string   `json:"reporter_ip" gorm:"type:inet"`
-	Status     string    `json:"status" gorm:"size:50;not null;default:'pending'"` // pending, resolved, dismissed
-	CreatedAt  time.Time `json:"created_at" gorm:"not null;default:now()"`
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	EventID      uuid.UUID  `json:"event_id" gorm:"type:uuid;not null;index"`
+	FlagType     string     `json:"flag_type" gorm:"size:50;not null"` // spam, inappropriate, duplicate, wrong_location, venue_dispute
+	Reason       *string    `json:"reason"`
+	ReporterIP   *string    `json:"reporter_ip" gorm:"type:inet"`
+	VenueClaimID *uuid.UUID `json:"venue_claim_id" gorm:"type:uuid;index"`            // set on venue disputes
+	Status       string     `json:"status" gorm:"size:50;not null;default:'pending'"` // pending, resolved, dismissed
+	CreatedAt    time.Time  `json:"created_at" gorm:"not null;default:now()"`
 
 	// Relations
 	Event Event `json:"event,omitempty"`
 }
 
+// VenueClaim associates a venue manager's email with a venue. The signed token issued for it
+// lets the manager dispute events attributed to the venue until it expires or is revoked.
+type VenueClaim struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	VenueID   uuid.UUID  `json:"venue_id" gorm:"type:uuid;not null;index"`
+	Email     string     `json:"email" gorm:"size:320;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null;default:now()"`
+}
+
+// EventChange is an append-only change feed entry consumed by downstream mirrors.
+// Seq is monotonically increasing and serves as the feed cursor.
+type EventChange struct {
+	Seq        int64     `json:"seq" gorm:"primaryKey;autoIncrement"`
+	EventID    uuid.UUID `json:"event_id" gorm:"type:uuid;not null;index"`
+	ChangeType string    `json:"change_type" gorm:"size:50;not null"` // created, updated, deleted
+	Reason     *string   `json:"reason" gorm:"size:100"`               // e.g. venue_merged, venue_deleted
+	OutboxID   *uuid.UUID `json:"-" gorm:"type:uuid;uniqueIndex"`      // outbox entry that appended it
+	CreatedAt  time.Time `json:"created_at" gorm:"not null;default:now()"`
+}
+
+// OutboxEntry is a side effect (webhook, notification, change feed entry) written in the same
+// transaction as the state change that caused it and delivered to one sink by the dispatcher.
+// Copies of one message for different sinks share an IdempotencyKey.
+type OutboxEntry struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Sink           string     `json:"sink" gorm:"size:50;not null;uniqueIndex:idx_outbox_entries_sink_key"`
+	Topic          string     `json:"topic" gorm:"size:100;not null"`
+	AggregateID    uuid.UUID  `json:"aggregate_id" gorm:"type:uuid;not null"`
+	IdempotencyKey string     `json:"idempotency_key" gorm:"size:100;not null;uniqueIndex:idx_outbox_entries_sink_key"`
+	Payload        string     `json:"payload" gorm:"type:jsonb;not null"`
+	Status         string     `json:"status" gorm:"size:20;not null;default:'pending';index:idx_outbox_entries_due"` // pending, delivered, dead
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"not null;default:now();index:idx_outbox_entries_due"`
+	LastError      *string    `json:"last_error" gorm:"type:text"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"not null;default:now()"`
+}
+
+// EventHistory is an append-only record of an event's full state over [ValidFrom, ValidTo).
+// The current version has a nil ValidTo.
+type EventHistory struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	EventID   uuid.UUID  `json:"event_id" gorm:"type:uuid;not null;index"`
+	ValidFrom time.Time  `json:"valid_from" gorm:"not null;index"`
+	ValidTo   *time.Time `json:"valid_to" gorm:"index"`
+	State     string     `json:"state" gorm:"type:jsonb;not null"` // serialized Event
+}
+
+// TableName keeps the history table singular like its migration
+func (EventHistory) TableName() string {
+	return "event_history"
+}
+
+// EventStateTransition records one change of an event's moderation_state and why it happened
+type EventStateTransition struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	EventID     uuid.UUID  `json:"event_id" gorm:"type:uuid;not null;index"`
+	FromState   *string    `json:"from_state" gorm:"size:50"` // nil when the event was created
+	ToState     string     `json:"to_state" gorm:"size:50;not null"`
+	ActorType   string     `json:"actor_type" gorm:"size:50;not null"` // auto, admin, api, system
+	ReasonCode  string     `json:"reason_code" gorm:"size:100;not null"`
+	CandidateID *uuid.UUID `json:"candidate_id" gorm:"type:uuid"`
+	FlagID      *uuid.UUID `json:"flag_id" gorm:"type:uuid"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"not null;default:now()"`
+}
+
+// CategoryPublishSetting overrides auto-publish rules for one normalized event category.
+// Nil fields fall back to the global AUTO_PUBLISH_* settings.
+type CategoryPublishSetting struct {
+	ID                   uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Category             string    `json:"category" gorm:"size:50;not null;uniqueIndex"`
+	AutoPublishThreshold *float64  `json:"auto_publish_threshold"`
+	MinStartOffsetMin    *int      `json:"min_start_offset_min"`
+	MaxStartOffsetDays   *int      `json:"max_start_offset_days"`
+	CreatedAt            time.Time `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt            time.Time `json:"updated_at" gorm:"not null;default:now()"`
+}
+
+// Setting is a runtime value for a setting registered in services. Without a row the
+// setting's env default applies.
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"type:jsonb;not null"`
+	UpdatedBy string    `json:"updated_by" gorm:"size:100;not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:now()"`
+}
+
+// SettingChange is an append-only record of one change to a setting. Nil values mean
+// the setting had no runtime value (before its first PUT, or after being unset).
+type SettingChange struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Key       string    `json:"key" gorm:"size:100;not null;index:idx_setting_changes_key"`
+	OldValue  *string   `json:"old_value" gorm:"type:jsonb"`
+	NewValue  *string   `json:"new_value" gorm:"type:jsonb"`
+	Diff      string    `json:"diff" gorm:"type:jsonb;not null"`
+	ChangedBy string    `json:"changed_by" gorm:"size:100;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now();index:idx_setting_changes_key"`
+}
+
+// EventClusterSet is the homepage map's grid clustering of approved events starting in the
+// next Days days, at one zoom level. Rows are rebuilt in the background, never per request.
+type EventClusterSet struct {
+	Days        int       `json:"days" gorm:"primaryKey;autoIncrement:false"`
+	Zoom        int       `json:"zoom" gorm:"primaryKey;autoIncrement:false"`
+	Clusters    string    `json:"clusters" gorm:"type:jsonb;not null;default:'[]'"`
+	EventCount  int       `json:"event_count" gorm:"not null;default:0"`
+	DataVersion int64     `json:"data_version" gorm:"not null;default:0"` // change feed seq at build time
+	WindowStart time.Time `json:"window_start" gorm:"not null"`
+	WindowEnd   time.Time `json:"window_end" gorm:"not null"`
+	ComputedAt  time.Time `json:"computed_at" gorm:"not null;default:now()"`
+	ComputeMS   int       `json:"compute_ms" gorm:"column:compute_ms;not null;default:0"`
+}
+
+// ModelVersion is a model that served calls made for a requested model name. A new row
+// for a requested model that already had one means the provider swapped models behind it.
+type ModelVersion struct {
+	ID              uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Purpose         string    `json:"purpose" gorm:"size:20;not null;uniqueIndex:idx_model_versions_served"` // vision or moderation
+	RequestedModel  string    `json:"requested_model" gorm:"size:100;not null;uniqueIndex:idx_model_versions_served"`
+	ServedModel     string    `json:"served_model" gorm:"size:100;not null;uniqueIndex:idx_model_versions_served"`
+	LastFingerprint *string   `json:"last_fingerprint" gorm:"size:100"`
+	Calls           int64     `json:"calls" gorm:"not null;default:0"`
+	FirstSeenAt     time.Time `json:"first_seen_at" gorm:"not null;default:now()"`
+	LastSeenAt      time.Time `json:"last_seen_at" gorm:"not null;default:now()"`
+}
+
+// EventTombstone records a hard-deleted event. It keeps no event content: the canonical key
+// is hashed, and only the times needed to cancel the calendar entry are kept.
+type EventTombstone struct {
+	EventID          uuid.UUID  `json:"event_id" gorm:"type:uuid;primary_key"`
+	CanonicalKeyHash string     `json:"-" gorm:"size:64;not null;index:idx_event_tombstones_canonical_key_hash"`
+	StartTs          time.Time  `json:"start_ts" gorm:"not null;index"`
+	EndTs            *time.Time `json:"end_ts"`
+	ICSSequence      int        `json:"-" gorm:"column:ics_sequence;not null;default:0"`
+	Reason           string     `json:"reason" gorm:"size:50;not null"`
+	Note             *string    `json:"note"`
+	ActorType        string     `json:"actor_type" gorm:"size:20;not null"`
+	AdminID          *string    `json:"admin_id" gorm:"size:100"`
+	DeletedAt        time.Time  `json:"deleted_at" gorm:"not null;default:now();index:idx_event_tombstones_canonical_key_hash"`
+}
+
+// AdminAPIKey is a generated admin API key. Only its bcrypt hash is stored; the key itself
+// is shown once, when it is generated.
+type AdminAPIKey struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	KeyHash   string     `json:"-" gorm:"size:100;not null"`
+	CreatedBy string     `json:"created_by" gorm:"size:100;not null"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null;default:now()"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// AdminSession is a dashboard sign-in. Its cookie names the session and the admin key it
+// was opened with, never the key itself; signing out revokes it, as does revoking the key.
+type AdminSession struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	KeyID     string     `json:"key_id" gorm:"size:64;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null;default:now()"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
 // BeforeCreate hooks
 func (s *Submission) BeforeCreate(tx *gorm.DB) error {
 	if s.ID == uuid.Nil {
@@ -173,4 +397,112 @@ func (e *Event) BeforeCreate(tx *gorm.DB) error {
 		e.ID = uuid.New()
 	}
 	return nil
-}
\ No newline at end of file
+}
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+func (h *EventHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
+
+func (t *EventStateTransition) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (s *CategoryPublishSetting) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+func (o *OutboxEntry) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+func (v *VenueClaim) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return nil
+}
+
+func (c *SettingChange) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// PriceTier is one purchasable price level parsed from a flyer, e.g. "$15 adv".
+// AmountCents is nil when the amount is open ("pay what you can").
+type PriceTier struct {
+	Label       string `json:"label"`
+	AmountCents *int   `json:"amount_cents"`
+	URL         string `json:"url,omitempty"`
+}
+
+// PriceTiers is stored as a jsonb array
+type PriceTiers []PriceTier
+
+// Value implements driver.Valuer
+func (p PriceTiers) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements sql.Scanner
+func (p *PriceTiers) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("unsupported price_tiers type %T", value)
+	}
+}
+
+// Attributes holds extracted fields beyond the built-in event columns, stored as a jsonb object
+type Attributes map[string]interface{}
+
+// Value implements driver.Valuer
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan implements sql.Scanner
+func (a *Attributes) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("unsupported attributes type %T", value)
+	}
+}
@@ -0,0 +1,13 @@
+package observability
+
+import (
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
+	"gorm.io/gorm"
+)
+
+// InstrumentGORM records every query db runs as a client span through the otelgorm plugin,
+// a child of the span in the statement's context (see gorm.DB.WithContext) or a trace of
+// its own. Statements are recorded with placeholders, never their bound values.
+func InstrumentGORM(db *gorm.DB) error {
+	return db.Use(otelgorm.NewPlugin(otelgorm.WithoutQueryVariables()))
+}
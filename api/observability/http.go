@@ -0,0 +1,58 @@
+package observability
+
+import (
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTPClient records each request client sends as a client span, a child of the span in
+// the request's context, and propagates the trace to the server in traceparent headers.
+// It changes client in place and returns it.
+func HTTPClient(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = tracingTransport{base: base}
+	return client
+}
+
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The query is left out: geocoders take their API keys there
+	target := url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path}
+	ctx, span := start(req.Context(), "HTTP "+req.Method+" "+req.URL.Host, trace.SpanKindClient, []Attribute{
+		String("http.request.method", req.Method),
+		String("url.full", target.String()),
+	})
+	defer span.End()
+
+	if span.span.SpanContext().IsValid() {
+		req = req.Clone(ctx)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.RecordError(statusError(resp.Status))
+	}
+	return resp, nil
+}
+
+// statusError reports an HTTP status as a span error
+type statusError string
+
+func (e statusError) Error() string {
+	return "HTTP " + string(e)
+}
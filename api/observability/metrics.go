@@ -0,0 +1,117 @@
+package observability
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// processingDurationBuckets are the upper bounds, in seconds, of the processing histogram;
+// a whole upload run is cut off at 90s
+var processingDurationBuckets = []float64{0.5, 1, 5, 15, 30, 60, 90}
+
+// geocodeConfidenceBuckets split geocoder confidence (0..1) into tenths
+var geocodeConfidenceBuckets = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}
+
+// Registry holds the operational metrics served at /metrics, registered on a Prometheus
+// registry of their own alongside the Go runtime and process collectors. Services record
+// through its methods; a nil Registry records nothing.
+type Registry struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	submissions        *prometheus.CounterVec
+	processingDuration *prometheus.HistogramVec
+	openAITokens       *prometheus.CounterVec
+	eventsPublished    *prometheus.CounterVec
+	geocodeConfidence  prometheus.Histogram
+}
+
+// NewRegistry creates the registry with every metric at zero
+func NewRegistry() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+		submissions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "williamboard_submissions_total",
+			Help: "Submissions reaching each processing status",
+		}, []string{"status"}),
+		processingDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "williamboard_processing_duration_seconds",
+			Help:    "Time spent in each upload processing stage",
+			Buckets: processingDurationBuckets,
+		}, []string{"stage"}),
+		openAITokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "williamboard_openai_tokens_total",
+			Help: "Tokens billed by OpenAI, by model and prompt or completion",
+		}, []string{"model", "type"}),
+		eventsPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "williamboard_events_published_total",
+			Help: "Candidates published as events, by who published them (auto or admin)",
+		}, []string{"via"}),
+		geocodeConfidence: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "williamboard_geocode_confidence_histogram",
+			Help:    "Confidence of successful geocoder results",
+			Buckets: geocodeConfidenceBuckets,
+		}),
+	}
+	r.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.submissions,
+		r.processingDuration,
+		r.openAITokens,
+		r.eventsPublished,
+		r.geocodeConfidence,
+	)
+	r.handler = promhttp.InstrumentMetricHandler(r.registry, promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{}))
+	return r
+}
+
+// SubmissionStatus counts a submission reaching status
+func (r *Registry) SubmissionStatus(status string) {
+	if r != nil {
+		r.submissions.WithLabelValues(status).Inc()
+	}
+}
+
+// ObserveStage records how long one processing stage took
+func (r *Registry) ObserveStage(stage string, elapsed time.Duration) {
+	if r != nil {
+		r.processingDuration.WithLabelValues(stage).Observe(elapsed.Seconds())
+	}
+}
+
+// OpenAITokens counts the prompt and completion tokens of one OpenAI call
+func (r *Registry) OpenAITokens(model string, promptTokens, completionTokens int) {
+	if r == nil || model == "" {
+		return
+	}
+	if promptTokens > 0 {
+		r.openAITokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		r.openAITokens.WithLabelValues(model, "completion").Add(float64(completionTokens))
+	}
+}
+
+// EventPublished counts a candidate published as an event; via is "auto" or "admin"
+func (r *Registry) EventPublished(via string) {
+	if r != nil {
+		r.eventsPublished.WithLabelValues(via).Inc()
+	}
+}
+
+// GeocodeConfidence records the confidence of a successful geocode
+func (r *Registry) GeocodeConfidence(confidence float64) {
+	if r != nil {
+		r.geocodeConfidence.Observe(confidence)
+	}
+}
+
+// ServeHTTP serves every registered metric in the Prometheus text format through promhttp
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
@@ -0,0 +1,57 @@
+package observability
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryServesRecordedMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.SubmissionStatus("done")
+	r.SubmissionStatus("done")
+	r.ObserveStage("vision", 2*time.Second)
+	r.OpenAITokens("gpt-4o", 120, 30)
+	r.OpenAITokens("", 5, 5) // no model: not counted
+	r.EventPublished("auto")
+	r.GeocodeConfidence(0.55)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"submission counter", `williamboard_submissions_total{status="done"} 2`},
+		{"stage bucket below", `williamboard_processing_duration_seconds_bucket{stage="vision",le="1"} 0`},
+		{"stage bucket above", `williamboard_processing_duration_seconds_bucket{stage="vision",le="5"} 1`},
+		{"stage sum", `williamboard_processing_duration_seconds_sum{stage="vision"} 2`},
+		{"prompt tokens", `williamboard_openai_tokens_total{model="gpt-4o",type="prompt"} 120`},
+		{"completion tokens", `williamboard_openai_tokens_total{model="gpt-4o",type="completion"} 30`},
+		{"published", `williamboard_events_published_total{via="auto"} 1`},
+		{"confidence bucket", `williamboard_geocode_confidence_histogram_bucket{le="0.6"} 1`},
+		{"go collector", `go_goroutines`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.line) {
+				t.Errorf("metrics output is missing %q", tt.line)
+			}
+		})
+	}
+	if strings.Contains(body, `model=""`) {
+		t.Error("tokens without a model were counted")
+	}
+}
+
+func TestNilRegistryRecordsNothing(t *testing.T) {
+	var r *Registry
+	r.SubmissionStatus("done")
+	r.ObserveStage("total", time.Second)
+	r.OpenAITokens("gpt-4o", 1, 1)
+	r.EventPublished("admin")
+	r.GeocodeConfidence(1)
+}
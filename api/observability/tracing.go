@@ -0,0 +1,133 @@
+// Package observability records traces of the upload pipeline, outbound HTTP calls, and
+// database queries with the OpenTelemetry SDK, and exports them to a collector over OTLP/HTTP.
+package observability
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the tracer name this package's spans are recorded under
+const instrumentationName = "github.com/lincolngreen/williamboard/api"
+
+// TracerProvider owns the SDK provider spans are exported through; without an endpoint it
+// is empty, the global provider stays the no-op one, and spans cost nothing
+type TracerProvider struct {
+	sdk *sdktrace.TracerProvider
+}
+
+// Init starts exporting spans to OTEL_EXPORTER_OTLP_ENDPOINT (the collector's OTLP/HTTP
+// address, e.g. http://localhost:4318) through a batching otlptracehttp exporter, and
+// installs the provider globally. Call once at startup, before creating services, and
+// Shutdown on exit.
+func Init(cfg *config.Config) (*TracerProvider, error) {
+	if cfg.OTELEndpoint == "" {
+		return &TracerProvider{}, nil
+	}
+	endpoint := strings.TrimRight(cfg.OTELEndpoint, "/")
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT must be an http(s) URL, got %q", cfg.OTELEndpoint)
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint+"/v1/traces"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.AppName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	p := &TracerProvider{sdk: sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)}
+	otel.SetTracerProvider(p.sdk)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	log.Printf("Exporting traces to %s/v1/traces", endpoint)
+	return p, nil
+}
+
+// Shutdown exports the spans still queued and stops the exporter, giving up at ctx's deadline
+func (p *TracerProvider) Shutdown(ctx context.Context) error {
+	if p.sdk == nil {
+		return nil
+	}
+	return p.sdk.Shutdown(ctx)
+}
+
+// tracer is looked up on every span so it follows the provider Init installs
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Attribute is a key/value recorded on a span
+type Attribute = attribute.KeyValue
+
+// String is a string-valued attribute
+func String(key, value string) Attribute {
+	return attribute.String(key, value)
+}
+
+// Int is an integer-valued attribute
+func Int(key string, value int) Attribute {
+	return attribute.Int(key, value)
+}
+
+// Span is one timed operation in a trace. While tracing is off it wraps a no-op span, so
+// callers never check whether tracing is enabled.
+type Span struct {
+	span trace.Span
+}
+
+// SpanFromContext returns the span ctx is inside; a no-op span when there is none
+func SpanFromContext(ctx context.Context) *Span {
+	return &Span{span: trace.SpanFromContext(ctx)}
+}
+
+// Start begins a span named name as a child of the span in ctx, or a new trace when there
+// is none. End it when the operation finishes.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return start(ctx, name, trace.SpanKindInternal, attributes)
+}
+
+func start(ctx context.Context, name string, kind trace.SpanKind, attributes []Attribute) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracer().Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+	return ctx, &Span{span: span}
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	s.span.SetAttributes(attributes...)
+}
+
+// RecordError marks the span failed and records err as an event; a nil error is ignored
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End finishes the span and hands it to the batching exporter. Later calls do nothing.
+func (s *Span) End() {
+	s.span.End()
+}
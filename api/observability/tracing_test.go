@@ -0,0 +1,100 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs an SDK provider that keeps finished spans in memory for the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func attributeValue(span sdktrace.ReadOnlySpan, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestHTTPClientSpansAndPropagation(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(context.Background(), "upload.geocoding")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/geocode/json?key=secret&address=x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := HTTPClient(&http.Client{}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want the client span and its parent", len(spans))
+	}
+	client := spans[0]
+	if client.Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("client span is not a child of the pipeline span")
+	}
+	if url, _ := attributeValue(client, "url.full"); url.AsString() != server.URL+"/geocode/json" {
+		t.Errorf("url.full = %q, want the URL without its query", url.AsString())
+	}
+	if status, _ := attributeValue(client, "http.response.status_code"); status.AsInt64() != http.StatusBadGateway {
+		t.Errorf("http.response.status_code = %d", status.AsInt64())
+	}
+	if client.Status().Code != codes.Error {
+		t.Errorf("status = %v, want an error for a 502", client.Status())
+	}
+
+	wantTraceparent := "00-" + client.SpanContext().TraceID().String() + "-" + client.SpanContext().SpanID().String() + "-01"
+	if traceparent != wantTraceparent {
+		t.Errorf("traceparent = %q, want %q", traceparent, wantTraceparent)
+	}
+}
+
+func TestSpansAreNoOpsWithoutProvider(t *testing.T) {
+	ctx, span := Start(context.Background(), "upload.process", String("submission.id", "s1"))
+	span.SetAttributes(Int("candidate.count", 2))
+	span.RecordError(errors.New("failed"))
+	span.End()
+	span.End()
+
+	if SpanFromContext(ctx).span.SpanContext().IsValid() {
+		t.Error("a span was recorded with tracing off")
+	}
+}
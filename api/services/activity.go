@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// DefaultActivityWindow is how far back the activity view looks without ?since=
+const DefaultActivityWindow = 7 * 24 * time.Hour
+
+// Activity categories, each covering one or more audit actions
+const (
+	ActivityApprovals   = "approvals"
+	ActivityRejections  = "rejections"
+	ActivityEdits       = "edits"
+	ActivityUnpublishes = "unpublishes"
+	ActivityVenueMerges = "venue_merges"
+)
+
+// ActivityCategories maps each activity category to the audit actions it counts
+var ActivityCategories = map[string][]AuditAction{
+	ActivityApprovals:   {AuditActionCandidatePublished},
+	ActivityRejections:  {AuditActionCandidateBlocked},
+	ActivityEdits:       {AuditActionCandidateEdited, AuditActionEventEdited},
+	ActivityUnpublishes: {AuditActionEventUnpublished, AuditActionEventDeleted},
+	ActivityVenueMerges: {AuditActionVenueMerged},
+}
+
+// AdminActivity summarizes one moderator's audited actions since a point in time.
+// Candidates blocked as part of an unpublish count as an unpublish, not a rejection; deletes
+// count as unpublishes.
+type AdminActivity struct {
+	AdminID                   string     `json:"admin_id"`
+	Approvals                 int64      `json:"approvals"`
+	Rejections                int64      `json:"rejections"`
+	Edits                     int64      `json:"edits"`
+	Unpublishes               int64      `json:"unpublishes"`
+	VenueMerges               int64      `json:"venue_merges"`
+	TotalActions              int64      `json:"total_actions"`
+	AvgDecisionLatencySeconds *float64   `json:"avg_decision_latency_seconds"`
+	LastActionAt              *time.Time `json:"last_action_at"`
+}
+
+// AvgDecisionLatency renders the average decision latency for display, or "" if there were no decisions
+func (a AdminActivity) AvgDecisionLatency() string {
+	if a.AvgDecisionLatencySeconds == nil {
+		return ""
+	}
+	return (time.Duration(*a.AvgDecisionLatencySeconds) * time.Second).String()
+}
+
+// ActivityEntry is one audited admin action with a link to the affected entity
+type ActivityEntry struct {
+	ID         uuid.UUID `json:"id"`
+	AdminID    string    `json:"admin_id"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   uuid.UUID `json:"entity_id"`
+	Route      *string   `json:"route,omitempty"`
+	EntityURL  string    `json:"entity_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// adminActions selects audited admin actions since the given time, optionally for one moderator
+func adminActions(db *gorm.DB, adminID string, since time.Time) *gorm.DB {
+	query := db.Model(&models.AuditLog{}).
+		Where("actor_type = ? AND admin_id IS NOT NULL AND created_at >= ?", ActorAdmin, since)
+	if adminID != "" {
+		query = query.Where("admin_id = ?", adminID)
+	}
+	return query
+}
+
+// activityRow is the scan target for the per-admin aggregate
+type activityRow struct {
+	AdminID      string
+	Approvals    int64
+	Rejections   int64
+	Edits        int64
+	Unpublishes  int64
+	VenueMerges  int64
+	TotalActions int64
+	AvgLatencyMs *float64
+	LastActionAt *time.Time
+}
+
+// ListAdminActivity aggregates audited actions per moderator, busiest first
+func ListAdminActivity(db *gorm.DB, adminID string, since time.Time) ([]AdminActivity, error) {
+	// Unpublishing and deleting also block the event's candidates; those rows are not rejections
+	notUnpublish := "COALESCE(metadata->>'unpublished_event_id', metadata->>'deleted_event_id', '') = ''"
+
+	selects := "admin_id"
+	for _, column := range []struct{ name, filter string }{
+		{ActivityApprovals, actionFilter(ActivityCategories[ActivityApprovals])},
+		{ActivityRejections, actionFilter(ActivityCategories[ActivityRejections]) + " AND " + notUnpublish},
+		{ActivityEdits, actionFilter(ActivityCategories[ActivityEdits])},
+		{ActivityUnpublishes, actionFilter(ActivityCategories[ActivityUnpublishes])},
+		{ActivityVenueMerges, actionFilter(ActivityCategories[ActivityVenueMerges])},
+	} {
+		selects += fmt.Sprintf(", COUNT(*) FILTER (WHERE %s) AS %s", column.filter, column.name)
+	}
+	selects += ", COUNT(*) FILTER (WHERE " + notUnpublish + ") AS total_actions" +
+		fmt.Sprintf(", AVG(latency_ms) FILTER (WHERE %s) AS avg_latency_ms", actionFilter(DecisiveCandidateActions)) +
+		", MAX(created_at) AS last_action_at"
+
+	var rows []activityRow
+	if err := adminActions(db, adminID, since).
+		Select(selects).
+		Group("admin_id").
+		Order("total_actions DESC, admin_id ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate admin activity: %w", err)
+	}
+
+	activity := make([]AdminActivity, 0, len(rows))
+	for _, row := range rows {
+		summary := AdminActivity{
+			AdminID:      row.AdminID,
+			Approvals:    row.Approvals,
+			Rejections:   row.Rejections,
+			Edits:        row.Edits,
+			Unpublishes:  row.Unpublishes,
+			VenueMerges:  row.VenueMerges,
+			TotalActions: row.TotalActions,
+			LastActionAt: row.LastActionAt,
+		}
+		if row.AvgLatencyMs != nil {
+			seconds := *row.AvgLatencyMs / 1000
+			summary.AvgDecisionLatencySeconds = &seconds
+		}
+		activity = append(activity, summary)
+	}
+	return activity, nil
+}
+
+// RecentAdminActions returns the latest audited admin actions, newest first
+func RecentAdminActions(db *gorm.DB, adminID string, since time.Time, limit int) ([]ActivityEntry, error) {
+	var logs []models.AuditLog
+	if err := adminActions(db, adminID, since).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list admin actions: %w", err)
+	}
+
+	entries := make([]ActivityEntry, 0, len(logs))
+	for _, log := range logs {
+		entries = append(entries, ActivityEntry{
+			ID:         log.ID,
+			AdminID:    *log.AdminID,
+			Action:     log.Action,
+			EntityType: log.EntityType,
+			EntityID:   log.EntityID,
+			Route:      log.Route,
+			EntityURL:  AdminEntityURL(log.EntityType, log.EntityID),
+			CreatedAt:  log.CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+// AdminEntityURL is the dashboard page for an audited entity, or "" if it has none
+func AdminEntityURL(entityType string, entityID uuid.UUID) string {
+	switch entityType {
+	case AuditEntityEvent:
+		return "/admin/events/" + entityID.String()
+	case AuditEntityCandidate:
+		return "/admin/raw/" + entityID.String()
+	case AuditEntityFlyer:
+		return "/admin/flyers/" + entityID.String() + "/redaction"
+	default:
+		// Venues are deleted by the actions that audit them
+		return ""
+	}
+}
+
+// actionFilter renders an SQL condition matching any of the actions. Actions are fixed
+// constants, never request input, so they are inlined rather than bound.
+func actionFilter(actions []AuditAction) string {
+	filter := "action IN ("
+	for i, action := range actions {
+		if i > 0 {
+			filter += ", "
+		}
+		filter += "'" + string(action) + "'"
+	}
+	return filter + ")"
+}
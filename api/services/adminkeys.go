@@ -0,0 +1,248 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// AdminKeyPrefix starts every generated admin API key, so a leaked one is recognisable
+const AdminKeyPrefix = "wbk_"
+
+// adminKeyBytes is the entropy of a generated admin API key
+const adminKeyBytes = 32
+
+// adminKeyCacheTTL is how long the active key hashes and successful verifications are
+// reused before going back to the database and bcrypt. A key revoked on another instance
+// keeps working here for up to this long.
+const adminKeyCacheTTL = 30 * time.Second
+
+// Key IDs of the configured credentials; a generated key's ID is its admin_api_keys row
+const (
+	adminKeyIDEnvHash = "admin_api_key_hash"
+	adminKeyIDToken   = "admin_token"
+)
+
+// AdminKeyring checks admin API keys against ADMIN_API_KEY_HASH, the legacy ADMIN_TOKEN,
+// and the keys generated through CreateAdminAPIKey, and the dashboard sessions opened with
+// them (see StartSession). bcrypt is deliberately slow, so a key that verified is
+// remembered (by its SHA-256) for adminKeyCacheTTL rather than compared again on every
+// request; so is a session found unrevoked.
+type AdminKeyring struct {
+	db            *gorm.DB
+	envHash       []byte
+	legacyToken   string
+	sessionSecret []byte
+
+	mu       sync.Mutex
+	keys     []activeAdminKey // generated keys, newest first
+	loaded   bool
+	loadedAt time.Time
+	verified map[[sha256.Size]byte]verifiedAdminKey
+	sessions map[uuid.UUID]time.Time // unrevoked sessions, by when that was checked
+}
+
+// activeAdminKey is an unrevoked generated key
+type activeAdminKey struct {
+	ID      uuid.UUID
+	KeyHash string
+}
+
+// verifiedAdminKey is a key that verified, and when
+type verifiedAdminKey struct {
+	keyID string
+	at    time.Time
+}
+
+// NewAdminKeyring loads the configured admin credentials. A malformed ADMIN_API_KEY_HASH
+// is an error rather than being ignored, since ignoring it could leave /admin open.
+// Sessions are signed with ADMIN_SESSION_SECRET; without it a random secret is used, so
+// dashboard sign-ins only work on the instance that made them and until it restarts.
+func NewAdminKeyring(db *gorm.DB, cfg *config.Config) (*AdminKeyring, error) {
+	keyring := &AdminKeyring{
+		db:            db,
+		legacyToken:   cfg.AdminToken,
+		sessionSecret: []byte(cfg.AdminSessionSecret),
+		verified:      make(map[[sha256.Size]byte]verifiedAdminKey),
+		sessions:      make(map[uuid.UUID]time.Time),
+	}
+	if cfg.AdminAPIKeyHash != "" {
+		if _, err := bcrypt.Cost([]byte(cfg.AdminAPIKeyHash)); err != nil {
+			return nil, fmt.Errorf("ADMIN_API_KEY_HASH is not a bcrypt hash: %w", err)
+		}
+		keyring.envHash = []byte(cfg.AdminAPIKeyHash)
+	}
+	if len(keyring.sessionSecret) == 0 {
+		keyring.sessionSecret = make([]byte, 32)
+		if _, err := rand.Read(keyring.sessionSecret); err != nil {
+			return nil, fmt.Errorf("failed to generate admin session secret: %w", err)
+		}
+		log.Println("ADMIN_SESSION_SECRET is not set; dashboard sign-ins won't survive a restart or work across instances")
+	}
+	return keyring, nil
+}
+
+// Configured reports whether any admin credential exists. Without one the admin routes
+// are closed and only the first key can be generated.
+func (k *AdminKeyring) Configured() bool {
+	if k.envHash != nil || k.legacyToken != "" {
+		return true
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.refreshLocked() {
+		// The database is unreachable and nothing was ever loaded: fail closed
+		return true
+	}
+	return len(k.keys) > 0
+}
+
+// Verify reports whether key is a valid admin credential
+func (k *AdminKeyring) Verify(key string) bool {
+	_, ok := k.verifiedKeyID(key)
+	return ok
+}
+
+// verifiedKeyID returns the ID of the credential key matches, if any
+func (k *AdminKeyring) verifiedKeyID(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	if k.legacyToken != "" && subtle.ConstantTimeCompare([]byte(key), []byte(k.legacyToken)) == 1 {
+		return adminKeyIDToken, true
+	}
+
+	digest := sha256.Sum256([]byte(key))
+	k.mu.Lock()
+	if cached, ok := k.verified[digest]; ok && time.Since(cached.at) < adminKeyCacheTTL {
+		k.mu.Unlock()
+		return cached.keyID, true
+	}
+	k.refreshLocked()
+	candidates := make([]verifiedAdminKey, 0, len(k.keys)+1)
+	hashes := make([][]byte, 0, len(k.keys)+1)
+	for _, active := range k.keys {
+		candidates = append(candidates, verifiedAdminKey{keyID: active.ID.String()})
+		hashes = append(hashes, []byte(active.KeyHash))
+	}
+	k.mu.Unlock()
+
+	if k.envHash != nil {
+		candidates = append(candidates, verifiedAdminKey{keyID: adminKeyIDEnvHash})
+		hashes = append(hashes, k.envHash)
+	}
+	for i, hash := range hashes {
+		if bcrypt.CompareHashAndPassword(hash, []byte(key)) == nil {
+			k.mu.Lock()
+			k.verified[digest] = verifiedAdminKey{keyID: candidates[i].keyID, at: time.Now()}
+			k.mu.Unlock()
+			return candidates[i].keyID, true
+		}
+	}
+	return "", false
+}
+
+// keyActiveLocked reports whether the credential with keyID is still accepted
+func (k *AdminKeyring) keyActiveLocked(keyID string) bool {
+	switch keyID {
+	case adminKeyIDToken:
+		return k.legacyToken != ""
+	case adminKeyIDEnvHash:
+		return k.envHash != nil
+	}
+	k.refreshLocked()
+	for _, active := range k.keys {
+		if active.ID.String() == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshLocked reloads the active generated keys once they are older than
+// adminKeyCacheTTL, pruning expired verifications and session checks at the same time. It
+// reports whether the keys were ever loaded; a failed reload keeps the previous ones.
+func (k *AdminKeyring) refreshLocked() bool {
+	if k.loaded && time.Since(k.loadedAt) < adminKeyCacheTTL {
+		return true
+	}
+
+	var keys []activeAdminKey
+	if err := k.db.Model(&models.AdminAPIKey{}).Select("id", "key_hash").Where("revoked_at IS NULL").
+		Order("created_at DESC").Find(&keys).Error; err != nil {
+		log.Printf("Failed to load admin API keys: %v", err)
+		return k.loaded
+	}
+
+	k.keys = keys
+	k.loaded = true
+	k.loadedAt = time.Now()
+	for digest, cached := range k.verified {
+		if time.Since(cached.at) >= adminKeyCacheTTL {
+			delete(k.verified, digest)
+		}
+	}
+	for id, at := range k.sessions {
+		if time.Since(at) >= adminKeyCacheTTL {
+			delete(k.sessions, id)
+		}
+	}
+	return true
+}
+
+// CreateAdminAPIKey generates a new admin API key, stores its bcrypt hash, and revokes
+// every earlier generated key. The key is returned once and can't be recovered later.
+// ADMIN_API_KEY_HASH and ADMIN_TOKEN are configuration and stay valid.
+func (k *AdminKeyring) CreateAdminAPIKey(actor Actor) (string, *models.AdminAPIKey, error) {
+	secret := make([]byte, adminKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate admin API key: %w", err)
+	}
+	key := AdminKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.DefaultCost)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to hash admin API key: %w", err)
+	}
+
+	record := models.AdminAPIKey{KeyHash: string(hash), CreatedBy: actor.AdminID}
+	if record.CreatedBy == "" {
+		record.CreatedBy = actor.Type
+	}
+	err = k.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AdminAPIKey{}).Where("revoked_at IS NULL").
+			Update("revoked_at", time.Now().UTC()).Error; err != nil {
+			return fmt.Errorf("failed to revoke previous admin API keys: %w", err)
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("failed to store admin API key: %w", err)
+		}
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityAdminKey,
+			EntityID:   record.ID,
+			Action:     AuditActionAdminKeyCreated,
+			Actor:      actor,
+		})
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Forget revoked keys here at once; other instances catch up within adminKeyCacheTTL
+	k.mu.Lock()
+	k.loaded = false
+	k.verified = make(map[[sha256.Size]byte]verifiedAdminKey)
+	k.sessions = make(map[uuid.UUID]time.Time)
+	k.mu.Unlock()
+	return key, &record, nil
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const activeAdminKeysSQL = `SELECT "id","key_hash" FROM "admin_api_keys" WHERE revoked_at IS NULL ORDER BY created_at DESC`
+
+// adminKeyHashes returns the id and key_hash rows for keys, hashed at bcrypt's minimum cost
+func adminKeyHashes(t *testing.T, keys ...string) *sqlmock.Rows {
+	t.Helper()
+	rows := sqlmock.NewRows([]string{"id", "key_hash"})
+	for _, key := range keys {
+		rows.AddRow(uuid.New().String(), mustHashAdminKey(t, key))
+	}
+	return rows
+}
+
+func mustHashAdminKey(t *testing.T, key string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// expireAdminKeyCache ages the keyring's loaded hashes and verifications past adminKeyCacheTTL
+func expireAdminKeyCache(k *AdminKeyring) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.loadedAt = k.loadedAt.Add(-adminKeyCacheTTL)
+	for digest, cached := range k.verified {
+		k.verified[digest] = verifiedAdminKey{keyID: cached.keyID, at: cached.at.Add(-adminKeyCacheTTL)}
+	}
+	for id, at := range k.sessions {
+		k.sessions[id] = at.Add(-adminKeyCacheTTL)
+	}
+}
+
+func TestNewAdminKeyringRejectsMalformedHash(t *testing.T) {
+	db, _ := testdb.New(t)
+	for _, hash := range []string{"env-key", "$2a$10$tooshort"} {
+		if _, err := NewAdminKeyring(db, &config.Config{AdminAPIKeyHash: hash}); err == nil {
+			t.Errorf("NewAdminKeyring() accepted ADMIN_API_KEY_HASH %q", hash)
+		}
+	}
+}
+
+func TestAdminKeyringVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		wantQuery bool // the generated keys are loaded to check it
+		want      bool
+	}{
+		{name: "legacy token", key: "legacy-token", want: true},
+		{name: "ADMIN_API_KEY_HASH", key: "env-key", wantQuery: true, want: true},
+		{name: "generated key", key: "wbk_generated", wantQuery: true, want: true},
+		{name: "wrong key", key: "wbk_guess", wantQuery: true},
+		{name: "legacy token with a suffix", key: "legacy-token2", wantQuery: true},
+		{name: "no key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			if tt.wantQuery {
+				mock.ExpectQuery(activeAdminKeysSQL).WillReturnRows(adminKeyHashes(t, "wbk_generated"))
+			}
+			keyring, err := NewAdminKeyring(db, &config.Config{AdminAPIKeyHash: mustHashAdminKey(t, "env-key"), AdminToken: "legacy-token"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keyring.Verify(tt.key); got != tt.want {
+				t.Errorf("Verify(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAdminKeyringCache checks a verified key isn't looked up again within the cache TTL,
+// and that a key revoked elsewhere stops working once the TTL runs out
+func TestAdminKeyringCache(t *testing.T) {
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(activeAdminKeysSQL).WillReturnRows(adminKeyHashes(t, "wbk_generated"))
+	keyring, err := NewAdminKeyring(db, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Any query past the first would be unexpected
+	for i := 0; i < 3; i++ {
+		if !keyring.Verify("wbk_generated") {
+			t.Fatalf("Verify() #%d rejected the generated key", i+1)
+		}
+	}
+	if !keyring.Configured() {
+		t.Error("Configured() = false with a generated key")
+	}
+
+	// Another instance revokes the key; this one notices after the TTL
+	mock.ExpectQuery(activeAdminKeysSQL).WillReturnRows(adminKeyHashes(t))
+	expireAdminKeyCache(keyring)
+	if keyring.Verify("wbk_generated") {
+		t.Error("Verify() accepted a revoked key after the cache expired")
+	}
+	if keyring.Configured() {
+		t.Error("Configured() = true with every generated key revoked")
+	}
+}
+
+func TestAdminKeyringConfigured(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		rows *sqlmock.Rows // nil when the database is unreachable
+		want bool
+	}{
+		{name: "ADMIN_TOKEN", cfg: config.Config{AdminToken: "legacy-token"}, want: true},
+		{name: "no keys yet", rows: sqlmock.NewRows([]string{"key_hash"})},
+		{name: "database unreachable fails closed", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			if tt.rows != nil {
+				mock.ExpectQuery(activeAdminKeysSQL).WillReturnRows(tt.rows)
+			}
+			keyring, err := NewAdminKeyring(db, &tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keyring.Configured(); got != tt.want {
+				t.Errorf("Configured() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAdminAPIKey(t *testing.T) {
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(activeAdminKeysSQL).WillReturnRows(adminKeyHashes(t, "wbk_previous"))
+	keyring, err := NewAdminKeyring(db, &config.Config{AdminToken: "legacy-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !keyring.Verify("wbk_previous") {
+		t.Fatal("Verify() rejected the previous key")
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "admin_api_keys" SET "revoked_at"=$1 WHERE revoked_at IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "admin_api_keys" ("key_hash","created_by","revoked_at") VALUES ($1,$2,$3) RETURNING "id","created_at"`).
+		WithArgs(testdb.Any, "ops", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New().String(), time.Now()))
+	expectAudit(mock, AuditActionAdminKeyCreated)
+	mock.ExpectCommit()
+
+	key, record, err := keyring.CreateAdminAPIKey(Actor{Type: ActorAdmin, AdminID: "ops"})
+	if err != nil {
+		t.Fatalf("CreateAdminAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, AdminKeyPrefix) || len(key) < len(AdminKeyPrefix)+40 {
+		t.Errorf("generated key %q lacks the %s prefix or its entropy", key, AdminKeyPrefix)
+	}
+	if strings.Contains(record.KeyHash, key) || bcrypt.CompareHashAndPassword([]byte(record.KeyHash), []byte(key)) != nil {
+		t.Error("stored hash is not a bcrypt hash of the generated key")
+	}
+
+	// The previous key stops working here at once, without waiting out the cache
+	mock.ExpectQuery(activeAdminKeysSQL).WillReturnRows(sqlmock.NewRows([]string{"key_hash"}).AddRow(record.KeyHash))
+	if keyring.Verify("wbk_previous") {
+		t.Error("Verify() accepted the revoked previous key")
+	}
+	if !keyring.Verify(key) {
+		t.Error("Verify() rejected the new key")
+	}
+	if !keyring.Verify("legacy-token") {
+		t.Error("Verify() rejected ADMIN_TOKEN after a new key was generated")
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+// AdminSessionTTL is how long a dashboard sign-in lasts
+const AdminSessionTTL = 7 * 24 * time.Hour
+
+// ErrInvalidAdminKey is returned when a session is asked for with a key that doesn't verify
+var ErrInvalidAdminKey = errors.New("invalid admin API key")
+
+// StartSession opens a dashboard session for a valid admin key. The token returned is
+// "<session id>.<key id>.<expiry, Unix seconds>.<signature>": it names the key it was opened
+// with but doesn't contain it, so the cookie holding it gives nothing away about the key.
+func (k *AdminKeyring) StartSession(key string) (string, time.Time, error) {
+	keyID, ok := k.verifiedKeyID(key)
+	if !ok {
+		return "", time.Time{}, ErrInvalidAdminKey
+	}
+
+	session := models.AdminSession{
+		ID:        uuid.New(),
+		KeyID:     keyID,
+		ExpiresAt: time.Now().Add(AdminSessionTTL).UTC().Truncate(time.Second),
+	}
+	if err := k.db.Create(&session).Error; err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store admin session: %w", err)
+	}
+
+	k.mu.Lock()
+	k.sessions[session.ID] = time.Now()
+	k.mu.Unlock()
+	payload := fmt.Sprintf("%s.%s.%d", session.ID, keyID, session.ExpiresAt.Unix())
+	return payload + "." + k.sessionSignature(payload), session.ExpiresAt, nil
+}
+
+// VerifySession reports whether token is an unexpired session that hasn't been ended, opened
+// with a key that is still valid. A session ended or a key revoked on another instance keeps
+// working here for up to adminKeyCacheTTL.
+func (k *AdminKeyring) VerifySession(token string) bool {
+	id, keyID, expiresAt, ok := k.parseSessionToken(token)
+	if !ok || !time.Now().Before(expiresAt) {
+		return false
+	}
+
+	k.mu.Lock()
+	if !k.keyActiveLocked(keyID) {
+		k.mu.Unlock()
+		return false
+	}
+	if at, ok := k.sessions[id]; ok && time.Since(at) < adminKeyCacheTTL {
+		k.mu.Unlock()
+		return true
+	}
+	k.mu.Unlock()
+
+	var session models.AdminSession
+	err := k.db.Select("id").Where("id = ? AND key_id = ? AND revoked_at IS NULL", id, keyID).Take(&session).Error
+	if err != nil {
+		return false
+	}
+	k.mu.Lock()
+	k.sessions[id] = time.Now()
+	k.mu.Unlock()
+	return true
+}
+
+// EndSession revokes the session token names, so its cookie stops working even if it was
+// copied. Tokens that aren't this keyring's own are ignored.
+func (k *AdminKeyring) EndSession(token string) error {
+	id, _, _, ok := k.parseSessionToken(token)
+	if !ok {
+		return nil
+	}
+	k.mu.Lock()
+	delete(k.sessions, id)
+	k.mu.Unlock()
+
+	if err := k.db.Model(&models.AdminSession{}).Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC()).Error; err != nil {
+		return fmt.Errorf("failed to end admin session: %w", err)
+	}
+	return nil
+}
+
+// parseSessionToken checks a token's signature and returns what it names
+func (k *AdminKeyring) parseSessionToken(token string) (uuid.UUID, string, time.Time, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return uuid.Nil, "", time.Time{}, false
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(k.sessionSignature(payload))) {
+		return uuid.Nil, "", time.Time{}, false
+	}
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return uuid.Nil, "", time.Time{}, false
+	}
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", time.Time{}, false
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return uuid.Nil, "", time.Time{}, false
+	}
+	return id, parts[1], time.Unix(expires, 0), true
+}
+
+// sessionSignature signs a session payload. The purpose prefix keeps it from being valid for
+// anything else signed with the same secret.
+func (k *AdminKeyring) sessionSignature(payload string) string {
+	mac := hmac.New(sha256.New, k.sessionSecret)
+	mac.Write([]byte("admin-session:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+const adminSessionSQL = `SELECT "id" FROM "admin_sessions" WHERE id = $1 AND key_id = $2 AND revoked_at IS NULL LIMIT $3`
+
+func TestAdminSessionLifecycle(t *testing.T) {
+	db, mock := testdb.New(t)
+	keyID := uuid.New()
+	mock.ExpectQuery(activeAdminKeysSQL).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash"}).AddRow(keyID.String(), mustHashAdminKey(t, "wbk_generated")))
+	keyring, err := NewAdminKeyring(db, &config.Config{AdminSessionSecret: "session-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := keyring.StartSession("wbk_guess"); !errors.Is(err, ErrInvalidAdminKey) {
+		t.Fatalf("StartSession() with a wrong key error = %v, want ErrInvalidAdminKey", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "admin_sessions" ("key_id","expires_at","revoked_at","id")`).
+		WithArgs(keyID.String(), testdb.Any, nil, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New().String(), time.Now()))
+	mock.ExpectCommit()
+	token, expiresAt, err := keyring.StartSession("wbk_generated")
+	if err != nil {
+		t.Fatalf("StartSession() error = %v", err)
+	}
+	if strings.Contains(token, "wbk_generated") || !strings.Contains(token, keyID.String()) {
+		t.Errorf("token %q should name the key's ID and never hold the key", token)
+	}
+	if got := time.Until(expiresAt); got < AdminSessionTTL-time.Minute || got > AdminSessionTTL {
+		t.Errorf("session expires in %s, want %s", got, AdminSessionTTL)
+	}
+
+	// Any query here would be unexpected: the new session and the key are both cached
+	if !keyring.VerifySession(token) {
+		t.Fatal("VerifySession() rejected a new session")
+	}
+
+	id, _, _, _ := keyring.parseSessionToken(token)
+	forged := []string{
+		"",
+		token + "x",
+		strings.Replace(token, fmt.Sprint(expiresAt.Unix()), fmt.Sprint(expiresAt.Unix()+3600), 1),
+		strings.Replace(token, keyID.String(), adminKeyIDEnvHash, 1),
+	}
+	for _, bad := range forged {
+		if keyring.VerifySession(bad) {
+			t.Errorf("VerifySession(%q) accepted a tampered token", bad)
+		}
+	}
+	expired := fmt.Sprintf("%s.%s.%d", id, keyID, time.Now().Add(-time.Minute).Unix())
+	if keyring.VerifySession(expired + "." + keyring.sessionSignature(expired)) {
+		t.Error("VerifySession() accepted an expired session")
+	}
+	other, err := NewAdminKeyring(db, &config.Config{AdminSessionSecret: "another-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.VerifySession(token) {
+		t.Error("VerifySession() accepted a session signed with another secret")
+	}
+
+	// Past the cache TTL the session is looked up again
+	expireAdminKeyCache(keyring)
+	mock.ExpectQuery(activeAdminKeysSQL).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash"}).AddRow(keyID.String(), mustHashAdminKey(t, "wbk_generated")))
+	mock.ExpectQuery(adminSessionSQL).WithArgs(id, keyID.String(), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id.String()))
+	if !keyring.VerifySession(token) {
+		t.Fatal("VerifySession() rejected a live session after the cache expired")
+	}
+
+	// Signing out revokes it at once, even for a copy of the cookie
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "admin_sessions" SET "revoked_at"=$1 WHERE id = $2 AND revoked_at IS NULL`).
+		WithArgs(testdb.Any, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := keyring.EndSession(token); err != nil {
+		t.Fatalf("EndSession() error = %v", err)
+	}
+	mock.ExpectQuery(adminSessionSQL).WithArgs(id, keyID.String(), 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if keyring.VerifySession(token) {
+		t.Error("VerifySession() accepted a session after EndSession()")
+	}
+}
+
+// TestAdminSessionEndsWithItsKey checks a session stops working once the key it was opened
+// with is revoked
+func TestAdminSessionEndsWithItsKey(t *testing.T) {
+	db, mock := testdb.New(t)
+	keyID := uuid.New()
+	mock.ExpectQuery(activeAdminKeysSQL).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash"}).AddRow(keyID.String(), mustHashAdminKey(t, "wbk_generated")))
+	keyring, err := NewAdminKeyring(db, &config.Config{AdminSessionSecret: "session-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "admin_sessions"`).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New().String(), time.Now()))
+	mock.ExpectCommit()
+	token, _, err := keyring.StartSession("wbk_generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is revoked elsewhere; the session fails once the keys are reloaded
+	expireAdminKeyCache(keyring)
+	mock.ExpectQuery(activeAdminKeysSQL).WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash"}))
+	if keyring.VerifySession(token) {
+		t.Error("VerifySession() accepted a session whose key was revoked")
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/observability"
+)
+
+// anthropicAPIVersion is the Messages API version requests are written against
+const anthropicAPIVersion = "2023-06-01"
+
+// maxAnthropicErrorBytes bounds how much of an error response is read for its message
+const maxAnthropicErrorBytes = 64 * 1024
+
+// AnthropicVisionProvider calls the Anthropic Messages API. The API has no JSON mode, so
+// the reply is prefilled with "{" to hold the model to the prompt's JSON object.
+type AnthropicVisionProvider struct {
+	httpClient *http.Client
+	config     *config.Config
+}
+
+func NewAnthropicVisionProvider(cfg *config.Config) *AnthropicVisionProvider {
+	return &AnthropicVisionProvider{httpClient: observability.HTTPClient(&http.Client{}), config: cfg}
+}
+
+func (p *AnthropicVisionProvider) Name() string {
+	return VisionProviderAnthropic
+}
+
+// ImageLimits keeps images within what Claude sees unscaled (1568px on the long side) and
+// under the API's 5MB per image
+func (p *AnthropicVisionProvider) ImageLimits() VisionImageLimits {
+	return VisionImageLimits{MaxLongSide: 1568, MaxEncodedBytes: 5 * 1024 * 1024}
+}
+
+type anthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"` // a string, or content blocks
+}
+
+type anthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Messages    []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *AnthropicVisionProvider) Complete(ctx context.Context, req VisionRequest) (string, VisionUsage, error) {
+	usage := VisionUsage{Model: req.Model}
+	body, err := json.Marshal(anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   visionMaxTokens,
+		Temperature: visionTemperature,
+		Messages: []anthropicMessage{
+			{
+				Role: "user",
+				Content: []anthropicContentBlock{
+					{
+						Type:   "image",
+						Source: &anthropicImageSource{Type: "base64", MediaType: req.MediaType, Data: req.ImageData},
+					},
+					{Type: "text", Text: req.Prompt},
+				},
+			},
+			{Role: "assistant", Content: "{"},
+		},
+	})
+	if err != nil {
+		return "", usage, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	// Each attempt gets its own timeout; retries stay within the caller's deadline
+	resp, err := callWithRetry(ctx, NewOpenAIRetryPolicy(p.config), "Anthropic "+req.Model+" vision", func(ctx context.Context) (*anthropicResponse, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.config.OpenAITimeoutMS)*time.Millisecond)
+		defer cancel()
+		return p.post(ctx, body)
+	})
+	if err != nil {
+		return "", usage, err
+	}
+	usage.ServedModel = resp.Model
+	usage.PromptTokens = resp.Usage.InputTokens
+	usage.CompletionTokens = resp.Usage.OutputTokens
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", usage, fmt.Errorf("no response from %s (stop reason %q)", req.Model, resp.StopReason)
+	}
+	// The reply continues the prefilled "{"
+	return "{" + text.String(), usage, nil
+}
+
+// post sends one Messages API request. Non-200 responses come back as an
+// UpstreamStatusError, so 429s and 5xx (including 529 overloaded) are retried.
+func (p *AnthropicVisionProvider) post(ctx context.Context, body []byte) (*anthropicResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.config.AnthropicBaseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.config.AnthropicAPIKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &UpstreamStatusError{Service: "anthropic", StatusCode: resp.StatusCode}
+		var apiErr anthropicErrorResponse
+		if data, readErr := io.ReadAll(io.LimitReader(resp.Body, maxAnthropicErrorBytes)); readErr == nil &&
+			json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("%w: %s: %s", statusErr, apiErr.Error.Type, apiErr.Error.Message)
+		}
+		return nil, statusErr
+	}
+
+	var parsed anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &parsed, nil
+}
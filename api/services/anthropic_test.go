@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+// anthropicTestConfig points the Anthropic provider at server, retrying quickly
+func anthropicTestConfig(server *httptest.Server) *config.Config {
+	return &config.Config{
+		AnthropicAPIKey:   "test-key",
+		AnthropicBaseURL:  server.URL + "/",
+		OpenAITimeoutMS:   5000,
+		OpenAIMaxRetries:  2,
+		OpenAIRetryBaseMS: 1,
+	}
+}
+
+func TestAnthropicVisionProviderRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+			t.Errorf("request = %s %s, want POST /v1/messages", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicAPIVersion {
+			t.Errorf("headers = %v, want the API key and version", r.Header)
+		}
+
+		var body struct {
+			Model     string `json:"model"`
+			MaxTokens int    `json:"max_tokens"`
+			Messages  []struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Model != "claude-3-5-sonnet-20241022" || body.MaxTokens != visionMaxTokens {
+			t.Errorf("model = %q, max_tokens = %d", body.Model, body.MaxTokens)
+		}
+		if len(body.Messages) != 2 {
+			t.Fatalf("sent %d messages, want the user turn and the prefill", len(body.Messages))
+		}
+		var blocks []anthropicContentBlock
+		if body.Messages[0].Role != "user" || json.Unmarshal(body.Messages[0].Content, &blocks) != nil || len(blocks) != 2 {
+			t.Fatalf("user message = %s", body.Messages[0].Content)
+		}
+		if image := blocks[0]; image.Type != "image" || image.Source == nil ||
+			*image.Source != (anthropicImageSource{Type: "base64", MediaType: "image/png", Data: "aW1hZ2U="}) {
+			t.Errorf("first block = %+v, want the base64 image", image)
+		}
+		if blocks[1].Type != "text" || blocks[1].Text != "Find the flyers" {
+			t.Errorf("second block = %+v, want the prompt", blocks[1])
+		}
+		if body.Messages[1].Role != "assistant" || string(body.Messages[1].Content) != `"{"` {
+			t.Errorf("last message = %s %s, want the assistant prefill", body.Messages[1].Role, body.Messages[1].Content)
+		}
+
+		io.WriteString(w, `{"model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn",
+			"content":[{"type":"text","text":"\"flyers\": [\n"},{"type":"text","text":"]}"}],
+			"usage":{"input_tokens":1200,"output_tokens":8}}`)
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicVisionProvider(anthropicTestConfig(server))
+	reply, usage, err := provider.Complete(context.Background(), VisionRequest{
+		Model: "claude-3-5-sonnet-20241022", Prompt: "Find the flyers", ImageData: "aW1hZ2U=", MediaType: "image/png",
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	// The reply continues the prefilled brace, across every text block
+	if reply != "{\"flyers\": [\n]}" {
+		t.Errorf("reply = %q, want the prefill joined to the text blocks", reply)
+	}
+	if usage.ServedModel != "claude-3-5-sonnet-20241022" || usage.PromptTokens != 1200 || usage.CompletionTokens != 8 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestAnthropicVisionProviderFailures(t *testing.T) {
+	const ok = `{"model":"claude-3-5-sonnet-20241022","content":[{"type":"text","text":"}"}],"usage":{"input_tokens":5,"output_tokens":1}}`
+
+	tests := []struct {
+		name         string
+		statuses     []int // of each attempt; the last repeats
+		body         string
+		wantAttempts int32
+		wantStatus   int    // of the UpstreamStatusError; 0 for none
+		wantErr      string // in the error message; empty for success
+	}{
+		{name: "rate limited, then served", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, body: ok, wantAttempts: 2},
+		{name: "overloaded, then served", statuses: []int{529, http.StatusOK}, body: ok, wantAttempts: 2},
+		{
+			name:         "overloaded throughout",
+			statuses:     []int{529},
+			body:         `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			wantAttempts: 3,
+			wantStatus:   529,
+			wantErr:      "overloaded_error: Overloaded",
+		},
+		{
+			name:         "bad request",
+			statuses:     []int{http.StatusBadRequest},
+			body:         `{"type":"error","error":{"type":"invalid_request_error","message":"image too large"}}`,
+			wantAttempts: 1,
+			wantStatus:   http.StatusBadRequest,
+			wantErr:      "invalid_request_error: image too large",
+		},
+		{name: "error without a body", statuses: []int{http.StatusUnauthorized}, wantAttempts: 1, wantStatus: http.StatusUnauthorized, wantErr: "status 401"},
+		{
+			name:         "empty content",
+			statuses:     []int{http.StatusOK},
+			body:         `{"model":"claude-3-5-sonnet-20241022","stop_reason":"refusal","content":[],"usage":{"input_tokens":5,"output_tokens":0}}`,
+			wantAttempts: 1,
+			wantErr:      `no response from claude-3-5-sonnet-20241022 (stop reason "refusal")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1))
+				status := tt.statuses[min(n, len(tt.statuses))-1]
+				w.WriteHeader(status)
+				if status == http.StatusOK || n >= len(tt.statuses) {
+					io.WriteString(w, tt.body)
+				}
+			}))
+			defer server.Close()
+
+			provider := NewAnthropicVisionProvider(anthropicTestConfig(server))
+			reply, usage, err := provider.Complete(context.Background(), VisionRequest{Model: "claude-3-5-sonnet-20241022", Prompt: "p", MediaType: "image/jpeg"})
+
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("made %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if tt.wantErr == "" {
+				if err != nil || reply != "{}" {
+					t.Errorf("Complete() = %q, %v; want {}", reply, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Complete() error = %v, want %q", err, tt.wantErr)
+			}
+			var statusErr *UpstreamStatusError
+			if gotStatus := errors.As(err, &statusErr); gotStatus != (tt.wantStatus != 0) || (gotStatus && statusErr.StatusCode != tt.wantStatus) {
+				t.Errorf("Complete() error = %#v, want an upstream status %d", err, tt.wantStatus)
+			}
+			if usage.Model != "claude-3-5-sonnet-20241022" {
+				t.Errorf("usage.Model = %q, want the requested model even on failure", usage.Model)
+			}
+		})
+	}
+}
+
+// TestVisionServiceChoosesProvider checks a model goes to VISION_PROVIDER unless it names its
+// provider, and that a provider without an API key is refused
+func TestVisionServiceChoosesProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config.Config
+		model        string
+		wantProvider string
+		wantModel    string
+	}{
+		{"VISION_PROVIDER=openai", config.Config{VisionProvider: VisionProviderOpenAI, OpenAIAPIKey: "k", AnthropicAPIKey: "k"}, "gpt-4o", VisionProviderOpenAI, "gpt-4o"},
+		{"VISION_PROVIDER=anthropic", config.Config{VisionProvider: VisionProviderAnthropic, AnthropicAPIKey: "k"}, "claude-3-5-sonnet-20241022", VisionProviderAnthropic, "claude-3-5-sonnet-20241022"},
+		{"prefix names Anthropic", config.Config{OpenAIAPIKey: "k", AnthropicAPIKey: "k"}, "anthropic/claude-3-5-sonnet-20241022", VisionProviderAnthropic, "claude-3-5-sonnet-20241022"},
+		{"prefix names OpenAI", config.Config{VisionProvider: VisionProviderAnthropic, OpenAIAPIKey: "k", AnthropicAPIKey: "k"}, "openai/gpt-4o", VisionProviderOpenAI, "gpt-4o"},
+		{"unknown prefix stays in the model", config.Config{VisionProvider: VisionProviderOpenAI, OpenAIAPIKey: "k"}, "ft/gpt-4o", VisionProviderOpenAI, "ft/gpt-4o"},
+		{"Anthropic without a key", config.Config{OpenAIAPIKey: "k"}, "anthropic/claude-3-5-sonnet-20241022", "", ""},
+		{"OpenAI without a key", config.Config{VisionProvider: VisionProviderOpenAI, AnthropicAPIKey: "k"}, "gpt-4o", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVisionService(&tt.cfg, nil)
+			provider, model, err := v.provider(tt.model)
+			if tt.wantProvider == "" {
+				if !errors.Is(err, ErrVisionProviderUnavailable) {
+					t.Errorf("provider(%q) error = %v, want ErrVisionProviderUnavailable", tt.model, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("provider(%q) error = %v", tt.model, err)
+			}
+			if provider.Name() != tt.wantProvider || model != tt.wantModel {
+				t.Errorf("provider(%q) = %s %q, want %s %q", tt.model, provider.Name(), model, tt.wantProvider, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestDefaultVisionModel(t *testing.T) {
+	cfg := &config.Config{OpenAIModel: "gpt-4o", AnthropicModel: "claude-3-5-sonnet-20241022"}
+	if got := DefaultVisionModel(cfg); got != "gpt-4o" {
+		t.Errorf("DefaultVisionModel() = %q, want the OpenAI model", got)
+	}
+	cfg.VisionProvider = VisionProviderAnthropic
+	if got := DefaultVisionModel(cfg); got != "claude-3-5-sonnet-20241022" {
+		t.Errorf("DefaultVisionModel() with VISION_PROVIDER=anthropic = %q, want the Anthropic model", got)
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// Audit entity types
+const (
+	AuditEntityCandidate  = "event_candidate"
+	AuditEntityEvent      = "event"
+	AuditEntityVenue      = "venue"
+	AuditEntityFlyer      = "flyer"
+	AuditEntitySetting    = "category_publish_setting"
+	AuditEntityAdminKey   = "admin_api_key"
+	AuditEntitySubmission = "submission"
+)
+
+// AuditAction is one of the fixed audit log actions below; RecordAudit rejects anything else
+type AuditAction string
+
+// Audit actions for candidate publish decisions
+const (
+	AuditActionCandidatePublished   AuditAction = "candidate.published"
+	AuditActionCandidateBlocked     AuditAction = "candidate.blocked"
+	AuditActionCandidateNeedsReview AuditAction = "candidate.needs_review"
+)
+
+// Audit actions for admin edits to candidates
+const (
+	AuditActionCandidateEdited AuditAction = "candidate.edited"
+)
+
+// Audit actions for restoring blocked candidates
+const (
+	AuditActionCandidateRestored AuditAction = "candidate.restored"
+)
+
+// Audit actions for published event changes
+const (
+	AuditActionEventUnpublished AuditAction = "event.unpublished"
+	AuditActionEventEdited      AuditAction = "event.edited"
+	AuditActionEventDeleted     AuditAction = "event.deleted"
+)
+
+// Audit actions for near-duplicate merges
+const (
+	AuditActionEventMerged        AuditAction = "event.merged"
+	AuditActionEventMergeAccepted AuditAction = "event.merge_accepted"
+	AuditActionEventMergeRejected AuditAction = "event.merge_rejected"
+)
+
+// Audit actions for public flags
+const (
+	AuditActionEventFlagged       AuditAction = "event.flagged"
+	AuditActionEventFlagThreshold AuditAction = "event.flag_threshold"
+	AuditActionEventFlagDismissed AuditAction = "event.flag_dismissed"
+)
+
+// Audit actions for venue manager disputes
+const (
+	AuditActionEventDisputed        AuditAction = "event.disputed"
+	AuditActionEventDisputeRejected AuditAction = "event.dispute_rejected"
+)
+
+// Audit actions for venue maintenance
+const (
+	AuditActionVenueDeleted AuditAction = "venue.deleted"
+	AuditActionVenueMerged  AuditAction = "venue.merged"
+)
+
+// Audit actions for venue claims
+const (
+	AuditActionVenueClaimCreated AuditAction = "venue.claim_created"
+	AuditActionVenueClaimRevoked AuditAction = "venue.claim_revoked"
+)
+
+// Audit actions for flyer images
+const (
+	AuditActionFlyerRedacted    AuditAction = "flyer.redacted"
+	AuditActionFlyerReextracted AuditAction = "flyer.reextracted"
+)
+
+// Audit actions for auto-publish settings
+const (
+	AuditActionSettingUpdated AuditAction = "setting.updated"
+	AuditActionSettingDeleted AuditAction = "setting.deleted"
+)
+
+// Audit actions for admin API keys
+const (
+	AuditActionAdminKeyCreated AuditAction = "admin_key.created"
+)
+
+// Audit actions for submission processing
+const (
+	AuditActionSubmissionFailed      AuditAction = "submission.failed"
+	AuditActionSubmissionReprocessed AuditAction = "submission.reprocessed"
+)
+
+// AuditActions lists every valid audit action
+var AuditActions = []AuditAction{
+	AuditActionCandidatePublished,
+	AuditActionCandidateBlocked,
+	AuditActionCandidateNeedsReview,
+	AuditActionCandidateEdited,
+	AuditActionCandidateRestored,
+	AuditActionEventUnpublished,
+	AuditActionEventEdited,
+	AuditActionEventDeleted,
+	AuditActionEventMerged,
+	AuditActionEventMergeAccepted,
+	AuditActionEventMergeRejected,
+	AuditActionEventFlagged,
+	AuditActionEventFlagThreshold,
+	AuditActionEventFlagDismissed,
+	AuditActionEventDisputed,
+	AuditActionEventDisputeRejected,
+	AuditActionVenueDeleted,
+	AuditActionVenueMerged,
+	AuditActionVenueClaimCreated,
+	AuditActionVenueClaimRevoked,
+	AuditActionFlyerRedacted,
+	AuditActionFlyerReextracted,
+	AuditActionSettingUpdated,
+	AuditActionSettingDeleted,
+	AuditActionAdminKeyCreated,
+	AuditActionSubmissionFailed,
+	AuditActionSubmissionReprocessed,
+}
+
+// IsValid reports whether a is one of AuditActions
+func (a AuditAction) IsValid() bool {
+	for _, action := range AuditActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Value stores the action as plain text, so actions can be used directly as query arguments
+func (a AuditAction) Value() (driver.Value, error) {
+	return string(a), nil
+}
+
+// DecisiveCandidateActions are the audit actions that resolve a candidate's review
+var DecisiveCandidateActions = []AuditAction{
+	AuditActionCandidatePublished,
+	AuditActionCandidateBlocked,
+}
+
+// Actor is who performed an audited action. Type is one of the Actor* constants; AdminID
+// and Route are set for admin requests (the acting moderator and the Gin route template).
+type Actor struct {
+	Type    string
+	AdminID string
+	Route   string
+}
+
+// SystemActor is an actor with no request behind it, such as the publish pipeline
+func SystemActor(actorType string) Actor {
+	return Actor{Type: actorType}
+}
+
+// AuditEntry describes a single audit log write. DecisionLatency is set on candidate
+// decisions: the time from extraction to the decision.
+type AuditEntry struct {
+	EntityType      string
+	EntityID        uuid.UUID
+	Action          AuditAction
+	Actor           Actor
+	UserID          *uuid.UUID
+	DecisionLatency *time.Duration
+	Changes         interface{}
+	Metadata        interface{}
+}
+
+// RecordAudit writes an audit log row using the given db or transaction.
+// CreatedAt is stamped explicitly so latency calculations use the decision time.
+func RecordAudit(db *gorm.DB, entry AuditEntry) error {
+	if !entry.Action.IsValid() {
+		return fmt.Errorf("unknown audit action %q", entry.Action)
+	}
+	if entry.Actor.Type == "" {
+		return fmt.Errorf("audit action %q has no actor", entry.Action)
+	}
+
+	log := models.AuditLog{
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Action:     string(entry.Action),
+		ActorType:  entry.Actor.Type,
+		AdminID:    optionalString(entry.Actor.AdminID),
+		Route:      optionalString(entry.Actor.Route),
+		UserID:     entry.UserID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if entry.DecisionLatency != nil {
+		ms := entry.DecisionLatency.Milliseconds()
+		log.LatencyMs = &ms
+	}
+
+	if entry.Changes != nil {
+		changesJSON, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit changes: %w", err)
+		}
+		changes := string(changesJSON)
+		log.Changes = &changes
+	}
+
+	if entry.Metadata != nil {
+		metadataJSON, err := json.Marshal(entry.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit metadata: %w", err)
+		}
+		metadata := string(metadataJSON)
+		log.Metadata = &metadata
+	}
+
+	if err := db.Create(&log).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// CandidateDecisionAction maps a publish result to its audit action
+func CandidateDecisionAction(publishResult string) AuditAction {
+	switch publishResult {
+	case "published":
+		return AuditActionCandidatePublished
+	case "blocked":
+		return AuditActionCandidateBlocked
+	default:
+		return AuditActionCandidateNeedsReview
+	}
+}
+
+// optionalString maps "" to nil for nullable columns
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Who blocked a candidate, read from its latest candidate.blocked audit entry
+const (
+	BlockSourceModeration = "moderation" // the moderation model judged it inappropriate
+	BlockSourceAdmin      = "admin"      // rejected, unpublished or deleted by a moderator or API key
+	BlockSourceRule       = "rule"       // a background job or other automatic rule
+	BlockSourceUnknown    = "unknown"    // blocked before decisions were audited
+)
+
+// Where a restored candidate goes
+const (
+	RestoreActionReview  = "review"  // back to needs_review for a normal decision
+	RestoreActionApprove = "approve" // straight to published, through the usual promotion
+)
+
+// ReviewReasonRestored marks a candidate an admin restored from blocked for another look
+const ReviewReasonRestored = "restored"
+
+// RecentlyBlockedLimit bounds the dashboard's recently blocked table
+const RecentlyBlockedLimit = 25
+
+var (
+	// ErrCandidateNotFound is returned when the candidate to restore does not exist
+	ErrCandidateNotFound = errors.New("candidate not found")
+	// ErrCandidateNotBlocked is returned when restoring a candidate that isn't blocked
+	ErrCandidateNotBlocked = errors.New("candidate is not blocked")
+	// ErrInvalidRestoreAction is returned for actions other than review and approve
+	ErrInvalidRestoreAction = errors.New("invalid restore action")
+	// ErrRestoreReasonRequired is returned when a restore gives no reason
+	ErrRestoreReasonRequired = errors.New("restore reason is required")
+)
+
+// BlockedCandidate is a blocked candidate with who blocked it and why
+type BlockedCandidate struct {
+	CandidateID    uuid.UUID  `json:"candidate_id"`
+	Title          string     `json:"title"`
+	CompositeScore *float64   `json:"composite_score"`
+	Reason         *string    `json:"reason"` // the candidate's publication reason
+	Source         string     `json:"source"` // one of the BlockSource* constants
+	AdminID        *string    `json:"admin_id,omitempty"`
+	BlockedAt      *time.Time `json:"blocked_at"` // nil when the block wasn't audited
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// latestBlockSQL joins each candidate to its most recent candidate.blocked audit entry
+const latestBlockSQL = `LEFT JOIN LATERAL (
+	SELECT al.actor_type, al.admin_id, al.created_at FROM audit_logs al
+	WHERE al.entity_type = ? AND al.entity_id = event_candidates.id AND al.action = ?
+	ORDER BY al.created_at DESC LIMIT 1) blocked ON true`
+
+// ListRecentlyBlocked returns up to limit blocked candidates, most recently blocked first
+func ListRecentlyBlocked(db *gorm.DB, limit int) ([]BlockedCandidate, error) {
+	var rows []struct {
+		ID             uuid.UUID
+		Title          string
+		CompositeScore *float64
+		Reason         *string
+		ActorType      *string
+		AdminID        *string
+		BlockedAt      *time.Time
+		CreatedAt      time.Time
+	}
+	if err := db.Table("event_candidates").
+		Select(`event_candidates.id, COALESCE(event_candidates.fields->>'title', '') AS title,
+			event_candidates.composite_score, event_candidates.publication_reason AS reason,
+			blocked.actor_type, blocked.admin_id, blocked.created_at AS blocked_at, event_candidates.created_at`).
+		Joins(latestBlockSQL, AuditEntityCandidate, AuditActionCandidateBlocked).
+		Where("event_candidates.publish_result = ?", "blocked").
+		Order("COALESCE(blocked.created_at, event_candidates.created_at) DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list blocked candidates: %w", err)
+	}
+
+	blocked := make([]BlockedCandidate, 0, len(rows))
+	for _, row := range rows {
+		blocked = append(blocked, BlockedCandidate{
+			CandidateID:    row.ID,
+			Title:          row.Title,
+			CompositeScore: row.CompositeScore,
+			Reason:         row.Reason,
+			Source:         blockSource(row.ActorType),
+			AdminID:        row.AdminID,
+			BlockedAt:      row.BlockedAt,
+			CreatedAt:      row.CreatedAt,
+		})
+	}
+	return blocked, nil
+}
+
+// blockSource maps the actor of a candidate.blocked entry to a BlockSource* constant
+func blockSource(actorType *string) string {
+	if actorType == nil {
+		return BlockSourceUnknown
+	}
+	switch *actorType {
+	case ActorAuto:
+		return BlockSourceModeration
+	case ActorAdmin, ActorAPI:
+		return BlockSourceAdmin
+	default:
+		return BlockSourceRule
+	}
+}
+
+// RestoreBlockedCandidate takes a blocked candidate out of blocked inside the caller's
+// transaction. RestoreActionReview returns it to needs_review and queues the usual review
+// notification; RestoreActionApprove marks it published, and the caller promotes it to a
+// public event in the same transaction. Either way the restore is audit-logged with the reason.
+func RestoreBlockedCandidate(tx *gorm.DB, candidateID uuid.UUID, action, reason string, actor Actor) (*models.EventCandidate, error) {
+	var publishResult string
+	switch action {
+	case RestoreActionReview:
+		publishResult = "needs_review"
+	case RestoreActionApprove:
+		publishResult = "published"
+	default:
+		return nil, ErrInvalidRestoreAction
+	}
+	if reason == "" {
+		return nil, ErrRestoreReasonRequired
+	}
+
+	var candidate models.EventCandidate
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&candidate, "id = ?", candidateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCandidateNotFound
+		}
+		return nil, fmt.Errorf("failed to load candidate: %w", err)
+	}
+	if candidate.PublishResult == nil || *candidate.PublishResult != "blocked" {
+		return nil, ErrCandidateNotBlocked
+	}
+
+	blockedReason := candidate.PublicationReason
+	publicationReason := "restored: " + reason
+	updates := map[string]interface{}{
+		"publish_result":     publishResult,
+		"publication_reason": publicationReason,
+	}
+	if action == RestoreActionReview {
+		updates["review_reason"] = ReviewReasonRestored
+	}
+	if err := tx.Model(&candidate).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to restore candidate: %w", err)
+	}
+	candidate.PublishResult = &publishResult
+	candidate.PublicationReason = &publicationReason
+
+	if err := RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityCandidate,
+		EntityID:   candidate.ID,
+		Action:     AuditActionCandidateRestored,
+		Actor:      actor,
+		Changes: map[string]interface{}{
+			"publish_result": map[string]string{"from": "blocked", "to": publishResult},
+		},
+		Metadata: map[string]interface{}{
+			"reason":         reason,
+			"blocked_reason": blockedReason,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	if action != RestoreActionReview {
+		return &candidate, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
+		log.Printf("Candidate %s has unreadable fields: %v", candidate.ID, err)
+	}
+	title, _ := fields["title"].(string)
+	if err := EnqueueOutbox(tx, OutboxTopicCandidateNeedsReview, candidate.ID, CandidateReviewPayload{
+		CandidateID:  candidate.ID,
+		Title:        title,
+		ReviewReason: ReviewReasonRestored,
+		Reason:       publicationReason,
+	}); err != nil {
+		return nil, err
+	}
+	return &candidate, nil
+}
+
+// PurgeBlockedCandidates removes candidates that moderation blocked before olderThan and
+// that nobody has restored. Submissions left with no other candidates go too, along with
+// their flyers and stored images; a submission that still has other candidates keeps its
+// files. Candidates blocked by an admin, or before blocks were audited, are never purged.
+func PurgeBlockedCandidates(db *gorm.DB, storage *StorageService, olderThan time.Time, dryRun bool) (*PurgeReport, error) {
+	report := &PurgeReport{DryRun: dryRun, OlderThan: olderThan}
+
+	var candidateIDs []uuid.UUID
+	if err := db.Model(&models.EventCandidate{}).
+		Joins(latestBlockSQL, AuditEntityCandidate, AuditActionCandidateBlocked).
+		Where("event_candidates.publish_result = ? AND event_candidates.published_event_id IS NULL", "blocked").
+		Where("blocked.actor_type = ? AND blocked.created_at < ?", ActorAuto, olderThan).
+		Pluck("event_candidates.id", &candidateIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to find purgeable blocked candidates: %w", err)
+	}
+	report.Candidates = int64(len(candidateIDs))
+	if len(candidateIDs) == 0 {
+		return report, nil
+	}
+
+	// Submissions whose every candidate is being purged
+	if err := db.Model(&models.Submission{}).
+		Where(`EXISTS (
+			SELECT 1 FROM flyers f JOIN event_candidates ec ON ec.flyer_id = f.id
+			WHERE f.submission_id = submissions.id AND ec.id IN ?)`, candidateIDs).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM flyers f JOIN event_candidates ec ON ec.flyer_id = f.id
+			WHERE f.submission_id = submissions.id AND ec.id NOT IN ?)`, candidateIDs).
+		Pluck("id", &report.SubmissionIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to find purgeable submissions: %w", err)
+	}
+	if len(report.SubmissionIDs) > 0 {
+		if err := db.Model(&models.Flyer{}).Where("submission_id IN ?", report.SubmissionIDs).Count(&report.Flyers).Error; err != nil {
+			return nil, fmt.Errorf("failed to count flyers: %w", err)
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ?", candidateIDs).Delete(&models.EventCandidate{}).Error; err != nil {
+			return fmt.Errorf("failed to delete candidates: %w", err)
+		}
+		if len(report.SubmissionIDs) == 0 {
+			return nil
+		}
+		if err := tx.Where("submission_id IN ?", report.SubmissionIDs).Delete(&models.Flyer{}).Error; err != nil {
+			return fmt.Errorf("failed to delete flyers: %w", err)
+		}
+		if err := tx.Where("id IN ?", report.SubmissionIDs).Delete(&models.Submission{}).Error; err != nil {
+			return fmt.Errorf("failed to delete submissions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Files go last so a failed transaction never leaves rows pointing at missing images
+	for _, id := range report.SubmissionIDs {
+		if err := storage.DeleteSubmissionFiles(id); err != nil {
+			log.Printf("Purge: failed to delete files for submission %s: %v", id, err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+func TestPurgeBlockedCandidates(t *testing.T) {
+	olderThan := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []uuid.UUID{uuid.New(), uuid.New()}
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		candidates  []uuid.UUID // moderation blocks old enough to purge
+		submissions int         // submissions left with no other candidates
+		wantFiles   bool        // the submission's images survive
+	}{
+		{name: "nothing old enough", wantFiles: true},
+		{name: "dry run counts but keeps everything", dryRun: true, candidates: candidates, submissions: 1, wantFiles: true},
+		{name: "submission with nothing else goes with its images", candidates: candidates, submissions: 1},
+		{name: "submission with other candidates keeps its images", candidates: candidates[:1], wantFiles: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewStorageService(&config.Config{UploadDir: t.TempDir()})
+			submissionID := uuid.New()
+			dir := filepath.Dir(storage.GetFilePath(submissionID, "original.jpg"))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			writeTestPhoto(t, dir, 40, 30, false)
+			var submissions []uuid.UUID
+			if tt.submissions > 0 {
+				submissions = []uuid.UUID{submissionID}
+			}
+
+			db, mock := testdb.New(t)
+			// Only moderation blocks count, never an admin's or an unaudited one
+			mock.ExpectQuery(`published_event_id IS NULL) AND (blocked.actor_type = $4 AND blocked.created_at < $5)`).
+				WithArgs(AuditEntityCandidate, string(AuditActionCandidateBlocked), "blocked", ActorAuto, olderThan).
+				WillReturnRows(testdb.IDs(tt.candidates...))
+			if len(tt.candidates) > 0 {
+				mock.ExpectQuery(`SELECT "id" FROM "submissions" WHERE (EXISTS`).
+					WillReturnRows(testdb.IDs(submissions...))
+			}
+			if len(submissions) > 0 {
+				mock.ExpectQuery(`SELECT count(*) FROM "flyers" WHERE submission_id IN ($1)`).
+					WithArgs(submissionID).
+					WillReturnRows(testdb.Count(2))
+			}
+			if len(tt.candidates) > 0 && !tt.dryRun {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM "event_candidates" WHERE id IN`).
+					WillReturnResult(sqlmock.NewResult(0, int64(len(tt.candidates))))
+				if len(submissions) > 0 {
+					mock.ExpectExec(`DELETE FROM "flyers" WHERE submission_id IN ($1)`).
+						WithArgs(submissionID).
+						WillReturnResult(sqlmock.NewResult(0, 2))
+					mock.ExpectExec(`DELETE FROM "submissions" WHERE id IN ($1)`).
+						WithArgs(submissionID).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit()
+			}
+
+			report, err := PurgeBlockedCandidates(db, storage, olderThan, tt.dryRun)
+			if err != nil {
+				t.Fatalf("PurgeBlockedCandidates() error = %v", err)
+			}
+			if report.DryRun != tt.dryRun || report.Candidates != int64(len(tt.candidates)) || len(report.SubmissionIDs) != tt.submissions {
+				t.Errorf("report = %+v, want %d candidates and %d submissions", report, len(tt.candidates), tt.submissions)
+			}
+			if wantFlyers := int64(2 * tt.submissions); report.Flyers != wantFlyers {
+				t.Errorf("report counts %d flyers, want %d", report.Flyers, wantFlyers)
+			}
+			if _, err := os.Stat(dir); (err == nil) != tt.wantFiles {
+				t.Errorf("submission files exist = %v, want %v", err == nil, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestBlockSource(t *testing.T) {
+	actor := func(actorType string) *string { return &actorType }
+	tests := []struct {
+		name      string
+		actorType *string
+		want      string
+	}{
+		{"moderation model", actor(ActorAuto), BlockSourceModeration},
+		{"moderator", actor(ActorAdmin), BlockSourceAdmin},
+		{"API key", actor(ActorAPI), BlockSourceAdmin},
+		{"background job", actor(ActorSystem), BlockSourceRule},
+		{"blocked before audits", nil, BlockSourceUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockSource(tt.actorType); got != tt.want {
+				t.Errorf("blockSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling a dependency whose breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker stops calls to a dependency after repeated retryable failures.
+// Once the cooldown passes, calls are let through again; the next failure reopens it.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewCircuitBreaker opens after threshold consecutive retryable failures (0 disables)
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Allow reports whether a call may be made now
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+// Call runs fn unless the breaker is open, and records its outcome
+func (b *CircuitBreaker) Call(fn func() error) error {
+	if !b.Allow() {
+		return fmt.Errorf("%s: %w", b.name, ErrCircuitOpen)
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+// record counts retryable failures; any other outcome closes the breaker
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !IsRetryableError(err) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		b.failures = 0
+	}
+}
+
+// Name identifies the dependency in logs and admin output
+func (b *CircuitBreaker) Name() string {
+	return b.name
+}
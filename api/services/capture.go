@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/exif"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// Where a piece of capture metadata came from, in order of precedence
+const (
+	CaptureSourceClient = "client" // sent by the app with the signed-url request
+	CaptureSourceExif   = "exif"   // read from the uploaded image
+	CaptureSourceUpload = "upload" // submission creation time, when nothing better is known
+)
+
+// ReviewReasonImplausibleDistance marks candidates geocoded far from where the photo was taken
+const ReviewReasonImplausibleDistance = "implausible_distance"
+
+// ValidDeviceOrientations are the accepted deviceOrientation values
+var ValidDeviceOrientations = []string{"portrait", "portrait_upside_down", "landscape_left", "landscape_right", "face_up", "face_down"}
+
+// Bounds on client-supplied capture times
+const (
+	maxCaptureClockSkew = 10 * time.Minute
+	maxCaptureAge       = 365 * 24 * time.Hour
+)
+
+// ErrInvalidCaptureMetadata is returned for client capture metadata that fails validation
+var ErrInvalidCaptureMetadata = errors.New("invalid capture metadata")
+
+// CaptureMetadata is what the client knows about the photo at upload time
+type CaptureMetadata struct {
+	CapturedAt        *time.Time
+	Latitude          *float64
+	Longitude         *float64
+	DeviceOrientation *string
+}
+
+// ValidateCaptureMetadata checks client metadata against now
+func ValidateCaptureMetadata(m CaptureMetadata, now time.Time) error {
+	if m.CapturedAt != nil {
+		if m.CapturedAt.After(now.Add(maxCaptureClockSkew)) {
+			return fmt.Errorf("%w: capturedAt is in the future", ErrInvalidCaptureMetadata)
+		}
+		if m.CapturedAt.Before(now.Add(-maxCaptureAge)) {
+			return fmt.Errorf("%w: capturedAt is more than a year old", ErrInvalidCaptureMetadata)
+		}
+	}
+	if (m.Latitude == nil) != (m.Longitude == nil) {
+		return fmt.Errorf("%w: latitude and longitude must be sent together", ErrInvalidCaptureMetadata)
+	}
+	if m.Latitude != nil && !ValidateCoordinates(*m.Latitude, *m.Longitude) {
+		return fmt.Errorf("%w: latitude/longitude out of range", ErrInvalidCaptureMetadata)
+	}
+	if m.DeviceOrientation != nil && !isValidDeviceOrientation(*m.DeviceOrientation) {
+		return fmt.Errorf("%w: deviceOrientation must be one of %v", ErrInvalidCaptureMetadata, ValidDeviceOrientations)
+	}
+	return nil
+}
+
+func isValidDeviceOrientation(value string) bool {
+	for _, valid := range ValidDeviceOrientations {
+		if value == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyCaptureMetadata stores validated client metadata on a new submission
+func ApplyCaptureMetadata(submission *models.Submission, m CaptureMetadata) {
+	submission.CapturedAt = m.CapturedAt
+	submission.CaptureLatitude = m.Latitude
+	submission.CaptureLongitude = m.Longitude
+	submission.DeviceOrientation = m.DeviceOrientation
+}
+
+// CaptureLocation is where a photo was taken
+type CaptureLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// CaptureContext is the effective capture metadata of a submission. Every consumer
+// (date resolution, geocoding bias, distance checks) reads it through SubmissionCapture
+// so the client-over-EXIF precedence lives in one place.
+type CaptureContext struct {
+	CapturedAt        time.Time        `json:"captured_at"`
+	TimeSource        string           `json:"time_source"`
+	Location          *CaptureLocation `json:"location,omitempty"`
+	LocationSource    string           `json:"location_source,omitempty"`
+	DeviceOrientation *string          `json:"device_orientation,omitempty"`
+}
+
+// SubmissionCapture resolves capture metadata: client values win over EXIF, and the
+// capture time falls back to when the submission was created
+func SubmissionCapture(submission *models.Submission) CaptureContext {
+	capture := CaptureContext{
+		CapturedAt:        submission.CreatedAt,
+		TimeSource:        CaptureSourceUpload,
+		DeviceOrientation: submission.DeviceOrientation,
+	}
+
+	switch {
+	case submission.CapturedAt != nil:
+		capture.CapturedAt = *submission.CapturedAt
+		capture.TimeSource = CaptureSourceClient
+	case submission.ExifCapturedAt != nil:
+		capture.CapturedAt = *submission.ExifCapturedAt
+		capture.TimeSource = CaptureSourceExif
+	}
+
+	switch {
+	case submission.CaptureLatitude != nil && submission.CaptureLongitude != nil:
+		capture.Location = &CaptureLocation{Latitude: *submission.CaptureLatitude, Longitude: *submission.CaptureLongitude}
+		capture.LocationSource = CaptureSourceClient
+	case submission.ExifOptIn && submission.ExifLatitude != nil && submission.ExifLongitude != nil:
+		capture.Location = &CaptureLocation{Latitude: *submission.ExifLatitude, Longitude: *submission.ExifLongitude}
+		capture.LocationSource = CaptureSourceExif
+	}
+	return capture
+}
+
+// CandidateCapture loads the capture metadata of the submission a candidate came from
+func CandidateCapture(db *gorm.DB, candidate *models.EventCandidate) (CaptureContext, error) {
+	var submission models.Submission
+	err := db.Select("submissions.*").
+		Joins("JOIN flyers ON flyers.submission_id = submissions.id").
+		Where("flyers.id = ?", candidate.FlyerID).
+		First(&submission).Error
+	if err != nil {
+		return CaptureContext{}, err
+	}
+	return SubmissionCapture(&submission), nil
+}
+
+// RecordExifCapture reads EXIF from the stored original and saves its capture time and,
+// when the submitter opted in, its GPS position. EXIF times carry no zone, so they are
+// read in the region's timezone. Images without EXIF are left untouched.
+func RecordExifCapture(db *gorm.DB, submissionID uuid.UUID, imagePath string, loc *time.Location) error {
+	var submission models.Submission
+	if err := db.Select("id", "exif_opt_in").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return err
+	}
+
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Without the uploader's opt-in the GPS tags aren't even parsed
+	read := exif.ReadWithoutLocation
+	if submission.ExifOptIn {
+		read = exif.Read
+	}
+	data, err := read(file)
+	if err != nil {
+		if errors.Is(err, exif.ErrNoExif) {
+			return nil
+		}
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if capturedAt, ok := data.CapturedAt(loc); ok {
+		updates["exif_captured_at"] = capturedAt
+	}
+	if submission.ExifOptIn && data.Latitude != nil && data.Longitude != nil && ValidateCoordinates(*data.Latitude, *data.Longitude) {
+		updates["exif_latitude"] = *data.Latitude
+		updates["exif_longitude"] = *data.Longitude
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return db.Model(&models.Submission{}).Where("id = ?", submissionID).Updates(updates).Error
+}
+
+// DistanceKm is the great-circle distance between two points
+func DistanceKm(a CaptureLocation, lat, lng float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat - a.Latitude)
+	dLng := toRad(lng - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Latitude))*math.Cos(toRad(lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
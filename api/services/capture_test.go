@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestSubmissionCapturePrecedence(t *testing.T) {
+	uploaded := time.Date(2026, 5, 20, 18, 0, 0, 0, time.UTC)
+	clientTime := time.Date(2026, 5, 20, 17, 45, 0, 0, time.UTC)
+	exifTime := time.Date(2026, 5, 19, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		submission     models.Submission
+		wantTime       time.Time
+		wantTimeSource string
+		wantLocation   *CaptureLocation
+		wantLocSource  string
+	}{
+		{
+			name:           "nothing known",
+			submission:     models.Submission{},
+			wantTime:       uploaded,
+			wantTimeSource: CaptureSourceUpload,
+		},
+		{
+			name: "exif only",
+			submission: models.Submission{
+				ExifOptIn: true, ExifCapturedAt: &exifTime,
+				ExifLatitude: floatPtr(45.52), ExifLongitude: floatPtr(-122.68),
+			},
+			wantTime:       exifTime,
+			wantTimeSource: CaptureSourceExif,
+			wantLocation:   &CaptureLocation{Latitude: 45.52, Longitude: -122.68},
+			wantLocSource:  CaptureSourceExif,
+		},
+		{
+			name: "client and exif disagree",
+			submission: models.Submission{
+				CapturedAt: &clientTime, CaptureLatitude: floatPtr(37.77), CaptureLongitude: floatPtr(-122.42),
+				ExifOptIn: true, ExifCapturedAt: &exifTime,
+				ExifLatitude: floatPtr(45.52), ExifLongitude: floatPtr(-122.68),
+			},
+			wantTime:       clientTime,
+			wantTimeSource: CaptureSourceClient,
+			wantLocation:   &CaptureLocation{Latitude: 37.77, Longitude: -122.42},
+			wantLocSource:  CaptureSourceClient,
+		},
+		{
+			name: "client time, exif location",
+			submission: models.Submission{
+				CapturedAt: &clientTime,
+				ExifOptIn:  true, ExifCapturedAt: &exifTime,
+				ExifLatitude: floatPtr(45.52), ExifLongitude: floatPtr(-122.68),
+			},
+			wantTime:       clientTime,
+			wantTimeSource: CaptureSourceClient,
+			wantLocation:   &CaptureLocation{Latitude: 45.52, Longitude: -122.68},
+			wantLocSource:  CaptureSourceExif,
+		},
+		{
+			name: "exif location without opt-in",
+			submission: models.Submission{
+				ExifCapturedAt: &exifTime, ExifLatitude: floatPtr(45.52), ExifLongitude: floatPtr(-122.68),
+			},
+			wantTime:       exifTime,
+			wantTimeSource: CaptureSourceExif,
+		},
+		{
+			name: "half a client location",
+			submission: models.Submission{
+				CaptureLatitude: floatPtr(37.77),
+				ExifOptIn:       true, ExifLatitude: floatPtr(45.52), ExifLongitude: floatPtr(-122.68),
+			},
+			wantTime:       uploaded,
+			wantTimeSource: CaptureSourceUpload,
+			wantLocation:   &CaptureLocation{Latitude: 45.52, Longitude: -122.68},
+			wantLocSource:  CaptureSourceExif,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.submission.CreatedAt = uploaded
+			got := SubmissionCapture(&tt.submission)
+			if !got.CapturedAt.Equal(tt.wantTime) || got.TimeSource != tt.wantTimeSource {
+				t.Errorf("capture time = %s (%s), want %s (%s)", got.CapturedAt, got.TimeSource, tt.wantTime, tt.wantTimeSource)
+			}
+			switch {
+			case tt.wantLocation == nil && got.Location != nil:
+				t.Errorf("location = %+v (%s), want none", *got.Location, got.LocationSource)
+			case tt.wantLocation != nil && (got.Location == nil || *got.Location != *tt.wantLocation):
+				t.Errorf("location = %v, want %+v", got.Location, *tt.wantLocation)
+			}
+			if got.LocationSource != tt.wantLocSource {
+				t.Errorf("location source = %q, want %q", got.LocationSource, tt.wantLocSource)
+			}
+		})
+	}
+}
+
+func TestValidateCaptureMetadata(t *testing.T) {
+	now := time.Date(2026, 5, 20, 18, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		meta    CaptureMetadata
+		wantErr bool
+	}{
+		{"empty", CaptureMetadata{}, false},
+		{"all fields", CaptureMetadata{CapturedAt: at(-time.Hour), Latitude: floatPtr(45.5), Longitude: floatPtr(-122.6), DeviceOrientation: strPtr("portrait")}, false},
+		{"small clock skew", CaptureMetadata{CapturedAt: at(5 * time.Minute)}, false},
+		{"future", CaptureMetadata{CapturedAt: at(time.Hour)}, true},
+		{"over a year old", CaptureMetadata{CapturedAt: at(-400 * 24 * time.Hour)}, true},
+		{"latitude only", CaptureMetadata{Latitude: floatPtr(45.5)}, true},
+		{"out of range", CaptureMetadata{Latitude: floatPtr(91), Longitude: floatPtr(0)}, true},
+		{"unknown orientation", CaptureMetadata{DeviceOrientation: strPtr("sideways")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCaptureMetadata(tt.meta, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateCaptureMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidCaptureMetadata) {
+				t.Errorf("error = %v, want ErrInvalidCaptureMetadata", err)
+			}
+		})
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	portland := CaptureLocation{Latitude: 45.5152, Longitude: -122.6784}
+	tests := []struct {
+		name     string
+		lat, lng float64
+		want     float64
+	}{
+		{"same point", 45.5152, -122.6784, 0},
+		{"seattle", 47.6062, -122.3321, 234},
+		{"san francisco", 37.7749, -122.4194, 861},
+	}
+
+	for _, tt := range tests {
+		if got := DistanceKm(portland, tt.lat, tt.lng); math.Abs(got-tt.want) > 2 {
+			t.Errorf("%s: DistanceKm() = %.1f, want about %.0f", tt.name, got, tt.want)
+		}
+	}
+}
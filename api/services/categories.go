@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	config_pkg "github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// ReviewReasonOutsidePublishWindow marks candidates starting too soon or too far ahead to auto-publish
+const ReviewReasonOutsidePublishWindow = "outside_publish_window"
+
+// Where a publish policy's threshold came from
+const (
+	PolicySourceDefault  = "default"
+	PolicySourceCategory = "category"
+)
+
+// categoryAliases folds the variants the vision model produces onto one category name
+var categoryAliases = map[string]string{
+	"class":               "classes",
+	"workshop":            "classes",
+	"workshops":           "classes",
+	"classes/workshops":   "classes",
+	"classes & workshops": "classes",
+	"education":           "classes",
+	"service":             "services",
+	"sale":                "sales",
+	"for sale":            "sales",
+	"yard sale":           "sales",
+	"garage sale":         "sales",
+	"concert":             "music",
+	"live music":          "music",
+	"art":                 "arts",
+	"sport":               "sports",
+}
+
+// ErrInvalidCategorySetting is returned for out-of-range category overrides
+var ErrInvalidCategorySetting = errors.New("invalid category setting")
+
+// NormalizeCategory lowercases and trims an extracted category and folds known
+// variants together, so "Workshops" and "Classes/Workshops" share one override.
+// Returns "" for no category.
+func NormalizeCategory(raw string) string {
+	category := strings.Join(strings.Fields(strings.ToLower(raw)), " ")
+	if alias, ok := categoryAliases[category]; ok {
+		return alias
+	}
+	return category
+}
+
+// CandidateCategory returns the normalized category of a candidate's fields
+func CandidateCategory(fields map[string]interface{}) string {
+	category, _ := fields["category"].(string)
+	return NormalizeCategory(category)
+}
+
+// PublishPolicy is the auto-publish rule set that applies to one category
+type PublishPolicy struct {
+	Category           string  `json:"category"`
+	Threshold          float64 `json:"auto_publish_threshold"`
+	Source             string  `json:"source"` // default, or category when an override set the threshold
+	MinStartOffsetMin  int     `json:"min_start_offset_min"`
+	MaxStartOffsetDays int     `json:"max_start_offset_days"` // 0 for no upper bound
+}
+
+// DefaultPublishPolicy is the global policy from AUTO_PUBLISH_* settings. The threshold
+// comes from the auto_publish_threshold runtime setting when one is stored.
+func DefaultPublishPolicy(db *gorm.DB, cfg *config_pkg.Config) PublishPolicy {
+	return PublishPolicy{
+		Threshold:          SettingAutoPublishThreshold.Get(db, cfg),
+		Source:             PolicySourceDefault,
+		MinStartOffsetMin:  cfg.AutoPublishMinStartOffsetMin,
+		MaxStartOffsetDays: cfg.AutoPublishMaxStartOffsetDays,
+	}
+}
+
+// apply layers a category override onto the policy; nil fields keep the current values
+func (p PublishPolicy) apply(setting *models.CategoryPublishSetting) PublishPolicy {
+	p.Category = setting.Category
+	if setting.AutoPublishThreshold != nil {
+		p.Threshold = *setting.AutoPublishThreshold
+		p.Source = PolicySourceCategory
+	}
+	if setting.MinStartOffsetMin != nil {
+		p.MinStartOffsetMin = *setting.MinStartOffsetMin
+	}
+	if setting.MaxStartOffsetDays != nil {
+		p.MaxStartOffsetDays = *setting.MaxStartOffsetDays
+	}
+	return p
+}
+
+// InWindow reports whether an event starting at start may be auto-published at now
+func (p PublishPolicy) InWindow(start, now time.Time) bool {
+	if start.Before(now.Add(time.Duration(p.MinStartOffsetMin) * time.Minute)) {
+		return false
+	}
+	return p.MaxStartOffsetDays <= 0 || !start.After(now.AddDate(0, 0, p.MaxStartOffsetDays))
+}
+
+// ResolvePublishPolicy returns the policy for a normalized category. Categories without
+// an override, and candidates without a category, use the global default.
+func ResolvePublishPolicy(db *gorm.DB, cfg *config_pkg.Config, category string) (PublishPolicy, error) {
+	policy := DefaultPublishPolicy(db, cfg)
+	policy.Category = category
+	if category == "" {
+		return policy, nil
+	}
+
+	var setting models.CategoryPublishSetting
+	if err := db.Where("category = ?", category).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return policy, nil
+		}
+		return policy, fmt.Errorf("failed to load category setting: %w", err)
+	}
+	return policy.apply(&setting), nil
+}
+
+// ListCategorySettings returns every category override, by category
+func ListCategorySettings(db *gorm.DB) ([]models.CategoryPublishSetting, error) {
+	var settings []models.CategoryPublishSetting
+	if err := db.Order("category ASC").Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to list category settings: %w", err)
+	}
+	return settings, nil
+}
+
+// validateCategorySetting checks override ranges
+func validateCategorySetting(setting *models.CategoryPublishSetting) error {
+	if setting.Category == "" {
+		return fmt.Errorf("%w: category is required", ErrInvalidCategorySetting)
+	}
+	if t := setting.AutoPublishThreshold; t != nil && (*t < 0 || *t > 1) {
+		return fmt.Errorf("%w: auto_publish_threshold must be between 0 and 1", ErrInvalidCategorySetting)
+	}
+	if m := setting.MinStartOffsetMin; m != nil && *m < 0 {
+		return fmt.Errorf("%w: min_start_offset_min must not be negative", ErrInvalidCategorySetting)
+	}
+	if d := setting.MaxStartOffsetDays; d != nil && *d < 0 {
+		return fmt.Errorf("%w: max_start_offset_days must not be negative", ErrInvalidCategorySetting)
+	}
+	return nil
+}
+
+// SaveCategorySetting creates or replaces the override for setting.Category, which is
+// normalized first. The change is audited with the previous values.
+func SaveCategorySetting(tx *gorm.DB, setting models.CategoryPublishSetting, actor Actor) (*models.CategoryPublishSetting, error) {
+	setting.Category = NormalizeCategory(setting.Category)
+	if err := validateCategorySetting(&setting); err != nil {
+		return nil, err
+	}
+
+	var existing models.CategoryPublishSetting
+	err := tx.Where("category = ?", setting.Category).First(&existing).Error
+	switch {
+	case err == nil:
+		setting.ID = existing.ID
+		setting.CreatedAt = existing.CreatedAt
+		setting.UpdatedAt = time.Now()
+		if err := tx.Save(&setting).Error; err != nil {
+			return nil, fmt.Errorf("failed to update category setting: %w", err)
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Create(&setting).Error; err != nil {
+			return nil, fmt.Errorf("failed to create category setting: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to load category setting: %w", err)
+	}
+
+	if err := RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntitySetting,
+		EntityID:   setting.ID,
+		Action:     AuditActionSettingUpdated,
+		Actor:      actor,
+		Changes: map[string]interface{}{
+			"auto_publish_threshold": map[string]interface{}{"from": existing.AutoPublishThreshold, "to": setting.AutoPublishThreshold},
+			"min_start_offset_min":   map[string]interface{}{"from": existing.MinStartOffsetMin, "to": setting.MinStartOffsetMin},
+			"max_start_offset_days":  map[string]interface{}{"from": existing.MaxStartOffsetDays, "to": setting.MaxStartOffsetDays},
+		},
+		Metadata: map[string]interface{}{"category": setting.Category},
+	}); err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// DeleteCategorySetting removes a category's override so it uses the global default again
+func DeleteCategorySetting(tx *gorm.DB, category string, actor Actor) error {
+	var setting models.CategoryPublishSetting
+	if err := tx.Where("category = ?", NormalizeCategory(category)).First(&setting).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&setting).Error; err != nil {
+		return fmt.Errorf("failed to delete category setting: %w", err)
+	}
+	return RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntitySetting,
+		EntityID:   setting.ID,
+		Action:     AuditActionSettingDeleted,
+		Actor:      actor,
+		Metadata:   map[string]interface{}{"category": setting.Category},
+	})
+}
+
+// CategoryPublishRate summarizes automated decisions for one normalized category
+type CategoryPublishRate struct {
+	Category    string        `json:"category"` // "" for candidates without a category
+	Candidates  int64         `json:"candidates"`
+	Published   int64         `json:"published"`
+	NeedsReview int64         `json:"needs_review"`
+	Blocked     int64         `json:"blocked"`
+	PublishRate float64       `json:"publish_rate"`
+	Policy      PublishPolicy `json:"policy"`
+}
+
+// categoryDecisionRow is the scan target for the per-category decision counts
+type categoryDecisionRow struct {
+	Category      string
+	PublishResult string
+	Count         int64
+}
+
+// ComputeCategoryPublishRates breaks down decisions on candidates created within the
+// window by normalized category, busiest first, with the policy each category uses now.
+// Categories are normalized here rather than in SQL so the aliases stay in one place.
+func ComputeCategoryPublishRates(db *gorm.DB, cfg *config_pkg.Config, window string, now time.Time) ([]CategoryPublishRate, error) {
+	windowDuration, ok := ReviewLatencyWindows[window]
+	if !ok {
+		return nil, fmt.Errorf("unsupported window: %s", window)
+	}
+
+	var rows []categoryDecisionRow
+	if err := db.Table("event_candidates").
+		Select("COALESCE(fields->>'category', '') AS category, publish_result, COUNT(*) AS count").
+		Where("created_at >= ? AND publish_result IS NOT NULL", now.Add(-windowDuration)).
+		Group("1, 2").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count category decisions: %w", err)
+	}
+
+	settings, err := ListCategorySettings(db)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateCategoryRates(rows, settings, DefaultPublishPolicy(db, cfg)), nil
+}
+
+// aggregateCategoryRates folds raw category rows onto normalized categories and attaches
+// each category's current policy
+func aggregateCategoryRates(rows []categoryDecisionRow, settings []models.CategoryPublishSetting, defaults PublishPolicy) []CategoryPublishRate {
+	overrides := make(map[string]*models.CategoryPublishSetting, len(settings))
+	for i := range settings {
+		overrides[settings[i].Category] = &settings[i]
+	}
+
+	byCategory := make(map[string]*CategoryPublishRate)
+	for _, row := range rows {
+		category := NormalizeCategory(row.Category)
+		rate, ok := byCategory[category]
+		if !ok {
+			policy := defaults
+			policy.Category = category
+			if setting, ok := overrides[category]; ok {
+				policy = policy.apply(setting)
+			}
+			rate = &CategoryPublishRate{Category: category, Policy: policy}
+			byCategory[category] = rate
+		}
+		rate.Candidates += row.Count
+		switch row.PublishResult {
+		case "published":
+			rate.Published += row.Count
+		case "needs_review":
+			rate.NeedsReview += row.Count
+		case "blocked":
+			rate.Blocked += row.Count
+		}
+	}
+
+	rates := make([]CategoryPublishRate, 0, len(byCategory))
+	for _, rate := range byCategory {
+		if rate.Candidates > 0 {
+			rate.PublishRate = float64(rate.Published) / float64(rate.Candidates)
+		}
+		rates = append(rates, *rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Candidates != rates[j].Candidates {
+			return rates[i].Candidates > rates[j].Candidates
+		}
+		return rates[i].Category < rates[j].Category
+	})
+	return rates
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+// categoryConfig has the global auto-publish policy every test starts from
+var categoryConfig = &config.Config{
+	AutoPublishThreshold:          0.8,
+	AutoPublishMinStartOffsetMin:  60,
+	AutoPublishMaxStartOffsetDays: 90,
+}
+
+// expectNoStoredThreshold expects the runtime settings lookup to find no stored
+// auto_publish_threshold, so the config value applies
+func expectNoStoredThreshold(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT * FROM "settings" WHERE key = $1`).
+		WithArgs("auto_publish_threshold", testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}))
+}
+
+func TestNormalizeCategory(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"Music", "music"},
+		{"  Live   Music ", "music"},
+		{"Classes/Workshops", "classes"},
+		{"WORKSHOP", "classes"},
+		{"Garage Sale", "sales"},
+		{"services", "services"},
+		{"Service", "services"},
+		{"Comedy", "comedy"},
+		{"food  and drink", "food and drink"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeCategory(tt.raw); got != tt.want {
+			t.Errorf("NormalizeCategory(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePublishPolicy(t *testing.T) {
+	threshold, minOffset, maxDays := 0.95, 240, 14
+
+	tests := []struct {
+		name     string
+		category string
+		rows     *sqlmock.Rows // the override lookup, nil when none is made
+		dbErr    error
+		want     PublishPolicy
+		wantErr  bool
+	}{
+		{
+			name: "no category uses the default",
+			want: PublishPolicy{Threshold: 0.8, Source: PolicySourceDefault, MinStartOffsetMin: 60, MaxStartOffsetDays: 90},
+		},
+		{
+			name:     "category without an override falls back",
+			category: "comedy",
+			rows:     sqlmock.NewRows([]string{"id"}),
+			want:     PublishPolicy{Category: "comedy", Threshold: 0.8, Source: PolicySourceDefault, MinStartOffsetMin: 60, MaxStartOffsetDays: 90},
+		},
+		{
+			name:     "threshold override",
+			category: "services",
+			rows: sqlmock.NewRows([]string{"id", "category", "auto_publish_threshold"}).
+				AddRow(uuid.New().String(), "services", threshold),
+			want: PublishPolicy{Category: "services", Threshold: 0.95, Source: PolicySourceCategory, MinStartOffsetMin: 60, MaxStartOffsetDays: 90},
+		},
+		{
+			name:     "window override keeps the default threshold",
+			category: "sales",
+			rows: sqlmock.NewRows([]string{"id", "category", "min_start_offset_min", "max_start_offset_days"}).
+				AddRow(uuid.New().String(), "sales", minOffset, maxDays),
+			want: PublishPolicy{Category: "sales", Threshold: 0.8, Source: PolicySourceDefault, MinStartOffsetMin: 240, MaxStartOffsetDays: 14},
+		},
+		{
+			name:     "lookup failure",
+			category: "classes",
+			dbErr:    errors.New("connection reset"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			expectNoStoredThreshold(mock)
+			switch {
+			case tt.dbErr != nil:
+				mock.ExpectQuery(`FROM "category_publish_settings" WHERE category = $1`).WillReturnError(tt.dbErr)
+			case tt.rows != nil:
+				mock.ExpectQuery(`FROM "category_publish_settings" WHERE category = $1`).
+					WithArgs(tt.category, testdb.Any).
+					WillReturnRows(tt.rows)
+			}
+
+			got, err := ResolvePublishPolicy(db, categoryConfig, tt.category)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ResolvePublishPolicy() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolvePublishPolicy() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolvePublishPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishPolicyInWindow(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	policy := PublishPolicy{MinStartOffsetMin: 60, MaxStartOffsetDays: 14}
+	unbounded := PublishPolicy{MinStartOffsetMin: 60}
+
+	tests := []struct {
+		name   string
+		policy PublishPolicy
+		start  time.Time
+		want   bool
+	}{
+		{"already started", policy, now.Add(-time.Hour), false},
+		{"too soon", policy, now.Add(59 * time.Minute), false},
+		{"exactly the minimum offset", policy, now.Add(time.Hour), true},
+		{"next week", policy, now.AddDate(0, 0, 7), true},
+		{"last day of the window", policy, now.AddDate(0, 0, 14), true},
+		{"past the window", policy, now.AddDate(0, 0, 14).Add(time.Minute), false},
+		{"no upper bound", unbounded, now.AddDate(1, 0, 0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.InWindow(tt.start, now); got != tt.want {
+				t.Errorf("InWindow(%v) = %v, want %v", tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeCategoryPublishRates(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	threshold := 0.95
+	db, mock := testdb.New(t)
+
+	// Raw categories as the model wrote them; variants fold onto one row each
+	mock.ExpectQuery(`SELECT COALESCE(fields->>'category', '') AS category, publish_result, COUNT(*) AS count FROM "event_candidates" ` +
+		`WHERE created_at >= $1 AND publish_result IS NOT NULL GROUP BY 1, 2`).
+		WithArgs(now.Add(-7 * 24 * time.Hour)).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "publish_result", "count"}).
+			AddRow("Workshops", "published", 6).
+			AddRow("Classes/Workshops", "published", 3).
+			AddRow("classes", "needs_review", 1).
+			AddRow("Services", "needs_review", 7).
+			AddRow("service", "blocked", 3).
+			AddRow("", "published", 1).
+			AddRow("", "needs_review", 1).
+			AddRow("Comedy", "published", 2))
+	mock.ExpectQuery(`SELECT * FROM "category_publish_settings" ORDER BY category ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category", "auto_publish_threshold"}).
+			AddRow(uuid.New().String(), "services", threshold))
+	expectNoStoredThreshold(mock)
+
+	got, err := ComputeCategoryPublishRates(db, categoryConfig, "7d", now)
+	if err != nil {
+		t.Fatalf("ComputeCategoryPublishRates() error = %v", err)
+	}
+
+	defaults := PublishPolicy{Threshold: 0.8, Source: PolicySourceDefault, MinStartOffsetMin: 60, MaxStartOffsetDays: 90}
+	policyFor := func(category string) PublishPolicy {
+		policy := defaults
+		policy.Category = category
+		return policy
+	}
+	servicesPolicy := policyFor("services")
+	servicesPolicy.Threshold, servicesPolicy.Source = 0.95, PolicySourceCategory
+
+	want := []CategoryPublishRate{
+		{Category: "classes", Candidates: 10, Published: 9, NeedsReview: 1, PublishRate: 0.9, Policy: policyFor("classes")},
+		{Category: "services", Candidates: 10, NeedsReview: 7, Blocked: 3, PublishRate: 0, Policy: servicesPolicy},
+		{Category: "", Candidates: 2, Published: 1, NeedsReview: 1, PublishRate: 0.5, Policy: policyFor("")},
+		{Category: "comedy", Candidates: 2, Published: 2, PublishRate: 1, Policy: policyFor("comedy")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputeCategoryPublishRates() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if _, err := ComputeCategoryPublishRates(db, categoryConfig, "1y", now); err == nil {
+		t.Error("ComputeCategoryPublishRates() accepted an unsupported window")
+	}
+}
+
+func TestValidateCategorySetting(t *testing.T) {
+	negative, tooHigh, ok := -1, 1.5, 0.9
+	negativeThreshold := -0.1
+
+	tests := []struct {
+		name    string
+		setting models.CategoryPublishSetting
+		wantErr bool
+	}{
+		{"threshold only", models.CategoryPublishSetting{Category: "sales", AutoPublishThreshold: &ok}, false},
+		{"no overrides", models.CategoryPublishSetting{Category: "sales"}, false},
+		{"missing category", models.CategoryPublishSetting{AutoPublishThreshold: &ok}, true},
+		{"threshold above one", models.CategoryPublishSetting{Category: "sales", AutoPublishThreshold: &tooHigh}, true},
+		{"threshold below zero", models.CategoryPublishSetting{Category: "sales", AutoPublishThreshold: &negativeThreshold}, true},
+		{"negative minimum offset", models.CategoryPublishSetting{Category: "sales", MinStartOffsetMin: &negative}, true},
+		{"negative window", models.CategoryPublishSetting{Category: "sales", MaxStartOffsetDays: &negative}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCategorySetting(&tt.setting)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidCategorySetting)) {
+				t.Errorf("validateCategorySetting() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
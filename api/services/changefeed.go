@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Change feed entry types
+const (
+	ChangeTypeCreated = "created"
+	ChangeTypeUpdated = "updated"
+	ChangeTypeDeleted = "deleted"
+)
+
+// Change feed reasons
+const (
+	ChangeReasonVenueMerged   = "venue_merged"
+	ChangeReasonVenueDeleted  = "venue_deleted"
+	ChangeReasonVenueOrphaned = "venue_orphaned"
+	ChangeReasonVenueLinked   = "venue_linked"
+	ChangeReasonUnpublished   = "unpublished"
+	ChangeReasonRepublished   = "republished"
+	ChangeReasonDisputed      = "disputed"
+	ChangeReasonFlagged       = "flagged"
+	ChangeReasonEdited        = "edited"
+	ChangeReasonRemoved       = "removed"
+	ChangeReasonMerged        = "merged"
+)
+
+// MaxChangeFeedPage bounds a single GET /v1/events/changes response
+const MaxChangeFeedPage = 500
+
+// RecordEventChange queues a change feed entry (and any webhook or notification for it)
+// in the outbox, using the given db or transaction
+func RecordEventChange(db *gorm.DB, eventID uuid.UUID, changeType, reason string) error {
+	return EnqueueOutbox(db, OutboxTopicEventChanged, eventID, EventChangePayload{
+		EventID:    eventID,
+		ChangeType: changeType,
+		Reason:     reason,
+	})
+}
+
+// RecordEventChanges queues one entry per event, all with the same type and reason
+func RecordEventChanges(db *gorm.DB, eventIDs []uuid.UUID, changeType, reason string) error {
+	for _, eventID := range eventIDs {
+		if err := RecordEventChange(db, eventID, changeType, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListEventChanges returns entries with seq greater than since, oldest first
+func ListEventChanges(db *gorm.DB, since int64, limit int) ([]models.EventChange, error) {
+	if limit <= 0 || limit > MaxChangeFeedPage {
+		limit = MaxChangeFeedPage
+	}
+
+	var changes []models.EventChange
+	if err := db.Where("seq > ?", since).Order("seq ASC").Limit(limit).Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list event changes: %w", err)
+	}
+	return changes, nil
+}
+
+// ChangeFeedSink is the outbox sink that sequences event changes onto the change feed.
+// Seq is assigned at delivery, and each outbox entry appends at most one feed entry.
+type ChangeFeedSink struct {
+	db *gorm.DB
+}
+
+func NewChangeFeedSink(db *gorm.DB) *ChangeFeedSink {
+	return &ChangeFeedSink{db: db}
+}
+
+func (s *ChangeFeedSink) Name() string {
+	return "changefeed"
+}
+
+func (s *ChangeFeedSink) Handles(topic string) bool {
+	return topic == OutboxTopicEventChanged
+}
+
+// Deliver appends the feed entry, ignoring a repeat delivery of the same outbox entry
+func (s *ChangeFeedSink) Deliver(ctx context.Context, entry *models.OutboxEntry) error {
+	var payload EventChangePayload
+	if err := json.Unmarshal([]byte(entry.Payload), &payload); err != nil {
+		return fmt.Errorf("failed to parse event change payload: %w", err)
+	}
+
+	change := models.EventChange{
+		EventID:    payload.EventID,
+		ChangeType: payload.ChangeType,
+		OutboxID:   &entry.ID,
+		CreatedAt:  entry.CreatedAt,
+	}
+	if payload.Reason != "" {
+		change.Reason = &payload.Reason
+	}
+
+	if err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "outbox_id"}}, DoNothing: true}).
+		Create(&change).Error; err != nil {
+		return fmt.Errorf("failed to record event change: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrClustersNotReady is returned when no cluster set has been built yet for a window and zoom
+var ErrClustersNotReady = errors.New("event clusters not computed yet")
+
+const (
+	// clusterCellPixels is the grid cell size on screen; events closer than this merge
+	clusterCellPixels = 64
+	// maxClusterEventIDs is the largest cluster whose event IDs are listed
+	maxClusterEventIDs = 10
+	// clusterRefreshDebounce lets a burst of publishes settle into one recompute
+	clusterRefreshDebounce = 2 * time.Second
+	// webMercatorWorldMeters is the width of the EPSG:3857 world
+	webMercatorWorldMeters = 40075016.68557849
+)
+
+// EventCluster is one grid cell of upcoming events on the homepage map, placed at the
+// centroid of its venues
+type EventCluster struct {
+	Latitude  float64     `json:"lat"`
+	Longitude float64     `json:"lng"`
+	Count     int         `json:"count"`
+	EventIDs  []uuid.UUID `json:"event_ids,omitempty"` // only for clusters of up to 10 events
+}
+
+// eventClusterSQL groups approved events at geocoded venues overlapping [start, end) into
+// square Web Mercator cells of @cell meters, matching what the event tiles show
+var eventClusterSQL = `
+WITH points AS (
+	SELECT e.id, e.start_ts, ST_Transform(v.location, 3857) AS geom
+	FROM events e
+	JOIN venues v ON v.id = e.venue_id
+	WHERE e.moderation_state = 'approved'
+		AND v.location IS NOT NULL
+		AND ` + EventOverlapSQL("e") + `
+)
+SELECT
+	COUNT(*) AS count,
+	ST_Y(ST_Transform(ST_Centroid(ST_Collect(geom)), 4326)) AS latitude,
+	ST_X(ST_Transform(ST_Centroid(ST_Collect(geom)), 4326)) AS longitude,
+	CASE WHEN COUNT(*) <= @max_ids THEN string_agg(id::text, ',' ORDER BY start_ts, id) END AS event_ids
+FROM points
+GROUP BY ST_SnapToGrid(geom, @cell)
+ORDER BY count DESC, latitude, longitude`
+
+// clusterCellMeters is the Web Mercator size of a clusterCellPixels cell at zoom
+func clusterCellMeters(zoom int) float64 {
+	return webMercatorWorldMeters / (256 * math.Pow(2, float64(zoom))) * clusterCellPixels
+}
+
+// ComputeEventClusters clusters approved events overlapping [start, end) for one zoom level
+func ComputeEventClusters(db *gorm.DB, zoom int, start, end time.Time) ([]EventCluster, error) {
+	var rows []struct {
+		Count     int
+		Latitude  float64
+		Longitude float64
+		EventIDs  *string
+	}
+	err := db.Raw(eventClusterSQL, map[string]interface{}{
+		"start":            start,
+		"end":              end,
+		"default_duration": DefaultEventDurationSeconds(),
+		"cell":             clusterCellMeters(zoom),
+		"max_ids":          maxClusterEventIDs,
+	}).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to cluster events at zoom %d: %w", zoom, err)
+	}
+
+	clusters := make([]EventCluster, 0, len(rows))
+	for _, row := range rows {
+		cluster := EventCluster{Latitude: row.Latitude, Longitude: row.Longitude, Count: row.Count}
+		if row.EventIDs != nil {
+			for _, raw := range strings.Split(*row.EventIDs, ",") {
+				if id, err := uuid.Parse(raw); err == nil {
+					cluster.EventIDs = append(cluster.EventIDs, id)
+				}
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// RefreshEventClusters rebuilds the cluster sets for a window of days from now at every zoom
+// level and stores them. Each set records the change feed seq it was built at, and all of
+// them record how long the whole refresh took.
+func RefreshEventClusters(db *gorm.DB, days int, zooms []int, now time.Time) (time.Duration, error) {
+	began := time.Now()
+	version, err := EventDataVersion(db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read event data version: %w", err)
+	}
+
+	start := now.UTC().Truncate(time.Minute)
+	end := start.AddDate(0, 0, days)
+	sets := make([]models.EventClusterSet, 0, len(zooms))
+	for _, zoom := range zooms {
+		clusters, err := ComputeEventClusters(db, zoom, start, end)
+		if err != nil {
+			return 0, err
+		}
+		clustersJSON, err := json.Marshal(clusters)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal clusters: %w", err)
+		}
+		count := 0
+		for _, cluster := range clusters {
+			count += cluster.Count
+		}
+		sets = append(sets, models.EventClusterSet{
+			Days:        days,
+			Zoom:        zoom,
+			Clusters:    string(clustersJSON),
+			EventCount:  count,
+			DataVersion: version,
+			WindowStart: start,
+			WindowEnd:   end,
+		})
+	}
+	if len(sets) == 0 {
+		return 0, nil
+	}
+
+	elapsed := time.Since(began)
+	computedAt := time.Now().UTC()
+	for i := range sets {
+		sets[i].ComputedAt = computedAt
+		sets[i].ComputeMS = int(elapsed.Milliseconds())
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "days"}, {Name: "zoom"}},
+		DoUpdates: clause.AssignmentColumns([]string{"clusters", "event_count", "data_version", "window_start", "window_end", "computed_at", "compute_ms"}),
+	}).Create(&sets).Error; err != nil {
+		return 0, fmt.Errorf("failed to store event clusters: %w", err)
+	}
+	return elapsed, nil
+}
+
+// GetEventClusterSet returns the stored cluster set for a window and zoom level
+func GetEventClusterSet(db *gorm.DB, days, zoom int) (*models.EventClusterSet, error) {
+	var set models.EventClusterSet
+	if err := db.Where("days = ? AND zoom = ?", days, zoom).Take(&set).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClustersNotReady
+		}
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ClusterRefresher keeps the homepage cluster sets current: on start, on a ticker so the
+// window keeps moving, and shortly after any event change delivered through the outbox
+type ClusterRefresher struct {
+	db       *gorm.DB
+	days     int
+	zooms    []int
+	interval time.Duration
+	trigger  chan struct{}
+}
+
+// NewClusterRefresher builds sets for cfg.ClusterWindowDays at each of cfg.ClusterZoomLevels
+func NewClusterRefresher(db *gorm.DB, cfg *config.Config) *ClusterRefresher {
+	return &ClusterRefresher{
+		db:       db,
+		days:     cfg.ClusterWindowDays,
+		zooms:    cfg.ClusterZoomLevels,
+		interval: time.Duration(cfg.ClusterRefreshIntervalSec) * time.Second,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Start builds the sets straight away, then refreshes them in the background. With no
+// interval only event changes trigger a refresh.
+func (r *ClusterRefresher) Start() {
+	if r.days <= 0 || len(r.zooms) == 0 {
+		log.Println("Cluster refresher disabled")
+		return
+	}
+
+	go func() {
+		var tick <-chan time.Time
+		if r.interval > 0 {
+			ticker := time.NewTicker(r.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		r.RunOnce()
+		for {
+			select {
+			case <-tick:
+			case <-r.trigger:
+				time.Sleep(clusterRefreshDebounce)
+				// Changes that arrived while waiting are covered by this run
+				select {
+				case <-r.trigger:
+				default:
+				}
+			}
+			r.RunOnce()
+		}
+	}()
+}
+
+// Trigger asks for a refresh without waiting for it; requests made before it runs coalesce
+func (r *ClusterRefresher) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// RunOnce rebuilds every cluster set, logging the recompute duration (or the failure)
+func (r *ClusterRefresher) RunOnce() {
+	elapsed, err := RefreshEventClusters(r.db, r.days, r.zooms, time.Now())
+	if err != nil {
+		log.Printf("Cluster refresher: %v", err)
+		return
+	}
+	log.Printf("Cluster refresher: rebuilt %d-day clusters at zooms %v in %s", r.days, r.zooms, elapsed.Round(time.Millisecond))
+}
+
+// ClusterCacheSink is the outbox sink that refreshes the cluster sets after event changes.
+// Delivery only schedules the refresh, so repeated deliveries cost at most one recompute.
+type ClusterCacheSink struct {
+	refresher *ClusterRefresher
+}
+
+func NewClusterCacheSink(refresher *ClusterRefresher) *ClusterCacheSink {
+	return &ClusterCacheSink{refresher: refresher}
+}
+
+func (s *ClusterCacheSink) Name() string {
+	return "clusters"
+}
+
+func (s *ClusterCacheSink) Handles(topic string) bool {
+	return topic == OutboxTopicEventChanged
+}
+
+func (s *ClusterCacheSink) Deliver(ctx context.Context, entry *models.OutboxEntry) error {
+	s.refresher.Trigger()
+	return nil
+}
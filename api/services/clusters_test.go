@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+// expectClusterRefresh expects RefreshEventClusters at one zoom level to read the change
+// feed version, cluster the events into one cell of count, and store the set at version
+func expectClusterRefresh(mock sqlmock.Sqlmock, version, count int64) {
+	mock.ExpectQuery(`SELECT COALESCE(MAX(seq), 0) FROM event_changes`).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(version))
+	mock.ExpectQuery(`GROUP BY ST_SnapToGrid(geom, $`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "latitude", "longitude", "event_ids"}).AddRow(count, 39.78, -89.65, nil))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "event_cluster_sets" ("days","zoom","clusters","event_count","data_version","window_start","window_end","compute_ms","computed_at") ` +
+		`VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) ON CONFLICT ("days","zoom") DO UPDATE SET`).
+		WithArgs(append([]driver.Value{30, 12, testdb.Containing(fmt.Sprintf(`"count":%d`, count)), count, version}, testdb.AnyArgs(4)...)...).
+		WillReturnRows(sqlmock.NewRows([]string{"computed_at"}).AddRow(time.Now()))
+	mock.ExpectCommit()
+}
+
+// withOutboxSink registers sink for the length of the test
+func withOutboxSink(t *testing.T, sink OutboxSink) {
+	t.Helper()
+	outboxSinksMu.Lock()
+	registered := outboxSinks
+	outboxSinks = append(append([]OutboxSink(nil), registered...), sink)
+	outboxSinksMu.Unlock()
+	t.Cleanup(func() {
+		outboxSinksMu.Lock()
+		outboxSinks = registered
+		outboxSinksMu.Unlock()
+	})
+}
+
+// TestClusterCacheRefreshedAfterPublish delivers a publish's change through the outbox and
+// waits for the running refresher to store a set built at the new change feed version
+func TestClusterCacheRefreshedAfterPublish(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out the refresh debounce")
+	}
+	db, mock := testdb.New(t)
+	refresher := &ClusterRefresher{db: db, days: 30, zooms: []int{12}, trigger: make(chan struct{}, 1)}
+	withOutboxSink(t, NewClusterCacheSink(refresher))
+
+	// The set built at startup
+	expectClusterRefresh(mock, 4, 2)
+	refresher.Start()
+	waitForExpectations(t, mock, time.Second)
+
+	// A publish queued a change for the clusters sink; the dispatcher delivers it twice,
+	// as it may after a lost acknowledgement, and the refresher runs once
+	entryID := uuid.New()
+	for attempt := 1; attempt <= 2; attempt++ {
+		expectClaim(mock, sqlmock.NewRows([]string{"id", "sink", "topic", "aggregate_id", "idempotency_key", "payload", "status", "attempts", "next_attempt_at", "created_at"}).
+			AddRow(entryID.String(), "clusters", OutboxTopicEventChanged, uuid.New().String(), "publish-1", `{"change_type":"created"}`, OutboxStatusPending, attempt-1, time.Now(), time.Now()), true)
+		expectOutcome(mock, entryID, attempt, `"delivered_at"=$1,"last_error"=$2,"status"=$3 WHERE`, 1)
+	}
+	expectClusterRefresh(mock, 5, 3)
+
+	dispatcher := testDispatcher(db)
+	dispatcher.RunOnce()
+	dispatcher.RunOnce()
+	waitForExpectations(t, mock, clusterRefreshDebounce+2*time.Second)
+}
+
+// waitForExpectations waits for work done in the background to meet every expectation
+func waitForExpectations(t *testing.T, mock sqlmock.Sqlmock, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		err := mock.ExpectationsWereMet()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out after %v: %v", timeout, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClusterCacheSinkCoalescesTriggers(t *testing.T) {
+	refresher := &ClusterRefresher{trigger: make(chan struct{}, 1)}
+	sink := NewClusterCacheSink(refresher)
+
+	if !sink.Handles(OutboxTopicEventChanged) || sink.Handles(outboxTestTopic) {
+		t.Errorf("clusters sink should handle only %s", OutboxTopicEventChanged)
+	}
+	for i := 0; i < 3; i++ {
+		if err := sink.Deliver(context.Background(), nil); err != nil {
+			t.Fatalf("Deliver() error = %v", err)
+		}
+	}
+	if n := len(refresher.trigger); n != 1 {
+		t.Errorf("%d refreshes pending after three deliveries, want 1", n)
+	}
+}
+
+func TestClusterCellMeters(t *testing.T) {
+	tests := []struct {
+		zoom int
+		want float64
+	}{
+		{0, 10018754.17},
+		{1, 5009377.09},
+		{10, 9783.94},
+		{14, 611.50},
+	}
+
+	for _, tt := range tests {
+		if got := clusterCellMeters(tt.zoom); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("clusterCellMeters(%d) = %.2f, want %.2f", tt.zoom, got, tt.want)
+		}
+	}
+}
+
+func TestComputeEventClusters(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`GROUP BY ST_SnapToGrid(geom, $`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "latitude", "longitude", "event_ids"}).
+			AddRow(14, 39.78, -89.65, nil). // too many events to list
+			AddRow(2, 39.80, -89.60, first.String()+","+second.String()).
+			AddRow(1, 40.11, -88.24, "not-a-uuid"))
+
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	got, err := ComputeEventClusters(db, 12, start, start.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatalf("ComputeEventClusters() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("ComputeEventClusters() = %d clusters, want 3", len(got))
+	}
+	if got[0].Count != 14 || got[0].EventIDs != nil {
+		t.Errorf("large cluster = %+v, want a count without IDs", got[0])
+	}
+	if len(got[1].EventIDs) != 2 || got[1].EventIDs[0] != first || got[1].EventIDs[1] != second {
+		t.Errorf("small cluster IDs = %v, want [%s %s] in order", got[1].EventIDs, first, second)
+	}
+	if got[2].Count != 1 || got[2].EventIDs != nil {
+		t.Errorf("unparseable IDs = %v, want them skipped", got[2].EventIDs)
+	}
+}
+
+func TestGetEventClusterSetNotReady(t *testing.T) {
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`SELECT * FROM "event_cluster_sets" WHERE days = $1 AND zoom = $2`).
+		WithArgs(30, 12, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"days", "zoom"}))
+
+	if _, err := GetEventClusterSet(db, 30, 12); !errors.Is(err, ErrClustersNotReady) {
+		t.Errorf("GetEventClusterSet() error = %v, want ErrClustersNotReady", err)
+	}
+}
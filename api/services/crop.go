@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// minDeskewDegrees is the smallest flyer rotation worth resampling the crop for
+const minDeskewDegrees = 0.5
+
+// CropService cuts detected flyers out of a submission's image as soon as vision results
+// are saved, so crops exist before anyone opens the review queue
+type CropService struct {
+	db      *gorm.DB
+	storage *StorageService
+}
+
+func NewCropService(db *gorm.DB, storage *StorageService) *CropService {
+	return &CropService{db: db, storage: storage}
+}
+
+// CropSubmission crops every detected flyer of a submission and records each crop's admin
+// URL. Polygons are stored in the frame of the upright photo each flyer was found in, so
+// that photo is cropped rather than the downscaled copy sent to the vision model. A flyer
+// that fails to crop is logged and skipped; FlyerCropPath retries it on first use.
+func (s *CropService) CropSubmission(ctx context.Context, submissionID uuid.UUID) error {
+	var flyers []models.Flyer
+	if err := s.db.Where("submission_id = ? AND region_id <> ?", submissionID, ManualFlyerRegionID).
+		Find(&flyers).Error; err != nil {
+		return fmt.Errorf("failed to load flyers: %w", err)
+	}
+	if len(flyers) == 0 {
+		return nil
+	}
+
+	// Each photo is decoded once, however many flyers were found in it
+	originals := map[int]image.Image{}
+	for i := range flyers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		original, ok := originals[flyers[i].ImageIndex]
+		if !ok {
+			var err error
+			original, err = decodeStoredImage(s.storage, submissionID, SubmissionImageFilename(flyers[i].ImageIndex))
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrFlyerCropUnavailable, err)
+			}
+			originals[flyers[i].ImageIndex] = original
+		}
+		if _, err := s.CropFlyer(original, &flyers[i]); err != nil {
+			log.Printf("Failed to crop flyer %s of submission %s: %v", flyers[i].ID, submissionID, err)
+		}
+	}
+	return nil
+}
+
+// CropFlyer writes one flyer's unredacted crop, cut from original, to the submission's
+// private directory and points the flyer's crop_image_url at the admin crop endpoint. The
+// same crop is stored as the submission's crop_<region_id>.jpg for the submitter's status
+// page, which shows it until a redacted copy exists. It returns the crop's local path.
+func (s *CropService) CropFlyer(original image.Image, flyer *models.Flyer) (string, error) {
+	path := s.storage.GetPrivateFilePath(flyer.SubmissionID, cropFilename(flyer.ID))
+	if err := writeJPEG(path, cutFlyerCrop(original, flyer)); err != nil {
+		return "", err
+	}
+
+	regionFilename := RegionCropFilename(flyer)
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read crop: %w", err)
+	}
+	defer file.Close()
+	if err := s.storage.SaveFile(flyer.SubmissionID, regionFilename, file); err != nil {
+		return "", fmt.Errorf("failed to store region crop: %w", err)
+	}
+
+	cropURL := s.storage.GetAdminCropURL(flyer.ID)
+	regionCropURL := s.storage.GetPublicURL(flyer.SubmissionID, regionFilename)
+	if err := s.db.Model(&models.Flyer{}).Where("id = ?", flyer.ID).Updates(map[string]interface{}{
+		"crop_image_url":  cropURL,
+		"region_crop_url": regionCropURL,
+	}).Error; err != nil {
+		return "", fmt.Errorf("failed to record crop: %w", err)
+	}
+	flyer.CropImageURL = &cropURL
+	flyer.RegionCropURL = &regionCropURL
+	return path, nil
+}
+
+// RegionCropFilename is a flyer's crop_<region_id>.jpg. The region ID comes from the model,
+// so only letters, digits, '-' and '_' are kept; flyers from later photos of the submission
+// carry the photo's index, since each photo numbers its regions afresh.
+func RegionCropFilename(flyer *models.Flyer) string {
+	region := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return -1
+	}, flyer.RegionID)
+	if region == "" {
+		region = flyer.ID.String()
+	}
+	if flyer.ImageIndex > 1 {
+		return fmt.Sprintf("crop_%d_%s.jpg", flyer.ImageIndex, region)
+	}
+	return fmt.Sprintf("crop_%s.jpg", region)
+}
+
+// cutFlyerCrop returns the part of original covered by a flyer's polygon. A flyer the model
+// saw as rotated (rotation_deg, clockwise) is turned back upright first, so the crop holds
+// the flyer squarely; a polygon that misses the image yields the whole image.
+func cutFlyerCrop(original image.Image, flyer *models.Flyer) image.Image {
+	var points []Point
+	json.Unmarshal([]byte(flyer.Polygon), &points)
+
+	bounds := polygonBounds(flyer.Polygon).Intersect(original.Bounds())
+	if bounds.Empty() {
+		bounds = original.Bounds()
+	}
+	if flyer.RotationDeg == nil || math.Abs(*flyer.RotationDeg) < minDeskewDegrees || len(points) == 0 {
+		crop := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(crop, crop.Bounds(), original, bounds.Min, draw.Src)
+		return crop
+	}
+	return deskewCrop(original, points, *flyer.RotationDeg)
+}
+
+// deskewCrop rotates the polygon counter-clockwise by degrees about its centre and samples
+// the upright bounding box of the result from original, bilinearly. Pixels that fall outside
+// original are left white.
+func deskewCrop(original image.Image, points []Point, degrees float64) image.Image {
+	var cx, cy float64
+	for _, p := range points {
+		cx += p.X
+		cy += p.Y
+	}
+	cx /= float64(len(points))
+	cy /= float64(len(points))
+
+	theta := -degrees * math.Pi / 180
+	sin, cos := math.Sin(theta), math.Cos(theta)
+
+	// Bounding box of the polygon once it is upright, relative to the centre
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		dx, dy := p.X-cx, p.Y-cy
+		x, y := dx*cos-dy*sin, dx*sin+dy*cos
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	w, h := int(math.Ceil(maxX-minX)), int(math.Ceil(maxY-minY))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	crop := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(crop, crop.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	src := original.Bounds()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Undo the rotation to find where this output pixel comes from
+			ux, uy := minX+float64(x)+0.5, minY+float64(y)+0.5
+			sx := cx + ux*cos + uy*sin - 0.5
+			sy := cy - ux*sin + uy*cos - 0.5
+			if sx < float64(src.Min.X) || sy < float64(src.Min.Y) || sx > float64(src.Max.X-1) || sy > float64(src.Max.Y-1) {
+				continue
+			}
+			crop.Set(x, y, bilinear(original, sx, sy))
+		}
+	}
+	return crop
+}
+
+// bilinear samples img at a fractional position inside its bounds
+func bilinear(img image.Image, x, y float64) color.RGBA64 {
+	bounds := img.Bounds()
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	x1, y1 := x0+1, y0+1
+	if x1 >= bounds.Max.X {
+		x1 = x0
+	}
+	if y1 >= bounds.Max.Y {
+		y1 = y0
+	}
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	var out [4]float64
+	for _, corner := range []struct {
+		x, y   int
+		weight float64
+	}{
+		{x0, y0, (1 - fx) * (1 - fy)},
+		{x1, y0, fx * (1 - fy)},
+		{x0, y1, (1 - fx) * fy},
+		{x1, y1, fx * fy},
+	} {
+		r, g, b, a := img.At(corner.x, corner.y).RGBA()
+		out[0] += float64(r) * corner.weight
+		out[1] += float64(g) * corner.weight
+		out[2] += float64(b) * corner.weight
+		out[3] += float64(a) * corner.weight
+	}
+	return color.RGBA64{R: uint16(out[0]), G: uint16(out[1]), B: uint16(out[2]), A: uint16(out[3])}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dbPingTimeout bounds one health ping, so a hung connection reads as down
+const dbPingTimeout = 2 * time.Second
+
+// DBHealth pings the database on a ticker and remembers whether the last ping succeeded,
+// so requests can tell the database is down without waiting on it themselves
+type DBHealth struct {
+	db       *gorm.DB
+	interval time.Duration
+
+	mu      sync.RWMutex
+	healthy bool
+	since   time.Time
+}
+
+// NewDBHealth starts out healthy: the server only starts after connecting and migrating
+func NewDBHealth(db *gorm.DB, interval time.Duration) *DBHealth {
+	return &DBHealth{
+		db:       db,
+		interval: interval,
+		healthy:  true,
+		since:    time.Now(),
+	}
+}
+
+// Start pings the database on a ticker in the background
+func (h *DBHealth) Start() {
+	if h.interval <= 0 {
+		log.Println("Database health watcher disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			h.Check(context.Background())
+		}
+	}()
+}
+
+// Check pings the database now and records the result, logging when it changes
+func (h *DBHealth) Check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, dbPingTimeout)
+	defer cancel()
+
+	err := h.ping(ctx)
+	healthy := err == nil
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if healthy != h.healthy {
+		if healthy {
+			log.Printf("Database reachable again after %s", time.Since(h.since).Round(time.Second))
+		} else {
+			log.Printf("Database unreachable, serving degraded reads: %v", err)
+		}
+		h.healthy = healthy
+		h.since = time.Now()
+	}
+	return healthy
+}
+
+func (h *DBHealth) ping(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Healthy reports the last ping's result and when that state began
+func (h *DBHealth) Healthy() (bool, time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.healthy, h.since
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Dedupe link statuses
+const (
+	DedupeStatusPending  = "pending"
+	DedupeStatusAccepted = "accepted"
+	DedupeStatusRejected = "rejected"
+)
+
+// DedupeReasonTitleSimilarity is the merge reason for events with near-identical titles
+const DedupeReasonTitleSimilarity = "title_similarity"
+
+// dedupeWindow is how far apart two events' starts can be and still be the same event
+const dedupeWindow = 48 * time.Hour
+
+// maxDedupeCandidates bounds how many nearby events one lookup compares titles against
+const maxDedupeCandidates = 500
+
+// maxPendingDuplicates bounds GET /admin/dupes
+const maxPendingDuplicates = 500
+
+var (
+	// ErrDedupeLinkNotFound is returned when the duplicate suggestion does not exist
+	ErrDedupeLinkNotFound = errors.New("duplicate suggestion not found")
+	// ErrDedupeLinkResolved is returned when the suggestion was already accepted or rejected
+	ErrDedupeLinkResolved = errors.New("duplicate suggestion already resolved")
+)
+
+// DedupeService finds published events that a new event duplicates under a slightly
+// different title ("Jazz Nite" for "Jazz Night"). Exact title and date matches never get
+// here: they share a canonical key and the candidate corroborates the existing event.
+type DedupeService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+func NewDedupeService(db *gorm.DB, cfg *config.Config) *DedupeService {
+	return &DedupeService{db: db, config: cfg}
+}
+
+// FindDuplicates returns the approved events starting within two days of event whose
+// normalized titles are within DEDUPE_TITLE_MAX_DISTANCE of its title, most similar first.
+// When near (the new event's geocoded location) is known, events at a located venue more
+// than DEDUPE_VENUE_MAX_METERS from it are not duplicates: the same show in two towns is two
+// events. Events without a located venue are still compared on title alone.
+func (s *DedupeService) FindDuplicates(ctx context.Context, event *models.Event, near *CaptureLocation) ([]models.Event, error) {
+	maxDistance := s.config.DedupeTitleMaxDistance
+	if maxDistance <= 0 || normalizeDedupeTitle(event.Title) == "" {
+		return nil, nil
+	}
+
+	query := s.db.WithContext(ctx).
+		Where("moderation_state = ?", EventStateApproved).
+		Where("start_ts BETWEEN ? AND ?", event.StartTs.Add(-dedupeWindow), event.StartTs.Add(dedupeWindow))
+	if event.ID != uuid.Nil {
+		query = query.Where("id <> ?", event.ID)
+	}
+	if near != nil && s.config.DedupeVenueMaxMeters > 0 {
+		// Geography distances are in meters; the cast matches idx_venues_location_geography
+		farVenues := s.db.Model(&models.Venue{}).
+			Select("id").
+			Where("location IS NOT NULL AND NOT ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)",
+				near.Longitude, near.Latitude, s.config.DedupeVenueMaxMeters)
+		query = query.Where("venue_id IS NULL OR venue_id NOT IN (?)", farVenues)
+	}
+	var nearby []models.Event
+	if err := query.Order("start_ts ASC").Limit(maxDedupeCandidates).Find(&nearby).Error; err != nil {
+		return nil, fmt.Errorf("failed to load nearby events: %w", err)
+	}
+
+	var duplicates []models.Event
+	similarity := make(map[uuid.UUID]float64)
+	for _, other := range nearby {
+		score := TitleSimilarity(event.Title, other.Title)
+		if 1-score <= maxDistance {
+			duplicates = append(duplicates, other)
+			similarity[other.ID] = score
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return similarity[duplicates[i].ID] > similarity[duplicates[j].ID]
+	})
+	return duplicates, nil
+}
+
+// CandidateLocation is where a candidate was geocoded to, or nil when it wasn't
+func CandidateLocation(candidate *models.EventCandidate) *CaptureLocation {
+	if candidate.Geocode == nil {
+		return nil
+	}
+	var geocode GeocodeResult
+	if err := json.Unmarshal([]byte(*candidate.Geocode), &geocode); err != nil {
+		return nil
+	}
+	if geocode.Latitude == 0 && geocode.Longitude == 0 {
+		return nil
+	}
+	return &CaptureLocation{Latitude: geocode.Latitude, Longitude: geocode.Longitude}
+}
+
+// MergedInto returns the event a confirmed duplicate was merged into, or nil when eventID
+// is not a confirmed duplicate
+func MergedInto(db *gorm.DB, eventID uuid.UUID) (*uuid.UUID, error) {
+	var link models.DedupeLink
+	err := db.Select("primary_event_id").
+		Where("duplicate_event_id = ? AND status = ?", eventID, DedupeStatusAccepted).
+		Order("resolved_at DESC").
+		Take(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link.PrimaryEventID, nil
+}
+
+// TitleSimilarity compares two titles after normalizing them (case, punctuation, spacing):
+// 1 minus their Levenshtein distance over the longer title's length, so 1 is identical
+func TitleSimilarity(a, b string) float64 {
+	ra, rb := []rune(normalizeDedupeTitle(a)), []rune(normalizeDedupeTitle(b))
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// normalizeDedupeTitle lowercases a title, drops accents, and reduces everything but letters
+// and digits to single spaces, so "Café Night!" and "cafe  night" compare equal
+func normalizeDedupeTitle(title string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// levenshtein is the edit distance between two rune slices
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// MergeEvents folds a duplicate event into its primary pending an admin's review: the
+// duplicate leaves the public listings (state merged) and a pending DedupeLink records the
+// suggestion. A duplicate that was live is removed from the change feed. The duplicate may
+// already be merged, as when it is created that way. Must run inside the caller's transaction.
+func MergeEvents(tx *gorm.DB, primaryID, duplicateID uuid.UUID, score float64, reason string) (*models.DedupeLink, error) {
+	if primaryID == duplicateID {
+		return nil, errors.New("an event can't be merged into itself")
+	}
+
+	var duplicate models.Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "moderation_state").
+		First(&duplicate, "id = ?", duplicateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+	from := duplicate.ModerationState
+	if from != EventStateMerged {
+		if err := TransitionEventState(tx, duplicateID, EventTransition{
+			To:     EventStateMerged,
+			Actor:  ActorAuto,
+			Reason: TransitionReasonMergedDuplicate,
+		}); err != nil {
+			return nil, err
+		}
+		if err := RecordEventHistoryByID(tx, duplicateID); err != nil {
+			return nil, err
+		}
+		if from == EventStateApproved {
+			if err := RecordEventChange(tx, duplicateID, ChangeTypeDeleted, ChangeReasonMerged); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	link := models.DedupeLink{
+		PrimaryEventID:   primaryID,
+		DuplicateEventID: duplicateID,
+		SimilarityScore:  score,
+		MergeReason:      reason,
+		Status:           DedupeStatusPending,
+		CreatedAt:        time.Now(),
+	}
+	if err := tx.Create(&link).Error; err != nil {
+		return nil, fmt.Errorf("failed to record dedupe link: %w", err)
+	}
+
+	if err := RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityEvent,
+		EntityID:   duplicateID,
+		Action:     AuditActionEventMerged,
+		Actor:      SystemActor(ActorAuto),
+		Changes: map[string]interface{}{
+			"moderation_state": map[string]string{"from": from, "to": EventStateMerged},
+		},
+		Metadata: map[string]interface{}{
+			"primary_event_id": primaryID,
+			"dedupe_link_id":   link.ID,
+			"similarity_score": score,
+			"merge_reason":     reason,
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// DuplicateSuggestion is one pending merge of an event into a group's primary
+type DuplicateSuggestion struct {
+	LinkID          uuid.UUID    `json:"link_id"`
+	Event           models.Event `json:"event"`
+	SimilarityScore float64      `json:"similarity_score"`
+	MergeReason     string       `json:"merge_reason"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// DuplicateGroup is a primary event with its pending duplicate suggestions
+type DuplicateGroup struct {
+	Primary    models.Event          `json:"primary"`
+	Duplicates []DuplicateSuggestion `json:"duplicates"`
+}
+
+// ListPendingDuplicates returns unresolved merge suggestions grouped by primary event,
+// groups with the oldest suggestion first
+func ListPendingDuplicates(db *gorm.DB) ([]DuplicateGroup, error) {
+	var links []models.DedupeLink
+	if err := db.Preload("PrimaryEvent").Preload("DuplicateEvent").
+		Where("status = ?", DedupeStatusPending).
+		Order("created_at ASC").
+		Limit(maxPendingDuplicates).
+		Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to list duplicate suggestions: %w", err)
+	}
+
+	groups := []DuplicateGroup{}
+	index := make(map[uuid.UUID]int)
+	for _, link := range links {
+		i, ok := index[link.PrimaryEventID]
+		if !ok {
+			i = len(groups)
+			index[link.PrimaryEventID] = i
+			groups = append(groups, DuplicateGroup{Primary: link.PrimaryEvent})
+		}
+		groups[i].Duplicates = append(groups[i].Duplicates, DuplicateSuggestion{
+			LinkID:          link.ID,
+			Event:           link.DuplicateEvent,
+			SimilarityScore: link.SimilarityScore,
+			MergeReason:     link.MergeReason,
+			CreatedAt:       link.CreatedAt,
+		})
+	}
+	return groups, nil
+}
+
+// ResolveDuplicate decides a pending merge suggestion. Accepting it keeps the duplicate
+// merged and moves its candidates over to the primary, which they now corroborate;
+// rejecting it restores the duplicate to the public listings.
+func ResolveDuplicate(db *gorm.DB, linkID uuid.UUID, accept bool, actor Actor) (*models.DedupeLink, error) {
+	var link models.DedupeLink
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&link, "id = ?", linkID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDedupeLinkNotFound
+			}
+			return err
+		}
+		if link.Status != DedupeStatusPending {
+			return ErrDedupeLinkResolved
+		}
+
+		if accept {
+			if err := acceptDuplicate(tx, &link, actor); err != nil {
+				return err
+			}
+			link.Status = DedupeStatusAccepted
+		} else {
+			if err := rejectDuplicate(tx, &link, actor); err != nil {
+				return err
+			}
+			link.Status = DedupeStatusRejected
+		}
+
+		now := time.Now()
+		link.ResolvedAt = &now
+		link.ResolvedBy = optionalString(actor.AdminID)
+		return tx.Model(&link).Updates(map[string]interface{}{
+			"status":      link.Status,
+			"resolved_at": link.ResolvedAt,
+			"resolved_by": link.ResolvedBy,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// acceptDuplicate points the duplicate's candidates at the primary
+func acceptDuplicate(tx *gorm.DB, link *models.DedupeLink, actor Actor) error {
+	var candidateIDs []uuid.UUID
+	if err := tx.Model(&models.EventCandidate{}).
+		Where("published_event_id = ?", link.DuplicateEventID).
+		Pluck("id", &candidateIDs).Error; err != nil {
+		return fmt.Errorf("failed to list linked candidates: %w", err)
+	}
+	if len(candidateIDs) > 0 {
+		if err := tx.Model(&models.EventCandidate{}).
+			Where("id IN ?", candidateIDs).
+			Update("published_event_id", link.PrimaryEventID).Error; err != nil {
+			return fmt.Errorf("failed to move candidates to the primary event: %w", err)
+		}
+	}
+
+	return RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityEvent,
+		EntityID:   link.DuplicateEventID,
+		Action:     AuditActionEventMergeAccepted,
+		Actor:      actor,
+		Changes:    map[string]interface{}{"candidate_ids": candidateIDs},
+		Metadata: map[string]interface{}{
+			"primary_event_id": link.PrimaryEventID,
+			"dedupe_link_id":   link.ID,
+		},
+	})
+}
+
+// rejectDuplicate returns a merged duplicate to the public listings. On the change feed it
+// is created if it was never public and republished if it was.
+func rejectDuplicate(tx *gorm.DB, link *models.DedupeLink, actor Actor) error {
+	var wasPublic int64
+	if err := tx.Model(&models.EventStateTransition{}).
+		Where("event_id = ? AND to_state = ?", link.DuplicateEventID, EventStateApproved).
+		Count(&wasPublic).Error; err != nil {
+		return fmt.Errorf("failed to check event state history: %w", err)
+	}
+
+	if err := TransitionEventState(tx, link.DuplicateEventID, EventTransition{
+		To:     EventStateApproved,
+		Actor:  actor.Type,
+		Reason: TransitionReasonMergeRejected,
+	}); err != nil {
+		return err
+	}
+	if err := RecordEventHistoryByID(tx, link.DuplicateEventID); err != nil {
+		return err
+	}
+
+	if err := RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityEvent,
+		EntityID:   link.DuplicateEventID,
+		Action:     AuditActionEventMergeRejected,
+		Actor:      actor,
+		Changes: map[string]interface{}{
+			"moderation_state": map[string]string{"from": EventStateMerged, "to": EventStateApproved},
+		},
+		Metadata: map[string]interface{}{
+			"primary_event_id": link.PrimaryEventID,
+			"dedupe_link_id":   link.ID,
+		},
+	}); err != nil {
+		return err
+	}
+	if wasPublic > 0 {
+		return RecordEventChange(tx, link.DuplicateEventID, ChangeTypeUpdated, ChangeReasonRepublished)
+	}
+	return RecordEventChange(tx, link.DuplicateEventID, ChangeTypeCreated, "")
+}
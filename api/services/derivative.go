@@ -0,0 +1,94 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+
+	// Registers WebP with image.Decode, so WebP uploads can be resized, cropped, and redacted
+	_ "golang.org/x/image/webp"
+)
+
+// SubmissionDerivativeFilename is the resized copy of a submission's original that the vision
+// model reads
+const SubmissionDerivativeFilename = "derivative.jpg"
+
+// RecordSubmissionDerivative writes the copy of the upright original that vision analysis
+// reads: its long side at most ImageMaxLongSide, re-encoded as JPEG at ImageJPEGQuality, so
+// the model gets the same kind of image whatever was uploaded (PNG, WebP, or a 20MB JPEG).
+// Its URL is stored as the submission's derivative image. Run it after NormalizeSubmissionImage.
+func RecordSubmissionDerivative(db *gorm.DB, storage *StorageService, cfg *config.Config, submissionID uuid.UUID, imagePath string) error {
+	img, err := decodeImageFile(imagePath)
+	if err != nil {
+		return err
+	}
+	// An original that couldn't be normalized may still carry an orientation
+	if orientation, err := readOrientation(imagePath); err == nil && orientation > 1 && orientation <= 8 {
+		img = applyOrientation(img, orientation)
+	}
+	if cfg.ImageMaxLongSide > 0 {
+		img = downscale(img, cfg.ImageMaxLongSide)
+	}
+
+	// Write next to the old derivative and swap it in, so a concurrent read never sees half a file
+	path := storage.GetFilePath(submissionID, SubmissionDerivativeFilename)
+	tmpPath := path + ".tmp"
+	if err := writeJPEGQuality(tmpPath, img, cfg.ImageJPEGQuality); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save derivative: %w", err)
+	}
+	if err := storage.Persist(submissionID, SubmissionDerivativeFilename); err != nil {
+		return err
+	}
+
+	return db.Model(&models.Submission{}).Where("id = ?", submissionID).
+		Update("derivative_image_url", storage.GetDerivativeImageURL(submissionID)).Error
+}
+
+// derivativeFrame returns the path of a submission's derivative and the factors that map its
+// coordinates to the upright original's, or ok=false when there is no usable derivative. A
+// derivative whose orientation doesn't match the original's (one made before the original
+// was rotated, say) isn't used.
+func derivativeFrame(storage *StorageService, submissionID uuid.UUID, imagePath string) (path string, scaleX, scaleY float64, ok bool) {
+	path = storage.GetFilePath(submissionID, SubmissionDerivativeFilename)
+	derivative, err := imageFileConfig(path)
+	if err != nil || derivative.Width == 0 || derivative.Height == 0 {
+		return "", 0, 0, false
+	}
+	original, err := imageFileConfig(imagePath)
+	if err != nil {
+		return "", 0, 0, false
+	}
+	if orientation, err := readOrientation(imagePath); err == nil && orientation >= 5 && orientation <= 8 {
+		original.Width, original.Height = original.Height, original.Width
+	}
+	if (original.Width >= original.Height) != (derivative.Width >= derivative.Height) {
+		return "", 0, 0, false
+	}
+
+	return path, float64(original.Width) / float64(derivative.Width), float64(original.Height) / float64(derivative.Height), true
+}
+
+// imageFileConfig reads an image file's dimensions without decoding it
+func imageFileConfig(path string) (image.Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("unsupported image: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+func TestRecordSubmissionDerivative(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		asPNG         bool
+		wantW, wantH  int
+	}{
+		{name: "portrait phone photo", width: 3024, height: 4032, wantW: 1536, wantH: 2048},
+		{name: "landscape PNG", width: 4000, height: 3000, asPNG: true, wantW: 2048, wantH: 1536},
+		{name: "already small", width: 800, height: 600, wantW: 800, wantH: 600},
+		{name: "exactly the limit", width: 2048, height: 1024, wantW: 2048, wantH: 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{UploadDir: t.TempDir(), ImageMaxLongSide: 2048, ImageJPEGQuality: 85}
+			storage := NewStorageService(cfg)
+			submissionID := uuid.New()
+			dir := filepath.Dir(storage.GetFilePath(submissionID, SubmissionDerivativeFilename))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			original := writeTestPhoto(t, dir, tt.width, tt.height, tt.asPNG)
+
+			db, mock := testdb.New(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "submissions" SET "derivative_image_url"=$1`).
+				WithArgs(storage.GetDerivativeImageURL(submissionID), testdb.Any, submissionID).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			if err := RecordSubmissionDerivative(db, storage, cfg, submissionID, original); err != nil {
+				t.Fatalf("RecordSubmissionDerivative() error = %v", err)
+			}
+
+			// Always a JPEG, whatever was uploaded
+			derivative, err := jpeg.DecodeConfig(mustOpen(t, storage.GetFilePath(submissionID, SubmissionDerivativeFilename)))
+			if err != nil {
+				t.Fatalf("derivative is not a JPEG: %v", err)
+			}
+			if derivative.Width != tt.wantW || derivative.Height != tt.wantH {
+				t.Errorf("derivative is %dx%d, want %dx%d", derivative.Width, derivative.Height, tt.wantW, tt.wantH)
+			}
+			if _, err := os.Stat(storage.GetFilePath(submissionID, SubmissionDerivativeFilename) + ".tmp"); !os.IsNotExist(err) {
+				t.Errorf("temporary file left behind: %v", err)
+			}
+
+			// Regions found on the derivative map back onto the original
+			_, scaleX, scaleY, ok := derivativeFrame(storage, submissionID, original)
+			if !ok {
+				t.Fatal("derivativeFrame() found no usable derivative")
+			}
+			if wantX, wantY := float64(tt.width)/float64(tt.wantW), float64(tt.height)/float64(tt.wantH); scaleX != wantX || scaleY != wantY {
+				t.Errorf("derivativeFrame() scales %.3f x %.3f, want %.3f x %.3f", scaleX, scaleY, wantX, wantY)
+			}
+		})
+	}
+}
+
+func TestDerivativeFrameRejectsMismatchedOrientation(t *testing.T) {
+	storage := NewStorageService(&config.Config{UploadDir: t.TempDir()})
+	submissionID := uuid.New()
+	dir := filepath.Dir(storage.GetFilePath(submissionID, SubmissionDerivativeFilename))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A landscape derivative left over from before the original was turned upright
+	derivative := writeTestPhoto(t, t.TempDir(), 400, 300, false)
+	if err := os.Rename(derivative, storage.GetFilePath(submissionID, SubmissionDerivativeFilename)); err != nil {
+		t.Fatal(err)
+	}
+	original := writeTestPhoto(t, dir, 600, 800, false)
+
+	if _, _, _, ok := derivativeFrame(storage, submissionID, original); ok {
+		t.Error("derivativeFrame() used a landscape derivative for a portrait original")
+	}
+	if _, _, _, ok := derivativeFrame(storage, uuid.New(), original); ok {
+		t.Error("derivativeFrame() found a derivative for a submission without one")
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// DistributableEvents narrows an event query to events that may go out on distribution
+// surfaces (bulk calendar feeds, syndication, digests, sitemaps, featured slots).
+// Quiet events stay on the map and list endpoints but are left out of all of these.
+func DistributableEvents(db *gorm.DB) *gorm.DB {
+	return db.Where("events.quiet = ?", false)
+}
+
+// SetEventQuiet flips an event's quiet flag and audits the change as actor. It is a no-op when
+// the flag already has the requested value. The flag is admin-only, so neither the
+// public change feed nor the event history is touched.
+func SetEventQuiet(tx *gorm.DB, eventID uuid.UUID, quiet bool, actor Actor) error {
+	var event models.Event
+	if err := tx.Select("id", "quiet").First(&event, "id = ?", eventID).Error; err != nil {
+		return err
+	}
+	if event.Quiet == quiet {
+		return nil
+	}
+
+	if err := tx.Model(&models.Event{}).Where("id = ?", eventID).Update("quiet", quiet).Error; err != nil {
+		return err
+	}
+	return RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityEvent,
+		EntityID:   eventID,
+		Action:     AuditActionEventEdited,
+		Actor:      actor,
+		Changes: map[string]interface{}{
+			"quiet": map[string]interface{}{"from": event.Quiet, "to": quiet},
+		},
+	})
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"gorm.io/gorm"
+)
+
+func TestSetEventQuiet(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   bool
+		quiet     bool
+		wantWrite bool
+	}{
+		{"mark quiet", false, true, true},
+		{"clear", true, false, true},
+		{"already quiet", true, true, false},
+		{"already loud", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+
+			// Callers set the flag inside their own transaction
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT "id","quiet" FROM "events"`).
+				WithArgs(eventID, testdb.Any).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "quiet"}).AddRow(eventID.String(), tt.current))
+			if tt.wantWrite {
+				// Only an audit entry: the flag is admin-only, so no change feed or history row
+				mock.ExpectExec(`UPDATE "events" SET "quiet"=$1`).
+					WithArgs(tt.quiet, testdb.Any, eventID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAudit(mock, AuditActionEventEdited)
+			}
+			mock.ExpectCommit()
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return SetEventQuiet(tx, eventID, tt.quiet, Actor{Type: "admin", AdminID: "ops"})
+			})
+			if err != nil {
+				t.Fatalf("SetEventQuiet() error = %v", err)
+			}
+		})
+	}
+}
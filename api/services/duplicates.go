@@ -0,0 +1,260 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidImageHash is returned for client-reported hashes that aren't in the documented format
+var ErrInvalidImageHash = errors.New("invalid image hash")
+
+// Ways an upload URL request can match an earlier upload
+const (
+	DuplicateMatchSHA256 = "sha256"
+	DuplicateMatchPHash  = "phash"
+)
+
+var (
+	sha256HexPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	phashHexPattern  = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// ImageHashes are the hashes a client may report before uploading a photo.
+// SHA256 is the hex SHA-256 of the exact file bytes it will upload; PHash is the 64-bit
+// dHash described at PerceptualHash, as 16 hex digits.
+type ImageHashes struct {
+	SHA256 *string
+	PHash  *uint64
+}
+
+// Empty reports whether the client sent no hashes
+func (h ImageHashes) Empty() bool {
+	return h.SHA256 == nil && h.PHash == nil
+}
+
+// ParseImageHashes validates client-reported hashes. Hex is accepted in either case; nil or
+// empty values are treated as not sent.
+func ParseImageHashes(sha256Hex, phashHex *string) (ImageHashes, error) {
+	var hashes ImageHashes
+	if sha256Hex != nil && *sha256Hex != "" {
+		value := strings.ToLower(*sha256Hex)
+		if !sha256HexPattern.MatchString(value) {
+			return ImageHashes{}, fmt.Errorf("%w: imageSha256 must be 64 hex digits", ErrInvalidImageHash)
+		}
+		hashes.SHA256 = &value
+	}
+	if phashHex != nil && *phashHex != "" {
+		value := strings.ToLower(*phashHex)
+		if !phashHexPattern.MatchString(value) {
+			return ImageHashes{}, fmt.Errorf("%w: imagePhash must be 16 hex digits", ErrInvalidImageHash)
+		}
+		phash, err := strconv.ParseUint(value, 16, 64)
+		if err != nil {
+			return ImageHashes{}, fmt.Errorf("%w: %v", ErrInvalidImageHash, err)
+		}
+		hashes.PHash = &phash
+	}
+	return hashes, nil
+}
+
+// DuplicateSubmission summarizes the earlier upload a new photo matched
+type DuplicateSubmission struct {
+	SubmissionID uuid.UUID `json:"submissionId"`
+	Status       string    `json:"status"`
+	MatchedBy    string    `json:"matchedBy"` // sha256 or phash
+	FlyersFound  int64     `json:"flyersFound"`
+	EventsFound  int64     `json:"eventsFound"`
+}
+
+// DuplicateSearch bounds what FindDuplicateSubmission compares against
+type DuplicateSearch struct {
+	MaxPHashDistance int           // bits; negative skips perceptual matching
+	Window           time.Duration // only uploads this recent; zero compares against all of them
+	Exclude          uuid.UUID     // the upload being checked, once it has its own row
+}
+
+// NewDuplicateSearch reads the configured duplicate thresholds
+func NewDuplicateSearch(cfg *config.Config) DuplicateSearch {
+	return DuplicateSearch{
+		MaxPHashDistance: cfg.DuplicatePHashMaxDistance,
+		Window:           time.Duration(cfg.DuplicateWindowHours) * time.Hour,
+	}
+}
+
+// FindDuplicateSubmission looks for an earlier upload of the same photo: first an exact
+// SHA-256 match, then the nearest perceptual hash within search.MaxPHashDistance bits. Only
+// hashes the server computed from stored files are compared, so a client that lies about
+// its hash can't plant matches for others. Failed and quality-rejected submissions are
+// ignored so the photo can be tried again, as are uploads that were themselves
+// deduplicated, so matches always lead to the submission holding the flyers. Returns nil
+// when nothing matches.
+func FindDuplicateSubmission(db *gorm.DB, hashes ImageHashes, search DuplicateSearch) (*DuplicateSubmission, error) {
+	candidates := func() *gorm.DB {
+		query := db.Model(&models.Submission{}).
+			Select("id, status").
+			Where("source = ? AND status NOT IN ?", SubmissionSourceUpload, []string{"error", SubmissionStatusFailed, SubmissionStatusRejectedQuality}).
+			Where("duplicate_of_id IS NULL")
+		if search.Window > 0 {
+			query = query.Where("created_at >= ?", time.Now().Add(-search.Window))
+		}
+		if search.Exclude != uuid.Nil {
+			query = query.Where("id <> ?", search.Exclude)
+		}
+		return query
+	}
+
+	var match models.Submission
+	matchedBy := ""
+	if hashes.SHA256 != nil {
+		err := candidates().Where("image_sha256 = ?", *hashes.SHA256).Order("created_at DESC").Take(&match).Error
+		if err == nil {
+			matchedBy = DuplicateMatchSHA256
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to look up image hash: %w", err)
+		}
+	}
+	if matchedBy == "" && hashes.PHash != nil && search.MaxPHashDistance >= 0 {
+		// Hamming distance: count the 1 bits of the XOR. The hash is an integer, so it is
+		// safe to format into the expression, which the ORDER BY needs.
+		distance := fmt.Sprintf("length(replace((image_phash # (%d)::bigint)::bit(64)::text, '0', ''))", int64(*hashes.PHash))
+		err := candidates().
+			Where("image_phash IS NOT NULL").
+			Where(distance+" <= ?", search.MaxPHashDistance).
+			Order(distance + " ASC, created_at DESC").
+			Take(&match).Error
+		if err == nil {
+			matchedBy = DuplicateMatchPHash
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to look up perceptual hash: %w", err)
+		}
+	}
+	if matchedBy == "" {
+		return nil, nil
+	}
+
+	summary := &DuplicateSubmission{SubmissionID: match.ID, Status: match.Status, MatchedBy: matchedBy}
+	if err := db.Model(&models.Flyer{}).Where("submission_id = ?", match.ID).Count(&summary.FlyersFound).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.EventCandidate{}).
+		Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+		Where("flyers.submission_id = ?", match.ID).
+		Count(&summary.EventsFound).Error; err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// DeduplicateSubmission links a freshly stored upload to an earlier submission of the same
+// photo, by the hashes RecordImageHashes stored, so it shares that submission's flyers and
+// candidates instead of being analyzed again. Only single-photo uploads are matched, since
+// hashes cover the first photo alone. Returns nil, clearing any link a previous upload of
+// the submission left, when the photo is new.
+func DeduplicateSubmission(db *gorm.DB, submissionID uuid.UUID, search DuplicateSearch) (*DuplicateSubmission, error) {
+	var submission models.Submission
+	if err := db.Select("image_count, image_sha256, image_phash").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return nil, err
+	}
+
+	var duplicate *DuplicateSubmission
+	hashes := ImageHashes{SHA256: submission.ImageSHA256}
+	if submission.ImagePHash != nil {
+		phash := uint64(*submission.ImagePHash)
+		hashes.PHash = &phash
+	}
+	if submission.ImageCount <= 1 && !hashes.Empty() {
+		search.Exclude = submissionID
+		var err error
+		if duplicate, err = FindDuplicateSubmission(db, hashes, search); err != nil {
+			return nil, err
+		}
+	}
+
+	var duplicateOf *uuid.UUID
+	if duplicate != nil {
+		duplicateOf = &duplicate.SubmissionID
+	}
+	if err := db.Model(&models.Submission{}).Where("id = ?", submissionID).
+		Update("duplicate_of_id", duplicateOf).Error; err != nil {
+		return nil, fmt.Errorf("failed to link duplicate submission: %w", err)
+	}
+	return duplicate, nil
+}
+
+// RecordImageHashes stores the server's own hashes of an upload: sha256Hex of the bytes as
+// received, and the perceptual hash of the upright image at imagePath, so run it after
+// NormalizeSubmissionImage. A client-reported hash that doesn't match is logged, not trusted.
+func RecordImageHashes(db *gorm.DB, submissionID uuid.UUID, sha256Hex, imagePath string) error {
+	updates := map[string]interface{}{"image_sha256": sha256Hex}
+	if img, err := decodeImageFile(imagePath); err == nil {
+		updates["image_phash"] = int64(PerceptualHash(img))
+	} else {
+		log.Printf("Failed to compute perceptual hash for submission %s: %v", submissionID, err)
+	}
+
+	var submission models.Submission
+	if err := db.Select("claimed_sha256").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return err
+	}
+	if submission.ClaimedSHA256 != nil && *submission.ClaimedSHA256 != sha256Hex {
+		log.Printf("Submission %s: client-reported SHA-256 %s does not match upload %s", submissionID, *submission.ClaimedSHA256, sha256Hex)
+	}
+
+	return db.Model(&models.Submission{}).Where("id = ?", submissionID).Updates(updates).Error
+}
+
+// PerceptualHash is a 64-bit difference hash (dHash): the image is reduced to a 9x8 grid of
+// average luminance (0.299 R + 0.587 G + 0.114 B), and each row's 8 left-to-right
+// comparisons set a bit when a cell is brighter than its right neighbour. Bits are taken
+// row by row from the top, most significant first. Similar photos differ in a few bits.
+func PerceptualHash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var grid [rows][cols]float64
+	for y := 0; y < rows; y++ {
+		y0, y1 := bounds.Min.Y+y*h/rows, bounds.Min.Y+(y+1)*h/rows
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < cols; x++ {
+			x0, x1 := bounds.Min.X+x*w/cols, bounds.Min.X+(x+1)*w/cols
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum float64
+			var n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			grid[y][x] = sum / float64(n)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
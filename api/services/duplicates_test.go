@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+func TestParseImageHashes(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	upperSHA := strings.ToUpper(sha)
+	phash, upperPHash := "00ff00ff00ff00ff", "00FF00FF00FF00FF"
+	short, nonHex, empty := "abc", strings.Repeat("zz", 32), ""
+
+	tests := []struct {
+		name      string
+		sha256    *string
+		phash     *string
+		wantSHA   string
+		wantPHash uint64
+		wantEmpty bool
+		wantErr   bool
+	}{
+		{name: "no hashes is backward compatible", wantEmpty: true},
+		{name: "empty strings are not sent", sha256: &empty, phash: &empty, wantEmpty: true},
+		{name: "sha256 only", sha256: &sha, wantSHA: sha},
+		{name: "upper-case hex", sha256: &upperSHA, phash: &upperPHash, wantSHA: sha, wantPHash: 0x00ff00ff00ff00ff},
+		{name: "phash only", phash: &phash, wantPHash: 0x00ff00ff00ff00ff},
+		{name: "short sha256", sha256: &short, wantErr: true},
+		{name: "non-hex sha256", sha256: &nonHex, wantErr: true},
+		{name: "phash the length of a sha256", phash: &sha, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseImageHashes(tt.sha256, tt.phash)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidImageHash) {
+					t.Errorf("ParseImageHashes() error = %v, want ErrInvalidImageHash", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseImageHashes() error = %v", err)
+			}
+			if got.Empty() != tt.wantEmpty {
+				t.Errorf("Empty() = %v, want %v", got.Empty(), tt.wantEmpty)
+			}
+			if (got.SHA256 == nil) != (tt.wantSHA == "") || (got.SHA256 != nil && *got.SHA256 != tt.wantSHA) {
+				t.Errorf("SHA256 = %v, want %q", got.SHA256, tt.wantSHA)
+			}
+			if (got.PHash == nil) != (tt.phash == nil || *tt.phash == "") || (got.PHash != nil && *got.PHash != tt.wantPHash) {
+				t.Errorf("PHash = %v, want %x", got.PHash, tt.wantPHash)
+			}
+		})
+	}
+}
+
+func TestFindDuplicateSubmission(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	phash := uint64(0xf0f0f0f0f0f0f0f0)
+	search := DuplicateSearch{MaxPHashDistance: 4}
+	lookup := `SELECT id, status FROM "submissions" WHERE (source = $1 AND status NOT IN ($2,$3,$4)) AND duplicate_of_id IS NULL`
+
+	tests := []struct {
+		name        string
+		hashes      ImageHashes
+		shaMatch    bool
+		phashLookup bool
+		phashMatch  bool
+		wantBy      string
+	}{
+		{name: "identical bytes", hashes: ImageHashes{SHA256: &sha, PHash: &phash}, shaMatch: true, wantBy: DuplicateMatchSHA256},
+		{name: "re-encoded copy", hashes: ImageHashes{SHA256: &sha, PHash: &phash}, phashLookup: true, phashMatch: true, wantBy: DuplicateMatchPHash},
+		{name: "new photo", hashes: ImageHashes{SHA256: &sha, PHash: &phash}, phashLookup: true},
+		{name: "sha256 alone", hashes: ImageHashes{SHA256: &sha}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			prior := uuid.New()
+			found := func(match bool) *sqlmock.Rows {
+				rows := sqlmock.NewRows([]string{"id", "status"})
+				if match {
+					rows.AddRow(prior.String(), "done")
+				}
+				return rows
+			}
+
+			mock.ExpectQuery(lookup+` AND image_sha256 = $5 ORDER BY created_at DESC LIMIT $6`).
+				WithArgs(SubmissionSourceUpload, "error", SubmissionStatusFailed, SubmissionStatusRejectedQuality, sha, 1).
+				WillReturnRows(found(tt.shaMatch))
+			if tt.phashLookup {
+				// Distances are compared against the server's stored hashes, by Hamming distance
+				mock.ExpectQuery(lookup+` AND image_phash IS NOT NULL AND length(replace((image_phash # (-1085102592571150096)::bigint)::bit(64)::text, '0', '')) <= $5`).
+					WithArgs(SubmissionSourceUpload, "error", SubmissionStatusFailed, SubmissionStatusRejectedQuality, 4, 1).
+					WillReturnRows(found(tt.phashMatch))
+			}
+			if tt.wantBy != "" {
+				mock.ExpectQuery(`SELECT count(*) FROM "flyers" WHERE submission_id = $1`).
+					WithArgs(prior).
+					WillReturnRows(testdb.Count(2))
+				mock.ExpectQuery(`SELECT count(*) FROM "event_candidates" JOIN flyers`).
+					WithArgs(prior).
+					WillReturnRows(testdb.Count(3))
+			}
+
+			got, err := FindDuplicateSubmission(db, tt.hashes, search)
+			if err != nil {
+				t.Fatalf("FindDuplicateSubmission() error = %v", err)
+			}
+			if tt.wantBy == "" {
+				if got != nil {
+					t.Errorf("FindDuplicateSubmission() = %+v, want no match", got)
+				}
+				return
+			}
+			want := DuplicateSubmission{SubmissionID: prior, Status: "done", MatchedBy: tt.wantBy, FlyersFound: 2, EventsFound: 3}
+			if got == nil || *got != want {
+				t.Errorf("FindDuplicateSubmission() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestRecordImageHashesIgnoresClientClaim is the lie case: the client reported one hash and
+// uploaded different bytes, so only the hash the server computed is stored
+func TestRecordImageHashesIgnoresClientClaim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "original.jpg")
+	img := image.NewGray(image.Rect(0, 0, 18, 16))
+	for x := 0; x < 18; x++ {
+		for y := 0; y < 16; y++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x * 14)})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	decoded, err := decodeImageFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, actual := strings.Repeat("0", 64), strings.Repeat("f", 64)
+	submissionID := uuid.New()
+
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`SELECT "claimed_sha256" FROM "submissions" WHERE id = $1`).
+		WithArgs(submissionID, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"claimed_sha256"}).AddRow(claimed))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "submissions" SET "image_phash"=$1,"image_sha256"=$2,"updated_at"=$3 WHERE id = $4`).
+		WithArgs(int64(PerceptualHash(decoded)), actual, testdb.Any, submissionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := RecordImageHashes(db, submissionID, actual, path); err != nil {
+		t.Fatalf("RecordImageHashes() error = %v", err)
+	}
+}
+
+func TestPerceptualHash(t *testing.T) {
+	gradient := func(w, h int, shade func(x, y int) uint8) image.Image {
+		img := image.NewGray(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.SetGray(x, y, color.Gray{Y: shade(x, y)})
+			}
+		}
+		return img
+	}
+	// A 2x2 checkerboard of light and dark quadrants, and the same at another size
+	checker := func(w, h int) image.Image {
+		return gradient(w, h, func(x, y int) uint8 {
+			if (x < w/2) == (y < h/2) {
+				return 230
+			}
+			return 20
+		})
+	}
+	original := checker(360, 320)
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		maxBits int
+		minBits int
+	}{
+		{"same image", checker(360, 320), 0, 0},
+		{"resized copy", checker(180, 160), 4, 0},
+		{"brightened copy", gradient(360, 320, func(x, y int) uint8 {
+			if (x < 180) == (y < 160) {
+				return 250
+			}
+			return 60
+		}), 4, 0},
+		{"different photo", gradient(360, 320, func(x, y int) uint8 { return uint8(x * 255 / 360) }), 64, 8},
+	}
+
+	want := PerceptualHash(original)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance := bits.OnesCount64(PerceptualHash(tt.img) ^ want)
+			if distance > tt.maxBits || distance < tt.minBits {
+				t.Errorf("distance = %d bits, want %d to %d", distance, tt.minBits, tt.maxBits)
+			}
+		})
+	}
+}
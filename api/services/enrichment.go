@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"golang.org/x/net/html"
+)
+
+// maxEnrichmentBody caps how much of a linked page we read
+const maxEnrichmentBody = 1 << 20 // 1MB
+
+// Review reasons recorded on candidates routed to needs_review
+const (
+	ReviewReasonLowScore        = "low_score"
+	ReviewReasonNeedsEnrichment = "needs_enrichment"
+)
+
+// enrichmentMetaFields maps page metadata names to candidate field names, in priority order
+var enrichmentMetaFields = []struct {
+	Meta  string
+	Field string
+}{
+	{"og:title", "title"},
+	{"twitter:title", "title"},
+	{"og:description", "description"},
+	{"description", "description"},
+	{"event:start_time", "date_time"},
+	{"og:start_time", "date_time"},
+	{"og:start-time", "date_time"},
+	{"startdate", "date_time"},
+	{"event:end_time", "end_time"},
+	{"enddate", "end_time"},
+	{"event:location", "venue"},
+	{"og:location", "venue"},
+	{"location", "venue"},
+	{"og:street-address", "address"},
+}
+
+// EnrichmentService fetches public event metadata for online-only flyers
+type EnrichmentService struct {
+	config     *config.Config
+	httpClient *http.Client
+}
+
+// EnrichmentResult holds the candidate fields recovered from a linked page
+type EnrichmentResult struct {
+	SourceURL string            `json:"source_url"`
+	Fields    map[string]string `json:"fields"`
+}
+
+func NewEnrichmentService(cfg *config.Config) *EnrichmentService {
+	return &EnrichmentService{
+		config:     cfg,
+		httpClient: NewHardenedHTTPClient(time.Duration(cfg.URLEnrichmentTimeoutMS) * time.Millisecond),
+	}
+}
+
+// Enabled reports whether server-side URL fetching is turned on
+func (e *EnrichmentService) Enabled() bool {
+	return e.config.URLEnrichmentEnabled
+}
+
+// EnrichFromURL fetches a page and extracts og:/event: metadata from its head
+func (e *EnrichmentService) EnrichFromURL(ctx context.Context, pageURL string) (*EnrichmentResult, error) {
+	target := SanitizeField("url", pageURL)
+	if target == "" {
+		return nil, fmt.Errorf("invalid enrichment URL: %q", pageURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", e.config.AppName+" event enrichment")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("enrichment request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("enrichment URL returned status %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, fmt.Errorf("enrichment URL is not HTML: %s", contentType)
+	}
+
+	meta, err := parseMetaTags(io.LimitReader(resp.Body, maxEnrichmentBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse enrichment page: %w", err)
+	}
+
+	fields := make(map[string]string)
+	for _, mapping := range enrichmentMetaFields {
+		if _, taken := fields[mapping.Field]; taken {
+			continue
+		}
+		if value := SanitizeField(mapping.Field, meta[mapping.Meta]); value != "" {
+			fields[mapping.Field] = value
+		}
+	}
+
+	return &EnrichmentResult{
+		SourceURL: resp.Request.URL.String(),
+		Fields:    fields,
+	}, nil
+}
+
+// parseMetaTags collects <meta property|name|itemprop=... content=...> values, lower-casing keys
+func parseMetaTags(r io.Reader) (map[string]string, error) {
+	meta := make(map[string]string)
+	tokenizer := html.NewTokenizer(r)
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return meta, nil
+			}
+			return meta, tokenizer.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "body" {
+				return meta, nil
+			}
+			if token.Data != "meta" {
+				continue
+			}
+
+			var key, content string
+			for _, attr := range token.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "property", "name", "itemprop":
+					key = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if key != "" && content != "" {
+				if _, exists := meta[key]; !exists {
+					meta[key] = content
+				}
+			}
+		}
+	}
+}
+
+// MergeEnrichment fills empty candidate fields from an enrichment result and records
+// where they came from. It returns the names of the fields that were filled.
+func MergeEnrichment(eventData map[string]interface{}, result *EnrichmentResult) []string {
+	var merged []string
+	for field, value := range result.Fields {
+		if existing, ok := eventData[field].(string); ok && strings.TrimSpace(existing) != "" {
+			continue
+		}
+		eventData[field] = value
+		merged = append(merged, field)
+	}
+
+	if len(merged) > 0 {
+		sort.Strings(merged)
+		eventData["enrichment_source"] = result.SourceURL
+		eventData["enriched_fields"] = merged
+	}
+	return merged
+}
+
+// EnrichmentURL returns the link an online-only candidate points to, preferring decoded QR codes
+func EnrichmentURL(eventData map[string]interface{}) string {
+	if qrURLs, ok := eventData["qr_urls"].([]interface{}); ok {
+		for _, raw := range qrURLs {
+			if link, ok := raw.(string); ok && link != "" {
+				return link
+			}
+		}
+	}
+	if link, ok := eventData["url"].(string); ok {
+		return link
+	}
+	return ""
+}
+
+// NeedsEnrichment reports whether a candidate advertises a link but lacks the date and venue
+// needed to publish. Candidates flagged details_online_only qualify when either is missing.
+func NeedsEnrichment(eventData map[string]interface{}) bool {
+	if EnrichmentURL(eventData) == "" {
+		return false
+	}
+
+	hasText := func(keys ...string) bool {
+		for _, key := range keys {
+			if value, ok := eventData[key].(string); ok && strings.TrimSpace(value) != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	hasDate := hasText("date", "date_time", "start_time")
+	hasVenue := hasText("venue", "address")
+	if onlineOnly, _ := eventData["details_online_only"].(bool); onlineOnly {
+		return !hasDate || !hasVenue
+	}
+	return !hasDate && !hasVenue
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+func TestNeedsEnrichment(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventData map[string]interface{}
+		want      bool
+	}{
+		{
+			name:      "no link never needs enrichment",
+			eventData: map[string]interface{}{"title": "Potluck"},
+			want:      false,
+		},
+		{
+			name:      "QR-only flyer",
+			eventData: map[string]interface{}{"title": "Potluck", "qr_urls": []interface{}{"https://example.com/e/1"}},
+			want:      true,
+		},
+		{
+			name:      "URL-only flyer",
+			eventData: map[string]interface{}{"title": "Potluck", "url": "https://example.com/e/1"},
+			want:      true,
+		},
+		{
+			name:      "link with a date is publishable as is",
+			eventData: map[string]interface{}{"url": "https://example.com/e/1", "date_time": "May 3 7pm"},
+			want:      false,
+		},
+		{
+			name:      "link with a venue is publishable as is",
+			eventData: map[string]interface{}{"url": "https://example.com/e/1", "venue": "Town Hall"},
+			want:      false,
+		},
+		{
+			name:      "blank date and venue count as missing",
+			eventData: map[string]interface{}{"url": "https://example.com/e/1", "date_time": "  ", "venue": ""},
+			want:      true,
+		},
+		{
+			name: "online-only flyer missing its venue",
+			eventData: map[string]interface{}{
+				"url": "https://example.com/e/1", "date_time": "May 3 7pm", "details_online_only": true,
+			},
+			want: true,
+		},
+		{
+			name: "online-only flyer with date and venue",
+			eventData: map[string]interface{}{
+				"url": "https://example.com/e/1", "date_time": "May 3 7pm", "address": "1 Main St", "details_online_only": true,
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsEnrichment(tt.eventData); got != tt.want {
+				t.Errorf("NeedsEnrichment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnrichmentURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventData map[string]interface{}
+		want      string
+	}{
+		{"none", map[string]interface{}{}, ""},
+		{"printed URL", map[string]interface{}{"url": "https://example.com/a"}, "https://example.com/a"},
+		{
+			"QR code beats the printed URL",
+			map[string]interface{}{"url": "https://example.com/a", "qr_urls": []interface{}{"", "https://example.com/qr"}},
+			"https://example.com/qr",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EnrichmentURL(tt.eventData); got != tt.want {
+				t.Errorf("EnrichmentURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeEnrichment(t *testing.T) {
+	result := &EnrichmentResult{
+		SourceURL: "https://example.com/e/1",
+		Fields: map[string]string{
+			"title":     "Page Title",
+			"date_time": "2026-05-03T19:00",
+			"venue":     "Town Hall",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		eventData  map[string]interface{}
+		wantMerged []string
+		wantTitle  string
+	}{
+		{
+			name:       "fills only empty fields",
+			eventData:  map[string]interface{}{"title": "Flyer Title", "venue": " "},
+			wantMerged: []string{"date_time", "venue"},
+			wantTitle:  "Flyer Title",
+		},
+		{
+			name:       "nothing to fill",
+			eventData:  map[string]interface{}{"title": "Flyer Title", "date_time": "May 3", "venue": "Library"},
+			wantMerged: nil,
+			wantTitle:  "Flyer Title",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := MergeEnrichment(tt.eventData, result)
+			if !reflect.DeepEqual(merged, tt.wantMerged) {
+				t.Errorf("merged = %v, want %v", merged, tt.wantMerged)
+			}
+			if tt.eventData["title"] != tt.wantTitle {
+				t.Errorf("title = %v, want %q", tt.eventData["title"], tt.wantTitle)
+			}
+			_, hasSource := tt.eventData["enrichment_source"]
+			if hasSource != (len(tt.wantMerged) > 0) {
+				t.Errorf("enrichment_source present = %v, want %v", hasSource, len(tt.wantMerged) > 0)
+			}
+		})
+	}
+}
+
+func TestParseMetaTags(t *testing.T) {
+	page := `<html><head>
+		<meta property="og:title" content="First">
+		<meta property="OG:TITLE" content="Second">
+		<meta name="description" content="About the event">
+		<meta itemprop="startDate" content="2026-05-03T19:00">
+		<meta property="og:image">
+	</head><body><meta property="og:location" content="Ignored"></body></html>`
+
+	meta, err := parseMetaTags(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("parseMetaTags: %v", err)
+	}
+	want := map[string]string{
+		"og:title":    "First",
+		"description": "About the event",
+		"startdate":   "2026-05-03T19:00",
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("meta = %v, want %v", meta, want)
+	}
+}
+
+func TestEnrichFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<head>
+				<meta property="og:title" content="Spring  Fair">
+				<meta name="twitter:title" content="Other title">
+				<meta property="event:start_time" content="2026-05-03T10:00">
+				<meta property="event:location" content="Town Hall">
+			</head>`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	enrichment := &EnrichmentService{config: &config.Config{AppName: "test"}, httpClient: server.Client()}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantFields map[string]string
+		wantErr    bool
+	}{
+		{
+			name: "event page",
+			path: "/event",
+			wantFields: map[string]string{
+				"title":     "Spring Fair",
+				"date_time": "2026-05-03T10:00",
+				"venue":     "Town Hall",
+			},
+		},
+		{name: "not HTML", path: "/image", wantErr: true},
+		{name: "missing page", path: "/gone", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := enrichment.EnrichFromURL(context.Background(), server.URL+tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("EnrichFromURL succeeded with %v, want an error", result.Fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EnrichFromURL: %v", err)
+			}
+			if !reflect.DeepEqual(result.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", result.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestHardenedHTTPClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := NewHardenedHTTPClient(time.Second)
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to loopback succeeded")
+	}
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("error = %v, want %v", err, errBlockedAddress)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	config_pkg "github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/dateparse"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// RegionLocation returns the configured region timezone, falling back to UTC
+func RegionLocation(cfg *config_pkg.Config) *time.Location {
+	loc, err := cfg.GetLocation()
+	if err != nil {
+		log.Printf("Invalid REGION_TZ %q, using UTC: %v", cfg.RegionTZ, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// CandidateDateString returns a candidate's raw start date text. Older extractions used
+// "date"; current ones use "date_time".
+func CandidateDateString(fields map[string]interface{}) string {
+	if date, ok := fields["date"].(string); ok && date != "" {
+		return date
+	}
+	if dateTime, ok := fields["date_time"].(string); ok && dateTime != "" {
+		return dateTime
+	}
+	return ""
+}
+
+// CandidateReferenceTime is the "now" that relative flyer dates are resolved against:
+// when the photo was taken if known (see SubmissionCapture), otherwise when it was submitted
+func CandidateReferenceTime(db *gorm.DB, candidate *models.EventCandidate) time.Time {
+	capture, err := CandidateCapture(db, candidate)
+	if err != nil {
+		if candidate.CreatedAt.IsZero() {
+			return time.Now()
+		}
+		return candidate.CreatedAt
+	}
+	return capture.CapturedAt
+}
+
+// ParseCandidateStart parses a candidate's start date. ok is false when the candidate
+// has no date or it could not be read.
+func ParseCandidateStart(db *gorm.DB, candidate *models.EventCandidate, fields map[string]interface{}, loc *time.Location) (result dateparse.Result, raw string, ok bool) {
+	raw = CandidateDateString(fields)
+	if raw == "" {
+		return dateparse.Result{}, "", false
+	}
+	result, err := dateparse.Parse(raw, CandidateReferenceTime(db, candidate), loc)
+	return result, raw, err == nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidEventEdit is returned for edits that would leave an event without a title or
+// ending before it starts
+var ErrInvalidEventEdit = errors.New("invalid event edit")
+
+// EventEdit corrects a public event's calendar fields; nil fields are left unchanged
+type EventEdit struct {
+	Title   *string
+	StartTs *time.Time
+	EndTs   *time.Time
+	VenueID *uuid.UUID
+}
+
+// Empty reports whether the edit changes nothing
+func (e EventEdit) Empty() bool {
+	return e.Title == nil && e.StartTs == nil && e.EndTs == nil && e.VenueID == nil
+}
+
+// EditEvent applies an edit (a retitle, reschedule, or move to another venue) and bumps
+// the event's ICS sequence so subscribed calendars update their copy. The canonical key is
+// left alone: it only deduplicates extractions, and the calendar UID never depended on it.
+// Must run inside the caller's transaction.
+func EditEvent(tx *gorm.DB, eventID uuid.UUID, edit EventEdit, actor Actor) (*models.Event, error) {
+	var event models.Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	changes := map[string]interface{}{}
+	if edit.Title != nil {
+		title := strings.TrimSpace(*edit.Title)
+		if title == "" {
+			return nil, fmt.Errorf("%w: title must not be empty", ErrInvalidEventEdit)
+		}
+		if title != event.Title {
+			changes["title"] = map[string]interface{}{"from": event.Title, "to": title}
+			updates["title"] = title
+			event.Title = title
+		}
+	}
+	if edit.StartTs != nil && !edit.StartTs.Equal(event.StartTs) {
+		changes["start_ts"] = map[string]interface{}{"from": event.StartTs, "to": *edit.StartTs}
+		updates["start_ts"] = *edit.StartTs
+		event.StartTs = *edit.StartTs
+	}
+	if edit.EndTs != nil && (event.EndTs == nil || !edit.EndTs.Equal(*event.EndTs)) {
+		changes["end_ts"] = map[string]interface{}{"from": event.EndTs, "to": *edit.EndTs}
+		updates["end_ts"] = *edit.EndTs
+		event.EndTs = edit.EndTs
+	}
+	if edit.VenueID != nil && (event.VenueID == nil || *event.VenueID != *edit.VenueID) {
+		changes["venue_id"] = map[string]interface{}{"from": event.VenueID, "to": *edit.VenueID}
+		updates["venue_id"] = *edit.VenueID
+		event.VenueID = edit.VenueID
+	}
+	if event.EndTs != nil && event.EndTs.Before(event.StartTs) {
+		return nil, fmt.Errorf("%w: end_ts is before start_ts", ErrInvalidEventEdit)
+	}
+	if len(updates) == 0 {
+		return &event, nil
+	}
+
+	event.ICSSequence++
+	event.UpdatedAt = time.Now()
+	updates["ics_sequence"] = event.ICSSequence
+	updates["updated_at"] = event.UpdatedAt
+	if err := tx.Model(&models.Event{}).Where("id = ?", eventID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	if err := RecordEventHistory(tx, &event); err != nil {
+		return nil, err
+	}
+
+	if err := RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityEvent,
+		EntityID:   eventID,
+		Action:     AuditActionEventEdited,
+		Actor:      actor,
+		Changes:    changes,
+	}); err != nil {
+		return nil, err
+	}
+	if err := RecordEventChange(tx, eventID, ChangeTypeUpdated, ChangeReasonEdited); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// Event image sizes accepted by GET /v1/events/:id/image
+const (
+	EventImageThumb = "thumb"
+	EventImageFull  = "full"
+)
+
+// thumbMaxDimension bounds the longer side of a thumbnail
+const thumbMaxDimension = 320
+
+// ErrEventImageUnavailable is returned when an event has no public flyer image, or its
+// file is gone (for example after the submission was purged)
+var ErrEventImageUnavailable = errors.New("event has no image")
+
+// IsValidEventImageSize reports whether size is thumb or full
+func IsValidEventImageSize(size string) bool {
+	return size == EventImageThumb || size == EventImageFull
+}
+
+// EventImageURL is the stable public URL for an event's flyer image. Partners should
+// use it rather than /files paths, which expose submission IDs and move with storage.
+func EventImageURL(baseURL string, eventID uuid.UUID, size string) string {
+	return fmt.Sprintf("%s/v1/events/%s/image?size=%s", baseURL, eventID, size)
+}
+
+// eventImageFlyers selects flyers with a public (redacted) image that back an approved event
+func eventImageFlyers(db *gorm.DB) *gorm.DB {
+	return db.Table("event_candidates").
+		Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+		Joins("JOIN events ON events.id = event_candidates.published_event_id").
+		Where("flyers.public_image_url IS NOT NULL AND events.moderation_state = ?", EventStateApproved)
+}
+
+// EventsWithImages returns which of the given events have a public flyer image
+func EventsWithImages(db *gorm.DB, eventIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	withImages := make(map[uuid.UUID]bool)
+	if len(eventIDs) == 0 {
+		return withImages, nil
+	}
+
+	var ids []uuid.UUID
+	if err := eventImageFlyers(db).
+		Where("event_candidates.published_event_id IN ?", eventIDs).
+		Distinct("event_candidates.published_event_id").
+		Pluck("event_candidates.published_event_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		withImages[id] = true
+	}
+	return withImages, nil
+}
+
+// EventImageFile resolves the local file to serve for an event's image. Only the redacted
+// public image is ever used; when several flyers back the event, the most recently
+// redacted one wins. Thumbnails are derived from it on first request.
+func EventImageFile(db *gorm.DB, storage *StorageService, eventID uuid.UUID, size string) (string, error) {
+	var flyer models.Flyer
+	err := eventImageFlyers(db).
+		Select("flyers.*").
+		Where("event_candidates.published_event_id = ?", eventID).
+		Order("flyers.redacted_at DESC").
+		Take(&flyer).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrEventImageUnavailable
+		}
+		return "", err
+	}
+
+	filename := path.Base(*flyer.PublicImageURL)
+	fullPath, err := storage.Fetch(flyer.SubmissionID, filename)
+	if err != nil {
+		return "", ErrEventImageUnavailable
+	}
+	// Local storage hands back the path without checking it; a purge leaves nothing there
+	if _, err := os.Stat(fullPath); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrEventImageUnavailable
+		}
+		return "", err
+	}
+	if size != EventImageThumb {
+		return fullPath, nil
+	}
+
+	// Thumbnails are named after the redacted file, so a new redaction gets a new thumbnail.
+	// Each instance makes its own rather than storing them.
+	thumbPath := storage.GetFilePath(flyer.SubmissionID, "thumb_"+filename)
+	if _, err := os.Stat(thumbPath); err == nil {
+		return thumbPath, nil
+	}
+	full, err := decodeImageFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if err := writeJPEG(thumbPath, downscale(full, thumbMaxDimension)); err != nil {
+		return "", err
+	}
+	return thumbPath, nil
+}
+
+// downscale shrinks img so its longer side is at most maxDim, averaging each source
+// block into one pixel. Smaller images are returned unchanged.
+func downscale(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxDim && h <= maxDim {
+		return img
+	}
+
+	dw, dh := maxDim, h*maxDim/w
+	if h > w {
+		dw, dh = w*maxDim/h, maxDim
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := bounds.Min.Y+y*h/dh, bounds.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := bounds.Min.X+x*w/dw, bounds.Min.X+(x+1)*w/dw
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+// expectEventImageFlyer expects the lookup of an event's image flyer, answering with one
+// flyer whose public image is publicFile, or with none when publicFile is ""
+func expectEventImageFlyer(mock sqlmock.Sqlmock, eventID, submissionID uuid.UUID, publicFile string) {
+	rows := sqlmock.NewRows([]string{"id", "submission_id", "public_image_url", "crop_image_url", "redacted_at"})
+	if publicFile != "" {
+		rows.AddRow(uuid.New().String(), submissionID.String(),
+			"https://api.example.com/files/"+submissionID.String()+"/"+publicFile,
+			"https://api.example.com/admin/flyers/x/crop", time.Now())
+	}
+	mock.ExpectQuery(`SELECT flyers.* FROM "event_candidates" JOIN flyers ON flyers.id = event_candidates.flyer_id `+
+		`JOIN events ON events.id = event_candidates.published_event_id `+
+		`WHERE (flyers.public_image_url IS NOT NULL AND events.moderation_state = $1) AND event_candidates.published_event_id = $2 `+
+		`ORDER BY flyers.redacted_at DESC`).
+		WithArgs(EventStateApproved, eventID, testdb.Any).
+		WillReturnRows(rows)
+}
+
+func TestEventImageFile(t *testing.T) {
+	storage := NewStorageService(&config.Config{UploadDir: t.TempDir()})
+	submissionID := uuid.New()
+
+	// The redacted image is public; the unredacted crop sits in the private directory
+	if err := writeJPEG(storage.GetFilePath(submissionID, "redacted_latest.jpg"), image.NewRGBA(image.Rect(0, 0, 800, 600))); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJPEG(storage.GetPrivateFilePath(submissionID, "crop_flyer.jpg"), image.NewRGBA(image.Rect(0, 0, 800, 600))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		publicFile string
+		size       string
+		wantFile   string // "" expects ErrEventImageUnavailable
+		wantBounds image.Point
+	}{
+		{"full size is the redacted image", "redacted_latest.jpg", EventImageFull, "redacted_latest.jpg", image.Pt(800, 600)},
+		{"thumbnail of the redacted image", "redacted_latest.jpg", EventImageThumb, "thumb_redacted_latest.jpg", image.Pt(320, 240)},
+		{"purged original", "redacted_purged.jpg", EventImageFull, "", image.Point{}},
+		{"purged original, thumbnail", "redacted_purged.jpg", EventImageThumb, "", image.Point{}},
+		{"no redacted flyer", "", EventImageFull, "", image.Point{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+			expectEventImageFlyer(mock, eventID, submissionID, tt.publicFile)
+
+			path, err := EventImageFile(db, storage, eventID, tt.size)
+			if tt.wantFile == "" {
+				if !errors.Is(err, ErrEventImageUnavailable) {
+					t.Errorf("EventImageFile() = %q, %v; want ErrEventImageUnavailable", path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EventImageFile() error = %v", err)
+			}
+			if path != storage.GetFilePath(submissionID, tt.wantFile) {
+				t.Errorf("EventImageFile() = %q, want the public %s", path, tt.wantFile)
+			}
+			img, err := decodeImageFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := img.Bounds().Size(); got != tt.wantBounds {
+				t.Errorf("image is %v, want %v", got, tt.wantBounds)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(storage.GetUploadDir(), submissionID.String(), "thumb_crop_flyer.jpg")); !os.IsNotExist(err) {
+		t.Errorf("a thumbnail of the unredacted crop was made public (stat error %v)", err)
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	tests := []struct {
+		w, h int
+		want image.Point
+	}{
+		{100, 80, image.Pt(100, 80)},
+		{320, 320, image.Pt(320, 320)},
+		{1000, 500, image.Pt(320, 160)},
+		{500, 1000, image.Pt(160, 320)},
+		{5000, 4, image.Pt(320, 1)},
+	}
+
+	for _, tt := range tests {
+		if got := downscale(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), thumbMaxDimension).Bounds().Size(); got != tt.want {
+			t.Errorf("downscale(%dx%d) = %v, want %v", tt.w, tt.h, got, tt.want)
+		}
+	}
+}
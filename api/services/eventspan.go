@@ -0,0 +1,74 @@
+package services
+
+import (
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"gorm.io/gorm"
+)
+
+// defaultEventDuration is assumed when an event has no end time; see ConfigureEventDuration
+var defaultEventDuration = 2 * time.Hour
+
+// ConfigureEventDuration sets the duration assumed for events without an end time from
+// DEFAULT_EVENT_DURATION_MIN. Call once at startup, before serving requests.
+func ConfigureEventDuration(cfg *config.Config) {
+	if cfg.DefaultEventDurationMin > 0 {
+		defaultEventDuration = time.Duration(cfg.DefaultEventDurationMin) * time.Minute
+	}
+}
+
+// DefaultEventDuration is the duration assumed when an event has no end time
+func DefaultEventDuration() time.Duration {
+	return defaultEventDuration
+}
+
+// eventEndSQL is an event's effective end in SQL: end_ts, or start_ts plus duration (seconds,
+// a placeholder or bind name) when unset. table is the events table or its alias.
+func eventEndSQL(table, duration string) string {
+	return "COALESCE(" + table + ".end_ts, " + table + ".start_ts + make_interval(secs => " + duration + "))"
+}
+
+// EventOverlapSQL is WhereEventOverlaps for raw queries. It binds @start, @end (NULL for
+// open-ended), and @default_duration, which takes DefaultEventDurationSeconds.
+func EventOverlapSQL(table string) string {
+	return "(" + table + ".start_ts >= @start OR " + eventEndSQL(table, "@default_duration") + " > @start)" +
+		" AND (@end::timestamptz IS NULL OR " + table + ".start_ts < @end::timestamptz)"
+}
+
+// DefaultEventDurationSeconds is DefaultEventDuration as bound into SQL
+func DefaultEventDurationSeconds() float64 {
+	return defaultEventDuration.Seconds()
+}
+
+// WhereEventOverlaps restricts an events query to events that overlap [from, until): they
+// start before until and are still running at from; an event that ends at or before it
+// starts still matches when its start is in range. A nil bound is open. This is the one
+// time filter every listing, feed, and export uses, so an event that runs past midnight or
+// across several days shows up on each day it covers, and one that is underway still
+// counts as upcoming.
+func WhereEventOverlaps(query *gorm.DB, from, until *time.Time) *gorm.DB {
+	if from != nil {
+		query = query.Where("(events.start_ts >= ? OR "+eventEndSQL("events", "?")+" > ?)", *from, DefaultEventDurationSeconds(), *from)
+	}
+	if until != nil {
+		query = query.Where("events.start_ts < ?", *until)
+	}
+	return query
+}
+
+// EventOverlaps is WhereEventOverlaps for an event already loaded
+func EventOverlaps(start time.Time, end *time.Time, from, until *time.Time) bool {
+	if from != nil && start.Before(*from) && !EventEnd(start, end).After(*from) {
+		return false
+	}
+	if until != nil && !start.Before(*until) {
+		return false
+	}
+	return true
+}
+
+// EventHappeningAt reports whether an event has started and not yet ended at t
+func EventHappeningAt(start time.Time, end *time.Time, t time.Time) bool {
+	return !start.After(t) && EventEnd(start, end).After(t)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+// chicagoDay is the [midnight, next midnight) window of a local date in America/Chicago,
+// which is 23 hours long when the clocks spring forward and 25 when they fall back
+func chicagoDay(t *testing.T, year int, month time.Month, day int) (from, until time.Time) {
+	t.Helper()
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from = time.Date(year, month, day, 0, 0, 0, 0, chicago)
+	return from, from.AddDate(0, 0, 1)
+}
+
+func TestEventOverlaps(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, chicago)
+	}
+
+	tests := []struct {
+		name  string
+		start time.Time
+		end   *time.Time
+		day   [3]int // year, month, day of the local window
+		want  bool
+	}{
+		// A show from 10pm to 1am belongs to both days
+		{"late show on its start day", at(6, 12, 22, 0), timePtr(at(6, 13, 1, 0)), [3]int{2026, 6, 12}, true},
+		{"late show on the day it ends", at(6, 12, 22, 0), timePtr(at(6, 13, 1, 0)), [3]int{2026, 6, 13}, true},
+		{"late show not on the day after", at(6, 12, 22, 0), timePtr(at(6, 13, 1, 0)), [3]int{2026, 6, 14}, false},
+		{"show ending exactly at midnight stays on its day", at(6, 12, 20, 0), timePtr(at(6, 13, 0, 0)), [3]int{2026, 6, 13}, false},
+		{"event starting exactly at midnight is on the new day", at(6, 13, 0, 0), timePtr(at(6, 13, 2, 0)), [3]int{2026, 6, 12}, false},
+
+		// A three-day festival is on every day it covers
+		{"festival first day", at(7, 10, 12, 0), timePtr(at(7, 12, 22, 0)), [3]int{2026, 7, 10}, true},
+		{"festival underway", at(7, 10, 12, 0), timePtr(at(7, 12, 22, 0)), [3]int{2026, 7, 11}, true},
+		{"festival last day", at(7, 10, 12, 0), timePtr(at(7, 12, 22, 0)), [3]int{2026, 7, 12}, true},
+		{"festival over", at(7, 10, 12, 0), timePtr(at(7, 12, 22, 0)), [3]int{2026, 7, 13}, false},
+
+		// Without an end the default duration decides
+		{"no end, 11pm start runs past midnight", at(6, 12, 23, 0), nil, [3]int{2026, 6, 13}, true},
+		{"no end, 9pm start is over by midnight", at(6, 12, 21, 0), nil, [3]int{2026, 6, 13}, false},
+		{"end before start still matches its start day", at(6, 12, 20, 0), timePtr(at(6, 12, 19, 0)), [3]int{2026, 6, 12}, true},
+
+		// Spring forward: 2am doesn't exist on March 8, so the local day is 23 hours
+		{"spring forward, show over the gap", at(3, 7, 23, 0), timePtr(at(3, 8, 3, 30)), [3]int{2026, 3, 8}, true},
+		{"spring forward, last hour of the short day", at(3, 8, 23, 30), timePtr(at(3, 9, 0, 30)), [3]int{2026, 3, 8}, true},
+		{"spring forward, next midnight is not the short day", at(3, 9, 0, 0), timePtr(at(3, 9, 1, 0)), [3]int{2026, 3, 8}, false},
+
+		// Fall back: 1am happens twice on November 1, so the local day is 25 hours
+		{"fall back, first 1:30am", time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC), nil, [3]int{2026, 11, 1}, true},
+		{"fall back, second 1:30am", time.Date(2026, 11, 1, 7, 30, 0, 0, time.UTC), nil, [3]int{2026, 11, 1}, true},
+		{"fall back, 11:30pm of the long day", at(11, 1, 23, 30), nil, [3]int{2026, 11, 1}, true},
+		{"fall back, late show from the night before", at(10, 31, 23, 0), timePtr(at(11, 1, 1, 30)), [3]int{2026, 11, 1}, true},
+		{"fall back, next midnight", at(11, 2, 0, 0), nil, [3]int{2026, 11, 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, until := chicagoDay(t, tt.day[0], time.Month(tt.day[1]), tt.day[2])
+			if got := EventOverlaps(tt.start, tt.end, &from, &until); got != tt.want {
+				t.Errorf("EventOverlaps(%v to %v, %v) = %v, want %v", tt.start, tt.end, from.Format("2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChicagoDayLengthsAroundDST(t *testing.T) {
+	tests := []struct {
+		month time.Month
+		day   int
+		want  time.Duration
+	}{
+		{time.March, 7, 24 * time.Hour},
+		{time.March, 8, 23 * time.Hour},
+		{time.November, 1, 25 * time.Hour},
+		{time.November, 2, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		from, until := chicagoDay(t, 2026, tt.month, tt.day)
+		if got := until.Sub(from); got != tt.want {
+			t.Errorf("%s %d is %v long, want %v", tt.month, tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestEventOverlapsOpenBounds(t *testing.T) {
+	now := time.Date(2026, 6, 12, 22, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		start time.Time
+		end   *time.Time
+		from  *time.Time
+		until *time.Time
+		want  bool
+	}{
+		{"no bounds", now, nil, nil, nil, true},
+		{"underway counts as upcoming", now.Add(-time.Hour), timePtr(now.Add(time.Hour)), &now, nil, true},
+		{"ended is not upcoming", now.Add(-3 * time.Hour), timePtr(now.Add(-time.Hour)), &now, nil, false},
+		{"ending right now is not upcoming", now.Add(-time.Hour), &now, &now, nil, false},
+		{"starting right now is upcoming", now, nil, &now, nil, true},
+		{"before an open-ended until", now.Add(-48 * time.Hour), nil, nil, &now, true},
+		{"at an until", now, nil, nil, &now, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EventOverlaps(tt.start, tt.end, tt.from, tt.until); got != tt.want {
+				t.Errorf("EventOverlaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventHappeningAt(t *testing.T) {
+	start := time.Date(2026, 6, 12, 22, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+
+	tests := []struct {
+		name string
+		end  *time.Time
+		at   time.Time
+		want bool
+	}{
+		{"before it starts", &end, start.Add(-time.Minute), false},
+		{"as it starts", &end, start, true},
+		{"after midnight", &end, start.Add(150 * time.Minute), true},
+		{"as it ends", &end, end, false},
+		{"no end, within the default duration", nil, start.Add(DefaultEventDuration() - time.Minute), true},
+		{"no end, after the default duration", nil, start.Add(DefaultEventDuration()), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EventHappeningAt(start, tt.end, tt.at); got != tt.want {
+				t.Errorf("EventHappeningAt(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureEventDuration(t *testing.T) {
+	t.Cleanup(func() { defaultEventDuration = 2 * time.Hour })
+	start := time.Date(2026, 6, 12, 23, 0, 0, 0, time.UTC)
+	from := time.Date(2026, 6, 13, 1, 30, 0, 0, time.UTC)
+
+	ConfigureEventDuration(&config.Config{})
+	if DefaultEventDuration() != 2*time.Hour || EventOverlaps(start, nil, &from, nil) {
+		t.Errorf("unset config: duration %v, want 2h ending before %v", DefaultEventDuration(), from)
+	}
+
+	ConfigureEventDuration(&config.Config{DefaultEventDurationMin: 180})
+	if DefaultEventDuration() != 3*time.Hour || DefaultEventDurationSeconds() != 10800 || !EventOverlaps(start, nil, &from, nil) {
+		t.Errorf("180 minutes: duration %v, want 3h running past %v", DefaultEventDuration(), from)
+	}
+}
+
+func TestWhereEventOverlaps(t *testing.T) {
+	from, until := chicagoDay(t, 2026, 3, 8)
+
+	tests := []struct {
+		name  string
+		from  *time.Time
+		until *time.Time
+		where string
+		args  []driver.Value
+	}{
+		{"both bounds", &from, &until,
+			`WHERE ((events.start_ts >= $1 OR COALESCE(events.end_ts, events.start_ts + make_interval(secs => $2)) > $3)) AND events.start_ts < $4`,
+			[]driver.Value{from, 7200.0, from, until}},
+		{"from only", &from, nil,
+			`WHERE (events.start_ts >= $1 OR COALESCE(events.end_ts, events.start_ts + make_interval(secs => $2)) > $3)`,
+			[]driver.Value{from, 7200.0, from}},
+		{"until only", nil, &until, `WHERE events.start_ts < $1`, []driver.Value{until}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			mock.ExpectQuery(`SELECT * FROM "events" ` + tt.where).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+			var events []models.Event
+			if err := WhereEventOverlaps(db.Model(&models.Event{}), tt.from, tt.until).Find(&events).Error; err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidVisionExperiment is returned for malformed VISION_EXPERIMENT_* settings
+	ErrInvalidVisionExperiment = errors.New("invalid vision experiment")
+	// ErrExperimentNotFound is returned for an experiment no submission was assigned to
+	ErrExperimentNotFound = errors.New("experiment not found")
+)
+
+// VisionVariant is one arm of a vision experiment: a model (optionally provider-prefixed,
+// see SplitVisionModel) and its share of traffic
+type VisionVariant struct {
+	Name   string `json:"name"`
+	Model  string `json:"model"`
+	Weight int    `json:"weight"`
+}
+
+// VisionExperiment splits submissions between vision variants by weight
+type VisionExperiment struct {
+	Name     string          `json:"name"`
+	Variants []VisionVariant `json:"variants"`
+}
+
+// ModelPrice is a model's USD price per million prompt and completion tokens
+type ModelPrice struct {
+	PromptPerMillion     float64 `json:"prompt_per_million"`
+	CompletionPerMillion float64 `json:"completion_per_million"`
+}
+
+// activeVisionExperiment is the running experiment, or nil when every submission uses
+// DefaultVisionModel; set once at startup by LoadVisionExperiment
+var activeVisionExperiment *VisionExperiment
+
+// modelPrices holds VISION_MODEL_PRICES, keyed by model
+var modelPrices = map[string]ModelPrice{}
+
+// ActiveVisionExperiment returns the running experiment, or nil
+func ActiveVisionExperiment() *VisionExperiment {
+	return activeVisionExperiment
+}
+
+// LoadVisionExperiment parses VISION_EXPERIMENT, its variants, and the model price list.
+// Call once at startup, before any extraction runs. An empty name turns the experiment off.
+func LoadVisionExperiment(cfg *config.Config) error {
+	prices, err := ParseModelPrices(cfg.VisionModelPrices)
+	if err != nil {
+		return err
+	}
+	modelPrices = prices
+
+	if cfg.VisionExperiment == "" {
+		activeVisionExperiment = nil
+		return nil
+	}
+	experiment, err := ParseVisionExperiment(cfg.VisionExperiment, cfg.VisionExperimentVariants)
+	if err != nil {
+		return err
+	}
+	for _, variant := range experiment.Variants {
+		if provider, _ := SplitVisionModel(variant.Model, cfg.VisionProvider); !visionProviderConfigured(cfg, provider) {
+			return fmt.Errorf("%w: variant %q needs the %s provider, which has no API key", ErrInvalidVisionExperiment, variant.Name, provider)
+		}
+	}
+	activeVisionExperiment = experiment
+	return nil
+}
+
+// ParseVisionExperiment reads variants written as name=model:weight
+func ParseVisionExperiment(name string, specs []string) (*VisionExperiment, error) {
+	if len(specs) < 2 {
+		return nil, fmt.Errorf("%w: %s needs at least two variants", ErrInvalidVisionExperiment, name)
+	}
+
+	experiment := &VisionExperiment{Name: name}
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		variantName, rest, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: variant %q is not name=model:weight", ErrInvalidVisionExperiment, spec)
+		}
+		model, weightStr, ok := strings.Cut(rest, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: variant %q is not name=model:weight", ErrInvalidVisionExperiment, spec)
+		}
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("%w: variant %q has an invalid weight", ErrInvalidVisionExperiment, spec)
+		}
+		variantName, model = strings.TrimSpace(variantName), strings.TrimSpace(model)
+		if variantName == "" || model == "" {
+			return nil, fmt.Errorf("%w: variant %q is not name=model:weight", ErrInvalidVisionExperiment, spec)
+		}
+		if seen[variantName] {
+			return nil, fmt.Errorf("%w: duplicate variant %q", ErrInvalidVisionExperiment, variantName)
+		}
+		seen[variantName] = true
+
+		experiment.Variants = append(experiment.Variants, VisionVariant{Name: variantName, Model: model, Weight: weight})
+	}
+
+	if experiment.totalWeight() == 0 {
+		return nil, fmt.Errorf("%w: %s has no traffic", ErrInvalidVisionExperiment, name)
+	}
+	return experiment, nil
+}
+
+// ParseModelPrices reads prices written as model=prompt/completion (USD per million tokens)
+func ParseModelPrices(specs []string) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice, len(specs))
+	for _, spec := range specs {
+		model, rest, ok := strings.Cut(spec, "=")
+		promptStr, completionStr, ok2 := strings.Cut(rest, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%w: price %q is not model=prompt/completion", ErrInvalidVisionExperiment, spec)
+		}
+		prompt, err := strconv.ParseFloat(promptStr, 64)
+		if err != nil || prompt < 0 {
+			return nil, fmt.Errorf("%w: price %q has an invalid prompt price", ErrInvalidVisionExperiment, spec)
+		}
+		completion, err := strconv.ParseFloat(completionStr, 64)
+		if err != nil || completion < 0 {
+			return nil, fmt.Errorf("%w: price %q has an invalid completion price", ErrInvalidVisionExperiment, spec)
+		}
+		prices[strings.TrimSpace(model)] = ModelPrice{PromptPerMillion: prompt, CompletionPerMillion: completion}
+	}
+	return prices, nil
+}
+
+func (e *VisionExperiment) totalWeight() int {
+	total := 0
+	for _, variant := range e.Variants {
+		total += variant.Weight
+	}
+	return total
+}
+
+// Variant returns the named variant, if the experiment still has it
+func (e *VisionExperiment) Variant(name string) (VisionVariant, bool) {
+	for _, variant := range e.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return VisionVariant{}, false
+}
+
+// Assign picks a variant from a hash of the experiment name and submission ID, so the
+// same submission always lands in the same variant while the weights are unchanged
+func (e *VisionExperiment) Assign(submissionID uuid.UUID) VisionVariant {
+	hash := fnv.New32a()
+	hash.Write([]byte(e.Name))
+	hash.Write(submissionID[:])
+	bucket := int(hash.Sum32() % uint32(e.totalWeight()))
+
+	for _, variant := range e.Variants {
+		if bucket < variant.Weight {
+			return variant
+		}
+		bucket -= variant.Weight
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// ResolveVisionModel returns the model to extract a submission with. While an experiment
+// runs, the submission keeps the variant it was first assigned (recorded on the submission),
+// so retries and re-extraction use the same model. Without one, DefaultVisionModel is used.
+func ResolveVisionModel(db *gorm.DB, cfg *config.Config, submissionID uuid.UUID) (string, error) {
+	experiment := ActiveVisionExperiment()
+	if experiment == nil {
+		return DefaultVisionModel(cfg), nil
+	}
+
+	var submission models.Submission
+	if err := db.Select("id", "experiment_name", "experiment_variant").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return "", fmt.Errorf("failed to load submission: %w", err)
+	}
+	if submission.ExperimentName != nil && *submission.ExperimentName == experiment.Name && submission.ExperimentVariant != nil {
+		if variant, ok := experiment.Variant(*submission.ExperimentVariant); ok {
+			return variant.Model, nil
+		}
+	}
+
+	variant := experiment.Assign(submissionID)
+	if err := db.Model(&models.Submission{}).Where("id = ?", submissionID).Updates(map[string]interface{}{
+		"experiment_name":    experiment.Name,
+		"experiment_variant": variant.Name,
+	}).Error; err != nil {
+		return "", fmt.Errorf("failed to record experiment assignment: %w", err)
+	}
+	return variant.Model, nil
+}
+
+// RecordVisionUsage adds a vision call's token usage to the submission's running totals
+// and notes which model served it (see RecordServedModel)
+func RecordVisionUsage(db *gorm.DB, submissionID uuid.UUID, usage VisionUsage) error {
+	updates := map[string]interface{}{
+		"vision_model":         usage.Model,
+		"vision_input_tokens":  gorm.Expr("COALESCE(vision_input_tokens, 0) + ?", usage.PromptTokens),
+		"vision_output_tokens": gorm.Expr("COALESCE(vision_output_tokens, 0) + ?", usage.CompletionTokens),
+	}
+	if usage.ServedModel != "" {
+		updates["vision_served_model"] = usage.ServedModel
+	}
+	if err := db.Model(&models.Submission{}).Where("id = ?", submissionID).Updates(updates).Error; err != nil {
+		return err
+	}
+	return RecordServedModel(db, ModelPurposeVision, usage.Model, usage.ServedModel, usage.SystemFingerprint)
+}
+
+// VariantOutcome aggregates what one variant's submissions produced
+type VariantOutcome struct {
+	Variant          string   `json:"variant"`
+	Model            string   `json:"model,omitempty"` // empty once the variant is no longer configured
+	Submissions      int64    `json:"submissions"`
+	Candidates       int64    `json:"candidates"`
+	CandidatesPerSub float64  `json:"candidates_per_submission"`
+	AutoPublished    int64    `json:"auto_published"`
+	AutoPublishRate  float64  `json:"auto_publish_rate"`
+	Corrections      int64    `json:"corrections"`    // admin edits to candidate fields
+	CorrectedRate    float64  `json:"corrected_rate"` // share of candidates edited at least once
+	PromptTokens     int64    `json:"prompt_tokens"`
+	CompletionTokens int64    `json:"completion_tokens"`
+	CostUSD          *float64 `json:"cost_usd"` // nil when a model used has no configured price
+	CostPerCandidate *float64 `json:"cost_per_candidate"`
+}
+
+// experimentSubmissionRow is the scan target for per-variant, per-model submission totals
+type experimentSubmissionRow struct {
+	Variant          string
+	Model            string
+	Submissions      int64
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// experimentCandidateRow is the scan target for per-variant candidate outcomes
+type experimentCandidateRow struct {
+	Variant             string
+	Candidates          int64
+	AutoPublished       int64
+	Corrections         int64
+	CorrectedCandidates int64
+}
+
+// candidateOutcomeColumns counts candidates, auto-publishes, and admin corrections (from the
+// audit log) for a grouped event_candidates query; bind candidateOutcomeArgs after it
+const candidateOutcomeColumns = `COUNT(*) AS candidates,
+	COUNT(*) FILTER (WHERE EXISTS (
+		SELECT 1 FROM audit_logs a WHERE a.entity_id = event_candidates.id
+		AND a.action = ? AND a.actor_type = ?)) AS auto_published,
+	COALESCE(SUM((SELECT COUNT(*) FROM audit_logs a WHERE a.entity_id = event_candidates.id
+		AND a.action = ?)), 0) AS corrections,
+	COUNT(*) FILTER (WHERE EXISTS (
+		SELECT 1 FROM audit_logs a WHERE a.entity_id = event_candidates.id
+		AND a.action = ?)) AS corrected_candidates`
+
+func candidateOutcomeArgs() []interface{} {
+	return []interface{}{AuditActionCandidatePublished, ActorAuto, AuditActionCandidateEdited, AuditActionCandidateEdited}
+}
+
+// ComputeExperimentOutcomes aggregates outcomes per variant for every submission assigned
+// to the named experiment, in the configured variant order when it is still running
+func ComputeExperimentOutcomes(db *gorm.DB, name string) ([]VariantOutcome, error) {
+	var submissionRows []experimentSubmissionRow
+	if err := db.Table("submissions").
+		Select(`experiment_variant AS variant, COALESCE(vision_model, '') AS model, COUNT(*) AS submissions,
+			COALESCE(SUM(vision_input_tokens), 0) AS prompt_tokens,
+			COALESCE(SUM(vision_output_tokens), 0) AS completion_tokens`).
+		Where("experiment_name = ?", name).
+		Group("1, 2").
+		Scan(&submissionRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count experiment submissions: %w", err)
+	}
+	if len(submissionRows) == 0 {
+		return nil, ErrExperimentNotFound
+	}
+
+	// Auto-publish decisions and admin edits come from the audit log
+	var candidateRows []experimentCandidateRow
+	if err := db.Table("event_candidates").
+		Select("submissions.experiment_variant AS variant, "+candidateOutcomeColumns, candidateOutcomeArgs()...).
+		Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+		Joins("JOIN submissions ON submissions.id = flyers.submission_id").
+		Where("submissions.experiment_name = ?", name).
+		Group("1").
+		Scan(&candidateRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count experiment candidates: %w", err)
+	}
+
+	var experiment *VisionExperiment
+	if active := ActiveVisionExperiment(); active != nil && active.Name == name {
+		experiment = active
+	}
+	return aggregateExperimentOutcomes(experiment, submissionRows, candidateRows), nil
+}
+
+// aggregateExperimentOutcomes folds per-model rows onto variants and derives rates and costs
+func aggregateExperimentOutcomes(experiment *VisionExperiment, submissionRows []experimentSubmissionRow, candidateRows []experimentCandidateRow) []VariantOutcome {
+	byVariant := make(map[string]*VariantOutcome)
+	var order []string
+	outcome := func(name string) *VariantOutcome {
+		if o, ok := byVariant[name]; ok {
+			return o
+		}
+		o := &VariantOutcome{Variant: name, CostUSD: new(float64)}
+		byVariant[name] = o
+		order = append(order, name)
+		return o
+	}
+	if experiment != nil {
+		for _, variant := range experiment.Variants {
+			outcome(variant.Name).Model = variant.Model
+		}
+	}
+
+	for _, row := range submissionRows {
+		o := outcome(row.Variant)
+		o.Submissions += row.Submissions
+		o.PromptTokens += row.PromptTokens
+		o.CompletionTokens += row.CompletionTokens
+		if row.PromptTokens == 0 && row.CompletionTokens == 0 {
+			continue
+		}
+		price, ok := modelPrices[row.Model]
+		if !ok || o.CostUSD == nil {
+			o.CostUSD = nil
+			continue
+		}
+		*o.CostUSD += (float64(row.PromptTokens)*price.PromptPerMillion + float64(row.CompletionTokens)*price.CompletionPerMillion) / 1e6
+	}
+	for _, row := range candidateRows {
+		o := outcome(row.Variant)
+		o.Candidates = row.Candidates
+		o.AutoPublished = row.AutoPublished
+		o.Corrections = row.Corrections
+		if row.Candidates > 0 {
+			o.AutoPublishRate = float64(row.AutoPublished) / float64(row.Candidates)
+			o.CorrectedRate = float64(row.CorrectedCandidates) / float64(row.Candidates)
+		}
+	}
+
+	outcomes := make([]VariantOutcome, 0, len(order))
+	for _, name := range order {
+		o := byVariant[name]
+		if o.Submissions > 0 {
+			o.CandidatesPerSub = float64(o.Candidates) / float64(o.Submissions)
+		}
+		if o.CostUSD != nil && o.Candidates > 0 {
+			perCandidate := *o.CostUSD / float64(o.Candidates)
+			o.CostPerCandidate = &perCandidate
+		}
+		outcomes = append(outcomes, *o)
+	}
+	return outcomes
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// Namespaces used by the syndication feeds
+const (
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+	georssNamespace = "http://www.georss.org/georss"
+)
+
+// EventFeed renders events as RSS 2.0 or Atom 1.0 for feed readers
+type EventFeed struct {
+	Title    string
+	Author   string         // the Atom feed's author, the app name
+	BaseURL  string         // PUBLIC_BASE_URL; entries link to the event's API URL under it
+	SelfURL  string         // the feed's own URL
+	Location *time.Location // zone for the start times in entry descriptions
+	Updated  time.Time      // the latest event update; zero means now
+}
+
+// MaxEventFeedItems bounds the RSS and Atom feeds
+const MaxEventFeedItems = 100
+
+// EventFeedLastModified is when a feed of these events last changed: the latest of their
+// updated_at and the newest change-feed entry, so an event leaving the feed also counts.
+// Truncated to seconds, the precision of Last-Modified.
+func EventFeedLastModified(db *gorm.DB, events []models.Event) (time.Time, error) {
+	// MAX is NULL while the change feed is empty
+	var latestChange sql.NullTime
+	if err := db.Model(&models.EventChange{}).Select("MAX(created_at)").Scan(&latestChange).Error; err != nil {
+		return time.Time{}, fmt.Errorf("failed to read latest change: %w", err)
+	}
+
+	var modified time.Time
+	if latestChange.Valid {
+		modified = latestChange.Time
+	}
+	for i := range events {
+		if events[i].UpdatedAt.After(modified) {
+			modified = events[i].UpdatedAt
+		}
+	}
+	return modified.UTC().Truncate(time.Second), nil
+}
+
+// EventURL is the public API URL of an event, used as its link in feeds
+func EventURL(baseURL string, eventID uuid.UUID) string {
+	return fmt.Sprintf("%s/v1/events/%s", baseURL, eventID)
+}
+
+// eventFeedID is an event's stable feed identifier; like the ICS UID it comes from the ID alone
+func eventFeedID(eventID uuid.UUID) string {
+	return "urn:uuid:" + eventID.String()
+}
+
+// rssDocument is an RSS 2.0 document. Element names carry their namespace prefix literally
+// because encoding/xml can't declare prefixes itself.
+type rssDocument struct {
+	XMLName  xml.Name   `xml:"rss"`
+	Version  string     `xml:"version,attr"`
+	AtomNS   string     `xml:"xmlns:atom,attr"`
+	GeoRSSNS string     `xml:"xmlns:georss,attr"`
+	Channel  rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	GUID        rssGUID `xml:"guid"`
+	Point       string  `xml:"georss:point,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// atomFeed is an RFC 4287 feed document
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	NS       string      `xml:"xmlns,attr"`
+	GeoRSSNS string      `xml:"xmlns:georss,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   atomAuthor  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published"`
+	Link      atomLink `xml:"link"`
+	Summary   atomText `xml:"summary"`
+	Point     string   `xml:"georss:point,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// RenderRSS returns the events as an RSS 2.0 channel, one item per event
+func (f EventFeed) RenderRSS(events []models.Event) ([]byte, error) {
+	doc := rssDocument{
+		Version:  "2.0",
+		AtomNS:   atomNamespace,
+		GeoRSSNS: georssNamespace,
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.BaseURL,
+			Description:   f.Title,
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+			SelfLink:      atomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+			Items:         make([]rssItem, 0, len(events)),
+		},
+	}
+	for i := range events {
+		event := &events[i]
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       event.Title,
+			Link:        EventURL(f.BaseURL, event.ID),
+			Description: f.describe(event),
+			PubDate:     event.CreatedAt.Format(time.RFC1123Z),
+			GUID:        rssGUID{IsPermaLink: "false", Value: eventFeedID(event.ID)},
+			Point:       georssPoint(event.Venue),
+		})
+	}
+	return marshalFeed(doc)
+}
+
+// RenderAtom returns the events as an Atom 1.0 feed, one entry per event
+func (f EventFeed) RenderAtom(events []models.Event) ([]byte, error) {
+	feed := atomFeed{
+		NS:       atomNamespace,
+		GeoRSSNS: georssNamespace,
+		ID:       f.SelfURL,
+		Title:    f.Title,
+		Updated:  f.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.BaseURL, Rel: "alternate"},
+		},
+		Author:  atomAuthor{Name: f.Author},
+		Entries: make([]atomEntry, 0, len(events)),
+	}
+	for i := range events {
+		event := &events[i]
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        eventFeedID(event.ID),
+			Title:     event.Title,
+			Updated:   event.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: event.CreatedAt.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: EventURL(f.BaseURL, event.ID), Rel: "alternate"},
+			Summary:   atomText{Type: "text", Value: f.describe(event)},
+			Point:     georssPoint(event.Venue),
+		})
+	}
+	return marshalFeed(feed)
+}
+
+func (f EventFeed) updated() time.Time {
+	if f.Updated.IsZero() {
+		return time.Now().UTC()
+	}
+	return f.Updated.UTC()
+}
+
+// describe is an entry's plain-text body: when and where, then the event's description
+func (f EventFeed) describe(event *models.Event) string {
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	parts := []string{event.StartTs.In(loc).Format("Mon Jan 2, 2006 3:04 PM MST")}
+	if event.Venue != nil {
+		where := event.Venue.Name
+		if event.Venue.AddressLine != nil && *event.Venue.AddressLine != "" {
+			where += ", " + *event.Venue.AddressLine
+		}
+		parts = append(parts, where)
+	}
+	summary := strings.Join(parts, " at ")
+	if event.Description != nil && strings.TrimSpace(*event.Description) != "" {
+		summary += "\n\n" + strings.TrimSpace(*event.Description)
+	}
+	return summary
+}
+
+// georssPoint is a venue's location as a GeoRSS "lat lng" point, or "" when it has none
+func georssPoint(venue *models.Venue) string {
+	if venue == nil || venue.Location == nil {
+		return ""
+	}
+	lng, lat, err := ParsePoint(*venue.Location)
+	if err != nil {
+		log.Printf("Venue %s has an unreadable location: %v", venue.ID, err)
+		return ""
+	}
+	return fmt.Sprintf("%.6f %.6f", lat, lng)
+}
+
+func marshalFeed(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render feed: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
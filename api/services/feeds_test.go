@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestEventFeedLastModified(t *testing.T) {
+	changed := time.Date(2026, 5, 20, 12, 0, 0, 500, time.UTC)
+	edited := time.Date(2026, 5, 21, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		latestChange interface{}
+		events       []models.Event
+		want         time.Time
+	}{
+		{"empty change feed, no events", nil, nil, time.Time{}},
+		{"empty change feed", nil, []models.Event{{UpdatedAt: edited}}, edited},
+		{"change is newest", changed, []models.Event{{UpdatedAt: changed.Add(-time.Hour)}}, changed.Truncate(time.Second)},
+		{"event is newest", changed, []models.Event{{UpdatedAt: edited}}, edited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			mock.ExpectQuery(`SELECT MAX(created_at) FROM "event_changes"`).
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(tt.latestChange))
+
+			got, err := EventFeedLastModified(db, tt.events)
+			if err != nil {
+				t.Fatalf("EventFeedLastModified() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("EventFeedLastModified() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+// Field types a deployment can declare for extracted fields
+const (
+	FieldTypeString   = "string"   // single line of text
+	FieldTypeText     = "text"     // multi-line text
+	FieldTypeDateTime = "datetime" // date/time text, ISO when the model can parse it
+	FieldTypeURL      = "url"      // http(s) link
+	FieldTypeURLList  = "url_list" // list of http(s) links
+	FieldTypeNumber   = "number"
+	FieldTypeBool     = "bool"
+)
+
+var validFieldTypes = map[string]bool{
+	FieldTypeString:   true,
+	FieldTypeText:     true,
+	FieldTypeDateTime: true,
+	FieldTypeURL:      true,
+	FieldTypeURLList:  true,
+	FieldTypeNumber:   true,
+	FieldTypeBool:     true,
+}
+
+// builtinFieldTypes are the fields with dedicated EventFields members and event columns.
+// Any other declared field is an extra: kept on the candidate and, when public, copied to
+// the event's attributes.
+var builtinFieldTypes = map[string]string{
+	"title":               FieldTypeString,
+	"date_time":           FieldTypeDateTime,
+	"start_time":          FieldTypeDateTime,
+	"end_time":            FieldTypeDateTime,
+	"venue":               FieldTypeString,
+	"address":             FieldTypeString,
+	"price":               FieldTypeString,
+	"description":         FieldTypeText,
+	"organizer":           FieldTypeString,
+	"url":                 FieldTypeURL,
+	"ticket_url":          FieldTypeURL,
+	"contact_info":        FieldTypeString,
+	"category":            FieldTypeString,
+	"age_restriction":     FieldTypeString,
+	"qr_urls":             FieldTypeURLList,
+	"details_online_only": FieldTypeBool,
+}
+
+// ErrInvalidFieldSchema is returned when a field definition file can't be used
+var ErrInvalidFieldSchema = errors.New("invalid extraction field schema")
+
+// FieldDefinition describes one field the vision model extracts
+type FieldDefinition struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// PromptHint is added to the prompt guidelines; CropPromptHint overrides it for
+	// single-flyer re-extraction
+	PromptHint     string `json:"prompt_hint,omitempty"`
+	CropPromptHint string `json:"crop_prompt_hint,omitempty"`
+	// FocusLabel names the field in the prompt's "Focus on extracting" list
+	FocusLabel string `json:"focus_label,omitempty"`
+	// Example is the JSON value shown for the field in the prompt's sample output
+	Example   json.RawMessage `json:"example,omitempty"`
+	MaxLength int             `json:"max_length,omitempty"`
+	// Required fields must be present before a candidate can be published
+	Required bool `json:"required"`
+	// Public extra fields are copied to the published event's attributes
+	Public bool `json:"public"`
+}
+
+// FieldSchema is the ordered set of fields a deployment extracts
+type FieldSchema struct {
+	Fields []FieldDefinition `json:"fields"`
+}
+
+// DefaultFieldSchema is the event board's field set
+func DefaultFieldSchema() *FieldSchema {
+	return &FieldSchema{Fields: []FieldDefinition{
+		{Name: "title", Type: FieldTypeString, FocusLabel: "title", Example: json.RawMessage(`"Summer Music Festival"`), Required: true, Public: true},
+		{Name: "date_time", Type: FieldTypeDateTime, FocusLabel: "date/time", Example: json.RawMessage(`"2024-07-15T19:00:00"`), Public: true},
+		{Name: "start_time", Type: FieldTypeDateTime, Public: true},
+		{Name: "end_time", Type: FieldTypeDateTime, Public: true},
+		{Name: "venue", Type: FieldTypeString, FocusLabel: "venue/location", Example: json.RawMessage(`"Central Park"`), Public: true},
+		{Name: "address", Type: FieldTypeString, Example: json.RawMessage(`"123 Main St, City, ST 12345"`), Public: true},
+		{
+			Name:           "price",
+			Type:           FieldTypeString,
+			PromptHint:     "Copy the price text with every tier it lists (advance/door, member, student, sliding scale, pay what you can); put a ticket purchase link in ticket_url",
+			CropPromptHint: "Copy the price text with every tier it lists and put a ticket purchase link in ticket_url",
+			FocusLabel:     "price tiers",
+			Example:        json.RawMessage(`"$20 adv / $25 door"`),
+			Public:         true,
+		},
+		{Name: "ticket_url", Type: FieldTypeURL, FocusLabel: "ticket links", Example: json.RawMessage(`"https://example.com/tickets"`), Public: true},
+		{Name: "description", Type: FieldTypeText, FocusLabel: "description", Example: json.RawMessage(`"Live music and food trucks"`), Public: true},
+		{Name: "organizer", Type: FieldTypeString, FocusLabel: "organizer", Example: json.RawMessage(`"Music Society"`), Public: true},
+		{Name: "url", Type: FieldTypeURL, Public: true},
+		{Name: "contact_info", Type: FieldTypeString, FocusLabel: "contact info"},
+		{Name: "category", Type: FieldTypeString, FocusLabel: "category", Example: json.RawMessage(`"music"`), Public: true},
+		{Name: "age_restriction", Type: FieldTypeString, Public: true},
+		{
+			Name:           "qr_urls",
+			Type:           FieldTypeURLList,
+			PromptHint:     "If a flyer shows a QR code, decode it when legible and list the URL in qr_urls; omit qr_urls if none can be read",
+			CropPromptHint: "Decode legible QR codes into qr_urls",
+			FocusLabel:     "QR code links",
+			Example:        json.RawMessage(`["https://example.com/tickets"]`),
+		},
+		{
+			Name:       "details_online_only",
+			Type:       FieldTypeBool,
+			PromptHint: "Set details_online_only to true when the flyer gives no date or venue and only points to a website, link-in-bio, or QR code",
+			Example:    json.RawMessage(`false`),
+		},
+	}}
+}
+
+// activeFieldSchema is the schema in use; replaced once at startup by LoadFieldSchema
+var activeFieldSchema = DefaultFieldSchema()
+
+// ActiveFieldSchema returns the deployment's field schema
+func ActiveFieldSchema() *FieldSchema {
+	return activeFieldSchema
+}
+
+// LoadFieldSchema reads EXTRACTION_FIELDS_FILE, if set, and makes it the active schema.
+// Call once at startup, before any extraction runs.
+func LoadFieldSchema(cfg *config.Config) error {
+	if cfg.ExtractionFieldsFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(cfg.ExtractionFieldsFile)
+	if err != nil {
+		return fmt.Errorf("failed to read extraction fields file: %w", err)
+	}
+
+	var schema FieldSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFieldSchema, err)
+	}
+	if err := schema.Validate(); err != nil {
+		return err
+	}
+
+	activeFieldSchema = &schema
+	return nil
+}
+
+// Validate checks names, types, and examples. Title is always required because
+// publishing and deduplication key on it.
+func (s *FieldSchema) Validate() error {
+	seen := make(map[string]bool, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.Name == "" {
+			return fmt.Errorf("%w: field without a name", ErrInvalidFieldSchema)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("%w: duplicate field %q", ErrInvalidFieldSchema, field.Name)
+		}
+		seen[field.Name] = true
+
+		if !validFieldTypes[field.Type] {
+			return fmt.Errorf("%w: field %q has unknown type %q", ErrInvalidFieldSchema, field.Name, field.Type)
+		}
+		if builtin, ok := builtinFieldTypes[field.Name]; ok && builtin != field.Type {
+			return fmt.Errorf("%w: built-in field %q must have type %q", ErrInvalidFieldSchema, field.Name, builtin)
+		}
+		if len(field.Example) > 0 && !json.Valid(field.Example) {
+			return fmt.Errorf("%w: field %q has an example that is not JSON", ErrInvalidFieldSchema, field.Name)
+		}
+	}
+
+	title, ok := s.Field("title")
+	if !ok || !title.Required {
+		return fmt.Errorf("%w: title must be defined and required", ErrInvalidFieldSchema)
+	}
+	return nil
+}
+
+// Field looks up a field definition by name
+func (s *FieldSchema) Field(name string) (FieldDefinition, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return FieldDefinition{}, false
+}
+
+// IsBuiltinField reports whether a field has a dedicated EventFields member
+func IsBuiltinField(name string) bool {
+	_, ok := builtinFieldTypes[name]
+	return ok
+}
+
+// promptExample renders the fields of the prompt's sample output, one per line
+func (s *FieldSchema) promptExample(indent string) string {
+	lines := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		if len(field.Example) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s%q: %s", indent, field.Name, field.Example))
+	}
+	return strings.Join(lines, ",\n")
+}
+
+// promptHints returns the per-field guideline lines, using crop hints when crop is set
+func (s *FieldSchema) promptHints(crop bool) string {
+	var b strings.Builder
+	for _, field := range s.Fields {
+		hint := field.PromptHint
+		if crop && field.CropPromptHint != "" {
+			hint = field.CropPromptHint
+		}
+		if hint != "" {
+			b.WriteString("\n- " + hint)
+		}
+	}
+	return b.String()
+}
+
+// promptFocus lists the focus labels, or "" when none are set
+func (s *FieldSchema) promptFocus() string {
+	labels := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.FocusLabel != "" {
+			labels = append(labels, field.FocusLabel)
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// ApplyToEventFields sanitizes extracted fields and drops anything the schema doesn't declare
+func (s *FieldSchema) ApplyToEventFields(fields *EventFields) {
+	SanitizeEventFields(fields)
+	for name := range builtinFieldTypes {
+		if _, ok := s.Field(name); !ok {
+			clearBuiltinField(fields, name)
+		}
+	}
+
+	for name, value := range fields.Extra {
+		field, ok := s.Field(name)
+		if !ok || IsBuiltinField(name) {
+			delete(fields.Extra, name)
+			continue
+		}
+		cleaned := sanitizeFieldValue(field, value)
+		if cleaned == nil {
+			delete(fields.Extra, name)
+			continue
+		}
+		fields.Extra[name] = cleaned
+	}
+	if len(fields.Extra) == 0 {
+		fields.Extra = nil
+	}
+}
+
+// ApplyToFieldMap sanitizes a loosely-typed fields map: built-in fields the schema
+// doesn't declare are removed and extra fields are cleaned according to their type.
+// Keys that are neither built-in nor declared (e.g. enrichment bookkeeping) are left alone.
+func (s *FieldSchema) ApplyToFieldMap(fields map[string]interface{}) {
+	SanitizeFieldMap(fields)
+	for name, value := range fields {
+		field, declared := s.Field(name)
+		if IsBuiltinField(name) {
+			if !declared {
+				delete(fields, name)
+			}
+			continue
+		}
+		if !declared {
+			continue
+		}
+		cleaned := sanitizeFieldValue(field, value)
+		if cleaned == nil {
+			delete(fields, name)
+			continue
+		}
+		fields[name] = cleaned
+	}
+}
+
+// ValidateFieldUpdate checks a partial fields update against the schema, returning the
+// cleaned values (nil marks a field to clear). Unknown field names are rejected.
+func (s *FieldSchema) ValidateFieldUpdate(update map[string]interface{}) (map[string]interface{}, error) {
+	cleaned := make(map[string]interface{}, len(update))
+	for name, value := range update {
+		field, ok := s.Field(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if value == nil {
+			if field.Required {
+				return nil, fmt.Errorf("field %q is required", name)
+			}
+			cleaned[name] = nil
+			continue
+		}
+		result := sanitizeFieldValue(field, value)
+		if result == nil {
+			if field.Required {
+				return nil, fmt.Errorf("field %q is required", name)
+			}
+			return nil, fmt.Errorf("field %q is not a valid %s", name, field.Type)
+		}
+		cleaned[name] = result
+	}
+	return cleaned, nil
+}
+
+// MissingRequired lists required fields with no value
+func (s *FieldSchema) MissingRequired(fields map[string]interface{}) []string {
+	var missing []string
+	for _, field := range s.Fields {
+		if !field.Required {
+			continue
+		}
+		if value, ok := fields[field.Name]; !ok || value == nil || value == "" {
+			missing = append(missing, field.Name)
+		}
+	}
+	return missing
+}
+
+// PublicAttributes returns the public extra fields to store on a published event,
+// or nil when there are none
+func (s *FieldSchema) PublicAttributes(fields map[string]interface{}) map[string]interface{} {
+	var attributes map[string]interface{}
+	for _, field := range s.Fields {
+		if !field.Public || IsBuiltinField(field.Name) {
+			continue
+		}
+		value, ok := fields[field.Name]
+		if !ok || value == nil {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]interface{})
+		}
+		attributes[field.Name] = value
+	}
+	return attributes
+}
+
+// sanitizeFieldValue cleans a value according to its declared type, returning nil
+// when nothing usable is left
+func sanitizeFieldValue(field FieldDefinition, value interface{}) interface{} {
+	limit := field.MaxLength
+	if limit <= 0 {
+		limit = defaultFieldLimit
+	}
+
+	switch field.Type {
+	case FieldTypeString, FieldTypeText, FieldTypeDateTime, FieldTypeURL:
+		str, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		cleaned := SanitizeText(str, limit, field.Type == FieldTypeText)
+		if field.Type == FieldTypeURL {
+			cleaned = sanitizeURL(cleaned)
+		}
+		if cleaned == "" {
+			return nil
+		}
+		return cleaned
+	case FieldTypeURLList:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		urls := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if str, ok := item.(string); ok {
+				if cleaned := sanitizeURL(SanitizeText(str, limit, false)); cleaned != "" {
+					urls = append(urls, cleaned)
+				}
+			}
+		}
+		if len(urls) == 0 {
+			return nil
+		}
+		return urls
+	case FieldTypeNumber:
+		if number, ok := value.(float64); ok {
+			return number
+		}
+		return nil
+	case FieldTypeBool:
+		if flag, ok := value.(bool); ok {
+			return flag
+		}
+		return nil
+	}
+	return nil
+}
+
+// clearBuiltinField empties a built-in field the active schema doesn't extract
+func clearBuiltinField(fields *EventFields, name string) {
+	switch name {
+	case "title":
+		fields.Title = ""
+	case "date_time":
+		fields.DateTime = nil
+	case "start_time":
+		fields.StartTime = nil
+	case "end_time":
+		fields.EndTime = nil
+	case "venue":
+		fields.Venue = nil
+	case "address":
+		fields.Address = nil
+	case "price":
+		fields.Price = nil
+	case "description":
+		fields.Description = nil
+	case "organizer":
+		fields.Organizer = nil
+	case "url":
+		fields.URL = nil
+	case "ticket_url":
+		fields.TicketURL = nil
+	case "contact_info":
+		fields.ContactInfo = nil
+	case "category":
+		fields.Category = nil
+	case "age_restriction":
+		fields.AgeRestriction = nil
+	case "qr_urls":
+		fields.QRCodeURLs = nil
+	case "details_online_only":
+		fields.DetailsOnlineOnly = false
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// assertGolden compares got with testdata/name, rewriting the file under -update
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file; run with -update if the change is intended\ngot:\n%s", name, got)
+	}
+}
+
+func TestDefaultSchemaPromptsGolden(t *testing.T) {
+	if ActiveFieldSchema() != activeFieldSchema || !reflect.DeepEqual(activeFieldSchema, DefaultFieldSchema()) {
+		t.Fatal("tests must run with the default field schema")
+	}
+
+	assertGolden(t, "analysis_prompt.golden", defaultAnalysisPrompt())
+	assertGolden(t, "single_flyer_prompt.golden", (&VisionService{}).createSingleFlyerPrompt())
+}
+
+// lostAndFoundSchema is a deployment that has no use for prices or organizers
+func lostAndFoundSchema() *FieldSchema {
+	return &FieldSchema{Fields: []FieldDefinition{
+		{Name: "title", Type: FieldTypeString, FocusLabel: "item", Example: json.RawMessage(`"Black umbrella"`), Required: true, Public: true},
+		{Name: "date_time", Type: FieldTypeDateTime, FocusLabel: "date lost", Public: true},
+		{Name: "item_description", Type: FieldTypeText, PromptHint: "Describe the item as the poster does", FocusLabel: "item description", Example: json.RawMessage(`"Folding, wooden handle"`), Required: true, Public: true},
+		{Name: "reward", Type: FieldTypeNumber, FocusLabel: "reward", Example: json.RawMessage(`20`), Public: true},
+		{Name: "contact_info", Type: FieldTypeString},
+		{Name: "finder_notes", Type: FieldTypeText},
+	}}
+}
+
+func TestFieldSchemaDrivesPrompt(t *testing.T) {
+	schema := lostAndFoundSchema()
+
+	example := schema.promptExample("  ")
+	for _, want := range []string{`"title": "Black umbrella"`, `"item_description": "Folding, wooden handle"`, `"reward": 20`} {
+		if !strings.Contains(example, want) {
+			t.Errorf("prompt example missing %s:\n%s", want, example)
+		}
+	}
+	if strings.Contains(example, "price") || strings.Contains(example, "organizer") {
+		t.Errorf("prompt example mentions undeclared fields:\n%s", example)
+	}
+	if got, want := schema.promptFocus(), "item, date lost, item description, reward"; got != want {
+		t.Errorf("promptFocus() = %q, want %q", got, want)
+	}
+	if got, want := schema.promptHints(false), "\n- Describe the item as the poster does"; got != want {
+		t.Errorf("promptHints() = %q, want %q", got, want)
+	}
+}
+
+func TestFieldSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []FieldDefinition
+		wantErr bool
+	}{
+		{"default", DefaultFieldSchema().Fields, false},
+		{"lost and found", lostAndFoundSchema().Fields, false},
+		{"no title", []FieldDefinition{{Name: "reward", Type: FieldTypeNumber}}, true},
+		{"optional title", []FieldDefinition{{Name: "title", Type: FieldTypeString}}, true},
+		{"unnamed field", []FieldDefinition{{Name: "title", Type: FieldTypeString, Required: true}, {Type: FieldTypeString}}, true},
+		{"duplicate field", []FieldDefinition{{Name: "title", Type: FieldTypeString, Required: true}, {Name: "title", Type: FieldTypeString}}, true},
+		{"unknown type", []FieldDefinition{{Name: "title", Type: FieldTypeString, Required: true}, {Name: "reward", Type: "money"}}, true},
+		{"retyped built-in", []FieldDefinition{{Name: "title", Type: FieldTypeString, Required: true}, {Name: "price", Type: FieldTypeNumber}}, true},
+		{"example not JSON", []FieldDefinition{{Name: "title", Type: FieldTypeString, Required: true, Example: json.RawMessage(`Umbrella`)}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&FieldSchema{Fields: tt.fields}).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidFieldSchema) {
+				t.Errorf("Validate() error = %v, want ErrInvalidFieldSchema", err)
+			}
+		})
+	}
+}
+
+func TestFieldSchemaSanitizesExtraction(t *testing.T) {
+	schema := lostAndFoundSchema()
+	price, organizer, contact := "$5", "Lost Property Office", "555-0100"
+	fields := EventFields{
+		Title:       "Black umbrella",
+		Price:       &price,
+		Organizer:   &organizer,
+		ContactInfo: &contact,
+		Extra: map[string]interface{}{
+			"item_description": "Folding\x00, wooden handle",
+			"reward":           20.0,
+			"finder_notes":     42.0, // wrong type
+			"secret":           "not declared",
+		},
+	}
+
+	schema.ApplyToEventFields(&fields)
+
+	if fields.Price != nil || fields.Organizer != nil {
+		t.Errorf("undeclared built-ins kept: price %v, organizer %v", fields.Price, fields.Organizer)
+	}
+	if fields.ContactInfo == nil || *fields.ContactInfo != contact {
+		t.Errorf("declared built-in dropped: contact_info %v", fields.ContactInfo)
+	}
+	want := map[string]interface{}{"item_description": "Folding, wooden handle", "reward": 20.0}
+	if !reflect.DeepEqual(fields.Extra, want) {
+		t.Errorf("Extra = %v, want %v", fields.Extra, want)
+	}
+}
+
+func TestFieldSchemaFieldMaps(t *testing.T) {
+	schema := lostAndFoundSchema()
+	fields := map[string]interface{}{
+		"title":            "Black umbrella",
+		"price":            "$5",
+		"item_description": "Folding",
+		"reward":           "twenty",
+		"enriched_from":    "https://example.com",
+	}
+
+	schema.ApplyToFieldMap(fields)
+	want := map[string]interface{}{
+		"title":            "Black umbrella",
+		"item_description": "Folding",
+		"enriched_from":    "https://example.com",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("ApplyToFieldMap() = %v, want %v", fields, want)
+	}
+
+	if missing := schema.MissingRequired(map[string]interface{}{"title": "Umbrella", "item_description": ""}); !reflect.DeepEqual(missing, []string{"item_description"}) {
+		t.Errorf("MissingRequired() = %v, want [item_description]", missing)
+	}
+
+	attributes := schema.PublicAttributes(map[string]interface{}{"title": "Umbrella", "reward": 20.0, "finder_notes": "under the bench"})
+	if !reflect.DeepEqual(attributes, map[string]interface{}{"reward": 20.0}) {
+		t.Errorf("PublicAttributes() = %v, want only the public extra field", attributes)
+	}
+	if attributes := DefaultFieldSchema().PublicAttributes(map[string]interface{}{"title": "Open Mic", "price": "$5"}); attributes != nil {
+		t.Errorf("default schema PublicAttributes() = %v, want nil so events serialize as before", attributes)
+	}
+}
+
+func TestValidateFieldUpdate(t *testing.T) {
+	schema := lostAndFoundSchema()
+
+	tests := []struct {
+		name    string
+		update  map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"edit an extra field", map[string]interface{}{"reward": 50.0}, map[string]interface{}{"reward": 50.0}, false},
+		{"clear an optional field", map[string]interface{}{"reward": nil}, map[string]interface{}{"reward": nil}, false},
+		{"clear a required field", map[string]interface{}{"title": nil}, nil, true},
+		{"blank a required field", map[string]interface{}{"item_description": "  "}, nil, true},
+		{"wrong type", map[string]interface{}{"reward": "fifty"}, nil, true},
+		{"undeclared field", map[string]interface{}{"price": "$5"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := schema.ValidateFieldUpdate(tt.update)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateFieldUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateFieldUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ValidFlagTypes are the reports the public can file against a published event
+var ValidFlagTypes = []string{"spam", "inappropriate", "duplicate", "wrong_location"}
+
+var (
+	// ErrInvalidFlagType is returned for flag types outside ValidFlagTypes
+	ErrInvalidFlagType = errors.New("invalid flag type")
+	// ErrFlagLimitExceeded is returned when a client has flagged an event too often today
+	ErrFlagLimitExceeded = errors.New("flag limit exceeded")
+)
+
+// maxFlagReasonLength bounds the free-text reason kept on a flag
+const maxFlagReasonLength = 1000
+
+// IsValidFlagType reports whether flagType is one of ValidFlagTypes
+func IsValidFlagType(flagType string) bool {
+	for _, valid := range ValidFlagTypes {
+		if flagType == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// FlagResult reports a filed flag and whether it sent the event back for review
+type FlagResult struct {
+	Flag            *models.Flag
+	PulledForReview bool
+}
+
+// FlagEvent files a public report against an approved event. Each client IP may flag an
+// event at most limit times in any 24 hours (0 disables the limit). Once flags from
+// threshold distinct networks have accumulated since the event was last approved, it is
+// moved back to pending, which hides it until an admin re-approves or unpublishes it
+// (0 disables the threshold). Unknown and unpublished events return ErrEventNotFound.
+func FlagEvent(db *gorm.DB, eventID uuid.UUID, flagType, reason, reporterIP string, limit, threshold int, actor Actor) (*FlagResult, error) {
+	if !IsValidFlagType(flagType) {
+		return nil, ErrInvalidFlagType
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxFlagReasonLength {
+		reason = reason[:maxFlagReasonLength]
+	}
+
+	flag := models.Flag{
+		EventID:   eventID,
+		FlagType:  flagType,
+		Reason:    optionalString(reason),
+		Status:    FlagStatusPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	if reporterIP != "" {
+		flag.ReporterIP = &reporterIP
+	}
+	result := &FlagResult{Flag: &flag}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Locking the event serializes its flags, so the counts below hold
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "moderation_state").
+			First(&event, "id = ?", eventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventNotFound
+			}
+			return err
+		}
+		if event.ModerationState != EventStateApproved {
+			return ErrEventNotFound
+		}
+
+		if limit > 0 && flag.ReporterIP != nil {
+			var recent int64
+			if err := tx.Model(&models.Flag{}).
+				Where("event_id = ? AND reporter_ip = ? AND created_at > ?", eventID, reporterIP, flag.CreatedAt.Add(-24*time.Hour)).
+				Count(&recent).Error; err != nil {
+				return fmt.Errorf("failed to count recent flags: %w", err)
+			}
+			if recent >= int64(limit) {
+				return ErrFlagLimitExceeded
+			}
+		}
+
+		if err := tx.Omit(clause.Associations).Create(&flag).Error; err != nil {
+			return fmt.Errorf("failed to record flag: %w", err)
+		}
+		if err := RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityEvent,
+			EntityID:   eventID,
+			Action:     AuditActionEventFlagged,
+			Actor:      actor,
+			Metadata: map[string]interface{}{
+				"flag_id":   flag.ID,
+				"flag_type": flagType,
+				"reason":    reason,
+			},
+		}); err != nil {
+			return err
+		}
+
+		if threshold <= 0 {
+			return nil
+		}
+		distinct, err := distinctFlagNetworksSinceApproval(tx, eventID)
+		if err != nil {
+			return err
+		}
+		if distinct < int64(threshold) {
+			return nil
+		}
+
+		if err := TransitionEventState(tx, eventID, EventTransition{
+			To:     EventStatePending,
+			Actor:  ActorSystem,
+			Reason: TransitionReasonUserFlags,
+			FlagID: &flag.ID,
+		}); err != nil {
+			return err
+		}
+		if err := RecordEventHistoryByID(tx, eventID); err != nil {
+			return err
+		}
+		if err := RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityEvent,
+			EntityID:   eventID,
+			Action:     AuditActionEventFlagThreshold,
+			Actor:      SystemActor(ActorSystem),
+			Changes: map[string]interface{}{
+				"moderation_state": map[string]string{"from": EventStateApproved, "to": EventStatePending},
+			},
+			Metadata: map[string]interface{}{
+				"flag_id":           flag.ID,
+				"distinct_networks": distinct,
+				"threshold":         threshold,
+			},
+		}); err != nil {
+			return err
+		}
+		result.PulledForReview = true
+		return RecordEventChange(tx, eventID, ChangeTypeDeleted, ChangeReasonFlagged)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// flagNetwork groups reporter IPs by network (IPv4 /24, IPv6 /48), so one client cycling
+// through the addresses it controls counts once toward the review threshold
+const flagNetwork = "network(set_masklen(reporter_ip, CASE WHEN family(reporter_ip) = 4 THEN 24 ELSE 48 END))"
+
+// distinctFlagNetworksSinceApproval counts the distinct networks with pending public flags
+// on an event filed since it last became approved, so flags an admin already saw don't
+// count again
+func distinctFlagNetworksSinceApproval(tx *gorm.DB, eventID uuid.UUID) (int64, error) {
+	// MAX is NULL for an event with no approval on record
+	var approvedAt sql.NullTime
+	if err := tx.Model(&models.EventStateTransition{}).
+		Where("event_id = ? AND to_state = ?", eventID, EventStateApproved).
+		Select("MAX(created_at)").
+		Scan(&approvedAt).Error; err != nil {
+		return 0, fmt.Errorf("failed to find last approval: %w", err)
+	}
+
+	query := tx.Model(&models.Flag{}).
+		Where("event_id = ? AND status = ? AND flag_type IN ?", eventID, FlagStatusPending, ValidFlagTypes)
+	if approvedAt.Valid {
+		query = query.Where("created_at > ?", approvedAt.Time)
+	}
+	var distinct int64
+	if err := query.Select("COUNT(DISTINCT " + flagNetwork + ")").Scan(&distinct).Error; err != nil {
+		return 0, fmt.Errorf("failed to count flagging networks: %w", err)
+	}
+	return distinct, nil
+}
+
+// Actions an admin can take on a public flag
+const (
+	FlagActionDismiss   = "dismiss"
+	FlagActionUnpublish = "unpublish"
+	// FlagActionRemoveEvent is another name for FlagActionUnpublish
+	FlagActionRemoveEvent = "remove_event"
+)
+
+var (
+	// ErrFlagNotFound is returned when a public flag does not exist
+	ErrFlagNotFound = errors.New("flag not found")
+	// ErrFlagResolved is returned when resolving a flag that was already decided
+	ErrFlagResolved = errors.New("flag already resolved")
+	// ErrInvalidFlagAction is returned for actions other than dismiss, unpublish, and remove_event
+	ErrInvalidFlagAction = errors.New("invalid flag action")
+)
+
+// flagUnpublishReasons maps a flag type to the unpublish reason recorded when it is upheld
+var flagUnpublishReasons = map[string]string{
+	"spam":           "spam",
+	"inappropriate":  "inappropriate",
+	"duplicate":      "duplicate",
+	"wrong_location": "bad_location",
+}
+
+// FlagSummary is one pending public flag, for the review queue
+type FlagSummary struct {
+	ID        uuid.UUID `json:"id"`
+	EventID   uuid.UUID `json:"-"`
+	FlagType  string    `json:"flag_type"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FlaggedEvent is an event with pending public flags, and those flags oldest first
+type FlaggedEvent struct {
+	EventID     uuid.UUID      `json:"event_id"`
+	EventTitle  string         `json:"event_title"`
+	EventState  string         `json:"event_state"`
+	FlagCount   int            `json:"flag_count"`
+	DistinctIPs int            `json:"distinct_ips"`
+	TypeCounts  map[string]int `json:"type_counts"`
+	Flags       []FlagSummary  `json:"flags"`
+}
+
+// maxPendingFlags bounds the flags loaded for the review queue
+const maxPendingFlags = 1000
+
+// ListPendingFlags returns pending public flags grouped by event, most-flagged events first
+func ListPendingFlags(db *gorm.DB) ([]FlaggedEvent, error) {
+	var rows []struct {
+		FlagSummary
+		EventTitle string
+		EventState string
+		ReporterIP *string
+	}
+	if err := db.Table("flags").
+		Select(`flags.id, flags.event_id, flags.flag_type, COALESCE(flags.reason, '') AS reason,
+			flags.created_at, host(flags.reporter_ip) AS reporter_ip,
+			events.title AS event_title, events.moderation_state AS event_state`).
+		Joins("JOIN events ON events.id = flags.event_id").
+		Where("flags.status = ? AND flags.flag_type IN ?", FlagStatusPending, ValidFlagTypes).
+		Order("flags.created_at ASC").
+		Limit(maxPendingFlags).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list flags: %w", err)
+	}
+
+	var groups []FlaggedEvent
+	index := make(map[uuid.UUID]int)
+	reporters := make(map[uuid.UUID]map[string]bool)
+	for _, row := range rows {
+		i, ok := index[row.EventID]
+		if !ok {
+			i = len(groups)
+			index[row.EventID] = i
+			reporters[row.EventID] = make(map[string]bool)
+			groups = append(groups, FlaggedEvent{
+				EventID:    row.EventID,
+				EventTitle: row.EventTitle,
+				EventState: row.EventState,
+				TypeCounts: make(map[string]int),
+			})
+		}
+		group := &groups[i]
+		group.Flags = append(group.Flags, row.FlagSummary)
+		group.FlagCount++
+		group.TypeCounts[row.FlagType]++
+		if row.ReporterIP != nil {
+			reporters[row.EventID][*row.ReporterIP] = true
+		}
+		group.DistinctIPs = len(reporters[row.EventID])
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].FlagCount > groups[j].FlagCount
+	})
+	return groups, nil
+}
+
+// ResolveFlag decides a pending public flag. Dismissing it marks it dismissed, and once no
+// pending flags remain on an event that flags pulled into review, the event is restored.
+// Unpublishing blocks the event (and its candidates) and resolves every pending flag on it.
+func ResolveFlag(db *gorm.DB, flagID uuid.UUID, action string, actor Actor) (*models.Flag, error) {
+	if action == FlagActionRemoveEvent {
+		action = FlagActionUnpublish
+	}
+	if action != FlagActionDismiss && action != FlagActionUnpublish {
+		return nil, ErrInvalidFlagAction
+	}
+
+	var flag models.Flag
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&flag, "id = ? AND flag_type IN ?", flagID, ValidFlagTypes).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFlagNotFound
+			}
+			return err
+		}
+		if flag.Status != FlagStatusPending {
+			return ErrFlagResolved
+		}
+
+		if action == FlagActionUnpublish {
+			if _, err := unpublishEvent(tx, flag.EventID, flagUnpublishReasons[flag.FlagType], actor, &flag.ID); err != nil {
+				return err
+			}
+			flag.Status = FlagStatusResolved
+			return tx.Model(&models.Flag{}).
+				Where("event_id = ? AND status = ? AND flag_type IN ?", flag.EventID, FlagStatusPending, ValidFlagTypes).
+				Update("status", FlagStatusResolved).Error
+		}
+
+		flag.Status = FlagStatusDismissed
+		if err := tx.Model(&flag).Update("status", flag.Status).Error; err != nil {
+			return err
+		}
+		restored, err := restoreFlaggedEvent(tx, &flag, actor)
+		if err != nil {
+			return err
+		}
+
+		entry := AuditEntry{
+			EntityType: AuditEntityEvent,
+			EntityID:   flag.EventID,
+			Action:     AuditActionEventFlagDismissed,
+			Actor:      actor,
+			Metadata: map[string]interface{}{
+				"flag_id":   flag.ID,
+				"flag_type": flag.FlagType,
+			},
+		}
+		if restored {
+			entry.Changes = map[string]interface{}{
+				"moderation_state": map[string]string{"from": EventStatePending, "to": EventStateApproved},
+			}
+		}
+		return RecordAudit(tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// restoreFlaggedEvent puts an event that flags pulled into review back in the public
+// listings once its last pending flag is dismissed, reporting whether it did. Events
+// pending for any other reason are left for the usual review.
+func restoreFlaggedEvent(tx *gorm.DB, flag *models.Flag, actor Actor) (bool, error) {
+	var remaining int64
+	if err := tx.Model(&models.Flag{}).
+		Where("event_id = ? AND status = ? AND flag_type IN ?", flag.EventID, FlagStatusPending, ValidFlagTypes).
+		Count(&remaining).Error; err != nil {
+		return false, fmt.Errorf("failed to count pending flags: %w", err)
+	}
+	if remaining > 0 {
+		return false, nil
+	}
+
+	var event models.Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "moderation_state").
+		First(&event, "id = ?", flag.EventID).Error; err != nil {
+		return false, err
+	}
+	if event.ModerationState != EventStatePending {
+		return false, nil
+	}
+	var last models.EventStateTransition
+	if err := tx.Where("event_id = ?", flag.EventID).Order("created_at DESC").
+		Limit(1).Find(&last).Error; err != nil {
+		return false, fmt.Errorf("failed to load last transition: %w", err)
+	}
+	if last.ReasonCode != TransitionReasonUserFlags {
+		return false, nil
+	}
+
+	if err := TransitionEventState(tx, flag.EventID, EventTransition{
+		To:     EventStateApproved,
+		Actor:  actor.Type,
+		Reason: TransitionReasonFlagsDismissed,
+		FlagID: &flag.ID,
+	}); err != nil {
+		return false, err
+	}
+	if err := RecordEventHistoryByID(tx, flag.EventID); err != nil {
+		return false, err
+	}
+	return true, RecordEventChange(tx, flag.EventID, ChangeTypeUpdated, ChangeReasonRepublished)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+func TestDistinctFlagNetworksSinceApproval(t *testing.T) {
+	approved := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		approvedAt interface{}
+		wantSince  bool // whether flags are limited to those after the approval
+	}{
+		{"never approved", nil, false},
+		{"re-approved", approved, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+
+			mock.ExpectQuery(`SELECT MAX(created_at) FROM "event_state_transitions"`).
+				WithArgs(eventID, EventStateApproved).
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(tt.approvedAt))
+			count := mock.ExpectQuery(`SELECT COUNT(DISTINCT network(set_masklen(reporter_ip`).WillReturnRows(testdb.Count(3))
+			args := testdb.AnyArgs(2 + len(ValidFlagTypes))
+			if tt.wantSince {
+				args = append(args, approved)
+			}
+			count.WithArgs(args...)
+
+			got, err := distinctFlagNetworksSinceApproval(db, eventID)
+			if err != nil {
+				t.Fatalf("distinctFlagNetworksSinceApproval() error = %v", err)
+			}
+			if got != 3 {
+				t.Errorf("distinctFlagNetworksSinceApproval() = %d, want 3", got)
+			}
+		})
+	}
+}
+
+func TestFlagEvent(t *testing.T) {
+	const limit, threshold = 3, 5
+
+	tests := []struct {
+		name       string
+		flagType   string
+		state      string // the event's moderation state, or "" when it doesn't exist
+		recent     int64  // flags from this IP on this event in the last day
+		networks   int64  // distinct flagging networks after this flag
+		wantPulled bool
+		wantErr    error
+	}{
+		{name: "first report", flagType: "spam", state: EventStateApproved, networks: 1},
+		{name: "one short of the threshold", flagType: "wrong_location", state: EventStateApproved, recent: 2, networks: threshold - 1},
+		{name: "threshold pulls the event for review", flagType: "inappropriate", state: EventStateApproved, networks: threshold, wantPulled: true},
+		{name: "daily limit per IP and event", flagType: "spam", state: EventStateApproved, recent: limit, wantErr: ErrFlagLimitExceeded},
+		{name: "unknown flag type", flagType: "boring", wantErr: ErrInvalidFlagType},
+		{name: "unknown event", flagType: "spam", wantErr: ErrEventNotFound},
+		{name: "event not approved", flagType: "duplicate", state: EventStatePending, wantErr: ErrEventNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+			if tt.wantErr != ErrInvalidFlagType {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"id", "moderation_state"})
+				if tt.state != "" {
+					rows.AddRow(eventID.String(), tt.state)
+				}
+				mock.ExpectQuery(`SELECT "id","moderation_state" FROM "events" WHERE id = $1`).
+					WithArgs(eventID, 1).
+					WillReturnRows(rows)
+			}
+			if tt.state == EventStateApproved {
+				mock.ExpectQuery(`SELECT count(*) FROM "flags" WHERE event_id = $1 AND reporter_ip = $2 AND created_at > $3`).
+					WithArgs(eventID, "203.0.113.7", testdb.Any).
+					WillReturnRows(testdb.Count(tt.recent))
+			}
+			if tt.wantErr != nil {
+				if tt.wantErr != ErrInvalidFlagType {
+					mock.ExpectRollback()
+				}
+			} else {
+				mock.ExpectQuery(`INSERT INTO "flags"`).WillReturnRows(testdb.IDs(uuid.New()))
+				expectAudit(mock, AuditActionEventFlagged)
+				mock.ExpectQuery(`SELECT MAX(created_at) FROM "event_state_transitions"`).
+					WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+				mock.ExpectQuery(`SELECT COUNT(DISTINCT network(set_masklen(reporter_ip`).WillReturnRows(testdb.Count(tt.networks))
+				if tt.wantPulled {
+					expectTransition(mock, eventID, EventStateApproved)
+					expectEventHistory(mock, eventID)
+					expectAudit(mock, AuditActionEventFlagThreshold)
+					expectEventChange(mock, eventID, ChangeTypeDeleted, ChangeReasonFlagged)
+				}
+				mock.ExpectCommit()
+			}
+
+			result, err := FlagEvent(db, eventID, tt.flagType, " wrong address ", "203.0.113.7", limit, threshold, Actor{Type: ActorAPI, Route: "/v1/events/:id/flag"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("FlagEvent() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FlagEvent() error = %v", err)
+			}
+			if result.PulledForReview != tt.wantPulled {
+				t.Errorf("PulledForReview = %v, want %v", result.PulledForReview, tt.wantPulled)
+			}
+			if *result.Flag.ReporterIP != "203.0.113.7" || *result.Flag.Reason != "wrong address" {
+				t.Errorf("flag = %+v, want the reporter IP and trimmed reason", result.Flag)
+			}
+		})
+	}
+}
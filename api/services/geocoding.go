@@ -6,14 +6,24 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/observability"
 )
 
 type GeocodingService struct {
 	config     *config.Config
 	httpClient *http.Client
+	hedger     *Hedger
+
+	// Nominatim's usage policy allows one request a second; the ticker spaces them and
+	// nominatimMu queues callers for it
+	nominatimMu     sync.Mutex
+	nominatimTicker *time.Ticker
 }
 
 type GeocodeResult struct {
@@ -23,9 +33,12 @@ type GeocodeResult struct {
 	Confidence       float64            `json:"confidence"`
 	Components       map[string]string  `json:"components"`
 	RawResponse      map[string]interface{} `json:"raw_response"`
+	// Approximate marks a fallback to where the photo was taken, not a geocoded address
+	Approximate bool `json:"approximate,omitempty"`
 }
 
 type MapboxFeature struct {
+	Text     string `json:"text"`
 	Geometry struct {
 		Coordinates []float64 `json:"coordinates"`
 	} `json:"geometry"`
@@ -44,29 +57,122 @@ type MapboxResponse struct {
 	Query    []string        `json:"query"`
 }
 
+// Geocoders selectable with GEOCODER
+const (
+	GeocoderMapbox     = "mapbox"
+	GeocoderGoogleMaps = "googlemaps"
+	GeocoderNominatim  = "nominatim"
+)
+
+// nominatimMinInterval is the least time between requests to a Nominatim server
+const nominatimMinInterval = time.Second
+
+// nominatimBiasDegrees is how far either side of the capture location the viewbox reaches
+const nominatimBiasDegrees = 0.25
+
+// googleGeocodeURL is the Google Maps Platform Geocoding API endpoint
+const googleGeocodeURL = "https://maps.googleapis.com/maps/api/geocode/json"
+
+// googleBiasDegrees is how far either side of the capture location Google's bounds bias reaches
+const googleBiasDegrees = 0.25
+
+// googleLocationConfidence maps Google's geometry.location_type to a confidence score
+var googleLocationConfidence = map[string]float64{
+	"ROOFTOP":            0.95,
+	"RANGE_INTERPOLATED": 0.80,
+	"GEOMETRIC_CENTER":   0.65,
+	"APPROXIMATE":        0.40,
+}
+
+type GoogleGeocodeResult struct {
+	FormattedAddress  string `json:"formatted_address"`
+	AddressComponents []struct {
+		LongName  string   `json:"long_name"`
+		ShortName string   `json:"short_name"`
+		Types     []string `json:"types"`
+	} `json:"address_components"`
+	Geometry struct {
+		Location struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"location"`
+		LocationType string `json:"location_type"`
+	} `json:"geometry"`
+	PlaceID      string `json:"place_id"`
+	PartialMatch bool   `json:"partial_match"`
+}
+
+type GoogleGeocodeResponse struct {
+	Status       string                `json:"status"`
+	ErrorMessage string                `json:"error_message"`
+	Results      []GoogleGeocodeResult `json:"results"`
+}
+
+type NominatimResult struct {
+	Lat         string            `json:"lat"`
+	Lon         string            `json:"lon"`
+	DisplayName string            `json:"display_name"`
+	Importance  float64           `json:"importance"`
+	Category    string            `json:"category"`
+	Type        string            `json:"type"`
+	PlaceRank   int               `json:"place_rank"`
+	Address     map[string]string `json:"address"`
+}
+
 func NewGeocodingService(cfg *config.Config) *GeocodingService {
-	return &GeocodingService{
+	g := &GeocodingService{
 		config:     cfg,
-		httpClient: &http.Client{},
+		httpClient: observability.HTTPClient(&http.Client{}),
+		hedger:     NewHedger(time.Duration(cfg.GeocodeHedgeDelayMS)*time.Millisecond, cfg.GeocodeHedgeMaxPerMin),
+	}
+	if cfg.Geocoder == GeocoderNominatim {
+		g.nominatimTicker = time.NewTicker(nominatimMinInterval)
 	}
+	return g
 }
 
-// GeocodeAddress converts a venue address to lat/lng coordinates
-func (g *GeocodingService) GeocodeAddress(ctx context.Context, address string) (*GeocodeResult, error) {
-	if g.config.GeocoderAPIKey == "" || g.config.GeocoderAPIKey == "your-mapbox-api-key" {
-		return g.mockGeocodeResult(address), nil
+// HedgeStats reports how often slow geocoding requests were hedged
+func (g *GeocodingService) HedgeStats() HedgeStats {
+	return g.hedger.Stats()
+}
+
+// GeocodeAddress converts a venue address to lat/lng coordinates. When near is set,
+// results close to where the photo was taken are preferred.
+func (g *GeocodingService) GeocodeAddress(ctx context.Context, address string, near *CaptureLocation) (*GeocodeResult, error) {
+	// Nominatim needs no key; Mapbox and Google fall back to mock results without one
+	if g.config.Geocoder != GeocoderNominatim {
+		if key := g.apiKey(); key == "" || key == "your-mapbox-api-key" {
+			return g.mockGeocodeResult(address), nil
+		}
 	}
 
 	switch g.config.Geocoder {
-	case "mapbox":
-		return g.geocodeWithMapbox(ctx, address)
+	case GeocoderMapbox:
+		return HedgedCall(ctx, g.hedger, func(ctx context.Context) (*GeocodeResult, error) {
+			return g.geocodeWithMapbox(ctx, address, near)
+		})
+	case GeocoderGoogleMaps:
+		return HedgedCall(ctx, g.hedger, func(ctx context.Context) (*GeocodeResult, error) {
+			return g.geocodeWithGoogleMaps(ctx, address, near)
+		})
+	case GeocoderNominatim:
+		// Not hedged: a second request would break the one-per-second usage policy
+		return g.geocodeWithNominatim(ctx, address, near)
 	default:
 		return nil, fmt.Errorf("unsupported geocoder: %s", g.config.Geocoder)
 	}
 }
 
+// apiKey returns the key of the configured geocoder
+func (g *GeocodingService) apiKey() string {
+	if g.config.Geocoder == GeocoderGoogleMaps && g.config.GoogleMapsAPIKey != "" {
+		return g.config.GoogleMapsAPIKey
+	}
+	return g.config.GeocoderAPIKey
+}
+
 // geocodeWithMapbox uses Mapbox Geocoding API
-func (g *GeocodingService) geocodeWithMapbox(ctx context.Context, address string) (*GeocodeResult, error) {
+func (g *GeocodingService) geocodeWithMapbox(ctx context.Context, address string, near *CaptureLocation) (*GeocodeResult, error) {
 	// Clean and format address
 	query := strings.TrimSpace(address)
 	if query == "" {
@@ -78,6 +184,9 @@ func (g *GeocodingService) geocodeWithMapbox(ctx context.Context, address string
 	encodedQuery := url.QueryEscape(query)
 	requestURL := fmt.Sprintf("%s%s.json?access_token=%s&limit=1&types=address,poi",
 		baseURL, encodedQuery, g.config.GeocoderAPIKey)
+	if near != nil {
+		requestURL += fmt.Sprintf("&proximity=%f,%f", near.Longitude, near.Latitude)
+	}
 
 	// Make request
 	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
@@ -92,7 +201,7 @@ func (g *GeocodingService) geocodeWithMapbox(ctx context.Context, address string
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("geocoding API returned status %d", resp.StatusCode)
+		return nil, &UpstreamStatusError{Service: "geocoding", StatusCode: resp.StatusCode}
 	}
 
 	// Parse response
@@ -156,6 +265,236 @@ func (g *GeocodingService) geocodeWithMapbox(ctx context.Context, address string
 	}, nil
 }
 
+// geocodeWithGoogleMaps uses the Google Maps Platform Geocoding API. Confidence comes from
+// how precisely Google placed the result (geometry.location_type).
+func (g *GeocodingService) geocodeWithGoogleMaps(ctx context.Context, address string, near *CaptureLocation) (*GeocodeResult, error) {
+	query := strings.TrimSpace(address)
+	if query == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+
+	params := url.Values{}
+	params.Set("address", query)
+	params.Set("key", g.apiKey())
+	if near != nil {
+		// Bounds only bias the results; addresses outside them are still returned
+		params.Set("bounds", fmt.Sprintf("%f,%f|%f,%f",
+			near.Latitude-googleBiasDegrees, near.Longitude-googleBiasDegrees,
+			near.Latitude+googleBiasDegrees, near.Longitude+googleBiasDegrees))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", googleGeocodeURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("geocoding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &UpstreamStatusError{Service: "geocoding", StatusCode: resp.StatusCode}
+	}
+
+	var googleResp GoogleGeocodeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&googleResp); err != nil {
+		return nil, fmt.Errorf("failed to parse geocoding response: %w", err)
+	}
+
+	// Google reports failures in the body with a 200; quota and server errors are transient
+	switch googleResp.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, fmt.Errorf("no geocoding results found for address: %s", address)
+	case "OVER_QUERY_LIMIT", "OVER_DAILY_LIMIT":
+		return nil, &UpstreamStatusError{Service: "geocoding", StatusCode: http.StatusTooManyRequests}
+	case "UNKNOWN_ERROR":
+		return nil, &UpstreamStatusError{Service: "geocoding", StatusCode: http.StatusServiceUnavailable}
+	default:
+		return nil, fmt.Errorf("geocoding failed: %s %s", googleResp.Status, googleResp.ErrorMessage)
+	}
+	if len(googleResp.Results) == 0 {
+		return nil, fmt.Errorf("no geocoding results found for address: %s", address)
+	}
+
+	result := googleResp.Results[0]
+
+	// Extract address components by their types
+	components := make(map[string]string)
+	for _, component := range result.AddressComponents {
+		for _, componentType := range component.Types {
+			switch componentType {
+			case "locality":
+				components["city"] = component.LongName
+			case "postal_town":
+				// UK and Sweden name the town here instead of locality
+				if components["city"] == "" {
+					components["city"] = component.LongName
+				}
+			case "administrative_area_level_1":
+				components["state"] = component.LongName
+			case "postal_code":
+				components["postal_code"] = component.LongName
+			case "country":
+				components["country"] = component.LongName
+			}
+		}
+	}
+
+	confidence, ok := googleLocationConfidence[result.Geometry.LocationType]
+	if !ok {
+		confidence = 0.5 // Default confidence for an unknown location type
+	}
+
+	formattedAddress := result.FormattedAddress
+	if formattedAddress == "" {
+		formattedAddress = address // Fall back to original
+	}
+
+	// Save raw response for debugging
+	rawResponse := make(map[string]interface{})
+	rawData, _ := json.Marshal(result)
+	json.Unmarshal(rawData, &rawResponse)
+
+	return &GeocodeResult{
+		Latitude:         result.Geometry.Location.Lat,
+		Longitude:        result.Geometry.Location.Lng,
+		FormattedAddress: formattedAddress,
+		Confidence:       confidence,
+		Components:       components,
+		RawResponse:      rawResponse,
+	}, nil
+}
+
+// geocodeWithNominatim uses a Nominatim (OpenStreetMap) server, the public one unless
+// NOMINATIM_BASE_URL points at a self-hosted instance. Requests identify themselves with
+// NOMINATIM_USER_AGENT and are spaced at least nominatimMinInterval apart, as the usage
+// policy asks. Confidence comes from the result's importance.
+func (g *GeocodingService) geocodeWithNominatim(ctx context.Context, address string, near *CaptureLocation) (*GeocodeResult, error) {
+	query := strings.TrimSpace(address)
+	if query == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	params.Set("addressdetails", "1")
+	if near != nil {
+		// Without bounded=1 the viewbox only biases the results
+		params.Set("viewbox", fmt.Sprintf("%f,%f,%f,%f",
+			near.Longitude-nominatimBiasDegrees, near.Latitude+nominatimBiasDegrees,
+			near.Longitude+nominatimBiasDegrees, near.Latitude-nominatimBiasDegrees))
+	}
+
+	baseURL := strings.TrimRight(g.config.NominatimBaseURL, "/")
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", g.config.NominatimUserAgent)
+
+	if err := g.waitNominatimTurn(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("geocoding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &UpstreamStatusError{Service: "geocoding", StatusCode: resp.StatusCode}
+	}
+
+	var results []NominatimResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("failed to parse geocoding response: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no geocoding results found for address: %s", address)
+	}
+
+	result := results[0]
+	lat, err := strconv.ParseFloat(result.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude in geocoding response: %q", result.Lat)
+	}
+	lng, err := strconv.ParseFloat(result.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude in geocoding response: %q", result.Lon)
+	}
+
+	// OSM names the place by its size; take the most specific one present
+	components := make(map[string]string)
+	for _, key := range []string{"city", "town", "village", "hamlet", "municipality"} {
+		if value := result.Address[key]; value != "" {
+			components["city"] = value
+			break
+		}
+	}
+	if state := result.Address["state"]; state != "" {
+		components["state"] = state
+	}
+	if postcode := result.Address["postcode"]; postcode != "" {
+		components["postal_code"] = postcode
+	}
+	if country := result.Address["country"]; country != "" {
+		components["country"] = country
+	}
+
+	formattedAddress := result.DisplayName
+	if formattedAddress == "" {
+		formattedAddress = address // Fall back to original
+	}
+
+	// Save raw response for debugging
+	rawResponse := make(map[string]interface{})
+	rawData, _ := json.Marshal(result)
+	json.Unmarshal(rawData, &rawResponse)
+
+	return &GeocodeResult{
+		Latitude:         lat,
+		Longitude:        lng,
+		FormattedAddress: formattedAddress,
+		Confidence:       nominatimConfidence(result.Importance),
+		Components:       components,
+		RawResponse:      rawResponse,
+	}, nil
+}
+
+// waitNominatimTurn blocks until this caller may send the next Nominatim request. Callers
+// queue on nominatimMu and each takes one tick; resetting the ticker afterwards keeps a tick
+// left over from an idle spell from letting two requests out back to back.
+func (g *GeocodingService) waitNominatimTurn(ctx context.Context) error {
+	if g.nominatimTicker == nil {
+		return nil
+	}
+
+	g.nominatimMu.Lock()
+	defer g.nominatimMu.Unlock()
+
+	select {
+	case <-g.nominatimTicker.C:
+		g.nominatimTicker.Reset(nominatimMinInterval)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nominatimConfidence maps Nominatim's importance (0 to 1, how prominent the place is) onto
+// 0.5 to 0.95. Importance says little about how exactly an address matched, so even a
+// perfect score stays below Google's ROOFTOP.
+func nominatimConfidence(importance float64) float64 {
+	importance = max(0, min(1, importance))
+	return 0.5 + importance*0.45
+}
+
 // mockGeocodeResult returns mock coordinates for testing
 func (g *GeocodingService) mockGeocodeResult(address string) *GeocodeResult {
 	// Mock coordinates for common test addresses, default to SF
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+func TestGeocodeWithGoogleMaps(t *testing.T) {
+	tests := []struct {
+		name           string
+		fixture        string // testdata/googlemaps response
+		near           *CaptureLocation
+		wantBounds     string
+		wantLat        float64
+		wantLng        float64
+		wantConfidence float64
+		wantAddress    string
+		wantComponents map[string]string
+		wantStatus     int  // UpstreamStatusError the call fails with
+		wantErr        bool // fails for good
+	}{
+		{
+			name:           "rooftop street address",
+			fixture:        "rooftop.json",
+			wantLat:        45.5226043,
+			wantLng:        -122.6565283,
+			wantConfidence: 0.95,
+			wantAddress:    "830 E Burnside St, Portland, OR 97214, USA",
+			wantComponents: map[string]string{"city": "Portland", "state": "Oregon", "postal_code": "97214", "country": "United States"},
+		},
+		{
+			name:           "interpolated address biased to the capture location",
+			fixture:        "range_interpolated.json",
+			near:           &CaptureLocation{Latitude: 45.5, Longitude: -122.65},
+			wantBounds:     "45.250000,-122.900000|45.750000,-122.400000",
+			wantLat:        45.5122339,
+			wantLng:        -122.6534108,
+			wantConfidence: 0.80,
+			wantAddress:    "1200 SE Hawthorne Blvd, Portland, OR 97214, USA",
+			wantComponents: map[string]string{"city": "Portland", "state": "Oregon", "postal_code": "97214", "country": "United States"},
+		},
+		{
+			name:           "UK postal town stands in for the city",
+			fixture:        "postal_town.json",
+			wantLat:        52.1983487,
+			wantLng:        0.1381436,
+			wantConfidence: 0.65,
+			wantAddress:    "Mill Rd, Cambridge CB1 2AD, UK",
+			wantComponents: map[string]string{"city": "Cambridge", "state": "England", "postal_code": "CB1 2AD", "country": "United Kingdom"},
+		},
+		{
+			name:           "city-level match",
+			fixture:        "approximate.json",
+			wantLat:        45.515232,
+			wantLng:        -122.6783853,
+			wantConfidence: 0.40,
+			wantAddress:    "Portland, OR, USA",
+			wantComponents: map[string]string{"city": "Portland", "state": "Oregon", "country": "United States"},
+		},
+		{name: "no results", fixture: "zero_results.json", wantErr: true},
+		{name: "quota exceeded is transient", fixture: "over_query_limit.json", wantStatus: http.StatusTooManyRequests},
+		{name: "bad key", fixture: "request_denied.json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture, err := os.ReadFile(filepath.Join("testdata", "googlemaps", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				if r.URL.Path != "/maps/api/geocode/json" || query.Get("key") != "google-key" || query.Get("address") != "830 E Burnside St, Portland" {
+					t.Errorf("request = %s, want the geocode endpoint with the address and GOOGLE_MAPS_API_KEY", r.URL)
+				}
+				if got := query.Get("bounds"); got != tt.wantBounds {
+					t.Errorf("bounds = %q, want %q", got, tt.wantBounds)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(fixture)
+			}))
+			defer server.Close()
+
+			geocoder := NewGeocodingService(&config.Config{Geocoder: GeocoderGoogleMaps, GeocoderAPIKey: "mapbox-key", GoogleMapsAPIKey: "google-key"})
+			geocoder.httpClient = &http.Client{Transport: upstreamTransport{server: server}}
+
+			result, err := geocoder.GeocodeAddress(context.Background(), " 830 E Burnside St, Portland ", tt.near)
+			var statusErr *UpstreamStatusError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+					t.Errorf("GeocodeAddress() error = %v, want upstream status %d", err, tt.wantStatus)
+				}
+				return
+			case tt.wantErr:
+				if err == nil || errors.As(err, &statusErr) {
+					t.Errorf("GeocodeAddress() error = %v, want a permanent failure", err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("GeocodeAddress() error = %v", err)
+			}
+
+			if result.Latitude != tt.wantLat || result.Longitude != tt.wantLng || result.Confidence != tt.wantConfidence {
+				t.Errorf("GeocodeAddress() = %v,%v (confidence %v), want %v,%v (%v)",
+					result.Latitude, result.Longitude, result.Confidence, tt.wantLat, tt.wantLng, tt.wantConfidence)
+			}
+			if result.FormattedAddress != tt.wantAddress {
+				t.Errorf("formatted address = %q, want %q", result.FormattedAddress, tt.wantAddress)
+			}
+			if len(result.Components) != len(tt.wantComponents) {
+				t.Errorf("components = %v, want %v", result.Components, tt.wantComponents)
+			}
+			for key, want := range tt.wantComponents {
+				if got := result.Components[key]; got != want {
+					t.Errorf("components[%s] = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrNotAPoint is returned for stored locations that aren't a 2D point
+var ErrNotAPoint = errors.New("location is not a point")
+
+// EWKB geometry type flags (PostGIS extended WKB)
+const (
+	ewkbZFlag    = 0x80000000
+	ewkbMFlag    = 0x40000000
+	ewkbSRIDFlag = 0x20000000
+	wkbPointType = 1
+)
+
+// ParsePoint reads a venue location as stored: the hex EWKB PostGIS returns for a geometry
+// column, or the WKT ("POINT(lng lat)", optionally "SRID=4326;"-prefixed) it is written as.
+// It returns longitude and latitude.
+func ParsePoint(value string) (float64, float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, ErrNotAPoint
+	}
+	if strings.HasPrefix(value, "00") || strings.HasPrefix(value, "01") {
+		return parseHexEWKBPoint(value)
+	}
+	return parseWKTPoint(value)
+}
+
+// parseWKTPoint reads POINT(x y), ignoring an SRID= prefix and any Z/M ordinates
+func parseWKTPoint(value string) (float64, float64, error) {
+	if i := strings.Index(value, ";"); i >= 0 && strings.HasPrefix(strings.ToUpper(value), "SRID=") {
+		value = value[i+1:]
+	}
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	if !strings.HasPrefix(upper, "POINT") {
+		return 0, 0, ErrNotAPoint
+	}
+	open, close := strings.Index(upper, "("), strings.LastIndex(upper, ")")
+	if open < 0 || close < open {
+		return 0, 0, fmt.Errorf("malformed WKT point %q", value)
+	}
+	ordinates := strings.Fields(upper[open+1 : close])
+	if len(ordinates) < 2 {
+		return 0, 0, fmt.Errorf("malformed WKT point %q", value)
+	}
+	lng, err := strconv.ParseFloat(ordinates[0], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed WKT point %q", value)
+	}
+	lat, err := strconv.ParseFloat(ordinates[1], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed WKT point %q", value)
+	}
+	return lng, lat, nil
+}
+
+// parseHexEWKBPoint reads a hex-encoded (E)WKB point in either byte order, with or without
+// an SRID, skipping any Z/M ordinates
+func parseHexEWKBPoint(value string) (float64, float64, error) {
+	data, err := hex.DecodeString(value)
+	if err != nil || len(data) < 5 {
+		return 0, 0, fmt.Errorf("malformed EWKB point")
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if data[0] == 1 {
+		order = binary.LittleEndian
+	}
+	geomType := order.Uint32(data[1:5])
+	offset := 5
+	if geomType&ewkbSRIDFlag != 0 {
+		offset += 4
+	}
+	// ISO WKB encodes Z/M as 1000s (1001 is POINT Z); EWKB uses the high flag bits
+	if (geomType&^(ewkbZFlag|ewkbMFlag|ewkbSRIDFlag))%1000 != wkbPointType {
+		return 0, 0, ErrNotAPoint
+	}
+	if len(data) < offset+16 {
+		return 0, 0, fmt.Errorf("malformed EWKB point")
+	}
+
+	lng := math.Float64frombits(order.Uint64(data[offset : offset+8]))
+	lat := math.Float64frombits(order.Uint64(data[offset+8 : offset+16]))
+	if math.IsNaN(lng) || math.IsNaN(lat) {
+		// PostGIS writes POINT EMPTY as NaN ordinates
+		return 0, 0, ErrNotAPoint
+	}
+	return lng, lat, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		wantLng    float64
+		wantLat    float64
+		notAPoint  bool // ErrNotAPoint rather than a malformed value
+		wantFailed bool
+	}{
+		// Written by createOrUpdateVenue
+		{name: "WKT", value: "POINT(-122.4194 37.7749)", wantLng: -122.4194, wantLat: 37.7749},
+		{name: "WKT with SRID", value: "SRID=4326;POINT(-89.65 39.78)", wantLng: -89.65, wantLat: 39.78},
+		{name: "WKT lower case with spaces", value: "  srid=4326; point ( -89.65   39.78 ) ", wantLng: -89.65, wantLat: 39.78},
+		{name: "WKT POINT Z", value: "POINT Z (2.35 48.85 35)", wantLng: 2.35, wantLat: 48.85},
+		{name: "WKT line", value: "LINESTRING(0 0, 1 1)", notAPoint: true, wantFailed: true},
+		{name: "WKT one ordinate", value: "POINT(-89.65)", wantFailed: true},
+		{name: "WKT non-numeric", value: "POINT(west north)", wantFailed: true},
+		{name: "WKT unclosed", value: "POINT -89.65 39.78", wantFailed: true},
+
+		// Read back from the geometry column
+		{name: "EWKB little-endian with SRID", value: "0101000020E610000050FC1873D79A5EC0D0D556EC2FE34240", wantLng: -122.4194, wantLat: 37.7749},
+		{name: "EWKB lower-case hex", value: "0101000020e610000050fc1873d79a5ec0d0d556ec2fe34240", wantLng: -122.4194, wantLat: 37.7749},
+		{name: "WKB big-endian without SRID", value: "0000000001C05669999999999A4043E3D70A3D70A4", wantLng: -89.65, wantLat: 39.78},
+		{name: "EWKB POINT Z", value: "01010000A0E6100000CDCCCCCCCCCC0240CDCCCCCCCC6C48400000000000804140", wantLng: 2.35, wantLat: 48.85},
+		{name: "ISO WKB POINT Z", value: "01E9030000CDCCCCCCCCCC0240CDCCCCCCCC6C48400000000000804140", wantLng: 2.35, wantLat: 48.85},
+		{name: "EWKB POINT EMPTY", value: "0101000020E6100000000000000000F87F000000000000F87F", notAPoint: true, wantFailed: true},
+		{name: "EWKB line", value: "0102000020E610000002000000", notAPoint: true, wantFailed: true},
+		{name: "EWKB truncated", value: "0101000020E610000050FC1873D79A5EC0", wantFailed: true},
+		{name: "EWKB not hex", value: "01ZZ", wantFailed: true},
+
+		{name: "empty", value: "", notAPoint: true, wantFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lng, lat, err := ParsePoint(tt.value)
+			if tt.wantFailed {
+				if err == nil {
+					t.Fatalf("ParsePoint(%q) = %v, %v; want an error", tt.value, lng, lat)
+				}
+				if errors.Is(err, ErrNotAPoint) != tt.notAPoint {
+					t.Errorf("ParsePoint(%q) error = %v, ErrNotAPoint: %v", tt.value, err, tt.notAPoint)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePoint(%q) error = %v", tt.value, err)
+			}
+			if lng != tt.wantLng || lat != tt.wantLat {
+				t.Errorf("ParsePoint(%q) = %v, %v; want %v, %v", tt.value, lng, lat, tt.wantLng, tt.wantLat)
+			}
+		})
+	}
+}
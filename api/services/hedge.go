@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Hedger races a second, identical request against one that has not answered within a
+// delay, keeping whichever succeeds first. Hedges are capped per minute so a slow
+// upstream does not double our quota usage.
+type Hedger struct {
+	delay        time.Duration
+	maxPerMinute int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowUsed  int
+	stats       HedgeStats
+}
+
+// HedgeStats counts hedging outcomes since startup
+type HedgeStats struct {
+	Calls     int64 `json:"calls"`      // calls made through the hedger
+	Hedged    int64 `json:"hedged"`     // calls that fired a second request
+	HedgeWins int64 `json:"hedge_wins"` // hedged calls answered by the second request
+	Throttled int64 `json:"throttled"`  // slow calls not hedged because the per-minute cap was reached
+}
+
+// NewHedger hedges calls still pending after delay, at most maxPerMinute times a minute.
+// A zero delay or cap disables hedging.
+func NewHedger(delay time.Duration, maxPerMinute int) *Hedger {
+	return &Hedger{
+		delay:        delay,
+		maxPerMinute: maxPerMinute,
+	}
+}
+
+// Stats returns a snapshot of the hedging counters
+func (h *Hedger) Stats() HedgeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+// enabled reports whether hedging is configured
+func (h *Hedger) enabled() bool {
+	return h != nil && h.delay > 0 && h.maxPerMinute > 0
+}
+
+// reserve takes a hedge from this minute's budget, or records that none was left
+func (h *Hedger) reserve(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if now.Sub(h.windowStart) >= time.Minute {
+		h.windowStart = now
+		h.windowUsed = 0
+	}
+	if h.windowUsed >= h.maxPerMinute {
+		h.stats.Throttled++
+		return false
+	}
+	h.windowUsed++
+	h.stats.Hedged++
+	return true
+}
+
+func (h *Hedger) count(update func(stats *HedgeStats)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	update(&h.stats)
+}
+
+// hedgeResult is one attempt's outcome
+type hedgeResult[T any] struct {
+	value   T
+	err     error
+	attempt int
+}
+
+// HedgedCall runs fn, and if it has not returned after the hedger's delay, runs it again
+// concurrently. The first success is returned and the other attempt's context is
+// canceled. If both attempts fail, the last error is returned. fn must respect its
+// context so canceled attempts stop promptly. A nil or disabled hedger calls fn once.
+func HedgedCall[T any](ctx context.Context, h *Hedger, fn func(ctx context.Context) (T, error)) (T, error) {
+	if !h.enabled() {
+		return fn(ctx)
+	}
+	h.count(func(stats *HedgeStats) { stats.Calls++ })
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // cancels whichever attempt is still running
+
+	// Buffered so a losing attempt can finish without a receiver
+	results := make(chan hedgeResult[T], 2)
+	launch := func(attempt int) {
+		go func() {
+			value, err := fn(ctx)
+			results <- hedgeResult[T]{value: value, err: err, attempt: attempt}
+		}()
+	}
+
+	launch(1)
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+
+	pending := 1
+	hedged := false
+	var last hedgeResult[T]
+	for {
+		select {
+		case <-timer.C:
+			if !hedged && h.reserve(time.Now()) {
+				hedged = true
+				pending++
+				launch(2)
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if result.attempt == 2 {
+					h.count(func(stats *HedgeStats) { stats.HedgeWins++ })
+				}
+				return result.value, nil
+			}
+			last = result
+			// Before the hedge fires, a failure is final: hedging is for slowness, not errors
+			if pending == 0 {
+				return last.value, last.err
+			}
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
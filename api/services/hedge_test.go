@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+// upstreamTransport sends every request to a test server in place of the real API host,
+// keeping the path and query
+type upstreamTransport struct {
+	server *httptest.Server
+}
+
+func (u upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(u.server.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host, req.Host = target.Scheme, target.Host, target.Host
+	return u.server.Client().Transport.RoundTrip(req)
+}
+
+// scriptedAttempt is how one attempt of a hedged call behaves
+type scriptedAttempt struct {
+	after time.Duration // how long the attempt takes unless canceled
+	err   error
+}
+
+func TestHedgedCall(t *testing.T) {
+	errUpstream := errors.New("upstream failed")
+	slow := scriptedAttempt{after: time.Second}
+
+	tests := []struct {
+		name         string
+		hedger       *Hedger
+		attempts     []scriptedAttempt
+		wantAttempts int32
+		wantWinner   int // attempt whose value is returned, 0 for an error
+		wantStats    HedgeStats
+	}{
+		{
+			name:         "fast answer is not hedged",
+			hedger:       NewHedger(50*time.Millisecond, 10),
+			attempts:     []scriptedAttempt{{}},
+			wantAttempts: 1,
+			wantWinner:   1,
+			wantStats:    HedgeStats{Calls: 1},
+		},
+		{
+			name:         "slow answer is hedged and the hedge wins",
+			hedger:       NewHedger(20*time.Millisecond, 10),
+			attempts:     []scriptedAttempt{slow, {}},
+			wantAttempts: 2,
+			wantWinner:   2,
+			wantStats:    HedgeStats{Calls: 1, Hedged: 1, HedgeWins: 1},
+		},
+		{
+			name:         "fast failure is final",
+			hedger:       NewHedger(50*time.Millisecond, 10),
+			attempts:     []scriptedAttempt{{err: errUpstream}},
+			wantAttempts: 1,
+			wantStats:    HedgeStats{Calls: 1},
+		},
+		{
+			name:         "hedge failing leaves the original to answer",
+			hedger:       NewHedger(20*time.Millisecond, 10),
+			attempts:     []scriptedAttempt{{after: 60 * time.Millisecond}, {err: errUpstream}},
+			wantAttempts: 2,
+			wantWinner:   1,
+			wantStats:    HedgeStats{Calls: 1, Hedged: 1},
+		},
+		{
+			name:         "both failing returns an error",
+			hedger:       NewHedger(20*time.Millisecond, 10),
+			attempts:     []scriptedAttempt{{after: 60 * time.Millisecond, err: errUpstream}, {err: errUpstream}},
+			wantAttempts: 2,
+			wantStats:    HedgeStats{Calls: 1, Hedged: 1},
+		},
+		{
+			name:         "disabled hedger calls once",
+			hedger:       NewHedger(0, 10),
+			attempts:     []scriptedAttempt{{after: 60 * time.Millisecond}},
+			wantAttempts: 1,
+			wantWinner:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var started atomic.Int32
+			value, err := HedgedCall(context.Background(), tt.hedger, func(ctx context.Context) (int, error) {
+				attempt := int(started.Add(1))
+				script := tt.attempts[attempt-1]
+				select {
+				case <-time.After(script.after):
+				case <-ctx.Done():
+					return 0, ctx.Err()
+				}
+				return attempt, script.err
+			})
+
+			if tt.wantWinner == 0 {
+				if !errors.Is(err, errUpstream) {
+					t.Errorf("HedgedCall() = %d, %v; want the upstream error", value, err)
+				}
+			} else if err != nil || value != tt.wantWinner {
+				t.Errorf("HedgedCall() = %d, %v; want attempt %d's answer", value, err, tt.wantWinner)
+			}
+			if got := started.Load(); got != tt.wantAttempts {
+				t.Errorf("made %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if got := tt.hedger.Stats(); got != tt.wantStats {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestHedgerCapsHedgesPerMinute(t *testing.T) {
+	// The original answers before its hedge would, so only the cap shows in the stats
+	hedger := NewHedger(10*time.Millisecond, 1)
+	slowCall := func(ctx context.Context) (bool, error) {
+		select {
+		case <-time.After(40 * time.Millisecond):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		return true, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if ok, err := HedgedCall(context.Background(), hedger, slowCall); !ok || err != nil {
+			t.Fatalf("call %d = %v, %v", i+1, ok, err)
+		}
+	}
+	if got, want := hedger.Stats(), (HedgeStats{Calls: 3, Hedged: 1, Throttled: 2}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGeocodeHedgesSlowUpstream(t *testing.T) {
+	// The first request hangs until it is canceled; the hedged one answers at once
+	var requests atomic.Int32
+	loserCanceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+				close(loserCanceled)
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"features":[{"text":"Doug Fir","relevance":0.9,` +
+			`"geometry":{"coordinates":[-122.6563,45.5225]},` +
+			`"properties":{"full_address":"830 E Burnside St, Portland, OR 97214"}}]}`))
+	}))
+	defer server.Close()
+
+	geocoder := &GeocodingService{
+		config:     &config.Config{Geocoder: GeocoderMapbox, GeocoderAPIKey: "test-key"},
+		httpClient: &http.Client{Transport: upstreamTransport{server: server}},
+		hedger:     NewHedger(30*time.Millisecond, 10),
+	}
+
+	result, err := geocoder.GeocodeAddress(context.Background(), "830 E Burnside St, Portland", nil)
+	if err != nil {
+		t.Fatalf("GeocodeAddress() error = %v", err)
+	}
+	if result.Latitude != 45.5225 || result.Longitude != -122.6563 || result.Confidence != 0.9 {
+		t.Errorf("GeocodeAddress() = %+v", result)
+	}
+	if got, want := geocoder.HedgeStats(), (HedgeStats{Calls: 1, Hedged: 1, HedgeWins: 1}); got != want {
+		t.Errorf("HedgeStats() = %+v, want %+v", got, want)
+	}
+
+	select {
+	case <-loserCanceled:
+	case <-time.After(2 * time.Second):
+		t.Error("the slow request was not canceled after the hedge won")
+	}
+}
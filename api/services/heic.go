@@ -0,0 +1,89 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+// ErrHEICUnsupported is returned for HEIC uploads when no converter is installed
+var ErrHEICUnsupported = errors.New("HEIC images are not supported on this server")
+
+// heicTranscodeTimeout bounds one HEIC to JPEG conversion
+const heicTranscodeTimeout = 30 * time.Second
+
+// heicBrands are the ISO BMFF major brands of HEIC/HEIF still images and sequences
+var heicBrands = [][]byte{
+	[]byte("heic"), []byte("heix"), []byte("hevc"), []byte("hevx"),
+	[]byte("heim"), []byte("heis"), []byte("mif1"), []byte("msf1"),
+}
+
+// IsHEIC reports whether data starts with an ftyp box naming a HEIC/HEIF brand
+func IsHEIC(data []byte) bool {
+	if len(data) < 12 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return false
+	}
+	for _, brand := range heicBrands {
+		if bytes.Equal(data[8:12], brand) {
+			return true
+		}
+	}
+	return false
+}
+
+// HEICSupported reports whether cfg.HEICConverter is set and can be found on PATH
+func HEICSupported(cfg *config.Config) bool {
+	if cfg.HEICConverter == "" {
+		return false
+	}
+	_, err := exec.LookPath(cfg.HEICConverter)
+	return err == nil
+}
+
+// TranscodeHEICOriginal replaces a HEIC file at path with a JPEG at ImageJPEGQuality, so
+// everything after upload sees a real JPEG. Other formats are left alone. Go has no HEIC
+// decoder in the standard library, so conversion runs HEICConverter, which must accept
+// heif-convert's arguments (`-q quality input output.jpg`); without it HEIC is refused.
+func TranscodeHEICOriginal(ctx context.Context, cfg *config.Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 12)
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil || !IsHEIC(header) {
+		return nil
+	}
+
+	if cfg.HEICConverter == "" {
+		return ErrHEICUnsupported
+	}
+	converter, err := exec.LookPath(cfg.HEICConverter)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrHEICUnsupported, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, heicTranscodeTimeout)
+	defer cancel()
+
+	// Convert next to the original and swap it in, so a failure never leaves a partial file
+	tmpPath := path + ".heic.jpg"
+	defer os.Remove(tmpPath)
+	output, err := exec.CommandContext(ctx, converter, "-q", strconv.Itoa(cfg.ImageJPEGQuality), path, tmpPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to convert HEIC: %v: %s", err, bytes.TrimSpace(output))
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace HEIC original: %w", err)
+	}
+	return nil
+}
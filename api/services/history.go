@@ -0,0 +1,77 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// RecordEventHistory closes the event's current history row and appends its new state.
+// Call it inside the same transaction as the mutation.
+func RecordEventHistory(tx *gorm.DB, event *models.Event) error {
+	state := *event
+	state.Venue = nil
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event history: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if err := tx.Model(&models.EventHistory{}).
+		Where("event_id = ? AND valid_to IS NULL", event.ID).
+		Update("valid_to", now).Error; err != nil {
+		return fmt.Errorf("failed to close event history: %w", err)
+	}
+
+	entry := models.EventHistory{
+		EventID:   event.ID,
+		ValidFrom: now,
+		State:     string(stateJSON),
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to write event history: %w", err)
+	}
+	return nil
+}
+
+// RecordEventHistoryByID reloads events after a bulk update and records each new state
+func RecordEventHistoryByID(tx *gorm.DB, eventIDs ...uuid.UUID) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	var events []models.Event
+	if err := tx.Where("id IN ?", eventIDs).Find(&events).Error; err != nil {
+		return fmt.Errorf("failed to load events for history: %w", err)
+	}
+	for i := range events {
+		if err := RecordEventHistory(tx, &events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EventsAsOf reconstructs the approved events as they were at the given time
+func EventsAsOf(db *gorm.DB, asOf time.Time) ([]models.Event, error) {
+	var entries []models.EventHistory
+	if err := db.Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", asOf, asOf).
+		Where("state->>'moderation_state' = ?", "approved").
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to query event history: %w", err)
+	}
+
+	events := make([]models.Event, 0, len(entries))
+	for _, entry := range entries {
+		var event models.Event
+		if err := json.Unmarshal([]byte(entry.State), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode event history %s: %w", entry.ID, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestEventsAsOfAfterPublishEditUnpublish(t *testing.T) {
+	db, mock := testdb.New(t)
+	creates := testdb.RecordCreates(t, db)
+
+	event := models.Event{
+		ID:              uuid.New(),
+		Title:           "Open Mic",
+		ModerationState: "approved",
+		Venue:           &models.Venue{Name: "Corner Library"},
+	}
+	steps := []struct {
+		name  string
+		apply func(*models.Event)
+	}{
+		{"publish", func(*models.Event) {}},
+		{"edit", func(e *models.Event) { e.Title = "Open Mic Night" }},
+		{"unpublish", func(e *models.Event) { e.ModerationState = "blocked" }},
+	}
+	for _, step := range steps {
+		step.apply(&event)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "event_history" SET "valid_to"`).
+			WithArgs(testdb.Any, event.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "event_history"`).WillReturnRows(testdb.IDs(uuid.New()))
+		mock.ExpectCommit()
+		if err := RecordEventHistory(db, &event); err != nil {
+			t.Fatalf("%s: RecordEventHistory() error = %v", step.name, err)
+		}
+		// Keep each version's valid_from distinct
+		time.Sleep(time.Millisecond)
+	}
+
+	recorded := creates.Table("event_history")
+	if len(recorded) != len(steps) {
+		t.Fatalf("recorded %d history rows, want %d", len(recorded), len(steps))
+	}
+	history := make([]models.EventHistory, len(recorded))
+	for i, value := range recorded {
+		history[i] = *value.(*models.EventHistory)
+		if history[i].EventID != event.ID {
+			t.Errorf("history[%d].EventID = %s, want %s", i, history[i].EventID, event.ID)
+		}
+		if strings.Contains(history[i].State, "Corner Library") {
+			t.Errorf("history[%d] snapshots the venue: %s", i, history[i].State)
+		}
+		// Each write closes the previous row at the new row's valid_from
+		if i > 0 {
+			validTo := history[i].ValidFrom
+			history[i-1].ValidTo = &validTo
+		}
+	}
+
+	tests := []struct {
+		name      string
+		asOf      time.Time
+		wantTitle string // "" when the event was not public
+	}{
+		{"before publish", history[0].ValidFrom.Add(-time.Hour), ""},
+		{"after publish", history[1].ValidFrom.Add(-time.Microsecond), "Open Mic"},
+		{"after edit", history[2].ValidFrom.Add(-time.Microsecond), "Open Mic Night"},
+		{"after unpublish", history[2].ValidFrom.Add(time.Hour), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.ExpectQuery(`SELECT * FROM "event_history" WHERE`).
+				WithArgs(tt.asOf, tt.asOf, "approved").
+				WillReturnRows(historyRowsAt(t, history, tt.asOf))
+
+			events, err := EventsAsOf(db, tt.asOf)
+			if err != nil {
+				t.Fatalf("EventsAsOf() error = %v", err)
+			}
+			if tt.wantTitle == "" {
+				if len(events) != 0 {
+					t.Errorf("EventsAsOf() = %d events, want none", len(events))
+				}
+				return
+			}
+			if len(events) != 1 || events[0].ID != event.ID || events[0].Title != tt.wantTitle {
+				t.Fatalf("EventsAsOf() = %+v, want %q", events, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestEventsAsOfRejectsCorruptState(t *testing.T) {
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`SELECT * FROM "event_history"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "valid_from", "state"}).
+			AddRow(uuid.New().String(), uuid.New().String(), time.Now(), `{"title":`))
+
+	if _, err := EventsAsOf(db, time.Now()); err == nil {
+		t.Fatal("EventsAsOf() error = nil, want a decode error")
+	}
+}
+
+// historyRowsAt returns the rows EventsAsOf's query selects from history at asOf: the
+// version valid then, if that version was approved
+func historyRowsAt(t *testing.T, history []models.EventHistory, asOf time.Time) *sqlmock.Rows {
+	t.Helper()
+
+	rows := sqlmock.NewRows([]string{"id", "event_id", "valid_from", "valid_to", "state"})
+	for _, entry := range history {
+		if entry.ValidFrom.After(asOf) || (entry.ValidTo != nil && !entry.ValidTo.After(asOf)) {
+			continue
+		}
+		var state struct {
+			ModerationState string `json:"moderation_state"`
+		}
+		if err := json.Unmarshal([]byte(entry.State), &state); err != nil {
+			t.Fatalf("decode history state: %v", err)
+		}
+		if state.ModerationState != "approved" {
+			continue
+		}
+		rows.AddRow(uuid.New().String(), entry.EventID.String(), entry.ValidFrom, entry.ValidTo, entry.State)
+	}
+	return rows
+}
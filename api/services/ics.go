@@ -0,0 +1,293 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// MaxICSFeedEvents bounds the bulk calendar feed
+const MaxICSFeedEvents = 2000
+
+// ICSEventUID is an event's calendar UID. It is derived only from the event ID, which never
+// changes (republishing reuses the existing row), so subscribers always see one entry per event.
+func ICSEventUID(eventID uuid.UUID, domain string) string {
+	return fmt.Sprintf("evt_%s@%s", eventID, domain)
+}
+
+// icsMaxLineOctets is the longest content line RFC 5545 allows before folding
+const icsMaxLineOctets = 75
+
+// ICSCalendar renders events as an iCalendar object
+type ICSCalendar struct {
+	ProdID    string
+	UIDDomain string
+	Location  *time.Location // nil or UTC emits UTC times; otherwise TZID times with a VTIMEZONE
+	Stamp     time.Time      // DTSTAMP; zero means now
+}
+
+// Render returns the events as iCalendar, serialized per RFC 5545: CRLF line endings, lines
+// folded at 75 octets, and TEXT values escaped. Approved events go in a METHOD:PUBLISH
+// VCALENDAR. Events that are not approved are cancellations: METHOD applies to a whole
+// object, so they follow in a second, METHOD:CANCEL VCALENDAR under their usual UID and
+// SEQUENCE (already bumped by the takedown), so a calendar that imported them removes them.
+// A calendar with only cancellations is just the CANCEL object.
+func (cal ICSCalendar) Render(events []models.Event) string {
+	stamp := cal.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	var published, cancelled []models.Event
+	for i := range events {
+		if events[i].ModerationState == EventStateApproved {
+			published = append(published, events[i])
+		} else {
+			cancelled = append(cancelled, events[i])
+		}
+	}
+
+	var b strings.Builder
+	if len(published) > 0 || len(cancelled) == 0 {
+		cal.writeCalendar(&b, "PUBLISH", published, stamp)
+	}
+	if len(cancelled) > 0 {
+		cal.writeCalendar(&b, "CANCEL", cancelled, stamp)
+	}
+	return b.String()
+}
+
+// writeCalendar writes one VCALENDAR object with the given METHOD
+func (cal ICSCalendar) writeCalendar(b *strings.Builder, method string, events []models.Event, stamp time.Time) {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + icsText(cal.ProdID),
+		"CALSCALE:GREGORIAN",
+		"METHOD:" + method,
+	}
+	if cal.Location != nil && cal.Location != time.UTC && len(events) > 0 {
+		from, until := events[0].StartTs, EventEnd(events[0].StartTs, events[0].EndTs)
+		for i := range events {
+			if events[i].StartTs.Before(from) {
+				from = events[i].StartTs
+			}
+			if end := EventEnd(events[i].StartTs, events[i].EndTs); end.After(until) {
+				until = end
+			}
+		}
+		lines = append(lines, icsTimezone(cal.Location, from, until)...)
+	}
+	for i := range events {
+		lines = append(lines, cal.eventLines(&events[i], stamp)...)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		b.WriteString(foldICSLine(line))
+		b.WriteString("\r\n")
+	}
+}
+
+// eventLines renders one VEVENT. SEQUENCE and LAST-MODIFIED let clients tell an update
+// (or cancellation) of an entry they already have from a new one.
+func (cal ICSCalendar) eventLines(event *models.Event, stamp time.Time) []string {
+	status := "CONFIRMED"
+	if event.ModerationState != EventStateApproved {
+		status = "CANCELLED"
+	}
+
+	lines := []string{
+		"BEGIN:VEVENT",
+		"UID:" + ICSEventUID(event.ID, cal.UIDDomain),
+		"DTSTAMP:" + stamp.UTC().Format(icsUTCLayout),
+		fmt.Sprintf("SEQUENCE:%d", event.ICSSequence),
+		"LAST-MODIFIED:" + event.UpdatedAt.UTC().Format(icsUTCLayout),
+		FormatICSTime("DTSTART", event.StartTs, cal.Location),
+	}
+	// Without a known end the default length is a DURATION, so it isn't mistaken for a real end time
+	if event.EndTs != nil {
+		lines = append(lines, FormatICSTime("DTEND", *event.EndTs, cal.Location))
+	} else {
+		lines = append(lines, "DURATION:"+icsDuration(defaultEventDuration))
+	}
+	lines = append(lines, "SUMMARY:"+icsText(event.Title))
+	if event.Description != nil && *event.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+icsText(*event.Description))
+	}
+	if event.Venue != nil {
+		location := event.Venue.Name
+		if event.Venue.AddressLine != nil {
+			location += ", " + *event.Venue.AddressLine
+		}
+		lines = append(lines, "LOCATION:"+icsText(location))
+	}
+	if event.URL != nil && *event.URL != "" {
+		// A URI value, not TEXT: it isn't escaped, but line breaks can't be allowed through
+		lines = append(lines, "URL:"+strings.NewReplacer("\r", "", "\n", "").Replace(*event.URL))
+	}
+	return append(lines, "STATUS:"+status, "END:VEVENT")
+}
+
+// icsText escapes a TEXT property value: backslashes, semicolons, commas, and line breaks
+func icsText(value string) string {
+	return strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+		"\r", "\\n",
+	).Replace(value)
+}
+
+// foldICSLine splits a content line into 75-octet pieces, each continuation starting with a
+// space. Breaks never fall inside a multi-byte UTF-8 character.
+func foldICSLine(line string) string {
+	if len(line) <= icsMaxLineOctets {
+		return line
+	}
+
+	var b strings.Builder
+	limit := icsMaxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = icsMaxLineOctets - 1 // the leading space counts
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// icsTimezone renders a VTIMEZONE for loc covering [from, until], with one STANDARD or
+// DAYLIGHT observance per offset change. Explicit transitions rather than RRULEs keep it
+// exact for any zone Go knows; a zone without changes in the range gets one observance.
+func icsTimezone(loc *time.Location, from, until time.Time) []string {
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + loc.String()}
+
+	// Start a little early so the offset in force at the first event is covered
+	start := from.AddDate(0, -6, 0)
+	name, offset := start.In(loc).Zone()
+	isDST := start.In(loc).IsDST()
+	observances := 0
+
+	emit := func(at time.Time, fromOffset int, toName string, toOffset int, dst bool) {
+		kind := "STANDARD"
+		if dst {
+			kind = "DAYLIGHT"
+		}
+		// DTSTART is the wall-clock time of the change in the offset being left
+		local := at.In(time.FixedZone("", fromOffset))
+		lines = append(lines,
+			"BEGIN:"+kind,
+			"DTSTART:"+local.Format(icsLocalLayout),
+			"TZOFFSETFROM:"+icsOffset(fromOffset),
+			"TZOFFSETTO:"+icsOffset(toOffset),
+			"TZNAME:"+icsText(toName),
+			"END:"+kind,
+		)
+		observances++
+	}
+
+	// Step a day at a time and binary-search each offset change down to the second
+	for day := start; day.Before(until); {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset != offset {
+			lo, hi := day, next
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if _, midOffset := mid.In(loc).Zone(); midOffset == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			toName, toOffset := hi.In(loc).Zone()
+			toDST := hi.In(loc).IsDST()
+			if observances == 0 {
+				// Anchor the offset in force before the first change
+				emit(start, offset, name, offset, isDST)
+			}
+			emit(hi, offset, toName, toOffset, toDST)
+			name, offset, isDST = toName, toOffset, toDST
+		}
+		day = next
+	}
+	if observances == 0 {
+		emit(start, offset, name, offset, isDST)
+	}
+
+	return append(lines, "END:VTIMEZONE")
+}
+
+// icsDuration formats a duration as an RFC 5545 dur-time, e.g. PT2H or PT1H30M
+func icsDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	value := "PT"
+	if hours := int(d / time.Hour); hours > 0 {
+		value += fmt.Sprintf("%dH", hours)
+	}
+	if minutes := int(d % time.Hour / time.Minute); minutes > 0 {
+		value += fmt.Sprintf("%dM", minutes)
+	}
+	if seconds := int(d % time.Minute / time.Second); seconds > 0 || value == "PT" {
+		value += fmt.Sprintf("%dS", seconds)
+	}
+	return value
+}
+
+// icsOffset formats a UTC offset in seconds as +HHMM
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// ICSFeedEvents returns the bulk feed's entries for events overlapping [from, until): approved,
+// distributable events, plus events in the window that were published and have since been
+// taken down or deleted, which the feed carries as cancellations. Ordered by start time.
+func ICSFeedEvents(db *gorm.DB, from, until time.Time) ([]models.Event, error) {
+	var events []models.Event
+	if err := icsFeedQuery(db, from, until).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to load calendar feed events: %w", err)
+	}
+
+	// Deleted events have no row left to cancel from; their tombstones stand in
+	deleted, err := tombstoneFeedEvents(db, from, until)
+	if err != nil {
+		return nil, err
+	}
+	return mergeFeedEvents(events, deleted, MaxICSFeedEvents), nil
+}
+
+// ICSVenueFeedEvents is ICSFeedEvents for one venue's events. Tombstones don't keep the
+// venue, so deleted events drop out of a venue feed rather than being cancelled.
+func ICSVenueFeedEvents(db *gorm.DB, venueID uuid.UUID, from, until time.Time) ([]models.Event, error) {
+	var events []models.Event
+	if err := icsFeedQuery(db, from, until).Where("events.venue_id = ?", venueID).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to load venue calendar events: %w", err)
+	}
+	return events, nil
+}
+
+// icsFeedQuery selects the approved and once-approved distributable events in the window
+func icsFeedQuery(db *gorm.DB, from, until time.Time) *gorm.DB {
+	return WhereEventOverlaps(DistributableEvents(db).Preload("Venue"), &from, &until).
+		Where(db.Session(&gorm.Session{NewDB: true}).Where("events.moderation_state = ?", EventStateApproved).
+			Or("EXISTS (SELECT 1 FROM event_state_transitions t WHERE t.event_id = events.id AND t.from_state = ?)", EventStateApproved)).
+		Order("events.start_ts ASC, events.id ASC").
+		Limit(MaxICSFeedEvents)
+}
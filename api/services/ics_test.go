@@ -0,0 +1,371 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// icsTestCalendar renders with fixed stamps so output can be compared with golden files
+var icsTestCalendar = ICSCalendar{
+	ProdID:    "-//WilliamBoard//Events//EN",
+	UIDDomain: "williamboard.test",
+	Location:  time.UTC,
+	Stamp:     time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC),
+}
+
+// icsTestEvent is an approved event that has never been edited
+func icsTestEvent(id string, title string, start time.Time) models.Event {
+	venueID := uuid.MustParse("7b0c1f5e-2f4c-4d36-9a0e-000000000001")
+	address := "12 Main St, Springfield"
+	return models.Event{
+		ID:              uuid.MustParse(id),
+		Title:           title,
+		StartTs:         start,
+		VenueID:         &venueID,
+		Venue:           &models.Venue{ID: venueID, Name: "The Grange Hall", AddressLine: &address},
+		ModerationState: EventStateApproved,
+		UpdatedAt:       time.Date(2026, 5, 20, 18, 30, 0, 0, time.UTC),
+	}
+}
+
+// TestICSEditAndCancelGolden follows one event from publication through a reschedule to a
+// takedown, checking each export against a golden file and that the UID never changes
+func TestICSEditAndCancelGolden(t *testing.T) {
+	event := icsTestEvent("3f2a9c1e-8d4b-4f6a-b1c2-0d9e8f7a6b01", "Spring Barn Dance", time.Date(2026, 6, 13, 19, 0, 0, 0, time.UTC))
+	other := icsTestEvent("3f2a9c1e-8d4b-4f6a-b1c2-0d9e8f7a6b02", "Seed Swap", time.Date(2026, 6, 14, 10, 0, 0, 0, time.UTC))
+	uid := "UID:" + ICSEventUID(event.ID, icsTestCalendar.UIDDomain)
+
+	published := icsTestCalendar.Render([]models.Event{event})
+	assertGolden(t, filepath.Join("ics", "published.ics"), published)
+
+	// A reschedule through EditEvent bumps SEQUENCE and LAST-MODIFIED
+	newStart := event.StartTs.Add(time.Hour)
+	end := newStart.Add(3 * time.Hour)
+	db, mock := testdb.New(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "events" WHERE id = $1`).
+		WithArgs(event.ID, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "start_ts", "venue_id", "moderation_state", "ics_sequence", "updated_at"}).
+			AddRow(event.ID.String(), event.Title, event.StartTs, event.VenueID.String(), event.ModerationState, 0, event.UpdatedAt))
+	mock.ExpectExec(`UPDATE "events" SET "end_ts"=$1,"ics_sequence"=$2,"start_ts"=$3,"updated_at"=$4 WHERE id = $5`).
+		WithArgs(end, 1, newStart, testdb.Any, event.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "event_history" SET "valid_to"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "event_history"`).WillReturnRows(testdb.IDs(uuid.New()))
+	expectAudit(mock, AuditActionEventEdited)
+	expectEventChange(mock, event.ID, ChangeTypeUpdated, ChangeReasonEdited)
+	mock.ExpectCommit()
+
+	var edited *models.Event
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		edited, err = EditEvent(tx, event.ID, EventEdit{StartTs: &newStart, EndTs: &end}, Actor{Type: ActorAdmin, AdminID: "ops"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("EditEvent() error = %v", err)
+	}
+	if edited.ICSSequence != 1 || !edited.UpdatedAt.After(event.UpdatedAt) {
+		t.Fatalf("EditEvent() left sequence %d, updated %v; want 1 and a later time", edited.ICSSequence, edited.UpdatedAt)
+	}
+	edited.Venue = event.Venue
+	edited.UpdatedAt = time.Date(2026, 5, 25, 8, 0, 0, 0, time.UTC) // pinned for the golden file
+	editedICS := icsTestCalendar.Render([]models.Event{*edited})
+	assertGolden(t, filepath.Join("ics", "edited.ics"), editedICS)
+
+	// A takedown bumps the sequence again in TransitionEventState
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id","moderation_state" FROM "events"`).
+		WithArgs(event.ID, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "moderation_state"}).AddRow(event.ID.String(), EventStateApproved))
+	mock.ExpectQuery(`INSERT INTO "event_state_transitions"`).WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectExec(`UPDATE "events" SET "ics_sequence"=ics_sequence + 1,"moderation_state"=$1,"updated_at"=$2 WHERE id = $3`).
+		WithArgs(EventStateBlocked, testdb.Any, event.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return TransitionEventState(tx, event.ID, EventTransition{To: EventStateBlocked, Actor: ActorAdmin, Reason: "spam"})
+	})
+	if err != nil {
+		t.Fatalf("TransitionEventState() error = %v", err)
+	}
+	cancelled := *edited
+	cancelled.ModerationState = EventStateBlocked
+	cancelled.ICSSequence++
+	cancelled.UpdatedAt = time.Date(2026, 5, 26, 8, 0, 0, 0, time.UTC)
+
+	single := icsTestCalendar.Render([]models.Event{cancelled})
+	assertGolden(t, filepath.Join("ics", "cancelled.ics"), single)
+	if strings.Contains(single, "METHOD:PUBLISH") {
+		t.Error("a lone cancellation carries a PUBLISH calendar")
+	}
+
+	// The bulk feed publishes the live event and cancels the taken-down one
+	feed := icsTestCalendar.Render([]models.Event{cancelled, other})
+	assertGolden(t, filepath.Join("ics", "feed_with_cancellation.ics"), feed)
+
+	for name, ics := range map[string]string{"published": published, "edited": editedICS, "cancelled": single, "feed": feed} {
+		if strings.Count(ics, uid+"\r\n") != 1 {
+			t.Errorf("%s export does not carry the event's stable %s exactly once", name, uid)
+		}
+	}
+}
+
+func TestEditEventRejects(t *testing.T) {
+	start := time.Date(2026, 6, 13, 19, 0, 0, 0, time.UTC)
+	before := start.Add(-time.Hour)
+	blank := "   "
+
+	tests := []struct {
+		name string
+		edit EventEdit
+	}{
+		{"blank title", EventEdit{Title: &blank}},
+		{"end before start", EventEdit{EndTs: &before}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT * FROM "events" WHERE id = $1`).
+				WithArgs(eventID, testdb.Any).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "start_ts"}).AddRow(eventID.String(), "Barn Dance", start))
+			mock.ExpectRollback()
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := EditEvent(tx, eventID, tt.edit, Actor{Type: ActorAdmin, AdminID: "ops"})
+				return err
+			})
+			if !errors.Is(err, ErrInvalidEventEdit) {
+				t.Errorf("EditEvent() error = %v, want ErrInvalidEventEdit", err)
+			}
+		})
+	}
+}
+
+func TestICSText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Open Mic", "Open Mic"},
+		{"Soup, Salad, Bread", `Soup\, Salad\, Bread`},
+		{"Doors 7pm; show 8pm", `Doors 7pm\; show 8pm`},
+		{`C:\Users\board`, `C:\\Users\\board`},
+		{"Line one\nLine two", `Line one\nLine two`},
+		{"Windows\r\nbreak", `Windows\nbreak`},
+		{"Old Mac\rbreak", `Old Mac\nbreak`},
+		{"Already escaped \\n", `Already escaped \\n`},
+		{"Sunset 🌅 yoga", "Sunset 🌅 yoga"},
+		{`Quotes "stay" and colons: too`, `Quotes "stay" and colons: too`},
+	}
+
+	for _, tt := range tests {
+		if got := icsText(tt.in); got != tt.want {
+			t.Errorf("icsText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Open Mic"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"76 octets", "SUMMARY:" + strings.Repeat("a", 68)},
+		{"long ASCII", "DESCRIPTION:" + strings.Repeat("Bring a chair\\, ", 20)},
+		{"emoji across the boundary", "SUMMARY:" + strings.Repeat("a", 65) + strings.Repeat("🎻", 10)},
+		{"accents across the boundary", "LOCATION:" + strings.Repeat("é", 80)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := foldICSLine(tt.line)
+			lines := strings.Split(folded, "\r\n")
+			for i, line := range lines {
+				if len(line) > icsMaxLineOctets {
+					t.Errorf("line %d is %d octets", i, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a character: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+			}
+			if wantFolds := len(tt.line) > icsMaxLineOctets; (len(lines) > 1) != wantFolds {
+				t.Errorf("folded into %d lines, want folding: %v", len(lines), wantFolds)
+			}
+			if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("unfolding gives %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
+
+func TestICSDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{2 * time.Hour, "PT2H"},
+		{90 * time.Minute, "PT1H30M"},
+		{45 * time.Minute, "PT45M"},
+		{time.Hour + 30*time.Second, "PT1H30S"},
+		{0, "PT0S"},
+	}
+
+	for _, tt := range tests {
+		if got := icsDuration(tt.in); got != tt.want {
+			t.Errorf("icsDuration(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// lintICS checks the RFC 5545 rules the serializer is responsible for: CRLF everywhere,
+// content lines within 75 octets of valid UTF-8, folded lines continuing with a space,
+// components nested and closed, and the properties each one requires
+func lintICS(t *testing.T, ics string) {
+	t.Helper()
+	if !strings.HasSuffix(ics, "\r\n") || strings.Contains(strings.ReplaceAll(ics, "\r\n", ""), "\n") ||
+		strings.Contains(strings.ReplaceAll(ics, "\r\n", ""), "\r") {
+		t.Fatal("ICS has a line ending other than CRLF")
+	}
+
+	var unfolded []string
+	for i, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > icsMaxLineOctets || !utf8.ValidString(line) {
+			t.Errorf("line %d is %d octets or invalid UTF-8: %q", i+1, len(line), line)
+		}
+		if strings.HasPrefix(line, " ") {
+			if len(unfolded) == 0 {
+				t.Fatal("ICS starts with a continuation line")
+			}
+			unfolded[len(unfolded)-1] += line[1:]
+			continue
+		}
+		unfolded = append(unfolded, line)
+	}
+
+	required := map[string][]string{
+		"VCALENDAR": {"VERSION", "PRODID"},
+		"VEVENT":    {"UID", "DTSTAMP", "DTSTART"},
+		"VTIMEZONE": {"TZID"},
+		"STANDARD":  {"DTSTART", "TZOFFSETFROM", "TZOFFSETTO"},
+		"DAYLIGHT":  {"DTSTART", "TZOFFSETFROM", "TZOFFSETTO"},
+	}
+	type component struct {
+		name  string
+		props map[string]bool
+	}
+	var stack []component
+	for _, line := range unfolded {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			t.Errorf("content line without a value: %q", line)
+			continue
+		}
+		name, _, _ = strings.Cut(name, ";")
+		switch name {
+		case "BEGIN":
+			stack = append(stack, component{name: value, props: map[string]bool{}})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != value {
+				t.Fatalf("END:%s does not close the open component", value)
+			}
+			for _, prop := range required[value] {
+				if !stack[len(stack)-1].props[prop] {
+					t.Errorf("%s is missing %s", value, prop)
+				}
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				t.Errorf("property %s outside any component", name)
+				continue
+			}
+			stack[len(stack)-1].props[name] = true
+		}
+	}
+	if len(stack) != 0 {
+		t.Errorf("%s is never closed", stack[len(stack)-1].name)
+	}
+}
+
+func TestICSRenderEscapesAndLints(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	description := "Bring: chairs, blankets; snacks.\nKids welcome!\r\nRain location C:\\Gym 🎶"
+	url := "https://example.com/e?a=1,b=2\n"
+	event := icsTestEvent("3f2a9c1e-8d4b-4f6a-b1c2-0d9e8f7a6b03", "Concert, Dance; & Potluck 🎉 — all ages, all welcome, bring a friend or three", time.Date(2026, 3, 7, 1, 0, 0, 0, time.UTC))
+	event.Description = &description
+	event.URL = &url
+	// The second event falls after the DST change, so the zone needs both observances
+	later := icsTestEvent("3f2a9c1e-8d4b-4f6a-b1c2-0d9e8f7a6b04", "Seed Swap", time.Date(2026, 3, 21, 15, 0, 0, 0, time.UTC))
+
+	for _, loc := range []*time.Location{time.UTC, chicago} {
+		t.Run(loc.String(), func(t *testing.T) {
+			cal := icsTestCalendar
+			cal.Location = loc
+			ics := cal.Render([]models.Event{event, later})
+			lintICS(t, ics)
+
+			unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+			for _, want := range []string{
+				`SUMMARY:Concert\, Dance\; & Potluck 🎉 — all ages\, all welcome\, bring a friend or three` + "\r\n",
+				`DESCRIPTION:Bring: chairs\, blankets\; snacks.\nKids welcome!\nRain location C:\\Gym 🎶` + "\r\n",
+				`LOCATION:The Grange Hall\, 12 Main St\, Springfield` + "\r\n",
+				"URL:https://example.com/e?a=1,b=2\r\n",
+				"DTSTAMP:20260601T090000Z\r\n",
+			} {
+				if !strings.Contains(unfolded, want) {
+					t.Errorf("ICS is missing %q", strings.TrimSuffix(want, "\r\n"))
+				}
+			}
+
+			if loc == time.UTC {
+				if strings.Contains(ics, "VTIMEZONE") || !strings.Contains(ics, "DTSTART:20260307T010000Z\r\n") {
+					t.Errorf("UTC calendar should use Z times without a VTIMEZONE:\n%s", ics)
+				}
+				return
+			}
+			for _, want := range []string{
+				"TZID:America/Chicago\r\n",
+				"DTSTART;TZID=America/Chicago:20260306T190000\r\n", // CST, before the change
+				"DTSTART;TZID=America/Chicago:20260321T100000\r\n", // CDT, after it
+				"TZOFFSETFROM:-0600\r\nTZOFFSETTO:-0500\r\n",
+			} {
+				if !strings.Contains(ics, want) {
+					t.Errorf("zoned ICS is missing %q:\n%s", want, ics)
+				}
+			}
+			if n := strings.Count(ics, "BEGIN:VTIMEZONE"); n != 1 {
+				t.Errorf("%d VTIMEZONE components, want 1", n)
+			}
+		})
+	}
+}
+
+func TestICSRenderEmptyCalendar(t *testing.T) {
+	ics := icsTestCalendar.Render(nil)
+	lintICS(t, ics)
+	if !strings.Contains(ics, "METHOD:PUBLISH\r\n") || strings.Contains(ics, "VEVENT") {
+		t.Errorf("an empty feed should be one PUBLISH calendar with no events:\n%s", ics)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Layouts used when rendering event times for people rather than machines
+const (
+	LocalTimeLayout = "2006-01-02T15:04:05-07:00"
+	icsUTCLayout    = "20060102T150405Z"
+	icsLocalLayout  = "20060102T150405"
+)
+
+// LocalTimes is an event's start/end rendered in a specific timezone
+type LocalTimes struct {
+	StartLocal string  `json:"start_local"`
+	EndLocal   *string `json:"end_local,omitempty"`
+	TZ         string  `json:"tz"`
+	TZOffset   string  `json:"tz_offset"`
+}
+
+// LoadTimezone resolves an IANA zone name, falling back when name is empty.
+// Abbreviations like "PST" and fixed offsets are rejected so results stay DST-correct.
+func LoadTimezone(name string, fallback *time.Location) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fallback, nil
+	}
+	if name != "UTC" && !strings.Contains(name, "/") {
+		return nil, fmt.Errorf("timezone %q is not an IANA zone name", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+// FormatLocalTimes renders start/end in loc. The offset is taken at the start time.
+func FormatLocalTimes(start time.Time, end *time.Time, loc *time.Location) LocalTimes {
+	localStart := start.In(loc)
+	result := LocalTimes{
+		StartLocal: localStart.Format(LocalTimeLayout),
+		TZ:         loc.String(),
+		TZOffset:   localStart.Format("-07:00"),
+	}
+	if end != nil {
+		endLocal := end.In(loc).Format(LocalTimeLayout)
+		result.EndLocal = &endLocal
+	}
+	return result
+}
+
+// EventEnd returns the event's end time, or start plus DefaultEventDuration when unset
+func EventEnd(start time.Time, end *time.Time) time.Time {
+	if end != nil {
+		return *end
+	}
+	return start.Add(defaultEventDuration)
+}
+
+// FormatICSTime renders a DTSTART/DTEND property. A nil loc emits UTC ("DTSTART:...Z");
+// otherwise the wall time is emitted with a TZID parameter.
+func FormatICSTime(property string, t time.Time, loc *time.Location) string {
+	if loc == nil || loc == time.UTC {
+		return property + ":" + t.UTC().Format(icsUTCLayout)
+	}
+	return fmt.Sprintf("%s;TZID=%s:%s", property, loc.String(), t.In(loc).Format(icsLocalLayout))
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestLoadTimezone(t *testing.T) {
+	fallback := time.FixedZone("fallback", 0)
+
+	tests := []struct {
+		name     string
+		in       string
+		wantName string
+		wantErr  bool
+	}{
+		{"empty uses the fallback", "", "fallback", false},
+		{"blank uses the fallback", "   ", "fallback", false},
+		{"IANA zone", "America/Los_Angeles", "America/Los_Angeles", false},
+		{"IANA zone with padding", " Europe/Berlin ", "Europe/Berlin", false},
+		{"UTC", "UTC", "UTC", false},
+		{"abbreviation", "PST", "", true},
+		{"fixed offset", "-07:00", "", true},
+		{"unknown zone", "Mars/Olympus_Mons", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := LoadTimezone(tt.in, fallback)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadTimezone(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && loc.String() != tt.wantName {
+				t.Errorf("LoadTimezone(%q) = %s, want %s", tt.in, loc, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestFormatLocalTimes(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	winterEnd := time.Date(2026, 1, 15, 5, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		start time.Time
+		end   *time.Time
+		want  LocalTimes
+	}{
+		{
+			name:  "standard time",
+			start: time.Date(2026, 1, 15, 3, 0, 0, 0, time.UTC),
+			end:   &winterEnd,
+			want: LocalTimes{
+				StartLocal: "2026-01-14T19:00:00-08:00",
+				EndLocal:   strPtr("2026-01-14T21:00:00-08:00"),
+				TZ:         "America/Los_Angeles",
+				TZOffset:   "-08:00",
+			},
+		},
+		{
+			name:  "daylight time without an end",
+			start: time.Date(2026, 7, 4, 2, 30, 0, 0, time.UTC),
+			want: LocalTimes{
+				StartLocal: "2026-07-03T19:30:00-07:00",
+				TZ:         "America/Los_Angeles",
+				TZOffset:   "-07:00",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatLocalTimes(tt.start, tt.end, la)
+			if got.StartLocal != tt.want.StartLocal || got.TZ != tt.want.TZ || got.TZOffset != tt.want.TZOffset {
+				t.Errorf("FormatLocalTimes() = %+v, want %+v", got, tt.want)
+			}
+			if (got.EndLocal == nil) != (tt.want.EndLocal == nil) ||
+				(got.EndLocal != nil && *got.EndLocal != *tt.want.EndLocal) {
+				t.Errorf("EndLocal = %v, want %v", got.EndLocal, tt.want.EndLocal)
+			}
+		})
+	}
+}
+
+func TestFormatICSTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 29, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want string
+	}{
+		{"nil location is UTC", nil, "DTSTART:20260329T080000Z"},
+		{"UTC", time.UTC, "DTSTART:20260329T080000Z"},
+		{"zoned wall time after the DST switch", berlin, "DTSTART;TZID=Europe/Berlin:20260329T100000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatICSTime("DTSTART", start, tt.loc); got != tt.want {
+				t.Errorf("FormatICSTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+func TestMain(m *testing.M) {
+	// Route event changes through the outbox as the server does, so tests see the writes
+	// that feed /v1/events/changes
+	RegisterOutboxSink(NewChangeFeedSink(nil))
+	RegisterOutboxSink(outboxReceiver)
+	m.Run()
+}
+
+// expectEventHistory expects RecordEventHistoryByID to reload the events and append a
+// history row for each
+func expectEventHistory(mock sqlmock.Sqlmock, eventIDs ...uuid.UUID) {
+	rows := sqlmock.NewRows([]string{"id", "title"})
+	for _, id := range eventIDs {
+		rows.AddRow(id.String(), "Event")
+	}
+	mock.ExpectQuery(`SELECT * FROM "events"`).WillReturnRows(rows)
+	for range eventIDs {
+		mock.ExpectExec(`UPDATE "event_history" SET "valid_to"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO "event_history"`).WillReturnRows(testdb.IDs(uuid.New()))
+	}
+}
+
+// expectEventChange expects one change feed entry for eventID to be queued in the outbox
+func expectEventChange(mock sqlmock.Sqlmock, eventID uuid.UUID, changeType, reason string) {
+	payload := testdb.Containing(`"change_type":"`+changeType+`"`, `"reason":"`+reason+`"`)
+	mock.ExpectQuery(`INSERT INTO "outbox_entries"`).
+		WithArgs(append([]driver.Value{"changefeed", OutboxTopicEventChanged, eventID, testdb.Any, payload, OutboxStatusPending},
+			testdb.AnyArgs(6)...)...).
+		WillReturnRows(testdb.IDs(uuid.New()))
+}
+
+// expectAudit expects one audit log entry for action
+func expectAudit(mock sqlmock.Sqlmock, action AuditAction) {
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(append([]driver.Value{testdb.Any, testdb.Any, string(action)}, testdb.AnyArgs(9)...)...).
+		WillReturnRows(testdb.IDs(uuid.New()))
+}
+
+// expectTransition expects TransitionEventState to find eventID in state from, record the
+// transition, and update the event
+func expectTransition(mock sqlmock.Sqlmock, eventID uuid.UUID, from string) {
+	mock.ExpectQuery(`SELECT "id","moderation_state" FROM "events"`).
+		WithArgs(eventID, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "moderation_state"}).AddRow(eventID.String(), from))
+	mock.ExpectQuery(`INSERT INTO "event_state_transitions"`).WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectExec(`UPDATE "events" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// PurgeReport lists what a maintenance purge removed (or would remove on a dry run)
+type PurgeReport struct {
+	DryRun        bool        `json:"dry_run"`
+	OlderThan     time.Time   `json:"older_than"`
+	SubmissionIDs []uuid.UUID `json:"submission_ids"`
+	Flyers        int64       `json:"flyers"`
+	Candidates    int64       `json:"candidates"`
+	// Blocked is the purge of expired moderation blocks that runs alongside, if enabled
+	Blocked *PurgeReport `json:"blocked,omitempty"`
+}
+
+// PurgeFailedSubmissions removes submissions that errored or failed for good before olderThan, along with their
+// flyers, candidates, and stored files. Submissions with a candidate linked to a public event
+// are kept.
+func PurgeFailedSubmissions(db *gorm.DB, storage *StorageService, olderThan time.Time, dryRun bool) (*PurgeReport, error) {
+	report := &PurgeReport{DryRun: dryRun, OlderThan: olderThan}
+
+	if err := db.Model(&models.Submission{}).
+		Where("status IN ? AND updated_at < ?", []string{"error", SubmissionStatusFailed}, olderThan).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM flyers f JOIN event_candidates ec ON ec.flyer_id = f.id
+			WHERE f.submission_id = submissions.id AND ec.published_event_id IS NOT NULL)`).
+		Pluck("id", &report.SubmissionIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to find purgeable submissions: %w", err)
+	}
+	if len(report.SubmissionIDs) == 0 {
+		return report, nil
+	}
+
+	flyerIDs := db.Model(&models.Flyer{}).Select("id").Where("submission_id IN ?", report.SubmissionIDs)
+	if err := db.Model(&models.Flyer{}).Where("submission_id IN ?", report.SubmissionIDs).Count(&report.Flyers).Error; err != nil {
+		return nil, fmt.Errorf("failed to count flyers: %w", err)
+	}
+	if err := db.Model(&models.EventCandidate{}).Where("flyer_id IN (?)", flyerIDs).Count(&report.Candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to count candidates: %w", err)
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txFlyerIDs := tx.Model(&models.Flyer{}).Select("id").Where("submission_id IN ?", report.SubmissionIDs)
+		if err := tx.Where("flyer_id IN (?)", txFlyerIDs).Delete(&models.EventCandidate{}).Error; err != nil {
+			return fmt.Errorf("failed to delete candidates: %w", err)
+		}
+		if err := tx.Where("submission_id IN ?", report.SubmissionIDs).Delete(&models.Flyer{}).Error; err != nil {
+			return fmt.Errorf("failed to delete flyers: %w", err)
+		}
+		if err := tx.Where("id IN ?", report.SubmissionIDs).Delete(&models.Submission{}).Error; err != nil {
+			return fmt.Errorf("failed to delete submissions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Files go last so a failed transaction never leaves rows pointing at missing images
+	for _, id := range report.SubmissionIDs {
+		if err := storage.DeleteSubmissionFiles(id); err != nil {
+			log.Printf("Purge: failed to delete files for submission %s: %v", id, err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// Submission sources
+const (
+	SubmissionSourceUpload = "upload"
+	SubmissionSourceManual = "manual"
+)
+
+// ManualFlyerRegionID marks the placeholder flyer that carries a manual submission's
+// candidate, so queries joining candidates to submissions via flyers keep working.
+const ManualFlyerRegionID = "manual"
+
+// ReviewReasonManualSubmission is recorded on typed-in events, which never auto-publish
+const ReviewReasonManualSubmission = "manual_submission"
+
+// ManualEventFields are the fields a volunteer can type in instead of photographing a flyer
+type ManualEventFields struct {
+	Title       string `json:"title" binding:"required"`
+	Date        string `json:"date"`
+	Venue       string `json:"venue"`
+	Description string `json:"description"`
+	Price       string `json:"price"`
+	URL         string `json:"url"`
+
+	// Attributes carries values for the deployment's extra fields
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// fieldMap converts manual fields to the candidate field names used by vision extraction
+func (m ManualEventFields) fieldMap() map[string]interface{} {
+	fields := map[string]interface{}{
+		"title":       m.Title,
+		"date_time":   m.Date,
+		"venue":       m.Venue,
+		"description": m.Description,
+		"price":       m.Price,
+		"url":         m.URL,
+	}
+	schema := ActiveFieldSchema()
+	for name, value := range m.Attributes {
+		if _, declared := schema.Field(name); declared && !IsBuiltinField(name) {
+			fields[name] = value
+		}
+	}
+	schema.ApplyToFieldMap(fields)
+	return fields
+}
+
+// CreateManualSubmission stores a typed-in event as a submission with a placeholder flyer
+// and a single candidate, ready for Stage 3 processing.
+func CreateManualSubmission(db *gorm.DB, input ManualEventFields) (*models.Submission, error) {
+	fields := input.fieldMap()
+	if _, ok := fields["title"]; !ok {
+		return nil, fmt.Errorf("title is required")
+	}
+
+	fieldsJSON, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manual fields: %w", err)
+	}
+
+	submission := models.Submission{
+		ID:     uuid.New(),
+		Status: "parsed",
+		Source: SubmissionSourceManual,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&submission).Error; err != nil {
+			return fmt.Errorf("failed to create submission: %w", err)
+		}
+
+		flyer := models.Flyer{
+			SubmissionID:        submission.ID,
+			RegionID:            ManualFlyerRegionID,
+			Polygon:             "[]",
+			DetectionConfidence: 1.0,
+		}
+		if err := tx.Create(&flyer).Error; err != nil {
+			return fmt.Errorf("failed to create placeholder flyer: %w", err)
+		}
+
+		candidate := models.EventCandidate{
+			FlyerID:     flyer.ID,
+			EventID:     "manual_1",
+			Fields:      string(fieldsJSON),
+			SearchText:  CandidateSearchText(string(fieldsJSON)),
+			Confidences: "{}",
+			CreatedAt:   time.Now().UTC(),
+		}
+		if err := tx.Create(&candidate).Error; err != nil {
+			return fmt.Errorf("failed to create candidate: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &submission, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestCreateManualSubmission(t *testing.T) {
+	db, mock := testdb.New(t)
+	creates := testdb.RecordCreates(t, db)
+	flyerID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "submissions"`).WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "flyers"`).WillReturnRows(testdb.IDs(flyerID))
+	mock.ExpectQuery(`INSERT INTO "event_candidates"`).WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectCommit()
+
+	submission, err := CreateManualSubmission(db, ManualEventFields{Title: "Bake Sale", Date: "Sat 10am"})
+	if err != nil {
+		t.Fatalf("CreateManualSubmission: %v", err)
+	}
+	if submission.Source != SubmissionSourceManual || submission.Status != "parsed" {
+		t.Errorf("submission source/status = %s/%s, want %s/parsed", submission.Source, submission.Status, SubmissionSourceManual)
+	}
+
+	// The placeholder flyer keeps every candidate -> flyer -> submission join intact
+	flyer := creates.Table("flyers")[0].(*models.Flyer)
+	if flyer.SubmissionID != submission.ID || flyer.RegionID != ManualFlyerRegionID {
+		t.Errorf("flyer = submission %s region %q, want submission %s region %q",
+			flyer.SubmissionID, flyer.RegionID, submission.ID, ManualFlyerRegionID)
+	}
+	candidate := creates.Table("event_candidates")[0].(*models.EventCandidate)
+	if candidate.FlyerID != flyerID {
+		t.Errorf("candidate flyer = %s, want %s", candidate.FlyerID, flyerID)
+	}
+	for _, fragment := range []string{`"title":"Bake Sale"`, `"date_time":"Sat 10am"`} {
+		if !strings.Contains(candidate.Fields, fragment) {
+			t.Errorf("candidate fields %s missing %s", candidate.Fields, fragment)
+		}
+	}
+	if candidate.PublishResult != nil {
+		t.Errorf("candidate publish result = %q, want none until Stage 3 runs", *candidate.PublishResult)
+	}
+}
+
+func TestCreateManualSubmissionRollsBack(t *testing.T) {
+	db, mock := testdb.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "submissions"`).WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "flyers"`).WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectRollback()
+
+	if _, err := CreateManualSubmission(db, ManualEventFields{Title: "Bake Sale"}); err == nil {
+		t.Fatal("CreateManualSubmission succeeded after the flyer insert failed")
+	}
+}
+
+func TestManualFieldMap(t *testing.T) {
+	fields := ManualEventFields{
+		Title:      "Bake Sale",
+		Date:       "Sat 10am",
+		Venue:      "Library",
+		URL:        "example.com",
+		Attributes: map[string]interface{}{"not_a_field": "dropped"},
+	}.fieldMap()
+
+	want := map[string]string{
+		"title":     "Bake Sale",
+		"date_time": "Sat 10am",
+		"venue":     "Library",
+		"url":       "https://example.com",
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("%s = %v, want %q", name, fields[name], value)
+		}
+	}
+	if _, ok := fields["not_a_field"]; ok {
+		t.Error("undeclared attribute was kept")
+	}
+}
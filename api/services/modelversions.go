@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// What a served model was called for
+const (
+	ModelPurposeVision     = "vision"
+	ModelPurposeModeration = "moderation"
+)
+
+// MaxModelReportDays bounds how far back the model version report looks
+const MaxModelReportDays = 365
+
+// RecordServedModel notes that a call for requested was served by served, as the response
+// reported it. The first time a requested model is seen its served model is just recorded;
+// a different served model appearing later means the provider swapped what stands behind
+// the name, and is announced on the outbox (OutboxTopicModelVersionNew). Calls without a
+// served model (failures, mocks) are ignored.
+func RecordServedModel(db *gorm.DB, purpose, requested, served, fingerprint string) error {
+	if requested == "" || served == "" {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var row struct {
+			ID       uuid.UUID
+			Inserted bool
+		}
+		err := tx.Raw(`
+			INSERT INTO model_versions (purpose, requested_model, served_model, last_fingerprint, calls, first_seen_at, last_seen_at)
+			VALUES (?, ?, ?, ?, 1, NOW(), NOW())
+			ON CONFLICT (purpose, requested_model, served_model) DO UPDATE SET
+				calls = model_versions.calls + 1,
+				last_seen_at = NOW(),
+				last_fingerprint = COALESCE(EXCLUDED.last_fingerprint, model_versions.last_fingerprint)
+			RETURNING id, (xmax = 0) AS inserted`,
+			purpose, requested, served, optionalString(fingerprint)).Scan(&row).Error
+		if err != nil {
+			return fmt.Errorf("failed to record served model: %w", err)
+		}
+		if !row.Inserted {
+			return nil
+		}
+
+		var previous []string
+		if err := tx.Model(&models.ModelVersion{}).
+			Where("purpose = ? AND requested_model = ? AND id <> ?", purpose, requested, row.ID).
+			Order("first_seen_at ASC").
+			Pluck("served_model", &previous).Error; err != nil {
+			return fmt.Errorf("failed to load previous served models: %w", err)
+		}
+		if len(previous) == 0 {
+			return nil
+		}
+		return EnqueueOutbox(tx, OutboxTopicModelVersionNew, row.ID, ModelVersionPayload{
+			Purpose:           purpose,
+			RequestedModel:    requested,
+			ServedModel:       served,
+			SystemFingerprint: fingerprint,
+			PreviousModels:    previous,
+		})
+	})
+}
+
+// RecordFlyerVisionModel stores on a flyer the model and prompt behind its current
+// candidates, after a re-extraction replaced them
+func RecordFlyerVisionModel(db *gorm.DB, flyerID uuid.UUID, usage VisionUsage) error {
+	return db.Model(&models.Flyer{}).Where("id = ?", flyerID).Updates(map[string]interface{}{
+		"vision_model":        optionalString(usage.Model),
+		"vision_served_model": optionalString(usage.ServedModel),
+		"vision_fingerprint":  optionalString(usage.SystemFingerprint),
+		"prompt_version":      optionalString(usage.PromptVersion),
+	}).Error
+}
+
+// ServedModelOutcome is one week of candidates extracted by one served model version
+type ServedModelOutcome struct {
+	Week            time.Time `json:"week"` // Monday 00:00 UTC
+	RequestedModel  string    `json:"requested_model"`
+	ServedModel     string    `json:"served_model"`
+	Candidates      int64     `json:"candidates"`
+	Published       int64     `json:"published"` // published or corroborated an event, by anyone
+	PublishRate     float64   `json:"publish_rate"`
+	AutoPublished   int64     `json:"auto_published"`
+	AutoPublishRate float64   `json:"auto_publish_rate"`
+	Corrections     int64     `json:"corrections"`    // admin edits to candidate fields
+	CorrectedRate   float64   `json:"corrected_rate"` // share of candidates edited at least once
+}
+
+// ModelVersionReport lists every served model seen and how each one's extractions fared
+type ModelVersionReport struct {
+	Versions []models.ModelVersion `json:"versions"`
+	Weekly   []ServedModelOutcome  `json:"weekly"`
+}
+
+// ComputeModelVersionReport returns the served model registry and weekly extraction outcomes
+// per served vision model since the given time. Flyers extracted before served models were
+// recorded are left out.
+func ComputeModelVersionReport(db *gorm.DB, since time.Time) (*ModelVersionReport, error) {
+	report := &ModelVersionReport{}
+	if err := db.Order("purpose, requested_model, first_seen_at").Find(&report.Versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load model versions: %w", err)
+	}
+
+	var rows []struct {
+		Week                time.Time
+		RequestedModel      string
+		ServedModel         string
+		Candidates          int64
+		Published           int64
+		AutoPublished       int64
+		Corrections         int64
+		CorrectedCandidates int64
+	}
+	args := append([]interface{}{}, candidateOutcomeArgs()...)
+	if err := db.Table("event_candidates").
+		Select(`date_trunc('week', event_candidates.created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS week,
+			COALESCE(flyers.vision_model, '') AS requested_model, flyers.vision_served_model AS served_model,
+			COUNT(*) FILTER (WHERE event_candidates.published_event_id IS NOT NULL) AS published,
+			`+candidateOutcomeColumns, args...).
+		Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+		Where("flyers.vision_served_model IS NOT NULL AND event_candidates.created_at >= ?", since).
+		Group("1, 2, 3").
+		Order("1, 2, 3").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate served model outcomes: %w", err)
+	}
+
+	report.Weekly = make([]ServedModelOutcome, 0, len(rows))
+	for _, row := range rows {
+		outcome := ServedModelOutcome{
+			Week:           row.Week.UTC(),
+			RequestedModel: row.RequestedModel,
+			ServedModel:    row.ServedModel,
+			Candidates:     row.Candidates,
+			Published:      row.Published,
+			AutoPublished:  row.AutoPublished,
+			Corrections:    row.Corrections,
+		}
+		if row.Candidates > 0 {
+			outcome.PublishRate = float64(row.Published) / float64(row.Candidates)
+			outcome.AutoPublishRate = float64(row.AutoPublished) / float64(row.Candidates)
+			outcome.CorrectedRate = float64(row.CorrectedCandidates) / float64(row.Candidates)
+		}
+		report.Weekly = append(report.Weekly, outcome)
+	}
+	return report, nil
+}
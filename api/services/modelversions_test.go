@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// fakeOpenAI serves chat completions that report being answered by served, as OpenAI does
+// when the model behind a name like gpt-4o changes
+func fakeOpenAI(t *testing.T, served, fingerprint, content string) *openai.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			ID:                "chatcmpl-test",
+			Object:            "chat.completion",
+			Model:             served,
+			SystemFingerprint: fingerprint,
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+				FinishReason: openai.FinishReasonStop,
+			}},
+			Usage: openai.Usage{PromptTokens: 1200, CompletionTokens: 80, TotalTokens: 1280},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+func TestOpenAICallsReportServedModel(t *testing.T) {
+	cfg := &config.Config{OpenAIModel: "gpt-4o", OpenAITimeoutMS: 5000}
+	tests := []struct {
+		name        string
+		served      string
+		fingerprint string
+	}{
+		{"pinned snapshot", "gpt-4o-2024-08-06", "fp_7c6ab6b9de"},
+		{"swapped snapshot", "gpt-4o-2024-11-20", "fp_0fd6ba2d1c"},
+		{"no fingerprint", "gpt-4o-2024-08-06", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vision := &OpenAIVisionProvider{client: fakeOpenAI(t, tt.served, tt.fingerprint, `{"flyers":[]}`), config: cfg}
+			_, usage, err := vision.Complete(context.Background(), VisionRequest{Model: "gpt-4o", Prompt: "Extract", MediaType: "image/jpeg", ImageData: "AA=="})
+			if err != nil {
+				t.Fatalf("vision Complete() error = %v", err)
+			}
+			if usage.Model != "gpt-4o" || usage.ServedModel != tt.served || usage.SystemFingerprint != tt.fingerprint {
+				t.Errorf("vision usage = %+v, want gpt-4o served by %s (%q)", usage, tt.served, tt.fingerprint)
+			}
+
+			moderation := &ModerationService{client: fakeOpenAI(t, tt.served, tt.fingerprint, `{"quality_factors":{},"is_appropriate":true}`), config: cfg}
+			result, err := moderation.ModerateEventCandidate(context.Background(), map[string]interface{}{"title": "Open Mic"})
+			if err != nil {
+				t.Fatalf("ModerateEventCandidate() error = %v", err)
+			}
+			if result.Model != "gpt-4o" || result.ServedModel != tt.served || result.SystemFingerprint != tt.fingerprint {
+				t.Errorf("moderation result served %q (%q) for %q, want %s (%q)", result.ServedModel, result.SystemFingerprint, result.Model, tt.served, tt.fingerprint)
+			}
+		})
+	}
+}
+
+func TestRecordServedModel(t *testing.T) {
+	tests := []struct {
+		name      string
+		served    string
+		inserted  bool     // the served model is new for the requested one
+		previous  []string // served models already recorded for the requested one
+		wantQuery bool
+		wantNote  bool
+	}{
+		{name: "mock result without a served model", served: ""},
+		{name: "first model seen for the name is the baseline", served: "gpt-4o-2024-08-06", inserted: true, wantQuery: true},
+		{name: "same model again", served: "gpt-4o-2024-08-06", wantQuery: true},
+		{name: "swapped model is announced", served: "gpt-4o-2024-11-20", inserted: true, previous: []string{"gpt-4o-2024-05-13", "gpt-4o-2024-08-06"}, wantQuery: true, wantNote: true},
+	}
+
+	withOutboxSink(t, NewSlackNotifier("http://slack.invalid/hook", "https://board.example", time.Second))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			versionID := uuid.New()
+			if tt.wantQuery {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO model_versions (purpose, requested_model, served_model, last_fingerprint, calls, first_seen_at, last_seen_at)`).
+					WithArgs(ModelPurposeVision, "gpt-4o", tt.served, "fp_test").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "inserted"}).AddRow(versionID.String(), tt.inserted))
+				if tt.inserted {
+					previous := sqlmock.NewRows([]string{"served_model"})
+					for _, model := range tt.previous {
+						previous.AddRow(model)
+					}
+					mock.ExpectQuery(`SELECT "served_model" FROM "model_versions" WHERE purpose = $1 AND requested_model = $2 AND id <> $3 ORDER BY first_seen_at ASC`).
+						WithArgs(ModelPurposeVision, "gpt-4o", versionID).
+						WillReturnRows(previous)
+				}
+				if tt.wantNote {
+					mock.ExpectQuery(`INSERT INTO "outbox_entries"`).
+						WithArgs(append([]driver.Value{"notifier", OutboxTopicModelVersionNew, versionID, testdb.Any,
+							testdb.Containing(`"served_model":"gpt-4o-2024-11-20"`, `"previous_models":["gpt-4o-2024-05-13","gpt-4o-2024-08-06"]`)},
+							testdb.AnyArgs(7)...)...).
+						WillReturnRows(testdb.IDs(uuid.New()))
+				}
+				mock.ExpectCommit()
+			}
+
+			if err := RecordServedModel(db, ModelPurposeVision, "gpt-4o", tt.served, "fp_test"); err != nil {
+				t.Fatalf("RecordServedModel() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSlackNotifierModelVersionMessage(t *testing.T) {
+	notifier := NewSlackNotifier("http://slack.invalid/hook", "https://board.example/", time.Second)
+	payload, _ := json.Marshal(ModelVersionPayload{
+		Purpose:        ModelPurposeVision,
+		RequestedModel: "gpt-4o",
+		ServedModel:    "gpt-4o-2024-11-20",
+		PreviousModels: []string{"gpt-4o-2024-05-13", "gpt-4o-2024-08-06"},
+	})
+
+	got, err := notifier.message(&models.OutboxEntry{Topic: OutboxTopicModelVersionNew, Payload: string(payload)})
+	if err != nil {
+		t.Fatalf("message() error = %v", err)
+	}
+	want := "OpenAI is now serving gpt-4o-2024-11-20 for gpt-4o vision calls (previously gpt-4o-2024-05-13, gpt-4o-2024-08-06); watch extraction quality: https://board.example/admin/api/models"
+	if got != want {
+		t.Errorf("message() = %q, want %q", got, want)
+	}
+}
+
+func TestComputeModelVersionReportRates(t *testing.T) {
+	db, mock := testdb.New(t)
+	week := time.Date(2026, 6, 8, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT * FROM "model_versions" ORDER BY purpose, requested_model, first_seen_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "purpose", "requested_model", "served_model"}).
+			AddRow(uuid.New().String(), ModelPurposeVision, "gpt-4o", "gpt-4o-2024-08-06"))
+	mock.ExpectQuery(`JOIN flyers ON flyers.id = event_candidates.flyer_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"week", "requested_model", "served_model", "candidates", "published", "auto_published", "corrections", "corrected_candidates"}).
+			AddRow(week, "gpt-4o", "gpt-4o-2024-08-06", 40, 30, 10, 12, 8).
+			AddRow(week, "gpt-4o", "gpt-4o-2024-11-20", 0, 0, 0, 0, 0))
+
+	report, err := ComputeModelVersionReport(db, week.AddDate(0, 0, -90))
+	if err != nil {
+		t.Fatalf("ComputeModelVersionReport() error = %v", err)
+	}
+	if len(report.Versions) != 1 || len(report.Weekly) != 2 {
+		t.Fatalf("report has %d versions and %d weeks, want 1 and 2", len(report.Versions), len(report.Weekly))
+	}
+	got := report.Weekly[0]
+	if got.PublishRate != 0.75 || got.AutoPublishRate != 0.25 || got.CorrectedRate != 0.2 || got.Corrections != 12 {
+		t.Errorf("rates = %+v, want publish 0.75, auto-publish 0.25, corrected 0.2", got)
+	}
+	if empty := report.Weekly[1]; empty.PublishRate != 0 || empty.AutoPublishRate != 0 || empty.CorrectedRate != 0 {
+		t.Errorf("week without candidates has rates %+v, want zeros", empty)
+	}
+}
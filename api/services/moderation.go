@@ -21,6 +21,15 @@ type ModerationResult struct {
 	IsAppropriate     bool    `json:"is_appropriate"`
 	ModerationReason  *string `json:"moderation_reason,omitempty"`
 	ConfidenceFactors map[string]float64 `json:"confidence_factors"`
+
+	// The model asked for and the one the response reports serving it; empty for mock results
+	Model             string `json:"-"`
+	ServedModel       string `json:"-"`
+	SystemFingerprint string `json:"-"`
+
+	// Tokens billed for the call; zero for mock results
+	PromptTokens     int `json:"-"`
+	CompletionTokens int `json:"-"`
 }
 
 type QualityFactors struct {
@@ -35,7 +44,7 @@ type QualityFactors struct {
 func NewModerationService(cfg *config.Config) *ModerationService {
 	var client *openai.Client
 	if cfg.OpenAIAPIKey != "" {
-		client = openai.NewClient(cfg.OpenAIAPIKey)
+		client = newOpenAIClient(cfg.OpenAIAPIKey)
 	}
 	
 	return &ModerationService{
@@ -100,7 +109,9 @@ Respond in this exact JSON format:
 		MaxTokens: 500,
 	}
 
-	resp, err := m.client.CreateChatCompletion(ctx, req)
+	resp, err := callWithRetry(ctx, NewOpenAIRetryPolicy(m.config), "OpenAI moderation", func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return m.client.CreateChatCompletion(ctx, req)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("moderation API call failed: %w", err)
 	}
@@ -118,10 +129,14 @@ Respond in this exact JSON format:
 		ModerationReason *string        `json:"moderation_reason"`
 	}
 
-	if err := json.Unmarshal([]byte(content), &moderationData); err != nil {
+	if err := json.Unmarshal([]byte(jsonObject(content)), &moderationData); err != nil {
 		log.Printf("Failed to parse moderation response: %v", err)
 		log.Printf("Raw response: %s", content)
-		return m.mockModerationResult(eventData), nil
+		// The call was still billed
+		result := m.mockModerationResult(eventData)
+		result.PromptTokens = resp.Usage.PromptTokens
+		result.CompletionTokens = resp.Usage.CompletionTokens
+		return result, nil
 	}
 
 	// Calculate composite quality score (weighted average)
@@ -142,6 +157,11 @@ Respond in this exact JSON format:
 		IsAppropriate:     moderationData.IsAppropriate,
 		ModerationReason:  moderationData.ModerationReason,
 		ConfidenceFactors: confidenceFactors,
+		Model:             req.Model,
+		ServedModel:       resp.Model,
+		SystemFingerprint: resp.SystemFingerprint,
+		PromptTokens:      resp.Usage.PromptTokens,
+		CompletionTokens:  resp.Usage.CompletionTokens,
 	}, nil
 }
 
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIRetryPolicy bounds how OpenAI calls are retried after transient failures
+type OpenAIRetryPolicy struct {
+	MaxRetries int           // retries after the first attempt
+	BaseDelay  time.Duration // before the first retry; doubles for each one after
+}
+
+// NewOpenAIRetryPolicy reads the policy from config
+func NewOpenAIRetryPolicy(cfg *config.Config) OpenAIRetryPolicy {
+	return OpenAIRetryPolicy{
+		MaxRetries: cfg.OpenAIMaxRetries,
+		BaseDelay:  time.Duration(cfg.OpenAIRetryBaseMS) * time.Millisecond,
+	}
+}
+
+// callWithRetry runs fn, retrying rate limits, 5xx responses, network errors, and attempt
+// timeouts with exponential backoff plus jitter. Anything else (bad requests, auth, an
+// exhausted quota) fails at once, as does a backoff that would outlast ctx's deadline.
+// label names the call, vendor included, in the retry logs.
+func callWithRetry[T any](ctx context.Context, policy OpenAIRetryPolicy, label string, fn func(ctx context.Context) (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		value, err := fn(ctx)
+		if err == nil {
+			if attempt > 0 {
+				log.Printf("%s succeeded after %d retries", label, attempt)
+			}
+			return value, nil
+		}
+		if attempt >= policy.MaxRetries || !retryableOpenAIError(ctx, err) {
+			if attempt > 0 {
+				log.Printf("%s failed after %d retries: %v", label, attempt, err)
+			}
+			return value, err
+		}
+
+		delay := backoffWithJitter(policy.BaseDelay, attempt+1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			log.Printf("%s: no time left to retry after %d attempts: %v", label, attempt+1, err)
+			return value, err
+		}
+		log.Printf("%s: attempt %d of %d failed, retrying in %s: %v", label, attempt+1, policy.MaxRetries+1, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return value, err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryableOpenAIError reports whether another attempt could succeed. An exhausted quota
+// also comes back as 429 but won't clear on its own.
+func retryableOpenAIError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.Code == "insufficient_quota" {
+		return false
+	}
+	return IsRetryableError(err)
+}
+
+// backoffWithJitter is RetryDelay for the attempt, randomized to between half and all of it
+// so callers that failed together don't retry together
+func backoffWithJitter(base time.Duration, attempt int) time.Duration {
+	delay := RetryDelay(base, attempt)
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
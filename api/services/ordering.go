@@ -0,0 +1,133 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// Event lists use one total order: start_ts, then id to break ties between events
+// starting at the same instant. Without the id, equal timestamps come back in whatever
+// order Postgres picks, so offset and cursor pages can skip or repeat events.
+
+// ErrInvalidCursor is returned for a cursor that was not produced by EventCursor.Encode
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorSignatureBytes is how much of the HMAC a cursor carries
+const cursorSignatureBytes = 16
+
+// cursorSigningKey signs event cursors; set once at startup by ConfigureCursorSigning
+var cursorSigningKey []byte
+
+// ConfigureCursorSigning sets the key event cursors are signed with from CURSOR_SIGNING_KEY.
+// Without one a random key is used, so cursors only work on the instance that issued them
+// and until it restarts. Call once at startup, before serving requests.
+func ConfigureCursorSigning(cfg *config.Config) {
+	if cfg.CursorSigningKey != "" {
+		cursorSigningKey = []byte(cfg.CursorSigningKey)
+		return
+	}
+	cursorSigningKey = make([]byte, 32)
+	if _, err := rand.Read(cursorSigningKey); err != nil {
+		log.Fatalf("Failed to generate cursor signing key: %v", err)
+	}
+	log.Println("CURSOR_SIGNING_KEY is not set; event cursors won't survive a restart or work across instances")
+}
+
+// cursorSignature signs a cursor payload. The purpose prefix keeps it from being valid for
+// anything else signed with the same key.
+func cursorSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, cursorSigningKey)
+	mac.Write([]byte("event-cursor:" + payload))
+	return mac.Sum(nil)[:cursorSignatureBytes]
+}
+
+// OrderEventsChronologically applies the total event order to a query on events
+func OrderEventsChronologically(db *gorm.DB) *gorm.DB {
+	return db.Order("events.start_ts ASC").Order("events.id ASC")
+}
+
+// SortEventsChronologically sorts events in memory in the same order as the database
+func SortEventsChronologically(events []models.Event) {
+	sort.Slice(events, func(i, j int) bool {
+		return EventBefore(&events[i], &events[j])
+	})
+}
+
+// EventBefore reports whether a sorts before b
+func EventBefore(a, b *models.Event) bool {
+	if !a.StartTs.Equal(b.StartTs) {
+		return a.StartTs.Before(b.StartTs)
+	}
+	return strings.Compare(a.ID.String(), b.ID.String()) < 0
+}
+
+// EventCursor is the position of the last event on a page, in the total event order
+type EventCursor struct {
+	StartTs time.Time
+	ID      uuid.UUID
+}
+
+// EventCursorAfter returns the cursor that continues after event
+func EventCursorAfter(event *models.Event) EventCursor {
+	return EventCursor{StartTs: event.StartTs, ID: event.ID}
+}
+
+// Encode renders the cursor as an opaque URL-safe token, signed so clients can't forge one
+func (c EventCursor) Encode() string {
+	raw := strconv.FormatInt(c.StartTs.UnixMicro(), 10) + ":" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw)) + "." +
+		base64.RawURLEncoding.EncodeToString(cursorSignature(raw))
+}
+
+// DecodeEventCursor parses a token produced by EventCursor.Encode, rejecting any whose
+// signature doesn't match
+func DecodeEventCursor(token string) (EventCursor, error) {
+	encoded, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, cursorSignature(string(raw))) {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	parsedMicros, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	return EventCursor{StartTs: time.UnixMicro(parsedMicros).UTC(), ID: parsedID}, nil
+}
+
+// Where restricts an events query to rows after the cursor
+func (c EventCursor) Where(db *gorm.DB) *gorm.DB {
+	return db.Where("(events.start_ts, events.id) > (?, ?)", c.StartTs, c.ID)
+}
+
+// After reports whether event sorts after the cursor
+func (c EventCursor) After(event *models.Event) bool {
+	return EventBefore(&models.Event{StartTs: c.StartTs, ID: c.ID}, event)
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// withCursorKey signs cursors with key for the rest of the test
+func withCursorKey(t *testing.T, key string) {
+	t.Helper()
+	previous := cursorSigningKey
+	ConfigureCursorSigning(&config.Config{CursorSigningKey: key})
+	t.Cleanup(func() { cursorSigningKey = previous })
+}
+
+func TestEventCursorRoundTrip(t *testing.T) {
+	withCursorKey(t, "test-key")
+
+	cursor := EventCursor{
+		StartTs: time.Date(2026, 6, 1, 19, 30, 0, 123456000, time.UTC),
+		ID:      uuid.MustParse("5f0c7f5e-1c1a-4b7e-9a51-2f8a8c5d0e11"),
+	}
+	token := cursor.Encode()
+	if strings.ContainsAny(token, "+/= ") {
+		t.Errorf("token %q is not URL-safe", token)
+	}
+
+	decoded, err := DecodeEventCursor(token)
+	if err != nil {
+		t.Fatalf("DecodeEventCursor() error = %v", err)
+	}
+	if !decoded.StartTs.Equal(cursor.StartTs) || decoded.ID != cursor.ID {
+		t.Errorf("DecodeEventCursor() = %+v, want %+v", decoded, cursor)
+	}
+
+	payload, _, _ := strings.Cut(token, ".")
+	forged := EventCursor{StartTs: cursor.StartTs.Add(-time.Hour), ID: cursor.ID}.Encode()
+	_, forgedSig, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"signature from another cursor", payload + "." + forgedSig},
+		{"not base64", "!!!." + forgedSig},
+		{"truncated signature", token[:len(token)-4]},
+		{"offset-style number", "100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeEventCursor(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeEventCursor(%q) error = %v, want ErrInvalidCursor", tt.token, err)
+			}
+		})
+	}
+
+	// A cursor signed with another instance's key is refused
+	withCursorKey(t, "other-key")
+	if _, err := DecodeEventCursor(token); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor from another key: error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+// TestEventCursorPagesStitch generates listings where many events share a start time and
+// checks that following cursors page by page returns every event exactly once, in order
+func TestEventCursorPagesStitch(t *testing.T) {
+	withCursorKey(t, "test-key")
+	base := time.Date(2026, 6, 1, 19, 0, 0, 0, time.UTC)
+	rng := rand.New(rand.NewSource(1987))
+
+	for trial := 0; trial < 200; trial++ {
+		n := 1 + rng.Intn(60)
+		distinctTimes := 1 + rng.Intn(5) // few distinct start times, so most collide
+		pageSize := 1 + rng.Intn(12)
+
+		events := make([]models.Event, n)
+		for i := range events {
+			events[i] = models.Event{ID: uuid.New(), StartTs: base.Add(time.Duration(rng.Intn(distinctTimes)) * time.Hour)}
+		}
+		listing := append([]models.Event(nil), events...)
+		SortEventsChronologically(listing)
+
+		t.Run(fmt.Sprintf("%d events, %d times, pages of %d", n, distinctTimes, pageSize), func(t *testing.T) {
+			seen := make(map[uuid.UUID]int, n)
+			var stitched []models.Event
+			var cursor *EventCursor
+			for pages := 0; ; pages++ {
+				if pages > n {
+					t.Fatal("pagination did not terminate")
+				}
+				page := nextPage(listing, cursor, pageSize)
+				stitched = append(stitched, page...)
+				for _, event := range page {
+					seen[event.ID]++
+				}
+				if len(page) < pageSize {
+					break
+				}
+
+				// The cursor travels through the client as a token
+				decoded, err := DecodeEventCursor(EventCursorAfter(&page[len(page)-1]).Encode())
+				if err != nil {
+					t.Fatal(err)
+				}
+				cursor = &decoded
+			}
+
+			if len(stitched) != n {
+				t.Errorf("stitched %d events, want %d", len(stitched), n)
+			}
+			for id, count := range seen {
+				if count != 1 {
+					t.Errorf("event %s appeared %d times", id, count)
+				}
+			}
+			for i := 1; i < len(stitched); i++ {
+				if !EventBefore(&stitched[i-1], &stitched[i]) {
+					t.Errorf("events %d and %d are out of order", i-1, i)
+				}
+			}
+		})
+	}
+}
+
+// nextPage is what the listing query returns: up to limit events after the cursor
+func nextPage(listing []models.Event, cursor *EventCursor, limit int) []models.Event {
+	var page []models.Event
+	for i := range listing {
+		if cursor != nil && !cursor.After(&listing[i]) {
+			continue
+		}
+		page = append(page, listing[i])
+		if len(page) == limit {
+			break
+		}
+	}
+	return page
+}
+
+func TestEventOrderSQL(t *testing.T) {
+	db, _ := testdb.New(t)
+	cursor := EventCursor{StartTs: time.Date(2026, 6, 1, 19, 0, 0, 0, time.UTC), ID: uuid.New()}
+
+	var events []models.Event
+	stmt := cursor.Where(OrderEventsChronologically(db.Session(&gorm.Session{DryRun: true}).Model(&models.Event{}))).
+		Limit(50).Find(&events).Statement
+
+	sql := stmt.SQL.String()
+	for _, want := range []string{
+		"(events.start_ts, events.id) > ($1, $2)",
+		"ORDER BY events.start_ts ASC,events.id ASC",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query %q does not contain %q", sql, want)
+		}
+	}
+	if len(stmt.Vars) < 2 || stmt.Vars[0] != cursor.StartTs || stmt.Vars[1] != cursor.ID {
+		t.Errorf("query vars = %v, want the cursor's start and id first", stmt.Vars)
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/exif"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// NormalizeSubmissionImage rotates a stored original upright according to its EXIF
+// orientation and records its upright dimensions on the submission. Run it after
+// RecordExifCapture: a rotated original is re-encoded, which drops every EXIF tag.
+func NormalizeSubmissionImage(db *gorm.DB, submissionID uuid.UUID, imagePath string) error {
+	width, height, err := NormalizeOrientation(imagePath)
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&models.Submission{}).Where("id = ?", submissionID).Updates(map[string]interface{}{
+		"image_width":  width,
+		"image_height": height,
+	}).Error
+}
+
+// StripExifUnlessOptedIn removes EXIF (and XMP) metadata from a submission's stored photos
+// unless its submitter allowed it to be read, so neither the served originals nor anything
+// cut from them carry a position the submitter didn't agree to share. Run it after
+// RecordExifCapture and NormalizeSubmissionImage, which read the tags first.
+func StripExifUnlessOptedIn(db *gorm.DB, submissionID uuid.UUID, paths ...string) error {
+	var submission models.Submission
+	if err := db.Select("id", "exif_opt_in").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return err
+	}
+	if submission.ExifOptIn {
+		return nil
+	}
+	for _, path := range paths {
+		if err := stripExif(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stripExif rewrites a JPEG without its metadata segments; files without any are left alone
+func stripExif(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	stripped, removed := exif.Strip(data)
+	if !removed {
+		return nil
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, stripped, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace original: %w", err)
+	}
+	return nil
+}
+
+// NormalizeOrientation applies a JPEG's EXIF orientation to its pixels and rewrites the file
+// without the tag, so the vision model, stored polygons, crops, and overlays all share the
+// upright frame. Files that are already upright are left byte-for-byte unchanged.
+// It returns the upright width and height.
+func NormalizeOrientation(path string) (int, int, error) {
+	orientation, err := readOrientation(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if orientation <= 1 || orientation > 8 {
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer file.Close()
+
+		config, _, err := image.DecodeConfig(file)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unsupported image: %w", err)
+		}
+		return config.Width, config.Height, nil
+	}
+
+	img, err := decodeImageFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	upright := applyOrientation(img, orientation)
+
+	// Write next to the original and swap it in, so a failed encode never leaves a partial file
+	tmpPath := path + ".tmp"
+	if err := writeJPEG(tmpPath, upright); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("failed to replace original: %w", err)
+	}
+
+	bounds := upright.Bounds()
+	return bounds.Dx(), bounds.Dy(), nil
+}
+
+// readOrientation returns the file's EXIF orientation, or 1 when it has none
+func readOrientation(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	data, err := exif.ReadWithoutLocation(file)
+	if err != nil {
+		if errors.Is(err, exif.ErrNoExif) {
+			return 1, nil
+		}
+		return 0, err
+	}
+	return data.Orientation, nil
+}
+
+// applyOrientation returns img transformed to display upright for a TIFF orientation:
+// 2 mirror, 3 rotate 180, 4 flip, 5 transpose, 6 rotate 90 clockwise, 7 transverse,
+// 8 rotate 90 counter-clockwise. Orientations 5-8 swap width and height.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	// source maps a destination pixel to the source pixel that lands there
+	var source func(x, y int) (int, int)
+	switch orientation {
+	case 2:
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		source = func(x, y int) (int, int) { return y, x }
+	case 6:
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return img
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			sx, sy := source(x, y)
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+// The upright scene in every orientation fixture: a red flyer on a white wall with a blue
+// marker in the top-right corner, so any leftover rotation or mirroring moves one of them
+var (
+	uprightSize = image.Pt(48, 32)
+	flyerRect   = image.Rect(8, 8, 24, 24)
+	markerRect  = image.Rect(40, 0, 48, 8)
+	flyerRed    = color.RGBA{R: 220, A: 255}
+	markerBlue  = color.RGBA{B: 220, A: 255}
+)
+
+// storedAt is where a camera writing orientation o stores the upright pixel (x, y), per the
+// TIFF definition of the tag; w and h are the upright width and height
+func storedAt(o, x, y, w, h int) (int, int) {
+	switch o {
+	case 2:
+		return w - 1 - x, y
+	case 3:
+		return w - 1 - x, h - 1 - y
+	case 4:
+		return x, h - 1 - y
+	case 5:
+		return y, x
+	case 6:
+		return y, w - 1 - x
+	case 7:
+		return h - 1 - y, w - 1 - x
+	case 8:
+		return h - 1 - y, x
+	}
+	return x, y
+}
+
+// orientationFixture is testdata/orientation/orientation_<o>.jpg: the upright scene stored
+// as a camera tagging orientation o would. It is written under -update.
+func orientationFixture(t *testing.T, o int) []byte {
+	t.Helper()
+	path := filepath.Join("testdata", "orientation", fmt.Sprintf("orientation_%d.jpg", o))
+	if *update {
+		stored := image.NewRGBA(image.Rect(0, 0, uprightSize.X, uprightSize.Y))
+		if o >= 5 {
+			stored = image.NewRGBA(image.Rect(0, 0, uprightSize.Y, uprightSize.X))
+		}
+		for y := 0; y < uprightSize.Y; y++ {
+			for x := 0; x < uprightSize.X; x++ {
+				c := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+				switch p := image.Pt(x, y); {
+				case p.In(flyerRect):
+					c = flyerRed
+				case p.In(markerRect):
+					c = markerBlue
+				}
+				sx, sy := storedAt(o, x, y, uprightSize.X, uprightSize.Y)
+				stored.Set(sx, sy, c)
+			}
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, stored, &jpeg.Options{Quality: 95}); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, withOrientationTag(buf.Bytes(), o), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read fixture (run with -update to create it): %v", err)
+	}
+	return data
+}
+
+// withOrientationTag inserts an Exif APP1 segment holding only the orientation tag after SOI
+func withOrientationTag(jpegData []byte, o int) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	binary.Write(&tiff, binary.BigEndian, uint32(8))      // IFD0 offset
+	binary.Write(&tiff, binary.BigEndian, uint16(1))      // one entry
+	binary.Write(&tiff, binary.BigEndian, uint16(0x0112)) // Orientation
+	binary.Write(&tiff, binary.BigEndian, uint16(3))      // SHORT
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, uint16(o))
+	binary.Write(&tiff, binary.BigEndian, uint16(0))
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // no next IFD
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, segment...)
+	out = append(out, payload...)
+	return append(out, jpegData[2:]...)
+}
+
+// near reports whether c is within JPEG noise of want
+func near(c color.Color, want color.RGBA) bool {
+	r, g, b, _ := c.RGBA()
+	diff := func(got uint32, want uint8) bool {
+		d := int(got>>8) - int(want)
+		return d > -40 && d < 40
+	}
+	return diff(r, want.R) && diff(g, want.G) && diff(b, want.B)
+}
+
+// TestNormalizeOrientationFixtures runs a photo stored in each EXIF orientation through
+// ingestion and checks that the stored original, a crop cut from the polygon the vision
+// model reports in the upright frame, and the recorded dimensions all agree
+func TestNormalizeOrientationFixtures(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	polygon, err := json.Marshal([]Point{{8, 8}, {24, 8}, {24, 24}, {8, 24}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for o := 1; o <= 8; o++ {
+		t.Run(fmt.Sprintf("orientation %d", o), func(t *testing.T) {
+			fixture := orientationFixture(t, o)
+			path := filepath.Join(t.TempDir(), "original.jpg")
+			if err := os.WriteFile(path, fixture, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// Ingestion records the upright dimensions on the submission
+			db, mock := testdb.New(t)
+			submissionID := uuid.New()
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "submissions" SET "image_height"=$1,"image_width"=$2,"updated_at"=$3 WHERE id = $4`).
+				WithArgs(uprightSize.Y, uprightSize.X, testdb.Any, submissionID).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			if err := NormalizeSubmissionImage(db, submissionID, path); err != nil {
+				t.Fatalf("NormalizeSubmissionImage() error = %v", err)
+			}
+
+			// The tag is gone (or was upright already), so nothing rotates the pixels twice
+			if got, err := readOrientation(path); err != nil || got > 1 {
+				t.Errorf("orientation after normalizing = %d, %v; want none", got, err)
+			}
+			if o == 1 {
+				if stored, _ := os.ReadFile(path); !bytes.Equal(stored, fixture) {
+					t.Error("an upright original was rewritten")
+				}
+			}
+
+			original, err := decodeImageFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := original.Bounds().Size(); got != uprightSize {
+				t.Fatalf("original is %v, want upright %v", got, uprightSize)
+			}
+			for _, check := range []struct {
+				at   image.Point
+				want color.RGBA
+			}{
+				{image.Pt(16, 16), flyerRed},
+				{image.Pt(44, 4), markerBlue},
+				{image.Pt(4, 28), white},
+				{image.Pt(44, 28), white},
+				{image.Pt(4, 4), white},
+			} {
+				if got := original.At(check.at.X, check.at.Y); !near(got, check.want) {
+					t.Errorf("upright pixel %v = %v, want %v", check.at, got, check.want)
+				}
+			}
+
+			// A crop from the upright polygon holds the flyer and nothing else
+			crop := cutFlyerCrop(original, &models.Flyer{Polygon: string(polygon)})
+			if got := crop.Bounds().Size(); got != flyerRect.Size() {
+				t.Fatalf("crop is %v, want %v", got, flyerRect.Size())
+			}
+			for _, at := range []image.Point{{2, 2}, {13, 2}, {2, 13}, {13, 13}, {8, 8}} {
+				if got := crop.At(at.X, at.Y); !near(got, flyerRed) {
+					t.Errorf("crop pixel %v = %v, want the flyer's red", at, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClampPolygon(t *testing.T) {
+	got, changed := clampPolygon([]Point{{-5, 10}, {60, -2}, {50, 40}, {24, 16}}, uprightSize.X, uprightSize.Y)
+	want := []Point{{0, 10}, {48, 0}, {48, 32}, {24, 16}}
+	if changed != 3 || len(got) != len(want) {
+		t.Fatalf("clampPolygon() = %v, %d changed; want %v, 3 changed", got, changed, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("clampPolygon() point %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
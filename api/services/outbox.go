@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outbox topics
+const (
+	OutboxTopicEventChanged         = "event.changed"
+	OutboxTopicSubmissionDone       = "submission.done"
+	OutboxTopicCandidateNeedsReview = "candidate.needs_review"
+	OutboxTopicEventDisputed        = "event.disputed"
+	OutboxTopicModelVersionNew      = "model.new_version"
+)
+
+// Outbox entry statuses
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusDelivered = "delivered"
+	OutboxStatusDead      = "dead"
+)
+
+// MaxOutboxPage bounds a single dead-letter listing
+const MaxOutboxPage = 200
+
+var (
+	// ErrOutboxEntryNotFound is returned when redriving an entry that does not exist
+	ErrOutboxEntryNotFound = errors.New("outbox entry not found")
+	// ErrOutboxEntryNotDead is returned when redriving an entry that is not dead-lettered
+	ErrOutboxEntryNotDead = errors.New("outbox entry is not dead-lettered")
+)
+
+// EventChangePayload is the message for OutboxTopicEventChanged
+type EventChangePayload struct {
+	EventID    uuid.UUID `json:"event_id"`
+	ChangeType string    `json:"change_type"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// SubmissionDonePayload is the message for OutboxTopicSubmissionDone
+type SubmissionDonePayload struct {
+	SubmissionID uuid.UUID `json:"submission_id"`
+	Status       string    `json:"status"`
+}
+
+// CandidateReviewPayload is the message for OutboxTopicCandidateNeedsReview
+type CandidateReviewPayload struct {
+	CandidateID  uuid.UUID `json:"candidate_id"`
+	Title        string    `json:"title,omitempty"`
+	ReviewReason string    `json:"review_reason,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// EventDisputePayload is the message for OutboxTopicEventDisputed
+type EventDisputePayload struct {
+	FlagID    uuid.UUID `json:"flag_id"`
+	EventID   uuid.UUID `json:"event_id"`
+	VenueID   uuid.UUID `json:"venue_id"`
+	VenueName string    `json:"venue_name"`
+	Reason    string    `json:"reason"`
+}
+
+// ModelVersionPayload is the message for OutboxTopicModelVersionNew
+type ModelVersionPayload struct {
+	Purpose           string   `json:"purpose"`
+	RequestedModel    string   `json:"requested_model"`
+	ServedModel       string   `json:"served_model"`
+	SystemFingerprint string   `json:"system_fingerprint,omitempty"`
+	PreviousModels    []string `json:"previous_models"` // served for the same requested model before
+}
+
+// OutboxSink delivers outbox entries for the topics it handles. Deliver may be called more
+// than once for the same entry (after a crash or lease expiry), so sinks pass the entry's
+// IdempotencyKey along or otherwise make repeated deliveries harmless.
+type OutboxSink interface {
+	Name() string
+	Handles(topic string) bool
+	Deliver(ctx context.Context, entry *models.OutboxEntry) error
+}
+
+var (
+	outboxSinksMu sync.RWMutex
+	outboxSinks   []OutboxSink
+)
+
+// RegisterOutboxSink adds a sink at startup. Only entries written after registration are
+// routed to it, so sinks must be registered before the server takes requests.
+func RegisterOutboxSink(sink OutboxSink) {
+	outboxSinksMu.Lock()
+	defer outboxSinksMu.Unlock()
+	outboxSinks = append(outboxSinks, sink)
+}
+
+// outboxSink returns the registered sink with the given name, or nil
+func outboxSink(name string) OutboxSink {
+	outboxSinksMu.RLock()
+	defer outboxSinksMu.RUnlock()
+	for _, sink := range outboxSinks {
+		if sink.Name() == name {
+			return sink
+		}
+	}
+	return nil
+}
+
+// outboxSinksFor returns the registered sinks that handle topic
+func outboxSinksFor(topic string) []OutboxSink {
+	outboxSinksMu.RLock()
+	defer outboxSinksMu.RUnlock()
+	var sinks []OutboxSink
+	for _, sink := range outboxSinks {
+		if sink.Handles(topic) {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks
+}
+
+// EnqueueOutbox writes one pending entry per sink that handles topic, using the given
+// db or transaction so the side effect commits (or rolls back) with the state change
+func EnqueueOutbox(db *gorm.DB, topic string, aggregateID uuid.UUID, payload interface{}) error {
+	sinks := outboxSinksFor(topic)
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s outbox payload: %w", topic, err)
+	}
+
+	key := uuid.New().String()
+	now := time.Now().UTC()
+	for _, sink := range sinks {
+		entry := models.OutboxEntry{
+			Sink:           sink.Name(),
+			Topic:          topic,
+			AggregateID:    aggregateID,
+			IdempotencyKey: key,
+			Payload:        string(payloadJSON),
+			Status:         OutboxStatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			return fmt.Errorf("failed to write %s outbox entry: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// ListDeadLetters returns dead-lettered entries, newest first, optionally for one sink
+func ListDeadLetters(db *gorm.DB, sink string, limit int) ([]models.OutboxEntry, error) {
+	if limit <= 0 || limit > MaxOutboxPage {
+		limit = MaxOutboxPage
+	}
+
+	query := db.Where("status = ?", OutboxStatusDead)
+	if sink != "" {
+		query = query.Where("sink = ?", sink)
+	}
+	var entries []models.OutboxEntry
+	if err := query.Order("created_at DESC").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return entries, nil
+}
+
+// RedriveOutboxEntry puts a dead-lettered entry back in the queue with a fresh attempt budget.
+// Its idempotency key is unchanged, so receivers that saw an earlier attempt can drop it.
+func RedriveOutboxEntry(db *gorm.DB, id uuid.UUID) (*models.OutboxEntry, error) {
+	var entry models.OutboxEntry
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOutboxEntryNotFound
+			}
+			return err
+		}
+		if entry.Status != OutboxStatusDead {
+			return ErrOutboxEntryNotDead
+		}
+
+		entry.Status = OutboxStatusPending
+		entry.Attempts = 0
+		entry.NextAttemptAt = time.Now().UTC()
+		return tx.Model(&entry).Updates(map[string]interface{}{
+			"status":          entry.Status,
+			"attempts":        entry.Attempts,
+			"next_attempt_at": entry.NextAttemptAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// OutboxDispatcher delivers pending outbox entries to their sinks, retrying failures with
+// exponential backoff and dead-lettering entries that run out of attempts
+type OutboxDispatcher struct {
+	db          *gorm.DB
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	baseDelay   time.Duration
+	lease       time.Duration
+}
+
+// NewOutboxDispatcher creates a dispatcher. Claimed entries are leased for OutboxLeaseSec;
+// if the process dies mid-delivery the entry becomes due again once the lease runs out.
+func NewOutboxDispatcher(db *gorm.DB, cfg *config.Config) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		db:          db,
+		interval:    time.Duration(cfg.OutboxPollIntervalMS) * time.Millisecond,
+		batchSize:   cfg.OutboxBatchSize,
+		maxAttempts: cfg.OutboxMaxAttempts,
+		baseDelay:   time.Duration(cfg.OutboxBaseDelaySec) * time.Second,
+		lease:       time.Duration(cfg.OutboxLeaseSec) * time.Second,
+	}
+}
+
+// Start polls for due entries on a ticker in the background
+func (d *OutboxDispatcher) Start() {
+	if d.interval <= 0 {
+		log.Println("Outbox dispatcher disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			d.RunOnce()
+		}
+	}()
+}
+
+// RunOnce claims and delivers up to one batch of due entries, logging (not failing) on errors
+func (d *OutboxDispatcher) RunOnce() {
+	entries, err := d.claim(time.Now().UTC())
+	if err != nil {
+		log.Printf("Outbox dispatcher: failed to claim entries: %v", err)
+		return
+	}
+
+	for i := range entries {
+		d.deliver(&entries[i])
+	}
+}
+
+// claim locks due entries (skipping ones another instance holds), counts the attempt, and
+// pushes their next attempt out by the lease so nothing else picks them up meanwhile
+func (d *OutboxDispatcher) claim(now time.Time) ([]models.OutboxEntry, error) {
+	var entries []models.OutboxEntry
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", OutboxStatusPending, now).
+			Order("next_attempt_at ASC").
+			Limit(d.batchSize).
+			Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(entries))
+		for i := range entries {
+			ids[i] = entries[i].ID
+			entries[i].Attempts++
+			entries[i].NextAttemptAt = now.Add(d.lease)
+		}
+		return tx.Model(&models.OutboxEntry{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"attempts":        gorm.Expr("attempts + 1"),
+				"next_attempt_at": now.Add(d.lease),
+			}).Error
+	})
+	return entries, err
+}
+
+// deliver hands one claimed entry to its sink and records the outcome. Updates are fenced on
+// the attempt count so a dispatcher whose lease expired can't overwrite a newer attempt.
+func (d *OutboxDispatcher) deliver(entry *models.OutboxEntry) {
+	sink := outboxSink(entry.Sink)
+	var err error
+	if sink == nil {
+		err = fmt.Errorf("no sink registered as %q", entry.Sink)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), d.lease)
+		err = sink.Deliver(ctx, entry)
+		cancel()
+	}
+
+	updates := map[string]interface{}{}
+	now := time.Now().UTC()
+	switch {
+	case err == nil:
+		updates["status"] = OutboxStatusDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = nil
+	case entry.Attempts >= d.maxAttempts || !isRetryableDelivery(err):
+		log.Printf("Outbox dispatcher: dead-lettering %s entry %s after %d attempts: %v", entry.Sink, entry.ID, entry.Attempts, err)
+		updates["status"] = OutboxStatusDead
+		updates["last_error"] = err.Error()
+	default:
+		log.Printf("Outbox dispatcher: %s delivery of %s failed (attempt %d/%d): %v", entry.Sink, entry.ID, entry.Attempts, d.maxAttempts, err)
+		updates["next_attempt_at"] = now.Add(RetryDelay(d.baseDelay, entry.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	if err := d.db.Model(&models.OutboxEntry{}).
+		Where("id = ? AND attempts = ? AND status = ?", entry.ID, entry.Attempts, OutboxStatusPending).
+		Updates(updates).Error; err != nil {
+		log.Printf("Outbox dispatcher: failed to record delivery of %s: %v", entry.ID, err)
+	}
+}
+
+// isRetryableDelivery reports whether a failed delivery may succeed later. Upstream 4xx
+// responses (other than 429) are permanent; anything else is worth another attempt.
+func isRetryableDelivery(err error) bool {
+	var statusErr *UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+	return true
+}
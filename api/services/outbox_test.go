@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// outboxTestTopic is only handled by outboxReceiver, so other tests' writes are unaffected
+const outboxTestTopic = "test.outbox"
+
+// idempotentReceiver is a sink that, like a well-behaved webhook receiver, applies each
+// idempotency key once however many times it is delivered
+type idempotentReceiver struct {
+	mu         sync.Mutex
+	deliveries int
+	applied    map[string]int
+	fail       error
+}
+
+var outboxReceiver = &idempotentReceiver{}
+
+func (r *idempotentReceiver) Name() string { return "test-receiver" }
+
+func (r *idempotentReceiver) Handles(topic string) bool { return topic == outboxTestTopic }
+
+func (r *idempotentReceiver) Deliver(ctx context.Context, entry *models.OutboxEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries++
+	if r.fail != nil {
+		return r.fail
+	}
+	if r.applied[entry.IdempotencyKey] == 0 {
+		r.applied[entry.IdempotencyKey]++
+	}
+	return nil
+}
+
+// reset clears what the receiver saw and makes it fail with err (nil to succeed)
+func (r *idempotentReceiver) reset(t *testing.T, err error) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries, r.applied, r.fail = 0, map[string]int{}, err
+}
+
+func (r *idempotentReceiver) counts() (deliveries, applied int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range r.applied {
+		applied += n
+	}
+	return r.deliveries, applied
+}
+
+// outboxRow is a pending entry for the test receiver as a claim query returns it
+func outboxRow(id uuid.UUID, key string, attempts int) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "sink", "topic", "aggregate_id", "idempotency_key", "payload", "status", "attempts", "next_attempt_at", "created_at"}).
+		AddRow(id.String(), "test-receiver", outboxTestTopic, uuid.New().String(), key, `{}`, OutboxStatusPending, attempts, time.Now(), time.Now())
+}
+
+// expectClaim expects one claim transaction that locks rows and, when there are any,
+// counts the attempt and extends the lease
+func expectClaim(mock sqlmock.Sqlmock, rows *sqlmock.Rows, claimed bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "outbox_entries" WHERE status = $1 AND next_attempt_at <= $2 ORDER BY next_attempt_at ASC LIMIT $3 FOR UPDATE SKIP LOCKED`).
+		WithArgs(OutboxStatusPending, testdb.Any, 10).
+		WillReturnRows(rows)
+	if claimed {
+		mock.ExpectExec(`UPDATE "outbox_entries" SET "attempts"=attempts + 1,"next_attempt_at"=$1 WHERE id IN ($2)`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+// expectOutcome expects the delivery result to be recorded, fenced on the attempt count
+func expectOutcome(mock sqlmock.Sqlmock, id uuid.UUID, attempts int, set string, rowsAffected int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "outbox_entries" SET `+set).
+		WithArgs(testdb.Any, testdb.Any, testdb.Any, id, attempts, OutboxStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+	mock.ExpectCommit()
+}
+
+func testDispatcher(db *gorm.DB) *OutboxDispatcher {
+	return NewOutboxDispatcher(db, &config.Config{OutboxBatchSize: 10, OutboxMaxAttempts: 3, OutboxBaseDelaySec: 10, OutboxLeaseSec: 30})
+}
+
+func TestEnqueueOutboxCommitsWithTheStateChange(t *testing.T) {
+	outboxReceiver.reset(t, nil)
+	db, mock := testdb.New(t)
+	aggregateID := uuid.New()
+
+	// A state change that fails after writing its outbox row takes the row with it
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "outbox_entries"`).
+		WithArgs(append([]driver.Value{"test-receiver", outboxTestTopic, aggregateID}, testdb.AnyArgs(9)...)...).
+		WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectRollback()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := EnqueueOutbox(tx, outboxTestTopic, aggregateID, map[string]string{"status": "done"}); err != nil {
+			return err
+		}
+		return errors.New("state change failed")
+	})
+	if err == nil {
+		t.Fatal("transaction committed")
+	}
+
+	// Nothing was left behind for the dispatcher
+	expectClaim(mock, sqlmock.NewRows([]string{"id"}), false)
+	testDispatcher(db).RunOnce()
+	if deliveries, _ := outboxReceiver.counts(); deliveries != 0 {
+		t.Errorf("rolled-back entry was delivered %d times", deliveries)
+	}
+
+	// Topics no sink handles write nothing
+	if err := EnqueueOutbox(db, "test.unhandled", aggregateID, nil); err != nil {
+		t.Errorf("EnqueueOutbox() for an unhandled topic = %v", err)
+	}
+}
+
+// TestOutboxDispatcherCrashes kills a dispatcher at each point between claiming an entry
+// and recording its delivery, then lets another dispatcher take over once the lease runs
+// out. Every scenario must apply the entry exactly once at the receiver.
+func TestOutboxDispatcherCrashes(t *testing.T) {
+	tests := []struct {
+		name string
+		// crash runs the first dispatcher up to the point it dies
+		crash func(t *testing.T, d *OutboxDispatcher, mock sqlmock.Sqlmock, id uuid.UUID, key string)
+		// reclaimAttempts is the attempt count the replacement finds; a committed claim counts
+		reclaimAttempts int
+		wantDeliveries  int
+	}{
+		{
+			name: "after claiming, before delivering",
+			crash: func(t *testing.T, d *OutboxDispatcher, mock sqlmock.Sqlmock, id uuid.UUID, key string) {
+				expectClaim(mock, outboxRow(id, key, 0), true)
+				if entries, err := d.claim(time.Now()); err != nil || len(entries) != 1 {
+					t.Fatalf("claim() = %d entries, %v", len(entries), err)
+				}
+			},
+			reclaimAttempts: 1,
+			wantDeliveries:  1,
+		},
+		{
+			name: "after delivering, before recording it",
+			crash: func(t *testing.T, d *OutboxDispatcher, mock sqlmock.Sqlmock, id uuid.UUID, key string) {
+				expectClaim(mock, outboxRow(id, key, 0), true)
+				entries, err := d.claim(time.Now())
+				if err != nil || len(entries) != 1 {
+					t.Fatalf("claim() = %d entries, %v", len(entries), err)
+				}
+				if err := outboxReceiver.Deliver(context.Background(), &entries[0]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reclaimAttempts: 1,
+			wantDeliveries:  2,
+		},
+		{
+			name: "while the database was unreachable",
+			crash: func(t *testing.T, d *OutboxDispatcher, mock sqlmock.Sqlmock, id uuid.UUID, key string) {
+				mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+				d.RunOnce()
+			},
+			wantDeliveries: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outboxReceiver.reset(t, nil)
+			db, mock := testdb.New(t)
+			id, key := uuid.New(), uuid.New().String()
+
+			tt.crash(t, testDispatcher(db), mock, id, key)
+
+			// The replacement finds the entry due again once the lease has run out, and
+			// fences its result on the attempt it claimed
+			expectClaim(mock, outboxRow(id, key, tt.reclaimAttempts), true)
+			expectOutcome(mock, id, tt.reclaimAttempts+1, `"delivered_at"=$1,"last_error"=$2,"status"=$3`, 1)
+			testDispatcher(db).RunOnce()
+
+			deliveries, applied := outboxReceiver.counts()
+			if deliveries != tt.wantDeliveries || applied != 1 {
+				t.Errorf("receiver got %d deliveries and applied %d; want %d deliveries applied once", deliveries, applied, tt.wantDeliveries)
+			}
+		})
+	}
+}
+
+func TestOutboxStaleDispatcherCannotOverwrite(t *testing.T) {
+	outboxReceiver.reset(t, nil)
+	db, mock := testdb.New(t)
+	id, key := uuid.New(), uuid.New().String()
+
+	// The slow dispatcher's lease expired and another claimed attempt 2; its late result
+	// for attempt 1 matches no row
+	entry := models.OutboxEntry{ID: id, Sink: "test-receiver", Topic: outboxTestTopic, IdempotencyKey: key, Attempts: 1}
+	expectOutcome(mock, id, 1, `"delivered_at"=$1,"last_error"=$2,"status"=$3`, 0)
+	testDispatcher(db).deliver(&entry)
+
+	if _, applied := outboxReceiver.counts(); applied != 1 {
+		t.Errorf("applied %d times, want 1", applied)
+	}
+}
+
+func TestOutboxDeliveryFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		sink     string
+		err      error
+		attempts int
+		wantSet  string
+	}{
+		{"retryable failure is retried", "test-receiver", errors.New("connection reset"), 1, `"last_error"=$1,"next_attempt_at"=$2`},
+		{"rate limited is retried", "test-receiver", &UpstreamStatusError{Service: "webhook", StatusCode: 429}, 2, `"last_error"=$1,"next_attempt_at"=$2`},
+		{"last attempt is dead-lettered", "test-receiver", errors.New("connection reset"), 3, `"last_error"=$1,"status"=$2`},
+		{"rejected by the receiver is dead-lettered at once", "test-receiver", &UpstreamStatusError{Service: "webhook", StatusCode: 400}, 1, `"last_error"=$1,"status"=$2`},
+		{"unregistered sink is retried", "gone", nil, 1, `"last_error"=$1,"next_attempt_at"=$2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outboxReceiver.reset(t, tt.err)
+			db, mock := testdb.New(t)
+			id := uuid.New()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "outbox_entries" SET `+tt.wantSet+` WHERE id = $3 AND attempts = $4 AND status = $5`).
+				WithArgs(testdb.Any, testdb.Any, id, tt.attempts, OutboxStatusPending).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			testDispatcher(db).deliver(&models.OutboxEntry{ID: id, Sink: tt.sink, Topic: outboxTestTopic, Attempts: tt.attempts})
+		})
+	}
+}
+
+func TestRedriveOutboxEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string // "" when the entry doesn't exist
+		wantErr error
+	}{
+		{"dead letter goes back in the queue", OutboxStatusDead, nil},
+		{"pending entry is left alone", OutboxStatusPending, ErrOutboxEntryNotDead},
+		{"delivered entry is left alone", OutboxStatusDelivered, ErrOutboxEntryNotDead},
+		{"missing entry", "", ErrOutboxEntryNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			id, key := uuid.New(), uuid.New().String()
+
+			rows := sqlmock.NewRows([]string{"id", "idempotency_key", "status", "attempts"})
+			if tt.status != "" {
+				rows.AddRow(id.String(), key, tt.status, 8)
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT * FROM "outbox_entries" WHERE id = $1`).WithArgs(id, testdb.Any).WillReturnRows(rows)
+			if tt.wantErr == nil {
+				mock.ExpectExec(`UPDATE "outbox_entries" SET "attempts"=$1,"next_attempt_at"=$2,"status"=$3 WHERE "id" = $4`).
+					WithArgs(0, testdb.Any, OutboxStatusPending, id).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			entry, err := RedriveOutboxEntry(db, id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RedriveOutboxEntry() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (entry.Status != OutboxStatusPending || entry.Attempts != 0 || entry.IdempotencyKey != key) {
+				t.Errorf("redriven entry = %+v, want pending with no attempts and the same key", entry)
+			}
+		})
+	}
+}
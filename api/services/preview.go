@@ -0,0 +1,54 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidPreviewLink is returned for a preview link whose signature doesn't match
+	ErrInvalidPreviewLink = errors.New("invalid preview link")
+	// ErrPreviewLinkExpired is returned for a correctly signed link past its expiry
+	ErrPreviewLinkExpired = errors.New("preview link expired")
+)
+
+// candidatePreviewSignature signs a candidate ID and expiry. The purpose prefix keeps
+// these signatures from being valid for anything else signed with the same key.
+func candidatePreviewSignature(key string, candidateID uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "candidate-preview:%s:%d", candidateID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CandidatePreviewURL is a signed link to a candidate's preview that works without
+// admin credentials until expires, for organizers to confirm details before publishing
+func CandidatePreviewURL(baseURL, key string, candidateID uuid.UUID, expires time.Time) string {
+	return fmt.Sprintf("%s/preview/candidates/%s?expires=%d&sig=%s",
+		baseURL, candidateID, expires.Unix(), candidatePreviewSignature(key, candidateID, expires.Unix()))
+}
+
+// VerifyCandidatePreview checks the expires and sig parameters of a signed preview link
+func VerifyCandidatePreview(key string, candidateID uuid.UUID, expires, sig string, now time.Time) error {
+	if key == "" {
+		return ErrInvalidPreviewLink
+	}
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidPreviewLink
+	}
+	expected := candidatePreviewSignature(key, candidateID, expiresUnix)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrInvalidPreviewLink
+	}
+	if now.After(time.Unix(expiresUnix, 0)) {
+		return ErrPreviewLinkExpired
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestVerifyCandidatePreview(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	candidateID := uuid.New()
+
+	signed, err := url.Parse(CandidatePreviewURL("https://williamboard.example", "preview-key", candidateID, now.Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/preview/candidates/" + candidateID.String(); signed.Path != want {
+		t.Errorf("preview path = %q, want %q", signed.Path, want)
+	}
+	expires, sig := signed.Query().Get("expires"), signed.Query().Get("sig")
+
+	tests := []struct {
+		name        string
+		key         string
+		candidateID uuid.UUID
+		expires     string
+		sig         string
+		now         time.Time
+		want        error
+	}{
+		{"valid", "preview-key", candidateID, expires, sig, now, nil},
+		{"valid until the last second", "preview-key", candidateID, expires, sig, now.Add(time.Hour), nil},
+		{"expired", "preview-key", candidateID, expires, sig, now.Add(time.Hour + time.Second), ErrPreviewLinkExpired},
+		{"another candidate", "preview-key", uuid.New(), expires, sig, now, ErrInvalidPreviewLink},
+		{"extended expiry", "preview-key", candidateID, "9999999999", sig, now, ErrInvalidPreviewLink},
+		{"tampered signature", "preview-key", candidateID, expires, strings.Repeat("0", len(sig)), now, ErrInvalidPreviewLink},
+		{"missing signature", "preview-key", candidateID, expires, "", now, ErrInvalidPreviewLink},
+		{"expiry not a number", "preview-key", candidateID, "tomorrow", sig, now, ErrInvalidPreviewLink},
+		{"signed with another key", "other-key", candidateID, expires, sig, now, ErrInvalidPreviewLink},
+		{"signing disabled", "", candidateID, expires, sig, now, ErrInvalidPreviewLink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyCandidatePreview(tt.key, tt.candidateID, tt.expires, tt.sig, tt.now)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("VerifyCandidatePreview() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDraftEventFromCandidate checks the merge that both the preview and publishing use:
+// whatever the candidate's fields say now, edits included, is what the event gets
+func TestDraftEventFromCandidate(t *testing.T) {
+	reference := time.Date(2026, 5, 27, 9, 0, 0, 0, time.UTC) // a Wednesday
+	venueID := uuid.New()
+	existingID := uuid.New()
+
+	tests := []struct {
+		name         string
+		fields       string
+		venueRows    *sqlmock.Rows
+		existingRows *sqlmock.Rows
+		wantErr      string
+		wantTitle    string
+		wantStart    time.Time
+		wantEnd      *time.Time
+		wantDesc     string
+		wantPrice    string
+		wantVenue    string
+		wantNewVenue bool
+		wantVenueID  bool
+		wantExisting bool
+	}{
+		{
+			name:         "edited fields with a new venue",
+			fields:       `{"title":"Open Mic (edited)","date_time":"2026-06-05 19:30","end_date":"2026-06-05 22:00","venue":"The Rookery","address":"12 Main St","description":"Sign-ups at 7","price":"$5"}`,
+			venueRows:    sqlmock.NewRows([]string{"id"}),
+			existingRows: sqlmock.NewRows([]string{"id"}),
+			wantTitle:    "Open Mic (edited)",
+			wantStart:    time.Date(2026, 6, 5, 19, 30, 0, 0, time.UTC),
+			wantEnd:      timePtr(time.Date(2026, 6, 5, 22, 0, 0, 0, time.UTC)),
+			wantDesc:     "Sign-ups at 7",
+			wantPrice:    "$5",
+			wantVenue:    "The Rookery",
+			wantNewVenue: true,
+		},
+		{
+			name:         "known venue is linked",
+			fields:       `{"title":"Trivia","date_time":"2026-06-03 20:00","venue":"doug fir"}`,
+			venueRows:    sqlmock.NewRows([]string{"id", "name"}).AddRow(venueID.String(), "Doug Fir"),
+			existingRows: sqlmock.NewRows([]string{"id"}),
+			wantTitle:    "Trivia",
+			wantStart:    time.Date(2026, 6, 3, 20, 0, 0, 0, time.UTC),
+			wantVenue:    "Doug Fir",
+			wantVenueID:  true,
+		},
+		{
+			name:         "duplicate of a published event",
+			fields:       `{"title":"Trivia","date_time":"2026-06-03 20:00"}`,
+			existingRows: sqlmock.NewRows([]string{"id", "title"}).AddRow(existingID.String(), "Trivia"),
+			wantTitle:    "Trivia",
+			wantStart:    time.Date(2026, 6, 3, 20, 0, 0, 0, time.UTC),
+			wantExisting: true,
+		},
+		{
+			name:         "end before start is dropped",
+			fields:       `{"title":"Late Show","date_time":"2026-06-03 23:00","end_date":"2026-06-03 21:00"}`,
+			existingRows: sqlmock.NewRows([]string{"id"}),
+			wantTitle:    "Late Show",
+			wantStart:    time.Date(2026, 6, 3, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "relative date is read from when the flyer was submitted",
+			fields:       `{"title":"Trivia","date":"Friday 8pm"}`,
+			existingRows: sqlmock.NewRows([]string{"id"}),
+			wantTitle:    "Trivia",
+			wantStart:    time.Date(2026, 5, 29, 20, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "title edited away",
+			fields:  `{"title":"","date_time":"2026-06-03 20:00"}`,
+			wantErr: "event title is required",
+		},
+		{
+			name:    "fields are not JSON",
+			fields:  `{"title":`,
+			wantErr: "failed to parse event fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			candidate := &models.EventCandidate{ID: uuid.New(), FlyerID: uuid.New(), Fields: tt.fields, CreatedAt: reference}
+			if tt.existingRows != nil {
+				mock.ExpectQuery(`FROM "submissions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(`FROM "events" WHERE canonical_key = $1`).
+					WithArgs(EventCanonicalKey(tt.wantTitle, tt.wantStart), testdb.Any).
+					WillReturnRows(tt.existingRows)
+			}
+			if tt.venueRows != nil {
+				mock.ExpectQuery(`FROM "venues" WHERE name ILIKE $1`).WillReturnRows(tt.venueRows)
+			}
+
+			draft, err := DraftEventFromCandidate(db, candidate, time.UTC)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DraftEventFromCandidate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DraftEventFromCandidate() error = %v", err)
+			}
+
+			event := draft.Event
+			if event.Title != tt.wantTitle || !event.StartTs.Equal(tt.wantStart) {
+				t.Errorf("event = %q at %v, want %q at %v", event.Title, event.StartTs, tt.wantTitle, tt.wantStart)
+			}
+			if (event.EndTs == nil) != (tt.wantEnd == nil) || (tt.wantEnd != nil && !event.EndTs.Equal(*tt.wantEnd)) {
+				t.Errorf("end = %v, want %v", event.EndTs, tt.wantEnd)
+			}
+			if got := derefString(event.Description); got != tt.wantDesc {
+				t.Errorf("description = %q, want %q", got, tt.wantDesc)
+			}
+			if got := derefString(event.Price); got != tt.wantPrice {
+				t.Errorf("price = %q, want %q", got, tt.wantPrice)
+			}
+			if event.ModerationState != EventStateApproved || event.CanonicalKey != EventCanonicalKey(tt.wantTitle, tt.wantStart) {
+				t.Errorf("event state %q, key %q", event.ModerationState, event.CanonicalKey)
+			}
+
+			switch {
+			case tt.wantVenue == "" && draft.Venue != nil:
+				t.Errorf("venue = %+v, want none", draft.Venue)
+			case tt.wantVenue != "" && (draft.Venue == nil || draft.Venue.Name != tt.wantVenue):
+				t.Errorf("venue = %+v, want %q", draft.Venue, tt.wantVenue)
+			}
+			if draft.NewVenue != tt.wantNewVenue {
+				t.Errorf("NewVenue = %v, want %v", draft.NewVenue, tt.wantNewVenue)
+			}
+			if tt.wantNewVenue && derefString(draft.Venue.AddressLine) != "12 Main St" {
+				t.Errorf("new venue address = %v", draft.Venue.AddressLine)
+			}
+			if (event.VenueID != nil) != tt.wantVenueID || (tt.wantVenueID && *event.VenueID != venueID) {
+				t.Errorf("VenueID = %v, want linked: %v", event.VenueID, tt.wantVenueID)
+			}
+			if (draft.Existing != nil) != tt.wantExisting || (tt.wantExisting && draft.Existing.ID != existingID) {
+				t.Errorf("Existing = %+v, want existing: %v", draft.Existing, tt.wantExisting)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+// maxPriceTiers bounds how many tiers a single price string can produce
+const maxPriceTiers = 10
+
+var (
+	// priceSeparators split "$15 adv / $20 door; free for members"
+	priceSeparators = regexp.MustCompile(`\s*(?:/|;|,|\||\n|\bor\b)\s*`)
+	// priceAmount matches "$15", "$7.50", and "15 dollars"
+	priceAmount = regexp.MustCompile(`\$\s*(\d{1,5}(?:\.\d{1,2})?)|(\d{1,5}(?:\.\d{1,2})?)\s*(?:dollars|usd)\b`)
+	// bareAmount matches plain numbers in a segment whose string used "$" elsewhere ("$10-20")
+	bareAmount = regexp.MustCompile(`\b(\d{1,5}(?:\.\d{1,2})?)\b`)
+	// priceRange matches "10-20" / "10 to 20" with an optional leading "$"
+	priceRange = regexp.MustCompile(`\$?\s*(\d{1,5}(?:\.\d{1,2})?)\s*(?:-|–|to)\s*\$?\s*(\d{1,5}(?:\.\d{1,2})?)`)
+	// labelNoise is stripped from labels once amounts are removed
+	labelNoise = regexp.MustCompile(`(?i)\$|\b(?:dollars|usd|for|at|the|tix|tickets?|cover|entry|admission)\b|[():]`)
+)
+
+// priceLabelAliases normalizes common flyer abbreviations
+var priceLabelAliases = map[string]string{
+	"adv":         "advance",
+	"adv.":        "advance",
+	"advanced":    "advance",
+	"presale":     "advance",
+	"pre-sale":    "advance",
+	"dos":         "door",
+	"day of":      "door",
+	"day of show": "door",
+	"at door":     "door",
+	"mbr":         "members",
+	"mbrs":        "members",
+	"member":      "members",
+	"student":     "students",
+	"kid":         "kids",
+	"child":       "kids",
+	"children":    "kids",
+	"sr":          "seniors",
+	"senior":      "seniors",
+	"ga":          "general admission",
+	"general":     "general admission",
+}
+
+// openPricePhrases mark tiers without a fixed amount
+var openPricePhrases = []string{"pay what you can", "pay what you want", "pwyc", "pwyw", "donation", "by donation", "suggested donation"}
+
+// ParsePriceTiers turns a flyer price string into structured tiers. ticketURL is attached to
+// tiers that can be bought ahead of time (everything except door pricing).
+func ParsePriceTiers(price, ticketURL string) models.PriceTiers {
+	price = strings.TrimSpace(price)
+	if price == "" {
+		return nil
+	}
+	ticketURL = SanitizeField("url", ticketURL)
+	usesDollarSign := strings.Contains(price, "$")
+
+	var tiers models.PriceTiers
+	for _, segment := range priceSeparators.Split(price, -1) {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		tiers = append(tiers, parsePriceSegment(segment, usesDollarSign)...)
+		if len(tiers) >= maxPriceTiers {
+			tiers = tiers[:maxPriceTiers]
+			break
+		}
+	}
+
+	for i := range tiers {
+		if ticketURL != "" && tiers[i].Label != "door" {
+			tiers[i].URL = ticketURL
+		}
+	}
+	return tiers
+}
+
+// parsePriceSegment parses one separator-delimited piece of a price string
+func parsePriceSegment(segment string, usesDollarSign bool) models.PriceTiers {
+	lower := strings.ToLower(segment)
+
+	// "$10-20 sliding scale" becomes a low and a high tier
+	if match := priceRange.FindStringSubmatchIndex(lower); match != nil && (usesDollarSign || strings.Contains(lower, "sliding")) {
+		low, _ := parseCents(lower[match[2]:match[3]])
+		high, _ := parseCents(lower[match[4]:match[5]])
+		label := priceLabel(lower[:match[0]] + " " + lower[match[1]:])
+		if label == "" {
+			label = "sliding scale"
+		}
+		return models.PriceTiers{
+			{Label: label + " (low)", AmountCents: &low},
+			{Label: label + " (high)", AmountCents: &high},
+		}
+	}
+
+	amounts := priceAmount.FindAllStringSubmatchIndex(lower, -1)
+	if len(amounts) == 0 && usesDollarSign {
+		amounts = bareAmount.FindAllStringSubmatchIndex(lower, -1)
+	}
+
+	if len(amounts) > 0 {
+		var tiers models.PriceTiers
+		rest := lower
+		for _, match := range amounts {
+			start, end := match[2], match[3]
+			if start < 0 {
+				start, end = match[4], match[5]
+			}
+			cents, err := parseCents(lower[start:end])
+			if err != nil {
+				continue
+			}
+			rest = strings.Replace(rest, lower[match[0]:match[1]], " ", 1)
+			amount := cents
+			tiers = append(tiers, models.PriceTier{AmountCents: &amount})
+		}
+		label := priceLabel(rest)
+		if isOpenPrice(lower) && label == "" {
+			label = "suggested"
+		}
+		for i := range tiers {
+			tiers[i].Label = label
+		}
+		return tiers
+	}
+
+	if isOpenPrice(lower) {
+		return models.PriceTiers{{Label: "pay what you can"}}
+	}
+
+	if phrase := freePhrase(lower); phrase != "" {
+		zero := 0
+		label := priceLabel(strings.Replace(lower, phrase, " ", 1))
+		if label == "" {
+			label = "free"
+		}
+		return models.PriceTiers{{Label: label, AmountCents: &zero}}
+	}
+
+	return nil
+}
+
+// priceLabel cleans the non-amount text of a segment into a short label
+func priceLabel(text string) string {
+	for _, phrase := range openPricePhrases {
+		text = strings.ReplaceAll(text, phrase, " ")
+	}
+	text = labelNoise.ReplaceAllString(text, " ")
+	text = strings.Join(strings.Fields(text), " ")
+	text = strings.Trim(text, " .-–")
+
+	if alias, ok := priceLabelAliases[text]; ok {
+		return alias
+	}
+	return SanitizeText(text, 50, false)
+}
+
+// freePhrases mark no-charge tiers
+var freePhrases = []string{"no cover", "no charge", "free"}
+
+func freePhrase(lower string) string {
+	for _, phrase := range freePhrases {
+		if strings.Contains(lower, phrase) {
+			return phrase
+		}
+	}
+	return ""
+}
+
+func isOpenPrice(lower string) bool {
+	for _, phrase := range openPricePhrases {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCents(amount string) (int, error) {
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(value * 100)), nil
+}
+
+// PriceRange returns the lowest and highest fixed amounts across tiers
+func PriceRange(tiers models.PriceTiers) (min, max *int) {
+	for _, tier := range tiers {
+		if tier.AmountCents == nil {
+			continue
+		}
+		amount := *tier.AmountCents
+		if min == nil || amount < *min {
+			min = &amount
+		}
+		if max == nil || amount > *max {
+			max = &amount
+		}
+	}
+	return min, max
+}
+
+// ValidatePriceTiers checks admin-supplied tiers and cleans their labels and URLs
+func ValidatePriceTiers(tiers models.PriceTiers) (models.PriceTiers, error) {
+	if len(tiers) > maxPriceTiers {
+		return nil, fmt.Errorf("at most %d price tiers are allowed", maxPriceTiers)
+	}
+
+	cleaned := make(models.PriceTiers, 0, len(tiers))
+	for i, tier := range tiers {
+		if tier.AmountCents != nil && *tier.AmountCents < 0 {
+			return nil, fmt.Errorf("tier %d: amount_cents must not be negative", i)
+		}
+		tier.Label = SanitizeText(tier.Label, 50, false)
+		if tier.URL != "" {
+			if tier.URL = SanitizeField("url", tier.URL); tier.URL == "" {
+				return nil, fmt.Errorf("tier %d: url must be an http(s) link", i)
+			}
+		}
+		cleaned = append(cleaned, tier)
+	}
+	return cleaned, nil
+}
+
+// ParseCandidatePriceTiers parses tiers from a candidate's price field, attaching its
+// ticket_url (or general url) to tiers that can be bought ahead of time
+func ParseCandidatePriceTiers(fields map[string]interface{}) models.PriceTiers {
+	price, _ := fields["price"].(string)
+	ticketURL, _ := fields["ticket_url"].(string)
+	if ticketURL == "" {
+		ticketURL, _ = fields["url"].(string)
+	}
+	return ParsePriceTiers(price, ticketURL)
+}
+
+// ApplyPriceTiers sets an event's tiers and keeps the min/max summary columns in sync
+func ApplyPriceTiers(event *models.Event, tiers models.PriceTiers) {
+	if len(tiers) == 0 {
+		tiers = nil
+	}
+	event.PriceTiers = tiers
+	event.PriceMinCents, event.PriceMaxCents = PriceRange(tiers)
+}
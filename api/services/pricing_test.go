@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestParsePriceTiers(t *testing.T) {
+	// Price lines as they appear on real flyers
+	tests := []struct {
+		price string
+		want  string
+	}{
+		{"", ""},
+		{"TBA", ""},
+		{"$7.50", "=750"},
+		{"20 dollars", "=2000"},
+		{"Tickets $35", "=3500"},
+		{"$10/$15", "=1000, =1500"},
+		{"$15 adv / $20 door", "advance=1500, door=2000"},
+		{"$10 advance, $15 day of show", "advance=1000, door=1500"},
+		{"$25 presale or $30 at the door", "advance=2500, door=3000"},
+		{"$5 members / $10 non-members", "members=500, non-members=1000"},
+		{"FREE for members, $5 general", "members=0, general admission=500"},
+		{"$12 students; $18 general", "students=1200, general admission=1800"},
+		{"Kids free, adults $8", "kids=0, adults=800"},
+		{"Free", "free=0"},
+		{"No cover", "free=0"},
+		{"$10-20 sliding scale", "sliding scale (low)=1000, sliding scale (high)=2000"},
+		{"sliding scale 5-15", "sliding scale (low)=500, sliding scale (high)=1500"},
+		{"Pay what you can", "pay what you can=?"},
+		{"PWYC ($5 suggested)", "suggested=500"},
+		{"Suggested donation $10", "suggested=1000"},
+		{strings.Repeat("$1 / ", 15), strings.TrimSuffix(strings.Repeat("=100, ", maxPriceTiers), ", ")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.price, func(t *testing.T) {
+			if got := formatTiers(ParsePriceTiers(tt.price, "")); got != tt.want {
+				t.Errorf("ParsePriceTiers(%q) = %q, want %q", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePriceTiersTicketURL(t *testing.T) {
+	tiers := ParsePriceTiers("$15 adv / $20 door", "tickets.example.com/show")
+	if tiers[0].URL != "https://tickets.example.com/show" {
+		t.Errorf("advance tier URL = %q, want the ticket link", tiers[0].URL)
+	}
+	if tiers[1].URL != "" {
+		t.Errorf("door tier URL = %q, want none", tiers[1].URL)
+	}
+}
+
+func TestPriceRange(t *testing.T) {
+	tests := []struct {
+		price            string
+		wantMin, wantMax string
+	}{
+		{"", "nil", "nil"},
+		{"Pay what you can", "nil", "nil"},
+		{"$15 adv / $20 door", "1500", "2000"},
+		{"Kids free, adults $8", "0", "800"},
+	}
+
+	for _, tt := range tests {
+		min, max := PriceRange(ParsePriceTiers(tt.price, ""))
+		if formatCents(min) != tt.wantMin || formatCents(max) != tt.wantMax {
+			t.Errorf("PriceRange(%q) = %s..%s, want %s..%s", tt.price, formatCents(min), formatCents(max), tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestValidatePriceTiers(t *testing.T) {
+	negative, ten := -1, 1000
+
+	tests := []struct {
+		name    string
+		tiers   models.PriceTiers
+		wantErr bool
+	}{
+		{"valid", models.PriceTiers{{Label: " door ", AmountCents: &ten, URL: "https://example.com"}}, false},
+		{"negative amount", models.PriceTiers{{Label: "door", AmountCents: &negative}}, true},
+		{"script URL", models.PriceTiers{{Label: "door", URL: "javascript:alert(1)"}}, true},
+		{"too many tiers", make(models.PriceTiers, maxPriceTiers+1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleaned, err := ValidatePriceTiers(tt.tiers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePriceTiers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cleaned[0].Label != "door" {
+				t.Errorf("label = %q, want it trimmed", cleaned[0].Label)
+			}
+		})
+	}
+}
+
+// formatTiers renders tiers as "label=cents, ..." with "?" for open amounts
+func formatTiers(tiers models.PriceTiers) string {
+	parts := make([]string, 0, len(tiers))
+	for _, tier := range tiers {
+		parts = append(parts, tier.Label+"="+strings.Replace(formatCents(tier.AmountCents), "nil", "?", 1))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatCents(cents *int) string {
+	if cents == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*cents)
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/internal/dateparse"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// EventDraft is the public event a candidate would publish as, built from its current
+// (possibly edited) fields without writing anything. Publishing and the moderator
+// preview both start from it, so the preview shows exactly what approval creates.
+type EventDraft struct {
+	Event models.Event
+	// Venue is the existing venue the event would use, or an unsaved one to create.
+	// Nil when the candidate names no venue.
+	Venue    *models.Venue
+	NewVenue bool
+	// Existing is set when an event with the same canonical key already exists; the
+	// candidate would corroborate it instead of creating a new event
+	Existing *models.Event
+}
+
+// EventCanonicalKey is the key events are deduplicated on: the lowercased title and the
+// start date
+func EventCanonicalKey(title string, startTs time.Time) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "_" + startTs.Format("2006-01-02")
+}
+
+// DraftEventFromCandidate merges a candidate's fields into the event it would publish
+func DraftEventFromCandidate(db *gorm.DB, candidate *models.EventCandidate, loc *time.Location) (*EventDraft, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse event fields: %v", err)
+	}
+
+	// Extract required title field
+	title, ok := fields["title"].(string)
+	if !ok || title == "" {
+		return nil, errors.New("event title is required")
+	}
+	schema := ActiveFieldSchema()
+	if missing := schema.MissingRequired(fields); len(missing) > 0 {
+		return nil, fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	// Parse start time relative to when the flyer was photographed
+	startTs := time.Now().Add(24 * time.Hour) // fallback to tomorrow to ensure future events
+	if parsed, dateStr, ok := ParseCandidateStart(db, candidate, fields, loc); ok {
+		fmt.Printf("Parsed date '%s' as %s (%s, confidence %.2f) for event: %s\n", dateStr, parsed.Time, parsed.Granularity, parsed.Confidence, title)
+		startTs = parsed.Time
+	} else if dateStr != "" {
+		fmt.Printf("Failed to parse date '%s', using fallback\n", dateStr)
+	}
+
+	// Create canonical key for deduplication (title + date)
+	canonicalKey := EventCanonicalKey(title, startTs)
+
+	draft := &EventDraft{}
+	var existing models.Event
+	if err := db.Where("canonical_key = ?", canonicalKey).First(&existing).Error; err == nil {
+		draft.Existing = &existing
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	draft.Event = models.Event{
+		CanonicalKey:    canonicalKey,
+		Title:           title,
+		StartTs:         startTs,
+		Source:          "flyer",
+		PublishedVia:    "manual",
+		QualityScore:    candidate.CompositeScore,
+		ModerationState: EventStateApproved,
+	}
+	event := &draft.Event
+
+	// Extract optional fields
+	if desc, ok := fields["description"].(string); ok && desc != "" {
+		event.Description = &desc
+	}
+	if url, ok := fields["url"].(string); ok && url != "" {
+		event.URL = &url
+	}
+	if price, ok := fields["price"].(string); ok && price != "" {
+		event.Price = &price
+	}
+	ApplyPriceTiers(event, ParseCandidatePriceTiers(fields))
+	if organizer, ok := fields["organizer"].(string); ok && organizer != "" {
+		event.Organizer = &organizer
+	}
+	event.Attributes = schema.PublicAttributes(fields)
+
+	// Handle end time if provided
+	if endStr, ok := fields["end_date"].(string); ok && endStr != "" {
+		if parsed, err := dateparse.Parse(endStr, startTs, loc); err == nil && parsed.Time.After(startTs) {
+			event.EndTs = &parsed.Time
+		}
+	}
+
+	// Match the venue by name; an unknown venue is created on publish
+	venue, isNew, err := CandidateVenue(db, fields)
+	if err != nil {
+		return nil, err
+	}
+	if venue != nil && !isNew {
+		event.VenueID = &venue.ID
+	}
+	draft.Venue, draft.NewVenue = venue, isNew
+
+	return draft, nil
+}
+
+// CandidateVenue finds the venue a candidate's fields name, matching by name. An unknown
+// venue comes back unsaved, with the candidate's address, and isNew set. Nil when the
+// candidate names no venue.
+func CandidateVenue(db *gorm.DB, fields map[string]interface{}) (venue *models.Venue, isNew bool, err error) {
+	venueName, ok := fields["venue"].(string)
+	if !ok || venueName == "" {
+		return nil, false, nil
+	}
+	var existing models.Venue
+	if err := db.Where("name ILIKE ?", venueName).First(&existing).Error; err == nil {
+		return &existing, false, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	venue = &models.Venue{Name: venueName}
+	if addr, ok := fields["address"].(string); ok && addr != "" {
+		venue.AddressLine = &addr
+	}
+	return venue, true, nil
+}
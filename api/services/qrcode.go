@@ -0,0 +1,210 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/multi/qrcode"
+	"gorm.io/gorm"
+)
+
+// QRCodeLink is a QR code holding an http(s) link and the centre of the code in the photo
+type QRCodeLink struct {
+	URL string
+	X   float64
+	Y   float64
+}
+
+// ScanQRCodeLinks decodes every QR code in an image, keeping those that hold a plain
+// http(s) link. An image without any readable code returns nil.
+func ScanQRCodeLinks(img image.Image) ([]QRCodeLink, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to binarize image: %w", err)
+	}
+
+	results, err := qrcode.NewQRCodeMultiReader().DecodeMultiple(bitmap, map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	})
+	if err != nil {
+		var notFound gozxing.NotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to decode QR codes: %w", err)
+	}
+
+	var links []QRCodeLink
+	for _, result := range results {
+		link := SanitizeField("url", result.GetText())
+		points := result.GetResultPoints()
+		if link == "" || len(points) == 0 {
+			continue
+		}
+		var x, y float64
+		for _, point := range points {
+			x += point.GetX()
+			y += point.GetY()
+		}
+		links = append(links, QRCodeLink{URL: link, X: x / float64(len(points)), Y: y / float64(len(points))})
+	}
+	return links, nil
+}
+
+// AttachQRCodeLinks scans one photo of a submission for QR codes and hands each link to the
+// flyer it was printed on: the flyer whose polygon contains the code, or failing that the one
+// whose centroid is nearest. A flyer's candidates get the closest of its codes as url when
+// they have none, and all of its links at the front of qr_urls, since decoded links are
+// exact where the vision model's transcription often isn't. It returns how many candidates
+// changed.
+func AttachQRCodeLinks(db *gorm.DB, storage *StorageService, submissionID uuid.UUID, imageIndex int) (int, error) {
+	var flyers []models.Flyer
+	if err := db.Where("submission_id = ? AND image_index = ?", submissionID, imageIndex).Find(&flyers).Error; err != nil {
+		return 0, fmt.Errorf("failed to load flyers: %w", err)
+	}
+	if len(flyers) == 0 {
+		return 0, nil
+	}
+
+	img, err := decodeStoredImage(storage, submissionID, SubmissionImageFilename(imageIndex))
+	if err != nil {
+		return 0, err
+	}
+	links, err := ScanQRCodeLinks(img)
+	if err != nil || len(links) == 0 {
+		return 0, err
+	}
+
+	polygons := make([][]Point, len(flyers))
+	for i := range flyers {
+		json.Unmarshal([]byte(flyers[i].Polygon), &polygons[i])
+	}
+
+	// Each code belongs to one flyer; several codes on one flyer are ordered nearest first
+	assigned := make(map[int][]QRCodeLink)
+	for _, link := range links {
+		if i := qrCodeFlyer(polygons, link); i >= 0 {
+			assigned[i] = append(assigned[i], link)
+		}
+	}
+
+	updated := 0
+	for i, flyerLinks := range assigned {
+		cx, cy := polygonCentroid(polygons[i])
+		sort.SliceStable(flyerLinks, func(a, b int) bool {
+			return math.Hypot(flyerLinks[a].X-cx, flyerLinks[a].Y-cy) < math.Hypot(flyerLinks[b].X-cx, flyerLinks[b].Y-cy)
+		})
+		count, err := attachFlyerQRCodeLinks(db, flyers[i].ID, flyerLinks)
+		if err != nil {
+			return updated, err
+		}
+		updated += count
+	}
+	return updated, nil
+}
+
+// qrCodeFlyer picks the flyer a code was printed on, or -1 when there are no usable polygons
+func qrCodeFlyer(polygons [][]Point, link QRCodeLink) int {
+	best, bestDistance := -1, math.Inf(1)
+	for i, polygon := range polygons {
+		if len(polygon) < 3 {
+			continue
+		}
+		if polygonContains(polygon, link.X, link.Y) {
+			return i
+		}
+		cx, cy := polygonCentroid(polygon)
+		if distance := math.Hypot(link.X-cx, link.Y-cy); distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// attachFlyerQRCodeLinks writes a flyer's decoded links into each of its candidates
+func attachFlyerQRCodeLinks(db *gorm.DB, flyerID uuid.UUID, links []QRCodeLink) (int, error) {
+	var candidates []models.EventCandidate
+	if err := db.Where("flyer_id = ?", flyerID).Find(&candidates).Error; err != nil {
+		return 0, fmt.Errorf("failed to load candidates: %w", err)
+	}
+
+	updated := 0
+	for _, candidate := range candidates {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
+			continue
+		}
+
+		changed := false
+		if current, _ := fields["url"].(string); current == "" {
+			fields["url"] = links[0].URL
+			changed = true
+		}
+
+		qrURLs := make([]interface{}, 0, len(links))
+		seen := make(map[string]bool)
+		for _, link := range links {
+			if !seen[link.URL] {
+				seen[link.URL] = true
+				qrURLs = append(qrURLs, link.URL)
+			}
+		}
+		existing, _ := fields["qr_urls"].([]interface{})
+		for _, raw := range existing {
+			if link, ok := raw.(string); ok && !seen[link] {
+				seen[link] = true
+				qrURLs = append(qrURLs, link)
+			}
+		}
+		if len(qrURLs) != len(existing) {
+			changed = true
+		}
+		fields["qr_urls"] = qrURLs
+
+		if !changed {
+			continue
+		}
+		fieldsJSON, err := json.Marshal(fields)
+		if err != nil {
+			return updated, fmt.Errorf("failed to marshal event fields: %w", err)
+		}
+		if err := db.Model(&models.EventCandidate{}).Where("id = ?", candidate.ID).Updates(map[string]interface{}{
+			"fields":      string(fieldsJSON),
+			"search_text": CandidateSearchText(string(fieldsJSON)),
+		}).Error; err != nil {
+			return updated, fmt.Errorf("failed to update candidate: %w", err)
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// polygonCentroid is the mean of a polygon's vertices
+func polygonCentroid(points []Point) (float64, float64) {
+	var x, y float64
+	for _, p := range points {
+		x += p.X
+		y += p.Y
+	}
+	n := float64(max(len(points), 1))
+	return x / n, y / n
+}
+
+// polygonContains reports whether (x, y) lies inside the polygon, by ray casting
+func polygonContains(points []Point, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		a, b := points[i], points[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons reported when processing is paused
+const (
+	PauseReasonCircuitBreaker = "circuit_breaker"
+)
+
+// defaultProcessingTime is assumed until a submission has finished in the last hour
+const defaultProcessingTime = 30 * time.Second
+
+// processingTimeWindow is how far back processing times are averaged
+const processingTimeWindow = time.Hour
+
+// ProcessingQueue bounds how many submissions are processed at once. Uploads beyond
+// the limit wait their turn in arrival order, which lets clients be told where they
+// stand. It is in-process only; each API instance has its own queue.
+type ProcessingQueue struct {
+	concurrency int
+
+	mu        sync.Mutex
+	running   map[uuid.UUID]time.Time
+	waiting   []uuid.UUID
+	turns     map[uuid.UUID]chan struct{}
+	completed []completedRun
+	breakers  []*CircuitBreaker
+}
+
+// completedRun is one finished submission, kept for the rolling average
+type completedRun struct {
+	finishedAt time.Time
+	duration   time.Duration
+}
+
+// Backpressure tells an upload client what to expect while a submission waits
+type Backpressure struct {
+	QueuePosition          int     `json:"queuePosition"`        // 0 once processing has started
+	EstimatedWaitSeconds   int     `json:"estimatedWaitSeconds"` // until processing is expected to finish
+	ProcessingPaused       bool    `json:"processing_paused"`
+	ProcessingPausedReason *string `json:"processing_paused_reason,omitempty"`
+}
+
+// NewProcessingQueue allows concurrency submissions to process at once (0 means no limit)
+func NewProcessingQueue(concurrency int) *ProcessingQueue {
+	return &ProcessingQueue{
+		concurrency: concurrency,
+		running:     make(map[uuid.UUID]time.Time),
+		turns:       make(map[uuid.UUID]chan struct{}),
+	}
+}
+
+// WatchBreakers reports processing as paused while any of the breakers is open
+func (q *ProcessingQueue) WatchBreakers(breakers ...*CircuitBreaker) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.breakers = append(q.breakers, breakers...)
+}
+
+// Join puts a submission in line without waiting, so work handed to a background worker
+// counts toward estimates before the worker picks it up. Acquire then waits for that turn.
+func (q *ProcessingQueue) Join(submissionID uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.running[submissionID]; ok {
+		return
+	}
+	if _, ok := q.turns[submissionID]; ok {
+		return
+	}
+	if q.concurrency <= 0 || (len(q.running) < q.concurrency && len(q.waiting) == 0) {
+		q.running[submissionID] = time.Now()
+		return
+	}
+	q.waiting = append(q.waiting, submissionID)
+	q.turns[submissionID] = make(chan struct{})
+}
+
+// Acquire blocks until the submission may be processed. The returned release must be
+// called when processing ends; it records the run's duration for estimates.
+func (q *ProcessingQueue) Acquire(submissionID uuid.UUID) (release func()) {
+	q.mu.Lock()
+	if _, ok := q.running[submissionID]; ok {
+		// Its turn came while it was joined; time the run from now
+		q.running[submissionID] = time.Now()
+		q.mu.Unlock()
+		return q.releaser(submissionID)
+	}
+	if turn, ok := q.turns[submissionID]; ok {
+		q.mu.Unlock()
+		<-turn
+		q.mu.Lock()
+		q.running[submissionID] = time.Now()
+		q.mu.Unlock()
+		return q.releaser(submissionID)
+	}
+	if q.concurrency <= 0 || (len(q.running) < q.concurrency && len(q.waiting) == 0) {
+		q.running[submissionID] = time.Now()
+		q.mu.Unlock()
+		return q.releaser(submissionID)
+	}
+	turn := make(chan struct{})
+	q.waiting = append(q.waiting, submissionID)
+	q.turns[submissionID] = turn
+	q.mu.Unlock()
+
+	<-turn
+	return q.releaser(submissionID)
+}
+
+// releaser frees the submission's slot exactly once and hands it to the next waiter
+func (q *ProcessingQueue) releaser(submissionID uuid.UUID) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+
+			now := time.Now()
+			if started, ok := q.running[submissionID]; ok {
+				q.completed = append(q.completed, completedRun{finishedAt: now, duration: now.Sub(started)})
+				delete(q.running, submissionID)
+			}
+			q.pruneLocked(now)
+
+			if len(q.waiting) > 0 {
+				next := q.waiting[0]
+				q.waiting = q.waiting[1:]
+				q.running[next] = now
+				close(q.turns[next])
+				delete(q.turns, next)
+			}
+		})
+	}
+}
+
+// pruneLocked drops runs that finished before the averaging window
+func (q *ProcessingQueue) pruneLocked(now time.Time) {
+	cutoff := now.Add(-processingTimeWindow)
+	keep := 0
+	for keep < len(q.completed) && q.completed[keep].finishedAt.Before(cutoff) {
+		keep++
+	}
+	q.completed = q.completed[keep:]
+}
+
+// averageLocked is the mean processing time over the last hour
+func (q *ProcessingQueue) averageLocked(now time.Time) time.Duration {
+	q.pruneLocked(now)
+	if len(q.completed) == 0 {
+		return defaultProcessingTime
+	}
+	var total time.Duration
+	for _, run := range q.completed {
+		total += run.duration
+	}
+	return total / time.Duration(len(q.completed))
+}
+
+// Estimate reports where a submission stands. A submission the queue hasn't seen yet
+// (for example one that is about to be uploaded) is estimated as joining the back.
+func (q *ProcessingQueue) Estimate(submissionID uuid.UUID) Backpressure {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	avg := q.averageLocked(now)
+	var wait time.Duration
+	position := 0
+
+	if started, ok := q.running[submissionID]; ok {
+		wait = avg - now.Sub(started)
+	} else {
+		position = len(q.waiting) + 1
+		for i, id := range q.waiting {
+			if id == submissionID {
+				position = i + 1
+				break
+			}
+		}
+		wait = q.queuedWait(position, avg)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+
+	result := Backpressure{
+		QueuePosition:        position,
+		EstimatedWaitSeconds: int((wait + time.Second - 1) / time.Second),
+	}
+	if position > 0 && len(q.waiting) == 0 && (q.concurrency <= 0 || len(q.running) < q.concurrency) {
+		// A free slot: the submission starts as soon as it arrives
+		result.QueuePosition = 0
+	}
+	for _, breaker := range q.breakers {
+		if !breaker.Allow() {
+			reason := PauseReasonCircuitBreaker
+			result.ProcessingPaused = true
+			result.ProcessingPausedReason = &reason
+			break
+		}
+	}
+	return result
+}
+
+// queuedWait estimates the time until a submission at position finishes: the rounds of
+// work ahead of it across all slots, then its own run
+func (q *ProcessingQueue) queuedWait(position int, avg time.Duration) time.Duration {
+	if q.concurrency <= 0 {
+		return avg
+	}
+	rounds := (position - 1 + len(q.running)) / q.concurrency
+	return time.Duration(rounds+1) * avg
+}
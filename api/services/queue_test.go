@@ -0,0 +1,194 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestProcessingQueueEstimate(t *testing.T) {
+	target := uuid.New()
+	ids := func(n int) []uuid.UUID {
+		out := make([]uuid.UUID, n)
+		for i := range out {
+			out[i] = uuid.New()
+		}
+		return out
+	}
+	recent := []completedRun{{finishedAt: time.Now(), duration: 10 * time.Second}, {finishedAt: time.Now(), duration: 30 * time.Second}}
+
+	tests := []struct {
+		name         string
+		concurrency  int
+		running      int           // other submissions processing, started just now
+		targetRunFor time.Duration // >0 puts target among the running, started this long ago
+		waitingAhead int
+		targetWaits  bool // target joins the line after waitingAhead
+		waitingAfter int
+		completed    []completedRun
+		wantPosition int
+		wantWait     int
+	}{
+		{
+			name:        "idle queue starts at once",
+			concurrency: 2,
+			completed:   recent,
+			wantWait:    20,
+		},
+		{
+			name:        "free slot beside a running submission",
+			concurrency: 2,
+			running:     1,
+			completed:   recent,
+			wantWait:    20,
+		},
+		{
+			name:         "all slots busy waits one round",
+			concurrency:  2,
+			running:      2,
+			completed:    recent,
+			wantPosition: 1,
+			wantWait:     40,
+		},
+		{
+			name:         "third in line behind two full slots",
+			concurrency:  2,
+			running:      2,
+			waitingAhead: 2,
+			targetWaits:  true,
+			waitingAfter: 3,
+			completed:    recent,
+			wantPosition: 3,
+			wantWait:     60,
+		},
+		{
+			name:         "unseen submission joins the back",
+			concurrency:  1,
+			running:      1,
+			waitingAhead: 4,
+			completed:    recent,
+			wantPosition: 5,
+			wantWait:     120,
+		},
+		{
+			name:         "running submission counts down",
+			concurrency:  2,
+			targetRunFor: 5 * time.Second,
+			completed:    recent,
+			wantWait:     15,
+		},
+		{
+			name:         "running past the average never goes negative",
+			concurrency:  2,
+			targetRunFor: time.Minute,
+			completed:    recent,
+			wantWait:     0,
+		},
+		{
+			name:        "no recent history assumes the default",
+			concurrency: 2,
+			wantWait:    30,
+		},
+		{
+			name:        "runs older than an hour are forgotten",
+			concurrency: 2,
+			completed:   []completedRun{{finishedAt: time.Now().Add(-2 * time.Hour), duration: 10 * time.Minute}},
+			wantWait:    30,
+		},
+		{
+			name:        "unlimited concurrency is one run",
+			concurrency: 0,
+			running:     50,
+			completed:   recent,
+			wantWait:    20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewProcessingQueue(tt.concurrency)
+			q.completed = append([]completedRun(nil), tt.completed...)
+			for _, id := range ids(tt.running) {
+				q.running[id] = time.Now()
+			}
+			if tt.targetRunFor > 0 {
+				q.running[target] = time.Now().Add(-tt.targetRunFor)
+			}
+			q.waiting = ids(tt.waitingAhead)
+			if tt.targetWaits {
+				q.waiting = append(q.waiting, target)
+			}
+			q.waiting = append(q.waiting, ids(tt.waitingAfter)...)
+
+			got := q.Estimate(target)
+			if got.QueuePosition != tt.wantPosition || got.EstimatedWaitSeconds != tt.wantWait {
+				t.Errorf("Estimate() = position %d, %ds; want position %d, %ds",
+					got.QueuePosition, got.EstimatedWaitSeconds, tt.wantPosition, tt.wantWait)
+			}
+			if got.ProcessingPaused {
+				t.Errorf("Estimate() reports processing paused with no breakers")
+			}
+		})
+	}
+}
+
+func TestProcessingQueueTurns(t *testing.T) {
+	q := NewProcessingQueue(1)
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+
+	releaseFirst := q.Acquire(first)
+	q.Join(second)
+	q.Join(third)
+	q.Join(third) // joining twice keeps its place
+
+	positions := func() (int, int) {
+		return q.Estimate(second).QueuePosition, q.Estimate(third).QueuePosition
+	}
+	if s, th := positions(); s != 1 || th != 2 {
+		t.Fatalf("positions = %d, %d; want 1, 2", s, th)
+	}
+
+	secondStarted := make(chan func())
+	go func() { secondStarted <- q.Acquire(second) }()
+	select {
+	case <-secondStarted:
+		t.Fatal("second started while first held the only slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	releaseFirst()
+	releaseFirst() // releasing twice frees one slot
+	releaseSecond := <-secondStarted
+	if s, th := positions(); s != 0 || th != 1 {
+		t.Errorf("after first finished, positions = %d, %d; want 0, 1", s, th)
+	}
+	if len(q.completed) != 1 {
+		t.Errorf("recorded %d runs, want 1", len(q.completed))
+	}
+
+	releaseSecond()
+	if got := q.Estimate(third).QueuePosition; got != 0 {
+		t.Errorf("third position = %d, want 0 once its turn came", got)
+	}
+	q.Acquire(third)()
+	if len(q.running) != 0 || len(q.waiting) != 0 || len(q.turns) != 0 {
+		t.Errorf("queue not empty after every release: %d running, %d waiting", len(q.running), len(q.waiting))
+	}
+}
+
+func TestProcessingQueuePausedByBreaker(t *testing.T) {
+	geocoding := NewCircuitBreaker("geocoding", 1, time.Hour)
+	q := NewProcessingQueue(2)
+	q.WatchBreakers(NewCircuitBreaker("vision", 1, time.Hour), geocoding)
+
+	if got := q.Estimate(uuid.New()); got.ProcessingPaused || got.ProcessingPausedReason != nil {
+		t.Errorf("Estimate() = %+v with closed breakers, want not paused", got)
+	}
+
+	geocoding.openUntil = time.Now().Add(time.Hour)
+	got := q.Estimate(uuid.New())
+	if !got.ProcessingPaused || got.ProcessingPausedReason == nil || *got.ProcessingPausedReason != PauseReasonCircuitBreaker {
+		t.Errorf("Estimate() = %+v with an open breaker, want paused for %q", got, PauseReasonCircuitBreaker)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reconciler periodically repairs data that should never drift but occasionally does
+type Reconciler struct {
+	db       *gorm.DB
+	interval time.Duration
+}
+
+func NewReconciler(db *gorm.DB, interval time.Duration) *Reconciler {
+	return &Reconciler{
+		db:       db,
+		interval: interval,
+	}
+}
+
+// Start runs the reconciliation checks on a ticker in the background
+func (r *Reconciler) Start() {
+	if r.interval <= 0 {
+		log.Println("Reconciler disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			r.RunOnce()
+		}
+	}()
+}
+
+// RunOnce executes every reconciliation check, logging (not failing) on errors
+func (r *Reconciler) RunOnce() {
+	orphaned, err := ReconcileOrphanedVenues(r.db)
+	if err != nil {
+		log.Printf("Reconciler: orphaned venue check failed: %v", err)
+	} else if len(orphaned) > 0 {
+		log.Printf("Reconciler: cleared dangling venue_id on %d events", len(orphaned))
+	}
+
+	linked, err := LinkAutoPublishedVenues(r.db)
+	if err != nil {
+		log.Printf("Reconciler: venue link check failed: %v", err)
+	} else if len(linked) > 0 {
+		log.Printf("Reconciler: linked %d auto-published events to their venues", len(linked))
+	}
+}
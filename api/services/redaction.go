@@ -0,0 +1,248 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // decoders for uploaded originals
+	"image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// maxRedactionRects bounds how many boxes one redaction request can draw
+const maxRedactionRects = 50
+
+var (
+	ErrFlyerNotFound        = errors.New("flyer not found")
+	ErrInvalidRedaction     = errors.New("invalid redaction rectangles")
+	ErrFlyerCropUnavailable = errors.New("flyer has no image to crop")
+)
+
+// RedactionRect is an opaque box in the unredacted crop's pixel coordinates
+type RedactionRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// RedactionSuggestion tells the reviewer where personal contact details may be.
+// Regions stays empty until extraction returns OCR word boxes.
+type RedactionSuggestion struct {
+	ContactInfoDetected bool            `json:"contact_info_detected"`
+	Regions             []RedactionRect `json:"regions"`
+}
+
+// cropFilename is the private, unredacted crop for a flyer
+func cropFilename(flyerID uuid.UUID) string {
+	return fmt.Sprintf("crop_%s.jpg", flyerID)
+}
+
+// FlyerCropPath returns the local path of a flyer's unredacted crop, cutting it from
+// the photo the flyer was found in if CropService hasn't already
+func FlyerCropPath(storage *StorageService, flyer *models.Flyer) (string, error) {
+	path := storage.GetPrivateFilePath(flyer.SubmissionID, cropFilename(flyer.ID))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if flyer.RegionID == ManualFlyerRegionID {
+		return "", ErrFlyerCropUnavailable
+	}
+
+	original, err := decodeStoredImage(storage, flyer.SubmissionID, SubmissionImageFilename(flyer.ImageIndex))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFlyerCropUnavailable, err)
+	}
+
+	if err := writeJPEG(path, cutFlyerCrop(original, flyer)); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// polygonBounds returns the bounding box of a flyer's stored polygon
+func polygonBounds(polygonJSON string) image.Rectangle {
+	var points []Point
+	if err := json.Unmarshal([]byte(polygonJSON), &points); err != nil || len(points) == 0 {
+		return image.Rectangle{}
+	}
+
+	minX, minY, maxX, maxY := points[0].X, points[0].Y, points[0].X, points[0].Y
+	for _, p := range points[1:] {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	return image.Rect(int(minX), int(minY), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
+
+// RedactFlyer draws opaque boxes over the flyer's crop and saves the result as the flyer's
+// public image. The unredacted crop stays in the submission's private directory.
+func RedactFlyer(db *gorm.DB, storage *StorageService, flyerID uuid.UUID, rects []RedactionRect, actor Actor) (*models.Flyer, error) {
+	if len(rects) == 0 || len(rects) > maxRedactionRects {
+		return nil, fmt.Errorf("%w: between 1 and %d rectangles are required", ErrInvalidRedaction, maxRedactionRects)
+	}
+
+	var flyer models.Flyer
+	if err := db.First(&flyer, "id = ?", flyerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFlyerNotFound
+		}
+		return nil, err
+	}
+
+	cropPath, err := FlyerCropPath(storage, &flyer)
+	if err != nil {
+		return nil, err
+	}
+	crop, err := decodeImageFile(cropPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crop: %w", err)
+	}
+
+	redacted := image.NewRGBA(image.Rect(0, 0, crop.Bounds().Dx(), crop.Bounds().Dy()))
+	draw.Draw(redacted, redacted.Bounds(), crop, crop.Bounds().Min, draw.Src)
+
+	black := image.NewUniform(color.Black)
+	for i, rect := range rects {
+		if rect.Width <= 0 || rect.Height <= 0 {
+			return nil, fmt.Errorf("%w: rectangle %d must have a positive width and height", ErrInvalidRedaction, i)
+		}
+		box := image.Rect(rect.X, rect.Y, rect.X+rect.Width, rect.Y+rect.Height).Intersect(redacted.Bounds())
+		if box.Empty() {
+			return nil, fmt.Errorf("%w: rectangle %d lies outside the %dx%d crop", ErrInvalidRedaction, i, redacted.Bounds().Dx(), redacted.Bounds().Dy())
+		}
+		draw.Draw(redacted, box, black, image.Point{}, draw.Src)
+	}
+
+	// A fresh filename per redaction keeps caches from serving an earlier version
+	now := time.Now()
+	filename := fmt.Sprintf("redacted_%s_%d.jpg", flyer.ID, now.Unix())
+	if err := writeJPEG(storage.GetFilePath(flyer.SubmissionID, filename), redacted); err != nil {
+		return nil, err
+	}
+	if err := storage.Persist(flyer.SubmissionID, filename); err != nil {
+		os.Remove(storage.GetFilePath(flyer.SubmissionID, filename))
+		return nil, err
+	}
+
+	rectsJSON, err := json.Marshal(rects)
+	if err != nil {
+		return nil, err
+	}
+	previous := flyer.PublicImageURL
+	publicURL := storage.GetPublicURL(flyer.SubmissionID, filename)
+	cropURL := storage.GetAdminCropURL(flyer.ID)
+	redactions := string(rectsJSON)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&flyer).Updates(map[string]interface{}{
+			"crop_image_url":   cropURL,
+			"public_image_url": publicURL,
+			"redactions":       redactions,
+			"redacted_at":      now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityFlyer,
+			EntityID:   flyer.ID,
+			Action:     AuditActionFlyerRedacted,
+			Actor:      actor,
+			Changes: map[string]interface{}{
+				"public_image_url": publicURL,
+				"redactions":       rects,
+			},
+		})
+	})
+	if err != nil {
+		storage.RemoveFile(flyer.SubmissionID, filename)
+		return nil, err
+	}
+
+	if previous != nil && *previous != publicURL {
+		storage.RemoveFile(flyer.SubmissionID, filepath.Base(*previous))
+	}
+
+	flyer.CropImageURL = &cropURL
+	flyer.PublicImageURL = &publicURL
+	flyer.Redactions = &redactions
+	flyer.RedactedAt = &now
+	return &flyer, nil
+}
+
+// SuggestRedactions flags flyers whose extracted events carry contact info
+func SuggestRedactions(db *gorm.DB, flyerID uuid.UUID) (*RedactionSuggestion, error) {
+	var candidates []models.EventCandidate
+	if err := db.Select("fields").Where("flyer_id = ?", flyerID).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	suggestion := &RedactionSuggestion{Regions: []RedactionRect{}}
+	for _, candidate := range candidates {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
+			continue
+		}
+		if contact, _ := fields["contact_info"].(string); contact != "" {
+			suggestion.ContactInfoDetected = true
+		}
+	}
+	return suggestion, nil
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	return img, nil
+}
+
+// decodeStoredImage decodes a submission file, fetching it from remote storage if needed
+func decodeStoredImage(storage *StorageService, submissionID uuid.UUID, filename string) (image.Image, error) {
+	path, err := storage.Fetch(submissionID, filename)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImageFile(path)
+}
+
+func writeJPEG(path string, img image.Image) error {
+	return writeJPEGQuality(path, img, 90)
+}
+
+func writeJPEGQuality(path string, img image.Image, quality int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	if err := jpeg.Encode(file, img, &jpeg.Options{Quality: quality}); err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	return nil
+}
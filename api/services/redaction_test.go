@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// redactionFixture is a stored submission photo and a mocked database holding one flyer in it
+type redactionFixture struct {
+	storage      *StorageService
+	db           *gorm.DB
+	mock         sqlmock.Sqlmock
+	flyerID      uuid.UUID
+	submissionID uuid.UUID
+}
+
+// newRedactionFixture stores a flat grey 100x80 photo and expects the lookup of a flyer
+// covering (10,10)-(90,70) of it
+func newRedactionFixture(t *testing.T) *redactionFixture {
+	t.Helper()
+
+	storage := NewStorageService(&config.Config{UploadDir: t.TempDir(), PublicBaseURL: "https://api.example.com"})
+	submissionID, flyerID := uuid.New(), uuid.New()
+
+	photo := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	draw.Draw(photo, photo.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+	if err := writeJPEG(storage.GetFilePath(submissionID, SubmissionImageFilename(1)), photo); err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`SELECT * FROM "flyers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "submission_id", "region_id", "polygon", "image_index"}).
+			AddRow(flyerID.String(), submissionID.String(), "r1", `[{"x":10,"y":10},{"x":90,"y":10},{"x":90,"y":70},{"x":10,"y":70}]`, 1))
+	return &redactionFixture{storage: storage, db: db, mock: mock, flyerID: flyerID, submissionID: submissionID}
+}
+
+func TestRedactFlyerKeepsOriginalPrivate(t *testing.T) {
+	h := newRedactionFixture(t)
+	storage, mock := h.storage, h.mock
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "flyers" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, AuditActionFlyerRedacted)
+	mock.ExpectCommit()
+
+	flyer, err := RedactFlyer(h.db, storage, h.flyerID, []RedactionRect{{X: 0, Y: 0, Width: 20, Height: 10}}, Actor{Type: "admin", AdminID: "ops"})
+	if err != nil {
+		t.Fatalf("RedactFlyer() error = %v", err)
+	}
+
+	// The public image is the redacted one, served from the submission's public files
+	publicFile := filepath.Base(*flyer.PublicImageURL)
+	if !strings.HasPrefix(*flyer.PublicImageURL, "https://api.example.com/") || strings.Contains(*flyer.PublicImageURL, privateDirName) {
+		t.Errorf("PublicImageURL = %q, want a public file URL", *flyer.PublicImageURL)
+	}
+	if want := "https://api.example.com/admin/flyers/" + h.flyerID.String() + "/crop"; *flyer.CropImageURL != want {
+		t.Errorf("CropImageURL = %q, want the admin crop endpoint %q", *flyer.CropImageURL, want)
+	}
+	redacted, err := decodeImageFile(storage.GetFilePath(h.submissionID, publicFile))
+	if err != nil {
+		t.Fatalf("read redacted image: %v", err)
+	}
+	if got := redacted.Bounds().Size(); got != image.Pt(80, 60) {
+		t.Errorf("redacted size = %v, want the 80x60 crop", got)
+	}
+	if y := grey(redacted, 5, 5); y > 30 {
+		t.Errorf("redacted box luma = %d, want black", y)
+	}
+	if y := grey(redacted, 50, 40); y < 100 {
+		t.Errorf("unredacted area luma = %d, want the flyer's grey", y)
+	}
+
+	// The unredacted crop exists only in the private directory, which /files refuses
+	cropPath := storage.GetPrivateFilePath(h.submissionID, cropFilename(h.flyerID))
+	crop, err := decodeImageFile(cropPath)
+	if err != nil {
+		t.Fatalf("read private crop: %v", err)
+	}
+	if y := grey(crop, 5, 5); y < 100 {
+		t.Errorf("private crop luma = %d, want it left unredacted", y)
+	}
+	if _, err := os.Stat(storage.GetFilePath(h.submissionID, cropFilename(h.flyerID))); !os.IsNotExist(err) {
+		t.Errorf("unredacted crop is in the public directory (stat error %v)", err)
+	}
+	relative, err := filepath.Rel(storage.GetUploadDir(), cropPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := storage.PublicFilePath(relative); ok {
+		t.Errorf("PublicFilePath(%q) is servable, want it refused", relative)
+	}
+}
+
+func TestRedactFlyerRejectsBadRectangles(t *testing.T) {
+	tests := []struct {
+		name  string
+		rects []RedactionRect
+	}{
+		{"zero width", []RedactionRect{{X: 0, Y: 0, Width: 0, Height: 10}}},
+		{"negative height", []RedactionRect{{X: 0, Y: 0, Width: 10, Height: -1}}},
+		{"outside the crop", []RedactionRect{{X: 500, Y: 500, Width: 10, Height: 10}}},
+		{"one good, one bad", []RedactionRect{{X: 0, Y: 0, Width: 10, Height: 10}, {X: 0, Y: 0, Width: 10, Height: 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newRedactionFixture(t)
+			storage := h.storage
+
+			_, err := RedactFlyer(h.db, storage, h.flyerID, tt.rects, Actor{Type: "admin", AdminID: "ops"})
+			if !errors.Is(err, ErrInvalidRedaction) {
+				t.Fatalf("RedactFlyer() error = %v, want ErrInvalidRedaction", err)
+			}
+			entries, _ := os.ReadDir(filepath.Join(storage.GetUploadDir(), h.submissionID.String()))
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), "redacted_") {
+					t.Errorf("rejected redaction left %s behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestRedactFlyerRectangleCount(t *testing.T) {
+	db, _ := testdb.New(t)
+	for _, n := range []int{0, maxRedactionRects + 1} {
+		rects := make([]RedactionRect, n)
+		if _, err := RedactFlyer(db, nil, uuid.New(), rects, Actor{}); !errors.Is(err, ErrInvalidRedaction) {
+			t.Errorf("%d rectangles: error = %v, want ErrInvalidRedaction", n, err)
+		}
+	}
+}
+
+// grey returns the luma of img at (x, y)
+func grey(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
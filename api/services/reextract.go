@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// ErrFlyerHasPublishedCandidates blocks re-extraction while a candidate still backs a public event
+var ErrFlyerHasPublishedCandidates = errors.New("flyer has published candidates")
+
+// ReplaceFlyerCandidates swaps a flyer's candidates for a fresh extraction in one transaction.
+// Other flyers of the submission, and the submission itself, are left untouched.
+func ReplaceFlyerCandidates(db *gorm.DB, flyerID uuid.UUID, result *SingleFlyerResult, actor Actor) ([]models.EventCandidate, error) {
+	var created []models.EventCandidate
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var previous []models.EventCandidate
+		if err := tx.Select("id", "published_event_id").Where("flyer_id = ?", flyerID).Find(&previous).Error; err != nil {
+			return err
+		}
+
+		previousIDs := make([]uuid.UUID, 0, len(previous))
+		for _, candidate := range previous {
+			if candidate.PublishedEventID != nil {
+				return ErrFlyerHasPublishedCandidates
+			}
+			previousIDs = append(previousIDs, candidate.ID)
+		}
+
+		if len(previousIDs) > 0 {
+			if err := tx.Where("id IN ?", previousIDs).Delete(&models.EventCandidate{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		created, err = SaveFlyerCandidates(tx, flyerID, result.Events)
+		if err != nil {
+			return err
+		}
+
+		notes := SanitizeField("notes", result.Notes)
+		if err := tx.Model(&models.Flyer{}).Where("id = ?", flyerID).Update("notes", notes).Error; err != nil {
+			return err
+		}
+
+		createdIDs := make([]uuid.UUID, 0, len(created))
+		for _, candidate := range created {
+			createdIDs = append(createdIDs, candidate.ID)
+		}
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityFlyer,
+			EntityID:   flyerID,
+			Action:     AuditActionFlyerReextracted,
+			Actor:      actor,
+			Changes: map[string]interface{}{
+				"removed_candidates": previousIDs,
+				"new_candidates":     createdIDs,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+// stubVisionProvider answers every call with reply and remembers the requests it got
+type stubVisionProvider struct {
+	reply    string
+	err      error
+	requests []VisionRequest
+}
+
+func (p *stubVisionProvider) Name() string { return "stub" }
+
+func (p *stubVisionProvider) ImageLimits() VisionImageLimits {
+	return VisionImageLimits{MaxEncodedBytes: 1 << 20}
+}
+
+func (p *stubVisionProvider) Complete(_ context.Context, req VisionRequest) (string, VisionUsage, error) {
+	p.requests = append(p.requests, req)
+	return p.reply, VisionUsage{Model: req.Model, PromptTokens: 100, CompletionTokens: 20}, p.err
+}
+
+func TestExtractFlyerEventsSendsOnlyTheCrop(t *testing.T) {
+	cropPath := t.TempDir() + "/crop.jpg"
+	if err := writeJPEG(cropPath, image.NewRGBA(image.Rect(0, 0, 40, 60))); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &stubVisionProvider{reply: "```json\n" +
+		`{"events":[{"fields":{"title":"Open Mic"},"confidences":{"overall":0.9}}],"notes":"tear-off tabs"}` + "\n```"}
+	vision := &VisionService{
+		providers: map[string]VisionProvider{VisionProviderOpenAI: stub},
+		config:    &config.Config{VisionProvider: VisionProviderOpenAI},
+	}
+
+	result, usage, err := vision.ExtractFlyerEvents(context.Background(), cropPath, "gpt-4o")
+	if err != nil {
+		t.Fatalf("ExtractFlyerEvents() error = %v", err)
+	}
+	if len(stub.requests) != 1 {
+		t.Fatalf("vision called %d times, want once", len(stub.requests))
+	}
+	req := stub.requests[0]
+	if !strings.Contains(req.Prompt, "single flyer cropped from a bulletin board photo") {
+		t.Errorf("prompt is not the single-flyer variant:\n%s", req.Prompt)
+	}
+	if req.Model != "gpt-4o" || req.MediaType != "image/jpeg" || req.ImageData == "" {
+		t.Errorf("request = model %q, media type %q, %d bytes of image", req.Model, req.MediaType, len(req.ImageData))
+	}
+	if len(result.Events) != 1 || result.Events[0].Fields.Title != "Open Mic" || result.Notes != "tear-off tabs" {
+		t.Errorf("result = %+v", result)
+	}
+	if usage.Model != "gpt-4o" || usage.PromptVersion != PromptVersion(req.Prompt) {
+		t.Errorf("usage = %+v, want the requested model and the single-flyer prompt's version", usage)
+	}
+}
+
+func TestReplaceFlyerCandidatesTouchesOnlyTheFlyer(t *testing.T) {
+	db, mock := testdb.New(t)
+	creates := testdb.RecordCreates(t, db)
+	flyerID := uuid.New()
+	oldIDs := []uuid.UUID{uuid.New(), uuid.New()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id","published_event_id" FROM "event_candidates" WHERE flyer_id = $1`).
+		WithArgs(flyerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "published_event_id"}).
+			AddRow(oldIDs[0].String(), nil).
+			AddRow(oldIDs[1].String(), nil))
+	mock.ExpectExec(`DELETE FROM "event_candidates" WHERE id IN ($1,$2)`).
+		WithArgs(oldIDs[0], oldIDs[1]).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`INSERT INTO "event_candidates"`).WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "event_candidates"`).WillReturnRows(testdb.IDs(uuid.New()))
+	mock.ExpectExec(`UPDATE "flyers" SET "notes"=$1 WHERE id = $2`).
+		WithArgs("re-read", flyerID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, AuditActionFlyerReextracted)
+	mock.ExpectCommit()
+
+	result := &SingleFlyerResult{
+		Events: []EventCandidate{
+			{Fields: EventFields{Title: "Open Mic"}, Confidences: EventConfidences{Overall: 0.9}},
+			{Fields: EventFields{Title: "Poetry Night"}, Confidences: EventConfidences{Overall: 0.7}},
+		},
+		Notes: "re-read",
+	}
+	created, err := ReplaceFlyerCandidates(db, flyerID, result, Actor{Type: "admin", AdminID: "ops"})
+	if err != nil {
+		t.Fatalf("ReplaceFlyerCandidates() error = %v", err)
+	}
+
+	if len(created) != 2 {
+		t.Fatalf("created %d candidates, want 2", len(created))
+	}
+	for _, value := range creates.Table("event_candidates") {
+		candidate := value.(*models.EventCandidate)
+		if candidate.FlyerID != flyerID {
+			t.Errorf("candidate created for flyer %s, want %s", candidate.FlyerID, flyerID)
+		}
+	}
+	if !strings.Contains(created[1].Fields, "Poetry Night") {
+		t.Errorf("second candidate fields = %s", created[1].Fields)
+	}
+}
+
+func TestReplaceFlyerCandidatesKeepsPublishedFlyers(t *testing.T) {
+	db, mock := testdb.New(t)
+	flyerID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id","published_event_id" FROM "event_candidates"`).
+		WithArgs(flyerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "published_event_id"}).
+			AddRow(uuid.New().String(), nil).
+			AddRow(uuid.New().String(), uuid.New().String()))
+	mock.ExpectRollback()
+
+	_, err := ReplaceFlyerCandidates(db, flyerID, &SingleFlyerResult{}, Actor{Type: "admin", AdminID: "ops"})
+	if !errors.Is(err, ErrFlyerHasPublishedCandidates) {
+		t.Errorf("ReplaceFlyerCandidates() error = %v, want ErrFlyerHasPublishedCandidates", err)
+	}
+}
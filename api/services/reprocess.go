@@ -0,0 +1,82 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reprocess stages: the whole pipeline from the stored image, or only Stage 3 (moderation
+// and geocoding) on the candidates already extracted
+const (
+	ReprocessStageAll    = "all"
+	ReprocessStageStage3 = "stage3"
+)
+
+// ErrSubmissionProcessing blocks reprocessing while a run is still in flight
+var ErrSubmissionProcessing = errors.New("submission is processing")
+
+// ClaimSubmissionForReprocess moves a submission to "processing" for another run and records
+// who asked, in one transaction. The move is a conditional UPDATE on the status it was read
+// with, so of two reprocess calls racing for one submission only the first gets it; the other
+// gets ErrSubmissionProcessing, as do calls while any run is in flight. A full reprocess also
+// deletes its flyers and candidates; a Stage 3 reprocess keeps them and is refused once one
+// is published. The caller then runs the stage, which ends the submission done or in error.
+func ClaimSubmissionForReprocess(db *gorm.DB, submissionID uuid.UUID, stage string, actor Actor) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var submission models.Submission
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status").
+			First(&submission, "id = ?", submissionID).Error; err != nil {
+			return err
+		}
+		if submission.Status == "processing" {
+			return ErrSubmissionProcessing
+		}
+
+		result := tx.Model(&models.Submission{}).
+			Where("id = ? AND status = ?", submissionID, submission.Status).
+			Updates(map[string]interface{}{
+				"status":     "processing",
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSubmissionProcessing
+		}
+
+		if stage == ReprocessStageStage3 {
+			var published int64
+			if err := tx.Model(&models.EventCandidate{}).
+				Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+				Where("flyers.submission_id = ? AND event_candidates.published_event_id IS NOT NULL", submissionID).
+				Count(&published).Error; err != nil {
+				return err
+			}
+			if published > 0 {
+				return ErrSubmissionHasPublished
+			}
+		} else if err := ClearSubmissionResults(tx, submissionID); err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntitySubmission,
+			EntityID:   submissionID,
+			Action:     AuditActionSubmissionReprocessed,
+			Actor:      actor,
+			Changes: map[string]interface{}{
+				"status": map[string]string{"from": submission.Status, "to": "processing"},
+			},
+			Metadata: map[string]interface{}{
+				"stage": stage,
+			},
+		})
+	})
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/lincolngreen/williamboard/api/observability"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// Review reasons for candidates whose Stage 3 dependencies failed transiently.
+// They stay in needs_review while retries are scheduled and after retries run out.
+const (
+	ReviewReasonModerationUnavailable = "moderation_unavailable"
+	ReviewReasonGeocodeFailed         = "geocode_failed"
+)
+
+// RetryReviewReasons are the review reasons the retry sweeper acts on
+var RetryReviewReasons = []string{ReviewReasonModerationUnavailable, ReviewReasonGeocodeFailed}
+
+// maxRetryDelay caps the exponential backoff between attempts
+const maxRetryDelay = 6 * time.Hour
+
+// UpstreamStatusError is a non-200 response from an external API
+type UpstreamStatusError struct {
+	Service    string
+	StatusCode int
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("%s API returned status %d", e.Service, e.StatusCode)
+}
+
+// IsRetryableError reports whether a failure is likely to clear on its own:
+// timeouts, network errors, rate limiting, server errors, and open breakers
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var statusErr *UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return retryableStatus(requestErr.HTTPStatusCode)
+	}
+	return false
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// RetryDelay is the backoff before the given attempt (1-based): base, 2x base, 4x base, ...
+func RetryDelay(base time.Duration, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := time.Duration(float64(base) * math.Pow(2, float64(attempt-1)))
+	if delay <= 0 || delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// ScheduleRetry records a failed Stage 3 attempt on the candidate. It returns false when
+// attempts are exhausted, leaving RetryAfter nil so the candidate stays in review.
+func ScheduleRetry(candidate *models.EventCandidate, cause error, maxAttempts int, base time.Duration, now time.Time) bool {
+	candidate.RetryAttempts++
+	message := cause.Error()
+	candidate.LastRetryError = &message
+
+	if candidate.RetryAttempts >= maxAttempts {
+		candidate.RetryAfter = nil
+		return false
+	}
+	next := now.Add(RetryDelay(base, candidate.RetryAttempts))
+	candidate.RetryAfter = &next
+	return true
+}
+
+// ClearRetry takes a candidate out of the retry queue after a clean Stage 3 pass
+func ClearRetry(candidate *models.EventCandidate) {
+	candidate.RetryAfter = nil
+}
+
+// IsAwaitingRetry reports whether a candidate is in review because of a transient
+// Stage 3 failure, whether or not another retry is scheduled
+func IsAwaitingRetry(candidate *models.EventCandidate) bool {
+	if candidate.PublishResult == nil || *candidate.PublishResult != "needs_review" || candidate.ReviewReason == nil {
+		return false
+	}
+	for _, reason := range RetryReviewReasons {
+		if *candidate.ReviewReason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingRetries selects candidates still waiting in review for a Stage 3 retry
+func pendingRetries(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.EventCandidate{}).
+		Where("publish_result = ? AND review_reason IN ? AND retry_after IS NOT NULL", "needs_review", RetryReviewReasons)
+}
+
+// ListPendingRetries returns the retry queue, soonest first
+func ListPendingRetries(db *gorm.DB, limit int) ([]models.EventCandidate, error) {
+	var candidates []models.EventCandidate
+	err := pendingRetries(db).Order("retry_after ASC").Limit(limit).Find(&candidates).Error
+	return candidates, err
+}
+
+// RetrySweeper re-runs Stage 3 for candidates whose retry time has come
+type RetrySweeper struct {
+	db        *gorm.DB
+	interval  time.Duration
+	batchSize int
+	process   func(ctx context.Context, candidateID uuid.UUID) error
+	breakers  []*CircuitBreaker
+}
+
+// NewRetrySweeper creates a sweeper that hands due candidates to process. Sweeps are
+// skipped while any of the breakers is open so retries don't pile onto a failing dependency.
+func NewRetrySweeper(db *gorm.DB, cfg *config.Config, process func(ctx context.Context, candidateID uuid.UUID) error, breakers ...*CircuitBreaker) *RetrySweeper {
+	return &RetrySweeper{
+		db:        db,
+		interval:  time.Duration(cfg.RetrySweepIntervalSec) * time.Second,
+		batchSize: cfg.RetryBatchSize,
+		process:   process,
+		breakers:  breakers,
+	}
+}
+
+// Start runs sweeps on a ticker in the background
+func (s *RetrySweeper) Start() {
+	if s.interval <= 0 {
+		log.Println("Retry sweeper disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.RunOnce()
+		}
+	}()
+}
+
+// RunOnce retries up to one batch of due candidates, logging (not failing) on errors
+func (s *RetrySweeper) RunOnce() {
+	if open := s.openBreaker(); open != "" {
+		log.Printf("Retry sweeper: %s circuit open, skipping sweep", open)
+		return
+	}
+
+	var due []models.EventCandidate
+	if err := pendingRetries(s.db).
+		Select("id").
+		Where("retry_after <= ?", time.Now()).
+		Order("retry_after ASC").
+		Limit(s.batchSize).
+		Find(&due).Error; err != nil {
+		log.Printf("Retry sweeper: failed to load due candidates: %v", err)
+		return
+	}
+
+	for _, candidate := range due {
+		// A failure during this sweep may have tripped a breaker
+		if open := s.openBreaker(); open != "" {
+			log.Printf("Retry sweeper: %s circuit opened, stopping sweep", open)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		if err := s.process(ctx, candidate.ID); err != nil {
+			log.Printf("Retry sweeper: retry of candidate %s failed: %v", candidate.ID, err)
+		}
+		cancel()
+	}
+	if len(due) > 0 {
+		log.Printf("Retry sweeper: retried %d candidates", len(due))
+	}
+}
+
+// openBreaker returns the name of the first open breaker, or ""
+func (s *RetrySweeper) openBreaker() string {
+	for _, breaker := range s.breakers {
+		if !breaker.Allow() {
+			return breaker.Name()
+		}
+	}
+	return ""
+}
+
+// SubmissionStatusFailed ends a submission whose automatic retries ran out; only an admin
+// retry runs it again
+const SubmissionStatusFailed = "failed"
+
+// submissionRetryGrowth is how much longer each automatic retry waits than the one before
+const submissionRetryGrowth = 5
+
+// RecordSubmissionError stores why a submission's processing failed, for the retry service
+// and admins; it logs rather than returns its own failures
+func RecordSubmissionError(db *gorm.DB, submissionID uuid.UUID, cause error) {
+	if err := db.Model(&models.Submission{}).
+		Where("id = ?", submissionID).
+		Update("last_error", cause.Error()).Error; err != nil {
+		log.Printf("Failed to record error for submission %s: %v", submissionID, err)
+	}
+}
+
+// RetryService gives uploads that ended in "error" another pass through the workers. The
+// nth retry runs once the submission has sat in error for base * 5^(n-1) (1, 5, then 25
+// minutes by default); after SUBMISSION_MAX_RETRIES the submission is marked "failed" for
+// good. Candidate-level Stage 3 retries are the RetrySweeper's job, not this one's.
+type RetryService struct {
+	db         *gorm.DB
+	interval   time.Duration
+	maxRetries int
+	baseDelay  time.Duration
+	batchSize  int
+	enqueue    func(submissionID uuid.UUID)
+	metrics    *observability.Registry
+}
+
+// NewRetryService creates a retry service that hands due submissions to the worker pool
+func NewRetryService(db *gorm.DB, cfg *config.Config, workers *SubmissionWorkers, metrics *observability.Registry) *RetryService {
+	return &RetryService{
+		db:         db,
+		interval:   time.Duration(cfg.SubmissionRetryIntervalSec) * time.Second,
+		maxRetries: cfg.SubmissionMaxRetries,
+		baseDelay:  time.Duration(cfg.SubmissionRetryBaseDelaySec) * time.Second,
+		batchSize:  cfg.RetryBatchSize,
+		enqueue:    workers.Enqueue,
+		metrics:    metrics,
+	}
+}
+
+// Start polls for due submissions on a ticker in the background
+func (s *RetryService) Start() {
+	if s.interval <= 0 {
+		log.Println("Submission retries disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.RunOnce()
+		}
+	}()
+}
+
+// RunOnce marks exhausted submissions failed and requeues up to one batch of due ones,
+// logging (not failing) on errors
+func (s *RetryService) RunOnce() {
+	var exhausted []models.Submission
+	if err := s.errored().
+		Select("id, retry_count, last_error").
+		Where("retry_count >= ?", s.maxRetries).
+		Limit(s.batchSize).
+		Find(&exhausted).Error; err != nil {
+		log.Printf("Submission retries: failed to load exhausted submissions: %v", err)
+		return
+	}
+	for _, submission := range exhausted {
+		if err := s.markFailed(&submission); err != nil {
+			log.Printf("Submission retries: failed to mark %s failed: %v", submission.ID, err)
+		}
+	}
+
+	var due []models.Submission
+	if err := s.errored().
+		Select("id").
+		Where("retry_count < ?", s.maxRetries).
+		Where("updated_at < NOW() - make_interval(secs => ? * power(?, retry_count))", s.baseDelay.Seconds(), submissionRetryGrowth).
+		Order("updated_at ASC").
+		Limit(s.batchSize).
+		Find(&due).Error; err != nil {
+		log.Printf("Submission retries: failed to load due submissions: %v", err)
+		return
+	}
+
+	retried := 0
+	for _, submission := range due {
+		ok, err := s.retry(submission.ID)
+		if err != nil {
+			log.Printf("Submission retries: retry of %s failed: %v", submission.ID, err)
+			continue
+		}
+		if ok {
+			retried++
+		}
+	}
+	if retried > 0 {
+		log.Printf("Submission retries: requeued %d submissions", retried)
+	}
+}
+
+// errored selects uploads sitting in "error"; typed-in submissions never reach the workers
+func (s *RetryService) errored() *gorm.DB {
+	return s.db.Model(&models.Submission{}).
+		Where("status = ? AND source = ?", "error", SubmissionSourceUpload)
+}
+
+// retry clears a submission's partial results and requeues it, counting the attempt. It
+// returns false when another instance got there first. A submission that published events
+// before failing can't be re-run safely and is marked failed instead.
+func (s *RetryService) retry(submissionID uuid.UUID) (bool, error) {
+	if err := ClearSubmissionResults(s.db, submissionID); err != nil {
+		if errors.Is(err, ErrSubmissionHasPublished) {
+			var submission models.Submission
+			if err := s.db.Select("id, retry_count, last_error").First(&submission, "id = ?", submissionID).Error; err != nil {
+				return false, err
+			}
+			return false, s.markFailed(&submission)
+		}
+		return false, err
+	}
+
+	// Marked "processing" before it is enqueued, as Enqueue requires, so a restart's
+	// RequeueStale finds it if it never runs
+	result := s.db.Model(&models.Submission{}).
+		Where("id = ? AND status = ?", submissionID, "error").
+		Updates(map[string]interface{}{
+			"status":      "processing",
+			"retry_count": gorm.Expr("retry_count + 1"),
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to reset submission: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	s.enqueue(submissionID)
+	return true, nil
+}
+
+// markFailed ends a submission whose retries ran out, with an audit log entry
+func (s *RetryService) markFailed(submission *models.Submission) error {
+	failed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Submission{}).
+			Where("id = ? AND status = ?", submission.ID, "error").
+			Updates(map[string]interface{}{
+				"status":     SubmissionStatusFailed,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		log.Printf("Submission %s failed after %d retries", submission.ID, submission.RetryCount)
+		failed = true
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntitySubmission,
+			EntityID:   submission.ID,
+			Action:     AuditActionSubmissionFailed,
+			Actor:      SystemActor(ActorSystem),
+			Changes: map[string]interface{}{
+				"status": map[string]string{"from": "error", "to": SubmissionStatusFailed},
+			},
+			Metadata: map[string]interface{}{
+				"retry_count": submission.RetryCount,
+				"last_error":  submission.LastError,
+			},
+		})
+	})
+	if err == nil && failed {
+		s.metrics.SubmissionStatus(SubmissionStatusFailed)
+	}
+	return err
+}
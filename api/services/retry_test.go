@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limited", &UpstreamStatusError{Service: "geocoding", StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &UpstreamStatusError{Service: "geocoding", StatusCode: http.StatusBadGateway}, true},
+		{"not found", &UpstreamStatusError{Service: "geocoding", StatusCode: http.StatusNotFound}, false},
+		{"wrapped server error", fmt.Errorf("geocode: %w", &UpstreamStatusError{Service: "geocoding", StatusCode: 503}), true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"open breaker", fmt.Errorf("geocoding: %w", ErrCircuitOpen), true},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"openai rate limit", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, true},
+		{"openai bad request", &openai.APIError{HTTPStatusCode: http.StatusBadRequest}, false},
+		{"openai request error", &openai.RequestError{HTTPStatusCode: http.StatusServiceUnavailable}, true},
+		{"plain error", errors.New("no results"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{10, maxRetryDelay},
+		{100, maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		if got := RetryDelay(time.Minute, tt.attempt); got != tt.want {
+			t.Errorf("RetryDelay(1m, %d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleRetryRunsOut(t *testing.T) {
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+	candidate := &models.EventCandidate{}
+	cause := &UpstreamStatusError{Service: "geocoding", StatusCode: 503}
+
+	for attempt, wantDelay := range []time.Duration{time.Minute, 2 * time.Minute} {
+		if !ScheduleRetry(candidate, cause, 3, time.Minute, now) {
+			t.Fatalf("attempt %d: ScheduleRetry() = false, want a retry scheduled", attempt+1)
+		}
+		if candidate.RetryAfter == nil || !candidate.RetryAfter.Equal(now.Add(wantDelay)) {
+			t.Errorf("attempt %d: RetryAfter = %v, want now + %s", attempt+1, candidate.RetryAfter, wantDelay)
+		}
+	}
+
+	if ScheduleRetry(candidate, cause, 3, time.Minute, now) {
+		t.Error("third attempt: ScheduleRetry() = true, want attempts exhausted")
+	}
+	if candidate.RetryAttempts != 3 || candidate.RetryAfter != nil {
+		t.Errorf("after exhaustion: attempts %d, RetryAfter %v; want 3, nil", candidate.RetryAttempts, candidate.RetryAfter)
+	}
+	if candidate.LastRetryError == nil || *candidate.LastRetryError != cause.Error() {
+		t.Errorf("LastRetryError = %v, want %q", candidate.LastRetryError, cause.Error())
+	}
+}
+
+func TestIsAwaitingRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		publishResult *string
+		reviewReason  *string
+		want          bool
+	}{
+		{"geocode failed", strPtr("needs_review"), strPtr(ReviewReasonGeocodeFailed), true},
+		{"moderation unavailable", strPtr("needs_review"), strPtr(ReviewReasonModerationUnavailable), true},
+		{"low score", strPtr("needs_review"), strPtr(ReviewReasonLowScore), false},
+		{"published", strPtr("published"), strPtr(ReviewReasonGeocodeFailed), false},
+		{"not decided", nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate := &models.EventCandidate{PublishResult: tt.publishResult, ReviewReason: tt.reviewReason}
+			if got := IsAwaitingRetry(candidate); got != tt.want {
+				t.Errorf("IsAwaitingRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerOpensOnRetryableFailures(t *testing.T) {
+	breaker := NewCircuitBreaker("geocoding", 2, time.Hour)
+	unavailable := &UpstreamStatusError{Service: "geocoding", StatusCode: 503}
+
+	// Non-retryable failures don't count toward opening
+	breaker.Call(func() error { return unavailable })
+	breaker.Call(func() error { return errors.New("no results") })
+	breaker.Call(func() error { return unavailable })
+	if !breaker.Allow() {
+		t.Fatal("breaker opened after failures that weren't consecutive")
+	}
+
+	breaker.Call(func() error { return unavailable })
+	if breaker.Allow() {
+		t.Fatal("breaker still closed after 2 consecutive retryable failures")
+	}
+	called := false
+	err := breaker.Call(func() error { called = true; return nil })
+	if called || !errors.Is(err, ErrCircuitOpen) || !IsRetryableError(err) {
+		t.Errorf("Call() on an open breaker = %v (called %v), want a retryable ErrCircuitOpen", err, called)
+	}
+}
+
+// expectDueRetries expects the sweeper's lookup of due candidates
+func expectDueRetries(mock sqlmock.Sqlmock, ids ...uuid.UUID) {
+	rows := sqlmock.NewRows([]string{"id"})
+	for _, id := range ids {
+		rows.AddRow(id.String())
+	}
+	mock.ExpectQuery(`SELECT "id" FROM "event_candidates" WHERE (publish_result = $1 AND review_reason IN ($2,$3) AND retry_after IS NOT NULL) AND retry_after <= $4 ORDER BY retry_after ASC LIMIT $5`).
+		WithArgs("needs_review", ReviewReasonModerationUnavailable, ReviewReasonGeocodeFailed, testdb.Any, 10).
+		WillReturnRows(rows)
+}
+
+func TestRetrySweeperPublishesAfterGeocoderRecovers(t *testing.T) {
+	db, mock := testdb.New(t)
+	candidate := &models.EventCandidate{ID: uuid.New()}
+	breaker := NewCircuitBreaker("geocoding", 5, time.Hour)
+
+	// A geocoder that fails twice with a 503, then answers
+	geocodeCalls := 0
+	geocode := func() error {
+		geocodeCalls++
+		if geocodeCalls <= 2 {
+			return &UpstreamStatusError{Service: "geocoding", StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil
+	}
+
+	// Stage 3 as the upload handler runs it: a transient failure holds the candidate in
+	// review with a retry scheduled, a clean pass publishes it
+	stage3 := func(ctx context.Context, candidateID uuid.UUID) error {
+		if candidateID != candidate.ID {
+			t.Fatalf("sweeper retried %s, want %s", candidateID, candidate.ID)
+		}
+		if err := breaker.Call(geocode); err != nil {
+			if !IsRetryableError(err) {
+				return err
+			}
+			needsReview, reason := "needs_review", ReviewReasonGeocodeFailed
+			candidate.PublishResult, candidate.ReviewReason = &needsReview, &reason
+			ScheduleRetry(candidate, err, 5, time.Minute, time.Now())
+			return nil
+		}
+		published := "published"
+		candidate.PublishResult = &published
+		ClearRetry(candidate)
+		return nil
+	}
+	sweeper := &RetrySweeper{db: db, batchSize: 10, process: stage3, breakers: []*CircuitBreaker{breaker}}
+
+	// The upload's own Stage 3 pass fails the first time
+	if err := stage3(context.Background(), candidate.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !IsAwaitingRetry(candidate) || candidate.RetryAfter == nil {
+		t.Fatalf("after first failure: candidate = %+v, want it queued for retry", candidate)
+	}
+
+	// Each sweep finds it due and runs Stage 3 again: one more failure, then success
+	for sweep := 1; sweep <= 2; sweep++ {
+		expectDueRetries(mock, candidate.ID)
+		sweeper.RunOnce()
+	}
+
+	if candidate.PublishResult == nil || *candidate.PublishResult != "published" {
+		t.Errorf("PublishResult = %v, want published without manual intervention", candidate.PublishResult)
+	}
+	if candidate.RetryAfter != nil || candidate.RetryAttempts != 2 || geocodeCalls != 3 {
+		t.Errorf("RetryAfter %v, attempts %d, geocode calls %d; want nil, 2, 3",
+			candidate.RetryAfter, candidate.RetryAttempts, geocodeCalls)
+	}
+	if IsAwaitingRetry(candidate) {
+		t.Error("published candidate is still awaiting a retry")
+	}
+}
+
+func TestRetrySweeperSkipsWhileBreakerOpen(t *testing.T) {
+	db, mock := testdb.New(t)
+	breaker := NewCircuitBreaker("moderation", 1, time.Hour)
+	breaker.Call(func() error { return context.DeadlineExceeded })
+
+	processed := 0
+	sweeper := &RetrySweeper{db: db, batchSize: 10, breakers: []*CircuitBreaker{breaker},
+		process: func(context.Context, uuid.UUID) error { processed++; return nil }}
+	sweeper.RunOnce()
+	if processed != 0 {
+		t.Errorf("processed %d candidates with the moderation breaker open", processed)
+	}
+
+	// A breaker tripping partway through a sweep stops it
+	closed := NewCircuitBreaker("geocoding", 1, time.Hour)
+	sweeper = &RetrySweeper{db: db, batchSize: 10, breakers: []*CircuitBreaker{closed},
+		process: func(context.Context, uuid.UUID) error {
+			processed++
+			return closed.Call(func() error { return context.DeadlineExceeded })
+		}}
+	expectDueRetries(mock, uuid.New(), uuid.New(), uuid.New())
+	sweeper.RunOnce()
+	if processed != 1 {
+		t.Errorf("processed %d candidates, want the sweep to stop after the breaker opened", processed)
+	}
+}
+
+func TestRetryServiceRunOnce(t *testing.T) {
+	tests := []struct {
+		name         string
+		exhausted    bool // the submission used up its retries
+		published    bool // it published events before failing
+		lostRace     bool // another instance requeued it first
+		wantEnqueued bool
+	}{
+		{name: "due submission is requeued", wantEnqueued: true},
+		{name: "another instance got there first", lostRace: true},
+		{name: "exhausted submission fails for good", exhausted: true},
+		{name: "submission with published events fails instead of re-running", published: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			submissionID := uuid.New()
+			exhausted := testdb.IDs()
+			if tt.exhausted {
+				exhausted = sqlmock.NewRows([]string{"id", "retry_count", "last_error"}).AddRow(submissionID.String(), 3, "vision timeout")
+			}
+			mock.ExpectQuery(`SELECT id, retry_count, last_error FROM "submissions" WHERE (status = $1 AND source = $2) AND retry_count >= $3 LIMIT $4`).
+				WithArgs("error", SubmissionSourceUpload, 3, 10).
+				WillReturnRows(exhausted)
+			expectMarkFailed := func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "submissions" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`).
+					WithArgs(SubmissionStatusFailed, testdb.Any, submissionID, "error").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAudit(mock, AuditActionSubmissionFailed)
+				mock.ExpectCommit()
+			}
+			if tt.exhausted {
+				expectMarkFailed()
+			}
+
+			// The nth retry waits base * 5^(n-1): one minute, then five, then twenty-five
+			due := testdb.IDs()
+			if !tt.exhausted {
+				due = testdb.IDs(submissionID)
+			}
+			mock.ExpectQuery(`WHERE (status = $1 AND source = $2) AND retry_count < $3 AND updated_at < NOW() - make_interval(secs => $4 * power($5, retry_count)) ORDER BY updated_at ASC LIMIT $6`).
+				WithArgs("error", SubmissionSourceUpload, 3, 60.0, submissionRetryGrowth, 10).
+				WillReturnRows(due)
+			if !tt.exhausted {
+				var published int64
+				if tt.published {
+					published = 2
+				}
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "event_candidates" JOIN flyers`).
+					WithArgs(submissionID).
+					WillReturnRows(testdb.Count(published))
+				if tt.published {
+					mock.ExpectRollback()
+					mock.ExpectQuery(`SELECT id, retry_count, last_error FROM "submissions" WHERE id = $1`).
+						WithArgs(submissionID, 1).
+						WillReturnRows(sqlmock.NewRows([]string{"id", "retry_count"}).AddRow(submissionID.String(), 0))
+					expectMarkFailed()
+				} else {
+					mock.ExpectExec(`DELETE FROM "event_candidates" WHERE flyer_id IN (SELECT "id" FROM "flyers" WHERE submission_id = $1)`).
+						WithArgs(submissionID).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(`DELETE FROM "flyers" WHERE submission_id = $1`).
+						WithArgs(submissionID).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(`UPDATE "submissions" SET "duplicate_of_id"=$1`).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+					affected := int64(1)
+					if tt.lostRace {
+						affected = 0
+					}
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "submissions" SET "retry_count"=retry_count + 1,"status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`).
+						WithArgs("processing", testdb.Any, submissionID, "error").
+						WillReturnResult(sqlmock.NewResult(0, affected))
+					mock.ExpectCommit()
+				}
+			}
+
+			var enqueued []uuid.UUID
+			s := &RetryService{db: db, maxRetries: 3, baseDelay: time.Minute, batchSize: 10,
+				enqueue: func(id uuid.UUID) { enqueued = append(enqueued, id) }}
+			s.RunOnce()
+
+			if got := len(enqueued) == 1 && enqueued[0] == submissionID; got != tt.wantEnqueued {
+				t.Errorf("enqueued %v, want the submission enqueued: %v", enqueued, tt.wantEnqueued)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// captureFallbackConfidence is the confidence given to a capture-location fallback: below any
+// auto-publish or venue threshold, so the event is placed but a human checks the venue
+const captureFallbackConfidence = 0.3
+
+// CaptureFallbackGeocode places a flyer that names no venue or address at the spot the photo
+// was taken, labelled "Near <neighborhood>" after a reverse lookup (or "Near photo location"
+// when the lookup finds nothing). The result is marked approximate and low-confidence.
+func (g *GeocodingService) CaptureFallbackGeocode(ctx context.Context, capture CaptureLocation) (*GeocodeResult, error) {
+	neighborhood, err := g.ReverseGeocodeNeighborhood(ctx, capture)
+	if err != nil {
+		return nil, err
+	}
+
+	label := "Near photo location"
+	components := map[string]string{}
+	if neighborhood != "" {
+		label = "Near " + neighborhood
+		components["neighborhood"] = neighborhood
+	}
+	return &GeocodeResult{
+		Latitude:         capture.Latitude,
+		Longitude:        capture.Longitude,
+		FormattedAddress: label,
+		Confidence:       captureFallbackConfidence,
+		Components:       components,
+		Approximate:      true,
+	}, nil
+}
+
+// ReverseGeocodeNeighborhood names the neighborhood (or failing that, the town) around a
+// point with the configured geocoder. It returns "" when the geocoder has nothing there or
+// no API key is configured.
+func (g *GeocodingService) ReverseGeocodeNeighborhood(ctx context.Context, point CaptureLocation) (string, error) {
+	if g.config.Geocoder != GeocoderNominatim {
+		if key := g.apiKey(); key == "" || key == "your-mapbox-api-key" {
+			return "", nil
+		}
+	}
+
+	switch g.config.Geocoder {
+	case GeocoderMapbox:
+		return HedgedCall(ctx, g.hedger, func(ctx context.Context) (string, error) {
+			return g.reverseWithMapbox(ctx, point)
+		})
+	case GeocoderGoogleMaps:
+		return HedgedCall(ctx, g.hedger, func(ctx context.Context) (string, error) {
+			return g.reverseWithGoogleMaps(ctx, point)
+		})
+	case GeocoderNominatim:
+		return g.reverseWithNominatim(ctx, point)
+	default:
+		return "", fmt.Errorf("unsupported geocoder: %s", g.config.Geocoder)
+	}
+}
+
+func (g *GeocodingService) reverseWithMapbox(ctx context.Context, point CaptureLocation) (string, error) {
+	requestURL := fmt.Sprintf("https://api.mapbox.com/geocoding/v5/mapbox.places/%f,%f.json?access_token=%s&limit=1&types=neighborhood,locality,place",
+		point.Longitude, point.Latitude, url.QueryEscape(g.config.GeocoderAPIKey))
+
+	var mapboxResp MapboxResponse
+	if err := g.getJSON(ctx, requestURL, nil, &mapboxResp); err != nil {
+		return "", err
+	}
+	if len(mapboxResp.Features) == 0 {
+		return "", nil
+	}
+	return mapboxResp.Features[0].Text, nil
+}
+
+func (g *GeocodingService) reverseWithGoogleMaps(ctx context.Context, point CaptureLocation) (string, error) {
+	params := url.Values{}
+	params.Set("latlng", fmt.Sprintf("%f,%f", point.Latitude, point.Longitude))
+	params.Set("result_type", "neighborhood|sublocality|locality")
+	params.Set("key", g.apiKey())
+
+	var googleResp GoogleGeocodeResponse
+	if err := g.getJSON(ctx, googleGeocodeURL+"?"+params.Encode(), nil, &googleResp); err != nil {
+		return "", err
+	}
+	switch googleResp.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return "", nil
+	default:
+		return "", fmt.Errorf("reverse geocoding failed: %s %s", googleResp.Status, googleResp.ErrorMessage)
+	}
+
+	// Results come most specific first; the first component is the place the result names
+	for _, result := range googleResp.Results {
+		if len(result.AddressComponents) > 0 {
+			return result.AddressComponents[0].LongName, nil
+		}
+	}
+	return "", nil
+}
+
+func (g *GeocodingService) reverseWithNominatim(ctx context.Context, point CaptureLocation) (string, error) {
+	params := url.Values{}
+	params.Set("lat", fmt.Sprintf("%f", point.Latitude))
+	params.Set("lon", fmt.Sprintf("%f", point.Longitude))
+	params.Set("format", "jsonv2")
+	params.Set("zoom", "14") // suburb level
+	params.Set("addressdetails", "1")
+
+	if err := g.waitNominatimTurn(ctx); err != nil {
+		return "", err
+	}
+
+	var result NominatimResult
+	baseURL := strings.TrimRight(g.config.NominatimBaseURL, "/")
+	header := http.Header{"User-Agent": []string{g.config.NominatimUserAgent}}
+	if err := g.getJSON(ctx, baseURL+"/reverse?"+params.Encode(), header, &result); err != nil {
+		return "", err
+	}
+	for _, key := range []string{"neighbourhood", "quarter", "suburb", "city_district", "city", "town", "village"} {
+		if value := result.Address[key]; value != "" {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+// getJSON sends a GET to a geocoder and decodes its JSON response into out
+func (g *GeocodingService) getJSON(ctx context.Context, requestURL string, header http.Header, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("geocoding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &UpstreamStatusError{Service: "geocoding", StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse geocoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// SLA states for candidates awaiting review
+const (
+	SLAStatusOK       = "ok"
+	SLAStatusWarning  = "warning"
+	SLAStatusBreached = "breached"
+)
+
+// slaWarningFraction is the share of the SLA after which a candidate is flagged as at risk
+const slaWarningFraction = 0.75
+
+// ReviewLatencyWindows maps the accepted window names to their durations
+var ReviewLatencyWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// ReviewLatencyReport summarizes time from candidate creation to first decision
+type ReviewLatencyReport struct {
+	Window          string     `json:"window"`
+	SLAHours        int        `json:"sla_hours"`
+	DecidedCount    int        `json:"decided_count"`
+	P50Seconds      *float64   `json:"p50_seconds"`
+	P90Seconds      *float64   `json:"p90_seconds"`
+	BreachingCount  int64      `json:"breaching_count"`
+	OldestPendingAt *time.Time `json:"oldest_pending_at,omitempty"`
+}
+
+// candidateDecision is the scan target for the latency query
+type candidateDecision struct {
+	CreatedAt time.Time
+	DecidedAt time.Time
+}
+
+// ComputeReviewLatency builds a latency report for candidates created within the window.
+// Latency is measured from candidate creation to the first decisive audit entry.
+func ComputeReviewLatency(db *gorm.DB, window string, sla time.Duration, now time.Time) (*ReviewLatencyReport, error) {
+	windowDuration, ok := ReviewLatencyWindows[window]
+	if !ok {
+		return nil, fmt.Errorf("unsupported window: %s", window)
+	}
+
+	var decisions []candidateDecision
+	err := db.Table("event_candidates").
+		Select("event_candidates.created_at AS created_at, MIN(audit_logs.created_at) AS decided_at").
+		Joins("JOIN audit_logs ON audit_logs.entity_id = event_candidates.id AND audit_logs.entity_type = ? AND audit_logs.action IN ?",
+			AuditEntityCandidate, DecisiveCandidateActions).
+		Where("event_candidates.created_at >= ?", now.Add(-windowDuration)).
+		Group("event_candidates.id, event_candidates.created_at").
+		Scan(&decisions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query review decisions: %w", err)
+	}
+
+	report := summarizeReviewLatency(window, sla, decisions)
+
+	// Candidates still waiting on a human past the SLA
+	if err := db.Model(&models.EventCandidate{}).
+		Where("publish_result = ? AND created_at < ?", "needs_review", now.Add(-sla)).
+		Count(&report.BreachingCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count SLA breaches: %w", err)
+	}
+
+	var oldest models.EventCandidate
+	if err := db.Where("publish_result = ?", "needs_review").Order("created_at ASC").First(&oldest).Error; err == nil {
+		report.OldestPendingAt = &oldest.CreatedAt
+	}
+
+	return report, nil
+}
+
+// summarizeReviewLatency fills the latency part of a report from each candidate's creation
+// and first decision; a decision stamped before creation (clock skew) counts as instant
+func summarizeReviewLatency(window string, sla time.Duration, decisions []candidateDecision) *ReviewLatencyReport {
+	latencies := make([]float64, 0, len(decisions))
+	for _, decision := range decisions {
+		latency := decision.DecidedAt.Sub(decision.CreatedAt).Seconds()
+		if latency < 0 {
+			latency = 0
+		}
+		latencies = append(latencies, latency)
+	}
+
+	report := &ReviewLatencyReport{
+		Window:       window,
+		SLAHours:     int(sla.Hours()),
+		DecidedCount: len(latencies),
+	}
+
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		p50 := Percentile(latencies, 50)
+		p90 := Percentile(latencies, 90)
+		report.P50Seconds = &p50
+		report.P90Seconds = &p90
+	}
+	return report
+}
+
+// Percentile returns the nearest-rank percentile of an ascending-sorted slice
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+
+	rank := int(math.Ceil(float64(len(sorted))*p/100.0)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// ReviewSLAStatus classifies how long a candidate has been waiting against the SLA
+func ReviewSLAStatus(age, sla time.Duration) string {
+	switch {
+	case age >= sla:
+		return SLAStatusBreached
+	case float64(age) >= float64(sla)*slaWarningFraction:
+		return SLAStatusWarning
+	default:
+		return SLAStatusOK
+	}
+}
+
+// FormatAge renders a waiting duration compactly for the dashboard
+func FormatAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeReviewLatency(t *testing.T) {
+	created := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	decided := func(after time.Duration) candidateDecision {
+		return candidateDecision{CreatedAt: created, DecidedAt: created.Add(after)}
+	}
+
+	tests := []struct {
+		name      string
+		decisions []candidateDecision
+		wantCount int
+		wantP50   *float64
+		wantP90   *float64
+	}{
+		{
+			name:      "no decisions leaves percentiles empty",
+			decisions: nil,
+		},
+		{
+			name:      "single decision",
+			decisions: []candidateDecision{decided(90 * time.Minute)},
+			wantCount: 1,
+			wantP50:   floatPtr(5400),
+			wantP90:   floatPtr(5400),
+		},
+		{
+			name: "ten decisions out of order",
+			decisions: []candidateDecision{
+				decided(10 * time.Hour), decided(1 * time.Hour), decided(9 * time.Hour),
+				decided(2 * time.Hour), decided(8 * time.Hour), decided(3 * time.Hour),
+				decided(7 * time.Hour), decided(4 * time.Hour), decided(6 * time.Hour),
+				decided(5 * time.Hour),
+			},
+			wantCount: 10,
+			wantP50:   floatPtr(5 * 3600),
+			wantP90:   floatPtr(9 * 3600),
+		},
+		{
+			name:      "decision stamped before creation counts as instant",
+			decisions: []candidateDecision{decided(-5 * time.Minute), decided(time.Hour)},
+			wantCount: 2,
+			wantP50:   floatPtr(0),
+			wantP90:   floatPtr(3600),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := summarizeReviewLatency("7d", 24*time.Hour, tt.decisions)
+			if report.Window != "7d" || report.SLAHours != 24 {
+				t.Errorf("window/sla = %q/%d, want 7d/24", report.Window, report.SLAHours)
+			}
+			if report.DecidedCount != tt.wantCount {
+				t.Errorf("DecidedCount = %d, want %d", report.DecidedCount, tt.wantCount)
+			}
+			assertSeconds(t, "P50", report.P50Seconds, tt.wantP50)
+			assertSeconds(t, "P90", report.P90Seconds, tt.wantP90)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 50, 0},
+		{"zero is the minimum", sorted, 0, 1},
+		{"hundred is the maximum", sorted, 100, 5},
+		{"median of odd count", sorted, 50, 3},
+		{"nearest rank rounds up", sorted, 41, 3},
+		{"p90 of five", sorted, 90, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.values, tt.p); got != tt.want {
+				t.Errorf("Percentile(%v, %v) = %v, want %v", tt.values, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReviewSLAStatus(t *testing.T) {
+	sla := 24 * time.Hour
+
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{0, SLAStatusOK},
+		{17 * time.Hour, SLAStatusOK},
+		{18 * time.Hour, SLAStatusWarning},
+		{23*time.Hour + 59*time.Minute, SLAStatusWarning},
+		{24 * time.Hour, SLAStatusBreached},
+		{72 * time.Hour, SLAStatusBreached},
+	}
+
+	for _, tt := range tests {
+		if got := ReviewSLAStatus(tt.age, sla); got != tt.want {
+			t.Errorf("ReviewSLAStatus(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{30 * time.Second, "0m"},
+		{45 * time.Minute, "45m"},
+		{time.Hour, "1h"},
+		{47*time.Hour + 59*time.Minute, "47h"},
+		{48 * time.Hour, "2d"},
+		{10 * 24 * time.Hour, "10d"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatAge(tt.age); got != tt.want {
+			t.Errorf("FormatAge(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func assertSeconds(t *testing.T, label string, got, want *float64) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("%s = %v, want %v", label, got, want)
+	case *got != *want:
+		t.Errorf("%s = %v, want %v", label, *got, *want)
+	}
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	config_pkg "github.com/lincolngreen/williamboard/api/config"
+)
+
+// s3UploadURLTTL is how long a presigned upload URL stays valid
+const s3UploadURLTTL = 15 * time.Minute
+
+// s3DateTimeLayout is the ISO 8601 basic format Signature Version 4 stamps requests with
+const s3DateTimeLayout = "20060102T150405Z"
+
+// s3EmptyPayloadHash is the SHA-256 of an empty request body
+const s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Backend keeps files in an S3-compatible bucket (AWS S3, MinIO, R2, and the like),
+// signing requests with AWS Signature Version 4
+type s3Backend struct {
+	endpoint      *url.URL
+	bucket        string
+	region        string
+	accessKey     string
+	secretKey     string
+	pathStyle     bool
+	publicBaseURL string
+	httpClient    *http.Client
+}
+
+func newS3Backend(cfg *config_pkg.Config) (*s3Backend, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required for STORAGE_BACKEND=s3")
+	}
+	rawEndpoint := cfg.S3Endpoint
+	if rawEndpoint == "" {
+		rawEndpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.S3Region)
+	}
+	endpoint, err := url.Parse(strings.TrimRight(rawEndpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", rawEndpoint)
+	}
+
+	b := &s3Backend{
+		endpoint:   endpoint,
+		bucket:     cfg.S3Bucket,
+		region:     cfg.S3Region,
+		accessKey:  cfg.S3AccessKeyID,
+		secretKey:  cfg.S3SecretAccessKey,
+		pathStyle:  cfg.S3ForcePathStyle,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+	b.publicBaseURL = strings.TrimRight(cfg.S3PublicBaseURL, "/")
+	if b.publicBaseURL == "" {
+		b.publicBaseURL = strings.TrimRight(b.objectURL("").String(), "/")
+	}
+	return b, nil
+}
+
+// objectURL is the bucket URL of key, path-style or virtual-hosted
+func (b *s3Backend) objectURL(key string) *url.URL {
+	u := *b.endpoint
+	if b.pathStyle {
+		u.Path = "/" + b.bucket + "/" + key
+	} else {
+		u.Host = b.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return &u
+}
+
+func (b *s3Backend) SaveFile(ctx context.Context, key string, data io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.objectURL(key).String(), data)
+	if err != nil {
+		return err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	req.Header.Set("Content-Type", storageContentType(key))
+	resp, err := b.do(req, "UNSIGNED-PAYLOAD")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *s3Backend) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(req, s3EmptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (b *s3Backend) GetPublicURL(key string) string {
+	return b.publicBaseURL + "/" + key
+}
+
+// GenerateUploadURL presigns a browser-style POST of key, so the client sends the photo
+// straight to the bucket. The signed policy holds the upload to maxSize bytes; S3 refuses
+// anything larger before storing it.
+func (b *s3Backend) GenerateUploadURL(ctx context.Context, key string, maxSize int64) (*DirectUpload, error) {
+	return b.presignPost(key, maxSize, time.Now().UTC())
+}
+
+// presignPost builds the form fields of a POST upload of key signed at now
+func (b *s3Backend) presignPost(key string, maxSize int64, now time.Time) (*DirectUpload, error) {
+	fields := map[string]string{
+		"key":              key,
+		"Content-Type":     storageContentType(key),
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": b.accessKey + "/" + b.credentialScope(now),
+		"x-amz-date":       now.Format(s3DateTimeLayout),
+	}
+	// Every field but the file, policy and signature must match a condition
+	conditions := []interface{}{
+		map[string]string{"bucket": b.bucket},
+		[]interface{}{"content-length-range", 1, maxSize},
+	}
+	for _, name := range []string{"key", "Content-Type", "x-amz-algorithm", "x-amz-credential", "x-amz-date"} {
+		conditions = append(conditions, map[string]string{name: fields[name]})
+	}
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(s3UploadURLTTL).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+	fields["x-amz-signature"] = hex.EncodeToString(s3HMAC(b.signingKey(now), fields["policy"]))
+	return &DirectUpload{URL: b.objectURL("").String(), Fields: fields}, nil
+}
+
+func (b *s3Backend) DeleteFile(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, b.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(req, s3EmptyPayloadHash)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *s3Backend) DeletePrefix(ctx context.Context, prefix string) error {
+	token := ""
+	for {
+		keys, next, err := b.list(ctx, prefix, token)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := b.DeleteFile(ctx, key); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		token = next
+	}
+}
+
+// s3ListResult is the part of a ListObjectsV2 response this backend reads
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns one page of keys under prefix and the token for the next page, if any
+func (b *s3Backend) list(ctx context.Context, prefix, token string) ([]string, string, error) {
+	u := b.objectURL("")
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := b.do(req, s3EmptyPayloadHash)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var result s3ListResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to parse bucket listing: %w", err)
+	}
+	keys := make([]string, 0, len(result.Contents))
+	for _, object := range result.Contents {
+		keys = append(keys, object.Key)
+	}
+	if !result.IsTruncated {
+		return keys, "", nil
+	}
+	return keys, result.NextContinuationToken, nil
+}
+
+// do signs and sends a request. A 404 returns an error satisfying os.IsNotExist; other
+// non-2xx statuses return an UpstreamStatusError.
+func (b *s3Backend) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	b.sign(req, payloadHash, time.Now().UTC())
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("storage request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, &os.PathError{Op: strings.ToLower(req.Method), Path: req.URL.Path, Err: os.ErrNotExist}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &UpstreamStatusError{Service: "storage", StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// sign sets a request's Signature Version 4 Authorization header as of now. It signs the
+// host, the payload hash, and any Content-Type, Range and other x-amz-* headers.
+func (b *s3Backend) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.Format(s3DateTimeLayout))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedNames := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			signedNames = append(signedNames, lower)
+		}
+	}
+	sort.Strings(signedNames)
+	var headers strings.Builder
+	for _, name := range signedNames {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signedNames, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		s3CanonicalPath(req.URL.Path),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, b.credentialScope(now), signedHeaders, b.signature(now, canonical)))
+}
+
+func (b *s3Backend) credentialScope(now time.Time) string {
+	return now.Format("20060102") + "/" + b.region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request with the secret key's derived signing key
+func (b *s3Backend) signature(now time.Time, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format(s3DateTimeLayout),
+		b.credentialScope(now),
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+	return hex.EncodeToString(s3HMAC(b.signingKey(now), stringToSign))
+}
+
+// signingKey derives the key for now's date, region and service from the secret key
+func (b *s3Backend) signingKey(now time.Time) []byte {
+	key := s3HMAC([]byte("AWS4"+b.secretKey), now.Format("20060102"))
+	key = s3HMAC(key, b.region)
+	key = s3HMAC(key, "s3")
+	return s3HMAC(key, "aws4_request")
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalPath URI-encodes each segment of a path the way Signature Version 4 expects
+func s3CanonicalPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes a query sorted by key, with spaces as %20
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything except unreserved characters
+func s3Escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// storageContentType guesses a stored file's type from its name; submissions only hold JPEGs
+// apart from the odd PNG or WebP resize
+func storageContentType(key string) string {
+	switch {
+	case strings.HasSuffix(key, ".png"):
+		return "image/png"
+	case strings.HasSuffix(key, ".webp"):
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSearchQueryTooShort is returned for queries the trigram index can't serve
+var ErrSearchQueryTooShort = errors.New("search query must be at least 3 characters")
+
+const (
+	// MaxCandidateSearchResults bounds one admin search
+	MaxCandidateSearchResults = 200
+	// minSearchQueryLength is the shortest query with a trigram to look up
+	minSearchQueryLength = 3
+	// searchBackfillBatch is how many candidates a backfill rewrites per query
+	searchBackfillBatch = 500
+)
+
+// candidateSearchFields are the extracted fields an admin search matches, in the order
+// they're joined into search_text
+var candidateSearchFields = []string{"title", "venue", "organizer", "description"}
+
+// CandidateSearchText builds a candidate's search_text from its fields JSON: the searchable
+// fields, lowercased and with runs of whitespace collapsed. Every write of a candidate's
+// fields stores it alongside, so the column never drifts from the fields it indexes.
+func CandidateSearchText(fieldsJSON string) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(fieldsJSON), &fields); err != nil {
+		return ""
+	}
+	parts := make([]string, 0, len(candidateSearchFields))
+	for _, name := range candidateSearchFields {
+		if value, ok := fields[name].(string); ok && value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return normalizeSearchText(strings.Join(parts, " "))
+}
+
+// normalizeSearchText lowercases text and collapses whitespace, for stored text and queries alike
+func normalizeSearchText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// SearchCandidates returns candidates whose title, venue, organizer, or description contain
+// query, best trigram match first. The ILIKE is served by the search_text trigram index.
+func SearchCandidates(db *gorm.DB, query string, limit int) ([]models.EventCandidate, error) {
+	query = normalizeSearchText(query)
+	if len([]rune(query)) < minSearchQueryLength {
+		return nil, ErrSearchQueryTooShort
+	}
+	if limit <= 0 || limit > MaxCandidateSearchResults {
+		limit = MaxCandidateSearchResults
+	}
+
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	var candidates []models.EventCandidate
+	err := db.Where("event_candidates.search_text ILIKE ?", pattern).
+		// Order drops a bare gorm.Expr, so the ranking goes in as an ORDER BY expression
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "similarity(event_candidates.search_text, ?) DESC, event_candidates.created_at DESC",
+			Vars:               []interface{}{query},
+			WithoutParentheses: true,
+		}}).
+		Limit(limit).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search candidates: %w", err)
+	}
+	return candidates, nil
+}
+
+// SearchBackfillReport counts what a search_text backfill looked at and rewrote
+type SearchBackfillReport struct {
+	Scanned int `json:"scanned"`
+	Updated int `json:"updated"`
+}
+
+// BackfillCandidateSearchText recomputes search_text for every candidate, in batches, and
+// rewrites the rows where it differs. It is safe to re-run and to run while the API is
+// serving: edits made meanwhile store their own search_text.
+func BackfillCandidateSearchText(db *gorm.DB) (*SearchBackfillReport, error) {
+	report := &SearchBackfillReport{}
+	after := uuid.Nil
+	for {
+		var batch []models.EventCandidate
+		if err := db.Select("id", "fields", "search_text").
+			Where("id > ?", after).
+			Order("id ASC").
+			Limit(searchBackfillBatch).
+			Find(&batch).Error; err != nil {
+			return report, fmt.Errorf("failed to load candidates: %w", err)
+		}
+		if len(batch) == 0 {
+			return report, nil
+		}
+
+		for _, candidate := range batch {
+			report.Scanned++
+			text := CandidateSearchText(candidate.Fields)
+			if text == candidate.SearchText {
+				continue
+			}
+			if err := db.Model(&models.EventCandidate{}).
+				Where("id = ?", candidate.ID).
+				Update("search_text", text).Error; err != nil {
+				return report, fmt.Errorf("failed to update candidate %s: %w", candidate.ID, err)
+			}
+			report.Updated++
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
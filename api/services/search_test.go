@@ -0,0 +1,201 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCandidateSearchText(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{"searchable fields in order", `{"description":"Bring a dish","organizer":"Friends of the Library","venue":"Main Branch","title":"Potluck"}`, "potluck main branch friends of the library bring a dish"},
+		{"other fields are left out", `{"title":"Open Mic","price":"$5","date_time":"Friday 8pm","address":"12 Main St"}`, "open mic"},
+		{"whitespace collapses", "{\"title\":\"  Jazz\\n\\tNight  \",\"venue\":\"The   Grange\"}", "jazz night the grange"},
+		{"empty and non-string values are skipped", `{"title":"","venue":null,"organizer":42,"description":"Quilting"}`, "quilting"},
+		{"no searchable fields", `{"price":"Free"}`, ""},
+		{"malformed fields", `{"title":`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CandidateSearchText(tt.fields); got != tt.want {
+				t.Errorf("CandidateSearchText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchCandidates(t *testing.T) {
+	search := `SELECT * FROM "event_candidates" WHERE event_candidates.search_text ILIKE $1 ` +
+		`ORDER BY similarity(event_candidates.search_text, $2) DESC, event_candidates.created_at DESC LIMIT $3`
+
+	tests := []struct {
+		name        string
+		query       string
+		limit       int
+		wantPattern string
+		wantQuery   string
+		wantLimit   int
+		wantErr     error
+	}{
+		{"normalized like stored text", "  Jazz   NIGHT ", 50, "%jazz night%", "jazz night", 50, nil},
+		{"LIKE wildcards are literal", "100%_off", 10, `%100\%\_off%`, "100%_off", 10, nil},
+		{"backslashes are literal", `c:\gym`, 10, `%c:\\gym%`, `c:\gym`, 10, nil},
+		{"limit is capped", "potluck", 5000, "%potluck%", "potluck", MaxCandidateSearchResults, nil},
+		{"no limit uses the cap", "potluck", 0, "%potluck%", "potluck", MaxCandidateSearchResults, nil},
+		{"too short for a trigram", " ab ", 10, "", "", 0, ErrSearchQueryTooShort},
+		{"two wide characters are too short", "日本", 10, "", "", 0, ErrSearchQueryTooShort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			candidateID := uuid.New()
+			if tt.wantErr == nil {
+				mock.ExpectQuery(search).
+					WithArgs(tt.wantPattern, tt.wantQuery, tt.wantLimit).
+					WillReturnRows(testdb.IDs(candidateID))
+			}
+
+			got, err := SearchCandidates(db, tt.query, tt.limit)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("SearchCandidates() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SearchCandidates() error = %v", err)
+			}
+			if len(got) != 1 || got[0].ID != candidateID {
+				t.Errorf("SearchCandidates() = %v, want candidate %s", got, candidateID)
+			}
+		})
+	}
+}
+
+func TestBackfillCandidateSearchText(t *testing.T) {
+	db, mock := testdb.New(t)
+	current, stale, blank := uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	mock.ExpectQuery(`SELECT "id","fields","search_text" FROM "event_candidates" WHERE id > $1 ORDER BY id ASC LIMIT $2`).
+		WithArgs(uuid.Nil, searchBackfillBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "fields", "search_text"}).
+			AddRow(current.String(), `{"title":"Open Mic"}`, "open mic").
+			AddRow(stale.String(), `{"title":"Open Mic Night"}`, "open mic").
+			AddRow(blank.String(), `{"title":"Seed Swap","venue":"Library"}`, ""))
+	// Only the rows whose text changed are rewritten
+	for _, row := range []struct {
+		id   uuid.UUID
+		text string
+	}{{stale, "open mic night"}, {blank, "seed swap library"}} {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "event_candidates" SET "search_text"=$1 WHERE id = $2`).
+			WithArgs(row.text, row.id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	// The next batch starts after the last row seen and finds nothing
+	mock.ExpectQuery(`SELECT "id","fields","search_text" FROM "event_candidates" WHERE id > $1 ORDER BY id ASC LIMIT $2`).
+		WithArgs(blank, searchBackfillBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "fields", "search_text"}))
+
+	report, err := BackfillCandidateSearchText(db)
+	if err != nil {
+		t.Fatalf("BackfillCandidateSearchText() error = %v", err)
+	}
+	if *report != (SearchBackfillReport{Scanned: 3, Updated: 2}) {
+		t.Errorf("BackfillCandidateSearchText() = %+v, want 3 scanned and 2 updated", *report)
+	}
+}
+
+// searchLatencyBudget is the slowest an admin search over the seeded dataset may be
+const searchLatencyBudget = 100 * time.Millisecond
+
+// TestSearchCandidatesLatency seeds a few thousand candidates into a migrated database and
+// checks searches through the trigram index stay within searchLatencyBudget. It needs
+// Postgres with pg_trgm: WB_TEST_DATABASE_URL=postgres://... go test -run SearchCandidatesLatency ./services
+func TestSearchCandidatesLatency(t *testing.T) {
+	dsn := os.Getenv("WB_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("WB_TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const seeded = 5000
+	titles := []string{"Open Mic", "Seed Swap", "Jazz Night", "Quilting Circle", "Pancake Breakfast", "Book Sale", "Barn Dance", "Yoga in the Park"}
+	venues := []string{"Main Branch Library", "The Grange Hall", "Riverside Park", "First Baptist Church", "Lincoln Elementary"}
+	organizers := []string{"Friends of the Library", "Rotary Club", "4-H", "Parks Department"}
+
+	// Everything happens on one connection in a transaction that is rolled back, against a
+	// temporary copy of the table (with its indexes) that shadows the real one
+	rollback := errors.New("rollback")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`CREATE TEMP TABLE event_candidates (LIKE public.event_candidates INCLUDING ALL) ON COMMIT DROP`).Error; err != nil {
+			return err
+		}
+		candidates := make([]models.EventCandidate, 0, seeded)
+		for i := 0; i < seeded; i++ {
+			fields, _ := json.Marshal(map[string]string{
+				"title":       fmt.Sprintf("%s #%d", titles[i%len(titles)], i),
+				"venue":       venues[i%len(venues)],
+				"organizer":   organizers[i%len(organizers)],
+				"description": fmt.Sprintf("Flyer %d from the community board; all welcome", i),
+			})
+			candidates = append(candidates, models.EventCandidate{
+				ID:          uuid.New(),
+				FlyerID:     uuid.New(),
+				EventID:     "event_1",
+				Fields:      string(fields),
+				SearchText:  CandidateSearchText(string(fields)),
+				Confidences: "{}",
+				CreatedAt:   time.Now().UTC(),
+			})
+		}
+		if err := tx.CreateInBatches(candidates, 500).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`ANALYZE event_candidates`).Error; err != nil {
+			return err
+		}
+
+		for _, query := range []string{"pancake breakfast #4241", "grange hall", "rotary", "community board"} {
+			began := time.Now()
+			results, err := SearchCandidates(tx, query, 50)
+			elapsed := time.Since(began)
+			if err != nil {
+				return err
+			}
+			if len(results) == 0 {
+				t.Errorf("search for %q found nothing", query)
+			}
+			if elapsed > searchLatencyBudget {
+				t.Errorf("search for %q took %v over %d candidates, budget %v", query, elapsed, seeded, searchLatencyBudget)
+			}
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatal(err)
+	}
+}
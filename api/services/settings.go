@@ -0,0 +1,405 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Setting value sources
+const (
+	SettingSourceDefault = "default" // the env/config value
+	SettingSourceRuntime = "runtime" // a value stored with PUT /admin/api/settings/:key
+)
+
+// MaxSettingHistory bounds the change history returned with a setting
+const MaxSettingHistory = 50
+
+var (
+	// ErrUnknownSetting is returned for keys that were never registered
+	ErrUnknownSetting = errors.New("unknown setting")
+	// ErrInvalidSettingValue is returned when a value fails to decode or validate
+	ErrInvalidSettingValue = errors.New("invalid setting value")
+)
+
+// Setting is a runtime-tunable value of type T. A value stored in the settings table takes
+// precedence over Default, which reads the env-backed config.
+type Setting[T any] struct {
+	Key         string
+	Description string
+	Default     func(cfg *config.Config) T
+	Validate    func(value T) error // optional
+}
+
+// settingDefinition is the type-erased view of a Setting kept in the registry
+type settingDefinition interface {
+	key() string
+	description() string
+	defaultValue(cfg *config.Config) interface{}
+	check(raw json.RawMessage) error
+}
+
+func (s *Setting[T]) key() string         { return s.Key }
+func (s *Setting[T]) description() string { return s.Description }
+
+func (s *Setting[T]) defaultValue(cfg *config.Config) interface{} {
+	return s.Default(cfg)
+}
+
+func (s *Setting[T]) check(raw json.RawMessage) error {
+	_, err := s.decode(raw)
+	return err
+}
+
+// decode parses and validates a stored or submitted value
+func (s *Setting[T]) decode(raw json.RawMessage) (T, error) {
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return value, fmt.Errorf("%w: %s expects %T", ErrInvalidSettingValue, s.Key, value)
+	}
+	if s.Validate != nil {
+		if err := s.Validate(value); err != nil {
+			return value, fmt.Errorf("%w: %s %v", ErrInvalidSettingValue, s.Key, err)
+		}
+	}
+	return value, nil
+}
+
+// Get returns the runtime value, or the config default when none is stored. A lookup or
+// decode failure is logged and falls back to the default rather than failing the caller.
+func (s *Setting[T]) Get(db *gorm.DB, cfg *config.Config) T {
+	raw, err := settingsCache.load(db, s.Key, time.Duration(cfg.SettingsCacheTTLSec)*time.Second)
+	if err != nil {
+		log.Printf("Settings: failed to load %s, using default: %v", s.Key, err)
+		return s.Default(cfg)
+	}
+	if raw == nil {
+		return s.Default(cfg)
+	}
+	value, err := s.decode(raw)
+	if err != nil {
+		log.Printf("Settings: stored %s is invalid, using default: %v", s.Key, err)
+		return s.Default(cfg)
+	}
+	return value
+}
+
+var (
+	settingsMu       sync.RWMutex
+	settingsRegistry = map[string]settingDefinition{}
+)
+
+// RegisterSetting adds a setting to the registry so it can be read and written through the
+// admin API. Keys must be unique; registering one twice panics at startup.
+func RegisterSetting[T any](s Setting[T]) *Setting[T] {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	if _, exists := settingsRegistry[s.Key]; exists {
+		panic(fmt.Sprintf("setting %q registered twice", s.Key))
+	}
+	setting := &s
+	settingsRegistry[s.Key] = setting
+	return setting
+}
+
+// lookupSetting returns the registered definition for key
+func lookupSetting(key string) (settingDefinition, error) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	def, ok := settingsRegistry[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSetting, key)
+	}
+	return def, nil
+}
+
+// settingCacheEntry is a cached settings row; a nil value means no runtime value is stored
+type settingCacheEntry struct {
+	value    json.RawMessage
+	loadedAt time.Time
+}
+
+// settingCache is a read-through cache of stored setting values. Writes through this process
+// invalidate it immediately; other instances pick changes up once their entry is older than
+// the TTL (SETTINGS_CACHE_TTL_SEC).
+type settingCache struct {
+	mu      sync.RWMutex
+	entries map[string]settingCacheEntry
+}
+
+var settingsCache = &settingCache{entries: map[string]settingCacheEntry{}}
+
+// load returns the stored value for key, reading the database on a miss or stale entry
+func (c *settingCache) load(db *gorm.DB, key string, ttl time.Duration) (json.RawMessage, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < ttl {
+		return entry.value, nil
+	}
+
+	var row models.Setting
+	err := db.Where("key = ?", key).First(&row).Error
+	switch {
+	case err == nil:
+		entry = settingCacheEntry{value: json.RawMessage(row.Value), loadedAt: time.Now()}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		entry = settingCacheEntry{loadedAt: time.Now()}
+	default:
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+	return entry.value, nil
+}
+
+// invalidate drops key so the next read goes to the database
+func (c *settingCache) invalidate(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// SettingView is a setting's effective value with where it came from
+type SettingView struct {
+	Key         string                 `json:"key"`
+	Description string                 `json:"description"`
+	Value       interface{}            `json:"value"`
+	Default     interface{}            `json:"default"`
+	Source      string                 `json:"source"`
+	UpdatedBy   *string                `json:"updated_by,omitempty"`
+	UpdatedAt   *time.Time             `json:"updated_at,omitempty"`
+	History     []models.SettingChange `json:"history,omitempty"`
+}
+
+// settingView builds the view of def from its stored row, if any
+func settingView(def settingDefinition, cfg *config.Config, row *models.Setting) SettingView {
+	view := SettingView{
+		Key:         def.key(),
+		Description: def.description(),
+		Default:     def.defaultValue(cfg),
+		Source:      SettingSourceDefault,
+	}
+	view.Value = view.Default
+	if row != nil {
+		view.Value = json.RawMessage(row.Value)
+		view.Source = SettingSourceRuntime
+		view.UpdatedBy = &row.UpdatedBy
+		view.UpdatedAt = &row.UpdatedAt
+	}
+	return view
+}
+
+// ListSettings returns every registered setting with its effective value, by key
+func ListSettings(db *gorm.DB, cfg *config.Config) ([]SettingView, error) {
+	var rows []models.Setting
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list settings: %w", err)
+	}
+	stored := make(map[string]*models.Setting, len(rows))
+	for i := range rows {
+		stored[rows[i].Key] = &rows[i]
+	}
+
+	settingsMu.RLock()
+	views := make([]SettingView, 0, len(settingsRegistry))
+	for key, def := range settingsRegistry {
+		views = append(views, settingView(def, cfg, stored[key]))
+	}
+	settingsMu.RUnlock()
+
+	sort.Slice(views, func(i, j int) bool { return views[i].Key < views[j].Key })
+	return views, nil
+}
+
+// GetSetting returns one setting's effective value and its most recent changes
+func GetSetting(db *gorm.DB, cfg *config.Config, key string) (*SettingView, error) {
+	def, err := lookupSetting(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var row *models.Setting
+	var stored models.Setting
+	if err := db.Where("key = ?", key).First(&stored).Error; err == nil {
+		row = &stored
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load setting: %w", err)
+	}
+
+	view := settingView(def, cfg, row)
+	if err := db.Where("key = ?", key).
+		Order("created_at DESC").
+		Limit(MaxSettingHistory).
+		Find(&view.History).Error; err != nil {
+		return nil, fmt.Errorf("failed to load setting history: %w", err)
+	}
+	return &view, nil
+}
+
+// PutSetting validates and stores a runtime value for key, recording the change with a diff.
+// A JSON null removes the runtime value so the env default applies again.
+func PutSetting(db *gorm.DB, cfg *config.Config, key string, value json.RawMessage, actor Actor) (*SettingView, error) {
+	def, err := lookupSetting(key)
+	if err != nil {
+		return nil, err
+	}
+	reset := len(value) == 0 || string(value) == "null"
+	if !reset {
+		if err := def.check(value); err != nil {
+			return nil, err
+		}
+	}
+
+	updatedBy := actor.AdminID
+	if updatedBy == "" {
+		updatedBy = actor.Type
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Setting
+		var previous json.RawMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&existing).Error
+		switch {
+		case err == nil:
+			previous = json.RawMessage(existing.Value)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		default:
+			return fmt.Errorf("failed to load setting: %w", err)
+		}
+
+		if reset {
+			if previous == nil {
+				return nil
+			}
+			if err := tx.Delete(&models.Setting{}, "key = ?", key).Error; err != nil {
+				return fmt.Errorf("failed to clear setting: %w", err)
+			}
+		} else {
+			row := models.Setting{
+				Key:       key,
+				Value:     string(value),
+				UpdatedBy: updatedBy,
+				UpdatedAt: time.Now().UTC(),
+			}
+			// UpdateAll would skip updated_at because the column has a database default
+			upsert := clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+			}
+			if err := tx.Clauses(upsert).Create(&row).Error; err != nil {
+				return fmt.Errorf("failed to save setting: %w", err)
+			}
+		}
+
+		var next json.RawMessage
+		if !reset {
+			next = value
+		}
+		return recordSettingChange(tx, key, previous, next, updatedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settingsCache.invalidate(key)
+	return GetSetting(db, cfg, key)
+}
+
+// recordSettingChange appends a history row for key going from previous to next (nil when unset)
+func recordSettingChange(tx *gorm.DB, key string, previous, next json.RawMessage, changedBy string) error {
+	diff, err := json.Marshal(settingDiff(previous, next))
+	if err != nil {
+		return fmt.Errorf("failed to marshal setting diff: %w", err)
+	}
+
+	change := models.SettingChange{
+		Key:       key,
+		OldValue:  optionalJSON(previous),
+		NewValue:  optionalJSON(next),
+		Diff:      string(diff),
+		ChangedBy: changedBy,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return fmt.Errorf("failed to record setting change: %w", err)
+	}
+	return nil
+}
+
+// settingDiff describes a change as {"from": ..., "to": ...}. When both values are JSON
+// objects only the fields that changed are listed, each with its own from and to.
+func settingDiff(previous, next json.RawMessage) map[string]interface{} {
+	var from, to interface{}
+	if previous != nil {
+		json.Unmarshal(previous, &from)
+	}
+	if next != nil {
+		json.Unmarshal(next, &to)
+	}
+
+	fromObject, fromOK := from.(map[string]interface{})
+	toObject, toOK := to.(map[string]interface{})
+	if !fromOK || !toOK {
+		return map[string]interface{}{"from": from, "to": to}
+	}
+
+	diff := map[string]interface{}{}
+	for field, value := range fromObject {
+		if !reflect.DeepEqual(value, toObject[field]) {
+			diff[field] = map[string]interface{}{"from": value, "to": toObject[field]}
+		}
+	}
+	for field, value := range toObject {
+		if _, seen := fromObject[field]; !seen {
+			diff[field] = map[string]interface{}{"from": nil, "to": value}
+		}
+	}
+	return diff
+}
+
+// optionalJSON converts a raw JSON value to a nullable jsonb column value
+func optionalJSON(raw json.RawMessage) *string {
+	if raw == nil {
+		return nil
+	}
+	s := string(raw)
+	return &s
+}
+
+// validateUnitInterval accepts values in [0, 1], such as score and confidence thresholds
+func validateUnitInterval(value float64) error {
+	if value < 0 || value > 1 {
+		return errors.New("must be between 0 and 1")
+	}
+	return nil
+}
+
+// Registered runtime settings. Each falls back to its env variable when no value is stored.
+var (
+	SettingAutoPublishThreshold = RegisterSetting(Setting[float64]{
+		Key:         "auto_publish_threshold",
+		Description: "Minimum quality score for auto-publishing, for categories without an override (AUTO_PUBLISH_THRESHOLD)",
+		Default:     func(cfg *config.Config) float64 { return cfg.AutoPublishThreshold },
+		Validate:    validateUnitInterval,
+	})
+
+	SettingGeoConfThreshold = RegisterSetting(Setting[float64]{
+		Key:         "geo_conf_threshold",
+		Description: "Minimum geocoding confidence for creating or updating a venue (GEO_CONF_THRESHOLD)",
+		Default:     func(cfg *config.Config) float64 { return cfg.GeoConfThreshold },
+		Validate:    validateUnitInterval,
+	})
+)
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+func TestSettingValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    float64
+		wantErr bool
+	}{
+		{"in range", `0.85`, 0.85, false},
+		{"lower bound", `0`, 0, false},
+		{"upper bound", `1`, 1, false},
+		{"above one", `1.2`, 0, true},
+		{"negative", `-0.1`, 0, true},
+		{"wrong type", `"high"`, 0, true},
+		{"object", `{"value": 0.5}`, 0, true},
+		{"malformed", `0.8,`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SettingAutoPublishThreshold.decode(json.RawMessage(tt.raw))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSettingValue) {
+					t.Errorf("decode(%s) error = %v, want ErrInvalidSettingValue", tt.raw, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("decode(%s) = %v, %v; want %v", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutSettingRejectsBeforeWriting(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr error
+	}{
+		{"unknown key", "no_such_setting", `1`, ErrUnknownSetting},
+		{"out of range", "geo_conf_threshold", `3`, ErrInvalidSettingValue},
+		{"wrong type", "auto_publish_threshold", `true`, ErrInvalidSettingValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No expectations: a rejected value never reaches the database
+			db, _ := testdb.New(t)
+			_, err := PutSetting(db, categoryConfig, tt.key, json.RawMessage(tt.value), Actor{Type: ActorAdmin, AdminID: "ops"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("PutSetting() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSettingPrecedence(t *testing.T) {
+	cfg := &config.Config{AutoPublishThreshold: 0.8} // TTL 0: every read goes to the database
+
+	tests := []struct {
+		name  string
+		rows  *sqlmock.Rows
+		dbErr error
+		want  float64
+	}{
+		{"nothing stored uses the env default", sqlmock.NewRows([]string{"key", "value"}), nil, 0.8},
+		{"runtime value wins over env", sqlmock.NewRows([]string{"key", "value"}).AddRow("auto_publish_threshold", "0.9"), nil, 0.9},
+		{"invalid stored value falls back", sqlmock.NewRows([]string{"key", "value"}).AddRow("auto_publish_threshold", "7"), nil, 0.8},
+		{"lookup failure falls back", nil, errors.New("connection refused"), 0.8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settingsCache.invalidate(SettingAutoPublishThreshold.Key)
+			db, mock := testdb.New(t)
+			query := mock.ExpectQuery(`SELECT * FROM "settings" WHERE key = $1`).
+				WithArgs("auto_publish_threshold", testdb.Any)
+			if tt.dbErr != nil {
+				query.WillReturnError(tt.dbErr)
+			} else {
+				query.WillReturnRows(tt.rows)
+			}
+
+			if got := SettingAutoPublishThreshold.Get(db, cfg); got != tt.want {
+				t.Errorf("Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingCacheInvalidation(t *testing.T) {
+	cfg := &config.Config{GeoConfThreshold: 0.6, SettingsCacheTTLSec: 3600}
+	key := SettingGeoConfThreshold.Key
+	settingsCache.invalidate(key)
+	t.Cleanup(func() { settingsCache.invalidate(key) })
+	db, mock := testdb.New(t)
+
+	expectStored := func(rows *sqlmock.Rows) {
+		mock.ExpectQuery(`SELECT * FROM "settings" WHERE key = $1`).
+			WithArgs(key, testdb.Any).
+			WillReturnRows(rows)
+	}
+
+	// The first read fills the cache and the second is served from it
+	expectStored(sqlmock.NewRows([]string{"key", "value"}).AddRow(key, "0.7"))
+	for i := 0; i < 2; i++ {
+		if got := SettingGeoConfThreshold.Get(db, cfg); got != 0.7 {
+			t.Fatalf("read %d: Get() = %v, want 0.7", i+1, got)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("cached read hit the database: %v", err)
+	}
+
+	// Writing through PutSetting records the change and drops the cached value
+	mock.ExpectBegin()
+	expectStored(sqlmock.NewRows([]string{"key", "value"}).AddRow(key, "0.7"))
+	mock.ExpectQuery(`INSERT INTO "settings" ("key","value","updated_by","updated_at") VALUES ($1,$2,$3,$4) ON CONFLICT ("key") `+
+		`DO UPDATE SET "value"="excluded"."value","updated_by"="excluded"."updated_by","updated_at"="excluded"."updated_at"`).
+		WithArgs(key, "0.75", "ops", testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+	mock.ExpectQuery(`INSERT INTO "setting_changes"`).
+		WithArgs(key, "0.7", "0.75", `{"from":0.7,"to":0.75}`, "ops", testdb.Any, testdb.Any).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("00000000-0000-0000-0000-000000000001"))
+	mock.ExpectCommit()
+	expectStored(sqlmock.NewRows([]string{"key", "value", "updated_by"}).AddRow(key, "0.75", "ops"))
+	mock.ExpectQuery(`SELECT * FROM "setting_changes" WHERE key = $1 ORDER BY created_at DESC LIMIT $2`).
+		WithArgs(key, MaxSettingHistory).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "diff"}).AddRow(key, `{"from":0.7,"to":0.75}`))
+
+	view, err := PutSetting(db, cfg, key, json.RawMessage(`0.75`), Actor{Type: ActorAdmin, AdminID: "ops"})
+	if err != nil {
+		t.Fatalf("PutSetting() error = %v", err)
+	}
+	if view.Source != SettingSourceRuntime || len(view.History) != 1 {
+		t.Errorf("PutSetting() = %+v, want a runtime value with one change", view)
+	}
+
+	// The next read goes back to the database and sees the new value
+	expectStored(sqlmock.NewRows([]string{"key", "value"}).AddRow(key, "0.75"))
+	if got := SettingGeoConfThreshold.Get(db, cfg); got != 0.75 {
+		t.Errorf("Get() after PutSetting = %v, want 0.75", got)
+	}
+}
+
+func TestSettingDiff(t *testing.T) {
+	tests := []struct {
+		name           string
+		previous, next string // "" for unset
+		want           string
+	}{
+		{"first value", "", `0.8`, `{"from":null,"to":0.8}`},
+		{"scalar change", `0.8`, `0.9`, `{"from":0.8,"to":0.9}`},
+		{"unset", `true`, "", `{"from":true,"to":null}`},
+		{"object fields", `{"a":1,"b":2}`, `{"a":1,"b":3,"c":4}`, `{"b":{"from":2,"to":3},"c":{"from":null,"to":4}}`},
+		{"removed field", `{"a":1,"b":2}`, `{"a":1}`, `{"b":{"from":2,"to":null}}`},
+		{"object replaced by scalar", `{"a":1}`, `5`, `{"from":{"a":1},"to":5}`},
+	}
+
+	raw := func(s string) json.RawMessage {
+		if s == "" {
+			return nil
+		}
+		return json.RawMessage(s)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if got := settingDiff(raw(tt.previous), raw(tt.next)); !reflect.DeepEqual(got, want) {
+				t.Errorf("settingDiff() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// terminalSubmissionStatuses are statuses after which a submission no longer changes on its own
+var terminalSubmissionStatuses = map[string]bool{
+	"done":                          true,
+	"error":                         true,
+	SubmissionStatusFailed:          true,
+	SubmissionStatusRejectedQuality: true,
+}
+
+// IsTerminalSubmissionStatus reports whether a submission has finished processing
+func IsTerminalSubmissionStatus(status string) bool {
+	return terminalSubmissionStatuses[status]
+}
+
+// StatusBroker is an in-process pub/sub for submission status changes.
+// Subscribers receive the latest status; intermediate updates may be coalesced.
+type StatusBroker struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan string]struct{}
+}
+
+func NewStatusBroker() *StatusBroker {
+	return &StatusBroker{
+		subscribers: make(map[uuid.UUID]map[chan string]struct{}),
+	}
+}
+
+// Subscribe registers for status changes of a submission. The returned cancel
+// function must be called to release the subscription.
+func (b *StatusBroker) Subscribe(submissionID uuid.UUID) (<-chan string, func()) {
+	ch := make(chan string, 1)
+
+	b.mu.Lock()
+	if b.subscribers[submissionID] == nil {
+		b.subscribers[submissionID] = make(map[chan string]struct{})
+	}
+	b.subscribers[submissionID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers[submissionID], ch)
+			if len(b.subscribers[submissionID]) == 0 {
+				delete(b.subscribers, submissionID)
+			}
+		})
+	}
+
+	return ch, cancel
+}
+
+// Publish notifies subscribers of a submission's new status without blocking
+func (b *StatusBroker) Publish(submissionID uuid.UUID, status string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[submissionID] {
+		// Replace any unread status so slow readers always see the newest one
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
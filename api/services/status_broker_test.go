@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestStatusBrokerDeliversLatestStatus(t *testing.T) {
+	broker := NewStatusBroker()
+	id := uuid.New()
+	updates, cancel := broker.Subscribe(id)
+	defer cancel()
+
+	// A slow reader only sees the newest of several updates
+	broker.Publish(id, "processing")
+	broker.Publish(id, "parsed")
+	broker.Publish(uuid.New(), "error")
+
+	select {
+	case status := <-updates:
+		if status != "parsed" {
+			t.Errorf("status = %q, want %q", status, "parsed")
+		}
+	default:
+		t.Fatal("no status delivered")
+	}
+	select {
+	case status := <-updates:
+		t.Errorf("unexpected second status %q", status)
+	default:
+	}
+}
+
+func TestStatusBrokerCancelReleasesSubscription(t *testing.T) {
+	broker := NewStatusBroker()
+	id := uuid.New()
+	_, cancelFirst := broker.Subscribe(id)
+	second, cancelSecond := broker.Subscribe(id)
+
+	cancelFirst()
+	cancelFirst() // cancelling twice is harmless
+	broker.Publish(id, "done")
+	if status := <-second; status != "done" {
+		t.Errorf("remaining subscriber got %q, want %q", status, "done")
+	}
+
+	cancelSecond()
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if len(broker.subscribers) != 0 {
+		t.Errorf("%d submissions still subscribed after every cancel", len(broker.subscribers))
+	}
+}
+
+func TestIsTerminalSubmissionStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{"uploaded", false},
+		{"processing", false},
+		{"parsed", false},
+		{"done", true},
+		{"error", true},
+		{SubmissionStatusFailed, true},
+		{SubmissionStatusRejectedQuality, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsTerminalSubmissionStatus(tt.status); got != tt.want {
+			t.Errorf("IsTerminalSubmissionStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
@@ -1,24 +1,94 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	config_pkg "github.com/lincolngreen/williamboard/api/config"
 )
 
+// privateDirName holds per-submission files that must never be served from /files,
+// such as unredacted flyer crops
+const privateDirName = "private"
+
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	StorageBackendLocal = "local"
+	StorageBackendS3    = "s3"
+)
+
+// storageOpTimeout bounds one transfer to or from a remote backend
+const storageOpTimeout = 2 * time.Minute
+
+// MaxUploadBytes is the largest photo accepted in one upload
+const MaxUploadBytes = 12 * 1024 * 1024
+
+// ErrFileTooLarge is returned by FetchUpload for a stored upload over MaxUploadBytes
+var ErrFileTooLarge = errors.New("file too large")
+
+// StorageBackend is where submission files are kept, under keys of the form
+// "<submission id>/<filename>"
+type StorageBackend interface {
+	// SaveFile stores data under key, replacing any earlier file; size is -1 when unknown
+	SaveFile(ctx context.Context, key string, data io.Reader, size int64) error
+	// Open reads the file under key; a missing file returns an error satisfying os.IsNotExist
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetPublicURL is the URL clients fetch the file from
+	GetPublicURL(key string) string
+	// GenerateUploadURL returns a presigned upload of at most maxSize bytes a client can
+	// send the file to directly, or nil when uploads must go through the API
+	GenerateUploadURL(ctx context.Context, key string, maxSize int64) (*DirectUpload, error)
+	// DeleteFile removes the file under key, if there is one
+	DeleteFile(ctx context.Context, key string) error
+	// DeletePrefix removes every file whose key starts with prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// StorageService stores submission files through a StorageBackend. The pipeline reads and
+// writes images as local files, so every file also has a working copy under uploadDir: with
+// the local backend that is the stored file itself, and with a remote one it is fetched on
+// demand (Fetch) and written back after changes (Persist). Files under a submission's
+// private directory, such as unredacted crops, are never written back; each instance cuts
+// its own from the original.
 type StorageService struct {
 	uploadDir string
 	baseURL   string
+	backend   StorageBackend
+	local     bool
+
+	// persisted remembers the size and modification time each working copy had when it was
+	// last written back, so unchanged files aren't uploaded again
+	persisted sync.Map
+}
+
+// DirectUpload is a presigned upload straight to the storage bucket: a multipart POST to URL
+// with Fields, followed by the file
+type DirectUpload struct {
+	URL    string
+	Fields map[string]string
 }
 
 type UploadURLResult struct {
-	SubmissionID string `json:"submissionId"`
-	URL          string `json:"url"`
-	MaxSizeMB    int    `json:"maxSizeMB"`
+	SubmissionID string            `json:"submissionId"`
+	URL          string            `json:"url"`
+	Method       string            `json:"method"`                // PUT (or POST, for a direct upload) the image to URL
+	Fields       map[string]string `json:"fields,omitempty"`      // form fields a direct upload sends before the file
+	CompleteURL  string            `json:"completeUrl,omitempty"` // POST here once a direct upload finishes
+	MaxSizeMB    int               `json:"maxSizeMB"`
+}
+
+// fileStamp identifies one version of a working copy
+type fileStamp struct {
+	size    int64
+	modTime time.Time
 }
 
 func NewStorageService(cfg *config_pkg.Config) *StorageService {
@@ -32,62 +102,191 @@ func NewStorageService(cfg *config_pkg.Config) *StorageService {
 		panic(fmt.Sprintf("unable to create upload directory: %v", err))
 	}
 
-	return &StorageService{
+	s := &StorageService{
 		uploadDir: uploadDir,
 		baseURL:   cfg.PublicBaseURL,
 	}
+	switch cfg.StorageBackend {
+	case StorageBackendS3:
+		backend, err := newS3Backend(cfg)
+		if err != nil {
+			panic(fmt.Sprintf("unable to configure S3 storage: %v", err))
+		}
+		s.backend = backend
+	default:
+		s.backend = &localBackend{dir: uploadDir, baseURL: cfg.PublicBaseURL}
+		s.local = true
+	}
+	return s
+}
+
+// ServesLocalFiles reports whether stored files are served by this API under /files
+func (s *StorageService) ServesLocalFiles() bool {
+	return s.local
+}
+
+// storageKey is a submission file's key in the backend
+func storageKey(submissionID uuid.UUID, filename string) string {
+	return submissionID.String() + "/" + filename
 }
 
-// GenerateUploadURL creates an upload endpoint URL for direct file uploads
-func (s *StorageService) GenerateUploadURL(submissionID uuid.UUID) *UploadURLResult {
-	return &UploadURLResult{
+// GenerateUploadURL returns where a client sends a submission's photo: a presigned bucket URL
+// when the backend takes direct uploads, otherwise this API's upload endpoint
+func (s *StorageService) GenerateUploadURL(submissionID uuid.UUID) (*UploadURLResult, error) {
+	result := &UploadURLResult{
 		SubmissionID: submissionID.String(),
 		URL:          fmt.Sprintf("%s/v1/uploads/%s", s.baseURL, submissionID.String()),
-		MaxSizeMB:    12,
+		Method:       "PUT",
+		MaxSizeMB:    MaxUploadBytes / (1024 * 1024),
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageOpTimeout)
+	defer cancel()
+	direct, err := s.backend.GenerateUploadURL(ctx, storageKey(submissionID, SubmissionOriginalFilename), MaxUploadBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
+	}
+	if direct != nil {
+		result.URL = direct.URL
+		result.Method = "POST"
+		result.Fields = direct.Fields
+		result.CompleteURL = fmt.Sprintf("%s/v1/uploads/%s/complete", s.baseURL, submissionID.String())
+	}
+	return result, nil
 }
 
-// SaveFile saves uploaded file data to disk
+// SaveFile saves file data as the submission's working copy and stores it in the backend
 func (s *StorageService) SaveFile(submissionID uuid.UUID, filename string, data io.Reader) error {
-	submissionDir := filepath.Join(s.uploadDir, submissionID.String())
-	if err := os.MkdirAll(submissionDir, 0755); err != nil {
-		return fmt.Errorf("failed to create submission directory: %w", err)
+	if err := writeLocalFile(s.GetFilePath(submissionID, filename), data); err != nil {
+		return err
+	}
+	return s.Persist(submissionID, filename)
+}
+
+// Persist writes a changed working copy back to a remote backend. It does nothing for the
+// local backend, or when the file hasn't changed since it was last written back.
+func (s *StorageService) Persist(submissionID uuid.UUID, filename string) error {
+	if s.local {
+		return nil
 	}
 
-	filePath := filepath.Join(submissionDir, filename)
-	file, err := os.Create(filePath)
+	path := s.GetFilePath(submissionID, filename)
+	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return fmt.Errorf("failed to open %s: %w", filename, err)
 	}
 	defer file.Close()
-
-	_, err = io.Copy(file, data)
+	info, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
+		return err
+	}
+
+	key := storageKey(submissionID, filename)
+	stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+	if previous, ok := s.persisted.Load(key); ok && previous.(fileStamp) == stamp {
+		return nil
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), storageOpTimeout)
+	defer cancel()
+	if err := s.backend.SaveFile(ctx, key, file, info.Size()); err != nil {
+		return fmt.Errorf("failed to store %s: %w", filename, err)
+	}
+	s.persisted.Store(key, stamp)
 	return nil
 }
 
+// Fetch makes sure a submission file has a working copy, downloading it from a remote
+// backend when this instance doesn't have it yet, and returns the copy's path
+func (s *StorageService) Fetch(submissionID uuid.UUID, filename string) (string, error) {
+	return s.fetch(submissionID, filename, -1)
+}
+
+// FetchUpload is Fetch for a file a client sent straight to the backend, which stops
+// downloading and returns ErrFileTooLarge once it passes MaxUploadBytes
+func (s *StorageService) FetchUpload(submissionID uuid.UUID, filename string) (string, error) {
+	return s.fetch(submissionID, filename, MaxUploadBytes)
+}
+
+// fetch downloads a working copy of at most maxSize bytes, or any size when maxSize is -1
+func (s *StorageService) fetch(submissionID uuid.UUID, filename string, maxSize int64) (string, error) {
+	path := s.GetFilePath(submissionID, filename)
+	if s.local {
+		return path, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageOpTimeout)
+	defer cancel()
+	body, err := s.backend.Open(ctx, storageKey(submissionID, filename))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", filename, err)
+	}
+	defer body.Close()
+
+	// Download beside the working copy and swap it in, so a reader never sees half a file
+	tmpPath := path + ".download"
+	var data io.Reader = body
+	if maxSize >= 0 {
+		data = io.LimitReader(body, maxSize+1)
+	}
+	if err := writeLocalFile(tmpPath, data); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if info, err := os.Stat(tmpPath); err == nil && maxSize >= 0 && info.Size() > maxSize {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to fetch %s: %w", filename, ErrFileTooLarge)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to save %s: %w", filename, err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		s.persisted.Store(storageKey(submissionID, filename), fileStamp{size: info.Size(), modTime: info.ModTime()})
+	}
+	return path, nil
+}
+
+// RemoveFile deletes a submission file and its working copy
+func (s *StorageService) RemoveFile(submissionID uuid.UUID, filename string) error {
+	os.Remove(s.GetFilePath(submissionID, filename))
+	if s.local {
+		return nil
+	}
+
+	key := storageKey(submissionID, filename)
+	s.persisted.Delete(key)
+	ctx, cancel := context.WithTimeout(context.Background(), storageOpTimeout)
+	defer cancel()
+	return s.backend.DeleteFile(ctx, key)
+}
+
 // GetPublicURL returns the public URL for a file
 func (s *StorageService) GetPublicURL(submissionID uuid.UUID, filename string) string {
-	return fmt.Sprintf("%s/files/%s/%s", s.baseURL, submissionID.String(), filename)
+	return s.backend.GetPublicURL(storageKey(submissionID, filename))
 }
 
 // GetOriginalImageURL returns the public URL for an original image
 func (s *StorageService) GetOriginalImageURL(submissionID uuid.UUID) string {
-	return s.GetPublicURL(submissionID, "original.jpg")
+	return s.GetPublicURL(submissionID, SubmissionOriginalFilename)
+}
+
+// GetSubmissionImageURL returns the public URL for one of a submission's photos, from 1
+func (s *StorageService) GetSubmissionImageURL(submissionID uuid.UUID, index int) string {
+	return s.GetPublicURL(submissionID, SubmissionImageFilename(index))
 }
 
 // GetDerivativeImageURL returns the public URL for a derivative image
 func (s *StorageService) GetDerivativeImageURL(submissionID uuid.UUID) string {
-	return s.GetPublicURL(submissionID, "derivative.jpg")
+	return s.GetPublicURL(submissionID, SubmissionDerivativeFilename)
 }
 
-// GetCropImageURL returns the public URL for a flyer crop
-func (s *StorageService) GetCropImageURL(submissionID uuid.UUID, regionID string) string {
-	filename := fmt.Sprintf("crop_%s.jpg", regionID)
-	return s.GetPublicURL(submissionID, filename)
+// GetThumbnailURL returns the public URL for a submission's dashboard thumbnail
+func (s *StorageService) GetThumbnailURL(submissionID uuid.UUID) string {
+	return s.GetPublicURL(submissionID, SubmissionThumbnailFilename)
 }
 
 // GetFilePath returns the local file system path for a file
@@ -95,7 +294,99 @@ func (s *StorageService) GetFilePath(submissionID uuid.UUID, filename string) st
 	return filepath.Join(s.uploadDir, submissionID.String(), filename)
 }
 
+// GetPrivateFilePath returns the local path for an admin-only file of a submission
+func (s *StorageService) GetPrivateFilePath(submissionID uuid.UUID, filename string) string {
+	return filepath.Join(s.uploadDir, submissionID.String(), privateDirName, filename)
+}
+
+// GetAdminCropURL returns the admin-only URL for a flyer's unredacted crop
+func (s *StorageService) GetAdminCropURL(flyerID uuid.UUID) string {
+	return fmt.Sprintf("%s/admin/flyers/%s/crop", s.baseURL, flyerID.String())
+}
+
+// PublicFilePath resolves a path requested under /files to a local file, refusing
+// anything outside the upload directory or inside a private directory
+func (s *StorageService) PublicFilePath(requested string) (string, bool) {
+	cleaned := filepath.Clean("/" + requested)
+	for _, segment := range strings.Split(cleaned, "/") {
+		if segment == privateDirName || strings.HasPrefix(segment, ".") {
+			return "", false
+		}
+	}
+	return filepath.Join(s.uploadDir, cleaned), true
+}
+
 // GetUploadDir returns the upload directory path
 func (s *StorageService) GetUploadDir() string {
 	return s.uploadDir
-}
\ No newline at end of file
+}
+
+// DeleteSubmissionFiles removes every stored file for a submission, and its working copies
+func (s *StorageService) DeleteSubmissionFiles(submissionID uuid.UUID) error {
+	if !s.local {
+		ctx, cancel := context.WithTimeout(context.Background(), storageOpTimeout)
+		defer cancel()
+		if err := s.backend.DeletePrefix(ctx, submissionID.String()+"/"); err != nil {
+			return err
+		}
+		s.persisted.Range(func(key, _ interface{}) bool {
+			if strings.HasPrefix(key.(string), submissionID.String()+"/") {
+				s.persisted.Delete(key)
+			}
+			return true
+		})
+	}
+	return os.RemoveAll(filepath.Join(s.uploadDir, submissionID.String()))
+}
+
+// writeLocalFile copies data to path, creating its directory
+func writeLocalFile(path string, data io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create submission directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, data); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
+
+// localBackend keeps files on disk under dir and serves them from /files
+type localBackend struct {
+	dir     string
+	baseURL string
+}
+
+func (b *localBackend) SaveFile(ctx context.Context, key string, data io.Reader, size int64) error {
+	return writeLocalFile(filepath.Join(b.dir, filepath.FromSlash(key)), data)
+}
+
+func (b *localBackend) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(b.dir, filepath.FromSlash(key)))
+}
+
+func (b *localBackend) GetPublicURL(key string) string {
+	return fmt.Sprintf("%s/files/%s", b.baseURL, key)
+}
+
+// GenerateUploadURL returns nil: local uploads go through the API's upload endpoint
+func (b *localBackend) GenerateUploadURL(ctx context.Context, key string, maxSize int64) (*DirectUpload, error) {
+	return nil, nil
+}
+
+func (b *localBackend) DeleteFile(ctx context.Context, key string) error {
+	if err := os.Remove(filepath.Join(b.dir, filepath.FromSlash(key))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *localBackend) DeletePrefix(ctx context.Context, prefix string) error {
+	return os.RemoveAll(filepath.Join(b.dir, filepath.FromSlash(strings.TrimSuffix(prefix, "/"))))
+}
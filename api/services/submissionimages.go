@@ -0,0 +1,109 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// SubmissionOriginalFilename is a submission's first photo, and its only one before
+// submissions could carry several
+const SubmissionOriginalFilename = "original.jpg"
+
+// SubmissionImageFilename names a submission's photo by its 1-based index. The first keeps
+// SubmissionOriginalFilename so earlier submissions, thumbnails, and links still find it;
+// later photos are original_2.jpg, original_3.jpg, and so on.
+func SubmissionImageFilename(index int) string {
+	if index <= 1 {
+		return SubmissionOriginalFilename
+	}
+	return fmt.Sprintf("original_%d.jpg", index)
+}
+
+// DedupeSubmissionCandidates drops candidates that repeat an event already read from an
+// earlier photo of the same submission, matching on normalized title and start date (the
+// date as printed when it can't be parsed). The copy from the lowest-numbered photo is
+// kept; flyers left without candidates are deleted with their duplicates. Candidates from
+// the same photo are never merged, since one flyer can list an event twice on purpose.
+// It returns how many candidates were removed.
+func DedupeSubmissionCandidates(db *gorm.DB, submissionID uuid.UUID, loc *time.Location) (int, error) {
+	var rows []struct {
+		models.EventCandidate
+		ImageIndex int
+	}
+	if err := db.Model(&models.EventCandidate{}).
+		Select("event_candidates.*, flyers.image_index").
+		Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+		Where("flyers.submission_id = ?", submissionID).
+		Order("flyers.image_index, event_candidates.created_at").
+		Scan(&rows).Error; err != nil {
+		return 0, fmt.Errorf("failed to load candidates: %w", err)
+	}
+
+	firstImage := map[string]int{}
+	var duplicateIDs []uuid.UUID
+	dupFlyers := map[uuid.UUID]bool{}
+	for i := range rows {
+		key, ok := candidateShotKey(db, &rows[i].EventCandidate, loc)
+		if !ok {
+			continue
+		}
+		index, seen := firstImage[key]
+		if !seen {
+			firstImage[key] = rows[i].ImageIndex
+			continue
+		}
+		if index != rows[i].ImageIndex {
+			duplicateIDs = append(duplicateIDs, rows[i].ID)
+			dupFlyers[rows[i].FlyerID] = true
+		}
+	}
+	if len(duplicateIDs) == 0 {
+		return 0, nil
+	}
+
+	flyerIDs := make([]uuid.UUID, 0, len(dupFlyers))
+	for id := range dupFlyers {
+		flyerIDs = append(flyerIDs, id)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ?", duplicateIDs).Delete(&models.EventCandidate{}).Error; err != nil {
+			return fmt.Errorf("failed to delete duplicate candidates: %w", err)
+		}
+		if err := tx.Where("id IN ?", flyerIDs).
+			Where("NOT EXISTS (SELECT 1 FROM event_candidates ec WHERE ec.flyer_id = flyers.id)").
+			Delete(&models.Flyer{}).Error; err != nil {
+			return fmt.Errorf("failed to delete emptied flyers: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(duplicateIDs), nil
+}
+
+// candidateShotKey is the title and start date a candidate is matched on across photos;
+// ok is false for candidates without a title
+func candidateShotKey(db *gorm.DB, candidate *models.EventCandidate, loc *time.Location) (string, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(candidate.Fields), &fields); err != nil {
+		return "", false
+	}
+	title, _ := fields["title"].(string)
+	title = normalizeDedupeTitle(title)
+	if title == "" {
+		return "", false
+	}
+
+	date := strings.ToLower(strings.TrimSpace(CandidateDateString(fields)))
+	if start, _, ok := ParseCandidateStart(db, candidate, fields, loc); ok {
+		date = start.Time.In(loc).Format("2006-01-02")
+	}
+	return title + "_" + date, true
+}
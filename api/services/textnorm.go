@@ -0,0 +1,96 @@
+package services
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Invisible characters that extraction sometimes leaves in text. They render as nothing,
+// defeat dedupe and search, and some CSV/calendar consumers choke on them.
+const (
+	zeroWidthSpace     = '\u200B'
+	zeroWidthNonJoiner = '\u200C'
+	zeroWidthJoiner    = '\u200D'
+	wordJoiner         = '\u2060'
+	byteOrderMark      = '\uFEFF'
+	mongolianVowelSep  = '\u180E'
+)
+
+// isDroppedInvisible reports whether r is always removed. U+FFFD is what JSON decoding
+// leaves for an unpaired UTF-16 surrogate, so it is dropped along with the zero-width set.
+func isDroppedInvisible(r rune) bool {
+	switch r {
+	case zeroWidthSpace, wordJoiner, byteOrderMark, mongolianVowelSep, utf8.RuneError:
+		return true
+	}
+	return false
+}
+
+// stripJoiners removes zero-width joiners and non-joiners unless they sit between two
+// visible characters, where they build emoji sequences and shape some scripts
+func stripJoiners(runes []rune) []rune {
+	out := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		if r == zeroWidthJoiner || r == zeroWidthNonJoiner {
+			if i == 0 || i == len(runes)-1 || unicode.IsSpace(runes[i-1]) || unicode.IsSpace(runes[i+1]) ||
+				runes[i+1] == zeroWidthJoiner || runes[i+1] == zeroWidthNonJoiner {
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// extendsGrapheme reports whether r attaches to the preceding character rather than
+// starting a new user-perceived character. This covers combining marks, variation
+// selectors, emoji skin tones and tag sequences; it is not a full UAX #29 segmenter.
+func extendsGrapheme(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF: // variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tag characters (subdivision flags)
+		return true
+	case r == zeroWidthJoiner:
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// truncateGraphemes cuts runes to at most maxRunes without splitting a user-perceived
+// character, so a capped title never ends in half an emoji or a stray accent
+func truncateGraphemes(runes []rune, maxRunes int) []rune {
+	if len(runes) <= maxRunes {
+		return runes
+	}
+
+	cut := 0
+	for i := 0; i < len(runes); {
+		end := i + 1
+		pairedFlag := isRegionalIndicator(runes[i]) && end < len(runes) && isRegionalIndicator(runes[end])
+		if pairedFlag {
+			end++
+		}
+		for end < len(runes) {
+			if runes[end-1] == zeroWidthJoiner || extendsGrapheme(runes[end]) {
+				end++
+				continue
+			}
+			break
+		}
+		if end > maxRunes {
+			break
+		}
+		cut = end
+		i = end
+	}
+	return runes[:cut]
+}
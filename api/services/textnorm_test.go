@@ -0,0 +1,172 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestTruncateGraphemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		maxRunes int
+		want     string
+	}{
+		{"short enough", "abc", 5, "abc"},
+		{"plain cut", "abcdef", 3, "abc"},
+		{"combining mark stays with its letter", "abe\u0301", 3, "ab"},
+		{"skin tone stays with its emoji", "a\U0001F44D\U0001F3FD", 2, "a"},
+		{"flag is not split", "a\U0001F1FA\U0001F1F8", 2, "a"},
+		{"zwj sequence is not split", "a\U0001F468\u200d\U0001F469", 3, "a"},
+		{"variation selector stays attached", "a\u2764\ufe0f", 2, "a"},
+		{"whole graphemes fit", "a\u2764\ufe0fb", 3, "a\u2764\ufe0f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(truncateGraphemes([]rune(tt.in), tt.maxRunes)); got != tt.want {
+				t.Errorf("truncateGraphemes(%q, %d) = %q, want %q", tt.in, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}
+
+// extractionFixtures are raw model responses, kept in testdata/extractions, that once broke
+// re-encoding after they were stored
+var extractionFixtures = []struct {
+	file      string
+	wantTitle string
+	wantVenue string
+	wantDesc  string
+}{
+	{
+		file:      "unpaired_surrogates.txt",
+		wantTitle: "Open Mic Night",
+		wantVenue: "The Rookery",
+		wantDesc:  "Bring your \U0001F3B8 and a friend",
+	},
+	{
+		file:      "invisible_characters.txt",
+		wantTitle: "Jazz Brunch",
+		wantVenue: "Caf\u00e9 Luna",
+		wantDesc:  "Family day \U0001F468\u200d\U0001F469\u200d\U0001F467\nDoors at 10",
+	},
+	{
+		file:      "emoji_heavy.txt",
+		wantTitle: strings.Repeat("\U0001F44D\U0001F3FD", 150),
+		wantVenue: "Flag Hall \U0001F1FA\U0001F1F8",
+		wantDesc:  "Ring the bell",
+	},
+	{
+		file:      "invalid_utf8.txt",
+		wantTitle: "Caf Concert",
+		wantVenue: "Salle toile",
+		wantDesc:  "Entre libre",
+	},
+}
+
+// TestExtractionRoundTrips runs each fixture through the whole chain: parse the model's
+// response, store it as a candidate, draft the event, and write it out as ICS, CSV and JSON
+func TestExtractionRoundTrips(t *testing.T) {
+	for _, fixture := range extractionFixtures {
+		t.Run(fixture.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", "extractions", fixture.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result SingleFlyerResult
+			if err := json.Unmarshal([]byte(jsonObject(string(raw))), &result); err != nil {
+				t.Fatalf("parse response: %v", err)
+			}
+
+			// Candidate: sanitized before the write
+			db, mock := testdb.New(t)
+			creates := testdb.RecordCreates(t, db)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "event_candidates"`).WillReturnRows(testdb.IDs(uuid.New()))
+			mock.ExpectCommit()
+			if _, err := SaveFlyerCandidates(db, uuid.New(), result.Events); err != nil {
+				t.Fatalf("SaveFlyerCandidates() error = %v", err)
+			}
+			candidate := creates.Table("event_candidates")[0].(*models.EventCandidate)
+			assertCleanText(t, "candidate fields", candidate.Fields)
+			assertCleanText(t, "source excerpt", *candidate.SourceExcerpt)
+
+			// Event: drafted from the stored fields, with no capture, duplicate or venue on file
+			mock.ExpectQuery(`FROM "submissions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			mock.ExpectQuery(`FROM "events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			mock.ExpectQuery(`FROM "venues"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			draft, err := DraftEventFromCandidate(db, candidate, time.UTC)
+			if err != nil {
+				t.Fatalf("DraftEventFromCandidate() error = %v", err)
+			}
+			event := draft.Event
+			event.ID = uuid.New()
+			if event.Title != fixture.wantTitle || draft.Venue == nil || draft.Venue.Name != fixture.wantVenue ||
+				event.Description == nil || *event.Description != fixture.wantDesc {
+				t.Fatalf("event = title %q, venue %v, description %v; want %q, %q, %q",
+					event.Title, draft.Venue, event.Description, fixture.wantTitle, fixture.wantVenue, fixture.wantDesc)
+			}
+			if n := utf8.RuneCountInString(event.Title); n > FieldLimits["title"] {
+				t.Errorf("title is %d runes, over the %d limit", n, FieldLimits["title"])
+			}
+			event.Venue = draft.Venue
+
+			// ICS: every folded line is valid UTF-8 within 75 octets
+			ics := ICSCalendar{ProdID: "-//test//EN", UIDDomain: "example.com", Location: time.UTC}.Render([]models.Event{event})
+			for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+				if !utf8.ValidString(line) || len(line) > 75 {
+					t.Errorf("ICS line %q is invalid UTF-8 or longer than 75 octets", line)
+				}
+			}
+			unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+			if !strings.Contains(unfolded, "SUMMARY:"+event.Title+"\r\n") {
+				t.Errorf("ICS does not carry the title intact:\n%s", ics)
+			}
+
+			// CSV and JSON read back exactly what was written
+			var buf bytes.Buffer
+			writer := csv.NewWriter(&buf)
+			writer.Write([]string{event.Title, draft.Venue.Name, *event.Description})
+			writer.Flush()
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil || len(records) != 1 || records[0][0] != event.Title || records[0][2] != *event.Description {
+				t.Errorf("CSV round trip = %q, %v", records, err)
+			}
+
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded models.Event
+			if err := json.Unmarshal(encoded, &decoded); err != nil || decoded.Title != event.Title || *decoded.Description != *event.Description {
+				t.Errorf("JSON round trip = %q, %v", decoded.Title, err)
+			}
+		})
+	}
+}
+
+// assertCleanText fails when s would not survive re-encoding: invalid UTF-8, unpaired
+// surrogate replacements, invisible characters, or text that is not NFC
+func assertCleanText(t *testing.T, what, s string) {
+	t.Helper()
+	if !utf8.ValidString(s) || !norm.NFC.IsNormalString(s) {
+		t.Errorf("%s %q is not valid NFC UTF-8", what, s)
+	}
+	if strings.ContainsAny(s, "\ufffd\u200b\u2060\ufeff\x00\x07") {
+		t.Errorf("%s %q still has replacement, invisible or control characters", what, s)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// SubmissionThumbnailFilename is the dashboard-sized copy of a submission's original
+	SubmissionThumbnailFilename = "thumb.jpg"
+	// submissionThumbnailWidth bounds a submission thumbnail's width
+	submissionThumbnailWidth = 400
+)
+
+// RecordSubmissionThumbnail writes a JPEG of the upright original at most 400px wide next to
+// it and stores its URL as the submission's thumbnail, which the admin dashboard shows
+// instead of the full original. Run it after NormalizeSubmissionImage.
+func RecordSubmissionThumbnail(db *gorm.DB, storage *StorageService, submissionID uuid.UUID, imagePath string) error {
+	original, err := decodeImageFile(imagePath)
+	if err != nil {
+		return err
+	}
+
+	// downscale bounds the longer side; a portrait image needs a taller bound to be 400px wide
+	bounds := original.Bounds()
+	maxDim := submissionThumbnailWidth
+	if bounds.Dy() > bounds.Dx() {
+		maxDim = bounds.Dy() * submissionThumbnailWidth / bounds.Dx()
+	}
+	if err := writeJPEG(storage.GetFilePath(submissionID, SubmissionThumbnailFilename), downscale(original, maxDim)); err != nil {
+		return err
+	}
+	if err := storage.Persist(submissionID, SubmissionThumbnailFilename); err != nil {
+		return err
+	}
+
+	return db.Model(&models.Submission{}).Where("id = ?", submissionID).
+		Update("thumbnail_url", storage.GetThumbnailURL(submissionID)).Error
+}
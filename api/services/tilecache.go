@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// TileCache holds encoded vector tiles keyed by tile and filters. Entries are stamped with
+// the change feed's latest seq, so any publish, unpublish, or edit invalidates them.
+type TileCache struct {
+	mu         sync.Mutex
+	entries    map[string]tileCacheEntry
+	maxEntries int
+}
+
+type tileCacheEntry struct {
+	version int64
+	data    []byte
+}
+
+// NewTileCache creates a cache holding at most maxEntries tiles (0 disables caching)
+func NewTileCache(maxEntries int) *TileCache {
+	return &TileCache{
+		entries:    make(map[string]tileCacheEntry),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get returns a cached tile if it was built at version
+func (c *TileCache) Get(key string, version int64) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.version != version {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// Put stores a tile built at version, evicting stale entries first when full
+func (c *TileCache) Put(key string, version int64, data []byte) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if entry.version != version {
+				delete(c.entries, k)
+			}
+		}
+		// Still full of current tiles: drop an arbitrary one
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = tileCacheEntry{version: version, data: data}
+}
+
+// EventDataVersion returns the latest change feed seq, which advances on every event change
+func EventDataVersion(db *gorm.DB) (int64, error) {
+	var version int64
+	err := db.Raw("SELECT COALESCE(MAX(seq), 0) FROM event_changes").Scan(&version).Error
+	return version, err
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTileCacheVersions(t *testing.T) {
+	cache := NewTileCache(10)
+	cache.Put("0/0/0", 7, []byte("tile"))
+
+	tests := []struct {
+		name    string
+		key     string
+		version int64
+		wantHit bool
+	}{
+		{"same version", "0/0/0", 7, true},
+		{"events changed since", "0/0/0", 8, false},
+		{"other tile", "1/0/0", 7, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, hit := cache.Get(tt.key, tt.version)
+			if hit != tt.wantHit || (hit && string(data) != "tile") {
+				t.Errorf("Get(%q, %d) = %q, %v; want hit %v", tt.key, tt.version, data, hit, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestTileCacheEvictsStaleTilesFirst(t *testing.T) {
+	cache := NewTileCache(3)
+	cache.Put("stale-a", 1, []byte("a"))
+	cache.Put("stale-b", 1, []byte("b"))
+	cache.Put("current", 2, []byte("c"))
+	cache.Put("new", 2, []byte("d"))
+
+	if _, hit := cache.Get("current", 2); !hit {
+		t.Error("current tile was evicted while stale ones were cached")
+	}
+	if _, hit := cache.Get("new", 2); !hit {
+		t.Error("new tile was not cached")
+	}
+	if len(cache.entries) != 2 {
+		t.Errorf("cache holds %d tiles, want the 2 current ones", len(cache.entries))
+	}
+}
+
+func TestTileCacheStaysBounded(t *testing.T) {
+	cache := NewTileCache(5)
+	for i := 0; i < 50; i++ {
+		cache.Put(fmt.Sprintf("tile-%d", i), 1, []byte("x"))
+		if len(cache.entries) > 5 {
+			t.Fatalf("cache grew to %d tiles, want at most 5", len(cache.entries))
+		}
+	}
+	if _, hit := cache.Get("tile-49", 1); !hit {
+		t.Error("most recent tile was not cached")
+	}
+}
+
+func TestTileCacheDisabled(t *testing.T) {
+	cache := NewTileCache(0)
+	cache.Put("0/0/0", 1, []byte("tile"))
+	if _, hit := cache.Get("0/0/0", 1); hit {
+		t.Error("disabled cache returned a tile")
+	}
+}
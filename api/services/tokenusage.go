@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// TokenUsage is the vision and moderation tokens billed for one or more submissions
+type TokenUsage struct {
+	VisionInputTokens      int64 `json:"vision_input_tokens"`
+	VisionOutputTokens     int64 `json:"vision_output_tokens"`
+	ModerationInputTokens  int64 `json:"moderation_input_tokens"`
+	ModerationOutputTokens int64 `json:"moderation_output_tokens"`
+	TotalTokens            int64 `json:"total_tokens"`
+}
+
+// RecordModerationUsage adds a candidate's moderation call to its submission's running
+// totals. Mock and failed calls carry no tokens and are skipped.
+func RecordModerationUsage(db *gorm.DB, flyerID uuid.UUID, result *ModerationResult) error {
+	if result.PromptTokens == 0 && result.CompletionTokens == 0 {
+		return nil
+	}
+	return db.Model(&models.Submission{}).
+		Where("id = (SELECT submission_id FROM flyers WHERE id = ?)", flyerID).
+		Updates(map[string]interface{}{
+			"moderation_input_tokens":  gorm.Expr("COALESCE(moderation_input_tokens, 0) + ?", result.PromptTokens),
+			"moderation_output_tokens": gorm.Expr("COALESCE(moderation_output_tokens, 0) + ?", result.CompletionTokens),
+		}).Error
+}
+
+// SubmissionTokenUsage totals a submission's token columns
+func SubmissionTokenUsage(submission *models.Submission) TokenUsage {
+	value := func(tokens *int) int64 {
+		if tokens == nil {
+			return 0
+		}
+		return int64(*tokens)
+	}
+	usage := TokenUsage{
+		VisionInputTokens:      value(submission.VisionInputTokens),
+		VisionOutputTokens:     value(submission.VisionOutputTokens),
+		ModerationInputTokens:  value(submission.ModerationInputTokens),
+		ModerationOutputTokens: value(submission.ModerationOutputTokens),
+	}
+	usage.TotalTokens = usage.VisionInputTokens + usage.VisionOutputTokens +
+		usage.ModerationInputTokens + usage.ModerationOutputTokens
+	return usage
+}
+
+// SumTokenUsage totals the tokens billed for submissions created since the given time
+func SumTokenUsage(db *gorm.DB, since time.Time) (TokenUsage, error) {
+	var usage TokenUsage
+	if err := db.Model(&models.Submission{}).
+		Select(`COALESCE(SUM(vision_input_tokens), 0) AS vision_input_tokens,
+			COALESCE(SUM(vision_output_tokens), 0) AS vision_output_tokens,
+			COALESCE(SUM(moderation_input_tokens), 0) AS moderation_input_tokens,
+			COALESCE(SUM(moderation_output_tokens), 0) AS moderation_output_tokens`).
+		Where("created_at > ?", since).
+		Scan(&usage).Error; err != nil {
+		return TokenUsage{}, fmt.Errorf("failed to sum token usage: %w", err)
+	}
+	usage.TotalTokens = usage.VisionInputTokens + usage.VisionOutputTokens +
+		usage.ModerationInputTokens + usage.ModerationOutputTokens
+	return usage, nil
+}
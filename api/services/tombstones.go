@@ -0,0 +1,239 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ValidDeleteReasons are the reasons accepted when deleting an event outright
+var ValidDeleteReasons = []string{"legal_takedown", "privacy", "copyright", "other"}
+
+// ErrInvalidDeleteReason is returned for reasons outside ValidDeleteReasons
+var ErrInvalidDeleteReason = errors.New("invalid delete reason")
+
+// ReviewReasonRecentlyDeleted marks candidates that would auto-publish an event deleted
+// within EVENT_DELETE_COOLDOWN_DAYS
+const ReviewReasonRecentlyDeleted = "recently_deleted"
+
+// EventStateDeleted is the moderation state of a tombstone rendered as an event (a calendar
+// cancellation); no stored event has it
+const EventStateDeleted = "deleted"
+
+// maxDeleteNoteLength bounds the free-text note kept on a tombstone
+const maxDeleteNoteLength = 1000
+
+// IsValidDeleteReason reports whether reason is one of ValidDeleteReasons
+func IsValidDeleteReason(reason string) bool {
+	for _, valid := range ValidDeleteReasons {
+		if reason == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalKeyHash is how a tombstone remembers a deleted event's canonical key
+func CanonicalKeyHash(canonicalKey string) string {
+	sum := sha256.Sum256([]byte(canonicalKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeleteResult reports what a delete removed
+type DeleteResult struct {
+	EventID       uuid.UUID   `json:"event_id"`
+	Reason        string      `json:"reason"`
+	CandidateIDs  []uuid.UUID `json:"candidate_ids"`
+	DeletedAt     time.Time   `json:"deleted_at"`
+	CooldownUntil time.Time   `json:"cooldown_until"`
+}
+
+// DeleteEvent removes an event for good, for takedowns where blocking isn't enough. The
+// event row, its history snapshots, state transitions, flags, and dedupe links are
+// deleted, and linked candidates are blocked and unlinked. A tombstone takes the event's
+// place: it announces the deletion on the change feed, keeps a cancellation in the bulk
+// calendar feed while the event is in its window, and keeps the canonical key from
+// auto-publishing again for EVENT_DELETE_COOLDOWN_DAYS. All in one transaction.
+func DeleteEvent(db *gorm.DB, cfg *config.Config, eventID uuid.UUID, reason, note string, actor Actor) (*DeleteResult, error) {
+	if !IsValidDeleteReason(reason) {
+		return nil, ErrInvalidDeleteReason
+	}
+	note = strings.TrimSpace(note)
+	if len(note) > maxDeleteNoteLength {
+		note = note[:maxDeleteNoteLength]
+	}
+
+	result := &DeleteResult{EventID: eventID, Reason: reason, DeletedAt: time.Now().UTC()}
+	result.CooldownUntil = result.DeletedAt.AddDate(0, 0, cfg.EventDeleteCooldownDays)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventNotFound
+			}
+			return err
+		}
+
+		if err := tx.Create(&models.EventTombstone{
+			EventID:          event.ID,
+			CanonicalKeyHash: CanonicalKeyHash(event.CanonicalKey),
+			StartTs:          event.StartTs,
+			EndTs:            event.EndTs,
+			ICSSequence:      event.ICSSequence + 1,
+			Reason:           reason,
+			Note:             optionalString(note),
+			ActorType:        actor.Type,
+			AdminID:          optionalString(actor.AdminID),
+			DeletedAt:        result.DeletedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to write tombstone: %w", err)
+		}
+
+		if err := tx.Model(&models.EventCandidate{}).
+			Where("published_event_id = ?", eventID).
+			Pluck("id", &result.CandidateIDs).Error; err != nil {
+			return fmt.Errorf("failed to list linked candidates: %w", err)
+		}
+		if len(result.CandidateIDs) > 0 {
+			if err := tx.Model(&models.EventCandidate{}).
+				Where("id IN ?", result.CandidateIDs).
+				Updates(map[string]interface{}{
+					"publish_result":     "blocked",
+					"publication_reason": "deleted: " + reason,
+					"published_event_id": nil,
+				}).Error; err != nil {
+				return fmt.Errorf("failed to block linked candidates: %w", err)
+			}
+		}
+
+		for _, model := range []interface{}{&models.EventHistory{}, &models.EventStateTransition{}, &models.Flag{}} {
+			if err := tx.Where("event_id = ?", eventID).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to delete event records: %w", err)
+			}
+		}
+		if err := tx.Where("primary_event_id = ? OR duplicate_event_id = ?", eventID, eventID).
+			Delete(&models.DedupeLink{}).Error; err != nil {
+			return fmt.Errorf("failed to delete dedupe links: %w", err)
+		}
+		if err := tx.Delete(&models.Event{}, "id = ?", eventID).Error; err != nil {
+			return fmt.Errorf("failed to delete event: %w", err)
+		}
+
+		metadata := map[string]interface{}{"reason": reason}
+		if note != "" {
+			metadata["note"] = note
+		}
+		if err := RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityEvent,
+			EntityID:   eventID,
+			Action:     AuditActionEventDeleted,
+			Actor:      actor,
+			Changes:    map[string]interface{}{"candidate_ids": result.CandidateIDs},
+			Metadata:   metadata,
+		}); err != nil {
+			return err
+		}
+		candidateMetadata := map[string]interface{}{
+			"reason":           reason,
+			"deleted_event_id": eventID,
+		}
+		for _, candidateID := range result.CandidateIDs {
+			if err := RecordAudit(tx, AuditEntry{
+				EntityType: AuditEntityCandidate,
+				EntityID:   candidateID,
+				Action:     AuditActionCandidateBlocked,
+				Actor:      actor,
+				Changes: map[string]interface{}{
+					"publish_result": map[string]string{"to": "blocked"},
+				},
+				Metadata: candidateMetadata,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return RecordEventChange(tx, eventID, ChangeTypeDeleted, ChangeReasonRemoved)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// IsEventDeleted reports whether an event ID belongs to a deleted event
+func IsEventDeleted(db *gorm.DB, eventID uuid.UUID) (bool, error) {
+	var count int64
+	if err := db.Model(&models.EventTombstone{}).Where("event_id = ?", eventID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check tombstones: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecentlyDeletedEvent returns the tombstone keeping a canonical key from auto-publishing:
+// an event with that key deleted within EVENT_DELETE_COOLDOWN_DAYS. Nil when there is none.
+// Moderators can still publish the candidate by hand.
+func RecentlyDeletedEvent(db *gorm.DB, cfg *config.Config, canonicalKey string) (*models.EventTombstone, error) {
+	if cfg.EventDeleteCooldownDays <= 0 {
+		return nil, nil
+	}
+	since := time.Now().AddDate(0, 0, -cfg.EventDeleteCooldownDays)
+
+	var tombstones []models.EventTombstone
+	if err := db.Where("canonical_key_hash = ? AND deleted_at > ?", CanonicalKeyHash(canonicalKey), since).
+		Order("deleted_at DESC").Limit(1).Find(&tombstones).Error; err != nil {
+		return nil, fmt.Errorf("failed to check tombstones: %w", err)
+	}
+	if len(tombstones) == 0 {
+		return nil, nil
+	}
+	return &tombstones[0], nil
+}
+
+// tombstoneFeedEvents returns deleted events overlapping [from, until) as calendar entries
+// to cancel: the event's UID and times with a bumped SEQUENCE, and nothing of its content
+func tombstoneFeedEvents(db *gorm.DB, from, until time.Time) ([]models.Event, error) {
+	var tombstones []models.EventTombstone
+	if err := db.Where("event_tombstones.start_ts < ?", until).
+		Where("(event_tombstones.start_ts >= ? OR "+eventEndSQL("event_tombstones", "?")+" > ?)", from, DefaultEventDurationSeconds(), from).
+		Order("event_tombstones.start_ts ASC").
+		Limit(MaxICSFeedEvents).
+		Find(&tombstones).Error; err != nil {
+		return nil, fmt.Errorf("failed to load deleted events: %w", err)
+	}
+
+	events := make([]models.Event, len(tombstones))
+	for i, tombstone := range tombstones {
+		events[i] = models.Event{
+			ID:              tombstone.EventID,
+			Title:           "Cancelled",
+			StartTs:         tombstone.StartTs,
+			EndTs:           tombstone.EndTs,
+			ModerationState: EventStateDeleted,
+			ICSSequence:     tombstone.ICSSequence,
+			UpdatedAt:       tombstone.DeletedAt,
+		}
+	}
+	return events, nil
+}
+
+// mergeFeedEvents merges two start-ordered event lists, keeping at most limit
+func mergeFeedEvents(events, more []models.Event, limit int) []models.Event {
+	merged := append(events, more...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StartTs.Before(merged[j].StartTs)
+	})
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestDeleteEvent(t *testing.T) {
+	start := time.Date(2026, 6, 12, 19, 0, 0, 0, time.UTC)
+	candidates := []uuid.UUID{uuid.New(), uuid.New()}
+
+	tests := []struct {
+		name       string
+		reason     string
+		found      bool
+		candidates []uuid.UUID // linked to the event
+		wantErr    error
+	}{
+		{name: "takedown of an event with two flyers", reason: "legal_takedown", found: true, candidates: candidates},
+		{name: "event published by hand", reason: "privacy", found: true},
+		{name: "unknown reason", reason: "spam", wantErr: ErrInvalidDeleteReason},
+		{name: "already gone", reason: "copyright", wantErr: ErrEventNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+			if tt.wantErr != ErrInvalidDeleteReason {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"id", "canonical_key", "start_ts", "ics_sequence"})
+				if tt.found {
+					rows.AddRow(eventID.String(), "open mic night_2026-06-12", start, 3)
+				}
+				mock.ExpectQuery(`SELECT * FROM "events" WHERE id = $1 ORDER BY "events"."id" LIMIT $2 FOR UPDATE`).
+					WithArgs(eventID, 1).
+					WillReturnRows(rows)
+			}
+			if tt.found {
+				// The tombstone keeps a hash of the key, not the key, and bumps the calendar sequence
+				mock.ExpectQuery(`INSERT INTO "event_tombstones"`).
+					WithArgs(eventID, CanonicalKeyHash("open mic night_2026-06-12"), start, nil, 4, tt.reason, "court order 24-118", ActorAdmin, "ops", testdb.Any).
+					WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(time.Now()))
+				mock.ExpectQuery(`SELECT "id" FROM "event_candidates" WHERE published_event_id = $1`).
+					WithArgs(eventID).
+					WillReturnRows(testdb.IDs(tt.candidates...))
+				if len(tt.candidates) > 0 {
+					mock.ExpectExec(`UPDATE "event_candidates" SET "publication_reason"=$1,"publish_result"=$2,"published_event_id"=$3 WHERE id IN ($4,$5)`).
+						WithArgs("deleted: "+tt.reason, "blocked", nil, tt.candidates[0], tt.candidates[1]).
+						WillReturnResult(sqlmock.NewResult(0, 2))
+				}
+				for _, table := range []string{"event_history", "event_state_transitions", "flags"} {
+					mock.ExpectExec(`DELETE FROM "` + table + `" WHERE event_id = $1`).
+						WithArgs(eventID).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectExec(`DELETE FROM "dedupe_links" WHERE primary_event_id = $1 OR duplicate_event_id = $2`).
+					WithArgs(eventID, eventID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`DELETE FROM "events" WHERE id = $1`).
+					WithArgs(eventID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAudit(mock, AuditActionEventDeleted)
+				for range tt.candidates {
+					expectAudit(mock, AuditActionCandidateBlocked)
+				}
+				expectEventChange(mock, eventID, ChangeTypeDeleted, ChangeReasonRemoved)
+				mock.ExpectCommit()
+			} else if tt.wantErr == ErrEventNotFound {
+				mock.ExpectRollback()
+			}
+
+			cfg := &config.Config{EventDeleteCooldownDays: 30}
+			result, err := DeleteEvent(db, cfg, eventID, tt.reason, "  court order 24-118  ", Actor{Type: ActorAdmin, AdminID: "ops"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("DeleteEvent() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeleteEvent() error = %v", err)
+			}
+			if len(result.CandidateIDs) != len(tt.candidates) {
+				t.Errorf("blocked candidates = %v, want %v", result.CandidateIDs, tt.candidates)
+			}
+			if cooldown := result.CooldownUntil.Sub(result.DeletedAt); cooldown != 30*24*time.Hour {
+				t.Errorf("cooldown lasts %v, want 30 days", cooldown)
+			}
+		})
+	}
+}
+
+func TestRecentlyDeletedEventDisabled(t *testing.T) {
+	db, _ := testdb.New(t)
+	tombstone, err := RecentlyDeletedEvent(db, &config.Config{}, EventCanonicalKey("Open Mic Night", time.Now()))
+	if tombstone != nil || err != nil {
+		t.Errorf("RecentlyDeletedEvent() = %v, %v with no cooldown; want nothing and no query", tombstone, err)
+	}
+}
+
+func TestEventCanonicalKey(t *testing.T) {
+	evening := time.Date(2026, 6, 12, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		title string
+		start time.Time
+		same  bool // same key as "Open Mic Night" on the evening of June 12
+	}{
+		{"re-read title in capitals", "OPEN MIC NIGHT", evening, true},
+		{"padded title", "  Open Mic Night\t", evening, true},
+		{"later the same day", "Open Mic Night", evening.Add(2 * time.Hour), true},
+		{"next week", "Open Mic Night", evening.AddDate(0, 0, 7), false},
+		{"other event", "Open Mic", evening, false},
+	}
+
+	want := EventCanonicalKey("Open Mic Night", evening)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EventCanonicalKey(tt.title, tt.start)
+			if (got == want) != tt.same || (CanonicalKeyHash(got) == CanonicalKeyHash(want)) != tt.same {
+				t.Errorf("EventCanonicalKey(%q, %v) = %q; same as %q: %v", tt.title, tt.start, got, want, tt.same)
+			}
+		})
+	}
+}
+
+func TestTombstoneFeedEvents(t *testing.T) {
+	db, mock := testdb.New(t)
+	from := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	eventID := uuid.New()
+	deletedAt := from.Add(36 * time.Hour)
+	mock.ExpectQuery(`SELECT * FROM "event_tombstones" WHERE event_tombstones.start_ts < $1`).
+		WillReturnRows(sqlmock.NewRows([]string{"event_id", "start_ts", "ics_sequence", "reason", "note", "deleted_at"}).
+			AddRow(eventID.String(), from.AddDate(0, 0, 3), 4, "privacy", "the organizer's home address", deletedAt))
+
+	events, err := tombstoneFeedEvents(db, from, from.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatalf("tombstoneFeedEvents() error = %v", err)
+	}
+	want := models.Event{ID: eventID, Title: "Cancelled", StartTs: from.AddDate(0, 0, 3), ModerationState: EventStateDeleted, ICSSequence: 4, UpdatedAt: deletedAt}
+	if len(events) != 1 || events[0].ID != want.ID || events[0].Title != want.Title || !events[0].StartTs.Equal(want.StartTs) ||
+		events[0].ModerationState != want.ModerationState || events[0].ICSSequence != want.ICSSequence || !events[0].UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("tombstoneFeedEvents() = %+v, want only a cancellation %+v", events, want)
+	}
+	if events[0].Description != nil || events[0].VenueID != nil {
+		t.Error("cancellation carries content of the deleted event")
+	}
+}
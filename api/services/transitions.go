@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Event moderation states
+const (
+	EventStatePending  = "pending"
+	EventStateApproved = "approved"
+	EventStateBlocked  = "blocked"
+	EventStateMerged   = "merged" // a likely duplicate of another event, pending admin review
+)
+
+// Actor types recorded on state transitions
+const (
+	ActorAuto   = "auto"   // the publish pipeline
+	ActorAdmin  = "admin"  // the admin dashboard or API
+	ActorAPI    = "api"    // public API callers
+	ActorSystem = "system" // background jobs and migrations
+)
+
+// Reason codes for transitions that aren't driven by an unpublish reason
+const (
+	TransitionReasonAutoPublished   = "auto_published"
+	TransitionReasonManualApproved  = "manual_approved"
+	TransitionReasonRepublished     = "republished"
+	TransitionReasonVenueDispute    = "venue_dispute"
+	TransitionReasonDisputeRejected = "dispute_rejected"
+	TransitionReasonMergedDuplicate = "merged_duplicate"
+	TransitionReasonMergeRejected   = "merge_rejected"
+	TransitionReasonUserFlags       = "user_flags"
+	TransitionReasonFlagsDismissed  = "flags_dismissed"
+)
+
+// ErrInvalidStateTransition is returned for state changes outside allowedEventTransitions
+var ErrInvalidStateTransition = errors.New("event state transition not allowed")
+
+// allowedEventTransitions is the moderation_state graph; "" is a newly created event
+var allowedEventTransitions = map[string][]string{
+	"":                 {EventStatePending, EventStateApproved, EventStateMerged},
+	EventStatePending:  {EventStateApproved, EventStateBlocked, EventStateMerged},
+	EventStateApproved: {EventStatePending, EventStateBlocked, EventStateMerged}, // back to pending when disputed or flagged
+	EventStateBlocked:  {EventStateApproved},
+	EventStateMerged:   {EventStateApproved}, // the merge was rejected
+}
+
+// EventTransition describes a requested moderation_state change
+type EventTransition struct {
+	To          string
+	Actor       string
+	Reason      string
+	CandidateID *uuid.UUID
+	FlagID      *uuid.UUID
+}
+
+// IsAllowedEventTransition reports whether from -> to is in the state graph
+func IsAllowedEventTransition(from, to string) bool {
+	for _, allowed := range allowedEventTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionEventState moves an existing event to t.To and records the transition.
+// Must run inside the caller's transaction; the event row is locked while it is checked.
+func TransitionEventState(tx *gorm.DB, eventID uuid.UUID, t EventTransition) error {
+	var event models.Event
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "moderation_state").
+		First(&event, "id = ?", eventID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEventNotFound
+		}
+		return err
+	}
+
+	if err := recordStateTransition(tx, eventID, event.ModerationState, t); err != nil {
+		return err
+	}
+
+	// Bump SEQUENCE so calendars replace their copy: a takedown becomes a cancellation
+	// and a republish supersedes it
+	return tx.Model(&models.Event{}).Where("id = ?", eventID).Updates(map[string]interface{}{
+		"moderation_state": t.To,
+		"ics_sequence":     gorm.Expr("ics_sequence + 1"),
+		"updated_at":       time.Now(),
+	}).Error
+}
+
+// RecordEventCreated records the initial state of a newly created event
+func RecordEventCreated(tx *gorm.DB, event *models.Event, t EventTransition) error {
+	t.To = event.ModerationState
+	return recordStateTransition(tx, event.ID, "", t)
+}
+
+// recordStateTransition validates from -> t.To and writes the transition row
+func recordStateTransition(tx *gorm.DB, eventID uuid.UUID, from string, t EventTransition) error {
+	if !IsAllowedEventTransition(from, t.To) {
+		if from == "" {
+			from = "(new)"
+		}
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStateTransition, from, t.To)
+	}
+
+	transition := models.EventStateTransition{
+		EventID:     eventID,
+		ToState:     t.To,
+		ActorType:   t.Actor,
+		ReasonCode:  t.Reason,
+		CandidateID: t.CandidateID,
+		FlagID:      t.FlagID,
+		CreatedAt:   time.Now(),
+	}
+	if from != "" {
+		transition.FromState = &from
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		return fmt.Errorf("failed to record state transition: %w", err)
+	}
+	return nil
+}
+
+// ListEventTransitions returns an event's state history, oldest first
+func ListEventTransitions(db *gorm.DB, eventID uuid.UUID) ([]models.EventStateTransition, error) {
+	var transitions []models.EventStateTransition
+	err := db.Where("event_id = ?", eventID).Order("created_at ASC").Find(&transitions).Error
+	return transitions, err
+}
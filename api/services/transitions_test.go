@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+func TestIsAllowedEventTransition(t *testing.T) {
+	states := []string{"", EventStatePending, EventStateApproved, EventStateBlocked, EventStateMerged}
+	allowed := map[[2]string]bool{
+		{"", EventStatePending}:                 true,
+		{"", EventStateApproved}:                true,
+		{"", EventStateMerged}:                  true,
+		{EventStatePending, EventStateApproved}: true,
+		{EventStatePending, EventStateBlocked}:  true,
+		{EventStatePending, EventStateMerged}:   true,
+		{EventStateApproved, EventStatePending}: true,
+		{EventStateApproved, EventStateBlocked}: true,
+		{EventStateApproved, EventStateMerged}:  true,
+		{EventStateBlocked, EventStateApproved}: true,
+		{EventStateMerged, EventStateApproved}:  true,
+	}
+
+	for _, from := range states {
+		for _, to := range states[1:] {
+			if got := IsAllowedEventTransition(from, to); got != allowed[[2]string{from, to}] {
+				t.Errorf("IsAllowedEventTransition(%q, %q) = %v", from, to, got)
+			}
+		}
+	}
+}
+
+func TestTransitionEventStateWritesOneRow(t *testing.T) {
+	candidateID, flagID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		from       string
+		transition EventTransition
+	}{
+		{
+			name:       "republished by a corroborating candidate",
+			from:       EventStateBlocked,
+			transition: EventTransition{To: EventStateApproved, Actor: ActorAuto, Reason: TransitionReasonRepublished, CandidateID: &candidateID},
+		},
+		{
+			name:       "manually republished",
+			from:       EventStateBlocked,
+			transition: EventTransition{To: EventStateApproved, Actor: ActorAdmin, Reason: TransitionReasonRepublished, CandidateID: &candidateID},
+		},
+		{
+			name:       "unpublished from the public API",
+			from:       EventStateApproved,
+			transition: EventTransition{To: EventStateBlocked, Actor: ActorAPI, Reason: "spam"},
+		},
+		{
+			name:       "unpublished from the dashboard",
+			from:       EventStateApproved,
+			transition: EventTransition{To: EventStateBlocked, Actor: ActorAdmin, Reason: "duplicate"},
+		},
+		{
+			name:       "pulled into review by flags",
+			from:       EventStateApproved,
+			transition: EventTransition{To: EventStatePending, Actor: ActorSystem, Reason: TransitionReasonUserFlags, FlagID: &flagID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			creates := testdb.RecordCreates(t, db)
+			eventID := uuid.New()
+			mock.ExpectBegin()
+			expectTransition(mock, eventID, tt.from)
+			mock.ExpectCommit()
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return TransitionEventState(tx, eventID, tt.transition)
+			})
+			if err != nil {
+				t.Fatalf("TransitionEventState() error = %v", err)
+			}
+
+			rows := creates.Table("event_state_transitions")
+			if len(rows) != 1 {
+				t.Fatalf("wrote %d transition rows, want 1", len(rows))
+			}
+			row := rows[0].(*models.EventStateTransition)
+			if row.EventID != eventID || row.FromState == nil || *row.FromState != tt.from || row.ToState != tt.transition.To {
+				t.Errorf("row = %s %v -> %s, want %s %s -> %s", row.EventID, row.FromState, row.ToState, eventID, tt.from, tt.transition.To)
+			}
+			if row.ActorType != tt.transition.Actor || row.ReasonCode != tt.transition.Reason ||
+				row.CandidateID != tt.transition.CandidateID || row.FlagID != tt.transition.FlagID {
+				t.Errorf("row = %+v, want the transition's actor, reason and links %+v", row, tt.transition)
+			}
+		})
+	}
+}
+
+func TestTransitionEventStateRefusesDisallowedMoves(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+	}{
+		{EventStateBlocked, EventStatePending},
+		{EventStateBlocked, EventStateBlocked},
+		{EventStateApproved, EventStateApproved},
+		{EventStateMerged, EventStateBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			eventID := uuid.New()
+			// Only the lookup runs; nothing is written
+			mock.ExpectQuery(`SELECT "id","moderation_state" FROM "events"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "moderation_state"}).AddRow(eventID.String(), tt.from))
+
+			err := TransitionEventState(db, eventID, EventTransition{To: tt.to, Actor: ActorAdmin, Reason: "spam"})
+			if !errors.Is(err, ErrInvalidStateTransition) {
+				t.Errorf("TransitionEventState() error = %v, want ErrInvalidStateTransition", err)
+			}
+		})
+	}
+}
+
+func TestTransitionEventStateMissingEvent(t *testing.T) {
+	db, mock := testdb.New(t)
+	mock.ExpectQuery(`SELECT "id","moderation_state" FROM "events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "moderation_state"}))
+
+	err := TransitionEventState(db, uuid.New(), EventTransition{To: EventStateBlocked, Actor: ActorAdmin, Reason: "spam"})
+	if !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("TransitionEventState() error = %v, want ErrEventNotFound", err)
+	}
+}
+
+func TestRecordEventCreated(t *testing.T) {
+	candidateID := uuid.New()
+
+	tests := []struct {
+		name    string
+		state   string
+		actor   string
+		reason  string
+		wantErr bool
+	}{
+		{"auto-published", EventStateApproved, ActorAuto, TransitionReasonAutoPublished, false},
+		{"manually approved", EventStateApproved, ActorAdmin, TransitionReasonManualApproved, false},
+		{"held as a duplicate", EventStateMerged, ActorAuto, TransitionReasonMergedDuplicate, false},
+		{"created blocked", EventStateBlocked, ActorAdmin, TransitionReasonManualApproved, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			creates := testdb.RecordCreates(t, db)
+			event := &models.Event{ID: uuid.New(), ModerationState: tt.state}
+			if !tt.wantErr {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "event_state_transitions"`).WillReturnRows(testdb.IDs(uuid.New()))
+				mock.ExpectCommit()
+			}
+
+			err := RecordEventCreated(db, event, EventTransition{Actor: tt.actor, Reason: tt.reason, CandidateID: &candidateID})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidStateTransition) {
+					t.Errorf("RecordEventCreated() error = %v, want ErrInvalidStateTransition", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RecordEventCreated() error = %v", err)
+			}
+			rows := creates.Table("event_state_transitions")
+			if len(rows) != 1 {
+				t.Fatalf("wrote %d transition rows, want 1", len(rows))
+			}
+			row := rows[0].(*models.EventStateTransition)
+			if row.FromState != nil || row.ToState != tt.state || row.ActorType != tt.actor || row.ReasonCode != tt.reason {
+				t.Errorf("row = %+v, want a creation row to %s by %s for %s", row, tt.state, tt.actor, tt.reason)
+			}
+		})
+	}
+}
+
+func TestUnpublishEventRecordsOneTransition(t *testing.T) {
+	db, mock := testdb.New(t)
+	creates := testdb.RecordCreates(t, db)
+	eventID, candidateID := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	expectTransition(mock, eventID, EventStateApproved)
+	expectEventHistory(mock, eventID)
+	mock.ExpectQuery(`SELECT "id" FROM "event_candidates" WHERE published_event_id = $1`).
+		WithArgs(eventID).
+		WillReturnRows(testdb.IDs(candidateID))
+	mock.ExpectExec(`UPDATE "event_candidates" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, AuditActionEventUnpublished)
+	expectAudit(mock, AuditActionCandidateBlocked)
+	expectEventChange(mock, eventID, ChangeTypeDeleted, ChangeReasonUnpublished)
+	mock.ExpectCommit()
+
+	result, err := UnpublishEvent(db, eventID, "spam", Actor{Type: ActorAPI})
+	if err != nil {
+		t.Fatalf("UnpublishEvent() error = %v", err)
+	}
+	if len(result.CandidateIDs) != 1 || result.CandidateIDs[0] != candidateID {
+		t.Errorf("CandidateIDs = %v, want [%s]", result.CandidateIDs, candidateID)
+	}
+
+	rows := creates.Table("event_state_transitions")
+	if len(rows) != 1 {
+		t.Fatalf("wrote %d transition rows, want 1", len(rows))
+	}
+	if row := rows[0].(*models.EventStateTransition); row.ToState != EventStateBlocked || row.ActorType != ActorAPI || row.ReasonCode != "spam" {
+		t.Errorf("row = %+v, want approved -> blocked by api for spam", row)
+	}
+}
+
+func TestUnpublishEventAlreadyBlocked(t *testing.T) {
+	db, mock := testdb.New(t)
+	eventID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id","moderation_state" FROM "events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "moderation_state"}).AddRow(eventID.String(), EventStateBlocked))
+	mock.ExpectRollback()
+
+	if _, err := UnpublishEvent(db, eventID, "spam", Actor{Type: ActorAdmin}); !errors.Is(err, ErrInvalidStateTransition) {
+		t.Errorf("UnpublishEvent() error = %v, want ErrInvalidStateTransition", err)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// ValidUnpublishReasons are the reasons accepted when taking a published event down
+var ValidUnpublishReasons = []string{"spam", "duplicate", "bad_location", "inappropriate"}
+
+var (
+	// ErrEventNotFound is returned when the event to unpublish does not exist
+	ErrEventNotFound = errors.New("event not found")
+	// ErrInvalidUnpublishReason is returned for reasons outside ValidUnpublishReasons
+	ErrInvalidUnpublishReason = errors.New("invalid unpublish reason")
+)
+
+// IsValidUnpublishReason reports whether reason is one of ValidUnpublishReasons
+func IsValidUnpublishReason(reason string) bool {
+	for _, valid := range ValidUnpublishReasons {
+		if reason == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// UnpublishResult reports what an unpublish touched
+type UnpublishResult struct {
+	EventID      uuid.UUID   `json:"event_id"`
+	Reason       string      `json:"reason"`
+	CandidateIDs []uuid.UUID `json:"candidate_ids"`
+}
+
+// LinkedCandidateCount returns how many candidates point at an event. More than one
+// means the event is corroborated and unpublishing it affects all of them.
+func LinkedCandidateCount(db *gorm.DB, eventID uuid.UUID) (int64, error) {
+	var count int64
+	if err := db.Model(&models.EventCandidate{}).Where("published_event_id = ?", eventID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count linked candidates: %w", err)
+	}
+	return count, nil
+}
+
+// UnpublishEvent blocks a public event and every candidate linked to it, writing audit
+// entries and a change feed entry in a single transaction. actor is an admin or API caller.
+func UnpublishEvent(db *gorm.DB, eventID uuid.UUID, reason string, actor Actor) (*UnpublishResult, error) {
+	if !IsValidUnpublishReason(reason) {
+		return nil, ErrInvalidUnpublishReason
+	}
+
+	var result *UnpublishResult
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = unpublishEvent(tx, eventID, reason, actor, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// unpublishEvent does the work of UnpublishEvent inside the caller's transaction.
+// flagID links the transition and audit entry to the flag that prompted it, if any.
+func unpublishEvent(tx *gorm.DB, eventID uuid.UUID, reason string, actor Actor, flagID *uuid.UUID) (*UnpublishResult, error) {
+	result := &UnpublishResult{EventID: eventID, Reason: reason}
+
+	if err := TransitionEventState(tx, eventID, EventTransition{
+		To:     EventStateBlocked,
+		Actor:  actor.Type,
+		Reason: reason,
+		FlagID: flagID,
+	}); err != nil {
+		return nil, err
+	}
+	if err := RecordEventHistoryByID(tx, eventID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.EventCandidate{}).
+		Where("published_event_id = ?", eventID).
+		Pluck("id", &result.CandidateIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list linked candidates: %w", err)
+	}
+
+	if len(result.CandidateIDs) > 0 {
+		publicationReason := "unpublished: " + reason
+		if err := tx.Model(&models.EventCandidate{}).
+			Where("id IN ?", result.CandidateIDs).
+			Updates(map[string]interface{}{
+				"publish_result":     "blocked",
+				"publication_reason": publicationReason,
+			}).Error; err != nil {
+			return nil, fmt.Errorf("failed to block linked candidates: %w", err)
+		}
+	}
+
+	metadata := map[string]interface{}{
+		"reason": reason,
+	}
+	if flagID != nil {
+		metadata["flag_id"] = *flagID
+	}
+	if err := RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityEvent,
+		EntityID:   eventID,
+		Action:     AuditActionEventUnpublished,
+		Actor:      actor,
+		Changes: map[string]interface{}{
+			"moderation_state": map[string]string{"to": "blocked"},
+			"candidate_ids":    result.CandidateIDs,
+		},
+		Metadata: metadata,
+	}); err != nil {
+		return nil, err
+	}
+	candidateMetadata := map[string]interface{}{
+		"reason":               reason,
+		"unpublished_event_id": eventID,
+	}
+	for _, candidateID := range result.CandidateIDs {
+		if err := RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityCandidate,
+			EntityID:   candidateID,
+			Action:     AuditActionCandidateBlocked,
+			Actor:      actor,
+			Changes: map[string]interface{}{
+				"publish_result": map[string]string{"from": "published", "to": "blocked"},
+			},
+			Metadata: candidateMetadata,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := RecordEventChange(tx, eventID, ChangeTypeDeleted, ChangeReasonUnpublished); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
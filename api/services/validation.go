@@ -0,0 +1,197 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FieldLimits caps the length (in runes) of each extracted text field before it is stored
+var FieldLimits = map[string]int{
+	"title":           300,
+	"date_time":       100,
+	"start_time":      100,
+	"end_time":        100,
+	"venue":           200,
+	"address":         300,
+	"price":           100,
+	"description":     2000,
+	"organizer":       200,
+	"url":             500,
+	"ticket_url":      500,
+	"contact_info":    200,
+	"category":        50,
+	"age_restriction": 50,
+	"source_excerpt":  2000,
+	"notes":           1000,
+}
+
+// multilineFields keep line breaks; every other field is collapsed onto one line
+var multilineFields = map[string]bool{
+	"description":    true,
+	"source_excerpt": true,
+	"notes":          true,
+}
+
+// defaultFieldLimit applies to fields without an explicit entry in FieldLimits
+const defaultFieldLimit = 500
+
+// SanitizeText makes extracted text safe to store and re-encode: it enforces valid UTF-8,
+// normalizes to NFC, drops control and invisible characters (including what is left of
+// unpaired surrogates), normalizes whitespace, and truncates to maxLen runes without
+// splitting a user-perceived character. When multiline is false all whitespace
+// (including newlines) collapses to single spaces.
+func SanitizeText(s string, maxLen int, multiline bool) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	s = norm.NFC.String(s)
+
+	var b strings.Builder
+	b.Grow(len(s))
+	lastSpace := false
+	lastNewline := false
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\r':
+			if multiline {
+				if !lastNewline {
+					b.WriteRune('\n')
+				}
+				lastNewline = true
+				lastSpace = true
+				continue
+			}
+			r = ' '
+			fallthrough
+		case unicode.IsSpace(r):
+			if !lastSpace {
+				b.WriteRune(' ')
+			}
+			lastSpace = true
+			continue
+		case unicode.IsControl(r), isDroppedInvisible(r):
+			continue
+		}
+		b.WriteRune(r)
+		lastSpace = false
+		lastNewline = false
+	}
+
+	result := strings.TrimSpace(string(stripJoiners([]rune(b.String()))))
+	if multiline {
+		// Drop spaces left hanging around line breaks
+		lines := strings.Split(result, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimSpace(line)
+		}
+		result = strings.Join(lines, "\n")
+	}
+
+	if maxLen > 0 && utf8.RuneCountInString(result) > maxLen {
+		result = strings.TrimSpace(string(truncateGraphemes([]rune(result), maxLen)))
+	}
+
+	return result
+}
+
+// SanitizeField applies the per-field limit and line handling for a named field
+func SanitizeField(name, value string) string {
+	limit, ok := FieldLimits[name]
+	if !ok {
+		limit = defaultFieldLimit
+	}
+
+	cleaned := SanitizeText(value, limit, multilineFields[name])
+	if name == "url" || name == "ticket_url" {
+		return sanitizeURL(cleaned)
+	}
+	return cleaned
+}
+
+// sanitizeURL drops anything that is not a plain http(s) link
+func sanitizeURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+
+	candidate := raw
+	if !strings.Contains(candidate, "://") {
+		// Flyers usually print bare domains like "example.com/tickets"
+		if strings.ContainsAny(candidate, " <>\"'") || !strings.Contains(candidate, ".") {
+			return ""
+		}
+		candidate = "https://" + candidate
+	}
+
+	parsed, err := url.Parse(candidate)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ""
+	}
+
+	return parsed.String()
+}
+
+// sanitizeOptional cleans an optional field, returning nil when nothing is left
+func sanitizeOptional(name string, value *string) *string {
+	if value == nil {
+		return nil
+	}
+	cleaned := SanitizeField(name, *value)
+	if cleaned == "" {
+		return nil
+	}
+	return &cleaned
+}
+
+// SanitizeEventFields cleans every extracted field in place
+func SanitizeEventFields(fields *EventFields) {
+	fields.Title = SanitizeField("title", fields.Title)
+	fields.DateTime = sanitizeOptional("date_time", fields.DateTime)
+	fields.StartTime = sanitizeOptional("start_time", fields.StartTime)
+	fields.EndTime = sanitizeOptional("end_time", fields.EndTime)
+	fields.Venue = sanitizeOptional("venue", fields.Venue)
+	fields.Address = sanitizeOptional("address", fields.Address)
+	fields.Price = sanitizeOptional("price", fields.Price)
+	fields.Description = sanitizeOptional("description", fields.Description)
+	fields.Organizer = sanitizeOptional("organizer", fields.Organizer)
+	fields.URL = sanitizeOptional("url", fields.URL)
+	fields.TicketURL = sanitizeOptional("ticket_url", fields.TicketURL)
+	fields.ContactInfo = sanitizeOptional("contact_info", fields.ContactInfo)
+	fields.Category = sanitizeOptional("category", fields.Category)
+	fields.AgeRestriction = sanitizeOptional("age_restriction", fields.AgeRestriction)
+
+	qrURLs := make([]string, 0, len(fields.QRCodeURLs))
+	for _, raw := range fields.QRCodeURLs {
+		if cleaned := SanitizeField("url", raw); cleaned != "" {
+			qrURLs = append(qrURLs, cleaned)
+		}
+	}
+	fields.QRCodeURLs = nil
+	if len(qrURLs) > 0 {
+		fields.QRCodeURLs = qrURLs
+	}
+}
+
+// SanitizeFieldMap cleans string values in a loosely-typed fields map in place.
+// Empty strings are removed so downstream "field present" checks stay meaningful.
+func SanitizeFieldMap(fields map[string]interface{}) {
+	for name, value := range fields {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		cleaned := SanitizeField(name, str)
+		if cleaned == "" {
+			delete(fields, name)
+			continue
+		}
+		fields[name] = cleaned
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		maxLen    int
+		multiline bool
+		want      string
+	}{
+		{"plain text is untouched", "Jazz Night", 0, false, "Jazz Night"},
+		{"whitespace collapses", "  Jazz \t  Night  ", 0, false, "Jazz Night"},
+		{"newlines become spaces on one-line fields", "Jazz\nNight\r\nLive", 0, false, "Jazz Night Live"},
+		{"multiline keeps single line breaks", "Doors 7pm  \n\n\n  Show 8pm", 0, true, "Doors 7pm\nShow 8pm"},
+		{"control characters are dropped", "Jazz\x00\x07 Night\x1b", 0, false, "Jazz Night"},
+		{"zero-width characters are dropped", "Ja\u200bzz\ufeff Night", 0, false, "Jazz Night"},
+		{"invalid UTF-8 is removed", "Caf\xe9 Night", 0, false, "Caf Night"},
+		{"decomposed accents are composed", "Cafe\u0301", 0, false, "Caf\u00e9"},
+		{"markup is kept verbatim for templates to escape", `<script>alert(1)</script>`, 0, false, `<script>alert(1)</script>`},
+		{"truncates to max runes", "abcdefghij", 4, false, "abcd"},
+		{"truncation trims a trailing space", "abc defgh", 4, false, "abc"},
+		{"truncation keeps an emoji sequence whole", "ab\U0001F468\u200d\U0001F469\u200d\U0001F467", 4, false, "ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeText(tt.in, tt.maxLen, tt.multiline); got != tt.want {
+				t.Errorf("SanitizeText(%q, %d, %v) = %q, want %q", tt.in, tt.maxLen, tt.multiline, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeField(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		in    string
+		want  string
+	}{
+		{"http link is kept", "url", "http://example.com/tickets", "http://example.com/tickets"},
+		{"bare domain gets https", "ticket_url", "example.com/tickets", "https://example.com/tickets"},
+		{"javascript scheme is dropped", "url", "javascript:alert(document.cookie)", ""},
+		{"data scheme is dropped", "url", "data:text/html,<script>alert(1)</script>", ""},
+		{"bare text with markup is dropped", "url", `example.com/"><script>`, ""},
+		{"bare word is not a link", "url", "tickets", ""},
+		{"title is one line", "title", "Spring\nFair", "Spring Fair"},
+		{"description keeps lines", "description", "Line one\nLine two", "Line one\nLine two"},
+		{"title is capped", "title", strings.Repeat("a", 400), strings.Repeat("a", FieldLimits["title"])},
+		{"unknown fields use the default cap", "mystery", strings.Repeat("b", 600), strings.Repeat("b", defaultFieldLimit)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeField(tt.field, tt.in); got != tt.want {
+				t.Errorf("SanitizeField(%q, %q) = %q, want %q", tt.field, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFieldMap(t *testing.T) {
+	fields := map[string]interface{}{
+		"title":      " Open\u200b Mic ",
+		"venue":      "   ",
+		"url":        "javascript:void(0)",
+		"confidence": 0.9,
+	}
+
+	SanitizeFieldMap(fields)
+
+	if fields["title"] != "Open Mic" {
+		t.Errorf("title = %q, want %q", fields["title"], "Open Mic")
+	}
+	for _, dropped := range []string{"venue", "url"} {
+		if _, ok := fields[dropped]; ok {
+			t.Errorf("%s = %q, want it removed", dropped, fields[dropped])
+		}
+	}
+	if fields["confidence"] != 0.9 {
+		t.Errorf("non-string confidence = %v, want it left alone", fields["confidence"])
+	}
+}
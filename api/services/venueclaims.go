@@ -0,0 +1,413 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FlagTypeVenueDispute marks a flag filed by a venue manager with a claim token
+const FlagTypeVenueDispute = "venue_dispute"
+
+// Flag statuses
+const (
+	FlagStatusPending   = "pending"
+	FlagStatusResolved  = "resolved"  // the flag was upheld
+	FlagStatusDismissed = "dismissed" // the flag was rejected
+)
+
+// DisputeAcceptReason is the unpublish reason recorded when an admin upholds a dispute:
+// the venue says the event doesn't happen there
+const DisputeAcceptReason = "bad_location"
+
+var (
+	// ErrVenueClaimsDisabled is returned when issuing a claim without a signing key
+	ErrVenueClaimsDisabled = errors.New("venue claims are not configured")
+	// ErrInvalidVenueClaim is returned for a malformed token or one whose signature doesn't match
+	ErrInvalidVenueClaim = errors.New("invalid venue claim token")
+	// ErrVenueClaimExpired is returned for a correctly signed token past its expiry
+	ErrVenueClaimExpired = errors.New("venue claim token expired")
+	// ErrVenueClaimRevoked is returned for a token whose claim an admin has revoked
+	ErrVenueClaimRevoked = errors.New("venue claim revoked")
+	// ErrVenueClaimNotFound is returned when a claim does not exist for the venue
+	ErrVenueClaimNotFound = errors.New("venue claim not found")
+	// ErrEventNotAtVenue is returned when disputing an event attributed to another venue
+	ErrEventNotAtVenue = errors.New("event is not attributed to this venue")
+	// ErrDisputeLimitExceeded is returned when a venue has filed its daily quota of disputes
+	ErrDisputeLimitExceeded = errors.New("venue dispute limit exceeded")
+	// ErrDisputeNotFound is returned when a dispute does not exist
+	ErrDisputeNotFound = errors.New("dispute not found")
+	// ErrDisputeResolved is returned when accepting or rejecting a dispute that was already decided
+	ErrDisputeResolved = errors.New("dispute already resolved")
+)
+
+// venueClaimSignature signs a claim, its venue, and expiry. The purpose prefix keeps
+// these signatures from being valid for anything else signed with the same key.
+func venueClaimSignature(key string, claimID, venueID uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "venue-claim:%s:%s:%d", claimID, venueID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VenueClaimToken is the management token for a claim: "<claim id>.<expires unix>.<signature>"
+func VenueClaimToken(key string, claim *models.VenueClaim) string {
+	expires := claim.ExpiresAt.Unix()
+	return fmt.Sprintf("%s.%d.%s", claim.ID, expires, venueClaimSignature(key, claim.ID, claim.VenueID, expires))
+}
+
+// VerifyVenueClaimToken checks a token's signature and expiry for venueID and returns its
+// claim ID. It does not consult the database; see AuthenticateVenueClaim for revocation.
+func VerifyVenueClaimToken(key string, venueID uuid.UUID, token string, now time.Time) (uuid.UUID, error) {
+	if key == "" {
+		return uuid.Nil, ErrInvalidVenueClaim
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return uuid.Nil, ErrInvalidVenueClaim
+	}
+	claimID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, ErrInvalidVenueClaim
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return uuid.Nil, ErrInvalidVenueClaim
+	}
+	expected := venueClaimSignature(key, claimID, venueID, expires)
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return uuid.Nil, ErrInvalidVenueClaim
+	}
+	if now.After(time.Unix(expires, 0)) {
+		return uuid.Nil, ErrVenueClaimExpired
+	}
+	return claimID, nil
+}
+
+// AuthenticateVenueClaim verifies a token for venueID and loads its claim, rejecting
+// claims that have been revoked or no longer exist
+func AuthenticateVenueClaim(db *gorm.DB, key string, venueID uuid.UUID, token string, now time.Time) (*models.VenueClaim, error) {
+	claimID, err := VerifyVenueClaimToken(key, venueID, token, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var claim models.VenueClaim
+	if err := db.First(&claim, "id = ? AND venue_id = ?", claimID, venueID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidVenueClaim
+		}
+		return nil, err
+	}
+	if claim.RevokedAt != nil {
+		return nil, ErrVenueClaimRevoked
+	}
+	return &claim, nil
+}
+
+// IssuedVenueClaim is a new claim with its token. The token is only available at creation;
+// it is sent to the venue manager out-of-band and never stored.
+type IssuedVenueClaim struct {
+	models.VenueClaim
+	Token string `json:"token"`
+}
+
+// CreateVenueClaim associates email with a venue and issues a token valid for ttl
+func CreateVenueClaim(db *gorm.DB, key string, venueID uuid.UUID, email string, ttl time.Duration, actor Actor) (*IssuedVenueClaim, error) {
+	if key == "" {
+		return nil, ErrVenueClaimsDisabled
+	}
+
+	claim := models.VenueClaim{
+		VenueID:   venueID,
+		Email:     strings.TrimSpace(email),
+		ExpiresAt: time.Now().UTC().Add(ttl).Truncate(time.Second),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := requireVenue(tx, venueID); err != nil {
+			return err
+		}
+		if err := tx.Create(&claim).Error; err != nil {
+			return fmt.Errorf("failed to create venue claim: %w", err)
+		}
+
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityVenue,
+			EntityID:   venueID,
+			Action:     AuditActionVenueClaimCreated,
+			Actor:      actor,
+			Metadata: map[string]interface{}{
+				"claim_id":   claim.ID,
+				"email":      claim.Email,
+				"expires_at": claim.ExpiresAt,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &IssuedVenueClaim{VenueClaim: claim, Token: VenueClaimToken(key, &claim)}, nil
+}
+
+// ListVenueClaims returns a venue's claims, newest first, including revoked and expired ones
+func ListVenueClaims(db *gorm.DB, venueID uuid.UUID) ([]models.VenueClaim, error) {
+	var claims []models.VenueClaim
+	if err := db.Where("venue_id = ?", venueID).Order("created_at DESC").Find(&claims).Error; err != nil {
+		return nil, fmt.Errorf("failed to list venue claims: %w", err)
+	}
+	return claims, nil
+}
+
+// RevokeVenueClaim stops a claim's token from working. Revoking twice is a no-op.
+func RevokeVenueClaim(db *gorm.DB, venueID, claimID uuid.UUID, actor Actor) (*models.VenueClaim, error) {
+	var claim models.VenueClaim
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&claim, "id = ? AND venue_id = ?", claimID, venueID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVenueClaimNotFound
+			}
+			return err
+		}
+		if claim.RevokedAt != nil {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		claim.RevokedAt = &now
+		if err := tx.Model(&claim).Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke venue claim: %w", err)
+		}
+
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityVenue,
+			EntityID:   venueID,
+			Action:     AuditActionVenueClaimRevoked,
+			Actor:      actor,
+			Metadata: map[string]interface{}{
+				"claim_id": claim.ID,
+				"email":    claim.Email,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// DisputeEvent files a venue manager's dispute against an event attributed to their venue.
+// The event is pulled from public listings back to pending until an admin accepts or
+// rejects the dispute, and admins are notified through the outbox. At most limit disputes
+// per venue are accepted in any 24 hours (0 disables the limit).
+func DisputeEvent(db *gorm.DB, claim *models.VenueClaim, eventID uuid.UUID, reason, reporterIP string, limit int, actor Actor) (*models.Flag, error) {
+	flag := models.Flag{
+		EventID:      eventID,
+		FlagType:     FlagTypeVenueDispute,
+		Reason:       &reason,
+		VenueClaimID: &claim.ID,
+		Status:       FlagStatusPending,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if reporterIP != "" {
+		flag.ReporterIP = &reporterIP
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Locking the venue serializes disputes from all of its claims, so the count holds
+		var venue models.Venue
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "name").
+			First(&venue, "id = ?", claim.VenueID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVenueNotFound
+			}
+			return err
+		}
+
+		if limit > 0 {
+			var recent int64
+			if err := tx.Model(&models.Flag{}).
+				Joins("JOIN venue_claims ON venue_claims.id = flags.venue_claim_id").
+				Where("venue_claims.venue_id = ? AND flags.created_at > ?", venue.ID, flag.CreatedAt.Add(-24*time.Hour)).
+				Count(&recent).Error; err != nil {
+				return fmt.Errorf("failed to count recent disputes: %w", err)
+			}
+			if recent >= int64(limit) {
+				return ErrDisputeLimitExceeded
+			}
+		}
+
+		var event models.Event
+		if err := tx.Select("id", "venue_id").First(&event, "id = ?", eventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventNotFound
+			}
+			return err
+		}
+		if event.VenueID == nil || *event.VenueID != venue.ID {
+			return ErrEventNotAtVenue
+		}
+
+		if err := tx.Omit(clause.Associations).Create(&flag).Error; err != nil {
+			return fmt.Errorf("failed to record dispute: %w", err)
+		}
+		if err := TransitionEventState(tx, eventID, EventTransition{
+			To:     EventStatePending,
+			Actor:  actor.Type,
+			Reason: TransitionReasonVenueDispute,
+			FlagID: &flag.ID,
+		}); err != nil {
+			return err
+		}
+		if err := RecordEventHistoryByID(tx, eventID); err != nil {
+			return err
+		}
+
+		if err := RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityEvent,
+			EntityID:   eventID,
+			Action:     AuditActionEventDisputed,
+			Actor:      actor,
+			Changes: map[string]interface{}{
+				"moderation_state": map[string]string{"from": EventStateApproved, "to": EventStatePending},
+			},
+			Metadata: map[string]interface{}{
+				"reason":   reason,
+				"flag_id":  flag.ID,
+				"venue_id": venue.ID,
+				"claim_id": claim.ID,
+			},
+		}); err != nil {
+			return err
+		}
+
+		if err := EnqueueOutbox(tx, OutboxTopicEventDisputed, eventID, EventDisputePayload{
+			FlagID:    flag.ID,
+			EventID:   eventID,
+			VenueID:   venue.ID,
+			VenueName: venue.Name,
+			Reason:    reason,
+		}); err != nil {
+			return err
+		}
+		return RecordEventChange(tx, eventID, ChangeTypeDeleted, ChangeReasonDisputed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// Dispute is a venue dispute with the event and venue it concerns, for the dashboard
+type Dispute struct {
+	ID         uuid.UUID `json:"id"`
+	EventID    uuid.UUID `json:"event_id"`
+	EventTitle string    `json:"event_title"`
+	EventState string    `json:"event_state"`
+	VenueID    uuid.UUID `json:"venue_id"`
+	VenueName  string    `json:"venue_name"`
+	ClaimEmail string    `json:"claim_email"`
+	Reason     string    `json:"reason"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ListDisputes returns venue disputes, oldest first, optionally filtered by flag status
+func ListDisputes(db *gorm.DB, status string) ([]Dispute, error) {
+	query := db.Table("flags").
+		Select(`flags.id, flags.event_id, events.title AS event_title, events.moderation_state AS event_state,
+			venue_claims.venue_id, venues.name AS venue_name, venue_claims.email AS claim_email,
+			COALESCE(flags.reason, '') AS reason, flags.status, flags.created_at`).
+		Joins("JOIN events ON events.id = flags.event_id").
+		Joins("JOIN venue_claims ON venue_claims.id = flags.venue_claim_id").
+		Joins("JOIN venues ON venues.id = venue_claims.venue_id").
+		Where("flags.flag_type = ?", FlagTypeVenueDispute)
+	if status != "" {
+		query = query.Where("flags.status = ?", status)
+	}
+
+	var disputes []Dispute
+	if err := query.Order("flags.created_at ASC").Scan(&disputes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list disputes: %w", err)
+	}
+	return disputes, nil
+}
+
+// ResolveDispute decides a pending dispute. Accepting it unpublishes the event (blocking its
+// candidates); rejecting it restores the event to the public listings.
+func ResolveDispute(db *gorm.DB, flagID uuid.UUID, accept bool, actor Actor) (*models.Flag, error) {
+	var flag models.Flag
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&flag, "id = ? AND flag_type = ?", flagID, FlagTypeVenueDispute).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDisputeNotFound
+			}
+			return err
+		}
+		if flag.Status != FlagStatusPending {
+			return ErrDisputeResolved
+		}
+
+		if accept {
+			if _, err := unpublishEvent(tx, flag.EventID, DisputeAcceptReason, actor, &flag.ID); err != nil {
+				return err
+			}
+			flag.Status = FlagStatusResolved
+		} else {
+			if err := restoreDisputedEvent(tx, &flag, actor); err != nil {
+				return err
+			}
+			flag.Status = FlagStatusDismissed
+		}
+
+		return tx.Model(&flag).Update("status", flag.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// restoreDisputedEvent returns a disputed event to the public listings
+func restoreDisputedEvent(tx *gorm.DB, flag *models.Flag, actor Actor) error {
+	if err := TransitionEventState(tx, flag.EventID, EventTransition{
+		To:     EventStateApproved,
+		Actor:  actor.Type,
+		Reason: TransitionReasonDisputeRejected,
+		FlagID: &flag.ID,
+	}); err != nil {
+		return err
+	}
+	if err := RecordEventHistoryByID(tx, flag.EventID); err != nil {
+		return err
+	}
+
+	if err := RecordAudit(tx, AuditEntry{
+		EntityType: AuditEntityEvent,
+		EntityID:   flag.EventID,
+		Action:     AuditActionEventDisputeRejected,
+		Actor:      actor,
+		Changes: map[string]interface{}{
+			"moderation_state": map[string]string{"from": EventStatePending, "to": EventStateApproved},
+		},
+		Metadata: map[string]interface{}{
+			"flag_id": flag.ID,
+		},
+	}); err != nil {
+		return err
+	}
+	return RecordEventChange(tx, flag.EventID, ChangeTypeUpdated, ChangeReasonRepublished)
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+func TestVerifyVenueClaimToken(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	venueID := uuid.New()
+	claim := &models.VenueClaim{ID: uuid.New(), VenueID: venueID, ExpiresAt: now.Add(30 * 24 * time.Hour)}
+	token := VenueClaimToken("claim-key", claim)
+	parts := strings.Split(token, ".")
+
+	otherClaim := &models.VenueClaim{ID: uuid.New(), VenueID: venueID, ExpiresAt: claim.ExpiresAt}
+	otherSig := strings.Split(VenueClaimToken("claim-key", otherClaim), ".")[2]
+
+	tests := []struct {
+		name    string
+		key     string
+		venueID uuid.UUID
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", "claim-key", venueID, token, now, nil},
+		{"valid on its last second", "claim-key", venueID, token, claim.ExpiresAt, nil},
+		{"expired", "claim-key", venueID, token, claim.ExpiresAt.Add(time.Second), ErrVenueClaimExpired},
+		{"another venue", "claim-key", uuid.New(), token, now, ErrInvalidVenueClaim},
+		{"extended expiry", "claim-key", venueID, parts[0] + ".9999999999." + parts[2], now, ErrInvalidVenueClaim},
+		{"signature from another claim", "claim-key", venueID, parts[0] + "." + parts[1] + "." + otherSig, now, ErrInvalidVenueClaim},
+		{"signed with another key", "other-key", venueID, token, now, ErrInvalidVenueClaim},
+		{"claims disabled", "", venueID, token, now, ErrInvalidVenueClaim},
+		{"missing part", "claim-key", venueID, parts[0] + "." + parts[1], now, ErrInvalidVenueClaim},
+		{"not a claim id", "claim-key", venueID, "venue." + parts[1] + "." + parts[2], now, ErrInvalidVenueClaim},
+		{"expiry not a number", "claim-key", venueID, parts[0] + ".soon." + parts[2], now, ErrInvalidVenueClaim},
+		{"empty", "claim-key", venueID, "", now, ErrInvalidVenueClaim},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claimID, err := VerifyVenueClaimToken(tt.key, tt.venueID, tt.token, tt.now)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("VerifyVenueClaimToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && claimID != claim.ID {
+				t.Errorf("VerifyVenueClaimToken() = %s, want %s", claimID, claim.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateVenueClaim(t *testing.T) {
+	now := time.Now().UTC()
+	venueID := uuid.New()
+	claim := &models.VenueClaim{ID: uuid.New(), VenueID: venueID, ExpiresAt: now.Add(time.Hour).Truncate(time.Second)}
+	token := VenueClaimToken("claim-key", claim)
+
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		wantErr error
+	}{
+		{"active claim", sqlmock.NewRows([]string{"id", "venue_id", "email"}).AddRow(claim.ID.String(), venueID.String(), "booker@venue.example"), nil},
+		{"revoked claim", sqlmock.NewRows([]string{"id", "venue_id", "revoked_at"}).AddRow(claim.ID.String(), venueID.String(), now), ErrVenueClaimRevoked},
+		{"deleted claim", sqlmock.NewRows([]string{"id"}), ErrInvalidVenueClaim},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			mock.ExpectQuery(`SELECT * FROM "venue_claims" WHERE id = $1 AND venue_id = $2`).
+				WithArgs(claim.ID, venueID, testdb.Any).
+				WillReturnRows(tt.rows)
+
+			got, err := AuthenticateVenueClaim(db, "claim-key", venueID, token, now)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("AuthenticateVenueClaim() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got.ID != claim.ID {
+				t.Errorf("AuthenticateVenueClaim() = %+v", got)
+			}
+		})
+	}
+
+	// A bad token is refused before the database is asked
+	db, _ := testdb.New(t)
+	if _, err := AuthenticateVenueClaim(db, "claim-key", uuid.New(), token, now); !errors.Is(err, ErrInvalidVenueClaim) {
+		t.Errorf("token for another venue: error = %v, want ErrInvalidVenueClaim", err)
+	}
+}
+
+func TestRevokeVenueClaim(t *testing.T) {
+	admin := Actor{Type: ActorAdmin, AdminID: "ops"}
+
+	tests := []struct {
+		name    string
+		revoked bool
+		found   bool
+		wantErr error
+	}{
+		{"active claim is revoked", false, true, nil},
+		{"revoking twice is a no-op", true, true, nil},
+		{"unknown claim", false, false, ErrVenueClaimNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			venueID, claimID := uuid.New(), uuid.New()
+			revokedAt := time.Now().Add(-time.Hour).UTC()
+
+			rows := sqlmock.NewRows([]string{"id", "venue_id", "revoked_at"})
+			if tt.found {
+				var revoked interface{}
+				if tt.revoked {
+					revoked = revokedAt
+				}
+				rows.AddRow(claimID.String(), venueID.String(), revoked)
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT * FROM "venue_claims" WHERE id = $1 AND venue_id = $2`).
+				WithArgs(claimID, venueID, testdb.Any).
+				WillReturnRows(rows)
+			switch {
+			case tt.wantErr != nil:
+				mock.ExpectRollback()
+			case tt.revoked:
+				mock.ExpectCommit()
+			default:
+				mock.ExpectExec(`UPDATE "venue_claims" SET "revoked_at"=$1 WHERE "id" = $2`).
+					WithArgs(testdb.Any, claimID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAudit(mock, AuditActionVenueClaimRevoked)
+				mock.ExpectCommit()
+			}
+
+			claim, err := RevokeVenueClaim(db, venueID, claimID, admin)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("RevokeVenueClaim() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if claim.RevokedAt == nil || (tt.revoked && !claim.RevokedAt.Equal(revokedAt)) {
+				t.Errorf("RevokedAt = %v, want it set (and unchanged when already revoked)", claim.RevokedAt)
+			}
+		})
+	}
+}
+
+func TestDisputeEvent(t *testing.T) {
+	venueID, eventID := uuid.New(), uuid.New()
+	claim := &models.VenueClaim{ID: uuid.New(), VenueID: venueID}
+	venueManager := Actor{Type: ActorAPI, Route: "/v1/venues/:id/events/:event_id/dispute"}
+
+	tests := []struct {
+		name       string
+		limit      int
+		recent     int64
+		eventVenue *uuid.UUID // nil for an event with no venue
+		eventFound bool
+		wantErr    error
+	}{
+		{"event pulled back to pending", 5, 2, &venueID, true, nil},
+		{"no limit", 0, 0, &venueID, true, nil},
+		{"daily limit reached", 5, 5, &venueID, true, ErrDisputeLimitExceeded},
+		{"event at another venue", 5, 0, uuidPtr(uuid.New()), true, ErrEventNotAtVenue},
+		{"event with no venue", 5, 0, nil, true, ErrEventNotAtVenue},
+		{"unknown event", 5, 0, nil, false, ErrEventNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			creates := testdb.RecordCreates(t, db)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT "id","name" FROM "venues" WHERE id = $1`).
+				WithArgs(venueID, testdb.Any).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(venueID.String(), "Doug Fir"))
+			if tt.limit > 0 {
+				mock.ExpectQuery(`SELECT count(*) FROM "flags" JOIN venue_claims ON venue_claims.id = flags.venue_claim_id WHERE venue_claims.venue_id = $1 AND flags.created_at > $2`).
+					WithArgs(venueID, testdb.Any).
+					WillReturnRows(testdb.Count(tt.recent))
+			}
+			if tt.recent < int64(tt.limit) || tt.limit == 0 {
+				rows := sqlmock.NewRows([]string{"id", "venue_id"})
+				if tt.eventFound {
+					var venue interface{}
+					if tt.eventVenue != nil {
+						venue = tt.eventVenue.String()
+					}
+					rows.AddRow(eventID.String(), venue)
+				}
+				mock.ExpectQuery(`SELECT "id","venue_id" FROM "events" WHERE id = $1`).WillReturnRows(rows)
+			}
+			if tt.wantErr == nil {
+				mock.ExpectQuery(`INSERT INTO "flags"`).WillReturnRows(testdb.IDs(uuid.New()))
+				expectTransition(mock, eventID, EventStateApproved)
+				expectEventHistory(mock, eventID)
+				expectAudit(mock, AuditActionEventDisputed)
+				expectEventChange(mock, eventID, ChangeTypeDeleted, ChangeReasonDisputed)
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			flag, err := DisputeEvent(db, claim, eventID, "We don't host this", "203.0.113.9", tt.limit, venueManager)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("DisputeEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if flag.FlagType != FlagTypeVenueDispute || flag.Status != FlagStatusPending || *flag.VenueClaimID != claim.ID || *flag.ReporterIP != "203.0.113.9" {
+				t.Errorf("flag = %+v, want a pending venue dispute from the claim", flag)
+			}
+			rows := creates.Table("event_state_transitions")
+			if len(rows) != 1 {
+				t.Fatalf("wrote %d transitions, want 1", len(rows))
+			}
+			if row := rows[0].(*models.EventStateTransition); row.ToState != EventStatePending || row.ReasonCode != TransitionReasonVenueDispute || row.FlagID == nil {
+				t.Errorf("transition = %+v, want to pending for a venue dispute, linked to the flag", row)
+			}
+		})
+	}
+}
+
+func TestResolveDispute(t *testing.T) {
+	admin := Actor{Type: ActorAdmin, AdminID: "ops"}
+
+	tests := []struct {
+		name       string
+		status     string // "" when no dispute exists
+		accept     bool
+		wantStatus string
+		wantState  string
+		wantErr    error
+	}{
+		{"accepting unpublishes the event", FlagStatusPending, true, FlagStatusResolved, EventStateBlocked, nil},
+		{"rejecting restores the event", FlagStatusPending, false, FlagStatusDismissed, EventStateApproved, nil},
+		{"already accepted", FlagStatusResolved, false, "", "", ErrDisputeResolved},
+		{"already rejected", FlagStatusDismissed, true, "", "", ErrDisputeResolved},
+		{"no such dispute", "", true, "", "", ErrDisputeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			creates := testdb.RecordCreates(t, db)
+			flagID, eventID := uuid.New(), uuid.New()
+
+			rows := sqlmock.NewRows([]string{"id", "event_id", "flag_type", "status"})
+			if tt.status != "" {
+				rows.AddRow(flagID.String(), eventID.String(), FlagTypeVenueDispute, tt.status)
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT * FROM "flags" WHERE id = $1 AND flag_type = $2`).
+				WithArgs(flagID, FlagTypeVenueDispute, testdb.Any).
+				WillReturnRows(rows)
+			switch {
+			case tt.wantErr != nil:
+				mock.ExpectRollback()
+			case tt.accept:
+				expectTransition(mock, eventID, EventStatePending)
+				expectEventHistory(mock, eventID)
+				mock.ExpectQuery(`SELECT "id" FROM "event_candidates" WHERE published_event_id = $1`).WillReturnRows(testdb.IDs())
+				expectAudit(mock, AuditActionEventUnpublished)
+				expectEventChange(mock, eventID, ChangeTypeDeleted, ChangeReasonUnpublished)
+			default:
+				expectTransition(mock, eventID, EventStatePending)
+				expectEventHistory(mock, eventID)
+				expectAudit(mock, AuditActionEventDisputeRejected)
+				expectEventChange(mock, eventID, ChangeTypeUpdated, ChangeReasonRepublished)
+			}
+			if tt.wantErr == nil {
+				mock.ExpectExec(`UPDATE "flags" SET "status"=$1 WHERE "id" = $2`).
+					WithArgs(tt.wantStatus, flagID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			flag, err := ResolveDispute(db, flagID, tt.accept, admin)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ResolveDispute() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if flag.Status != tt.wantStatus {
+				t.Errorf("flag status = %q, want %q", flag.Status, tt.wantStatus)
+			}
+			transitions := creates.Table("event_state_transitions")
+			if len(transitions) != 1 {
+				t.Fatalf("wrote %d transitions, want 1", len(transitions))
+			}
+			if row := transitions[0].(*models.EventStateTransition); row.ToState != tt.wantState || row.FlagID == nil || *row.FlagID != flagID {
+				t.Errorf("transition = %+v, want to %s linked to the dispute", row, tt.wantState)
+			}
+		})
+	}
+}
+
+func uuidPtr(id uuid.UUID) *uuid.UUID {
+	return &id
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrVenueNotFound is returned when a venue (or its reassignment target) does not exist
+	ErrVenueNotFound = errors.New("venue not found")
+	// ErrVenueInUse is returned when deleting a venue that events still reference
+	ErrVenueInUse = errors.New("venue is referenced by events")
+	// ErrVenueSelfReference is returned when a venue is merged or reassigned into itself
+	ErrVenueSelfReference = errors.New("venue cannot be merged into itself")
+)
+
+// VenueChangeResult reports which events were re-pointed by a venue delete or merge
+type VenueChangeResult struct {
+	VenueID         uuid.UUID   `json:"venue_id"`
+	TargetVenueID   *uuid.UUID  `json:"target_venue_id,omitempty"`
+	RepointedEvents []uuid.UUID `json:"repointed_events"`
+}
+
+// DeleteVenue removes a venue. When events reference it, reassignTo must name another
+// venue; the events are re-pointed and emitted on the change feed in the same transaction.
+func DeleteVenue(db *gorm.DB, venueID uuid.UUID, reassignTo *uuid.UUID, actor Actor) (*VenueChangeResult, error) {
+	result := &VenueChangeResult{VenueID: venueID, TargetVenueID: reassignTo}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := requireVenue(tx, venueID); err != nil {
+			return err
+		}
+
+		eventIDs, err := venueEventIDs(tx, venueID)
+		if err != nil {
+			return err
+		}
+
+		if len(eventIDs) > 0 {
+			if reassignTo == nil {
+				return ErrVenueInUse
+			}
+			if err := repointVenueEvents(tx, venueID, *reassignTo, eventIDs, ChangeReasonVenueDeleted); err != nil {
+				return err
+			}
+			result.RepointedEvents = eventIDs
+		}
+
+		if err := tx.Delete(&models.Venue{}, "id = ?", venueID).Error; err != nil {
+			return fmt.Errorf("failed to delete venue: %w", err)
+		}
+
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityVenue,
+			EntityID:   venueID,
+			Action:     AuditActionVenueDeleted,
+			Actor:      actor,
+			Changes:    result,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MergeVenues folds source into target: events are re-pointed, emitted on the change
+// feed, and the source venue is deleted, all in one transaction.
+func MergeVenues(db *gorm.DB, sourceID, targetID uuid.UUID, actor Actor) (*VenueChangeResult, error) {
+	result := &VenueChangeResult{VenueID: sourceID, TargetVenueID: &targetID}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := requireVenue(tx, sourceID); err != nil {
+			return err
+		}
+
+		eventIDs, err := venueEventIDs(tx, sourceID)
+		if err != nil {
+			return err
+		}
+		if err := repointVenueEvents(tx, sourceID, targetID, eventIDs, ChangeReasonVenueMerged); err != nil {
+			return err
+		}
+		result.RepointedEvents = eventIDs
+
+		if err := tx.Delete(&models.Venue{}, "id = ?", sourceID).Error; err != nil {
+			return fmt.Errorf("failed to delete merged venue: %w", err)
+		}
+
+		return RecordAudit(tx, AuditEntry{
+			EntityType: AuditEntityVenue,
+			EntityID:   sourceID,
+			Action:     AuditActionVenueMerged,
+			Actor:      actor,
+			Changes:    result,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReconcileOrphanedVenues clears venue_id on events whose venue no longer exists and
+// emits them on the change feed. It is a safety net; DeleteVenue/MergeVenues should
+// never leave orphans behind.
+func ReconcileOrphanedVenues(db *gorm.DB) ([]uuid.UUID, error) {
+	var eventIDs []uuid.UUID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Event{}).
+			Where("venue_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM venues WHERE venues.id = events.venue_id)").
+			Pluck("id", &eventIDs).Error; err != nil {
+			return fmt.Errorf("failed to find orphaned events: %w", err)
+		}
+		if len(eventIDs) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.Event{}).
+			Where("id IN ?", eventIDs).
+			Updates(map[string]interface{}{
+				"venue_id":   nil,
+				"updated_at": gorm.Expr("NOW()"),
+			}).Error; err != nil {
+			return fmt.Errorf("failed to clear orphaned venue references: %w", err)
+		}
+		if err := RecordEventHistoryByID(tx, eventIDs...); err != nil {
+			return err
+		}
+
+		return RecordEventChanges(tx, eventIDs, ChangeTypeUpdated, ChangeReasonVenueOrphaned)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eventIDs, nil
+}
+
+// LinkAutoPublishedVenues links auto-published events that have no venue to the venue their
+// candidate names, matched by name as publishing does, and emits them on the change feed.
+// Auto-publish once created events without a venue, which hid them from every spatial
+// listing; migrations/039 runs the same repair.
+func LinkAutoPublishedVenues(db *gorm.DB) ([]uuid.UUID, error) {
+	var eventIDs []uuid.UUID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var links []struct {
+			EventID uuid.UUID
+			VenueID uuid.UUID
+		}
+		if err := tx.Raw(`
+			SELECT DISTINCT ON (ec.published_event_id) ec.published_event_id AS event_id, v.id AS venue_id
+			FROM event_candidates ec
+			JOIN events e ON e.id = ec.published_event_id
+			JOIN venues v ON lower(v.name) = lower(ec.fields->>'venue')
+			WHERE e.venue_id IS NULL AND e.published_via = 'auto'
+			ORDER BY ec.published_event_id, (v.location IS NULL), v.created_at`).
+			Scan(&links).Error; err != nil {
+			return fmt.Errorf("failed to find unlinked events: %w", err)
+		}
+
+		for _, link := range links {
+			if err := tx.Model(&models.Event{}).
+				Where("id = ? AND venue_id IS NULL", link.EventID).
+				Updates(map[string]interface{}{
+					"venue_id":   link.VenueID,
+					"updated_at": gorm.Expr("NOW()"),
+				}).Error; err != nil {
+				return fmt.Errorf("failed to link event venue: %w", err)
+			}
+			eventIDs = append(eventIDs, link.EventID)
+		}
+		if len(eventIDs) == 0 {
+			return nil
+		}
+		if err := RecordEventHistoryByID(tx, eventIDs...); err != nil {
+			return err
+		}
+
+		return RecordEventChanges(tx, eventIDs, ChangeTypeUpdated, ChangeReasonVenueLinked)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eventIDs, nil
+}
+
+func requireVenue(tx *gorm.DB, venueID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.Venue{}).Where("id = ?", venueID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to look up venue: %w", err)
+	}
+	if count == 0 {
+		return ErrVenueNotFound
+	}
+	return nil
+}
+
+func venueEventIDs(tx *gorm.DB, venueID uuid.UUID) ([]uuid.UUID, error) {
+	var eventIDs []uuid.UUID
+	if err := tx.Model(&models.Event{}).Where("venue_id = ?", venueID).Pluck("id", &eventIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list venue events: %w", err)
+	}
+	return eventIDs, nil
+}
+
+// repointVenueEvents moves events from one venue to another and emits a change per event
+func repointVenueEvents(tx *gorm.DB, fromID, toID uuid.UUID, eventIDs []uuid.UUID, reason string) error {
+	if fromID == toID {
+		return ErrVenueSelfReference
+	}
+	if err := requireVenue(tx, toID); err != nil {
+		return err
+	}
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&models.Event{}).
+		Where("id IN ?", eventIDs).
+		Updates(map[string]interface{}{
+			"venue_id":   toID,
+			"updated_at": gorm.Expr("NOW()"),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to re-point events: %w", err)
+	}
+	if err := RecordEventHistoryByID(tx, eventIDs...); err != nil {
+		return err
+	}
+
+	return RecordEventChanges(tx, eventIDs, ChangeTypeUpdated, reason)
+}
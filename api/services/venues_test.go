@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+func TestDeleteVenue(t *testing.T) {
+	venueID, targetID := uuid.New(), uuid.New()
+	eventA, eventB := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name         string
+		reassignTo   *uuid.UUID
+		expect       func(mock sqlmock.Sqlmock)
+		wantErr      error
+		wantRepoints []uuid.UUID
+	}{
+		{
+			name: "unused venue is deleted",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WithArgs(venueID).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT "id" FROM "events" WHERE venue_id = $1`).WithArgs(venueID).WillReturnRows(testdb.IDs())
+				mock.ExpectExec(`DELETE FROM "venues"`).WithArgs(venueID).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAudit(mock, AuditActionVenueDeleted)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "venue with events is blocked without a reassignment",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT "id" FROM "events"`).WillReturnRows(testdb.IDs(eventA))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrVenueInUse,
+		},
+		{
+			name:       "events are re-pointed to the reassignment target",
+			reassignTo: &targetID,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WithArgs(venueID).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT "id" FROM "events"`).WillReturnRows(testdb.IDs(eventA, eventB))
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WithArgs(targetID).WillReturnRows(testdb.Count(1))
+				mock.ExpectExec(`UPDATE "events" SET "updated_at"=NOW(),"venue_id"=$1 WHERE id IN ($2,$3)`).
+					WithArgs(targetID, eventA, eventB).WillReturnResult(sqlmock.NewResult(0, 2))
+				expectEventHistory(mock, eventA, eventB)
+				expectEventChange(mock, eventA, ChangeTypeUpdated, ChangeReasonVenueDeleted)
+				expectEventChange(mock, eventB, ChangeTypeUpdated, ChangeReasonVenueDeleted)
+				mock.ExpectExec(`DELETE FROM "venues"`).WithArgs(venueID).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAudit(mock, AuditActionVenueDeleted)
+				mock.ExpectCommit()
+			},
+			wantRepoints: []uuid.UUID{eventA, eventB},
+		},
+		{
+			name:       "missing reassignment target",
+			reassignTo: &targetID,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WithArgs(venueID).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT "id" FROM "events"`).WillReturnRows(testdb.IDs(eventA))
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WithArgs(targetID).WillReturnRows(testdb.Count(0))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrVenueNotFound,
+		},
+		{
+			name:       "reassigning into itself",
+			reassignTo: &venueID,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT "id" FROM "events"`).WillReturnRows(testdb.IDs(eventA))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrVenueSelfReference,
+		},
+		{
+			name: "missing venue",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WillReturnRows(testdb.Count(0))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrVenueNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			tt.expect(mock)
+
+			result, err := DeleteVenue(db, venueID, tt.reassignTo, Actor{Type: "admin", AdminID: "ops"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteVenue() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(result.RepointedEvents, tt.wantRepoints) {
+				t.Errorf("RepointedEvents = %v, want %v", result.RepointedEvents, tt.wantRepoints)
+			}
+		})
+	}
+}
+
+func TestMergeVenues(t *testing.T) {
+	sourceID, targetID := uuid.New(), uuid.New()
+	event := uuid.New()
+
+	tests := []struct {
+		name     string
+		targetID uuid.UUID
+		expect   func(mock sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name:     "events move to the target and the source is deleted",
+			targetID: targetID,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WithArgs(sourceID).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT "id" FROM "events"`).WithArgs(sourceID).WillReturnRows(testdb.IDs(event))
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WithArgs(targetID).WillReturnRows(testdb.Count(1))
+				mock.ExpectExec(`UPDATE "events"`).WithArgs(targetID, event).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectEventHistory(mock, event)
+				expectEventChange(mock, event, ChangeTypeUpdated, ChangeReasonVenueMerged)
+				mock.ExpectExec(`DELETE FROM "venues"`).WithArgs(sourceID).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAudit(mock, AuditActionVenueMerged)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:     "merging into itself",
+			targetID: sourceID,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT count(*) FROM "venues"`).WillReturnRows(testdb.Count(1))
+				mock.ExpectQuery(`SELECT "id" FROM "events"`).WillReturnRows(testdb.IDs(event))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrVenueSelfReference,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			tt.expect(mock)
+
+			if _, err := MergeVenues(db, sourceID, tt.targetID, Actor{Type: "admin", AdminID: "ops"}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MergeVenues() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,27 +1,54 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/jpeg"
 	"io"
+	"log"
+	"math"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/sashabaranov/go-openai"
 	config_pkg "github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/exif"
 	"github.com/lincolngreen/williamboard/api/models"
 	"gorm.io/gorm"
 )
 
+// VisionService detects flyers and extracts events through a VisionProvider. Image
+// preparation, prompts, and reading the JSON reply are shared; only the API call differs.
 type VisionService struct {
-	client *openai.Client
-	config *config_pkg.Config
+	providers map[string]VisionProvider
+	config    *config_pkg.Config
+	storage   *StorageService
+	slots     chan struct{} // bounds calls in flight; nil when unlimited
 }
 
-// FlyerDetectionResult represents the structured output from GPT-4o
+// ErrVisionBusy is returned when no vision slot frees up within VisionSlotWaitSec; the
+// caller should queue the work again rather than fail it
+var ErrVisionBusy = errors.New("vision calls are at capacity")
+
+// ImageQualityPoor is the image_quality the vision model gives a photo too dark, blurred,
+// or distant to read
+const ImageQualityPoor = "poor"
+
+// SubmissionStatusRejectedQuality ends a submission whose photos were too poor to find any
+// flyers in; the same submission can be uploaded again with a better photo
+const SubmissionStatusRejectedQuality = "rejected_quality"
+
+// RejectedQualityMessage tells the submitter why a rejected_quality photo was not read
+const RejectedQualityMessage = "We couldn't find any flyers in this photo. Please retake it in better light, holding the camera steady and close enough that the text is in focus."
+
+// FlyerDetectionResult represents the structured output of a flyer detection call
 type FlyerDetectionResult struct {
 	FlyersDetected []FlyerRegion `json:"flyers_detected"`
 	TotalRegions   int           `json:"total_regions"`
@@ -29,6 +56,17 @@ type FlyerDetectionResult struct {
 	ProcessingNotes string       `json:"processing_notes"`
 }
 
+// VisionUsage is the model and token counts of one vision call. Tokens are billed even
+// when the reply can't be used, so calls report usage alongside errors.
+type VisionUsage struct {
+	Model             string
+	ServedModel       string // the exact model the response reports, e.g. gpt-4o-2024-08-06
+	SystemFingerprint string
+	PromptVersion     string // PromptVersion of the prompt sent
+	PromptTokens      int
+	CompletionTokens  int
+}
+
 // FlyerRegion represents a detected flyer region
 type FlyerRegion struct {
 	RegionID    string             `json:"region_id"`
@@ -65,9 +103,61 @@ type EventFields struct {
 	Description  *string   `json:"description,omitempty"`
 	Organizer    *string   `json:"organizer,omitempty"`
 	URL          *string   `json:"url,omitempty"`
+	TicketURL    *string   `json:"ticket_url,omitempty"`
 	ContactInfo  *string   `json:"contact_info,omitempty"`
 	Category     *string   `json:"category,omitempty"`
 	AgeRestriction *string `json:"age_restriction,omitempty"`
+	QRCodeURLs   []string  `json:"qr_urls,omitempty"`
+	DetailsOnlineOnly bool `json:"details_online_only,omitempty"`
+
+	// Extra holds fields declared by the deployment's field schema beyond the built-ins
+	Extra map[string]interface{} `json:"-"`
+}
+
+// eventFieldsJSON has EventFields' layout without its JSON methods
+type eventFieldsJSON EventFields
+
+// MarshalJSON writes the built-in fields followed by any extra fields
+func (f EventFields) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(eventFieldsJSON(f))
+	if err != nil || len(f.Extra) == 0 {
+		return data, err
+	}
+
+	var merged map[string]interface{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for name, value := range f.Extra {
+		if _, ok := merged[name]; !ok {
+			merged[name] = value
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// UnmarshalJSON reads the built-in fields and keeps every other key in Extra
+func (f *EventFields) UnmarshalJSON(data []byte) error {
+	var builtin eventFieldsJSON
+	if err := json.Unmarshal(data, &builtin); err != nil {
+		return err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for name := range all {
+		if IsBuiltinField(name) {
+			delete(all, name)
+		}
+	}
+
+	*f = EventFields(builtin)
+	if len(all) > 0 {
+		f.Extra = all
+	}
+	return nil
 }
 
 // EventConfidences contains confidence scores for each field
@@ -78,103 +168,286 @@ type EventConfidences struct {
 	Overall   float64 `json:"overall"`
 }
 
-func NewVisionService(cfg *config_pkg.Config) *VisionService {
-	client := openai.NewClient(cfg.OpenAIAPIKey)
-	
+// NewVisionService sets up every provider that has an API key; which one a call uses
+// follows from its model (see SplitVisionModel)
+func NewVisionService(cfg *config_pkg.Config, storage *StorageService) *VisionService {
+	providers := make(map[string]VisionProvider)
+	if cfg.OpenAIAPIKey != "" {
+		providers[VisionProviderOpenAI] = NewOpenAIVisionProvider(cfg)
+	}
+	if cfg.AnthropicAPIKey != "" {
+		providers[VisionProviderAnthropic] = NewAnthropicVisionProvider(cfg)
+	}
+
+	var slots chan struct{}
+	if cfg.VisionConcurrency > 0 {
+		slots = make(chan struct{}, cfg.VisionConcurrency)
+	}
 	return &VisionService{
-		client: client,
-		config: cfg,
+		providers: providers,
+		config:    cfg,
+		storage:   storage,
+		slots:     slots,
 	}
 }
 
-// AnalyzeImage processes an image to detect flyers and extract events
-func (v *VisionService) AnalyzeImage(ctx context.Context, submissionID uuid.UUID, imagePath string) (*FlyerDetectionResult, error) {
-	// Read and encode image
-	imageData, err := v.prepareImage(imagePath)
+// acquireSlot waits for one of the VisionConcurrency slots, which covers preparing the
+// image as well as the call, so at most that many encoded images are held at once. It
+// gives up with ErrVisionBusy after VisionSlotWaitSec, or with ctx's error first.
+func (v *VisionService) acquireSlot(ctx context.Context) (func(), error) {
+	if v.slots == nil {
+		return func() {}, nil
+	}
+	timer := time.NewTimer(time.Duration(v.config.VisionSlotWaitSec) * time.Second)
+	defer timer.Stop()
+	select {
+	case v.slots <- struct{}{}:
+		return func() { <-v.slots }, nil
+	case <-timer.C:
+		return nil, ErrVisionBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// provider returns the provider for a requested model and the model name it knows it by
+func (v *VisionService) provider(model string) (VisionProvider, string, error) {
+	name, providerModel := SplitVisionModel(model, v.config.VisionProvider)
+	provider, ok := v.providers[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s (model %s)", ErrVisionProviderUnavailable, name, model)
+	}
+	return provider, providerModel, nil
+}
+
+// ResizedImageFilename is the copy of a submission's image that was actually sent to the
+// vision model, stored next to the original when the original had to be downscaled
+const ResizedImageFilename = "resized.jpg"
+
+// AnalyzeImage processes one of a submission's photos (1-based, see SubmissionImageFilename)
+// with the given model to detect flyers and extract events. For the first photo the model
+// reads the submission's derivative (see RecordSubmissionDerivative) when there is one; other
+// photos, and a first photo without one, are read from the original. When the first photo is
+// downscaled further for the model, the resized copy is saved as ResizedImageFilename. The
+// returned polygons are always in that photo's original frame.
+func (v *VisionService) AnalyzeImage(ctx context.Context, submissionID uuid.UUID, imageIndex int, model string) (*FlyerDetectionResult, VisionUsage, error) {
+	usage := VisionUsage{Model: model}
+	provider, providerModel, err := v.provider(model)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare image: %w", err)
-	}
-
-	// Create the prompt for structured analysis
-	prompt := v.createAnalysisPrompt()
-
-	// Call GPT-4o Vision with structured output
-	req := openai.ChatCompletionRequest{
-		Model: v.config.OpenAIModel,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role: openai.ChatMessageRoleUser,
-				MultiContent: []openai.ChatMessagePart{
-					{
-						Type: openai.ChatMessagePartTypeText,
-						Text: prompt,
-					},
-					{
-						Type: openai.ChatMessagePartTypeImageURL,
-						ImageURL: &openai.ChatMessageImageURL{
-							URL: fmt.Sprintf("data:image/jpeg;base64,%s", imageData),
-						},
-					},
-				},
-			},
-		},
-		MaxTokens:   2000,
-		Temperature: 0.1, // Low temperature for consistent structured output
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-		},
-	}
-
-	// Set timeout context
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(v.config.OpenAITimeoutMS)*time.Millisecond)
-	defer cancel()
-
-	resp, err := v.client.CreateChatCompletion(ctx, req)
+		return nil, usage, err
+	}
+
+	release, err := v.acquireSlot(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("GPT-4o API call failed: %w", err)
+		return nil, usage, err
 	}
+	defer release()
 
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from GPT-4o")
+	imagePath, err := v.storage.Fetch(submissionID, SubmissionImageFilename(imageIndex))
+	if err != nil {
+		return nil, usage, err
+	}
+	sendPath, frameX, frameY := imagePath, 1.0, 1.0
+	if imageIndex <= 1 {
+		if path, scaleX, scaleY, ok := derivativeFrame(v.storage, submissionID, imagePath); ok {
+			sendPath, frameX, frameY = path, scaleX, scaleY
+		}
+	}
+	prepared, err := v.prepareImage(sendPath, provider.ImageLimits())
+	if err != nil {
+		return nil, usage, fmt.Errorf("failed to prepare image: %w", err)
+	}
+	prepared.scaleX *= frameX
+	prepared.scaleY *= frameY
+	if prepared.resized != nil && imageIndex <= 1 {
+		if err := v.storage.SaveFile(submissionID, ResizedImageFilename, bytes.NewReader(prepared.resized)); err != nil {
+			log.Printf("Failed to save resized image for submission %s: %v", submissionID, err)
+		}
 	}
 
-	// Parse structured output
 	var result FlyerDetectionResult
-	content := resp.Choices[0].Message.Content
-	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse structured output: %w, content: %s", err, content)
+	usage, err = v.analyze(ctx, provider, prepared, analysisPrompt(), model, providerModel, &result)
+	if err != nil {
+		return nil, usage, err
 	}
+	if prepared.scaleX != 1 || prepared.scaleY != 1 {
+		for i := range result.FlyersDetected {
+			polygon := result.FlyersDetected[i].Polygon
+			for j := range polygon {
+				polygon[j].X *= prepared.scaleX
+				polygon[j].Y *= prepared.scaleY
+			}
+		}
+	}
+	return &result, usage, nil
+}
 
-	return &result, nil
+// SingleFlyerResult is the structured output for one cropped flyer
+type SingleFlyerResult struct {
+	Events []EventCandidate `json:"events"`
+	Notes  string           `json:"notes"`
 }
 
-// prepareImage reads, processes, and encodes image file for optimal GPT-4o Vision analysis
-func (v *VisionService) prepareImage(imagePath string) (string, error) {
-	file, err := os.Open(imagePath)
+// ExtractFlyerEvents re-reads a single cropped flyer with a prompt focused on that flyer
+func (v *VisionService) ExtractFlyerEvents(ctx context.Context, cropPath, model string) (*SingleFlyerResult, VisionUsage, error) {
+	provider, providerModel, err := v.provider(model)
 	if err != nil {
-		return "", err
+		return nil, VisionUsage{Model: model}, err
+	}
+	release, err := v.acquireSlot(ctx)
+	if err != nil {
+		return nil, VisionUsage{Model: model}, err
+	}
+	defer release()
+	prepared, err := v.prepareImage(cropPath, provider.ImageLimits())
+	if err != nil {
+		return nil, VisionUsage{Model: model}, fmt.Errorf("failed to prepare image: %w", err)
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	var result SingleFlyerResult
+	usage, err := v.analyze(ctx, provider, prepared, v.createSingleFlyerPrompt(), model, providerModel, &result)
 	if err != nil {
-		return "", err
+		return nil, usage, err
+	}
+	return &result, usage, nil
+}
+
+// analyze sends a prepared image and prompt to the provider and decodes its JSON reply into
+// out. Usage is reported under the model as requested, provider prefix included, so
+// experiments and prices tell vendors apart.
+func (v *VisionService) analyze(ctx context.Context, provider VisionProvider, image visionImage, prompt, model, providerModel string, out interface{}) (VisionUsage, error) {
+	content, usage, err := provider.Complete(ctx, VisionRequest{
+		Model:     providerModel,
+		Prompt:    prompt,
+		ImageData: image.encoded,
+		MediaType: image.mediaType,
+	})
+	usage.Model = model
+	usage.PromptVersion = PromptVersion(prompt)
+	if err != nil {
+		return usage, fmt.Errorf("%s API call failed: %w", model, err)
 	}
 
-	// Check file size - GPT-4o has a 20MB limit
-	maxSize := 18 * 1024 * 1024 // 18MB to be safe
-	if len(data) > maxSize {
-		// For now, we'll just truncate to avoid issues
-		// TODO: Implement proper image resizing with image/jpeg or similar
-		return "", fmt.Errorf("image too large: %d bytes (max %d bytes)", len(data), maxSize)
+	// Parse structured output
+	if err := json.Unmarshal([]byte(jsonObject(content)), out); err != nil {
+		return usage, fmt.Errorf("failed to parse structured output: %w, content: %s", err, content)
+	}
+
+	return usage, nil
+}
+
+// visionImage is an image ready to send to the vision model
+type visionImage struct {
+	encoded   string // base64 image data
+	mediaType string // e.g. image/jpeg
+	resized   []byte // the re-encoded JPEG, or nil when the file was sent as-is
+	// scaleX and scaleY map the sent image's coordinates back to the upright original's
+	scaleX, scaleY float64
+}
+
+// prepareImage reads an image and base64-encodes it for a vision provider. Images whose
+// long side exceeds ImageMaxLongSide (or the provider's own limit, if lower), or that would
+// encode past the provider's size limit, are downscaled and re-encoded as JPEG at ImageJPEGQuality first. Uploads are normally rotated
+// upright when stored, but an image that still carries an EXIF orientation (one stored
+// before that, for instance) is rotated here too; the re-encoded JPEG has no EXIF, so
+// nothing downstream rotates it again. Images without an orientation pass through as-is,
+// streamed from disk into the encoding so the raw bytes are never held in memory as well.
+func (v *VisionService) prepareImage(imagePath string, limits VisionImageLimits) (visionImage, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return visionImage{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return visionImage{}, err
 	}
 
 	// Validate it's a supported image format by checking headers
-	if !v.isValidImageFormat(data) {
-		return "", fmt.Errorf("unsupported image format")
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return visionImage{}, fmt.Errorf("unsupported image format")
+	}
+	header = header[:n]
+	if !v.isValidImageFormat(header) {
+		return visionImage{}, fmt.Errorf("unsupported image format")
 	}
+	if IsHEIC(header) {
+		return visionImage{}, fmt.Errorf("unsupported image format: HEIC must be converted to JPEG on upload")
+	}
+
+	orientation := 1
+	if exifData, err := exif.ReadWithoutLocation(io.NewSectionReader(file, 0, info.Size())); err == nil {
+		orientation = exifData.Orientation
+	}
+	rotate := orientation > 1 && orientation <= 8
+
+	maxSide := v.config.ImageMaxLongSide
+	if limits.MaxLongSide > 0 && (maxSide <= 0 || limits.MaxLongSide < maxSide) {
+		maxSide = limits.MaxLongSide
+	}
+	imgConfig, _, configErr := image.DecodeConfig(io.NewSectionReader(file, 0, info.Size()))
+	oversized := configErr == nil && maxSide > 0 && (imgConfig.Width > maxSide || imgConfig.Height > maxSide)
+	if !rotate && !oversized && int64(base64.StdEncoding.EncodedLen(int(info.Size()))) <= int64(limits.MaxEncodedBytes) {
+		encoded, err := encodeBase64(io.NewSectionReader(file, 0, info.Size()), info.Size())
+		if err != nil {
+			return visionImage{}, fmt.Errorf("failed to read image: %w", err)
+		}
+		return visionImage{
+			encoded:   encoded,
+			mediaType: http.DetectContentType(header),
+			scaleX:    1,
+			scaleY:    1,
+		}, nil
+	}
+	if configErr != nil {
+		return visionImage{}, fmt.Errorf("unsupported image format: %w", configErr)
+	}
+
+	img, _, err := image.Decode(io.NewSectionReader(file, 0, info.Size()))
+	if err != nil {
+		return visionImage{}, fmt.Errorf("unsupported image format: %w", err)
+	}
+	if rotate {
+		img = applyOrientation(img, orientation)
+	}
+	upright := img.Bounds()
+	if maxSide > 0 {
+		img = downscale(img, maxSide)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: v.config.ImageJPEGQuality}); err != nil {
+		return visionImage{}, fmt.Errorf("failed to encode resized image: %w", err)
+	}
+	if size := base64.StdEncoding.EncodedLen(buf.Len()); size > limits.MaxEncodedBytes {
+		return visionImage{}, fmt.Errorf("image too large after resizing: %d bytes encoded (max %d bytes)", size, limits.MaxEncodedBytes)
+	}
+
+	sent := img.Bounds()
+	return visionImage{
+		encoded:   base64.StdEncoding.EncodeToString(buf.Bytes()),
+		mediaType: "image/jpeg",
+		resized:   buf.Bytes(),
+		scaleX:    float64(upright.Dx()) / float64(sent.Dx()),
+		scaleY:    float64(upright.Dy()) / float64(sent.Dy()),
+	}, nil
+}
 
-	return base64.StdEncoding.EncodeToString(data), nil
+// encodeBase64 streams size bytes from r into a base64 string sized up front, so encoding
+// costs only the encoded copy
+func encodeBase64(r io.Reader, size int64) (string, error) {
+	var encoded strings.Builder
+	encoded.Grow(base64.StdEncoding.EncodedLen(int(size)))
+	encoder := base64.NewEncoder(base64.StdEncoding, &encoded)
+	if _, err := io.Copy(encoder, r); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return encoded.String(), nil
 }
 
 // isValidImageFormat checks if the data represents a valid image format
@@ -202,6 +475,11 @@ func (v *VisionService) isValidImageFormat(data []byte) bool {
 		return true
 	}
 
+	// Check for HEIC/HEIF (converted to JPEG on upload, but recognized so the error says so)
+	if IsHEIC(data) {
+		return true
+	}
+
 	// Check for GIF
 	if len(data) >= 6 &&
 		((data[0] == 0x47 && data[1] == 0x49 && data[2] == 0x46 && data[3] == 0x38 && data[4] == 0x37 && data[5] == 0x61) ||
@@ -212,9 +490,11 @@ func (v *VisionService) isValidImageFormat(data []byte) bool {
 	return false
 }
 
-// createAnalysisPrompt creates the detailed prompt for flyer analysis
-func (v *VisionService) createAnalysisPrompt() string {
-	return `You are an expert at analyzing bulletin board photos to detect and extract event information from flyers and posters.
+// defaultAnalysisPrompt creates the built-in prompt for flyer analysis from the active field
+// schema; VISION_PROMPT_PATH or VISION_PROMPT replace it (see LoadVisionPrompt)
+func defaultAnalysisPrompt() string {
+	schema := ActiveFieldSchema()
+	prompt := `You are an expert at analyzing bulletin board photos to detect and extract event information from flyers and posters.
 
 Analyze this image and identify all event flyers/posters. For each flyer detected, extract the event details.
 
@@ -236,14 +516,7 @@ Return your analysis in this EXACT JSON format:
         {
           "event_id": "event_1_1",
           "fields": {
-            "title": "Summer Music Festival",
-            "date_time": "2024-07-15T19:00:00",
-            "venue": "Central Park",
-            "address": "123 Main St, City, ST 12345",
-            "price": "$25",
-            "description": "Live music and food trucks",
-            "organizer": "Music Society",
-            "category": "music"
+` + schema.promptExample("            ") + `
           },
           "confidences": {
             "title": 0.98,
@@ -269,16 +542,86 @@ Guidelines:
 - Parse dates into ISO format when possible, otherwise leave as text
 - Extract all visible event details, use null for missing information
 - Be conservative with confidence scores - only high confidence for clearly visible text
-- If no flyers detected, return empty flyers_detected array
+- If no flyers detected, return empty flyers_detected array` + schema.promptHints(false)
 
-Focus on extracting: title, date/time, venue/location, price, description, organizer, contact info, category.`
+	if focus := schema.promptFocus(); focus != "" {
+		prompt += "\n\nFocus on extracting: " + focus + "."
+	}
+	return prompt
 }
 
-// SaveResults stores the analysis results in the database
-func (v *VisionService) SaveResults(db *gorm.DB, submissionID uuid.UUID, result *FlyerDetectionResult) error {
+// createSingleFlyerPrompt asks for the events on one already-cropped flyer
+func (v *VisionService) createSingleFlyerPrompt() string {
+	schema := ActiveFieldSchema()
+	return `You are an expert at reading event flyers and posters.
+
+This image is a single flyer cropped from a bulletin board photo. Extract every event it advertises. Ignore any partial text from neighboring flyers at the edges of the crop.
+
+Return your analysis in this EXACT JSON format:
+
+{
+  "events": [
+    {
+      "event_id": "event_1",
+      "fields": {
+` + schema.promptExample("        ") + `
+      },
+      "confidences": {
+        "title": 0.98,
+        "date_time": 0.85,
+        "location": 0.90,
+        "overall": 0.91
+      },
+      "source_excerpt": "The text from the flyer that contains this event info"
+    }
+  ],
+  "notes": "Observations about legibility or missing details"
+}
+
+Guidelines:
+- Read the flyer closely; small print such as dates, times, and addresses matters most
+- Parse dates into ISO format when possible, otherwise copy the text as printed; do not invent a year
+- Use null for missing information and keep confidence scores conservative (0.0-1.0)` + schema.promptHints(true) + `
+- If the image contains no event, return an empty events array`
+}
+
+// SaveResults stores the analysis of one of a submission's photos in the database, recording
+// on each flyer the photo it was found in, that photo's dimensions, and the model that usage
+// reports served the extraction. Polygons are clamped into the photo; a flyer left with
+// fewer than three distinct points has no usable region and is dropped with its events.
+func (v *VisionService) SaveResults(db *gorm.DB, submissionID uuid.UUID, imageIndex int, result *FlyerDetectionResult, usage VisionUsage) error {
+	// Polygons are kept inside the upright photo's bounds when they are known
+	var submission models.Submission
+	if err := db.Select("id", "image_width", "image_height").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return fmt.Errorf("failed to load submission: %w", err)
+	}
+	width, height := submission.ImageWidth, submission.ImageHeight
+	if imageIndex > 1 || width == nil || height == nil {
+		width, height = nil, nil
+		if config, err := imageFileConfig(v.storage.GetFilePath(submissionID, SubmissionImageFilename(imageIndex))); err == nil {
+			width, height = &config.Width, &config.Height
+		}
+	}
+
 	// Create flyer records for each detected region
 	for _, flyerRegion := range result.FlyersDetected {
+		// Clean model-provided text before it reaches the database
+		notes := SanitizeField("notes", flyerRegion.Notes)
+
 		// Convert polygon to JSON
+		if width != nil && height != nil {
+			polygon, clamped := clampPolygon(flyerRegion.Polygon, *width, *height)
+			if clamped > 0 {
+				log.Printf("Clamped %d of %d polygon points of region %s in image %d of submission %s into %dx%d",
+					clamped, len(flyerRegion.Polygon), flyerRegion.RegionID, imageIndex, submissionID, *width, *height)
+			}
+			flyerRegion.Polygon = polygon
+		}
+		if distinct := distinctPoints(flyerRegion.Polygon); distinct < 3 {
+			log.Printf("Dropping region %s in image %d of submission %s: %d usable polygon points, %d events discarded",
+				flyerRegion.RegionID, imageIndex, submissionID, distinct, len(flyerRegion.Events))
+			continue
+		}
 		polygonJSON, err := json.Marshal(flyerRegion.Polygon)
 		if err != nil {
 			return fmt.Errorf("failed to marshal polygon: %w", err)
@@ -290,8 +633,15 @@ func (v *VisionService) SaveResults(db *gorm.DB, submissionID uuid.UUID, result
 			RegionID:           flyerRegion.RegionID,
 			Polygon:            string(polygonJSON),
 			RotationDeg:        flyerRegion.Rotation,
+			ImageIndex:         imageIndex,
+			ImageWidth:         width,
+			ImageHeight:        height,
 			DetectionConfidence: flyerRegion.Confidence,
-			Notes:              &flyerRegion.Notes,
+			Notes:              &notes,
+			VisionModel:        optionalString(usage.Model),
+			VisionServedModel:  optionalString(usage.ServedModel),
+			VisionFingerprint:  optionalString(usage.SystemFingerprint),
+			PromptVersion:      optionalString(usage.PromptVersion),
 		}
 
 		if err := db.Create(&flyer).Error; err != nil {
@@ -299,32 +649,83 @@ func (v *VisionService) SaveResults(db *gorm.DB, submissionID uuid.UUID, result
 		}
 
 		// Create event candidate records for each extracted event
-		for _, event := range flyerRegion.Events {
-			// Convert fields and confidences to JSON
-			fieldsJSON, err := json.Marshal(event.Fields)
-			if err != nil {
-				return fmt.Errorf("failed to marshal event fields: %w", err)
-			}
+		if _, err := SaveFlyerCandidates(db, flyer.ID, flyerRegion.Events); err != nil {
+			return err
+		}
+	}
 
-			confidencesJSON, err := json.Marshal(event.Confidences)
-			if err != nil {
-				return fmt.Errorf("failed to marshal confidences: %w", err)
-			}
+	return nil
+}
 
-			eventCandidate := models.EventCandidate{
-				FlyerID:      flyer.ID,
-				EventID:      event.EventID,
-				Fields:       string(fieldsJSON),
-				Confidences:  string(confidencesJSON),
-				SourceExcerpt: &event.Excerpt,
-				CompositeScore: &event.Confidences.Overall,
-			}
+// clampPolygon moves points that fall outside a width x height image onto its edge and
+// drops points that aren't numbers at all. It returns the points kept and how many were
+// moved or dropped.
+func clampPolygon(points []Point, width, height int) ([]Point, int) {
+	clamped := make([]Point, 0, len(points))
+	changed := 0
+	for _, p := range points {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) {
+			changed++
+			continue
+		}
+		q := Point{
+			X: math.Min(math.Max(p.X, 0), float64(width)),
+			Y: math.Min(math.Max(p.Y, 0), float64(height)),
+		}
+		if q != p {
+			changed++
+		}
+		clamped = append(clamped, q)
+	}
+	return clamped, changed
+}
 
-			if err := db.Create(&eventCandidate).Error; err != nil {
-				return fmt.Errorf("failed to create event candidate: %w", err)
-			}
+// distinctPoints counts the finite points of a polygon that differ from every other
+func distinctPoints(points []Point) int {
+	seen := make(map[Point]bool, len(points))
+	for _, p := range points {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+			continue
 		}
+		seen[p] = true
 	}
+	return len(seen)
+}
 
-	return nil
-}
\ No newline at end of file
+// SaveFlyerCandidates sanitizes extracted events and stores them as candidates of a flyer
+func SaveFlyerCandidates(db *gorm.DB, flyerID uuid.UUID, events []EventCandidate) ([]models.EventCandidate, error) {
+	candidates := make([]models.EventCandidate, 0, len(events))
+	schema := ActiveFieldSchema()
+	for _, event := range events {
+		schema.ApplyToEventFields(&event.Fields)
+		excerpt := SanitizeField("source_excerpt", event.Excerpt)
+
+		// Convert fields and confidences to JSON
+		fieldsJSON, err := json.Marshal(event.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event fields: %w", err)
+		}
+
+		confidencesJSON, err := json.Marshal(event.Confidences)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal confidences: %w", err)
+		}
+
+		overall := event.Confidences.Overall
+		eventCandidate := models.EventCandidate{
+			FlyerID:        flyerID,
+			EventID:        event.EventID,
+			Fields:         string(fieldsJSON),
+			SearchText:     CandidateSearchText(string(fieldsJSON)),
+			Confidences:    string(confidencesJSON),
+			SourceExcerpt:  &excerpt,
+			CompositeScore: &overall,
+		}
+
+		if err := db.Create(&eventCandidate).Error; err != nil {
+			return nil, fmt.Errorf("failed to create event candidate: %w", err)
+		}
+		candidates = append(candidates, eventCandidate)
+	}
+	return candidates, nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+// writeTestPhoto writes a w x h photo-like image (gradients with fine detail, so it doesn't
+// compress to nothing) as a JPEG or PNG and returns its path
+func writeTestPhoto(tb testing.TB, dir string, w, h int, asPNG bool) string {
+	tb.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < w; x++ {
+			row[x*4] = uint8(x * 255 / w)
+			row[x*4+1] = uint8(y * 255 / h)
+			row[x*4+2] = uint8((x ^ y) & 0xff)
+			row[x*4+3] = 255
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	name := "photo.jpg"
+	if asPNG {
+		name = "photo.png"
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func TestPrepareImage(t *testing.T) {
+	notImage := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notImage, []byte("definitely not a photo, just some text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := &VisionService{config: &config.Config{ImageMaxLongSide: 512, ImageJPEGQuality: 85}}
+	roomy := VisionImageLimits{MaxEncodedBytes: 20 << 20}
+
+	tests := []struct {
+		name        string
+		path        string
+		limits      VisionImageLimits
+		wantSize    image.Point
+		wantResized bool
+		wantErr     bool
+	}{
+		{"small JPEG is sent as-is", writeTestPhoto(t, t.TempDir(), 400, 300, false), roomy, image.Pt(400, 300), false, false},
+		{"landscape over the long side", writeTestPhoto(t, t.TempDir(), 1600, 1200, false), roomy, image.Pt(512, 384), true, false},
+		{"portrait over the long side", writeTestPhoto(t, t.TempDir(), 600, 1200, false), roomy, image.Pt(256, 512), true, false},
+		{"provider limit below the config", writeTestPhoto(t, t.TempDir(), 1000, 500, false), VisionImageLimits{MaxLongSide: 200, MaxEncodedBytes: 20 << 20}, image.Pt(200, 100), true, false},
+		{"oversized PNG becomes JPEG", writeTestPhoto(t, t.TempDir(), 1024, 1024, true), roomy, image.Pt(512, 512), true, false},
+		{"still too large after resizing", writeTestPhoto(t, t.TempDir(), 1600, 1200, false), VisionImageLimits{MaxEncodedBytes: 1024}, image.Point{}, false, true},
+		{"not an image", notImage, roomy, image.Point{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prepared, err := v.prepareImage(tt.path, tt.limits)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("prepareImage() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("prepareImage() error = %v", err)
+			}
+
+			raw, err := base64.StdEncoding.DecodeString(prepared.encoded)
+			if err != nil {
+				t.Fatalf("encoded image is not base64: %v", err)
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("encoded image does not decode: %v", err)
+			}
+			if got := image.Pt(cfg.Width, cfg.Height); got != tt.wantSize {
+				t.Errorf("sent %v, want %v", got, tt.wantSize)
+			}
+			if (prepared.resized != nil) != tt.wantResized {
+				t.Errorf("resized = %v, want %v", prepared.resized != nil, tt.wantResized)
+			}
+			if tt.wantResized && (format != "jpeg" || prepared.mediaType != "image/jpeg" || !bytes.Equal(raw, prepared.resized)) {
+				t.Errorf("resized image is %s (%s), want the JPEG kept for the crops", format, prepared.mediaType)
+			}
+
+			// Polygon coordinates from the sent image scale back onto the original
+			original, _, err := image.DecodeConfig(mustOpen(t, tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if x := float64(cfg.Width) * prepared.scaleX; x < float64(original.Width)-1 || x > float64(original.Width)+1 {
+				t.Errorf("scaleX maps the width to %.1f, want %d", x, original.Width)
+			}
+			if y := float64(cfg.Height) * prepared.scaleY; y < float64(original.Height)-1 || y > float64(original.Height)+1 {
+				t.Errorf("scaleY maps the height to %.1f, want %d", y, original.Height)
+			}
+		})
+	}
+}
+
+func mustOpen(t *testing.T, path string) *os.File {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// A 20-megapixel phone photo, the size that used to fail outright
+const twentyMPWidth, twentyMPHeight = 5472, 3648
+
+// TestPrepareImage20MP checks a 20-megapixel photo is resized rather than refused. The
+// time budget is BenchmarkPrepareImage20MP's to check, away from -race and busy CI runs.
+func TestPrepareImage20MP(t *testing.T) {
+	if testing.Short() {
+		t.Skip("decodes a 20-megapixel JPEG")
+	}
+	path := writeTestPhoto(t, t.TempDir(), twentyMPWidth, twentyMPHeight, false)
+	v := &VisionService{config: &config.Config{ImageMaxLongSide: 2048, ImageJPEGQuality: 85}}
+
+	prepared, err := v.prepareImage(path, VisionImageLimits{MaxEncodedBytes: 20 << 20})
+	if err != nil {
+		t.Fatalf("prepareImage() error = %v", err)
+	}
+	if prepared.resized == nil {
+		t.Fatal("20-megapixel photo was sent without resizing")
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(prepared.resized))
+	if err != nil || cfg.Width != 2048 || cfg.Height != 1365 {
+		t.Errorf("resized to %dx%d (%v), want 2048x1365", cfg.Width, cfg.Height, err)
+	}
+}
+
+// BenchmarkPrepareImage20MP resizes a 20-megapixel photo, failing if one resize averages
+// two seconds or more: go test -run '^$' -bench PrepareImage20MP ./services
+func BenchmarkPrepareImage20MP(b *testing.B) {
+	path := writeTestPhoto(b, b.TempDir(), twentyMPWidth, twentyMPHeight, false)
+	v := &VisionService{config: &config.Config{ImageMaxLongSide: 2048, ImageJPEGQuality: 85}}
+	limits := VisionImageLimits{MaxEncodedBytes: 20 << 20}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := v.prepareImage(path, limits); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if perOp := b.Elapsed() / time.Duration(b.N); perOp >= 2*time.Second {
+		b.Fatalf("resizing took %v per image, want under 2s", perOp)
+	}
+}
+
+// slotTestProvider answers every call after hold, recording the most calls it saw at once
+type slotTestProvider struct {
+	hold time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (p *slotTestProvider) Name() string { return VisionProviderOpenAI }
+
+func (p *slotTestProvider) ImageLimits() VisionImageLimits {
+	return VisionImageLimits{MaxEncodedBytes: 18 << 20}
+}
+
+func (p *slotTestProvider) Complete(ctx context.Context, req VisionRequest) (string, VisionUsage, error) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.peak {
+		p.peak = p.inFlight
+	}
+	p.mu.Unlock()
+	time.Sleep(p.hold)
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	return `{"events":[],"notes":""}`, VisionUsage{Model: req.Model}, nil
+}
+
+// slotTestService is a VisionService on provider with concurrency slots (0 for no limit)
+// and a slot wait of waitSec
+func slotTestService(provider VisionProvider, concurrency, waitSec int) *VisionService {
+	cfg := &config.Config{VisionProvider: VisionProviderOpenAI, VisionConcurrency: concurrency, VisionSlotWaitSec: waitSec, ImageMaxLongSide: 2048, ImageJPEGQuality: 85}
+	v := NewVisionService(cfg, nil)
+	v.providers = map[string]VisionProvider{VisionProviderOpenAI: provider}
+	return v
+}
+
+// extractConcurrently runs calls ExtractFlyerEvents on path at once and returns their errors
+func extractConcurrently(v *VisionService, path string, calls int) []error {
+	errs := make([]error, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, errs[i] = v.ExtractFlyerEvents(context.Background(), path, "gpt-4o")
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestVisionSlotsBoundConcurrentCalls(t *testing.T) {
+	path := writeTestPhoto(t, t.TempDir(), 640, 480, false)
+
+	tests := []struct {
+		name        string
+		concurrency int
+		waitSec     int
+		held        int // slots already taken by calls elsewhere
+		calls       int
+		wantPeak    int
+		wantBusy    int
+	}{
+		{name: "calls beyond the limit wait their turn", concurrency: 2, waitSec: 30, calls: 8, wantPeak: 2},
+		{name: "single slot serializes", concurrency: 1, waitSec: 30, calls: 4, wantPeak: 1},
+		{name: "no limit", calls: 4, wantPeak: 4},
+		{name: "calls that can't get a slot in time are busy", concurrency: 2, held: 2, calls: 3, wantBusy: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &slotTestProvider{hold: 50 * time.Millisecond}
+			v := slotTestService(provider, tt.concurrency, tt.waitSec)
+			for i := 0; i < tt.held; i++ {
+				v.slots <- struct{}{}
+			}
+
+			busy := 0
+			for _, err := range extractConcurrently(v, path, tt.calls) {
+				switch {
+				case errors.Is(err, ErrVisionBusy):
+					busy++
+				case err != nil:
+					t.Errorf("ExtractFlyerEvents() error = %v", err)
+				}
+			}
+			if provider.peak != tt.wantPeak {
+				t.Errorf("%d calls in flight at once, want %d", provider.peak, tt.wantPeak)
+			}
+			if busy != tt.wantBusy {
+				t.Errorf("%d calls busy, want %d", busy, tt.wantBusy)
+			}
+		})
+	}
+}
+
+func TestAcquireSlotGivesUpWithTheContext(t *testing.T) {
+	v := slotTestService(&slotTestProvider{}, 1, 30)
+	release, err := v.acquireSlot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := v.acquireSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquireSlot() error = %v, want the context's deadline", err)
+	}
+}
+
+// BenchmarkConcurrentVisionHeap runs ten simultaneous 20-megapixel extractions against a fake
+// provider and reports the peak heap, failing if it passes 400 MB. With two vision slots
+// only two decoded photos are held at once: go test -run '^$' -bench ConcurrentVisionHeap ./services
+func BenchmarkConcurrentVisionHeap(b *testing.B) {
+	path := writeTestPhoto(b, b.TempDir(), twentyMPWidth, twentyMPHeight, false)
+	v := slotTestService(&slotTestProvider{}, 2, 300)
+
+	var peak uint64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, err := range extractConcurrently(v, path, 10) {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.StopTimer()
+	close(done)
+	<-sampled
+
+	peakMB := float64(peak) / (1 << 20)
+	b.ReportMetric(peakMB, "peak-heap-MB")
+	if peakMB >= 400 {
+		b.Fatalf("peak heap %.0f MB with two vision slots, want under 400 MB", peakMB)
+	}
+}
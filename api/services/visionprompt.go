@@ -0,0 +1,56 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lincolngreen/williamboard/api/config"
+)
+
+// ErrInvalidVisionPrompt is returned when a configured vision prompt is empty
+var ErrInvalidVisionPrompt = errors.New("invalid vision prompt")
+
+// customAnalysisPrompt replaces the built-in photo analysis prompt when set; it is loaded
+// once at startup by LoadVisionPrompt
+var customAnalysisPrompt string
+
+// LoadVisionPrompt reads the photo analysis prompt from VISION_PROMPT_PATH, or failing that
+// VISION_PROMPT, and makes it the prompt every photo is analyzed with. With neither set the
+// built-in prompt, generated from the field schema, is used. Call once at startup, after
+// LoadFieldSchema. It returns the version of the prompt in use.
+func LoadVisionPrompt(cfg *config.Config) (string, error) {
+	prompt := cfg.VisionPrompt
+	if cfg.VisionPromptPath != "" {
+		data, err := os.ReadFile(cfg.VisionPromptPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read vision prompt file: %w", err)
+		}
+		prompt = string(data)
+		if strings.TrimSpace(prompt) == "" {
+			return "", fmt.Errorf("%w: %s is empty", ErrInvalidVisionPrompt, cfg.VisionPromptPath)
+		}
+	}
+
+	customAnalysisPrompt = strings.TrimSpace(prompt)
+	return PromptVersion(analysisPrompt()), nil
+}
+
+// PromptVersion identifies a prompt's exact text: the first 12 hex digits of its SHA-256.
+// Flyers record the version their candidates were extracted with, so extraction quality
+// can be compared across prompt revisions.
+func PromptVersion(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// analysisPrompt is the prompt photos are analyzed with: the configured one, if any
+func analysisPrompt() string {
+	if customAnalysisPrompt != "" {
+		return customAnalysisPrompt
+	}
+	return defaultAnalysisPrompt()
+}
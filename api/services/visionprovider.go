@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/observability"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Vision providers, as named by VISION_PROVIDER and model prefixes
+const (
+	VisionProviderOpenAI    = "openai"
+	VisionProviderAnthropic = "anthropic"
+)
+
+// ErrVisionProviderUnavailable is returned for a model whose provider has no API key
+var ErrVisionProviderUnavailable = errors.New("vision provider is not configured")
+
+// VisionRequest is one image and prompt for a vision model
+type VisionRequest struct {
+	Model     string // the provider's own model name, without a provider prefix
+	Prompt    string
+	ImageData string // base64
+	MediaType string // e.g. image/jpeg
+}
+
+// VisionImageLimits is the largest image a provider takes as sent. Anything bigger would be
+// refused or silently downscaled by the provider, which would put the polygons it returns
+// in a frame we don't know.
+type VisionImageLimits struct {
+	MaxLongSide     int // pixels; 0 leaves it to ImageMaxLongSide
+	MaxEncodedBytes int // base64 size
+}
+
+// VisionProvider sends an image and prompt to one vendor's vision models and returns the
+// text of the reply, which the prompt asks to be a JSON object. Providers retry transient
+// failures themselves, each attempt bounded by OPENAI_TIMEOUT_MS. Usage is returned even
+// when the call fails, since a failed reply may still have been billed.
+type VisionProvider interface {
+	Name() string
+	ImageLimits() VisionImageLimits
+	Complete(ctx context.Context, req VisionRequest) (string, VisionUsage, error)
+}
+
+// SplitVisionModel reads a requested model. "provider/model" names its provider
+// explicitly, which lets an experiment compare vendors (control=gpt-4o,
+// claude=anthropic/claude-3-5-sonnet-20241022); a bare model belongs to defaultProvider.
+func SplitVisionModel(model, defaultProvider string) (string, string) {
+	if provider, name, ok := strings.Cut(model, "/"); ok {
+		switch provider {
+		case VisionProviderOpenAI, VisionProviderAnthropic:
+			return provider, name
+		}
+	}
+	return defaultProvider, model
+}
+
+// DefaultVisionModel is the model used outside experiments: VISION_PROVIDER's model
+func DefaultVisionModel(cfg *config.Config) string {
+	if cfg.VisionProvider == VisionProviderAnthropic {
+		return cfg.AnthropicModel
+	}
+	return cfg.OpenAIModel
+}
+
+// visionProviderConfigured reports whether a provider has the API key it needs
+func visionProviderConfigured(cfg *config.Config, provider string) bool {
+	switch provider {
+	case VisionProviderOpenAI:
+		return cfg.OpenAIAPIKey != ""
+	case VisionProviderAnthropic:
+		return cfg.AnthropicAPIKey != ""
+	default:
+		return false
+	}
+}
+
+// jsonObject trims a reply to its outermost JSON object, dropping any prose or code fence
+// a model wrapped around it
+func jsonObject(content string) string {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start : end+1]
+}
+
+// visionMaxTokens bounds the reply to one vision call
+const visionMaxTokens = 2000
+
+// visionTemperature is low for consistent structured output
+const visionTemperature = 0.1
+
+// OpenAIVisionProvider calls the OpenAI chat completions API in JSON mode
+type OpenAIVisionProvider struct {
+	client *openai.Client
+	config *config.Config
+}
+
+// newOpenAIClient is an OpenAI client whose requests are traced
+func newOpenAIClient(apiKey string) *openai.Client {
+	clientConfig := openai.DefaultConfig(apiKey)
+	clientConfig.HTTPClient = observability.HTTPClient(&http.Client{})
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+func NewOpenAIVisionProvider(cfg *config.Config) *OpenAIVisionProvider {
+	return &OpenAIVisionProvider{client: newOpenAIClient(cfg.OpenAIAPIKey), config: cfg}
+}
+
+func (p *OpenAIVisionProvider) Name() string {
+	return VisionProviderOpenAI
+}
+
+// ImageLimits allows 18MB of base64 (the API takes 20MB)
+func (p *OpenAIVisionProvider) ImageLimits() VisionImageLimits {
+	return VisionImageLimits{MaxEncodedBytes: 18 * 1024 * 1024}
+}
+
+func (p *OpenAIVisionProvider) Complete(ctx context.Context, req VisionRequest) (string, VisionUsage, error) {
+	usage := VisionUsage{Model: req.Model}
+	chatReq := openai.ChatCompletionRequest{
+		Model: req.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{
+						Type: openai.ChatMessagePartTypeText,
+						Text: req.Prompt,
+					},
+					{
+						Type: openai.ChatMessagePartTypeImageURL,
+						ImageURL: &openai.ChatMessageImageURL{
+							URL: fmt.Sprintf("data:%s;base64,%s", req.MediaType, req.ImageData),
+						},
+					},
+				},
+			},
+		},
+		MaxTokens:   visionMaxTokens,
+		Temperature: visionTemperature,
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+	}
+
+	// Each attempt gets its own timeout; retries stay within the caller's deadline
+	resp, err := callWithRetry(ctx, NewOpenAIRetryPolicy(p.config), "OpenAI "+req.Model+" vision", func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(p.config.OpenAITimeoutMS)*time.Millisecond)
+		defer cancel()
+		return p.client.CreateChatCompletion(ctx, chatReq)
+	})
+	if err != nil {
+		return "", usage, err
+	}
+	usage.ServedModel = resp.Model
+	usage.SystemFingerprint = resp.SystemFingerprint
+	usage.PromptTokens = resp.Usage.PromptTokens
+	usage.CompletionTokens = resp.Usage.CompletionTokens
+
+	if len(resp.Choices) == 0 {
+		return "", usage, fmt.Errorf("no response from %s", req.Model)
+	}
+	return resp.Choices[0].Message.Content, usage, nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lincolngreen/williamboard/api/models"
+)
+
+// WebhookSink POSTs every outbox topic to a configured receiver. Each request carries
+// the entry's idempotency key so the receiver can drop repeated deliveries.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink for url. With a secret, the body is signed with
+// HMAC-SHA256 in the X-WilliamBoard-Signature header.
+func NewWebhookSink(url, secret string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Handles(topic string) bool {
+	return true
+}
+
+// webhookBody is the JSON envelope sent to webhook receivers
+type webhookBody struct {
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, entry *models.OutboxEntry) error {
+	body, err := json.Marshal(webhookBody{
+		ID:        entry.IdempotencyKey,
+		Topic:     entry.Topic,
+		CreatedAt: entry.CreatedAt,
+		Data:      json.RawMessage(entry.Payload),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", entry.IdempotencyKey)
+	req.Header.Set("X-WilliamBoard-Topic", entry.Topic)
+	if s.secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(body)
+		req.Header.Set("X-WilliamBoard-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return postOutboxRequest(s.client, req, "webhook")
+}
+
+// SlackNotifier posts a message to a Slack incoming webhook when a candidate needs review,
+// an event is published, a venue manager disputes an event, or OpenAI starts serving a new
+// model version. Slack can't deduplicate, so a redelivery may post twice.
+type SlackNotifier struct {
+	webhookURL    string
+	publicBaseURL string
+	client        *http.Client
+}
+
+func NewSlackNotifier(webhookURL, publicBaseURL string, timeout time.Duration) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL:    webhookURL,
+		publicBaseURL: strings.TrimRight(publicBaseURL, "/"),
+		client:        &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *SlackNotifier) Name() string {
+	return "notifier"
+}
+
+func (n *SlackNotifier) Handles(topic string) bool {
+	return topic == OutboxTopicCandidateNeedsReview || topic == OutboxTopicEventChanged ||
+		topic == OutboxTopicEventDisputed || topic == OutboxTopicModelVersionNew
+}
+
+func (n *SlackNotifier) Deliver(ctx context.Context, entry *models.OutboxEntry) error {
+	text, err := n.message(entry)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		return nil // not worth a notification
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return postOutboxRequest(n.client, req, "slack")
+}
+
+// message renders the notification text, or "" for changes nobody needs to hear about
+func (n *SlackNotifier) message(entry *models.OutboxEntry) (string, error) {
+	switch entry.Topic {
+	case OutboxTopicCandidateNeedsReview:
+		var payload CandidateReviewPayload
+		if err := json.Unmarshal([]byte(entry.Payload), &payload); err != nil {
+			return "", fmt.Errorf("failed to parse candidate review payload: %w", err)
+		}
+		title := payload.Title
+		if title == "" {
+			title = "Untitled event"
+		}
+		return fmt.Sprintf("%s needs review (%s): %s/admin/candidates/%s/preview",
+			title, payload.ReviewReason, n.publicBaseURL, payload.CandidateID), nil
+
+	case OutboxTopicEventChanged:
+		var payload EventChangePayload
+		if err := json.Unmarshal([]byte(entry.Payload), &payload); err != nil {
+			return "", fmt.Errorf("failed to parse event change payload: %w", err)
+		}
+		if payload.ChangeType != ChangeTypeCreated && payload.Reason != ChangeReasonRepublished {
+			return "", nil
+		}
+		return fmt.Sprintf("Event published: %s/admin/events/%s", n.publicBaseURL, payload.EventID), nil
+
+	case OutboxTopicEventDisputed:
+		var payload EventDisputePayload
+		if err := json.Unmarshal([]byte(entry.Payload), &payload); err != nil {
+			return "", fmt.Errorf("failed to parse event dispute payload: %w", err)
+		}
+		return fmt.Sprintf("%s disputed an event and it was pulled for review (%s): %s/admin/events/%s",
+			payload.VenueName, payload.Reason, n.publicBaseURL, payload.EventID), nil
+
+	case OutboxTopicModelVersionNew:
+		var payload ModelVersionPayload
+		if err := json.Unmarshal([]byte(entry.Payload), &payload); err != nil {
+			return "", fmt.Errorf("failed to parse model version payload: %w", err)
+		}
+		return fmt.Sprintf("OpenAI is now serving %s for %s %s calls (previously %s); watch extraction quality: %s/admin/api/models",
+			payload.ServedModel, payload.RequestedModel, payload.Purpose, strings.Join(payload.PreviousModels, ", "), n.publicBaseURL), nil
+	}
+	return "", nil
+}
+
+// postOutboxRequest sends req and turns a non-2xx response into an UpstreamStatusError
+func postOutboxRequest(client *http.Client, req *http.Request, service string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", service, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &UpstreamStatusError{Service: service, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
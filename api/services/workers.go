@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/models"
+	"gorm.io/gorm"
+)
+
+// ErrSubmissionHasPublished is returned when clearing results would orphan published events
+var ErrSubmissionHasPublished = errors.New("submission already has published events")
+
+// workerBacklog is how many jobs can wait in the channel before Enqueue hands off to a
+// goroutine that waits for room
+const workerBacklog = 1024
+
+// ClearSubmissionResults deletes a submission's flyers and candidates, and unlinks it from
+// any submission it duplicated, so processing can run again without duplicating vision output. Submissions with published candidates are refused.
+func ClearSubmissionResults(db *gorm.DB, submissionID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var published int64
+		if err := tx.Model(&models.EventCandidate{}).
+			Joins("JOIN flyers ON flyers.id = event_candidates.flyer_id").
+			Where("flyers.submission_id = ? AND event_candidates.published_event_id IS NOT NULL", submissionID).
+			Count(&published).Error; err != nil {
+			return err
+		}
+		if published > 0 {
+			return ErrSubmissionHasPublished
+		}
+
+		flyerIDs := tx.Model(&models.Flyer{}).Select("id").Where("submission_id = ?", submissionID)
+		if err := tx.Where("flyer_id IN (?)", flyerIDs).Delete(&models.EventCandidate{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("submission_id = ?", submissionID).Delete(&models.Flyer{}).Error; err != nil {
+			return err
+		}
+		// A deduplicated upload gets its own analysis from now on
+		return tx.Model(&models.Submission{}).Where("id = ?", submissionID).Update("duplicate_of_id", nil).Error
+	})
+}
+
+// SubmissionWorkers runs uploaded submissions through the pipeline in the background, so
+// uploads return as soon as the file is stored. Jobs are held in memory; a submission
+// whose instance dies mid-job is picked up again by RequeueStale on the next start.
+type SubmissionWorkers struct {
+	db           *gorm.DB
+	size         int
+	staleTimeout time.Duration
+	broker       *StatusBroker
+	queue        *ProcessingQueue
+	process      func(ctx context.Context, submissionID uuid.UUID) error
+	jobs         chan uuid.UUID
+
+	// ctx is cancelled when Close gives up waiting, interrupting in-flight jobs
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	// mu guards closed, so no overflow send is added to pending once Close waits on it
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// NewSubmissionWorkers creates a pool of cfg.WorkerPoolSize workers that hand each job to
+// process. The queue still bounds how many run at once and reports queue positions.
+func NewSubmissionWorkers(db *gorm.DB, cfg *config.Config, broker *StatusBroker, queue *ProcessingQueue, process func(ctx context.Context, submissionID uuid.UUID) error) *SubmissionWorkers {
+	size := cfg.WorkerPoolSize
+	if size < 1 {
+		size = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SubmissionWorkers{
+		db:           db,
+		size:         size,
+		staleTimeout: time.Duration(cfg.StaleProcessingTimeoutSec) * time.Second,
+		broker:       broker,
+		queue:        queue,
+		process:      process,
+		jobs:         make(chan uuid.UUID, workerBacklog),
+		ctx:          ctx,
+		cancel:       cancel,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start launches the workers and re-enqueues submissions abandoned by an earlier run
+func (w *SubmissionWorkers) Start() {
+	for i := 0; i < w.size; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for {
+				// Checked first so a closed pool never starts another job
+				select {
+				case <-w.stop:
+					return
+				default:
+				}
+				select {
+				case <-w.stop:
+					return
+				case submissionID := <-w.jobs:
+					w.run(submissionID)
+				}
+			}
+		}()
+	}
+	log.Printf("Started %d submission workers", w.size)
+
+	w.RequeueStale()
+}
+
+// Close stops taking jobs and waits up to grace for in-flight ones to finish, then cancels
+// them. Interrupted and still-queued submissions are handed back for the next start to
+// requeue. Call it after the HTTP server has stopped accepting uploads.
+func (w *SubmissionWorkers) Close(grace time.Duration) {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		close(w.stop)
+		w.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			w.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(grace):
+			log.Printf("Submission workers: jobs still running after %s, cancelling", grace)
+			w.cancel()
+			<-done
+		}
+		w.cancel()
+
+		// Overflow sends either land in the channel, drained below, or hand their job back
+		w.pending.Wait()
+		abandoned := 0
+		for {
+			select {
+			case submissionID := <-w.jobs:
+				w.abandon(submissionID)
+				abandoned++
+			default:
+				if abandoned > 0 {
+					log.Printf("Submission workers: handed back %d queued submissions", abandoned)
+				}
+				return
+			}
+		}
+	})
+}
+
+// Enqueue queues a submission for processing without blocking. The caller marks it
+// "processing" first, so a restart can find it if it never runs. After Close, or when the
+// pool closes while the job waits for room in a full backlog, it is handed back instead.
+func (w *SubmissionWorkers) Enqueue(submissionID uuid.UUID) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		w.abandon(submissionID)
+		return
+	}
+	w.queue.Join(submissionID)
+	select {
+	case w.jobs <- submissionID:
+		w.mu.Unlock()
+		return
+	default:
+	}
+	w.pending.Add(1)
+	w.mu.Unlock()
+
+	go func() {
+		defer w.pending.Done()
+		select {
+		case w.jobs <- submissionID:
+		case <-w.stop:
+			w.abandon(submissionID)
+		}
+	}()
+}
+
+// run processes one job. A panic marks the submission "error" instead of leaving it
+// stuck in "processing" and keeps the worker alive for the next job. A job cut short by
+// Close is handed back, and one that found vision at capacity is queued again, rather
+// than left as a failure.
+func (w *SubmissionWorkers) run(submissionID uuid.UUID) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Submission worker: processing of %s panicked: %v", submissionID, r)
+			w.markError(submissionID, fmt.Errorf("processing panicked: %v", r))
+		}
+	}()
+
+	if err := w.process(w.ctx, submissionID); err != nil {
+		if w.ctx.Err() != nil {
+			log.Printf("Submission worker: processing of %s interrupted by shutdown", submissionID)
+			w.abandon(submissionID)
+			return
+		}
+		// Vision stayed at capacity; back of the queue rather than a failure
+		if errors.Is(err, ErrVisionBusy) {
+			log.Printf("Submission worker: %s waited too long for vision, requeueing", submissionID)
+			if err := w.requeue(submissionID); err != nil {
+				log.Printf("Submission worker: failed to requeue %s: %v", submissionID, err)
+				w.markError(submissionID, err)
+			}
+			return
+		}
+		log.Printf("Submission worker: processing of %s failed: %v", submissionID, err)
+		RecordSubmissionError(w.db, submissionID, err)
+	}
+}
+
+// abandon puts a submission back to "processing" with a timestamp old enough that the next
+// start's RequeueStale picks it up straight away
+func (w *SubmissionWorkers) abandon(submissionID uuid.UUID) {
+	if err := w.db.Model(&models.Submission{}).
+		Where("id = ?", submissionID).
+		Updates(map[string]interface{}{
+			"status":     "processing",
+			"updated_at": time.Now().Add(-w.staleTimeout - time.Minute),
+		}).Error; err != nil {
+		log.Printf("Submission worker: failed to hand back %s: %v", submissionID, err)
+	}
+}
+
+// markError records a failed job and its cause, logging (not failing) when the update
+// itself fails
+func (w *SubmissionWorkers) markError(submissionID uuid.UUID, cause error) {
+	if err := w.db.Model(&models.Submission{}).
+		Where("id = ?", submissionID).
+		Updates(map[string]interface{}{
+			"status":     "error",
+			"last_error": cause.Error(),
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+		log.Printf("Submission worker: failed to mark %s as error: %v", submissionID, err)
+		return
+	}
+	w.broker.Publish(submissionID, "error")
+}
+
+// RequeueStale re-enqueues uploaded submissions that have been "processing" for longer than
+// the stale timeout, after clearing any partial results. Ones that already published events
+// can't be re-run safely and are marked "error" for an admin to look at.
+func (w *SubmissionWorkers) RequeueStale() {
+	var stale []models.Submission
+	if err := w.db.Select("id").
+		Where("status = ? AND source = ? AND updated_at < ?", "processing", SubmissionSourceUpload, time.Now().Add(-w.staleTimeout)).
+		Order("updated_at ASC").
+		Find(&stale).Error; err != nil {
+		log.Printf("Submission worker: failed to load stale submissions: %v", err)
+		return
+	}
+
+	requeued := 0
+	for _, submission := range stale {
+		if err := w.requeue(submission.ID); err != nil {
+			log.Printf("Submission worker: failed to requeue %s: %v", submission.ID, err)
+			w.markError(submission.ID, err)
+			continue
+		}
+		requeued++
+	}
+	if len(stale) > 0 {
+		log.Printf("Submission worker: requeued %d of %d stale submissions", requeued, len(stale))
+	}
+}
+
+// requeue clears a stale submission's partial results, refreshes its timestamp, and enqueues it
+func (w *SubmissionWorkers) requeue(submissionID uuid.UUID) error {
+	if err := ClearSubmissionResults(w.db, submissionID); err != nil {
+		return err
+	}
+	if err := w.db.Model(&models.Submission{}).
+		Where("id = ?", submissionID).
+		Update("updated_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to refresh submission: %w", err)
+	}
+	w.Enqueue(submissionID)
+	return nil
+}
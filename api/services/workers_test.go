@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lincolngreen/williamboard/api/config"
+	"github.com/lincolngreen/williamboard/api/internal/testdb"
+)
+
+var workersConfig = &config.Config{WorkerPoolSize: 1, StaleProcessingTimeoutSec: 600}
+
+// expectHandBack expects a submission to be put back to "processing" for the next start
+func expectHandBack(mock sqlmock.Sqlmock, submissionID uuid.UUID) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "submissions" SET "status"=$1,"updated_at"=$2 WHERE id = $3`).
+		WithArgs("processing", testdb.Any, submissionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectMarkError expects a submission to be marked "error"
+func expectMarkError(mock sqlmock.Sqlmock, submissionID uuid.UUID) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "submissions" SET "last_error"=$1,"status"=$2,"updated_at"=$3 WHERE id = $4`).
+		WithArgs(testdb.Any, "error", testdb.Any, submissionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectStale expects RequeueStale's lookup to find the given submissions
+func expectStale(mock sqlmock.Sqlmock, submissionIDs ...uuid.UUID) {
+	rows := sqlmock.NewRows([]string{"id"})
+	for _, id := range submissionIDs {
+		rows.AddRow(id.String())
+	}
+	mock.ExpectQuery(`SELECT "id" FROM "submissions" WHERE status = $1 AND source = $2 AND updated_at < $3 ORDER BY updated_at ASC`).
+		WithArgs("processing", SubmissionSourceUpload, testdb.Any).
+		WillReturnRows(rows)
+}
+
+// expectClearResults expects ClearSubmissionResults for a submission with published
+// candidates already live (refused) or none
+func expectClearResults(mock sqlmock.Sqlmock, submissionID uuid.UUID, published int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count(*) FROM "event_candidates" JOIN flyers`).
+		WithArgs(submissionID).
+		WillReturnRows(testdb.Count(published))
+	if published > 0 {
+		mock.ExpectRollback()
+		return
+	}
+	mock.ExpectExec(`DELETE FROM "event_candidates" WHERE flyer_id IN (SELECT "id" FROM "flyers" WHERE submission_id = $1)`).
+		WithArgs(submissionID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "flyers" WHERE submission_id = $1`).
+		WithArgs(submissionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "submissions" SET "duplicate_of_id"=$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestSubmissionWorkersRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		process func(ctx context.Context, submissionID uuid.UUID) error
+		expect  func(mock sqlmock.Sqlmock, submissionID uuid.UUID)
+		status  string // published to status subscribers, "" for none
+		requeue bool
+	}{
+		{
+			name:    "success touches nothing",
+			process: func(context.Context, uuid.UUID) error { return nil },
+			expect:  func(sqlmock.Sqlmock, uuid.UUID) {},
+		},
+		{
+			name:    "failure records the error",
+			process: func(context.Context, uuid.UUID) error { return errors.New("geocoder down") },
+			expect: func(mock sqlmock.Sqlmock, submissionID uuid.UUID) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "submissions" SET "last_error"=$1,"updated_at"=$2 WHERE id = $3`).
+					WithArgs("geocoder down", testdb.Any, submissionID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:    "panic marks error instead of leaving it processing",
+			process: func(context.Context, uuid.UUID) error { panic("nil map") },
+			expect:  expectMarkError,
+			status:  "error",
+		},
+		{
+			name:    "vision at capacity goes to the back of the queue",
+			process: func(context.Context, uuid.UUID) error { return ErrVisionBusy },
+			expect: func(mock sqlmock.Sqlmock, submissionID uuid.UUID) {
+				expectClearResults(mock, submissionID, 0)
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "submissions" SET "updated_at"=$1 WHERE id = $2`).
+					WithArgs(testdb.Any, submissionID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			requeue: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testdb.New(t)
+			broker := NewStatusBroker()
+			submissionID := uuid.New()
+			statuses, cancel := broker.Subscribe(submissionID)
+			defer cancel()
+			tt.expect(mock, submissionID)
+
+			w := NewSubmissionWorkers(db, workersConfig, broker, NewProcessingQueue(0), tt.process)
+			w.run(submissionID)
+
+			select {
+			case status := <-statuses:
+				if status != tt.status {
+					t.Errorf("published %q, want %q", status, tt.status)
+				}
+			default:
+				if tt.status != "" {
+					t.Errorf("published nothing, want %q", tt.status)
+				}
+			}
+			if requeued := len(w.jobs) == 1; requeued != tt.requeue {
+				t.Errorf("requeued = %v, want %v", requeued, tt.requeue)
+			}
+		})
+	}
+}
+
+func TestSubmissionWorkersOverflowAfterClose(t *testing.T) {
+	db, mock := testdb.New(t)
+	// Overflow sends give their jobs back from their own goroutines, in any order
+	mock.MatchExpectationsInOrder(false)
+
+	w := NewSubmissionWorkers(db, workersConfig, NewStatusBroker(), NewProcessingQueue(0), func(context.Context, uuid.UUID) error {
+		t.Error("a job ran on a pool that was never started")
+		return nil
+	})
+	w.jobs = make(chan uuid.UUID, 1)
+
+	queued := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for _, id := range queued {
+		expectHandBack(mock, id)
+		w.Enqueue(id) // one fits the backlog, two wait for room
+	}
+	w.Close(time.Second)
+
+	late := uuid.New()
+	expectHandBack(mock, late)
+	w.Enqueue(late)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("not every queued submission was handed back: %v", err)
+	}
+	if len(w.jobs) != 0 {
+		t.Errorf("%d jobs left in the backlog after Close", len(w.jobs))
+	}
+}
+
+func TestSubmissionWorkersCloseInterruptsRunningJob(t *testing.T) {
+	db, mock := testdb.New(t)
+	panicking, running := uuid.New(), uuid.New()
+	expectStale(mock)
+	expectMarkError(mock, panicking)
+	expectHandBack(mock, running)
+
+	started := make(chan struct{})
+	w := NewSubmissionWorkers(db, workersConfig, NewStatusBroker(), NewProcessingQueue(0), func(ctx context.Context, submissionID uuid.UUID) error {
+		if submissionID == panicking {
+			panic("boom")
+		}
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	w.Start()
+	w.Enqueue(panicking)
+	w.Enqueue(running)
+
+	// The worker survived the panic and picked up the next job
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the worker did not take the job after the one that panicked")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		w.Close(20 * time.Millisecond)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not cancel the running job after its grace period")
+	}
+}
+
+func TestSubmissionWorkersRequeueStale(t *testing.T) {
+	db, mock := testdb.New(t)
+	stuck, published := uuid.New(), uuid.New()
+
+	expectStale(mock, stuck, published)
+	expectClearResults(mock, stuck, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "submissions" SET "updated_at"=$1 WHERE id = $2`).
+		WithArgs(testdb.Any, stuck).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// Re-running would duplicate live events, so this one is left for an admin
+	expectClearResults(mock, published, 1)
+	expectMarkError(mock, published)
+
+	w := NewSubmissionWorkers(db, workersConfig, NewStatusBroker(), NewProcessingQueue(0), nil)
+	w.RequeueStale()
+
+	if n := len(w.jobs); n != 1 || <-w.jobs != stuck {
+		t.Errorf("RequeueStale() queued %d jobs, want only the stuck submission", n)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client talks to the admin API
+type client struct {
+	baseURL string
+	token   string
+	adminID string
+	http    *http.Client
+}
+
+func newClient(baseURL, token, adminID string) *client {
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		adminID: adminID,
+		// Retries run the full vision pipeline, so allow for slow responses
+		http: &http.Client{Timeout: 3 * time.Minute},
+	}
+}
+
+// do sends a request and returns the raw response body, turning non-2xx responses into errors
+func (c *client) do(method, path string, query url.Values, body interface{}) ([]byte, error) {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.adminID != "" {
+		req.Header.Set("X-Admin-Id", c.adminID)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error interface{} `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != nil {
+			return nil, fmt.Errorf("%s %s: %d: %v", method, path, resp.StatusCode, apiErr.Error)
+		}
+		return nil, fmt.Errorf("%s %s: %d", method, path, resp.StatusCode)
+	}
+	return data, nil
+}
+
+// getJSON decodes a successful response into out
+func (c *client) getJSON(method, path string, query url.Values, body, out interface{}) ([]byte, error) {
+	data, err := c.do(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,482 @@
+// Command wbctl is an operator CLI for common WilliamBoard maintenance tasks.
+// It talks to the admin API at WB_API_URL, authenticating with the admin API key in
+// WB_ADMIN_TOKEN.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const usage = `Usage: wbctl [--json] <command> [arguments]
+
+Commands:
+  submissions list [--status=error] [--limit=50]
+  submissions retry <id>
+  events unpublish <id> --reason=spam|duplicate|bad_location|inappropriate
+  events delete <id> --reason=legal_takedown|privacy|copyright|other [--note=...]
+  venues merge <duplicate-id> <canonical-id>
+  export events [--start=YYYY-MM-DD] [--end=YYYY-MM-DD] [--format=csv|json]
+  maintenance purge [--dry-run]
+  maintenance backfill-search
+  candidates search <query> [--limit=50]
+  admin setup-key
+  admin generate-key
+
+Environment:
+  WB_API_URL       API base URL (default http://localhost:8080)
+  WB_ADMIN_TOKEN   Admin API key (from admin setup-key, or the server's ADMIN_TOKEN; the
+                   server's ADMIN_BOOTSTRAP_TOKEN for the first setup-key)
+  WB_ADMIN_ID      Your moderator name, recorded in the audit log
+`
+
+func main() {
+	args, jsonOutput := extractJSONFlag(os.Args[1:])
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	baseURL := os.Getenv("WB_API_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	cli := &cli{
+		client: newClient(baseURL, os.Getenv("WB_ADMIN_TOKEN"), os.Getenv("WB_ADMIN_ID")),
+		json:   jsonOutput,
+	}
+
+	var err error
+	switch args[0] + " " + args[1] {
+	case "submissions list":
+		err = cli.submissionsList(args[2:])
+	case "submissions retry":
+		err = cli.submissionsRetry(args[2:])
+	case "events unpublish":
+		err = cli.eventsUnpublish(args[2:])
+	case "events delete":
+		err = cli.eventsDelete(args[2:])
+	case "venues merge":
+		err = cli.venuesMerge(args[2:])
+	case "export events":
+		err = cli.exportEvents(args[2:])
+	case "maintenance purge":
+		err = cli.maintenancePurge(args[2:])
+	case "maintenance backfill-search":
+		err = cli.maintenanceBackfillSearch(args[2:])
+	case "candidates search":
+		err = cli.candidatesSearch(args[2:])
+	case "admin setup-key":
+		err = cli.adminSetupKey(args[2:])
+	case "admin generate-key":
+		err = cli.adminGenerateKey(args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wbctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+type cli struct {
+	client *client
+	json   bool
+}
+
+// extractJSONFlag pulls the global --json flag from anywhere in the arguments
+func extractJSONFlag(args []string) ([]string, bool) {
+	rest := make([]string, 0, len(args))
+	found := false
+	for _, arg := range args {
+		if arg == "--json" || arg == "-json" {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, found
+}
+
+// parseInterspersed parses flags that may appear before or after positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// printJSON pretty-prints a raw API response
+func printJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+func (c *cli) submissionsList(args []string) error {
+	fs := flag.NewFlagSet("submissions list", flag.ContinueOnError)
+	status := fs.String("status", "", "filter by status (uploaded, processing, parsed, error, done)")
+	limit := fs.Int("limit", 50, "maximum submissions to list")
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+
+	query := url.Values{"limit": {strconv.Itoa(*limit)}}
+	if *status != "" {
+		query.Set("status", *status)
+	}
+
+	var resp struct {
+		Submissions []struct {
+			ID         string `json:"id"`
+			Status     string `json:"status"`
+			Source     string `json:"source"`
+			Flyers     int64  `json:"flyers"`
+			Candidates int64  `json:"candidates"`
+			UpdatedAt  string `json:"updated_at"`
+		} `json:"submissions"`
+	}
+	data, err := c.client.getJSON("GET", "/admin/api/submissions", query, nil, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+
+	table := newTable()
+	fmt.Fprintln(table, "ID\tSTATUS\tSOURCE\tFLYERS\tCANDIDATES\tUPDATED")
+	for _, s := range resp.Submissions {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\t%s\n", s.ID, s.Status, s.Source, s.Flyers, s.Candidates, s.UpdatedAt)
+	}
+	return table.Flush()
+}
+
+func (c *cli) submissionsRetry(args []string) error {
+	fs := flag.NewFlagSet("submissions retry", flag.ContinueOnError)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: submissions retry <id>")
+	}
+
+	var resp struct {
+		SubmissionID string `json:"submissionId"`
+		Status       string `json:"status"`
+	}
+	data, err := c.client.getJSON("POST", "/admin/api/submissions/"+url.PathEscape(positional[0])+"/retry", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+	fmt.Printf("Submission %s retried: status %s\n", resp.SubmissionID, resp.Status)
+	return nil
+}
+
+func (c *cli) eventsUnpublish(args []string) error {
+	fs := flag.NewFlagSet("events unpublish", flag.ContinueOnError)
+	reason := fs.String("reason", "", "spam, duplicate, bad_location, or inappropriate")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || *reason == "" {
+		return fmt.Errorf("usage: events unpublish <id> --reason=<reason>")
+	}
+
+	var resp struct {
+		EventID      string   `json:"event_id"`
+		CandidateIDs []string `json:"candidate_ids"`
+	}
+	data, err := c.client.getJSON("POST", "/admin/api/events/"+url.PathEscape(positional[0])+"/unpublish", nil,
+		map[string]string{"reason": *reason}, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+	fmt.Printf("Event %s unpublished (%s); %d linked candidates blocked\n", resp.EventID, *reason, len(resp.CandidateIDs))
+	return nil
+}
+
+func (c *cli) eventsDelete(args []string) error {
+	fs := flag.NewFlagSet("events delete", flag.ContinueOnError)
+	reason := fs.String("reason", "", "legal_takedown, privacy, copyright, or other")
+	note := fs.String("note", "", "why the event was deleted, kept on its tombstone")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || *reason == "" {
+		return fmt.Errorf("usage: events delete <id> --reason=<reason> [--note=...]")
+	}
+
+	var resp struct {
+		EventID       string   `json:"event_id"`
+		CandidateIDs  []string `json:"candidate_ids"`
+		CooldownUntil string   `json:"cooldown_until"`
+	}
+	data, err := c.client.getJSON("DELETE", "/admin/events/"+url.PathEscape(positional[0]), nil,
+		map[string]string{"reason": *reason, "note": *note}, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+	fmt.Printf("Event %s deleted (%s); %d linked candidates blocked; no auto-publishing until %s\n",
+		resp.EventID, *reason, len(resp.CandidateIDs), resp.CooldownUntil)
+	return nil
+}
+
+func (c *cli) venuesMerge(args []string) error {
+	fs := flag.NewFlagSet("venues merge", flag.ContinueOnError)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: venues merge <duplicate-id> <canonical-id>")
+	}
+
+	var resp struct {
+		RepointedEvents []string `json:"repointed_events"`
+	}
+	data, err := c.client.getJSON("POST", "/admin/api/venues/"+url.PathEscape(positional[0])+"/merge", nil,
+		map[string]string{"into": positional[1]}, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+	fmt.Printf("Venue %s merged into %s; %d events re-pointed\n", positional[0], positional[1], len(resp.RepointedEvents))
+	return nil
+}
+
+func (c *cli) exportEvents(args []string) error {
+	fs := flag.NewFlagSet("export events", flag.ContinueOnError)
+	start := fs.String("start", "", "first start date (YYYY-MM-DD, inclusive)")
+	end := fs.String("end", "", "last start date (YYYY-MM-DD, exclusive)")
+	format := fs.String("format", "csv", "csv or json")
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	if c.json {
+		*format = "json"
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("--format must be csv or json")
+	}
+
+	query := url.Values{"format": {*format}}
+	if *start != "" {
+		query.Set("start", *start)
+	}
+	if *end != "" {
+		query.Set("end", *end)
+	}
+
+	data, err := c.client.do("GET", "/admin/api/export/events", query, nil)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		return printJSON(data)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func (c *cli) maintenancePurge(args []string) error {
+	fs := flag.NewFlagSet("maintenance purge", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would be removed without deleting")
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+
+	type purgeReport struct {
+		DryRun        bool     `json:"dry_run"`
+		OlderThan     string   `json:"older_than"`
+		SubmissionIDs []string `json:"submission_ids"`
+		Flyers        int64    `json:"flyers"`
+		Candidates    int64    `json:"candidates"`
+	}
+	var resp struct {
+		purgeReport
+		Blocked *purgeReport `json:"blocked"`
+	}
+	data, err := c.client.getJSON("POST", "/admin/api/maintenance/purge", url.Values{"dry_run": {strconv.FormatBool(*dryRun)}}, nil, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+
+	verb := "Purged"
+	if resp.DryRun {
+		verb = "Would purge"
+	}
+	fmt.Printf("%s %d failed submissions older than %s (%d flyers, %d candidates)\n",
+		verb, len(resp.SubmissionIDs), resp.OlderThan, resp.Flyers, resp.Candidates)
+	if blocked := resp.Blocked; blocked != nil {
+		fmt.Printf("%s %d blocked candidates older than %s (%d submissions, %d flyers with their images)\n",
+			verb, blocked.Candidates, blocked.OlderThan, len(blocked.SubmissionIDs), blocked.Flyers)
+	}
+	return nil
+}
+
+func (c *cli) maintenanceBackfillSearch(args []string) error {
+	fs := flag.NewFlagSet("maintenance backfill-search", flag.ContinueOnError)
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Scanned int `json:"scanned"`
+		Updated int `json:"updated"`
+	}
+	data, err := c.client.getJSON("POST", "/admin/api/maintenance/search-backfill", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+
+	fmt.Printf("Scanned %d candidates, updated search text on %d\n", resp.Scanned, resp.Updated)
+	return nil
+}
+
+func (c *cli) candidatesSearch(args []string) error {
+	fs := flag.NewFlagSet("candidates search", flag.ContinueOnError)
+	limit := fs.Int("limit", 50, "maximum candidates to list")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: candidates search <query> [--limit=50]")
+	}
+
+	query := url.Values{"q": {strings.Join(positional, " ")}, "limit": {strconv.Itoa(*limit)}}
+	var resp struct {
+		Candidates []struct {
+			ID            string  `json:"id"`
+			Title         string  `json:"title"`
+			Venue         string  `json:"venue"`
+			PublishResult *string `json:"publish_result"`
+			CreatedAt     string  `json:"created_at"`
+		} `json:"candidates"`
+	}
+	data, err := c.client.getJSON("GET", "/admin/api/candidates/search", query, nil, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+
+	table := newTable()
+	fmt.Fprintln(table, "ID\tTITLE\tVENUE\tRESULT\tCREATED")
+	for _, candidate := range resp.Candidates {
+		result := "-"
+		if candidate.PublishResult != nil {
+			result = *candidate.PublishResult
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", candidate.ID, candidate.Title, candidate.Venue, result, candidate.CreatedAt)
+	}
+	return table.Flush()
+}
+
+func (c *cli) adminSetupKey(args []string) error {
+	fs := flag.NewFlagSet("admin setup-key", flag.ContinueOnError)
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+
+	var resp struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	data, err := c.client.getJSON("POST", "/admin/setup-key", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(data)
+	}
+
+	fmt.Println(resp.Key)
+	fmt.Fprintf(os.Stderr, "Admin API key %s created. Store it now; it won't be shown again.\n", resp.ID)
+	fmt.Fprintln(os.Stderr, "Earlier generated keys are revoked; update WB_ADMIN_TOKEN to the new key.")
+	return nil
+}
+
+// adminGenerateKey makes a key for ADMIN_API_KEY_HASH without contacting the server, for
+// deployments that configure admin access before first start
+func (c *cli) adminGenerateKey(args []string) error {
+	fs := flag.NewFlagSet("admin generate-key", flag.ContinueOnError)
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	key := "wbk_" + base64.RawURLEncoding.EncodeToString(secret)
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		out, err := json.MarshalIndent(map[string]string{"key": key, "hash": string(hash)}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	fmt.Printf("Key (for WB_ADMIN_TOKEN and the dashboard): %s\n", key)
+	// Single quotes keep .env loaders from expanding the hash's $ signs
+	fmt.Printf("ADMIN_API_KEY_HASH='%s'\n", hash)
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSubcommands(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(*cli, []string) error
+		args []string
+		json bool
+
+		wantMethod string
+		wantPath   string
+		wantQuery  string
+		wantBody   map[string]string
+		response   string
+
+		wantOutput []string
+	}{
+		{
+			name:       "submissions list",
+			run:        (*cli).submissionsList,
+			args:       []string{"--status=error"},
+			wantMethod: "GET",
+			wantPath:   "/admin/api/submissions",
+			wantQuery:  "limit=50&status=error",
+			response:   `{"submissions":[{"id":"sub-1","status":"error","source":"upload","flyers":1,"candidates":0,"updated_at":"2026-05-01T10:00:00Z"}]}`,
+			wantOutput: []string{"ID", "STATUS", "sub-1", "error", "upload"},
+		},
+		{
+			name:       "submissions list as JSON",
+			run:        (*cli).submissionsList,
+			args:       []string{"--limit=5"},
+			json:       true,
+			wantMethod: "GET",
+			wantPath:   "/admin/api/submissions",
+			wantQuery:  "limit=5",
+			response:   `{"submissions":[{"id":"sub-1"}]}`,
+			wantOutput: []string{`"submissions": [`, `"id": "sub-1"`},
+		},
+		{
+			name:       "submissions retry",
+			run:        (*cli).submissionsRetry,
+			args:       []string{"sub-1"},
+			wantMethod: "POST",
+			wantPath:   "/admin/api/submissions/sub-1/retry",
+			response:   `{"submissionId":"sub-1","status":"done"}`,
+			wantOutput: []string{"Submission sub-1 retried: status done"},
+		},
+		{
+			name:       "events unpublish with the reason after the id",
+			run:        (*cli).eventsUnpublish,
+			args:       []string{"evt-1", "--reason=spam"},
+			wantMethod: "POST",
+			wantPath:   "/admin/api/events/evt-1/unpublish",
+			wantBody:   map[string]string{"reason": "spam"},
+			response:   `{"event_id":"evt-1","candidate_ids":["c-1","c-2"]}`,
+			wantOutput: []string{"Event evt-1 unpublished (spam); 2 linked candidates blocked"},
+		},
+		{
+			name:       "events delete",
+			run:        (*cli).eventsDelete,
+			args:       []string{"--reason=privacy", "--note=requested by organizer", "evt-1"},
+			wantMethod: "DELETE",
+			wantPath:   "/admin/events/evt-1",
+			wantBody:   map[string]string{"reason": "privacy", "note": "requested by organizer"},
+			response:   `{"event_id":"evt-1","candidate_ids":["c-1"],"cooldown_until":"2026-06-01T00:00:00Z"}`,
+			wantOutput: []string{"Event evt-1 deleted (privacy); 1 linked candidates blocked; no auto-publishing until 2026-06-01T00:00:00Z"},
+		},
+		{
+			name:       "venues merge",
+			run:        (*cli).venuesMerge,
+			args:       []string{"dup-1", "canon-1"},
+			wantMethod: "POST",
+			wantPath:   "/admin/api/venues/dup-1/merge",
+			wantBody:   map[string]string{"into": "canon-1"},
+			response:   `{"repointed_events":["e-1","e-2","e-3"]}`,
+			wantOutput: []string{"Venue dup-1 merged into canon-1; 3 events re-pointed"},
+		},
+		{
+			name:       "export events as CSV",
+			run:        (*cli).exportEvents,
+			args:       []string{"--start=2026-05-01", "--end=2026-06-01"},
+			wantMethod: "GET",
+			wantPath:   "/admin/api/export/events",
+			wantQuery:  "end=2026-06-01&format=csv&start=2026-05-01",
+			response:   "id,title\nevt-1,Open Mic\n",
+			wantOutput: []string{"id,title\nevt-1,Open Mic\n"},
+		},
+		{
+			name:       "export events with --json asks for JSON",
+			run:        (*cli).exportEvents,
+			json:       true,
+			wantMethod: "GET",
+			wantPath:   "/admin/api/export/events",
+			wantQuery:  "format=json",
+			response:   `[{"id":"evt-1"}]`,
+			wantOutput: []string{`"id": "evt-1"`},
+		},
+		{
+			name:       "maintenance purge dry run",
+			run:        (*cli).maintenancePurge,
+			args:       []string{"--dry-run"},
+			wantMethod: "POST",
+			wantPath:   "/admin/api/maintenance/purge",
+			wantQuery:  "dry_run=true",
+			response: `{"dry_run":true,"older_than":"2026-04-01","submission_ids":["s-1","s-2"],"flyers":2,"candidates":3,
+				"blocked":{"dry_run":true,"older_than":"2026-03-01","submission_ids":["s-3"],"flyers":1,"candidates":4}}`,
+			wantOutput: []string{
+				"Would purge 2 failed submissions older than 2026-04-01 (2 flyers, 3 candidates)",
+				"Would purge 4 blocked candidates older than 2026-03-01 (1 submissions, 1 flyers with their images)",
+			},
+		},
+		{
+			name:       "candidates search",
+			run:        (*cli).candidatesSearch,
+			args:       []string{"open", "mic", "--limit=10"},
+			wantMethod: "GET",
+			wantPath:   "/admin/api/candidates/search",
+			wantQuery:  "limit=10&q=open+mic",
+			response:   `{"candidates":[{"id":"c-1","title":"Open Mic","venue":"Library","publish_result":null,"created_at":"2026-05-01"}]}`,
+			wantOutput: []string{"TITLE", "Open Mic", "Library", "-"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != tt.wantMethod || r.URL.Path != tt.wantPath || r.URL.RawQuery != tt.wantQuery {
+					t.Errorf("request = %s %s?%s, want %s %s?%s",
+						r.Method, r.URL.Path, r.URL.RawQuery, tt.wantMethod, tt.wantPath, tt.wantQuery)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer wbk_test" {
+					t.Errorf("Authorization = %q, want the admin token", got)
+				}
+				if got := r.Header.Get("X-Admin-Id"); got != "alice" {
+					t.Errorf("X-Admin-Id = %q, want alice", got)
+				}
+				if tt.wantBody != nil {
+					var body map[string]string
+					if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+						t.Errorf("decode request body: %v", err)
+					}
+					for key, want := range tt.wantBody {
+						if body[key] != want {
+							t.Errorf("body[%q] = %q, want %q", key, body[key], want)
+						}
+					}
+				}
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			c := &cli{client: newClient(server.URL+"/", "wbk_test", "alice"), json: tt.json}
+			output := captureStdout(t, func() error { return tt.run(c, tt.args) })
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(output, want) {
+					t.Errorf("output missing %q:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestSubcommandUsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(*cli, []string) error
+		args []string
+	}{
+		{"retry without an id", (*cli).submissionsRetry, nil},
+		{"unpublish without a reason", (*cli).eventsUnpublish, []string{"evt-1"}},
+		{"delete without a reason", (*cli).eventsDelete, []string{"evt-1"}},
+		{"merge with one venue", (*cli).venuesMerge, []string{"dup-1"}},
+		{"export in an unknown format", (*cli).exportEvents, []string{"--format=xml"}},
+		{"search without a query", (*cli).candidatesSearch, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Usage errors are caught before any request is sent
+			c := &cli{client: newClient("http://127.0.0.1:0", "", "")}
+			if err := tt.run(c, tt.args); err == nil {
+				t.Error("error = nil, want a usage error")
+			}
+		})
+	}
+}
+
+func TestClientReportsAPIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"structured error", http.StatusNotFound, `{"error":"submission not found"}`, "POST /admin/api/submissions/sub-1/retry: 404: submission not found"},
+		{"bare status", http.StatusBadGateway, `upstream down`, "POST /admin/api/submissions/sub-1/retry: 502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			c := &cli{client: newClient(server.URL, "", "")}
+			err := c.submissionsRetry([]string{"sub-1"})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractJSONFlag(t *testing.T) {
+	args, found := extractJSONFlag([]string{"submissions", "--json", "list", "--status=error"})
+	if !found || strings.Join(args, " ") != "submissions list --status=error" {
+		t.Errorf("extractJSONFlag() = %q, %v", args, found)
+	}
+	if _, found := extractJSONFlag([]string{"submissions", "list"}); found {
+		t.Error("extractJSONFlag() found --json where there is none")
+	}
+}
+
+// captureStdout returns what run prints to standard output
+func captureStdout(t *testing.T, run func() error) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	runErr := run()
+	w.Close()
+	output := <-done
+	if runErr != nil {
+		t.Fatalf("run: %v", runErr)
+	}
+	return output
+}
@@ -4,9 +4,11 @@ import { useState } from 'react'
 import { FileUpload } from '../components/FileUpload'
 import { EventMap } from '../components/EventMap'
 import { EventList } from '../components/EventList'
+import { useLocale } from '../lib/i18n'
 
 export default function Home() {
   const [activeTab, setActiveTab] = useState<'upload' | 'map' | 'events'>('upload')
+  const { t } = useLocale()
 
   return (
     <div className="min-h-screen bg-gradient-to-br from-blue-50 to-indigo-100">
@@ -15,7 +17,7 @@ export default function Home() {
           <div className="flex justify-between items-center py-4">
             <div className="flex items-center">
               <h1 className="text-2xl font-bold text-gray-900">WilliamBoard</h1>
-              <span className="ml-2 text-sm text-gray-600">Event Discovery</span>
+              <span className="ml-2 text-sm text-gray-600">{t('app.tagline')}</span>
             </div>
             <nav className="flex space-x-4">
               <button
@@ -26,7 +28,7 @@ export default function Home() {
                     : 'text-gray-600 hover:text-gray-900'
                 }`}
               >
-                Upload
+                {t('nav.upload')}
               </button>
               <button
                 onClick={() => setActiveTab('map')}
@@ -36,7 +38,7 @@ export default function Home() {
                     : 'text-gray-600 hover:text-gray-900'
                 }`}
               >
-                Map
+                {t('nav.map')}
               </button>
               <button
                 onClick={() => setActiveTab('events')}
@@ -46,7 +48,7 @@ export default function Home() {
                     : 'text-gray-600 hover:text-gray-900'
                 }`}
               >
-                Events
+                {t('nav.events')}
               </button>
             </nav>
           </div>
@@ -58,10 +60,10 @@ export default function Home() {
           <div className="space-y-8">
             <div className="text-center">
               <h2 className="text-3xl font-bold text-gray-900">
-                Discover Events from Bulletin Boards
+                {t('page.upload.title')}
               </h2>
               <p className="mt-4 text-lg text-gray-600">
-                Upload a photo of a bulletin board and we&apos;ll extract event information using AI
+                {t('page.upload.subtitle')}
               </p>
             </div>
             <FileUpload />
@@ -71,9 +73,9 @@ export default function Home() {
         {activeTab === 'map' && (
           <div className="space-y-8">
             <div className="text-center">
-              <h2 className="text-3xl font-bold text-gray-900">Event Map</h2>
+              <h2 className="text-3xl font-bold text-gray-900">{t('page.map.title')}</h2>
               <p className="mt-4 text-lg text-gray-600">
-                Explore events on the map
+                {t('page.map.subtitle')}
               </p>
             </div>
             <EventMap />
@@ -83,9 +85,9 @@ export default function Home() {
         {activeTab === 'events' && (
           <div className="space-y-8">
             <div className="text-center">
-              <h2 className="text-3xl font-bold text-gray-900">All Events</h2>
+              <h2 className="text-3xl font-bold text-gray-900">{t('page.events.title')}</h2>
               <p className="mt-4 text-lg text-gray-600">
-                Browse all discovered events
+                {t('page.events.subtitle')}
               </p>
             </div>
             <EventList />
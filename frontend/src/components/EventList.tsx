@@ -1,12 +1,14 @@
 'use client'
 
 import { useState, useEffect } from 'react'
+import { formatEventDate, useLocale } from '../lib/i18n'
 
 interface Event {
   id: string
   title: string
   start_ts: string
   end_ts?: string
+  happening_now?: boolean
   venue_name?: string
   address?: string
   description?: string
@@ -19,13 +21,14 @@ interface Event {
 export function EventList() {
   const [allEvents, setAllEvents] = useState<Event[]>([])
   const [loading, setLoading] = useState(true)
+  const { locale, t } = useLocale()
 
   useEffect(() => {
     const fetchEvents = async () => {
       try {
         // Call backend directly and get all events (upcoming and past)
         const apiBaseUrl = process.env.NEXT_PUBLIC_API_BASE_URL || 'http://localhost:8080'
-        const response = await fetch(`${apiBaseUrl}/v1/events?limit=500&include_past=true`)
+        const response = await fetch(`${apiBaseUrl}/v1/events?limit=500&include_past=true&include_unlocated=true`)
         if (response.ok) {
           const data = await response.json()
           const eventData = data.features?.map((feature: any) => ({
@@ -33,6 +36,7 @@ export function EventList() {
             title: feature.properties.title,
             start_ts: feature.properties.start_ts,
             end_ts: feature.properties.end_ts,
+            happening_now: feature.properties.happening_now,
             venue_name: feature.properties.venue_name,
             address: feature.properties.address,
             description: feature.properties.description,
@@ -53,10 +57,11 @@ export function EventList() {
     fetchEvents()
   }, [])
 
-  // Group events into upcoming and past
+  // Group events into upcoming and past; events already underway stay upcoming
   const now = new Date()
-  const upcomingEvents = allEvents.filter(event => new Date(event.start_ts) >= now)
-  const pastEvents = allEvents.filter(event => new Date(event.start_ts) < now)
+  const isUpcoming = (event: Event) => event.happening_now || new Date(event.start_ts) >= now
+  const upcomingEvents = allEvents.filter(isUpcoming)
+  const pastEvents = allEvents.filter(event => !isUpcoming(event))
 
   // Sort events by date
   upcomingEvents.sort((a, b) => new Date(a.start_ts).getTime() - new Date(b.start_ts).getTime())
@@ -82,17 +87,7 @@ export function EventList() {
     }
   }
 
-  const formatDate = (dateString: string) => {
-    const date = new Date(dateString)
-    return date.toLocaleDateString('en-US', {
-      weekday: 'short',
-      year: 'numeric',
-      month: 'short',
-      day: 'numeric',
-      hour: 'numeric',
-      minute: '2-digit',
-    })
-  }
+  const formatDate = (dateString: string) => formatEventDate(locale, dateString)
 
   const renderEventCard = (event: Event) => (
     <div
@@ -165,7 +160,7 @@ export function EventList() {
           <svg className="w-4 h-4 mr-1" fill="none" stroke="currentColor" viewBox="0 0 24 24">
             <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M12 10v6m0 0l-3-3m3 3l3-3m2 8H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z" />
           </svg>
-          Add to Calendar
+          {t('events.addToCalendar')}
         </button>
         {event.url && (
           <a 
@@ -177,7 +172,7 @@ export function EventList() {
             <svg className="w-4 h-4 mr-1" fill="none" stroke="currentColor" viewBox="0 0 24 24">
               <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M10 6H6a2 2 0 00-2 2v10a2 2 0 002 2h10a2 2 0 002-2v-4M14 4h6m0 0v6m0-6L10 14" />
             </svg>
-            View Details
+            {t('events.viewDetails')}
           </a>
         )}
       </div>
@@ -208,9 +203,9 @@ export function EventList() {
             d="M8 7V3m8 4V3m-9 8h10M5 21h14a2 2 0 002-2V7a2 2 0 00-2-2H5a2 2 0 00-2 2v12a2 2 0 002 2z"
           />
         </svg>
-        <h3 className="text-lg font-medium text-gray-900 mt-4">No Events Found</h3>
+        <h3 className="text-lg font-medium text-gray-900 mt-4">{t('events.noneTitle')}</h3>
         <p className="text-gray-600 mt-2">
-          Upload some bulletin board images to discover events!
+          {t('events.noneBody')}
         </p>
       </div>
     )
@@ -222,7 +217,7 @@ export function EventList() {
       {upcomingEvents.length > 0 && (
         <div>
           <div className="flex items-center mb-6">
-            <h2 className="text-2xl font-bold text-gray-900">Upcoming Events</h2>
+            <h2 className="text-2xl font-bold text-gray-900">{t('events.upcoming')}</h2>
             <span className="ml-3 bg-blue-100 text-blue-800 text-sm font-medium px-3 py-1 rounded-full">
               {upcomingEvents.length}
             </span>
@@ -237,7 +232,7 @@ export function EventList() {
       {pastEvents.length > 0 && (
         <div>
           <div className="flex items-center mb-6">
-            <h2 className="text-2xl font-bold text-gray-900">Past Events</h2>
+            <h2 className="text-2xl font-bold text-gray-900">{t('events.past')}</h2>
             <span className="ml-3 bg-gray-100 text-gray-800 text-sm font-medium px-3 py-1 rounded-full">
               {pastEvents.length}
             </span>
@@ -248,7 +243,7 @@ export function EventList() {
           {pastEvents.length > 12 && (
             <div className="text-center mt-6">
               <button className="text-gray-600 hover:text-gray-800 text-sm font-medium">
-                Show {pastEvents.length - 12} more past events
+                {t('events.showMorePast', { count: pastEvents.length - 12 })}
               </button>
             </div>
           )}
@@ -271,9 +266,9 @@ export function EventList() {
               d="M8 7V3m8 4V3m-9 8h10M5 21h14a2 2 0 002-2V7a2 2 0 00-2-2H5a2 2 0 00-2 2v12a2 2 0 002 2z"
             />
           </svg>
-          <h3 className="text-lg font-medium text-gray-900 mt-4">No Upcoming Events</h3>
+          <h3 className="text-lg font-medium text-gray-900 mt-4">{t('events.noUpcomingTitle')}</h3>
           <p className="text-gray-600 mt-2">
-            Check back soon for new events, or upload bulletin board images to discover more!
+            {t('events.noUpcomingBody')}
           </p>
         </div>
       )}
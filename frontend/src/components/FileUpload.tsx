@@ -2,6 +2,7 @@
 
 import { useState, useCallback } from 'react'
 import { useDropzone } from 'react-dropzone'
+import { useLocale } from '../lib/i18n'
 
 interface UploadStatus {
   submissionId?: string
@@ -12,12 +13,13 @@ interface UploadStatus {
 
 export function FileUpload() {
   const [uploadStatus, setUploadStatus] = useState<UploadStatus>({ status: 'idle' })
+  const { locale, t } = useLocale()
 
   const onDrop = useCallback(async (acceptedFiles: File[]) => {
     if (acceptedFiles.length === 0) return
 
     const file = acceptedFiles[0]
-    setUploadStatus({ status: 'uploading', message: 'Preparing upload...' })
+    setUploadStatus({ status: 'uploading', message: t('upload.preparing') })
 
     try {
       // Step 1: Get signed URL - call backend directly
@@ -29,14 +31,14 @@ export function FileUpload() {
       })
 
       if (!signedUrlResponse.ok) {
-        throw new Error('Failed to get upload URL')
+        throw new Error(t('upload.failedUrl'))
       }
 
       const { uploadUrl, submissionId } = await signedUrlResponse.json()
 
       setUploadStatus({
         status: 'uploading',
-        message: 'Processing with AI...',
+        message: t('upload.processing'),
         submissionId,
       })
 
@@ -52,14 +54,14 @@ export function FileUpload() {
       })
 
       if (!uploadResponse.ok) {
-        throw new Error('Upload failed')
+        throw new Error(t('upload.failed'))
       }
 
       const result = await uploadResponse.json()
       
       setUploadStatus({
         status: 'completed',
-        message: `Processing complete! Found ${result.eventsFound} events.`,
+        message: t('upload.complete', { count: result.eventsFound }),
         submissionId,
         eventsFound: result.eventsFound,
       })
@@ -68,10 +70,10 @@ export function FileUpload() {
       console.error('Upload error:', error)
       setUploadStatus({
         status: 'error',
-        message: error instanceof Error ? error.message : 'Upload failed',
+        message: error instanceof Error ? error.message : t('upload.failed'),
       })
     }
-  }, [])
+  }, [locale]) // eslint-disable-line react-hooks/exhaustive-deps
 
   const { getRootProps, getInputProps, isDragActive } = useDropzone({
     onDrop,
@@ -116,10 +118,10 @@ export function FileUpload() {
             </svg>
             <p className="mt-2 text-sm text-gray-600">
               {isDragActive
-                ? 'Drop the bulletin board image here'
-                : 'Drop a bulletin board image here, or click to select'}
+                ? t('upload.dropActive')
+                : t('upload.dropIdle')}
             </p>
-            <p className="text-xs text-gray-500">PNG, JPG, WEBP up to 12MB</p>
+            <p className="text-xs text-gray-500">{t('upload.formats')}</p>
           </>
         )}
 
@@ -133,7 +135,7 @@ export function FileUpload() {
         {uploadStatus.status === 'processing' && (
           <>
             <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-blue-600 mx-auto"></div>
-            <p className="mt-2 text-sm text-gray-600">Processing with AI...</p>
+            <p className="mt-2 text-sm text-gray-600">{t('upload.processing')}</p>
           </>
         )}
 
@@ -155,13 +157,13 @@ export function FileUpload() {
             <p className="mt-2 text-sm text-green-600">{uploadStatus.message}</p>
             {uploadStatus.submissionId && (
               <div className="mt-4 p-4 bg-green-50 rounded-lg">
-                <h3 className="font-medium text-green-800">Success!</h3>
+                <h3 className="font-medium text-green-800">{t('upload.success')}</h3>
                 <p className="text-sm text-green-700 mt-1">
-                  Submission ID: {uploadStatus.submissionId}
+                  {t('upload.submissionId', { id: uploadStatus.submissionId })}
                 </p>
                 {uploadStatus.eventsFound && uploadStatus.eventsFound > 0 && (
                   <p className="text-sm text-green-700">
-                    Found {uploadStatus.eventsFound} events in your image.
+                    {t('upload.found', { count: uploadStatus.eventsFound })}
                   </p>
                 )}
               </div>
@@ -170,7 +172,7 @@ export function FileUpload() {
               onClick={resetUpload}
               className="mt-4 px-4 py-2 bg-blue-600 text-white rounded-md hover:bg-blue-700 transition-colors"
             >
-              Upload Another Image
+              {t('upload.another')}
             </button>
           </>
         )}
@@ -195,7 +197,7 @@ export function FileUpload() {
               onClick={resetUpload}
               className="mt-4 px-4 py-2 bg-blue-600 text-white rounded-md hover:bg-blue-700 transition-colors"
             >
-              Try Again
+              {t('upload.retry')}
             </button>
           </>
         )}
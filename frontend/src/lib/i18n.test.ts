@@ -0,0 +1,57 @@
+import { describe, it } from 'node:test'
+import assert from 'node:assert/strict'
+
+import { formatEventDate, locales, messages, resolveLocale, translate, type MessageKey } from './i18n.ts'
+
+const keys = Object.keys(messages.en) as MessageKey[]
+const vars = { count: 3, id: 'sub-1' }
+
+describe('translate', () => {
+  for (const locale of locales) {
+    it(`renders every ${locale} message without leaking keys or placeholders`, () => {
+      assert.deepEqual(Object.keys(messages[locale]).sort(), [...keys].sort())
+      for (const key of keys) {
+        const text = translate(locale, key, vars)
+        assert.ok(text.trim() !== '', `${locale} ${key} is empty`)
+        assert.notEqual(text, key, `${locale} ${key} renders its own key`)
+        assert.doesNotMatch(text, /\{\w+\}/, `${locale} ${key} leaves a placeholder: ${text}`)
+      }
+    })
+  }
+
+  it('fills placeholders in each locale', () => {
+    assert.equal(translate('en', 'upload.complete', vars), 'Processing complete! Found 3 events.')
+    assert.equal(translate('es', 'upload.complete', vars), '¡Listo! Se encontraron 3 eventos.')
+    assert.equal(translate('es', 'upload.submissionId', vars), 'ID de envío: sub-1')
+  })
+
+  it('keeps a placeholder it was given no value for', () => {
+    assert.equal(translate('en', 'upload.found'), 'Found {count} events in your image.')
+  })
+})
+
+describe('resolveLocale', () => {
+  const cases: { name: string; search: string; languages: string[]; want: string }[] = [
+    { name: 'lang parameter', search: '?lang=es', languages: ['en-US'], want: 'es' },
+    { name: 'lang parameter in capitals', search: '?lang=ES', languages: [], want: 'es' },
+    { name: 'unsupported lang parameter falls back to the browser', search: '?lang=fr', languages: ['es-MX'], want: 'es' },
+    { name: 'first supported browser language', search: '', languages: ['fr-FR', 'es-419', 'en'], want: 'es' },
+    { name: 'no supported language', search: '', languages: ['fr-FR', 'de'], want: 'en' },
+    { name: 'nothing to go on', search: '', languages: [], want: 'en' },
+  ]
+  for (const tc of cases) {
+    it(tc.name, () => {
+      assert.equal(resolveLocale(tc.search, tc.languages), tc.want)
+    })
+  }
+})
+
+describe('formatEventDate', () => {
+  // Midday UTC stays on the same calendar day in nearly every time zone
+  const friday = '2026-06-12T12:00:00Z'
+
+  it('uses each locale\'s own names for days and months', () => {
+    assert.match(formatEventDate('en', friday), /Fri.*Jun/)
+    assert.match(formatEventDate('es', friday), /vie.*jun/)
+  })
+})
@@ -0,0 +1,139 @@
+'use client'
+
+import { useEffect, useState } from 'react'
+
+// Locales the public pages are translated into; English is the fallback
+export const locales = ['en', 'es'] as const
+export type Locale = (typeof locales)[number]
+export const defaultLocale: Locale = 'en'
+
+const en = {
+  'nav.upload': 'Upload',
+  'nav.map': 'Map',
+  'nav.events': 'Events',
+  'app.tagline': 'Event Discovery',
+  'page.upload.title': 'Discover Events from Bulletin Boards',
+  'page.upload.subtitle': "Upload a photo of a bulletin board and we'll extract event information using AI",
+  'page.map.title': 'Event Map',
+  'page.map.subtitle': 'Explore events on the map',
+  'page.events.title': 'All Events',
+  'page.events.subtitle': 'Browse all discovered events',
+
+  'upload.preparing': 'Preparing upload...',
+  'upload.processing': 'Processing with AI...',
+  'upload.complete': 'Processing complete! Found {count} events.',
+  'upload.failedUrl': 'Failed to get upload URL',
+  'upload.failed': 'Upload failed',
+  'upload.dropActive': 'Drop the bulletin board image here',
+  'upload.dropIdle': 'Drop a bulletin board image here, or click to select',
+  'upload.formats': 'PNG, JPG, WEBP up to 12MB',
+  'upload.success': 'Success!',
+  'upload.submissionId': 'Submission ID: {id}',
+  'upload.found': 'Found {count} events in your image.',
+  'upload.another': 'Upload Another Image',
+  'upload.retry': 'Try Again',
+
+  'events.addToCalendar': 'Add to Calendar',
+  'events.viewDetails': 'View Details',
+  'events.noneTitle': 'No Events Found',
+  'events.noneBody': 'Upload some bulletin board images to discover events!',
+  'events.upcoming': 'Upcoming Events',
+  'events.past': 'Past Events',
+  'events.showMorePast': 'Show {count} more past events',
+  'events.noUpcomingTitle': 'No Upcoming Events',
+  'events.noUpcomingBody': 'Check back soon for new events, or upload bulletin board images to discover more!',
+}
+
+export type MessageKey = keyof typeof en
+
+const es: Record<MessageKey, string> = {
+  'nav.upload': 'Subir',
+  'nav.map': 'Mapa',
+  'nav.events': 'Eventos',
+  'app.tagline': 'Descubre eventos',
+  'page.upload.title': 'Descubre eventos en los tablones de anuncios',
+  'page.upload.subtitle': 'Sube una foto de un tablón de anuncios y extraeremos la información de los eventos con IA',
+  'page.map.title': 'Mapa de eventos',
+  'page.map.subtitle': 'Explora los eventos en el mapa',
+  'page.events.title': 'Todos los eventos',
+  'page.events.subtitle': 'Explora todos los eventos descubiertos',
+
+  'upload.preparing': 'Preparando la subida...',
+  'upload.processing': 'Procesando con IA...',
+  'upload.complete': '¡Listo! Se encontraron {count} eventos.',
+  'upload.failedUrl': 'No se pudo obtener la URL de subida',
+  'upload.failed': 'La subida falló',
+  'upload.dropActive': 'Suelta aquí la imagen del tablón',
+  'upload.dropIdle': 'Suelta aquí una imagen de un tablón de anuncios, o haz clic para elegirla',
+  'upload.formats': 'PNG, JPG, WEBP de hasta 12 MB',
+  'upload.success': '¡Éxito!',
+  'upload.submissionId': 'ID de envío: {id}',
+  'upload.found': 'Se encontraron {count} eventos en tu imagen.',
+  'upload.another': 'Subir otra imagen',
+  'upload.retry': 'Intentar de nuevo',
+
+  'events.addToCalendar': 'Añadir al calendario',
+  'events.viewDetails': 'Ver detalles',
+  'events.noneTitle': 'No se encontraron eventos',
+  'events.noneBody': '¡Sube fotos de tablones de anuncios para descubrir eventos!',
+  'events.upcoming': 'Próximos eventos',
+  'events.past': 'Eventos pasados',
+  'events.showMorePast': 'Mostrar {count} eventos pasados más',
+  'events.noUpcomingTitle': 'No hay próximos eventos',
+  'events.noUpcomingBody': 'Vuelve pronto para ver eventos nuevos, o sube fotos de tablones para descubrir más.',
+}
+
+export const messages: Record<Locale, Record<MessageKey, string>> = { en, es }
+
+function isLocale(value: string): value is Locale {
+  return (locales as readonly string[]).includes(value)
+}
+
+// resolveLocale picks ?lang= when it names a supported locale, then the first supported
+// browser language, then English
+export function resolveLocale(search: string, languages: readonly string[]): Locale {
+  const requested = new URLSearchParams(search).get('lang')?.toLowerCase()
+  if (requested && isLocale(requested)) {
+    return requested
+  }
+  for (const language of languages) {
+    const base = language.toLowerCase().split('-')[0]
+    if (isLocale(base)) {
+      return base
+    }
+  }
+  return defaultLocale
+}
+
+// translate looks key up in locale's bundle and fills {name} placeholders from vars
+export function translate(locale: Locale, key: MessageKey, vars: Record<string, string | number> = {}): string {
+  const template = messages[locale][key] ?? messages[defaultLocale][key]
+  return template.replace(/\{(\w+)\}/g, (match, name) => (name in vars ? String(vars[name]) : match))
+}
+
+// formatEventDate formats an event time in the locale's own date and time conventions
+export function formatEventDate(locale: Locale, value: string): string {
+  return new Date(value).toLocaleString(locale, {
+    weekday: 'short',
+    year: 'numeric',
+    month: 'short',
+    day: 'numeric',
+    hour: 'numeric',
+    minute: '2-digit',
+  })
+}
+
+// useLocale resolves the visitor's locale once mounted (English while rendering on the
+// server) and returns it with a bound translate
+export function useLocale() {
+  const [locale, setLocale] = useState<Locale>(defaultLocale)
+
+  useEffect(() => {
+    const resolved = resolveLocale(window.location.search, navigator.languages ?? [navigator.language])
+    setLocale(resolved)
+    document.documentElement.lang = resolved
+  }, [])
+
+  const t = (key: MessageKey, vars?: Record<string, string | number>) => translate(locale, key, vars)
+  return { locale, t }
+}
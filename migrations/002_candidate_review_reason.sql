@@ -0,0 +1,4 @@
+-- Structured reason for candidates routed to needs_review
+ALTER TABLE event_candidates ADD COLUMN IF NOT EXISTS review_reason VARCHAR(50) NULL; -- low_score, needs_enrichment
+
+CREATE INDEX IF NOT EXISTS idx_event_candidates_review_reason ON event_candidates(review_reason);
@@ -0,0 +1,15 @@
+-- Append-only change feed for downstream mirrors (GET /v1/events/changes)
+CREATE TABLE IF NOT EXISTS event_changes (
+    seq BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    change_type VARCHAR(50) NOT NULL, -- created, updated, deleted
+    reason VARCHAR(100) NULL,          -- venue_merged, venue_deleted, venue_orphaned, ...
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_changes_event_id ON event_changes(event_id);
+
+-- Venue deletion must go through the admin endpoints, which re-point events first
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_venue_id_fkey;
+ALTER TABLE events ADD CONSTRAINT events_venue_id_fkey
+    FOREIGN KEY (venue_id) REFERENCES venues(id) ON DELETE RESTRICT;
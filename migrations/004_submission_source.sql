@@ -0,0 +1,4 @@
+-- How a submission entered the system: photo upload or typed-in manual entry
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS source VARCHAR(50) NOT NULL DEFAULT 'upload'; -- upload, manual
+
+CREATE INDEX IF NOT EXISTS idx_submissions_source ON submissions(source);
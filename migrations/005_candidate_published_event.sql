@@ -0,0 +1,4 @@
+-- Link each published candidate to the public event it created or corroborated
+ALTER TABLE event_candidates ADD COLUMN IF NOT EXISTS published_event_id UUID NULL REFERENCES events(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_event_candidates_published_event_id ON event_candidates(published_event_id);
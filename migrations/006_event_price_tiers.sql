@@ -0,0 +1,6 @@
+-- Structured price tiers parsed from flyer pricing, with min/max summary columns
+ALTER TABLE events ADD COLUMN IF NOT EXISTS price_tiers JSONB NULL; -- [{label, amount_cents, url}]
+ALTER TABLE events ADD COLUMN IF NOT EXISTS price_min_cents INTEGER NULL;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS price_max_cents INTEGER NULL;
+
+CREATE INDEX IF NOT EXISTS idx_events_price_min_cents ON events(price_min_cents);
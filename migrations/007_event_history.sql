@@ -0,0 +1,16 @@
+-- Append-only history of event states for "what did the board show on <date>" queries
+CREATE TABLE IF NOT EXISTS event_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    valid_to TIMESTAMP WITH TIME ZONE NULL, -- NULL for the current version
+    state JSONB NOT NULL                    -- full serialized event
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_history_event_id ON event_history(event_id);
+CREATE INDEX IF NOT EXISTS idx_event_history_validity ON event_history(valid_from, valid_to);
+
+-- Seed current state so as_of queries cover events published before history existed
+INSERT INTO event_history (event_id, valid_from, state)
+SELECT e.id, e.updated_at, to_jsonb(e) FROM events e
+WHERE NOT EXISTS (SELECT 1 FROM event_history h WHERE h.event_id = e.id);
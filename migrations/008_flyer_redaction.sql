@@ -0,0 +1,4 @@
+-- Redacted flyer crops: the redacted variant is the only flyer image served publicly
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS public_image_url VARCHAR(500) NULL;
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS redactions JSONB NULL; -- [{x, y, width, height}] in crop coordinates
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS redacted_at TIMESTAMP WITH TIME ZONE NULL;
@@ -0,0 +1,19 @@
+-- Every moderation_state change of an event, with who made it and why
+CREATE TABLE IF NOT EXISTS event_state_transitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    from_state VARCHAR(50) NULL, -- NULL when the event was created
+    to_state VARCHAR(50) NOT NULL,
+    actor_type VARCHAR(50) NOT NULL, -- auto, admin, api, system
+    reason_code VARCHAR(100) NOT NULL,
+    candidate_id UUID NULL REFERENCES event_candidates(id) ON DELETE SET NULL,
+    flag_id UUID NULL REFERENCES flags(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_state_transitions_event_id ON event_state_transitions(event_id, created_at);
+
+-- Existing events get a synthetic creation row so every history starts somewhere
+INSERT INTO event_state_transitions (event_id, to_state, actor_type, reason_code, created_at)
+SELECT e.id, e.moderation_state, 'system', 'backfilled', e.created_at FROM events e
+WHERE NOT EXISTS (SELECT 1 FROM event_state_transitions t WHERE t.event_id = e.id);
@@ -0,0 +1,2 @@
+-- Public extra fields declared by a deployment's extraction field schema (EXTRACTION_FIELDS_FILE)
+ALTER TABLE events ADD COLUMN IF NOT EXISTS attributes JSONB NULL;
@@ -0,0 +1,5 @@
+-- Stage 3 retry queue for transient moderation/geocoding failures
+ALTER TABLE event_candidates ADD COLUMN IF NOT EXISTS retry_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE event_candidates ADD COLUMN IF NOT EXISTS retry_after TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE event_candidates ADD COLUMN IF NOT EXISTS last_retry_error TEXT NULL;
+CREATE INDEX IF NOT EXISTS idx_event_candidates_retry_after ON event_candidates (retry_after) WHERE retry_after IS NOT NULL;
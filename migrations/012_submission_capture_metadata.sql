@@ -0,0 +1,8 @@
+-- Capture metadata: client-reported values (captured_at is reused for the client's time)
+-- and the EXIF values they take precedence over
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS capture_latitude DOUBLE PRECISION NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS capture_longitude DOUBLE PRECISION NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS device_orientation VARCHAR(30) NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS exif_captured_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS exif_latitude DOUBLE PRECISION NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS exif_longitude DOUBLE PRECISION NULL;
@@ -0,0 +1,2 @@
+-- Quiet events are listed and mapped but kept out of distribution surfaces
+ALTER TABLE events ADD COLUMN IF NOT EXISTS quiet BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,20 @@
+-- Who performed each audited action, through which route, and (for candidate decisions)
+-- how long after extraction the decision came
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_type VARCHAR(20) NOT NULL DEFAULT 'system';
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS admin_id VARCHAR(100) NULL;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS route VARCHAR(200) NULL;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS latency_ms BIGINT NULL;
+
+-- Older rows carried the actor type in metadata; admin actions predate per-moderator ids
+UPDATE audit_logs SET actor_type = metadata->>'decided_by'
+WHERE metadata->>'decided_by' IN ('auto', 'admin', 'api', 'system');
+UPDATE audit_logs SET admin_id = 'admin' WHERE actor_type = 'admin' AND admin_id IS NULL;
+
+UPDATE audit_logs SET latency_ms = (EXTRACT(EPOCH FROM (audit_logs.created_at - event_candidates.created_at)) * 1000)::BIGINT
+FROM event_candidates
+WHERE audit_logs.entity_type = 'event_candidate'
+  AND audit_logs.entity_id = event_candidates.id
+  AND audit_logs.action IN ('candidate.published', 'candidate.blocked')
+  AND audit_logs.latency_ms IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_admin_created ON audit_logs (admin_id, created_at) WHERE admin_id IS NOT NULL;
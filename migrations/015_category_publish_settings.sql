@@ -0,0 +1,10 @@
+-- Per-category overrides of the auto-publish threshold and start window
+CREATE TABLE IF NOT EXISTS category_publish_settings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    category VARCHAR(50) NOT NULL UNIQUE,
+    auto_publish_threshold DOUBLE PRECISION NULL CHECK (auto_publish_threshold BETWEEN 0 AND 1),
+    min_start_offset_min INTEGER NULL,
+    max_start_offset_days INTEGER NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,23 @@
+-- Transactional outbox: side effects (webhooks, notifications, change feed) are written in the
+-- same transaction as the state change, one row per sink, and delivered by a background dispatcher
+CREATE TABLE IF NOT EXISTS outbox_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sink VARCHAR(50) NOT NULL,             -- changefeed, webhook, notifier
+    topic VARCHAR(100) NOT NULL,           -- event.changed, submission.done, candidate.needs_review
+    aggregate_id UUID NOT NULL,
+    idempotency_key VARCHAR(100) NOT NULL, -- shared by every sink's copy of one message
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, delivered, dead
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_entries_sink_key ON outbox_entries(sink, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_outbox_entries_due ON outbox_entries(status, next_attempt_at);
+
+-- The change-feed sink appends at most one entry per outbox row, even if delivery is repeated
+ALTER TABLE event_changes ADD COLUMN IF NOT EXISTS outbox_id UUID NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_changes_outbox_id ON event_changes(outbox_id);
@@ -0,0 +1,3 @@
+-- Dimensions of the stored original after its EXIF orientation has been applied
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS image_width INTEGER NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS image_height INTEGER NULL;
@@ -0,0 +1,8 @@
+-- Vision model experiment assignment (sticky per submission) and token usage for cost tracking
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS experiment_name VARCHAR(100) NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS experiment_variant VARCHAR(100) NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS vision_model VARCHAR(100) NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS vision_input_tokens INTEGER NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS vision_output_tokens INTEGER NULL;
+
+CREATE INDEX IF NOT EXISTS idx_submissions_experiment_name ON submissions(experiment_name);
@@ -0,0 +1,16 @@
+-- Venue claims: an admin associates a manager's email with a venue and sends them a signed
+-- token that lets them dispute events attributed to the venue
+CREATE TABLE IF NOT EXISTS venue_claims (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    venue_id UUID NOT NULL REFERENCES venues(id) ON DELETE CASCADE,
+    email VARCHAR(320) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_venue_claims_venue_id ON venue_claims(venue_id);
+
+-- Disputes are flags of type venue_dispute, tied to the claim whose token filed them
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS venue_claim_id UUID NULL REFERENCES venue_claims(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_flags_venue_claim_id ON flags(venue_claim_id, created_at);
@@ -0,0 +1,21 @@
+-- Runtime settings: values registered in code (services.RegisterSetting) and tuned through
+-- the admin API without a redeploy; a missing row means the env default applies
+CREATE TABLE IF NOT EXISTS settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value JSONB NOT NULL,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Append-only history of setting changes; a NULL value means the setting was unset
+CREATE TABLE IF NOT EXISTS setting_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    key VARCHAR(100) NOT NULL,
+    old_value JSONB NULL,
+    new_value JSONB NULL,
+    diff JSONB NOT NULL,
+    changed_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_setting_changes_key ON setting_changes(key, created_at);
@@ -0,0 +1,3 @@
+-- iCalendar SEQUENCE for each event, bumped whenever its calendar entry changes (edits,
+-- reschedules, unpublish and republish) so subscribed calendars update instead of duplicating
+ALTER TABLE events ADD COLUMN IF NOT EXISTS ics_sequence INTEGER NOT NULL DEFAULT 0;
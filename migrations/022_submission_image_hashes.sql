@@ -0,0 +1,8 @@
+-- Image hashes for spotting re-uploads of the same photo before the file is sent.
+-- image_sha256 and image_phash are computed by the server from the stored file; claimed_sha256
+-- is what the client reported when it asked for an upload URL, kept to spot clients that lie.
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS image_sha256 VARCHAR(64);
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS image_phash BIGINT;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS claimed_sha256 VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_submissions_image_sha256 ON submissions(image_sha256);
@@ -0,0 +1,14 @@
+-- Precomputed homepage map clusters: one row per window length and zoom level, rebuilt in the
+-- background when events change so the map endpoint only reads a row
+CREATE TABLE IF NOT EXISTS event_cluster_sets (
+    days INTEGER NOT NULL,
+    zoom INTEGER NOT NULL,
+    clusters JSONB NOT NULL DEFAULT '[]',
+    event_count INTEGER NOT NULL DEFAULT 0,
+    data_version BIGINT NOT NULL DEFAULT 0,  -- change feed seq the clusters were built at
+    window_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    window_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    compute_ms INTEGER NOT NULL DEFAULT 0,   -- how long the whole refresh took
+    PRIMARY KEY (days, zoom)
+);
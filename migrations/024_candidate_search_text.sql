@@ -0,0 +1,10 @@
+-- Admin candidate search: the searchable extracted fields (title, venue, organizer,
+-- description), lowercased, stored by the API on every write of a candidate's fields.
+-- A trigram index serves substring matches. Existing rows start empty; fill them with
+-- `wbctl maintenance backfill-search`.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE event_candidates ADD COLUMN IF NOT EXISTS search_text TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_event_candidates_search_text
+    ON event_candidates USING GIN (search_text gin_trgm_ops);
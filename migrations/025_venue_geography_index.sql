@@ -0,0 +1,6 @@
+-- Radius searches on /v1/events measure in meters with ST_DWithin on location::geography,
+-- which the geometry GIST index from 001 can't serve. Index the geography cast as well, and
+-- recreate the geometry index in case a database was built without it.
+CREATE INDEX IF NOT EXISTS idx_venues_location ON venues USING GIST (location);
+
+CREATE INDEX IF NOT EXISTS idx_venues_location_geography ON venues USING GIST ((location::geography));
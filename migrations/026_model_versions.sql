@@ -0,0 +1,24 @@
+-- OpenAI can change the model behind a name like "gpt-4o" without notice. Record the exact
+-- model (and system fingerprint) that served each flyer's extraction, and keep a registry
+-- of every served model per requested model so a new one can be reported when it appears.
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS vision_model VARCHAR(100);
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS vision_served_model VARCHAR(100);
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS vision_fingerprint VARCHAR(100);
+
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS vision_served_model VARCHAR(100);
+
+CREATE TABLE IF NOT EXISTS model_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    purpose VARCHAR(20) NOT NULL,          -- vision or moderation
+    requested_model VARCHAR(100) NOT NULL, -- the model we asked for
+    served_model VARCHAR(100) NOT NULL,    -- the model the response reported
+    last_fingerprint VARCHAR(100),
+    calls BIGINT NOT NULL DEFAULT 0,
+    first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_model_versions_served
+    ON model_versions (purpose, requested_model, served_model);
+
+CREATE INDEX IF NOT EXISTS idx_flyers_vision_served_model ON flyers (vision_served_model);
@@ -0,0 +1,11 @@
+-- Admin API keys generated through POST /admin/setup-key (or wbctl admin setup-key); only
+-- the bcrypt hash is kept, and generating a new key revokes the previous ones
+CREATE TABLE IF NOT EXISTS admin_api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    key_hash VARCHAR(100) NOT NULL,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_api_keys_active ON admin_api_keys(created_at) WHERE revoked_at IS NULL;
@@ -0,0 +1,19 @@
+-- Tombstones for hard-deleted events (DELETE /admin/events/:id). The event row, its history,
+-- and its state transitions are removed; the tombstone keeps only what the change feed, the
+-- calendar feed cancellation, and the re-publication cooldown need. The canonical key is
+-- stored hashed, so the removed title isn't kept.
+CREATE TABLE IF NOT EXISTS event_tombstones (
+    event_id UUID PRIMARY KEY,
+    canonical_key_hash VARCHAR(64) NOT NULL,
+    start_ts TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_ts TIMESTAMP WITH TIME ZONE NULL,
+    ics_sequence INTEGER NOT NULL DEFAULT 0,
+    reason VARCHAR(50) NOT NULL,
+    note TEXT NULL,
+    actor_type VARCHAR(20) NOT NULL,
+    admin_id VARCHAR(100) NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_tombstones_canonical_key_hash ON event_tombstones(canonical_key_hash, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_event_tombstones_start_ts ON event_tombstones(start_ts);
@@ -0,0 +1,7 @@
+-- derivative_image_url now holds the resized copy the vision model reads (derivative.jpg);
+-- the dashboard thumbnail, which it used to hold, gets its own column
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS thumbnail_url VARCHAR(500) NULL;
+
+UPDATE submissions
+SET thumbnail_url = derivative_image_url, derivative_image_url = NULL
+WHERE thumbnail_url IS NULL AND derivative_image_url LIKE '%/thumb.jpg';
@@ -0,0 +1,8 @@
+-- Near-duplicate events are merged automatically and confirmed or undone by an admin.
+-- A link is pending until then.
+ALTER TABLE dedupe_links ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';
+ALTER TABLE dedupe_links ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE dedupe_links ADD COLUMN IF NOT EXISTS resolved_by VARCHAR(100) NULL;
+
+CREATE INDEX IF NOT EXISTS idx_dedupe_links_status ON dedupe_links(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_dedupe_links_duplicate_event_id ON dedupe_links(duplicate_event_id);
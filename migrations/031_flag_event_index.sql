@@ -0,0 +1,2 @@
+-- Public flags are counted per event and reporter IP for rate limiting and the review threshold
+CREATE INDEX IF NOT EXISTS idx_flags_event_id ON flags(event_id, created_at);
@@ -0,0 +1,3 @@
+-- Token usage of the per-candidate moderation calls, summed per submission like the vision tokens
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS moderation_input_tokens INTEGER NULL;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS moderation_output_tokens INTEGER NULL;
@@ -0,0 +1,4 @@
+-- Submissions can carry several photos of the same flyers; each flyer records which photo it was found in
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS image_count INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS images_processed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS image_index INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Size of the photo each flyer's polygon was read from, so coordinates can be interpreted on their own
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS image_width INTEGER NULL;
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS image_height INTEGER NULL;
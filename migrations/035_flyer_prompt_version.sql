@@ -0,0 +1,3 @@
+-- Version (hash) of the vision prompt each flyer's candidates were extracted with
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(12) NULL;
+CREATE INDEX IF NOT EXISTS idx_flyers_prompt_version ON flyers(prompt_version);
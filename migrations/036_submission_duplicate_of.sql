@@ -0,0 +1,3 @@
+-- Uploads of an already-analyzed photo point at the earlier submission instead of being analyzed again
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS duplicate_of_id UUID NULL REFERENCES submissions(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_submissions_duplicate_of_id ON submissions(duplicate_of_id);
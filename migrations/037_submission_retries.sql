@@ -0,0 +1,3 @@
+-- Automatic retries of failed submissions, and why processing last failed
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS retry_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS last_error TEXT NULL;
@@ -0,0 +1,7 @@
+-- Full-text search over public events: the keyword (or q) filter matches search_vector
+-- with plainto_tsquery, so it stems words and ignores stop words, and sort=relevance ranks
+-- by ts_rank. Postgres keeps the column in step with title and description.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', coalesce(title, '') || ' ' || coalesce(description, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_events_search_vector ON events USING GIN (search_vector);
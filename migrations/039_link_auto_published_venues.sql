@@ -0,0 +1,13 @@
+-- Auto-published events were created without a venue, so they had no geometry and dropped
+-- out of the default listing, bbox, radius, near, tiles, and clusters. Link each to the venue
+-- its candidate names, matching names case-insensitively as publishing does.
+UPDATE events e
+SET venue_id = linked.venue_id, updated_at = NOW()
+FROM (
+    SELECT DISTINCT ON (ec.published_event_id) ec.published_event_id AS event_id, v.id AS venue_id
+    FROM event_candidates ec
+    JOIN venues v ON lower(v.name) = lower(ec.fields->>'venue')
+    WHERE ec.published_event_id IS NOT NULL
+    ORDER BY ec.published_event_id, (v.location IS NULL), v.created_at
+) linked
+WHERE e.id = linked.event_id AND e.venue_id IS NULL AND e.published_via = 'auto';
@@ -0,0 +1,3 @@
+-- Each detected flyer's crop is also stored as crop_<region_id>.jpg beside the submitter's
+-- photo, and its URL shown on the submission status page until a redacted copy exists
+ALTER TABLE flyers ADD COLUMN IF NOT EXISTS region_crop_url VARCHAR(500);